@@ -1,13 +1,25 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+
 	"sales-track/internal/database"
+	"sales-track/internal/ecb"
+	"sales-track/internal/export"
+	"sales-track/internal/membudget"
 	"sales-track/internal/models"
 	"sales-track/internal/parser"
 )
@@ -15,7 +27,11 @@ import (
 // App struct
 type App struct {
 	ctx       context.Context
-	dbService *database.Service
+	dbService *database.Service // Set once at startup; bindings use service()
+	dbPath    string
+	dbErr     error                                  // Why the database failed to open at startup
+	emit      func(name string, data ...interface{}) // Frontend event emitter; nil outside Wails
+	lock      appLock                                // Holds the database back while the app lock is engaged
 	// Removed parser field to avoid shared state and cross-request side effects
 }
 
@@ -30,11 +46,19 @@ func NewApp() *App {
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
+	a.emit = func(name string, data ...interface{}) {
+		runtime.EventsEmit(ctx, name, data...)
+	}
 	
 	// Initialize database service
-	dbPath := filepath.Join(".", "sales_track.db")
+	a.dbPath = filepath.Join(".", "sales_track.db")
+	if applied, err := database.ApplyStagedWorkspace(a.dbPath); err != nil {
+		log.Printf("Failed to apply the imported workspace: %v", err)
+	} else if applied {
+		log.Printf("Applied the imported workspace; the previous database was kept as %s", a.dbPath+database.ReplacedDatabaseSuffix)
+	}
 	config := database.Config{
-		FilePath:    dbPath,
+		FilePath:    a.dbPath,
 		InMemory:    false,
 		AutoMigrate: true, // Enable auto-migration
 	}
@@ -42,11 +66,39 @@ func (a *App) startup(ctx context.Context) {
 	dbService, err := database.NewService(config)
 	if err != nil {
 		log.Printf("Failed to initialize database: %v", err)
+		a.dbErr = err
 		return
 	}
 	
+	// Forward change-feed events so open views can refresh after writes
+	dbService.SetEventEmitter(func(name string, payload interface{}) {
+		a.emitEvent(name, payload)
+	})
+
 	a.dbService = dbService
 	log.Println("Database service initialized successfully")
+
+	// With an app lock set, nothing is shown until the PIN or password is entered
+	if _, err := a.engageLock(); err != nil {
+		log.Printf("Failed to read the app lock: %v", err)
+	}
+
+	// Apply the retention policy at startup and daily while the app is open
+	scheduler := database.NewMaintenanceScheduler(dbService, database.DefaultMaintenanceInterval, func(result *models.RetentionResult, err error) {
+		if err != nil {
+			log.Printf("Scheduled maintenance failed: %v", err)
+		} else if result != nil && result.Changed() {
+			log.Printf("Scheduled maintenance archived %d records and purged %d deleted records and %d import summaries",
+				result.ArchivedRecords, result.PurgedDeletedRecords, result.PurgedImportRuns)
+		}
+	})
+	go scheduler.Run(ctx)
+
+	// Refresh the dashboard's cached reports after imports and when idle
+	refresher := database.NewCacheRefresher(dbService, func(err error) {
+		log.Printf("Refreshing cached reports failed: %v", err)
+	})
+	go refresher.Run(ctx)
 }
 
 // Greet returns a greeting for the given name
@@ -56,19 +108,25 @@ func (a *App) Greet(name string) string {
 
 // ImportHTMLData imports HTML table data into the database
 func (a *App) ImportHTMLData(htmlData string) (*ImportResult, error) {
-	if a.dbService == nil {
-		return nil, fmt.Errorf("database service not initialized")
-	}
+	return a.trackImport(models.ImportSourcePaste, nil, strings.NewReader(htmlData), "single", func(svc *database.Service) (*ImportResult, error) {
+		return a.importHTMLData(svc, htmlData)
+	})
+}
 
+// importHTMLData imports records one at a time, keeping those that succeed
+func (a *App) importHTMLData(svc *database.Service, htmlData string) (*ImportResult, error) {
 	// Create fresh parser instance to avoid cross-request side effects
 	parser := parser.NewHTMLTableParser()
+	if err := setIgnoreRules(svc, parser); err != nil {
+		return nil, err
+	}
 
 	// Parse HTML data
 	parseResult, err := parser.ParseHTML(htmlData)
 	if err != nil {
 		return &ImportResult{
 			Success:      false,
-			ErrorMessage: fmt.Sprintf("Failed to parse HTML data: %v", err),
+			Error:        parseFailure(err),
 			TotalRows:    0,
 			ParsedRows:   0,
 		}, nil
@@ -78,11 +136,14 @@ func (a *App) ImportHTMLData(htmlData string) (*ImportResult, error) {
 	var importedRecords []models.SalesRecord
 	var importErrors []ImportError
 
-	for _, record := range parseResult.Records {
+	// The rows are announced together once written, not one event per row
+	rows := svc.ForRowImport()
+	for i, record := range parseResult.Records {
 		// Import individual record
-		savedRecord, err := a.dbService.CreateSalesRecord(record)
+		savedRecord, err := rows.CreateSalesRecord(record)
 		if err != nil {
 			importErrors = append(importErrors, ImportError{
+				Index:  i,
 				Record: record,
 				Error:  err.Error(),
 			})
@@ -90,24 +151,32 @@ func (a *App) ImportHTMLData(htmlData string) (*ImportResult, error) {
 		}
 		importedRecords = append(importedRecords, *savedRecord)
 	}
+	if len(importedRecords) > 0 {
+		rows.CompleteRowImport(database.ImportCompletedEvent{Inserted: len(importedRecords), Failed: len(importErrors)})
+	}
 
 	// Prepare result
 	result := &ImportResult{
 		Success:           len(importedRecords) > 0,
 		TotalRows:         parseResult.TotalRows,
+		IgnoredRows:       parseResult.IgnoredRows,
+		fees:              parseResult.Fees,
+		quarantined:       parseResult.Quarantined,
+		contentHash:       parseResult.ContentHash,
 		ParsedRows:        parseResult.SuccessCount,
 		ImportedRows:      len(importedRecords),
 		ParseErrors:       parseResult.Errors,
 		ImportErrors:      importErrors,
 		ProcessingTime:    parseResult.Statistics.ProcessingTime,
+		Timings:           parseResult.Statistics.Timings(),
 		ImportedRecords:   importedRecords,
 		ColumnMapping:     parseResult.ColumnMapping,
+		Title:             parseResult.Title(),
 		DataTypesDetected: parseResult.Statistics.DataTypesDetected,
 	}
 
 	if len(importErrors) > 0 {
-		result.ErrorMessage = fmt.Sprintf("Imported %d of %d records. %d records failed to import.", 
-			len(importedRecords), parseResult.SuccessCount, len(importErrors))
+		result.Error = partialImportFailure(len(importedRecords), parseResult.SuccessCount, len(importErrors))
 	}
 
 	return result, nil
@@ -115,19 +184,25 @@ func (a *App) ImportHTMLData(htmlData string) (*ImportResult, error) {
 
 // ImportHTMLDataBatch imports HTML data using batch operations for better performance
 func (a *App) ImportHTMLDataBatch(htmlData string) (*ImportResult, error) {
-	if a.dbService == nil {
-		return nil, fmt.Errorf("database service not initialized")
-	}
+	return a.trackImport(models.ImportSourcePaste, nil, strings.NewReader(htmlData), "batch", func(svc *database.Service) (*ImportResult, error) {
+		return a.importHTMLDataBatch(svc, htmlData)
+	})
+}
 
+// importHTMLDataBatch imports all records in a single batch
+func (a *App) importHTMLDataBatch(svc *database.Service, htmlData string) (*ImportResult, error) {
 	// Create fresh parser instance to avoid cross-request side effects
 	parser := parser.NewHTMLTableParser()
+	if err := setIgnoreRules(svc, parser); err != nil {
+		return nil, err
+	}
 
 	// Parse HTML data
 	parseResult, err := parser.ParseHTML(htmlData)
 	if err != nil {
 		return &ImportResult{
 			Success:      false,
-			ErrorMessage: fmt.Sprintf("Failed to parse HTML data: %v", err),
+			Error:        parseFailure(err),
 			TotalRows:    0,
 			ParsedRows:   0,
 			ParseErrors:  nil, // Will be nil for complete parse failures,
@@ -135,11 +210,11 @@ func (a *App) ImportHTMLDataBatch(htmlData string) (*ImportResult, error) {
 	}
 
 	// Use batch import for better performance
-	importedRecords, err := a.dbService.CreateSalesRecordsBatch(parseResult.Records)
+	importedRecords, err := svc.CreateSalesRecordsBatch(parseResult.Records)
 	if err != nil {
 		return &ImportResult{
 			Success:      false,
-			ErrorMessage: fmt.Sprintf("Failed to import records: %v", err),
+			Error:        importFailure(err),
 			TotalRows:    parseResult.TotalRows,
 			ParsedRows:   parseResult.SuccessCount,
 			ParseErrors:  parseResult.Errors,
@@ -150,11 +225,17 @@ func (a *App) ImportHTMLDataBatch(htmlData string) (*ImportResult, error) {
 	result := &ImportResult{
 		Success:           true,
 		TotalRows:         parseResult.TotalRows,
+		IgnoredRows:       parseResult.IgnoredRows,
+		fees:              parseResult.Fees,
+		quarantined:       parseResult.Quarantined,
+		contentHash:       parseResult.ContentHash,
 		ParsedRows:        parseResult.SuccessCount,
 		ImportedRows:      len(importedRecords),
 		ProcessingTime:    parseResult.Statistics.ProcessingTime,
+		Timings:           parseResult.Statistics.Timings(),
 		ImportedRecords:   importedRecords,
 		ColumnMapping:     parseResult.ColumnMapping,
+		Title:             parseResult.Title(),
 		DataTypesDetected: parseResult.Statistics.DataTypesDetected,
 	}
 
@@ -163,53 +244,558 @@ func (a *App) ImportHTMLDataBatch(htmlData string) (*ImportResult, error) {
 
 // ImportHTMLDataWithOptions imports HTML data with parsing options
 func (a *App) ImportHTMLDataWithOptions(htmlData string, options ImportOptions) (*ImportResult, error) {
-	if a.dbService == nil {
-		return nil, fmt.Errorf("database service not initialized")
+	return a.trackImport(models.ImportSourcePaste, nil, strings.NewReader(htmlData), "options", func(svc *database.Service) (*ImportResult, error) {
+		return a.importHTMLDataWithOptions(svc, htmlData, options)
+	})
+}
+
+// importHTMLDataWithOptions runs the import selected by options
+func (a *App) importHTMLDataWithOptions(svc *database.Service, htmlData string, options ImportOptions) (*ImportResult, error) {
+	options = resolveAutoLayout(options, htmlData)
+	parser, err := newParserWithOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	if err := setIgnoreRules(svc, parser); err != nil {
+		return nil, err
 	}
 
-	// Create fresh parser instance to avoid cross-request side effects
-	parser := parser.NewHTMLTableParser()
+	// Data too large to parse whole within the memory budget is parsed and
+	// saved in chunks by the streaming pipeline, when the parser can stream it.
+	// Blocking duplicate content needs the chunks in one transaction.
+	if !options.Upsert && exceedsParseBudget(svc, htmlData) && parser.CanStream(htmlData) {
+		atomic := options.atomic() || options.DuplicateContent == DuplicateContentBlock
+		options.Atomic = &atomic
+		result, err := a.importHTMLStream(svc, strings.NewReader(htmlData), options)
+		if result != nil {
+			result.Chunked = true
+		}
+		return result, err
+	}
+
+	importFn := a.importHTMLDataWithParser
+	if options.UseBatchImport {
+		importFn = a.importHTMLDataBatchWithParser
+	}
+	if options.Upsert {
+		importFn = a.importHTMLDataUpsertWithParser
+	}
+
+	if !options.DryRun {
+		result, err := importFn(svc, htmlData, parser, options)
+		if result != nil {
+			result.Layout = options.Layout
+		}
+		return result, err
+	}
+
+	// Dry run: run the full pipeline inside a transaction that is always
+	// rolled back, and report exactly what would have been written
+	var result *ImportResult
+	err = svc.DryRun(func(txService *database.Service) error {
+		var err error
+		result, err = importFn(txService, htmlData, parser, options)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.DryRun = true
+	result.Layout = options.Layout
+	return result, nil
+}
+
+// ImportHTMLFile imports the HTML table in a file using the streaming pipeline,
+// so large exports can be imported without loading them into memory. Pages
+// saved as MHTML (.mht) or Safari web archives, and data URLs, are read whole
+// to take the page out of its container.
+func (a *App) ImportHTMLFile(path string, options ImportOptions) (*ImportResult, error) {
+	if _, err := a.service(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, newAppErrorf(err, "failed to open import file")
+	}
+	defer file.Close()
+
+	// The source is read from the start of the file once the import finishes,
+	// independently of the import's reads
+	source := io.NewSectionReader(file, 0, models.MaxImportSourceSize+1)
+
+	// Pages saved as MHTML, web archives or data URLs are unwrapped first. The
+	// import history keeps the file as it was saved.
+	content := bufio.NewReaderSize(file, parser.ContainerSniffSize)
+	prefix, _ := content.Peek(parser.ContainerSniffSize)
+	if parser.DetectContainer(prefix) != "" {
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return nil, newAppErrorf(err, "failed to read import file")
+		}
+		page, err := parser.ExtractHTML(data)
+		if err != nil {
+			return a.trackImport(models.ImportSourceFile, &path, source, "options", func(*database.Service) (*ImportResult, error) {
+				return &ImportResult{Success: false, Error: parseFailure(err)}, nil
+			})
+		}
+		return a.importFileData(path, source, string(page), options)
+	}
+
+	// Multi-table reports need the whole page, so they are not streamed
+	if options.MultiTable {
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return nil, newAppErrorf(err, "failed to read import file")
+		}
+		return a.importFileData(path, source, string(data), options)
+	}
+
+	return a.trackImport(models.ImportSourceFile, &path, source, "stream", func(svc *database.Service) (*ImportResult, error) {
+		return a.importHTMLStream(svc, content, options)
+	})
+}
+
+// importFileData imports the page read from a file, streaming it unless it
+// is a multi-table report, which needs the whole page
+func (a *App) importFileData(path string, source io.Reader, data string, options ImportOptions) (*ImportResult, error) {
+	if options.MultiTable {
+		return a.trackImport(models.ImportSourceFile, &path, source, "options", func(svc *database.Service) (*ImportResult, error) {
+			return a.importHTMLDataWithOptions(svc, data, options)
+		})
+	}
+	return a.trackImport(models.ImportSourceFile, &path, source, "stream", func(svc *database.Service) (*ImportResult, error) {
+		return a.importHTMLStream(svc, strings.NewReader(data), options)
+	})
+}
+
+// ImportHTMLDataStream imports HTML data using the streaming pipeline. Records
+// flow from the parser to the database in chunks instead of being collected
+// first, and an "import:progress" event is emitted after each chunk.
+func (a *App) ImportHTMLDataStream(htmlData string, options ImportOptions) (*ImportResult, error) {
+	if _, err := a.service(); err != nil {
+		return nil, err
+	}
+
+	return a.trackImport(models.ImportSourcePaste, nil, strings.NewReader(htmlData), "stream", func(svc *database.Service) (*ImportResult, error) {
+		return a.importHTMLStream(svc, strings.NewReader(htmlData), options)
+	})
+}
+
+// trackImport runs an import and records its summary in the import history,
+// along with the raw content read from content. The import is recorded as it
+// starts and run writes through a service that attributes records to it, so
+// each record can be traced back to its import, and that files them under
+// the canonical names of aliased stores and vendors. Dry runs and imports
+// that fail before producing a result are removed from the history again,
+// and a failure to record is logged rather than failing the import itself.
+// A result whose parsed records match an earlier successful import names
+// that import in DuplicateOf.
+func (a *App) trackImport(source string, fileName *string, content io.Reader, method string, run func(*database.Service) (*ImportResult, error)) (*ImportResult, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	svc, err := service.WithNameAliases()
+	if err != nil {
+		return nil, newAppErrorf(err, "failed to load name aliases")
+	}
+	recorded, err := service.RecordImport(models.ImportRun{
+		StartedAt: started,
+		Source:    source,
+		FileName:  fileName,
+		Method:    method,
+	})
+	if err != nil {
+		log.Printf("Failed to record import history: %v", err)
+	} else {
+		svc = svc.ForImport(recorded.ID)
+	}
+
+	result, err := run(svc)
+	if result != nil {
+		result.SchemaVersion = parser.SchemaVersion
+	}
+	if result != nil && result.Error != nil {
+		result.ErrorMessage = result.Error.Message
+	}
+	if result != nil && result.contentHash != "" && result.DuplicateOf == nil {
+		earlier, err := service.FindDuplicateImport(result.contentHash)
+		if err != nil {
+			log.Printf("Failed to look for an earlier import of the same content: %v", err)
+		}
+		result.DuplicateOf = earlier
+	}
+	if result != nil {
+		result.FeeRows = len(result.fees)
+		result.QuarantinedRows = len(result.quarantined)
+		written := svc.ImportTimings()
+		result.Timings.Dedupe, result.Timings.Insert = written.Dedupe, written.Insert
+	}
+	if err != nil || result == nil || result.DryRun {
+		if recorded != nil {
+			if err := service.DiscardImport(recorded.ID); err != nil {
+				log.Printf("Failed to discard import history: %v", err)
+			}
+		}
+		return result, err
+	}
+	// Fee lines are kept with the records of the import, and dropped with them
+	// when it was rolled back
+	if (result.Success || result.Error == nil) && len(result.fees) > 0 {
+		if _, err := svc.CreateFees(result.fees); err != nil {
+			result.Error = newAppErrorf(err, "failed to record fees")
+			result.ErrorMessage = result.Error.Message
+		}
+	}
+	// Quarantined rows wait for review only when the import they were read in
+	// was kept
+	if (result.Success || result.Error == nil) && len(result.quarantined) > 0 {
+		if _, err := svc.QuarantineRows(result.quarantined); err != nil {
+			result.Error = newAppErrorf(err, "failed to keep quarantined rows")
+			result.ErrorMessage = result.Error.Message
+		}
+	}
+	if recorded == nil {
+		return result, nil
+	}
+
+	summary := models.ImportRun{
+		ID:            recorded.ID,
+		StartedAt:     started,
+		Source:        source,
+		FileName:      fileName,
+		Method:        method,
+		Success:       result.Success,
+		TotalRows:     result.TotalRows,
+		ParsedRows:    result.ParsedRows,
+		ImportedRows:  result.ImportedRows,
+		UpdatedRows:   result.UpdatedRows,
+		UnchangedRows: result.UnchangedRows,
+		ErrorRows:     result.TotalRows - result.ParsedRows + len(result.ImportErrors),
+		Duration:      models.Duration(time.Since(started)),
+		Timings:       &result.Timings,
+	}
+	if result.Layout != "" {
+		summary.Layout = &result.Layout
+	}
+	if result.Title != "" {
+		summary.Title = &result.Title
+	}
+	label := importLabel(source, fileName, result.Layout)
+	summary.Name = &label
+	if result.ErrorMessage != "" {
+		summary.ErrorMessage = &result.ErrorMessage
+	}
+	if result.contentHash != "" {
+		summary.ContentHash = &result.contentHash
+	}
+
+	if err := service.FinishImport(summary); err != nil {
+		log.Printf("Failed to record import history: %v", err)
+		return result, nil
+	}
+	if err := service.SaveImportSource(recorded.ID, content); err != nil {
+		log.Printf("Failed to keep import source: %v", err)
+	}
+	return result, nil
+}
+
+// importLabel names where an import came from: the platform of its layout,
+// the imported file, or pasted data
+func importLabel(source string, fileName *string, layout string) string {
+	switch {
+	case layout != "":
+		return parser.LayoutLabel(layout)
+	case fileName != nil:
+		name := filepath.Base(*fileName)
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	case source == models.ImportSourcePaste:
+		return "Pasted data"
+	}
+	return "Imported file"
+}
+
+// importHTMLStream runs the parser and the chunked inserter concurrently,
+// connected by a channel. Streaming imports are atomic unless options.Atomic
+// is explicitly false, in which case each chunk is committed on its own.
+func (a *App) importHTMLStream(svc *database.Service, r io.Reader, options ImportOptions) (*ImportResult, error) {
+	// Layout detection only needs the first rows, so it inspects a buffered prefix
+	if strings.EqualFold(options.Layout, parser.AutoLayout) {
+		buffered := bufio.NewReaderSize(r, detectSampleSize)
+		sample, _ := buffered.Peek(detectSampleSize)
+		options = resolveAutoLayout(options, string(sample))
+		r = buffered
+	}
+
+	htmlParser, err := newParserWithOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	if err := setIgnoreRules(svc, htmlParser); err != nil {
+		return nil, err
+	}
+	if options.DuplicateContent == DuplicateContentBlock && !options.DryRun && options.Atomic != nil && !*options.Atomic {
+		err := fmt.Errorf("blocking duplicate content requires an atomic streaming import")
+		return nil, &AppError{Code: ErrCodeValidation, Message: err.Error(), Details: map[string]interface{}{"field": "duplicate_content"}, cause: err}
+	}
+
+	var parseResult *parser.ParseResult
+	var inserted int
+	var insertErr error
+
+	run := func(svc *database.Service) error {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		records := make(chan models.CreateSalesRecordRequest, streamChunkSize)
+		parseDone := make(chan error, 1)
+		go func() {
+			var err error
+			parseResult, err = htmlParser.ParseHTMLStream(ctx, r, records)
+			parseDone <- err
+		}()
+
+		chunks := 0
+		inserted, insertErr = svc.CreateSalesRecordsStream(records, streamChunkSize, func(rowsInserted, rowsUpdated int) {
+			chunks++
+			a.emitEvent(importProgressEvent, ImportProgress{RowsInserted: rowsInserted, RowsUpdated: rowsUpdated, Chunks: chunks})
+		})
+		if insertErr != nil {
+			// Unblock the parser before waiting for it
+			cancel()
+			<-parseDone
+			return insertErr
+		}
+		if err := <-parseDone; err != nil {
+			return err
+		}
+		// The records were inserted as they were parsed, so content already
+		// imported is refused by rolling them back
+		return duplicateContentError(svc, parseResult.ContentHash, options)
+	}
+
+	switch {
+	case options.DryRun:
+		err = svc.DryRun(run)
+	case options.Atomic != nil && !*options.Atomic:
+		err = run(svc)
+	default:
+		err = svc.ExecTx(run)
+	}
+
+	if parseResult == nil {
+		// The parser only returns without a result when it failed or was
+		// cancelled because inserting failed
+		failure := parseFailure(err)
+		if insertErr != nil {
+			failure = importFailure(insertErr)
+		}
+		return &ImportResult{
+			Success: false,
+			Error:   failure,
+			DryRun:  options.DryRun,
+		}, nil
+	}
+
+	result := &ImportResult{
+		Success:           err == nil && inserted > 0,
+		TotalRows:         parseResult.TotalRows,
+		IgnoredRows:       parseResult.IgnoredRows,
+		fees:              parseResult.Fees,
+		quarantined:       parseResult.Quarantined,
+		contentHash:       parseResult.ContentHash,
+		ParsedRows:        parseResult.SuccessCount,
+		ImportedRows:      inserted,
+		ParseErrors:       parseResult.Errors,
+		ProcessingTime:    parseResult.Statistics.ProcessingTime,
+		Timings:           parseResult.Statistics.Timings(),
+		ColumnMapping:     parseResult.ColumnMapping,
+		Title:             parseResult.Title(),
+		DataTypesDetected: parseResult.Statistics.DataTypesDetected,
+		DryRun:            options.DryRun,
+		Layout:            options.Layout,
+	}
+	if err != nil {
+		result.Error = importFailure(err)
+		if options.Atomic == nil || *options.Atomic {
+			result.ImportedRows = 0
+		}
+	}
+
+	return result, nil
+}
+
+// exceedsParseBudget reports whether parsing data whole would take more memory
+// than the saved memory budget
+func exceedsParseBudget(svc *database.Service, data string) bool {
+	budget, err := svc.GetMemoryBudget()
+	if err != nil {
+		log.Printf("Failed to load memory budget: %v", err)
+	}
+	return parser.EstimateParseMemory(len(data)) > budget.Bytes()
+}
+
+// resolveAutoLayout replaces the "auto" layout with the layout detected in
+// sample, or clears it so generic header matching is used when no layout is a
+// confident match
+func resolveAutoLayout(options ImportOptions, sample string) ImportOptions {
+	if !strings.EqualFold(options.Layout, parser.AutoLayout) {
+		return options
+	}
+
+	options.Layout = ""
+	matches := parser.DetectLayout(sample)
+	if len(matches) > 0 && matches[0].Confidence >= parser.MinAutoDetectConfidence {
+		options.Layout = matches[0].Layout
+	}
+	return options
+}
 
-	// Configure parser based on options (no shared state concerns)
-	if options.UseConsignableFormat {
-		parser.SetConsignableMapping()
+// newParserWithOptions creates a fresh parser configured from import options,
+// avoiding shared state between requests
+func newParserWithOptions(options ImportOptions) (*parser.HTMLTableParser, error) {
+	p := parser.NewHTMLTableParser()
+
+	if options.Mapping != nil {
+		if err := p.SetCSVMapping(*options.Mapping); err != nil {
+			return nil, &AppError{Code: ErrCodeValidation, Message: err.Error(), Details: map[string]interface{}{"field": "mapping"}, cause: err}
+		}
+	} else if options.Layout != "" {
+		if err := p.SetLayout(options.Layout); err != nil {
+			return nil, &AppError{Code: ErrCodeValidation, Message: err.Error(), Details: map[string]interface{}{"field": "layout"}, cause: err}
+		}
+	} else if options.UseConsignableFormat {
+		p.SetConsignableMapping()
 	} else if len(options.CustomColumnMapping) > 0 {
-		parser.SetPositionalMapping(options.CustomColumnMapping)
+		p.SetPositionalMapping(options.CustomColumnMapping)
 	}
 
 	// Set strict mode if requested
-	parser.StrictMode = options.StrictMode
+	p.StrictMode = options.StrictMode
+	p.MultiTable = options.MultiTable
+	p.KeepUnmappedColumns = options.KeepUnmappedColumns
+	p.Quarantine = options.Quarantine
+	if options.Defaults != nil {
+		if err := options.Defaults.Validate(); err != nil {
+			return nil, &AppError{Code: ErrCodeValidation, Message: err.Error(), Details: map[string]interface{}{"field": "defaults"}, cause: err}
+		}
+		p.Defaults = *options.Defaults
+	}
+	if options.YearInference != nil {
+		if err := options.YearInference.Validate(); err != nil {
+			return nil, &AppError{Code: ErrCodeValidation, Message: err.Error(), Details: map[string]interface{}{"field": "year_inference"}, cause: err}
+		}
+		p.YearInference = *options.YearInference
+	}
+	switch options.DuplicateContent {
+	case "", DuplicateContentWarn, DuplicateContentBlock:
+	default:
+		err := fmt.Errorf("unknown duplicate content handling %q; use %q or %q", options.DuplicateContent, DuplicateContentWarn, DuplicateContentBlock)
+		return nil, &AppError{Code: ErrCodeValidation, Message: err.Error(), Details: map[string]interface{}{"field": "duplicate_content"}, cause: err}
+	}
+	if err := p.SetColumnOverrides(options.ColumnOverrides); err != nil {
+		return nil, &AppError{Code: ErrCodeValidation, Message: err.Error(), Details: map[string]interface{}{"field": "column_overrides"}, cause: err}
+	}
 
-	// Use batch import if available
-	if options.UseBatchImport {
-		return a.importHTMLDataBatchWithParser(htmlData, parser)
+	return p, nil
+}
+
+// duplicateContentError returns the error refusing an import whose parsed
+// records have the content hash hash when options block report content that
+// an earlier import already imported, or nil to go ahead
+func duplicateContentError(svc *database.Service, hash string, options ImportOptions) error {
+	if options.DuplicateContent != DuplicateContentBlock {
+		return nil
+	}
+	earlier, err := svc.FindDuplicateImport(hash)
+	if err != nil {
+		return err
+	}
+	if earlier != nil {
+		return duplicateImportFailure(earlier)
+	}
+	return nil
+}
+
+// refuseDuplicate returns the result of an import of parseResult refused by
+// duplicateContentError, or nil to go ahead with the import
+func refuseDuplicate(svc *database.Service, parseResult *parser.ParseResult, options ImportOptions) (*ImportResult, error) {
+	err := duplicateContentError(svc, parseResult.ContentHash, options)
+	if err == nil {
+		return nil, nil
+	}
+	return &ImportResult{
+		Success:           false,
+		Error:             importFailure(err),
+		TotalRows:         parseResult.TotalRows,
+		IgnoredRows:       parseResult.IgnoredRows,
+		ParsedRows:        parseResult.SuccessCount,
+		ParseErrors:       parseResult.Errors,
+		ProcessingTime:    parseResult.Statistics.ProcessingTime,
+		Timings:           parseResult.Statistics.Timings(),
+		ColumnMapping:     parseResult.ColumnMapping,
+		Title:             parseResult.Title(),
+		Tables:            parseResult.Tables,
+		DataTypesDetected: parseResult.Statistics.DataTypesDetected,
+		contentHash:       parseResult.ContentHash,
+	}, nil
+}
+
+// setIgnoreRules makes p skip the rows matching the saved ignore rules
+func setIgnoreRules(svc *database.Service, p *parser.HTMLTableParser) error {
+	rules, err := svc.GetIgnoreRules()
+	if err != nil {
+		return newAppErrorf(err, "failed to load ignore rules")
+	}
+	if err := p.SetIgnoreRules(rules); err != nil {
+		return newAppErrorf(err, "failed to apply ignore rules")
 	}
+	return nil
+}
 
-	return a.importHTMLDataWithParser(htmlData, parser)
+// emitEvent sends an event to the frontend when running inside Wails
+func (a *App) emitEvent(name string, data ...interface{}) {
+	if a.emit != nil {
+		a.emit(name, data...)
+	}
 }
 
 // importHTMLDataWithParser imports HTML data using the provided parser instance
-func (a *App) importHTMLDataWithParser(htmlData string, parser *parser.HTMLTableParser) (*ImportResult, error) {
+func (a *App) importHTMLDataWithParser(svc *database.Service, htmlData string, parser *parser.HTMLTableParser, options ImportOptions) (*ImportResult, error) {
 	// Parse HTML data
 	parseResult, err := parser.ParseHTML(htmlData)
 	if err != nil {
 		return &ImportResult{
 			Success:      false,
-			ErrorMessage: fmt.Sprintf("Failed to parse HTML data: %v", err),
+			Error:        parseFailure(err),
 			TotalRows:    0,
 			ParsedRows:   0,
 		}, nil
 	}
 
+	if refused, err := refuseDuplicate(svc, parseResult, options); refused != nil || err != nil {
+		return refused, err
+	}
+
+	if options.atomic() {
+		return a.importRecordsAtomic(svc, parseResult)
+	}
+
 	// Convert parsed records to database format and import
 	var importedRecords []models.SalesRecord
 	var importErrors []ImportError
 
-	for _, record := range parseResult.Records {
+	// The rows are announced together once written, not one event per row
+	rows := svc.ForRowImport()
+	for i, record := range parseResult.Records {
 		// Import individual record
-		savedRecord, err := a.dbService.CreateSalesRecord(record)
+		savedRecord, err := rows.CreateSalesRecord(record)
 		if err != nil {
 			importErrors = append(importErrors, ImportError{
+				Index:  i,
 				Record: record,
 				Error:  err.Error(),
 			})
@@ -217,49 +803,139 @@ func (a *App) importHTMLDataWithParser(htmlData string, parser *parser.HTMLTable
 		}
 		importedRecords = append(importedRecords, *savedRecord)
 	}
+	if len(importedRecords) > 0 {
+		rows.CompleteRowImport(database.ImportCompletedEvent{Inserted: len(importedRecords), Failed: len(importErrors)})
+	}
 
 	// Prepare result
 	result := &ImportResult{
 		Success:           len(importedRecords) > 0,
 		TotalRows:         parseResult.TotalRows,
+		IgnoredRows:       parseResult.IgnoredRows,
+		fees:              parseResult.Fees,
+		quarantined:       parseResult.Quarantined,
+		contentHash:       parseResult.ContentHash,
 		ParsedRows:        parseResult.SuccessCount,
 		ImportedRows:      len(importedRecords),
 		ParseErrors:       parseResult.Errors,
 		ImportErrors:      importErrors,
 		ProcessingTime:    parseResult.Statistics.ProcessingTime,
+		Timings:           parseResult.Statistics.Timings(),
 		ImportedRecords:   importedRecords,
 		ColumnMapping:     parseResult.ColumnMapping,
+		Title:             parseResult.Title(),
+		Tables:            parseResult.Tables,
 		DataTypesDetected: parseResult.Statistics.DataTypesDetected,
 	}
 
 	if len(importErrors) > 0 {
-		result.ErrorMessage = fmt.Sprintf("Imported %d of %d records. %d records failed to import.", 
-			len(importedRecords), parseResult.SuccessCount, len(importErrors))
+		result.Error = partialImportFailure(len(importedRecords), parseResult.SuccessCount, len(importErrors))
 	}
 
 	return result, nil
 }
 
+// importRecordsAtomic imports parsed records one by one inside a single
+// transaction; any failure rolls back the whole import
+func (a *App) importRecordsAtomic(svc *database.Service, parseResult *parser.ParseResult) (*ImportResult, error) {
+	var importedRecords []models.SalesRecord
+	var failure *ImportError
+	var failedIndex int
+
+	err := svc.ForRowImport().ExecTx(func(txService *database.Service) error {
+		for i, record := range parseResult.Records {
+			savedRecord, err := txService.CreateSalesRecord(record)
+			if err != nil {
+				failure = &ImportError{Index: i, Record: record, Error: err.Error()}
+				failedIndex = i
+				return err
+			}
+			importedRecords = append(importedRecords, *savedRecord)
+		}
+		txService.CompleteRowImport(database.ImportCompletedEvent{Inserted: len(importedRecords)})
+		return nil
+	})
+
+	if err != nil {
+		result := &ImportResult{
+			Success:           false,
+			TotalRows:         parseResult.TotalRows,
+			IgnoredRows:       parseResult.IgnoredRows,
+			fees:              parseResult.Fees,
+			quarantined:       parseResult.Quarantined,
+			contentHash:       parseResult.ContentHash,
+			ParsedRows:        parseResult.SuccessCount,
+			ParseErrors:       parseResult.Errors,
+			ProcessingTime:    parseResult.Statistics.ProcessingTime,
+			Timings:           parseResult.Statistics.Timings(),
+			ColumnMapping:     parseResult.ColumnMapping,
+			Title:             parseResult.Title(),
+			Tables:            parseResult.Tables,
+			DataTypesDetected: parseResult.Statistics.DataTypesDetected,
+		}
+		if failure != nil {
+			result.ImportErrors = []ImportError{*failure}
+			result.Error = &AppError{
+				Code: ErrCodeRolledBack,
+				Message: fmt.Sprintf("Import rolled back: record %d of %d failed: %s",
+					failedIndex+1, len(parseResult.Records), failure.Error),
+				Details: map[string]interface{}{"record": failedIndex + 1, "total": len(parseResult.Records)},
+				cause:   err,
+			}
+		} else {
+			result.Error = rolledBackFailure(err)
+		}
+		return result, nil
+	}
+
+	return &ImportResult{
+		Success:           len(importedRecords) > 0,
+		TotalRows:         parseResult.TotalRows,
+		IgnoredRows:       parseResult.IgnoredRows,
+		fees:              parseResult.Fees,
+		quarantined:       parseResult.Quarantined,
+		contentHash:       parseResult.ContentHash,
+		ParsedRows:        parseResult.SuccessCount,
+		ImportedRows:      len(importedRecords),
+		ParseErrors:       parseResult.Errors,
+		ProcessingTime:    parseResult.Statistics.ProcessingTime,
+		Timings:           parseResult.Statistics.Timings(),
+		ImportedRecords:   importedRecords,
+		ColumnMapping:     parseResult.ColumnMapping,
+		Title:             parseResult.Title(),
+		Tables:            parseResult.Tables,
+		DataTypesDetected: parseResult.Statistics.DataTypesDetected,
+	}, nil
+}
+
 // importHTMLDataBatchWithParser imports HTML data using batch operations with the provided parser
-func (a *App) importHTMLDataBatchWithParser(htmlData string, parser *parser.HTMLTableParser) (*ImportResult, error) {
+func (a *App) importHTMLDataBatchWithParser(svc *database.Service, htmlData string, parser *parser.HTMLTableParser, options ImportOptions) (*ImportResult, error) {
 	// Parse HTML data
 	parseResult, err := parser.ParseHTML(htmlData)
 	if err != nil {
 		return &ImportResult{
 			Success:      false,
-			ErrorMessage: fmt.Sprintf("Failed to parse HTML data: %v", err),
+			Error:        parseFailure(err),
 			TotalRows:    0,
 			ParsedRows:   0,
 			ParseErrors:  nil, // Will be nil for complete parse failures,
 		}, nil
 	}
 
+	if refused, err := refuseDuplicate(svc, parseResult, options); refused != nil || err != nil {
+		return refused, err
+	}
+
+	if !options.atomic() {
+		return a.importRecordsPartial(svc, parseResult)
+	}
+
 	// Use batch import for better performance
-	importedRecords, err := a.dbService.CreateSalesRecordsBatch(parseResult.Records)
+	importedRecords, err := svc.CreateSalesRecordsBatch(parseResult.Records)
 	if err != nil {
 		return &ImportResult{
 			Success:      false,
-			ErrorMessage: fmt.Sprintf("Failed to import records: %v", err),
+			Error:        rolledBackFailure(err),
 			TotalRows:    parseResult.TotalRows,
 			ParsedRows:   parseResult.SuccessCount,
 			ParseErrors:  parseResult.Errors,
@@ -270,104 +946,380 @@ func (a *App) importHTMLDataBatchWithParser(htmlData string, parser *parser.HTML
 	result := &ImportResult{
 		Success:           true,
 		TotalRows:         parseResult.TotalRows,
+		IgnoredRows:       parseResult.IgnoredRows,
+		fees:              parseResult.Fees,
+		quarantined:       parseResult.Quarantined,
+		contentHash:       parseResult.ContentHash,
 		ParsedRows:        parseResult.SuccessCount,
 		ImportedRows:      len(importedRecords),
 		ProcessingTime:    parseResult.Statistics.ProcessingTime,
+		Timings:           parseResult.Statistics.Timings(),
 		ImportedRecords:   importedRecords,
 		ColumnMapping:     parseResult.ColumnMapping,
+		Title:             parseResult.Title(),
+		Tables:            parseResult.Tables,
 		DataTypesDetected: parseResult.Statistics.DataTypesDetected,
 	}
 
 	return result, nil
 }
 
-// GetImportStatistics returns statistics about imported data
-func (a *App) GetImportStatistics() (*ImportStatistics, error) {
-	if a.dbService == nil {
-		return nil, fmt.Errorf("database service not initialized")
-	}
-
-	// Get database statistics
-	stats, err := a.dbService.GetDatabaseStats()
+// importHTMLDataUpsertWithParser imports parsed records in a single transaction,
+// updating records already imported with the same store and transaction id
+func (a *App) importHTMLDataUpsertWithParser(svc *database.Service, htmlData string, parser *parser.HTMLTableParser, options ImportOptions) (*ImportResult, error) {
+	parseResult, err := parser.ParseHTML(htmlData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get database statistics: %v", err)
+		return &ImportResult{
+			Success:      false,
+			Error:        parseFailure(err),
+		}, nil
 	}
 
-	// Calculate recent records (last 30 days)
-	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
-	limit := 1 // We just want the count
-	recentFilter := models.SalesRecordFilter{
-		DateFrom: &thirtyDaysAgo,
-		Limit:    &limit,
+	if refused, err := refuseDuplicate(svc, parseResult, options); refused != nil || err != nil {
+		return refused, err
 	}
-	
-	recentList, err := a.dbService.ListSalesRecords(recentFilter)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get recent records: %v", err)
-	}
-
-	return &ImportStatistics{
-		TotalRecords:  int(stats.TotalRecords),
-		RecentRecords: int(recentList.Total),
-		TotalSales:    stats.TotalSales,
-		AveragePrice:  stats.AvgSalePrice,
-	}, nil
-}
-
-// ValidateHTMLData validates HTML data without importing
-func (a *App) ValidateHTMLData(htmlData string) (*ValidationResult, error) {
-	// Create fresh parser instance to avoid cross-request side effects
-	parser := parser.NewHTMLTableParser()
 
-	// Parse HTML data without importing
-	parseResult, err := parser.ParseHTML(htmlData)
+	upserted, err := svc.UpsertSalesRecords(parseResult.Records)
 	if err != nil {
-		return &ValidationResult{
-			Valid:        false,
-			ErrorMessage: fmt.Sprintf("Failed to parse HTML data: %v", err),
+		return &ImportResult{
+			Success:      false,
+			Error:        importFailure(err),
+			TotalRows:    parseResult.TotalRows,
+			ParsedRows:   parseResult.SuccessCount,
+			ParseErrors:  parseResult.Errors,
 		}, nil
 	}
 
-	return &ValidationResult{
-		Valid:             parseResult.SuccessCount > 0,
+	return &ImportResult{
+		Success:           true,
 		TotalRows:         parseResult.TotalRows,
-		ValidRows:         parseResult.SuccessCount,
-		InvalidRows:       parseResult.ErrorCount,
-		Errors:            parseResult.Errors,
-		Warnings:          parseResult.Warnings,
+		IgnoredRows:       parseResult.IgnoredRows,
+		fees:              parseResult.Fees,
+		quarantined:       parseResult.Quarantined,
+		contentHash:       parseResult.ContentHash,
+		ParsedRows:        parseResult.SuccessCount,
+		ImportedRows:      upserted.Inserted,
+		UpdatedRows:       upserted.Updated,
+		UnchangedRows:     upserted.Unchanged,
+		ParseErrors:       parseResult.Errors,
+		ProcessingTime:    parseResult.Statistics.ProcessingTime,
+		Timings:           parseResult.Statistics.Timings(),
 		ColumnMapping:     parseResult.ColumnMapping,
+		Title:             parseResult.Title(),
+		Tables:            parseResult.Tables,
 		DataTypesDetected: parseResult.Statistics.DataTypesDetected,
-		ProcessingTime:    parseResult.Statistics.ProcessingTime,
 	}, nil
 }
 
-// GetDatabaseHealth returns database connection health status
-func (a *App) GetDatabaseHealth() (*DatabaseHealth, error) {
-	if a.dbService == nil {
-		return &DatabaseHealth{
-			Connected: false,
-			Error:     "Database service not initialized",
-		}, nil
-	}
-
-	// Check database health
-	err := a.dbService.Health()
+// importRecordsPartial batch-imports parsed records, keeping the valid rows and
+// reporting each rejected row instead of aborting the whole batch
+func (a *App) importRecordsPartial(svc *database.Service, parseResult *parser.ParseResult) (*ImportResult, error) {
+	importedRecords, failures, err := svc.CreateSalesRecordsBatchPartial(parseResult.Records)
 	if err != nil {
+		return &ImportResult{
+			Success:      false,
+			Error:        importFailure(err),
+			TotalRows:    parseResult.TotalRows,
+			ParsedRows:   parseResult.SuccessCount,
+			ParseErrors:  parseResult.Errors,
+		}, nil
+	}
+
+	var importErrors []ImportError
+	for _, failure := range failures {
+		importErrors = append(importErrors, ImportError{
+			Index:  failure.Index,
+			Record: failure.Record,
+			Error:  failure.Reason,
+		})
+	}
+
+	result := &ImportResult{
+		Success:           len(importedRecords) > 0,
+		TotalRows:         parseResult.TotalRows,
+		IgnoredRows:       parseResult.IgnoredRows,
+		fees:              parseResult.Fees,
+		quarantined:       parseResult.Quarantined,
+		contentHash:       parseResult.ContentHash,
+		ParsedRows:        parseResult.SuccessCount,
+		ImportedRows:      len(importedRecords),
+		ParseErrors:       parseResult.Errors,
+		ImportErrors:      importErrors,
+		ProcessingTime:    parseResult.Statistics.ProcessingTime,
+		Timings:           parseResult.Statistics.Timings(),
+		ImportedRecords:   importedRecords,
+		ColumnMapping:     parseResult.ColumnMapping,
+		Title:             parseResult.Title(),
+		Tables:            parseResult.Tables,
+		DataTypesDetected: parseResult.Statistics.DataTypesDetected,
+	}
+	if len(importErrors) > 0 {
+		result.Error = partialImportFailure(len(importedRecords), parseResult.SuccessCount, len(importErrors))
+	}
+	return result, nil
+}
+
+// GetImportStatistics returns statistics about imported data
+func (a *App) GetImportStatistics() (*ImportStatistics, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get database statistics
+	stats, err := service.GetDatabaseStats()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database statistics: %w", err)
+	}
+
+	// Calculate recent records (last 30 days)
+	recentCount, err := service.GetRecentRecordCount(30)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent records: %w", err)
+	}
+
+	return &ImportStatistics{
+		TotalRecords:  int(stats.TotalRecords),
+		RecentRecords: int(recentCount),
+		TotalSales:    stats.TotalSales,
+		AveragePrice:  stats.AvgSalePrice,
+	}, nil
+}
+
+// ValidateHTMLData validates HTML data without importing
+func (a *App) ValidateHTMLData(htmlData string) (*ValidationResult, error) {
+	// Create fresh parser instance to avoid cross-request side effects
+	return a.validateData(parser.NewHTMLTableParser(), htmlData)
+}
+
+// validateData parses data with parser, applying the saved ignore rules, and
+// reports what importing it would store
+func (a *App) validateData(parser *parser.HTMLTableParser, htmlData string) (*ValidationResult, error) {
+	// Without the database, or while the app is locked, no ignore rules or
+	// aliases are applied
+	service, _ := a.service()
+	if service != nil {
+		if err := setIgnoreRules(service, parser); err != nil {
+			return nil, err
+		}
+	}
+
+	// Parse HTML data without importing
+	parseResult, err := parser.ParseHTML(htmlData)
+	if err != nil {
+		failure := parseFailure(err)
+		return &ValidationResult{
+			Valid:            false,
+			ErrorMessage:     failure.Message,
+			Error:            failure,
+			SuggestedLayouts: detectLayouts(htmlData),
+		}, nil
+	}
+
+	// Show the records as they would be stored, under their canonical names
+	records := parseResult.Records
+	if service != nil {
+		if records, err = service.ApplyNameAliases(records); err != nil {
+			return nil, newAppErrorf(err, "failed to load name aliases")
+		}
+	}
+
+	return &ValidationResult{
+		Valid:             parseResult.SuccessCount > 0,
+		TotalRows:         parseResult.TotalRows,
+		IgnoredRows:       parseResult.IgnoredRows,
+		FeeRows:           len(parseResult.Fees),
+		ValidRows:         parseResult.SuccessCount,
+		InvalidRows:       parseResult.ErrorCount,
+		Errors:            parseResult.Errors,
+		Warnings:          parseResult.Warnings,
+		ColumnMapping:     parseResult.ColumnMapping,
+		DataTypesDetected: parseResult.Statistics.DataTypesDetected,
+		ProcessingTime:    parseResult.Statistics.ProcessingTime,
+		SuggestedLayouts:  detectLayouts(htmlData),
+		Context:           parseResult.Statistics.Context,
+		ColumnMatches:     parseResult.ColumnMatches,
+		UnmappedColumns:   parseResult.UnmappedColumns,
+		Records:           records,
+	}, nil
+}
+
+// InspectCSVColumns starts the mapping wizard for a delimited file that
+// matches no built-in layout. It detects the delimiter and describes each
+// column with sample values, a type guess and a suggested field.
+func (a *App) InspectCSVColumns(data string) (*parser.CSVInspection, error) {
+	inspection, err := parser.InspectCSV(data)
+	if err != nil {
+		return nil, &AppError{Code: ErrCodeValidation, Message: err.Error(), Details: map[string]interface{}{"field": "data"}, cause: err}
+	}
+	return inspection, nil
+}
+
+// ValidateCSVMapping validates a delimited file with the fields the user
+// assigned to its columns in the mapping wizard, without importing. Import
+// it by passing the mapping in ImportOptions.Mapping.
+func (a *App) ValidateCSVMapping(data string, mapping models.CSVMapping) (*ValidationResult, error) {
+	p := parser.NewHTMLTableParser()
+	if err := p.SetCSVMapping(mapping); err != nil {
+		return nil, &AppError{Code: ErrCodeValidation, Message: err.Error(), Details: map[string]interface{}{"field": "mapping"}, cause: err}
+	}
+	return a.validateData(p, data)
+}
+
+// ListMappingProfiles returns the mappings saved from the mapping wizard,
+// sorted by name
+func (a *App) ListMappingProfiles() ([]models.CSVMapping, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ListMappingProfiles()
+}
+
+// SaveMappingProfile saves a mapping from the wizard under its name, for
+// importing files from the same source again. A profile with the same name
+// is replaced.
+func (a *App) SaveMappingProfile(profile models.CSVMapping) (*models.CSVMapping, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+	if err := parser.ValidateCSVMapping(profile); err != nil {
+		return nil, &AppError{Code: ErrCodeValidation, Message: err.Error(), Details: map[string]interface{}{"field": "mapping"}, cause: err}
+	}
+
+	return service.SaveMappingProfile(profile)
+}
+
+// DeleteMappingProfile removes a saved mapping profile by name
+func (a *App) DeleteMappingProfile(name string) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.DeleteMappingProfile(name)
+}
+
+// GetDatabaseHealth checks the connection, schema version, pending migrations,
+// last backup, write-ahead log size and free disk space. Each check has a
+// severity and, when it fails, a suggested action.
+func (a *App) GetDatabaseHealth() (*DatabaseHealth, error) {
+	service, err := a.service()
+	if err != nil {
+		check := database.HealthCheck{
+			Name:     "connection",
+			Severity: database.SeverityCritical,
+			Message:  "Database service not initialized",
+			Action:   "Restart the app. If the problem persists, check that the database file is accessible.",
+		}
+		if errors.Is(a.dbErr, database.ErrSchemaTooNew) {
+			check.Name = "schema_version"
+			check.Message = a.dbErr.Error()
+			check.Action = "Update the app before opening this database."
+		} else if err == errAppLocked {
+			check.Name = "app_lock"
+			check.Message = "The app is locked"
+			check.Action = "Enter the PIN or password to continue."
+		}
 		return &DatabaseHealth{
 			Connected: false,
-			Error:     err.Error(),
+			Error:     check.Message,
+			Status:    database.SeverityCritical,
+			Checks:    []database.HealthCheck{check},
 		}, nil
 	}
 
-	return &DatabaseHealth{
+	report := service.HealthReport()
+	health := &DatabaseHealth{
 		Connected: true,
-	}, nil
+		Status:    report.Status,
+		Checks:    report.Checks,
+
+		WALSize:        report.WALSize,
+		LastCheckpoint: report.LastCheckpoint,
+		Metrics:        report.Metrics,
+	}
+	for _, check := range report.Checks {
+		if check.Name == "connection" && check.Severity != database.SeverityOK {
+			health.Connected = false
+			health.Error = check.Message
+		}
+	}
+
+	return health, nil
+}
+
+// CheckSchemaCompatibility reports whether this version of the app can work
+// with the database. It also answers when the database was refused at
+// startup, so the frontend can explain that a newer version created it.
+func (a *App) CheckSchemaCompatibility() (*database.SchemaCompatibility, error) {
+	if service, err := a.service(); err == nil {
+		return service.CheckSchemaCompatibility()
+	}
+	if a.dbPath == "" {
+		return nil, errNotInitialized
+	}
+
+	return database.InspectSchema(a.dbPath)
+}
+
+// GetQueryPlanDiagnostics explains the common list and report queries against
+// the live database and flags plans that are missing an index. It is intended
+// for troubleshooting slow reports on a particular user's data.
+func (a *App) GetQueryPlanDiagnostics() ([]database.QueryPlanReport, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetQueryPlanDiagnostics()
+}
+
+// GetDatabaseMetrics returns the connection pool statistics, a timing
+// histogram of the queries each repository method ran since the database was
+// opened and the recent queries slower than the slow query threshold, for
+// troubleshooting a slow app on a particular user's machine
+func (a *App) GetDatabaseMetrics() (*database.DatabaseMetrics, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetDatabaseMetrics(), nil
+}
+
+// GetReportMethodology returns the SQL of the v_* reporting views, what their
+// columns hold and the rules the summaries follow, so advanced users can
+// check the figures or reproduce them with RunReadOnlyQuery or other tools
+func (a *App) GetReportMethodology() (*database.ReportMethodology, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetReportMethodology()
+}
+
+// RunReadOnlyQuery runs a single SELECT statement with params against the
+// database for one-off questions the reports do not answer. Writes are
+// refused, the query is stopped after database.QueryTimeout and at most
+// database.MaxQueryRows rows are returned.
+func (a *App) RunReadOnlyQuery(sql string, params []interface{}) (*database.ReadOnlyQueryResult, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.RunReadOnlyQuery(sql, params)
 }
 
 // GetRecentImports returns recently imported sales records
 func (a *App) GetRecentImports(limit int) ([]models.SalesRecord, error) {
-	if a.dbService == nil {
-		return nil, fmt.Errorf("database service not initialized")
+	service, err := a.service()
+	if err != nil {
+		return nil, err
 	}
 
 	sortBy := "created_at"
@@ -378,10 +1330,1209 @@ func (a *App) GetRecentImports(limit int) ([]models.SalesRecord, error) {
 		SortOrder: &sortOrder,
 	}
 
-	result, err := a.dbService.ListSalesRecords(filter)
+	result, err := service.ListSalesRecords(filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get recent imports: %v", err)
+		return nil, fmt.Errorf("failed to get recent imports: %w", err)
 	}
 
 	return result.Records, nil
 }
+
+// GetRecordProvenance traces a sales record back to where its numbers came
+// from: the import that last wrote it and the row of the import's source it
+// was parsed from, with its adjustments and the changes made to it since.
+// Records entered by hand have no import or source row.
+func (a *App) GetRecordProvenance(id int64) (*models.RecordProvenance, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetRecordProvenance(id)
+}
+
+// GetImportSource returns the raw content of an import exactly as it was
+// pasted or read from the file, so disputed numbers can be traced back to
+// what the store's portal showed at import time
+func (a *App) GetImportSource(sessionID int64) (*models.ImportSource, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetImportSource(sessionID)
+}
+
+// GetImportHistory returns the summaries of the most recent imports, newest first
+func (a *App) GetImportHistory(limit int) ([]models.ImportRun, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ListImportRuns(limit)
+}
+
+// GetImportActivity returns import counts and error rates by month, including
+// months without imports, so missed months and error spikes stand out
+func (a *App) GetImportActivity() ([]models.ImportActivity, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetImportActivity()
+}
+
+// SaveExchangeRate stores a manually entered exchange rate, quoted as units of
+// the currency per 1 EUR
+func (a *App) SaveExchangeRate(rate models.CreateExchangeRateRequest) (*models.ExchangeRate, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.SaveExchangeRate(rate)
+}
+
+// ListExchangeRates returns stored exchange rates, newest first
+func (a *App) ListExchangeRates(filter models.ExchangeRateFilter) ([]models.ExchangeRate, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ListExchangeRates(filter)
+}
+
+// DeleteExchangeRate removes a stored exchange rate
+func (a *App) DeleteExchangeRate(id int64) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.DeleteExchangeRate(id)
+}
+
+// FetchECBExchangeRates downloads European Central Bank reference rates and
+// stores them, replacing existing rates for the same dates. It fetches the last
+// 90 days, or every rate since 1999 when fullHistory is set, and returns the
+// number of rates saved.
+func (a *App) FetchECBExchangeRates(fullHistory bool) (int, error) {
+	service, err := a.service()
+	if err != nil {
+		return 0, err
+	}
+
+	url := ecb.Last90DaysURL
+	if fullHistory {
+		url = ecb.HistoryURL
+	}
+
+	ctx := a.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	rates, err := ecb.Fetch(ctx, nil, url)
+	if err != nil {
+		return 0, err
+	}
+
+	return service.SaveExchangeRates(rates)
+}
+
+// SaveCommissionRule stores the commission rate a store charges from a date,
+// replacing any existing rule for the same store and date
+func (a *App) SaveCommissionRule(rule models.CreateCommissionRuleRequest) (*models.CommissionRule, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.SaveCommissionRule(rule)
+}
+
+// ListCommissionRules returns the commission rules, optionally for one store
+func (a *App) ListCommissionRules(store *string) ([]models.CommissionRule, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ListCommissionRules(store)
+}
+
+// DeleteCommissionRule removes a commission rule
+func (a *App) DeleteCommissionRule(id int64) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.DeleteCommissionRule(id)
+}
+
+// ReconcileCommissions compares the commissions stores reported with those
+// computed from the commission rules, per store and month, and lists each
+// sale that differs, so stores that over-charge stand out
+func (a *App) ReconcileCommissions(filter models.ReconciliationFilter) (*models.CommissionReconciliation, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ReconcileCommissions(filter)
+}
+
+// BackfillRemaining checks the remaining amounts of the records matching the
+// request's filter against sale price less commission, or less the
+// commission from the store's rule when none was reported, and lists those
+// missing or off. With Fix set it stores the expected amounts.
+func (a *App) BackfillRemaining(req models.RemainingBackfillRequest) (*models.RemainingBackfillResult, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.BackfillRemaining(req)
+}
+
+// SavePayoutSchedule stores when a store pays out each month's sales, such
+// as on the 15th of the following month
+func (a *App) SavePayoutSchedule(schedule models.SavePayoutScheduleRequest) (*models.PayoutSchedule, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.SavePayoutSchedule(schedule)
+}
+
+// ListPayoutSchedules returns the stores' payout schedules
+func (a *App) ListPayoutSchedules() ([]models.PayoutSchedule, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ListPayoutSchedules()
+}
+
+// DeletePayoutSchedule removes a store's payout schedule
+func (a *App) DeletePayoutSchedule(store string) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.DeletePayoutSchedule(store)
+}
+
+// GetExpectedPayouts returns the payouts expected from stores with a payout
+// schedule, one per store and month of sales, ordered by payout date
+func (a *App) GetExpectedPayouts(filter models.PayoutFilter) ([]models.ExpectedPayout, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetExpectedPayouts(filter)
+}
+
+// GetTimeline returns a store's or vendor's sales, returns, adjustments and
+// expected payouts as one list, oldest first, for its detail page
+func (a *App) GetTimeline(filter models.TimelineFilter) (*models.Timeline, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetTimeline(filter)
+}
+
+// SaveNameAlias maps a store or vendor name as it appears in imported reports
+// to the name records are kept under, such as "DT Branch" to "Downtown
+// Store". Imports from then on use the canonical name.
+func (a *App) SaveNameAlias(alias models.SaveNameAliasRequest) (*models.NameAlias, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.SaveNameAlias(alias)
+}
+
+// ListNameAliases returns the "store" or "vendor" aliases, or all aliases
+// when kind is empty
+func (a *App) ListNameAliases(kind string) ([]models.NameAlias, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ListNameAliases(kind)
+}
+
+// DeleteNameAlias removes an alias
+func (a *App) DeleteNameAlias(id int64) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.DeleteNameAlias(id)
+}
+
+// CreateReportSnapshot keeps the records a report covers as they are now,
+// such as January's sales at settlement time, to compare against later
+func (a *App) CreateReportSnapshot(req models.CreateReportSnapshotRequest) (*models.ReportSnapshot, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.CreateReportSnapshot(req)
+}
+
+// ListReportSnapshots returns the report snapshots, newest first
+func (a *App) ListReportSnapshots() ([]models.ReportSnapshot, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ListReportSnapshots()
+}
+
+// CompareReportSnapshot lists the records added, removed and changed since a
+// snapshot was taken, with the report's totals then and now
+func (a *App) CompareReportSnapshot(id int64) (*models.SnapshotComparison, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.CompareReportSnapshot(id)
+}
+
+// DeleteReportSnapshot removes a report snapshot
+func (a *App) DeleteReportSnapshot(id int64) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.DeleteReportSnapshot(id)
+}
+
+// EnrichSalesRecords sets a cost or category, or adds and removes tags, on
+// every record matching the request's filter, so historic data can be
+// enriched in one step. Set DryRun to see how many records would change.
+func (a *App) EnrichSalesRecords(req models.EnrichmentRequest) (*models.EnrichmentResult, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.EnrichSalesRecords(req)
+}
+
+// ApplyCategoryMappings sets categories from a pasted list with one
+// "description → category" pair per line, matching records by product key
+func (a *App) ApplyCategoryMappings(req models.CategoryMappingRequest) (*models.EnrichmentResult, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ApplyCategoryMappings(req)
+}
+
+// CreateAdjustment records a correction to a sale, such as a price change from a
+// later store statement, without editing the original record
+func (a *App) CreateAdjustment(adjustment models.CreateSalesAdjustmentRequest) (*models.SalesAdjustment, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.CreateAdjustment(adjustment)
+}
+
+// ListAdjustments returns the adjustments recorded against a sale, oldest first
+func (a *App) ListAdjustments(salesRecordID int64) ([]models.SalesAdjustment, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ListAdjustments(salesRecordID)
+}
+
+// DeleteAdjustment removes an adjustment
+func (a *App) DeleteAdjustment(id int64) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.DeleteAdjustment(id)
+}
+
+// CreateFee records a fee charged by a store, such as booth rent
+func (a *App) CreateFee(fee models.CreateFeeRequest) (*models.Fee, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.CreateFee(fee)
+}
+
+// ListFees returns the fees matching a filter, newest first
+func (a *App) ListFees(filter models.FeeFilter) ([]models.Fee, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ListFees(filter)
+}
+
+// DeleteFee removes a fee
+func (a *App) DeleteFee(id int64) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.DeleteFee(id)
+}
+
+// GetMonthlyFeeSummary totals the fees charged by month and category, newest
+// first, for a year and store; nil selects every year or store
+func (a *App) GetMonthlyFeeSummary(year, store *string) ([]models.MonthlyFeeSummary, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetMonthlyFeeSummary(year, store)
+}
+
+// GetNetIncome returns each month's remaining amount less the fees charged
+// that month, newest first, for a year and store; nil selects every year or
+// store
+func (a *App) GetNetIncome(year, store *string) ([]models.NetIncomeMonth, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetNetIncome(year, store)
+}
+
+// ListQuarantinedRows returns the imported rows set aside for review by the
+// Quarantine import option, oldest first, with the checks each failed
+func (a *App) ListQuarantinedRows() ([]models.QuarantinedRow, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ListQuarantinedRows()
+}
+
+// ApproveQuarantinedRow imports a quarantined row as a sales record. A
+// non-nil record is the row as corrected in review and replaces its values.
+func (a *App) ApproveQuarantinedRow(id int64, record *models.CreateSalesRecordRequest) (*models.SalesRecord, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ApproveQuarantinedRow(id, record)
+}
+
+// DiscardQuarantinedRow removes a quarantined row without importing it
+func (a *App) DiscardQuarantinedRow(id int64) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.DiscardQuarantinedRow(id)
+}
+
+// ClosePeriod closes a settled month, making its records read-only and
+// keeping a snapshot of them to compare against if it is reopened
+func (a *App) ClosePeriod(req models.ClosePeriodRequest) (*models.PeriodClose, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ClosePeriod(req)
+}
+
+// ReopenPeriod reopens a closed month for changes, recording why in the
+// audit log
+func (a *App) ReopenPeriod(req models.ReopenPeriodRequest) (*models.PeriodClose, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ReopenPeriod(req)
+}
+
+// ListPeriodCloses returns the months closed or reopened, newest first,
+// flagging reopened months changed since they were closed
+func (a *App) ListPeriodCloses() ([]models.PeriodClose, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ListPeriodCloses()
+}
+
+// GetRetentionPolicy returns the saved retention policy, or the default
+// policy if none has been saved
+func (a *App) GetRetentionPolicy() (models.RetentionPolicy, error) {
+	service, err := a.service()
+	if err != nil {
+		return models.RetentionPolicy{}, err
+	}
+
+	return service.GetRetentionPolicy()
+}
+
+// SaveRetentionPolicy stores the retention policy. While it is enabled the
+// maintenance scheduler applies it daily.
+func (a *App) SaveRetentionPolicy(policy models.RetentionPolicy) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.SaveRetentionPolicy(policy)
+}
+
+// PreviewRetentionPolicy reports what applying policy now would archive and
+// purge, without changing anything, so a policy can be checked before saving
+func (a *App) PreviewRetentionPolicy(policy models.RetentionPolicy) (*models.RetentionResult, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.PreviewRetention(policy, time.Now())
+}
+
+// ApplyRetentionPolicy applies the saved retention policy now, whether or not
+// it is enabled for the scheduler
+func (a *App) ApplyRetentionPolicy() (*models.RetentionResult, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := service.GetRetentionPolicy()
+	if err != nil {
+		return nil, err
+	}
+	return service.ApplyRetention(policy, time.Now())
+}
+
+// GetDashboardKPIs returns the dashboard's month-to-date and year-to-date
+// sales, commission, net and item counts for period, a month in YYYY-MM form
+// or "" for the current month, with deltas from the same days of the prior
+// month and year. The projection paces the month's sales to its end, next to
+// the same month last year.
+func (a *App) GetDashboardKPIs(period string) (*models.DashboardKPIs, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetDashboardKPIs(period, time.Now())
+}
+
+// GetTrailingTwelveMonths returns the sales, commission, net and item counts
+// of the twelve months ending with endMonth, in YYYY-MM form or "" for the
+// current month, with deltas from the twelve months before
+func (a *App) GetTrailingTwelveMonths(endMonth string) (*models.TrailingTwelveMonths, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetTrailingTwelveMonths(endMonth, time.Now())
+}
+
+// GetDigest summarizes the last complete "weekly" or "monthly" period: its
+// sales, top vendor and the change from the period before. Text() of the
+// result is the body of a digest message.
+func (a *App) GetDigest(period string) (*models.Digest, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetDigest(period, time.Now())
+}
+
+// GetDigestSchedule returns whether the digest is delivered to the
+// notification center and for which period
+func (a *App) GetDigestSchedule() (models.DigestSchedule, error) {
+	service, err := a.service()
+	if err != nil {
+		return models.DigestSchedule{}, err
+	}
+
+	return service.GetDigestSchedule()
+}
+
+// SaveDigestSchedule stores whether the "weekly" or "monthly" digest is
+// delivered to the notification center once each period is complete
+func (a *App) SaveDigestSchedule(schedule models.DigestSchedule) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.SaveDigestSchedule(schedule)
+}
+
+// GetProfitability reports the profit and margin made on items whose cost was
+// entered or imported, grouped by "item", "vendor" or "category", with the
+// number of items sold without a known cost
+func (a *App) GetProfitability(filter models.ProfitabilityFilter) (*models.ProfitabilityReport, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetProfitability(filter)
+}
+
+// GetPareto ranks vendors or items by revenue, net of returns, with each
+// one's share and cumulative share of the total, showing how concentrated
+// revenue is
+func (a *App) GetPareto(filter models.ParetoFilter) (*models.ParetoReport, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetPareto(filter)
+}
+
+// GetVendorVelocity reports the items each vendor sells per week and the
+// weeks since its last sale, flagging dormant vendors whose inventory may be
+// worth returning. Dormant vendors come first, then the slowest sellers.
+func (a *App) GetVendorVelocity(filter models.VelocityFilter) (*models.VelocityReport, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetVendorVelocity(filter, time.Now())
+}
+
+// GetVendorCohorts groups vendors by the month of their first sale and
+// follows their revenue and how many still sell in each month after, showing
+// how well consignors are retained
+func (a *App) GetVendorCohorts(filter models.CohortFilter) (*models.CohortReport, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetVendorCohorts(filter, time.Now())
+}
+
+// GetCustomSummary totals the records matching a filter by year, month,
+// week, day, store or vendor, keeping the groups that reach the request's
+// minimum sales or items sold, such as the vendors with over $1,000 of sales
+// in March. Weeks start on the saved week start day.
+func (a *App) GetCustomSummary(req models.CustomSummaryRequest) ([]models.SalesSummary, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetCustomSummaryWithFilter(req)
+}
+
+// GetMonthlySummaryByDimension returns the monthly summary, optionally for
+// one year, split per "store" or "vendor" within each month in one call, for
+// stacked bar charts. Options convert currency, fold in adjustments or date
+// records by import.
+func (a *App) GetMonthlySummaryByDimension(year *string, dimension string, opts models.ReportOptions) ([]models.MonthlySummary, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetMonthlySummaryByDimension(year, dimension, opts)
+}
+
+// GetConsolidatedReport returns the monthly summary of the open database and
+// of other businesses' database files, per business and combined, for users
+// keeping one database per business. The other files are only read.
+func (a *App) GetConsolidatedReport(req models.ConsolidatedReportRequest) (*models.ConsolidatedReport, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetConsolidatedReport(req)
+}
+
+// GetDrillDownData returns the records of a year, month or day for the
+// drill-down grid as rows holding only the requested columns, in their
+// order, sorted by date, sale price, description, vendor or store
+func (a *App) GetDrillDownData(req models.DrillDownRequest) (*models.DrillDownTable, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetDrillDownTable(req)
+}
+
+// GetDrillDownDataBatch returns the drill-down rows of several years, months
+// or days in one call, as when the user expands several months at once,
+// grouped by period in the order requested
+func (a *App) GetDrillDownDataBatch(req models.DrillDownBatchRequest) (*models.DrillDownBatch, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetDrillDownBatch(req)
+}
+
+// ExportSalesCSV writes the records matching filter to a CSV file at path, in
+// the saved export format, and returns the number of records written.
+// Limit and offset are ignored, so every matching record is exported.
+func (a *App) ExportSalesCSV(filter models.SalesRecordFilter, path string) (int, error) {
+	return a.ExportSalesRecords(filter, path, export.FileFormatCSV)
+}
+
+// ExportSalesRecords streams the records matching filter to a file at path as
+// "csv", in the saved export format, or "jsonl", one JSON record per line, and
+// returns the number of records written. Any other registered exporter's
+// format is accepted too; see Export.
+func (a *App) ExportSalesRecords(filter models.SalesRecordFilter, path string, fileFormat string) (int, error) {
+	return a.Export(path, fileFormat, ExportOptions{Filter: filter})
+}
+
+// Export streams the records selected by options to a file at path through
+// the exporter registered for format, and returns the number of records
+// written. Records are handed to the exporter as they are read, so
+// full-history exports never sit in memory, and an export:progress event
+// follows each chunk. Limit and offset are ignored. Exports too large to sort
+// within the memory budget are sorted in a temporary file, and an export
+// stops with ErrCodeMemoryBudget if the exporter holds more than the budget.
+// The file at path is only replaced once the export is complete.
+func (a *App) Export(path string, format string, options ExportOptions) (int, error) {
+	service, err := a.service()
+	if err != nil {
+		return 0, err
+	}
+	exporter, ok := export.LookupExporter(format)
+	if !ok {
+		return 0, &AppError{Code: ErrCodeValidation, Message: fmt.Sprintf("unknown export file format %q", format), Details: map[string]interface{}{"field": "file_format"}}
+	}
+
+	exportFormat, err := service.GetExportFormat()
+	if err != nil {
+		return 0, err
+	}
+	total, err := service.CountSalesRecords(options.Filter)
+	if err != nil {
+		return 0, err
+	}
+	budget, err := service.GetMemoryBudget()
+	if err != nil {
+		return 0, err
+	}
+	each := service.EachSalesRecord
+	if total*exportSortMemory > budget.Bytes() {
+		each = service.EachSalesRecordSpilled
+	}
+	monitor := membudget.NewMonitor(budget.Bytes())
+
+	// Write beside path and move the file into place once complete, so a
+	// failed export leaves no partial file and keeps any earlier one
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, newAppErrorf(err, "failed to create export file")
+	}
+	complete := false
+	defer func() {
+		if !complete {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+	if err := file.Chmod(0o644); err != nil {
+		return 0, newAppErrorf(err, "failed to create export file")
+	}
+
+	handed := 0
+	records := func(fn func(models.SalesRecord) error) error {
+		return each(options.Filter, func(record models.SalesRecord) error {
+			if err := monitor.Check(); err != nil {
+				return err
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+			if handed++; handed%exportChunkSize == 0 {
+				a.emitEvent(exportProgressEvent, ExportProgress{RecordsWritten: handed, Total: total})
+			}
+			return nil
+		})
+	}
+	count, err := exporter.Write(file, records, exportFormat)
+	if err != nil {
+		return 0, newAppErrorf(err, "failed to write export file")
+	}
+	if err := file.Close(); err != nil {
+		return 0, newAppErrorf(err, "failed to write export file")
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return 0, newAppErrorf(err, "failed to write export file")
+	}
+	complete = true
+	a.emitEvent(exportProgressEvent, ExportProgress{RecordsWritten: count, Total: total})
+	return count, nil
+}
+
+// ListExportFormats returns the file formats Export accepts, with the MIME
+// type of the files each writes, sorted by name
+func (a *App) ListExportFormats() []ExportFormatInfo {
+	exporters := export.Exporters()
+	formats := make([]ExportFormatInfo, len(exporters))
+	for i, exporter := range exporters {
+		formats[i] = ExportFormatInfo{Name: exporter.Name(), ContentType: exporter.ContentType()}
+	}
+	return formats
+}
+
+// GetIgnoreRules returns the rules for report rows skipped during import,
+// such as fee lines
+func (a *App) GetIgnoreRules() ([]models.IgnoreRule, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetIgnoreRules()
+}
+
+// SaveIgnoreRules replaces the rules for report rows skipped during import.
+// Each rule matches a regular expression against a row's description or
+// store, ignoring case, such as "LISTING FEE" or "^SHIPPING".
+func (a *App) SaveIgnoreRules(rules []models.IgnoreRule) ([]models.IgnoreRule, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.SaveIgnoreRules(rules)
+}
+
+// ExportSettings writes the saved settings, name aliases, commission rules,
+// payout schedules and ignore rules to a JSON file at path, for setting up
+// another computer or restoring them after a reinstall. The bundle written is
+// also returned.
+func (a *App) ExportSettings(path string) (*models.SettingsBundle, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	bundle, err := service.ExportSettings(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, encoded, 0o644); err != nil {
+		return nil, newAppErrorf(err, "failed to write settings file")
+	}
+	return bundle, nil
+}
+
+// ImportSettings saves the settings, aliases and rules of a file written by
+// ExportSettings. Nothing is saved if any of them is invalid.
+func (a *App) ImportSettings(path string) (*models.SettingsImportResult, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, newAppErrorf(err, "failed to read settings file")
+	}
+	var bundle models.SettingsBundle
+	if err := json.Unmarshal(encoded, &bundle); err != nil {
+		return nil, &AppError{Code: ErrCodeValidation, Message: fmt.Sprintf("not a settings file: %v", err), cause: err}
+	}
+	return service.ImportSettings(bundle)
+}
+
+// GetExportFormat returns the saved number, currency and date formatting used
+// by exports, or the default plain format if none has been saved
+func (a *App) GetExportFormat() (models.ExportFormat, error) {
+	service, err := a.service()
+	if err != nil {
+		return models.ExportFormat{}, err
+	}
+
+	return service.GetExportFormat()
+}
+
+// SaveExportFormat stores the formatting used by all exports, such as a ","
+// decimal separator with ";" delimited CSV for European spreadsheets
+func (a *App) SaveExportFormat(format models.ExportFormat) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.SaveExportFormat(format)
+}
+
+// GetWeekStart returns the day weeks start on in weekly reports, "monday"
+// until another is saved
+func (a *App) GetWeekStart() (string, error) {
+	service, err := a.service()
+	if err != nil {
+		return "", err
+	}
+
+	return service.GetWeekStart()
+}
+
+// SaveWeekStart stores the day weeks start on in weekly reports, "monday" or
+// "sunday"
+func (a *App) SaveWeekStart(weekStart string) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.SaveWeekStart(weekStart)
+}
+
+// GetReportPerformanceStatus returns the report performance settings, the
+// record count and whether the pivot, performance and top products reports
+// are in performance mode, for showing when figures are estimated
+func (a *App) GetReportPerformanceStatus() (*models.ReportPerformanceStatus, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.GetReportPerformanceStatus()
+}
+
+// SaveReportPerformanceConfig stores when the heavy reports switch to
+// pre-aggregated sales and sampled estimates
+func (a *App) SaveReportPerformanceConfig(config models.ReportPerformanceConfig) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.SaveReportPerformanceConfig(config)
+}
+
+// GetMemoryBudget returns the memory budget of parses and exports of large
+// data
+func (a *App) GetMemoryBudget() (models.MemoryBudget, error) {
+	service, err := a.service()
+	if err != nil {
+		return models.MemoryBudget{}, err
+	}
+
+	return service.GetMemoryBudget()
+}
+
+// SaveMemoryBudget stores the memory budget past which parses switch to
+// chunked processing and exports to temporary files. Machines with little
+// memory can lower it to stay clear of being killed for running out.
+func (a *App) SaveMemoryBudget(budget models.MemoryBudget) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.SaveMemoryBudget(budget)
+}
+
+// GetQueryTimeouts returns how long reads, writes and maintenance queries may
+// run before they are stopped
+func (a *App) GetQueryTimeouts() (models.QueryTimeouts, error) {
+	service, err := a.service()
+	if err != nil {
+		return models.QueryTimeouts{}, err
+	}
+
+	return service.GetQueryTimeouts()
+}
+
+// SaveQueryTimeouts stores and applies the query timeouts, so a pathological
+// report fails with a QUERY_TIMEOUT error rather than freezing the app. A zero
+// timeout lets queries of its class run as long as they need.
+func (a *App) SaveQueryTimeouts(timeouts models.QueryTimeouts) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.SaveQueryTimeouts(timeouts)
+}
+
+// ExportVendorStatement writes a standalone HTML statement of one vendor's
+// sales and monthly settlements to path, for sharing with the vendor. It
+// holds no other vendor's data and prints cleanly to PDF. The statement is
+// also returned, for showing what was exported.
+func (a *App) ExportVendorStatement(filter models.VendorStatementFilter, path string) (*models.VendorStatement, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	statement, err := service.GetVendorStatement(filter, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	format, err := service.GetExportFormat()
+	if err != nil {
+		return nil, err
+	}
+
+	var page bytes.Buffer
+	if err := export.WriteVendorStatementHTML(&page, statement, format); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, page.Bytes(), 0o644); err != nil {
+		return nil, newAppErrorf(err, "failed to write vendor statement")
+	}
+	return statement, nil
+}
+
+// ExportPayoutCalendar writes the expected payouts matching filter to path as
+// an iCalendar file, so upcoming payouts show in the user's calendar app, and
+// returns the number of payouts written. Importing a newer file updates the
+// events of an older one.
+func (a *App) ExportPayoutCalendar(filter models.PayoutFilter, path string) (int, error) {
+	service, err := a.service()
+	if err != nil {
+		return 0, err
+	}
+
+	payouts, err := service.GetExpectedPayouts(filter)
+	if err != nil {
+		return 0, err
+	}
+	format, err := service.GetExportFormat()
+	if err != nil {
+		return 0, err
+	}
+
+	var calendar bytes.Buffer
+	if err := export.WritePayoutCalendar(&calendar, payouts, format, time.Now()); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(path, calendar.Bytes(), 0o644); err != nil {
+		return 0, newAppErrorf(err, "failed to write payout calendar")
+	}
+	return len(payouts), nil
+}
+
+// GetAuditLog returns the most recent maintenance and settings changes, newest first
+func (a *App) GetAuditLog(limit int) ([]models.AuditEntry, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ListAuditLog(limit)
+}
+
+// StartCompaction compacts the database in the background to reclaim the
+// space left by deleted rows. Progress and completion are published as
+// maintenance.progress and maintenance.completed events, and imports and other
+// writes are refused until it finishes.
+func (a *App) StartCompaction() error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.StartCompaction()
+}
+
+// StartBackup copies the database to path in the background, publishing
+// progress like StartCompaction
+func (a *App) StartBackup(path string) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.StartBackup(path)
+}
+
+// ExportWorkspace writes the whole workspace, every record, setting and rule
+// kept in the database, to path as one archive encrypted with passphrase, for
+// moving to another computer with ImportWorkspace. The passphrase is needed
+// to import it and cannot be recovered.
+func (a *App) ExportWorkspace(path string, passphrase string) (*models.WorkspaceManifest, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ExportWorkspace(path, passphrase, time.Now())
+}
+
+// ImportWorkspace decrypts a workspace archive written by ExportWorkspace and
+// stages it to replace this computer's database when the app next starts.
+// The database replaced is kept beside the new one. The manifest returned
+// says when the archive was written and how many records it holds.
+func (a *App) ImportWorkspace(path string, passphrase string) (*models.WorkspaceManifest, error) {
+	if _, err := a.service(); err != nil {
+		return nil, err
+	}
+	if a.dbPath == "" {
+		return nil, errNotInitialized
+	}
+
+	return database.StageWorkspace(path, passphrase, a.dbPath)
+}
+
+// GetMaintenanceStatus returns the progress of the running compaction or
+// backup, or of the last one, so a view opened mid-operation can show it.
+// It returns nil if no maintenance has run.
+func (a *App) GetMaintenanceStatus() (*database.MaintenanceProgress, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.MaintenanceStatus(), nil
+}
+
+// ListNotifications returns the outcomes recorded by background work, such
+// as backups, compactions and scheduled maintenance, newest first, with the
+// number unread. New ones are published as notification.created events.
+func (a *App) ListNotifications(filter models.NotificationFilter) (*models.NotificationList, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ListNotifications(filter)
+}
+
+// MarkNotificationRead marks a notification read
+func (a *App) MarkNotificationRead(id int64) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.MarkNotificationRead(id, time.Now())
+}
+
+// MarkAllNotificationsRead marks every unread notification read and returns
+// how many were
+func (a *App) MarkAllNotificationsRead() (int64, error) {
+	service, err := a.service()
+	if err != nil {
+		return 0, err
+	}
+
+	return service.MarkAllNotificationsRead(time.Now())
+}
+
+// CreateAPIToken creates a token for clients of the local HTTP server, with
+// the scopes it is granted and an optional expiry. The returned secret is
+// shown once; only its hash is kept.
+func (a *App) CreateAPIToken(req models.CreateAPITokenRequest) (*models.CreatedAPIToken, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.CreateAPIToken(req, time.Now())
+}
+
+// ListAPITokens returns every API token, revoked and expired ones included,
+// newest first, for the settings panel
+func (a *App) ListAPITokens() ([]models.APIToken, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.ListAPITokens()
+}
+
+// RevokeAPIToken stops an API token from being accepted
+func (a *App) RevokeAPIToken(id int64) (*models.APIToken, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	return service.RevokeAPIToken(id, time.Now())
+}
+
+// GetWriteQueueStatus reports whether a change is being saved and how many
+// are waiting behind it. Writes are saved one at a time, so an edit made
+// while an import runs waits for the import instead of failing.
+func (a *App) GetWriteQueueStatus() (*database.WriteQueueStatus, error) {
+	service, err := a.service()
+	if err != nil {
+		return nil, err
+	}
+
+	status := service.WriteQueueStatus()
+	return &status, nil
+}
+
+// GetImportLayouts returns the built-in platform layouts that can be selected
+// by name in ImportOptions.Layout
+func (a *App) GetImportLayouts() []parser.Layout {
+	return parser.Layouts()
+}
+
+// DetectImportLayout fingerprints pasted data and returns the built-in layouts
+// that match it, most likely first, so the frontend can preselect a format
+func (a *App) DetectImportLayout(htmlData string) []parser.LayoutMatch {
+	return detectLayouts(htmlData)
+}
+
+// detectLayouts runs layout detection, returning an empty slice rather than nil
+func detectLayouts(data string) []parser.LayoutMatch {
+	matches := parser.DetectLayout(data)
+	if matches == nil {
+		return []parser.LayoutMatch{}
+	}
+	return matches
+}
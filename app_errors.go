@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/url"
+
+	"github.com/mattn/go-sqlite3"
+
+	"sales-track/internal/database"
+	"sales-track/internal/membudget"
+	"sales-track/internal/models"
+)
+
+// Error codes returned to the frontend. The frontend maps them to localized
+// messages; the English message is a fallback.
+const (
+	ErrCodeNotInitialized  = "NOT_INITIALIZED"         // The database failed to open at startup
+	ErrCodeLocked          = "APP_LOCKED"              // The app lock's PIN or password must be entered first
+	ErrCodeValidation      = "VALIDATION_FAILED"       // Input was rejected; details may name the field
+	ErrCodeNotFound        = "NOT_FOUND"               // The requested record does not exist
+	ErrCodeSchemaTooNew    = "SCHEMA_TOO_NEW"          // A newer version of the app created the database
+	ErrCodePeriodClosed    = "PERIOD_CLOSED"           // The change touches a record of a closed month
+	ErrCodeParse           = "PARSE_FAILED"            // The pasted or imported data could not be parsed
+	ErrCodeImport          = "IMPORT_FAILED"           // Parsed records could not be saved
+	ErrCodePartialImport   = "PARTIAL_IMPORT"          // Some records were saved and some failed
+	ErrCodeRolledBack      = "IMPORT_ROLLED_BACK"      // An atomic import failed and nothing was saved
+	ErrCodeDuplicateImport = "DUPLICATE_IMPORT"        // The report's records were already imported; details name the earlier import
+	ErrCodeMaintenance     = "MAINTENANCE_IN_PROGRESS" // A compaction or backup is running
+	ErrCodeBusy            = "DATABASE_BUSY"           // Another write held the database for too long
+	ErrCodeTimeout         = "QUERY_TIMEOUT"           // A query ran past the saved timeout of its operation class
+	ErrCodeFile            = "FILE_ERROR"              // A file could not be read or written
+	ErrCodeMemoryBudget    = "MEMORY_BUDGET_EXCEEDED"  // An export stopped before using more memory than the saved budget
+	ErrCodeNetwork         = "NETWORK_ERROR"           // A download failed
+	ErrCodeInternal        = "INTERNAL_ERROR"          // Anything else
+)
+
+// AppError is the error envelope returned by every App binding, and attached
+// to import and validation results that failed
+type AppError struct {
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	Retryable bool                   `json:"retryable"` // The same call may succeed if tried again later
+	cause     error
+}
+
+// Error returns the message
+func (e *AppError) Error() string { return e.Message }
+
+// Unwrap returns the error the envelope was created from
+func (e *AppError) Unwrap() error { return e.cause }
+
+// errNotInitialized is returned by bindings called when the database failed to open
+var errNotInitialized = &AppError{Code: ErrCodeNotInitialized, Message: "database service not initialized"}
+
+// newAppError wraps err in an envelope, choosing the code from the kind of
+// error. Errors that already are envelopes are returned unchanged.
+func newAppError(err error) *AppError {
+	if err == nil {
+		return nil
+	}
+
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	envelope := &AppError{Code: ErrCodeInternal, Message: err.Error(), cause: err}
+
+	var sqliteErr sqlite3.Error
+	var urlErr *url.Error
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, database.ErrMaintenanceInProgress):
+		envelope.Code = ErrCodeMaintenance
+		envelope.Retryable = true
+	case errors.Is(err, database.ErrValidation):
+		envelope.Code = ErrCodeValidation
+	case errors.Is(err, database.ErrNotFound):
+		envelope.Code = ErrCodeNotFound
+	case errors.Is(err, database.ErrSchemaTooNew):
+		envelope.Code = ErrCodeSchemaTooNew
+	case database.IsPeriodClosed(err):
+		envelope.Code = ErrCodePeriodClosed
+		envelope.Message += "; reopen the month to change its records"
+	case errors.Is(err, database.ErrWriteBusy),
+		errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked):
+		envelope.Code = ErrCodeBusy
+		envelope.Retryable = true
+	case errors.Is(err, database.ErrQueryTimeout):
+		envelope.Code = ErrCodeTimeout
+		envelope.Message += "; narrow the filters or raise the query timeout"
+	case errors.As(err, &urlErr), errors.As(err, &opErr):
+		envelope.Code = ErrCodeNetwork
+		envelope.Retryable = true
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrPermission):
+		envelope.Code = ErrCodeFile
+	case errors.Is(err, membudget.ErrExceeded):
+		envelope.Code = ErrCodeMemoryBudget
+	}
+	return envelope
+}
+
+// newAppErrorf wraps err in an envelope like newAppError, prefixing its message
+// with context such as "Failed to import records"
+func newAppErrorf(err error, format string, args ...interface{}) *AppError {
+	envelope := *newAppError(err)
+	envelope.Message = fmt.Sprintf(format, args...) + ": " + envelope.Message
+	return &envelope
+}
+
+// parseFailure is the envelope for data that could not be parsed at all
+func parseFailure(err error) *AppError {
+	return &AppError{Code: ErrCodeParse, Message: fmt.Sprintf("Failed to parse HTML data: %v", err), cause: err}
+}
+
+// importFailure is the envelope for parsed records that could not be saved.
+// Maintenance, busy and validation errors keep their own codes.
+func importFailure(err error) *AppError {
+	envelope := newAppErrorf(err, "Failed to import records")
+	if envelope.Code == ErrCodeInternal {
+		envelope.Code = ErrCodeImport
+	}
+	return envelope
+}
+
+// rolledBackFailure is the envelope for an atomic import that saved nothing.
+// Maintenance and busy errors keep their codes so the frontend can retry.
+func rolledBackFailure(err error) *AppError {
+	envelope := newAppErrorf(err, "Import rolled back")
+	if !envelope.Retryable {
+		envelope.Code = ErrCodeRolledBack
+	}
+	return envelope
+}
+
+// duplicateImportFailure is the envelope for an import refused because the
+// earlier import already imported the same records
+func duplicateImportFailure(earlier *models.ImportRun) *AppError {
+	name := earlier.StartedAt.Local().Format("Jan 2, 2006 3:04 PM")
+	if earlier.Name != nil {
+		name = *earlier.Name
+	}
+	return &AppError{
+		Code:    ErrCodeDuplicateImport,
+		Message: fmt.Sprintf("This report was already imported (%s)", name),
+		Details: map[string]interface{}{"import_run_id": earlier.ID},
+	}
+}
+
+// partialImportFailure is the envelope for an import that saved some records
+func partialImportFailure(imported, parsed, failed int) *AppError {
+	return &AppError{
+		Code:    ErrCodePartialImport,
+		Message: fmt.Sprintf("Imported %d of %d records. %d records failed to import.", imported, parsed, failed),
+		Details: map[string]interface{}{"imported": imported, "parsed": parsed, "failed": failed},
+	}
+}
+
+// formatBindingError converts errors returned by bindings into envelopes for
+// the frontend. It is registered as the Wails error formatter.
+func formatBindingError(err error) interface{} {
+	return newAppError(err)
+}
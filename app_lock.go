@@ -0,0 +1,159 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"sales-track/internal/database"
+	"sales-track/internal/models"
+)
+
+// Limits on wrong app lock entries, slowing down guessing a short PIN
+const (
+	maxUnlockAttempts = 5                // Wrong entries allowed in a row
+	unlockBackoff     = 30 * time.Second // How long unlocking is refused after them
+)
+
+var errAppLocked = &AppError{Code: ErrCodeLocked, Message: "the app is locked; enter the PIN or password to continue"}
+
+// appLock holds the open database back from the bindings while the app lock
+// is engaged, so every binding fails with errAppLocked
+type appLock struct {
+	mu         sync.Mutex
+	engaged    bool
+	failures   int // Wrong entries since the last backoff or unlock
+	retryAfter time.Time
+}
+
+// service returns the database for a binding to use: errNotInitialized if it
+// failed to open and errAppLocked while the app lock is engaged. Bindings
+// reach the database only through it, since LockApp may run alongside them.
+func (a *App) service() (*database.Service, error) {
+	a.lock.mu.Lock()
+	defer a.lock.mu.Unlock()
+
+	if a.lock.engaged {
+		return nil, errAppLocked
+	}
+	if a.dbService == nil {
+		return nil, errNotInitialized
+	}
+	return a.dbService, nil
+}
+
+// engageLock locks the app if an app lock is set, holding the database back
+func (a *App) engageLock() (bool, error) {
+	a.lock.mu.Lock()
+	defer a.lock.mu.Unlock()
+
+	if a.lock.engaged {
+		return true, nil
+	}
+	if a.dbService == nil {
+		return false, errNotInitialized
+	}
+	enabled, err := a.dbService.AppLockEnabled()
+	if err != nil || !enabled {
+		return false, err
+	}
+	a.lock.engaged = true
+	return true, nil
+}
+
+// GetAppLockStatus reports whether an app lock is set and whether it must be
+// entered before the app shows any data. It is answered while locked.
+func (a *App) GetAppLockStatus() (models.AppLockStatus, error) {
+	a.lock.mu.Lock()
+	defer a.lock.mu.Unlock()
+
+	status := models.AppLockStatus{Locked: a.lock.engaged}
+	if status.Locked {
+		status.Enabled = true
+		if time.Now().Before(a.lock.retryAfter) {
+			retryAfter := a.lock.retryAfter
+			status.RetryAfter = &retryAfter
+		}
+		return status, nil
+	}
+	if a.dbService == nil {
+		return status, errNotInitialized
+	}
+
+	enabled, err := a.dbService.AppLockEnabled()
+	if err != nil {
+		return status, err
+	}
+	status.Enabled = enabled
+	return status, nil
+}
+
+// UnlockApp releases the database to the bindings once the app lock's PIN or
+// password is entered. After maxUnlockAttempts wrong entries in a row it is
+// refused for unlockBackoff.
+func (a *App) UnlockApp(secret string) error {
+	a.lock.mu.Lock()
+	defer a.lock.mu.Unlock()
+
+	if !a.lock.engaged {
+		return nil
+	}
+	if time.Now().Before(a.lock.retryAfter) {
+		return &AppError{
+			Code:      ErrCodeLocked,
+			Message:   "too many wrong attempts; try again shortly",
+			Details:   map[string]interface{}{"retry_after": a.lock.retryAfter},
+			Retryable: true,
+		}
+	}
+
+	ok, err := a.dbService.VerifyAppLock(secret)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		a.lock.failures++
+		if a.lock.failures >= maxUnlockAttempts {
+			a.lock.failures = 0
+			a.lock.retryAfter = time.Now().Add(unlockBackoff)
+		}
+		return &AppError{Code: ErrCodeValidation, Message: "incorrect PIN or password", Details: map[string]interface{}{"field": "secret"}}
+	}
+
+	a.lock.failures = 0
+	a.lock.engaged = false
+	return nil
+}
+
+// LockApp locks the app until the PIN or password is entered again, such as
+// before stepping away from a shared computer
+func (a *App) LockApp() error {
+	locked, err := a.engageLock()
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return &AppError{Code: ErrCodeValidation, Message: "no app lock is set"}
+	}
+	return nil
+}
+
+// SetAppLock sets the PIN or password entered at startup and by LockApp, or
+// changes it given the current one
+func (a *App) SetAppLock(current, secret string) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.SetAppLock(current, secret)
+}
+
+// RemoveAppLock turns the app lock off, given the current PIN or password
+func (a *App) RemoveAppLock(current string) error {
+	service, err := a.service()
+	if err != nil {
+		return err
+	}
+
+	return service.RemoveAppLock(current)
+}
@@ -2,11 +2,24 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"sales-track/internal/database"
+	"sales-track/internal/export"
+	"sales-track/internal/models"
+	"sales-track/internal/parser"
+	"sales-track/internal/tsgen"
 )
 
 // Test HTML data for testing
@@ -193,38 +206,128 @@ func TestApp_ImportHTMLDataWithOptions_Consignable(t *testing.T) {
 	}
 }
 
-func TestApp_ValidateHTMLData(t *testing.T) {
+func TestApp_ImportHTMLDataWithOptions_Defaults(t *testing.T) {
 	app := setupTestApp(t)
 	defer app.dbService.Close()
 
-	result, err := app.ValidateHTMLData(testHTMLData)
+	htmlData := `<table>
+		<tr><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>$40.00</td></tr>
+	</table>`
+	rate := 20.0
+	options := ImportOptions{Defaults: &parser.FieldDefaults{Store: "Main Street", CommissionRate: &rate}}
+
+	result, err := app.ImportHTMLDataWithOptions(htmlData, options)
 	if err != nil {
-		t.Fatalf("ValidateHTMLData failed: %v", err)
+		t.Fatalf("ImportHTMLDataWithOptions failed: %v", err)
+	}
+	if result.ImportedRows != 1 {
+		t.Fatalf("Expected ImportedRows=1, got %d: %s", result.ImportedRows, result.ErrorMessage)
+	}
+	record := result.ImportedRecords[0]
+	if record.Store != "Main Street" || record.Commission == nil || *record.Commission != 8.00 {
+		t.Errorf("Expected the default store and commission, got %+v", record)
 	}
 
-	// Verify validation results
-	if !result.Valid {
-		t.Errorf("Expected Valid=true, got %v", result.Valid)
+	rate = 120
+	if _, err := app.ImportHTMLDataWithOptions(htmlData, options); newAppError(err).Code != ErrCodeValidation {
+		t.Errorf("Expected a validation error for a commission rate over 100, got %v", err)
 	}
+}
 
-	if result.TotalRows != 2 {
-		t.Errorf("Expected TotalRows=2, got %d", result.TotalRows)
+func TestApp_ImportHTMLDataWithOptions_YearInference(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	htmlData := `<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>Mar 15</td><td>Lamp</td><td>$40.00</td></tr>
+	</table>`
+	options := ImportOptions{YearInference: &parser.YearInference{Source: parser.YearFixed, Year: 2022}}
+
+	result, err := app.ImportHTMLDataWithOptions(htmlData, options)
+	if err != nil || result.ImportedRows != 1 {
+		t.Fatalf("ImportHTMLDataWithOptions failed: %v %+v", err, result)
+	}
+	if date := result.ImportedRecords[0].Date.String(); date != "2022-03-15" {
+		t.Errorf("Expected the date read in the fixed year, got %s", date)
 	}
 
-	if result.ValidRows != 2 {
-		t.Errorf("Expected ValidRows=2, got %d", result.ValidRows)
+	options.YearInference.Year = 0
+	_, err = app.ImportHTMLDataWithOptions(htmlData, options)
+	if appErr := newAppError(err); appErr.Code != ErrCodeValidation || appErr.Details["field"] != "year_inference" {
+		t.Errorf("Expected a validation error for a fixed source without a year, got %v", err)
 	}
+}
 
-	if result.InvalidRows != 0 {
-		t.Errorf("Expected InvalidRows=0, got %d", result.InvalidRows)
+func TestApp_ImportHTMLDataWithOptions_ColumnOverrides(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	htmlData := `<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th><th>Consignor Cut</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>$40.00</td><td>$4.00</td></tr>
+	</table>`
+	options := ImportOptions{ColumnOverrides: []parser.ColumnOverride{{Index: 6, Field: "commission"}}}
+
+	// Streaming and in-memory imports read the overridden column alike
+	for _, useBatch := range []bool{false, true} {
+		options.UseBatchImport = useBatch
+		result, err := app.ImportHTMLDataWithOptions(htmlData, options)
+		if err != nil || result.ImportedRows != 1 {
+			t.Fatalf("ImportHTMLDataWithOptions failed: %v %+v", err, result)
+		}
+		if commission := result.ImportedRecords[0].Commission; commission == nil || *commission != 4.00 {
+			t.Errorf("Expected the overridden column to be the commission, got %v", commission)
+		}
+	}
+
+	options.ColumnOverrides = []parser.ColumnOverride{{Index: 6, Field: "payout"}}
+	_, err := app.ImportHTMLDataWithOptions(htmlData, options)
+	if appErr := newAppError(err); appErr.Code != ErrCodeValidation || appErr.Details["field"] != "column_overrides" {
+		t.Errorf("Expected a validation error for an unknown field, got %v", err)
 	}
 }
 
-func TestApp_ValidateHTMLData_Invalid(t *testing.T) {
+func TestApp_ImportHTMLDataWithOptions_DryRun(t *testing.T) {
 	app := setupTestApp(t)
 	defer app.dbService.Close()
 
-	invalidHTML := `
+	for _, useBatch := range []bool{false, true} {
+		options := ImportOptions{
+			UseBatchImport: useBatch,
+			DryRun:         true,
+		}
+
+		result, err := app.ImportHTMLDataWithOptions(testHTMLData, options)
+		if err != nil {
+			t.Fatalf("ImportHTMLDataWithOptions failed (batch=%v): %v", useBatch, err)
+		}
+
+		if !result.DryRun {
+			t.Errorf("Expected DryRun=true in result (batch=%v)", useBatch)
+		}
+		if result.ImportedRows != 2 {
+			t.Errorf("Expected ImportedRows=2 (batch=%v), got %d", useBatch, result.ImportedRows)
+		}
+		if len(result.ImportedRecords) != 2 || result.ImportedRecords[0].ID == 0 {
+			t.Errorf("Expected dry run to return would-be records with IDs (batch=%v)", useBatch)
+		}
+	}
+
+	// Nothing should have been persisted
+	stats, err := app.dbService.GetDatabaseStats()
+	if err != nil {
+		t.Fatalf("GetDatabaseStats failed: %v", err)
+	}
+	if stats.TotalRecords != 0 {
+		t.Errorf("Expected no persisted records after dry run, got %d", stats.TotalRecords)
+	}
+}
+
+func TestApp_ImportHTMLDataWithOptions_Atomic(t *testing.T) {
+	// The second row passes parsing but fails database validation
+	failingHTML := `
 	<table>
 		<tr>
 			<th>Store</th>
@@ -232,221 +335,1908 @@ func TestApp_ValidateHTMLData_Invalid(t *testing.T) {
 			<th>Date</th>
 			<th>Description</th>
 			<th>Sale Price</th>
+			<th>Commission</th>
+			<th>Remaining</th>
 		</tr>
 		<tr>
-			<td></td>
-			<td>Test Vendor</td>
-			<td>invalid-date</td>
-			<td></td>
-			<td>not-a-price</td>
+			<td>Store A</td>
+			<td>Vendor 1</td>
+			<td>2024-01-15</td>
+			<td>Product 1</td>
+			<td>100.00</td>
+			<td>10.00</td>
+			<td>90.00</td>
 		</tr>
-	</table>
-	`
-
-	result, err := app.ValidateHTMLData(invalidHTML)
-	if err != nil {
-		t.Fatalf("ValidateHTMLData failed: %v", err)
+		<tr>
+			<td>Store B</td>
+			<td>Vendor 2</td>
+			<td>2024-01-16</td>
+			<td>Product 2</td>
+			<td>50.00</td>
+			<td>-5.00</td>
+			<td>55.00</td>
+		</tr>
+	</table>`
+
+	atomic := true
+	nonAtomic := false
+
+	tests := []struct {
+		name          string
+		options       ImportOptions
+		expectSuccess bool
+		expectStored  int64
+		expectCode    string
+	}{
+		{"row-by-row default is non-atomic", ImportOptions{}, true, 1, ErrCodePartialImport},
+		{"row-by-row atomic", ImportOptions{Atomic: &atomic}, false, 0, ErrCodeRolledBack},
+		{"batch default is atomic", ImportOptions{UseBatchImport: true}, false, 0, ErrCodeRolledBack},
+		{"explicit non-atomic", ImportOptions{Atomic: &nonAtomic}, true, 1, ErrCodePartialImport},
+		{"batch partial success", ImportOptions{UseBatchImport: true, Atomic: &nonAtomic}, true, 1, ErrCodePartialImport},
 	}
 
-	// Should have validation errors
-	if result.Valid {
-		t.Errorf("Expected Valid=false for invalid data, got %v", result.Valid)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := setupTestApp(t)
+			defer app.dbService.Close()
+
+			result, err := app.ImportHTMLDataWithOptions(failingHTML, tt.options)
+			if err != nil {
+				t.Fatalf("ImportHTMLDataWithOptions failed: %v", err)
+			}
+			if result.ParsedRows != 2 {
+				t.Fatalf("Expected both rows to parse, got %d", result.ParsedRows)
+			}
+
+			if result.Success != tt.expectSuccess {
+				t.Errorf("Expected Success=%v, got %v (%s)", tt.expectSuccess, result.Success, result.ErrorMessage)
+			}
+			if !tt.expectSuccess && result.ErrorMessage == "" {
+				t.Error("Expected an error message for a rolled back import")
+			}
+			if result.Error == nil || result.Error.Code != tt.expectCode || result.Error.Message != result.ErrorMessage {
+				t.Errorf("Expected a %s error matching ErrorMessage, got %+v", tt.expectCode, result.Error)
+			}
+			if tt.options.atomic() && tt.options.UseBatchImport {
+				// Atomic batch failures are reported through ErrorMessage only
+			} else if len(result.ImportErrors) != 1 || result.ImportErrors[0].Index != 1 {
+				t.Errorf("Expected 1 import error for record index 1, got %+v", result.ImportErrors)
+			}
+
+			stats, err := app.dbService.GetDatabaseStats()
+			if err != nil {
+				t.Fatalf("GetDatabaseStats failed: %v", err)
+			}
+			if stats.TotalRecords != tt.expectStored {
+				t.Errorf("Expected %d stored records, got %d", tt.expectStored, stats.TotalRecords)
+			}
+		})
 	}
+}
 
-	if result.InvalidRows == 0 {
-		t.Errorf("Expected InvalidRows>0 for invalid data, got %d", result.InvalidRows)
+func TestApp_RowImportEvents(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	var events []string
+	app.dbService.SetEventEmitter(func(name string, payload interface{}) {
+		events = append(events, name)
+	})
+
+	// Imports written row by row announce themselves once, as batches do, so
+	// the cached reports wait for the import rather than refreshing per row
+	atomic := true
+	for _, options := range []ImportOptions{{}, {Atomic: &atomic}} {
+		events = nil
+		result, err := app.ImportHTMLDataWithOptions(testHTMLData, options)
+		if err != nil || !result.Success {
+			t.Fatalf("Import failed: %+v, %v", result, err)
+		}
+		if len(events) != 1 || events[0] != database.EventImportCompleted {
+			t.Errorf("Expected one %s event importing with %+v, got %v", database.EventImportCompleted, options, events)
+		}
 	}
 
-	if len(result.Errors) == 0 {
-		t.Errorf("Expected validation errors for invalid data, got none")
+	events = nil
+	if _, err := app.ImportHTMLData(testHTMLData); err != nil {
+		t.Fatalf("ImportHTMLData failed: %v", err)
+	}
+	if len(events) != 1 || events[0] != database.EventImportCompleted {
+		t.Errorf("Expected one %s event, got %v", database.EventImportCompleted, events)
 	}
 }
 
-func TestApp_GetDatabaseHealth(t *testing.T) {
+func TestApp_ImportHTMLDataStream(t *testing.T) {
 	app := setupTestApp(t)
 	defer app.dbService.Close()
 
-	health, err := app.GetDatabaseHealth()
+	var progress []ImportProgress
+	app.emit = func(name string, data ...interface{}) {
+		if name == importProgressEvent {
+			progress = append(progress, data[0].(ImportProgress))
+		}
+	}
+
+	rows := streamChunkSize*2 + 7
+	result, err := app.ImportHTMLDataStream(generateHTMLTable(rows), ImportOptions{})
 	if err != nil {
-		t.Fatalf("GetDatabaseHealth failed: %v", err)
+		t.Fatalf("ImportHTMLDataStream failed: %v", err)
 	}
 
-	if !health.Connected {
-		t.Errorf("Expected Connected=true, got %v", health.Connected)
+	if !result.Success || result.ImportedRows != rows {
+		t.Errorf("Expected %d imported rows, got %d (%s)", rows, result.ImportedRows, result.ErrorMessage)
+	}
+	if len(result.ImportedRecords) != 0 {
+		t.Errorf("Expected streaming import not to return records, got %d", len(result.ImportedRecords))
 	}
 
-	if health.Error != "" {
-		t.Errorf("Expected no error, got '%s'", health.Error)
+	if len(progress) != 3 {
+		t.Fatalf("Expected 3 progress events, got %d", len(progress))
+	}
+	if last := progress[len(progress)-1]; last.RowsInserted != rows || last.Chunks != 3 {
+		t.Errorf("Expected final progress %d rows in 3 chunks, got %+v", rows, last)
+	}
+
+	stats, err := app.dbService.GetDatabaseStats()
+	if err != nil {
+		t.Fatalf("GetDatabaseStats failed: %v", err)
+	}
+	if stats.TotalRecords != int64(rows) {
+		t.Errorf("Expected %d stored records, got %d", rows, stats.TotalRecords)
 	}
 }
 
-func TestApp_GetDatabaseHealth_NotInitialized(t *testing.T) {
-	app := NewApp()
+func TestApp_ImportHTMLDataStream_DryRun(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
 
-	health, err := app.GetDatabaseHealth()
+	result, err := app.ImportHTMLDataStream(testHTMLData, ImportOptions{DryRun: true})
 	if err != nil {
-		t.Fatalf("GetDatabaseHealth failed: %v", err)
+		t.Fatalf("ImportHTMLDataStream failed: %v", err)
 	}
-
-	if health.Connected {
-		t.Errorf("Expected Connected=false for uninitialized service, got %v", health.Connected)
+	if !result.DryRun || result.ImportedRows != 2 {
+		t.Errorf("Expected dry run reporting 2 rows, got %+v", result)
 	}
 
-	if health.Error == "" {
-		t.Errorf("Expected error message for uninitialized service, got empty string")
+	stats, err := app.dbService.GetDatabaseStats()
+	if err != nil {
+		t.Fatalf("GetDatabaseStats failed: %v", err)
+	}
+	if stats.TotalRecords != 0 {
+		t.Errorf("Expected no persisted records after dry run, got %d", stats.TotalRecords)
 	}
 }
 
-func TestApp_GetImportStatistics(t *testing.T) {
+func TestApp_ImportHTMLDataWithOptions_Upsert(t *testing.T) {
 	app := setupTestApp(t)
 	defer app.dbService.Close()
 
-	// Import some test data first
-	_, err := app.ImportHTMLData(testHTMLData)
-	if err != nil {
-		t.Fatalf("Failed to import test data: %v", err)
+	table := func(rows string) string {
+		return `<table><tr><th>Transaction ID</th><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th><th>Commission</th><th>Remaining</th></tr>` + rows + `</table>`
+	}
+	first := table(`<tr><td>TX-1</td><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>10.00</td><td>1.00</td><td>9.00</td></tr>
+		<tr><td>TX-2</td><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Chair</td><td>20.00</td><td>2.00</td><td>18.00</td></tr>`)
+	second := table(`<tr><td>TX-1</td><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>10.00</td><td>1.00</td><td>9.00</td></tr>
+		<tr><td>TX-2</td><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Chair</td><td>25.00</td><td>2.50</td><td>22.50</td></tr>
+		<tr><td>TX-3</td><td>Store A</td><td>Vendor 1</td><td>2024-01-16</td><td>Table</td><td>30.00</td><td>3.00</td><td>27.00</td></tr>`)
+
+	result, err := app.ImportHTMLDataWithOptions(first, ImportOptions{Upsert: true})
+	if err != nil || !result.Success {
+		t.Fatalf("First upsert import failed: %v %+v", err, result)
+	}
+	if result.ImportedRows != 2 || result.UpdatedRows != 0 || result.UnchangedRows != 0 {
+		t.Errorf("Expected 2 imported, got %+v", result)
 	}
 
-	// Get statistics
-	stats, err := app.GetImportStatistics()
+	result, err = app.ImportHTMLDataWithOptions(second, ImportOptions{Upsert: true})
+	if err != nil || !result.Success {
+		t.Fatalf("Second upsert import failed: %v %+v", err, result)
+	}
+	if result.ImportedRows != 1 || result.UpdatedRows != 1 || result.UnchangedRows != 1 {
+		t.Errorf("Expected 1 imported, 1 updated, 1 unchanged, got %+v", result)
+	}
+	if result.Timings.Validate <= 0 || result.Timings.Dedupe <= 0 || result.Timings.Insert <= 0 {
+		t.Errorf("Expected validate, dedupe and insert timings, got %+v", result.Timings)
+	}
+	history, err := app.GetImportHistory(1)
+	if err != nil || len(history) != 1 || history[0].Timings == nil {
+		t.Errorf("Expected the import history to keep the timings, got %+v, %v", history, err)
+	}
+
+	stats, err := app.dbService.GetDatabaseStats()
 	if err != nil {
-		t.Fatalf("GetImportStatistics failed: %v", err)
+		t.Fatalf("GetDatabaseStats failed: %v", err)
+	}
+	if stats.TotalRecords != 3 {
+		t.Errorf("Expected 3 records, got %d", stats.TotalRecords)
 	}
+}
 
-	if stats.TotalRecords != 2 {
-		t.Errorf("Expected TotalRecords=2, got %d", stats.TotalRecords)
+func TestApp_ImportHTMLDataWithOptions_Layout(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	etsyCSV := `Sale Date,Item Name,Quantity,Item Total,Currency,Transaction ID
+05/03/24,Hand-Thrown Mug,2,44.00,USD,3011223344
+05/04/24,"Ring, Sterling",1,58.50,GBP,3011223345
+`
+
+	result, err := app.ImportHTMLDataWithOptions(etsyCSV, ImportOptions{Layout: "etsy", Upsert: true})
+	if err != nil || !result.Success {
+		t.Fatalf("Layout import failed: %v %+v", err, result)
+	}
+	if result.ImportedRows != 2 {
+		t.Errorf("Expected 2 imported rows, got %+v", result)
 	}
 
-	if stats.TotalSales != 300.00 { // 100 + 200
-		t.Errorf("Expected TotalSales=300.00, got %f", stats.TotalSales)
+	// Re-importing the export updates nothing thanks to the Etsy transaction ids
+	result, err = app.ImportHTMLDataWithOptions(etsyCSV, ImportOptions{Layout: "etsy", Upsert: true})
+	if err != nil || result.UnchangedRows != 2 {
+		t.Errorf("Expected 2 unchanged rows on re-import, got %v %+v", err, result)
 	}
 
-	if stats.AveragePrice != 150.00 { // (100 + 200) / 2
-		t.Errorf("Expected AveragePrice=150.00, got %f", stats.AveragePrice)
+	if _, err := app.ImportHTMLDataWithOptions(etsyCSV, ImportOptions{Layout: "unknown"}); err == nil {
+		t.Error("Expected error for unknown layout")
+	}
+
+	if len(app.GetImportLayouts()) == 0 {
+		t.Error("Expected built-in layouts")
 	}
 }
 
-func TestApp_GetRecentImports(t *testing.T) {
+func TestApp_ImportHTMLDataWithOptions_AutoLayout(t *testing.T) {
 	app := setupTestApp(t)
 	defer app.dbService.Close()
 
-	// Import some test data first
-	_, err := app.ImportHTMLData(testHTMLData)
+	squareCSV := `Date,Time,Time Zone,Category,Item,Qty,Gross Sales,Net Sales,Event Type,Location
+2024-03-02,10:15:32,Eastern Time (US & Canada),Ceramics,Blue Glazed Vase,1,$45.00,$45.00,Payment,Main Street Market
+2024-03-05,16:40:03,Eastern Time (US & Canada),Ceramics,Blue Glazed Vase,-1,-$45.00,-$45.00,Refund,Main Street Market
+`
+
+	validation, err := app.ValidateHTMLData(squareCSV)
 	if err != nil {
-		t.Fatalf("Failed to import test data: %v", err)
+		t.Fatalf("ValidateHTMLData failed: %v", err)
+	}
+	if len(validation.SuggestedLayouts) == 0 || validation.SuggestedLayouts[0].Layout != "square" {
+		t.Errorf("Expected square to be suggested, got %+v", validation.SuggestedLayouts)
 	}
 
-	// Get recent imports
-	records, err := app.GetRecentImports(10)
-	if err != nil {
-		t.Fatalf("GetRecentImports failed: %v", err)
+	result, err := app.ImportHTMLDataWithOptions(squareCSV, ImportOptions{Layout: "auto"})
+	if err != nil || !result.Success {
+		t.Fatalf("Auto layout import failed: %v %+v", err, result)
+	}
+	if result.Layout != "square" || result.ImportedRows != 2 {
+		t.Errorf("Expected 2 rows imported with the square layout, got %+v", result)
 	}
 
-	if len(records) != 2 {
-		t.Errorf("Expected 2 recent records, got %d", len(records))
+	stream, err := app.ImportHTMLDataStream(squareCSV, ImportOptions{Layout: "auto", DryRun: true})
+	if err != nil || stream.ImportedRows != 2 || stream.Layout != "square" {
+		t.Errorf("Expected streamed auto layout import of 2 rows, got %v %+v", err, stream)
 	}
 
-	// Records should be sorted by created_at desc, so newest first
-	// Both records should have recent timestamps
-	for _, record := range records {
-		if time.Since(record.CreatedAt) > time.Minute {
-			t.Errorf("Expected recent record, but CreatedAt is %v", record.CreatedAt)
-		}
+	// Generic tables fall back to header matching
+	table := `<table><tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>10.00</td></tr></table>`
+	result, err = app.ImportHTMLDataWithOptions(table, ImportOptions{Layout: "auto"})
+	if err != nil || !result.Success || result.Layout != "" {
+		t.Errorf("Expected generic import without a layout, got %v %+v", err, result)
 	}
 }
 
-func TestApp_ImportHTMLData_DatabaseError(t *testing.T) {
-	app := NewApp() // No database service initialized
+func TestApp_ImportHistory(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
 
-	result, err := app.ImportHTMLData(testHTMLData)
-	if err == nil {
-		t.Fatalf("Expected error for uninitialized database service, got nil")
+	table := `<table><tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>10.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>not a date</td><td>Chair</td><td>20.00</td></tr></table>`
+
+	if _, err := app.ImportHTMLDataBatch(table); err != nil {
+		t.Fatalf("Batch import failed: %v", err)
+	}
+	// Dry runs persist nothing, so they are not part of the history
+	if _, err := app.ImportHTMLDataWithOptions(table, ImportOptions{DryRun: true}); err != nil {
+		t.Fatalf("Dry run failed: %v", err)
 	}
 
-	if result != nil {
-		t.Errorf("Expected nil result for error case, got %+v", result)
+	path := filepath.Join(t.TempDir(), "january.html")
+	if err := os.WriteFile(path, []byte("<h1>January Statement</h1>"+table), 0o644); err != nil {
+		t.Fatalf("Failed to write import file: %v", err)
+	}
+	if _, err := app.ImportHTMLFile(path, ImportOptions{}); err != nil {
+		t.Fatalf("File import failed: %v", err)
+	}
+
+	history, err := app.GetImportHistory(10)
+	if err != nil {
+		t.Fatalf("GetImportHistory failed: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 recorded imports, got %+v", history)
+	}
+	for _, run := range history {
+		if run.TotalRows != 2 || run.ImportedRows != 1 || run.ErrorRows != 1 {
+			t.Errorf("Unexpected import summary: %+v", run)
+		}
+	}
+	if file := history[0]; file.Source != "file" || file.Method != "stream" || file.FileName == nil || *file.FileName != "january.html" {
+		t.Errorf("Expected the newest import to be the january.html file, got %+v", file)
+	}
+	// Imports are named after the heading of the imported table, if it has one
+	if title := history[0].Title; title == nil || *title != "January Statement" {
+		t.Errorf("Expected the file import to be titled from its heading, got %v", title)
+	}
+	if history[1].Title != nil {
+		t.Errorf("Expected no title for a table without a heading, got %q", *history[1].Title)
+	}
+	// and described by the records they wrote
+	for i, want := range []string{"january – January 2024 – Store A, 2 rows", "Pasted data – January 2024 – Store A, 2 rows"} {
+		if name := history[i].Name; name == nil || *name != want {
+			t.Errorf("Expected import %d to be named %q, got %v", i, want, name)
+		}
+	}
+
+	activity, err := app.GetImportActivity()
+	if err != nil {
+		t.Fatalf("GetImportActivity failed: %v", err)
+	}
+	if len(activity) != 1 || activity[0].Imports != 2 || activity[0].ErrorRate != 0.5 {
+		t.Errorf("Expected one month with 2 imports, got %+v", activity)
 	}
 }
 
-func TestApp_ImportHTMLData_ParseError(t *testing.T) {
+func TestApp_ImportHTMLFile_SavedPage(t *testing.T) {
 	app := setupTestApp(t)
 	defer app.dbService.Close()
 
-	invalidHTML := "not valid html"
-
-	result, err := app.ImportHTMLData(invalidHTML)
-	if err != nil {
-		t.Fatalf("ImportHTMLData should not return error for parse failures: %v", err)
+	mhtml := "From: <Saved by Blink>\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/related; type=\"text/html\"; boundary=\"----boundary----\"\r\n" +
+		"\r\n" +
+		"------boundary----\r\n" +
+		"Content-Type: text/html\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"<h1>January Statement</h1><table><tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>=\r\n" +
+		"<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>10.00</td></tr></table>\r\n" +
+		"------boundary------\r\n"
+	for _, multiTable := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "statement.mht")
+		if err := os.WriteFile(path, []byte(mhtml), 0o644); err != nil {
+			t.Fatalf("Failed to write import file: %v", err)
+		}
+		result, err := app.ImportHTMLFile(path, ImportOptions{MultiTable: multiTable})
+		if err != nil || !result.Success || result.ImportedRows != 1 {
+			t.Errorf("Expected the saved page to import (multi-table %v), got %+v, %v", multiTable, result, err)
+		}
 	}
 
-	if result.Success {
-		t.Errorf("Expected Success=false for invalid HTML, got %v", result.Success)
+	// The import history keeps the file as it was saved
+	history, err := app.GetImportHistory(10)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("Expected 2 recorded imports, got %+v, %v", history, err)
+	}
+	if source, err := app.GetImportSource(history[0].ID); err != nil || source.Content != mhtml {
+		t.Errorf("Expected the saved page to be kept, got %+v, %v", source, err)
 	}
 
-	if result.ErrorMessage == "" {
-		t.Errorf("Expected error message for invalid HTML, got empty string")
+	path := filepath.Join(t.TempDir(), "broken.mht")
+	if err := os.WriteFile(path, []byte("MIME-Version: 1.0\r\nContent-Type: multipart/related; boundary=b\r\n\r\n--b--\r\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write import file: %v", err)
+	}
+	result, err := app.ImportHTMLFile(path, ImportOptions{})
+	if err != nil || result.Success || result.Error == nil || result.Error.Code != ErrCodeParse {
+		t.Errorf("Expected a parse failure for a saved page without HTML, got %+v, %v", result, err)
 	}
 }
 
-// Benchmark tests
-func BenchmarkApp_ImportHTMLData(b *testing.B) {
-	// Create temporary database
-	tempDir := b.TempDir()
-	dbPath := filepath.Join(tempDir, "bench.db")
-	
-	app := NewApp()
-	config := database.Config{
-		FilePath:    dbPath,
-		InMemory:    false,
-		AutoMigrate: true,
+func TestApp_IgnoreRules(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	if _, err := app.SaveIgnoreRules([]models.IgnoreRule{{Field: models.IgnoreFieldDescription, Pattern: "("}}); newAppError(err).Code != ErrCodeValidation {
+		t.Errorf("Expected a validation error for an invalid pattern, got %v", err)
 	}
-	
-	dbService, err := database.NewService(config)
-	if err != nil {
-		b.Fatalf("Failed to create benchmark database service: %v", err)
+	if _, err := app.SaveIgnoreRules([]models.IgnoreRule{{Field: models.IgnoreFieldDescription, Pattern: "listing fee|shipping"}}); err != nil {
+		t.Fatalf("SaveIgnoreRules failed: %v", err)
 	}
-	defer dbService.Close()
-	
-	app.dbService = dbService
-	app.ctx = context.Background()
 
-	b.ResetTimer()
-	
-	for i := 0; i < b.N; i++ {
-		_, err := app.ImportHTMLData(testHTMLData)
-		if err != nil {
-			b.Fatalf("ImportHTMLData failed: %v", err)
+	table := `<table><tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>10.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-31</td><td>LISTING FEE</td><td>-2.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-31</td><td>SHIPPING</td><td>5.00</td></tr></table>`
+
+	validation, err := app.ValidateHTMLData(table)
+	if err != nil || validation.IgnoredRows != 2 || validation.TotalRows != 1 {
+		t.Errorf("Expected validation to skip the fee lines, got %+v, %v", validation, err)
+	}
+	for name, run := range map[string]func() (*ImportResult, error){
+		"single":  func() (*ImportResult, error) { return app.ImportHTMLData(table) },
+		"stream":  func() (*ImportResult, error) { return app.ImportHTMLDataStream(table, ImportOptions{DryRun: true}) },
+		"options": func() (*ImportResult, error) { return app.ImportHTMLDataWithOptions(table, ImportOptions{DryRun: true}) },
+	} {
+		result, err := run()
+		if err != nil || !result.Success || result.IgnoredRows != 2 || result.TotalRows != 1 || result.ImportedRows != 1 {
+			t.Errorf("%s: expected the fee lines to be ignored, got %+v, %v", name, result, err)
 		}
 	}
-}
 
-func BenchmarkApp_ImportHTMLDataBatch(b *testing.B) {
-	// Create temporary database
-	tempDir := b.TempDir()
-	dbPath := filepath.Join(tempDir, "bench_batch.db")
-	
-	app := NewApp()
-	config := database.Config{
-		FilePath:    dbPath,
-		InMemory:    false,
-		AutoMigrate: true,
+	// Ignored rows are not counted as errors in the import history
+	history, err := app.GetImportHistory(10)
+	if err != nil || len(history) != 1 || history[0].ErrorRows != 0 {
+		t.Errorf("Expected one import without errors, got %+v, %v", history, err)
 	}
-	
-	dbService, err := database.NewService(config)
-	if err != nil {
-		b.Fatalf("Failed to create benchmark database service: %v", err)
+
+	// A rule with a fee category records the rows it matches as fees, except
+	// in dry runs
+	rules := []models.IgnoreRule{{Field: models.IgnoreFieldDescription, Pattern: "listing fee", FeeCategory: models.FeeCategoryListing}}
+	if _, err := app.SaveIgnoreRules(rules); err != nil {
+		t.Fatalf("SaveIgnoreRules failed: %v", err)
 	}
-	defer dbService.Close()
-	
-	app.dbService = dbService
-	app.ctx = context.Background()
+	if result, err := app.ImportHTMLDataWithOptions(table, ImportOptions{DryRun: true}); err != nil || result.FeeRows != 1 {
+		t.Errorf("Expected a dry run to count the fee, got %+v, %v", result, err)
+	}
+	result, err := app.ImportHTMLData(table)
+	if err != nil || !result.Success || result.FeeRows != 1 {
+		t.Fatalf("Expected the listing fee to be recorded, got %+v, %v", result, err)
+	}
+	fees, err := app.ListFees(models.FeeFilter{})
+	if err != nil || len(fees) != 1 || fees[0].Amount != 2.00 || fees[0].ImportRunID == nil {
+		t.Errorf("Expected one listing fee from the import, got %+v, %v", fees, err)
+	}
+}
 
-	b.ResetTimer()
-	
-	for i := 0; i < b.N; i++ {
-		_, err := app.ImportHTMLDataBatch(testHTMLData)
+func TestApp_ImportQuarantine(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	table := `<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th><th>Commission</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>$40.00</td><td>$10.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2999-01-15</td><td>Vase</td><td>$20.00</td><td>$5.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-16</td><td>Rug</td><td>$10.00</td><td>$100.00</td></tr>
+	</table>`
+
+	// Dry runs count quarantined rows without keeping them
+	result, err := app.ImportHTMLDataWithOptions(table, ImportOptions{Quarantine: true, DryRun: true})
+	if err != nil || result.QuarantinedRows != 2 || result.TotalRows != 1 {
+		t.Fatalf("Expected a dry run to quarantine 2 rows, got %+v, %v", result, err)
+	}
+	if rows, err := app.ListQuarantinedRows(); err != nil || len(rows) != 0 {
+		t.Fatalf("Expected no rows kept by a dry run, got %+v, %v", rows, err)
+	}
+
+	result, err = app.ImportHTMLDataWithOptions(table, ImportOptions{Quarantine: true})
+	if err != nil || !result.Success || result.ImportedRows != 1 || result.QuarantinedRows != 2 {
+		t.Fatalf("Expected the lamp imported and 2 rows quarantined, got %+v, %v", result, err)
+	}
+	rows, err := app.ListQuarantinedRows()
+	if err != nil || len(rows) != 2 || rows[0].ImportRunID == nil || len(rows[1].Reasons) != 1 {
+		t.Fatalf("Expected the vase and rug awaiting review, got %+v, %v", rows, err)
+	}
+	vase, rug := rows[0], rows[1]
+
+	// Approving with corrections imports the edited record under the import
+	corrected := rug.Record
+	commission := 1.00
+	corrected.Commission = &commission
+	record, err := app.ApproveQuarantinedRow(rug.ID, &corrected)
+	if err != nil || record.Commission == nil || *record.Commission != 1.00 {
+		t.Fatalf("Expected the corrected rug imported, got %+v, %v", record, err)
+	}
+	provenance, err := app.GetRecordProvenance(record.ID)
+	if err != nil || provenance.Import == nil || provenance.Import.ID != *rug.ImportRunID || provenance.SourceRow == nil {
+		t.Errorf("Expected the rug traced to its import and row, got %+v, %v", provenance, err)
+	}
+	if err := app.DiscardQuarantinedRow(vase.ID); err != nil {
+		t.Fatalf("DiscardQuarantinedRow failed: %v", err)
+	}
+	if rows, err := app.ListQuarantinedRows(); err != nil || len(rows) != 0 {
+		t.Errorf("Expected the quarantine to be empty, got %+v, %v", rows, err)
+	}
+	if _, err := app.ApproveQuarantinedRow(vase.ID, nil); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("Expected a discarded row to be gone, got %v", err)
+	}
+}
+
+func TestApp_NameAliases(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	saved, err := app.SaveNameAlias(models.SaveNameAliasRequest{Kind: models.AliasKindStore, Alias: "test store", Canonical: "Main Street"})
+	if err != nil {
+		t.Fatalf("SaveNameAlias failed: %v", err)
+	}
+
+	// Every kind of import files records under the canonical name
+	for _, options := range []ImportOptions{{}, {UseBatchImport: true}, {Upsert: true}} {
+		result, err := app.ImportHTMLDataWithOptions(testHTMLData, options)
+		if err != nil {
+			t.Fatalf("ImportHTMLDataWithOptions failed (%+v): %v", options, err)
+		}
+		if len(result.ImportedRecords) > 0 && result.ImportedRecords[0].Store != "Main Street" {
+			t.Errorf("Expected the canonical store (%+v), got %q", options, result.ImportedRecords[0].Store)
+		}
+	}
+	if _, err := app.ImportHTMLDataStream(testHTMLData, ImportOptions{}); err != nil {
+		t.Fatalf("ImportHTMLDataStream failed: %v", err)
+	}
+
+	store := "Main Street"
+	list, err := app.dbService.ListSalesRecords(models.SalesRecordFilter{Store: &store})
+	if err != nil {
+		t.Fatalf("ListSalesRecords failed: %v", err)
+	}
+	if list.Total != 4 {
+		t.Errorf("Expected 4 records for the canonical store, got %d", list.Total)
+	}
+
+	aliases, err := app.ListNameAliases("")
+	if err != nil || len(aliases) != 1 {
+		t.Fatalf("Expected 1 alias, got %v (%v)", aliases, err)
+	}
+	if err := app.DeleteNameAlias(saved.ID); err != nil {
+		t.Fatalf("DeleteNameAlias failed: %v", err)
+	}
+	if _, err := app.SaveNameAlias(models.SaveNameAliasRequest{Kind: "product", Alias: "A", Canonical: "B"}); newAppError(err).Code != ErrCodeValidation {
+		t.Errorf("Expected a validation error for an unknown kind, got %v", err)
+	}
+}
+
+func TestApp_GetRecordProvenance(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	table := `<table><tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>$10.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-16</td><td>Chair</td><td>$20.00</td></tr></table>`
+	// Dry runs write nothing, so they leave no import to trace records to
+	if _, err := app.ImportHTMLDataWithOptions(table, ImportOptions{DryRun: true}); err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	result, err := app.ImportHTMLDataBatch(table)
+	if err != nil || len(result.ImportedRecords) != 2 {
+		t.Fatalf("Batch import failed: %+v, %v", result, err)
+	}
+	chair := result.ImportedRecords[1]
+
+	salePrice := 18.00
+	if _, err := app.dbService.UpdateSalesRecord(chair.ID, models.UpdateSalesRecordRequest{SalePrice: &salePrice}); err != nil {
+		t.Fatalf("UpdateSalesRecord failed: %v", err)
+	}
+	if _, err := app.dbService.CreateAdjustment(models.CreateSalesAdjustmentRequest{
+		SalesRecordID: chair.ID, Date: "2024-02-01", Reason: "Discount", SalePriceDelta: -2.00,
+	}); err != nil {
+		t.Fatalf("CreateAdjustment failed: %v", err)
+	}
+
+	provenance, err := app.GetRecordProvenance(chair.ID)
+	if err != nil {
+		t.Fatalf("GetRecordProvenance failed: %v", err)
+	}
+	if provenance.Record.SalePrice != 18.00 {
+		t.Errorf("Expected the current record, got %+v", provenance.Record)
+	}
+	history, _ := app.GetImportHistory(10)
+	if len(history) != 1 || provenance.Import == nil || provenance.Import.ID != history[0].ID || !provenance.HasSource {
+		t.Fatalf("Expected the record to trace back to the batch import and its source, got %+v", provenance)
+	}
+	row := provenance.SourceRow
+	if row == nil || row.Row != 3 || row.Headers[4] != "Sale Price" || row.Cells[4] != "$20.00" {
+		t.Errorf("Expected the chair's source row as imported, got %+v", row)
+	}
+	if len(provenance.Adjustments) != 1 || provenance.Adjustments[0].Reason != "Discount" {
+		t.Errorf("Expected the discount adjustment, got %+v", provenance.Adjustments)
+	}
+	if len(provenance.History) != 1 || provenance.History[0].Details != "sale price 20.00 → 18.00" {
+		t.Errorf("Expected the sale price change in the history, got %+v", provenance.History)
+	}
+
+	// Records entered by hand have no import
+	manual, err := app.dbService.CreateSalesRecord(models.CreateSalesRecordRequest{
+		Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-20", Description: "Rug", SalePrice: 30.00,
+	})
+	if err != nil {
+		t.Fatalf("CreateSalesRecord failed: %v", err)
+	}
+	provenance, err = app.GetRecordProvenance(manual.ID)
+	if err != nil {
+		t.Fatalf("GetRecordProvenance failed: %v", err)
+	}
+	if provenance.Import != nil || provenance.SourceRow != nil || len(provenance.History) != 0 {
+		t.Errorf("Expected no import for a record entered by hand, got %+v", provenance)
+	}
+
+	if _, err := app.GetRecordProvenance(manual.ID + 100); newAppError(err).Code != ErrCodeNotFound {
+		t.Errorf("Expected a not found error for an unknown record, got %v", err)
+	}
+}
+
+func TestApp_GetImportSource(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	table := `<table><tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>10.00</td></tr></table>`
+	if _, err := app.ImportHTMLDataStream(table, ImportOptions{}); err != nil {
+		t.Fatalf("Stream import failed: %v", err)
+	}
+	page := "<h1>January Statement</h1>" + table
+	path := filepath.Join(t.TempDir(), "january.html")
+	if err := os.WriteFile(path, []byte(page), 0o644); err != nil {
+		t.Fatalf("Failed to write import file: %v", err)
+	}
+	if _, err := app.ImportHTMLFile(path, ImportOptions{}); err != nil {
+		t.Fatalf("File import failed: %v", err)
+	}
+
+	history, err := app.GetImportHistory(10)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("Expected 2 recorded imports, got %+v, %v", history, err)
+	}
+	// Both the pasted data and the file are kept exactly as imported, even
+	// though both were streamed
+	for i, want := range []string{page, table} {
+		source, err := app.GetImportSource(history[i].ID)
+		if err != nil {
+			t.Fatalf("GetImportSource failed: %v", err)
+		}
+		if source.Content != want || source.Size != int64(len(want)) {
+			t.Errorf("Expected the source of import %d to be %q, got %+v", history[i].ID, want, source)
+		}
+	}
+
+	if _, err := app.GetImportSource(history[0].ID + 100); newAppError(err).Code != ErrCodeNotFound {
+		t.Errorf("Expected a not found error for an unknown import, got %v", err)
+	}
+}
+
+func TestApp_RetentionPolicy(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	old := fmt.Sprintf("%d-01-15", time.Now().Year()-10)
+	table := `<table><tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>` + old + `</td><td>Lamp</td><td>10.00</td></tr></table>`
+	if _, err := app.ImportHTMLDataBatch(table); err != nil {
+		t.Fatalf("Batch import failed: %v", err)
+	}
+
+	policy, err := app.GetRetentionPolicy()
+	if err != nil {
+		t.Fatalf("GetRetentionPolicy failed: %v", err)
+	}
+	if policy.Enabled || policy.ArchiveAfterYears != 7 {
+		t.Errorf("Expected the disabled default policy, got %+v", policy)
+	}
+
+	preview, err := app.PreviewRetentionPolicy(policy)
+	if err != nil {
+		t.Fatalf("PreviewRetentionPolicy failed: %v", err)
+	}
+	if !preview.DryRun || preview.ArchivedRecords != 1 {
+		t.Errorf("Expected a preview archiving 1 record, got %+v", preview)
+	}
+
+	result, err := app.ApplyRetentionPolicy()
+	if err != nil {
+		t.Fatalf("ApplyRetentionPolicy failed: %v", err)
+	}
+	if result.DryRun || result.ArchivedRecords != 1 {
+		t.Errorf("Expected 1 archived record, got %+v", result)
+	}
+
+	stats, err := app.GetImportStatistics()
+	if err != nil {
+		t.Fatalf("GetImportStatistics failed: %v", err)
+	}
+	if stats.TotalRecords != 0 {
+		t.Errorf("Expected archived records to leave the statistics, got %d", stats.TotalRecords)
+	}
+
+	entries, err := app.GetAuditLog(10)
+	if err != nil {
+		t.Fatalf("GetAuditLog failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Action != models.AuditRetentionArchived {
+		t.Errorf("Expected one archive audit entry, got %+v", entries)
+	}
+}
+
+func TestApp_ConcurrentImportsWithOptions(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	// Headerless rows with the vendor and store columns swapped relative to
+	// the Consignable layout; each import must use its own parser settings
+	rows := `<tr><td>A</td><td>B</td><td>2024-01-15</td><td>Item</td><td>10.00</td><td>1.00</td><td>9.00</td></tr>`
+	consignable := ImportOptions{UseConsignableFormat: true}
+	swapped := ImportOptions{CustomColumnMapping: []string{"vendor", "store", "date", "description", "sale_price", "commission", "remaining"}}
+
+	const rounds = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, rounds*3)
+
+	for i := 0; i < rounds; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			result, err := app.ImportHTMLDataWithOptions(rows, consignable)
+			if err != nil || result.ImportedRows != 1 || result.ImportedRecords[0].Store != "A" {
+				errs <- fmt.Errorf("consignable import: %v %+v", err, result)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			result, err := app.ImportHTMLDataWithOptions(rows, swapped)
+			if err != nil || result.ImportedRows != 1 || result.ImportedRecords[0].Store != "B" {
+				errs <- fmt.Errorf("custom mapping import: %v %+v", err, result)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := app.GetImportStatistics(); err != nil {
+				errs <- fmt.Errorf("statistics: %w", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	stats, err := app.GetImportStatistics()
+	if err != nil {
+		t.Fatalf("GetImportStatistics failed: %v", err)
+	}
+	if stats.TotalRecords != rounds*2 {
+		t.Errorf("Expected %d records, got %d", rounds*2, stats.TotalRecords)
+	}
+}
+
+func TestApp_ValidateHTMLData(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	result, err := app.ValidateHTMLData(testHTMLData)
+	if err != nil {
+		t.Fatalf("ValidateHTMLData failed: %v", err)
+	}
+
+	// Verify validation results
+	if !result.Valid {
+		t.Errorf("Expected Valid=true, got %v", result.Valid)
+	}
+
+	if result.TotalRows != 2 {
+		t.Errorf("Expected TotalRows=2, got %d", result.TotalRows)
+	}
+
+	if result.ValidRows != 2 {
+		t.Errorf("Expected ValidRows=2, got %d", result.ValidRows)
+	}
+
+	if result.InvalidRows != 0 {
+		t.Errorf("Expected InvalidRows=0, got %d", result.InvalidRows)
+	}
+
+	// Each mapped field explains which header it came from
+	if match, ok := result.ColumnMatches["sale_price"]; !ok || match.Column != result.ColumnMapping["sale_price"] || match.Confidence == 0 {
+		t.Errorf("Expected a sale_price column match, got %+v", result.ColumnMatches)
+	}
+
+	// Records are shown as they would be stored, under their canonical names
+	if _, err := app.SaveNameAlias(models.SaveNameAliasRequest{Kind: models.AliasKindStore, Alias: "test store", Canonical: "Main Street"}); err != nil {
+		t.Fatalf("SaveNameAlias failed: %v", err)
+	}
+	result, err = app.ValidateHTMLData(testHTMLData)
+	if err != nil {
+		t.Fatalf("ValidateHTMLData failed: %v", err)
+	}
+	if len(result.Records) != 2 {
+		t.Fatalf("Expected 2 normalized records, got %+v", result.Records)
+	}
+	record := result.Records[0]
+	if record.Store != "Main Street" || record.Date != "2024-01-15" || record.SalePrice != 100.00 || record.Commission == nil || *record.Commission != 10.00 {
+		t.Errorf("Unexpected normalized record: %+v", record)
+	}
+	if result.Records[1].Store != "Another Store" {
+		t.Errorf("Expected stores without an alias to keep their name, got %q", result.Records[1].Store)
+	}
+}
+
+func TestApp_ValidateHTMLData_Invalid(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	invalidHTML := `
+	<table>
+		<tr>
+			<th>Store</th>
+			<th>Vendor</th>
+			<th>Date</th>
+			<th>Description</th>
+			<th>Sale Price</th>
+		</tr>
+		<tr>
+			<td></td>
+			<td>Test Vendor</td>
+			<td>invalid-date</td>
+			<td></td>
+			<td>not-a-price</td>
+		</tr>
+	</table>
+	`
+
+	result, err := app.ValidateHTMLData(invalidHTML)
+	if err != nil {
+		t.Fatalf("ValidateHTMLData failed: %v", err)
+	}
+
+	// Should have validation errors
+	if result.Valid {
+		t.Errorf("Expected Valid=false for invalid data, got %v", result.Valid)
+	}
+
+	if result.InvalidRows == 0 {
+		t.Errorf("Expected InvalidRows>0 for invalid data, got %d", result.InvalidRows)
+	}
+
+	if len(result.Errors) == 0 {
+		t.Errorf("Expected validation errors for invalid data, got none")
+	}
+}
+
+func TestApp_GetDatabaseHealth(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	health, err := app.GetDatabaseHealth()
+	if err != nil {
+		t.Fatalf("GetDatabaseHealth failed: %v", err)
+	}
+
+	if !health.Connected {
+		t.Errorf("Expected Connected=true, got %v", health.Connected)
+	}
+
+	if health.Error != "" {
+		t.Errorf("Expected no error, got '%s'", health.Error)
+	}
+
+	// A fresh database has never been backed up
+	if health.Status != database.SeverityWarning || len(health.Checks) != 6 {
+		t.Errorf("Expected a warning status from 6 checks, got %s from %+v", health.Status, health.Checks)
+	}
+
+	if health.Metrics == nil || len(health.Metrics.Queries) == 0 || health.Metrics.Connections.Open == 0 {
+		t.Errorf("Expected connection statistics and query timings, got %+v", health.Metrics)
+	}
+
+	metrics, err := app.GetDatabaseMetrics()
+	if err != nil || metrics.SlowQueryThreshold != models.Duration(database.DefaultSlowQueryThreshold) {
+		t.Errorf("Expected the metrics with the default slow query threshold, got %+v, %v", metrics, err)
+	}
+}
+
+func TestApp_CheckSchemaCompatibility(t *testing.T) {
+	app := NewApp()
+	if _, err := app.CheckSchemaCompatibility(); err != errNotInitialized {
+		t.Errorf("Expected errNotInitialized before startup, got %v", err)
+	}
+
+	// A database migrated by a newer build is refused at startup
+	app.dbPath = filepath.Join(t.TempDir(), "sales_track.db")
+	service, err := database.NewService(database.Config{FilePath: app.dbPath, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	if _, err := service.GetDB().Conn().Exec("INSERT INTO migrations (version, name) VALUES (999, 'future')"); err != nil {
+		t.Fatalf("Failed to record future migration: %v", err)
+	}
+	service.Close()
+
+	_, app.dbErr = database.NewService(database.Config{FilePath: app.dbPath, AutoMigrate: true})
+	if code := newAppError(app.dbErr).Code; code != ErrCodeSchemaTooNew {
+		t.Errorf("Expected %s, got %s (%v)", ErrCodeSchemaTooNew, code, app.dbErr)
+	}
+
+	compatibility, err := app.CheckSchemaCompatibility()
+	if err != nil {
+		t.Fatalf("CheckSchemaCompatibility failed: %v", err)
+	}
+	if compatibility.Compatible || compatibility.AppliedVersion != 999 {
+		t.Errorf("Expected an incompatible schema at version 999, got %+v", compatibility)
+	}
+
+	health, err := app.GetDatabaseHealth()
+	if err != nil {
+		t.Fatalf("GetDatabaseHealth failed: %v", err)
+	}
+	if len(health.Checks) != 1 || health.Checks[0].Name != "schema_version" || !strings.Contains(health.Error, "newer version") {
+		t.Errorf("Expected the health report to explain the schema version, got %+v", health)
+	}
+}
+
+func TestApp_GetDatabaseHealth_NotInitialized(t *testing.T) {
+	app := NewApp()
+
+	health, err := app.GetDatabaseHealth()
+	if err != nil {
+		t.Fatalf("GetDatabaseHealth failed: %v", err)
+	}
+
+	if health.Connected {
+		t.Errorf("Expected Connected=false for uninitialized service, got %v", health.Connected)
+	}
+
+	if health.Error == "" {
+		t.Errorf("Expected error message for uninitialized service, got empty string")
+	}
+
+	if health.Status != database.SeverityCritical || len(health.Checks) != 1 || health.Checks[0].Action == "" {
+		t.Errorf("Expected a critical connection check with an action, got %+v", health)
+	}
+}
+
+func TestApp_GetImportStatistics(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	// Import some test data first
+	_, err := app.ImportHTMLData(testHTMLData)
+	if err != nil {
+		t.Fatalf("Failed to import test data: %v", err)
+	}
+
+	// Get statistics
+	stats, err := app.GetImportStatistics()
+	if err != nil {
+		t.Fatalf("GetImportStatistics failed: %v", err)
+	}
+
+	if stats.TotalRecords != 2 {
+		t.Errorf("Expected TotalRecords=2, got %d", stats.TotalRecords)
+	}
+
+	if stats.TotalSales != 300.00 { // 100 + 200
+		t.Errorf("Expected TotalSales=300.00, got %f", stats.TotalSales)
+	}
+
+	if stats.AveragePrice != 150.00 { // (100 + 200) / 2
+		t.Errorf("Expected AveragePrice=150.00, got %f", stats.AveragePrice)
+	}
+}
+
+func TestApp_GetRecentImports(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	// Import some test data first
+	_, err := app.ImportHTMLData(testHTMLData)
+	if err != nil {
+		t.Fatalf("Failed to import test data: %v", err)
+	}
+
+	// Get recent imports
+	records, err := app.GetRecentImports(10)
+	if err != nil {
+		t.Fatalf("GetRecentImports failed: %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Errorf("Expected 2 recent records, got %d", len(records))
+	}
+
+	// Records should be sorted by created_at desc, so newest first
+	// Both records should have recent timestamps
+	for _, record := range records {
+		if time.Since(record.CreatedAt) > time.Minute {
+			t.Errorf("Expected recent record, but CreatedAt is %v", record.CreatedAt)
+		}
+	}
+}
+
+func TestApp_ImportHTMLData_DatabaseError(t *testing.T) {
+	app := NewApp() // No database service initialized
+
+	result, err := app.ImportHTMLData(testHTMLData)
+	if err == nil {
+		t.Fatalf("Expected error for uninitialized database service, got nil")
+	}
+
+	if result != nil {
+		t.Errorf("Expected nil result for error case, got %+v", result)
+	}
+}
+
+func TestApp_ImportHTMLData_ParseError(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	invalidHTML := "not valid html"
+
+	result, err := app.ImportHTMLData(invalidHTML)
+	if err != nil {
+		t.Fatalf("ImportHTMLData should not return error for parse failures: %v", err)
+	}
+
+	if result.Success {
+		t.Errorf("Expected Success=false for invalid HTML, got %v", result.Success)
+	}
+
+	if result.ErrorMessage == "" {
+		t.Errorf("Expected error message for invalid HTML, got empty string")
+	}
+
+	if result.Error == nil || result.Error.Code != ErrCodeParse || result.Error.Message != result.ErrorMessage {
+		t.Errorf("Expected a %s error matching ErrorMessage, got %+v", ErrCodeParse, result.Error)
+	}
+}
+
+func TestApp_ImportHTMLDataWithOptions_MultiTable(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	statement := `
+	<h2>January 2024</h2>
+	<table>
+		<tr><th>Store</th><th>Vendor</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>Lamp</td><td>40.00</td></tr>
+	</table>
+	<h2>February 2024</h2>
+	<table>
+		<tr><th>Store</th><th>Vendor</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>Chair</td><td>75.00</td></tr>
+		<tr><td>Store B</td><td>Vendor 2</td><td>Rug</td><td>120.00</td></tr>
+	</table>`
+
+	result, err := app.ImportHTMLDataWithOptions(statement, ImportOptions{MultiTable: true, UseBatchImport: true})
+	if err != nil {
+		t.Fatalf("ImportHTMLDataWithOptions failed: %v", err)
+	}
+	if !result.Success || result.ImportedRows != 3 {
+		t.Fatalf("Expected 3 imported records, got %d (%s)", result.ImportedRows, result.ErrorMessage)
+	}
+	if len(result.Tables) != 2 || result.Tables[1].Period != "2024-02" || result.Tables[1].SuccessCount != 2 {
+		t.Errorf("Expected two monthly tables, got %+v", result.Tables)
+	}
+	if date := result.ImportedRecords[2].Date.String(); date != "2024-02-01" {
+		t.Errorf("Expected the February table's records dated 2024-02-01, got %s", date)
+	}
+}
+
+func TestApp_AppLock(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	if err := app.LockApp(); newAppError(err).Code != ErrCodeValidation {
+		t.Errorf("Expected a validation error locking without a lock set, got %v", err)
+	}
+	if err := app.SetAppLock("", "2468"); err != nil {
+		t.Fatalf("SetAppLock failed: %v", err)
+	}
+	if err := app.LockApp(); err != nil {
+		t.Fatalf("LockApp failed: %v", err)
+	}
+
+	// Nothing is returned while locked
+	if _, err := app.GetImportHistory(10); newAppError(err).Code != ErrCodeLocked {
+		t.Errorf("Expected %s while locked, got %v", ErrCodeLocked, err)
+	}
+	if health, err := app.GetDatabaseHealth(); err != nil || health.Connected || health.Checks[0].Name != "app_lock" {
+		t.Errorf("Expected the health report to say the app is locked, got %+v, %v", health, err)
+	}
+	if status, err := app.GetAppLockStatus(); err != nil || !status.Enabled || !status.Locked {
+		t.Errorf("Expected a locked status, got %+v, %v", status, err)
+	}
+
+	// Too many wrong entries hold unlocking back for a while
+	for i := 0; i < maxUnlockAttempts; i++ {
+		if err := app.UnlockApp("1357"); newAppError(err).Code != ErrCodeValidation {
+			t.Fatalf("Expected a wrong PIN to be refused, got %v", err)
+		}
+	}
+	if err := app.UnlockApp("2468"); newAppError(err).Code != ErrCodeLocked || !newAppError(err).Retryable {
+		t.Errorf("Expected unlocking to be held back, got %v", err)
+	}
+	if status, _ := app.GetAppLockStatus(); status.RetryAfter == nil {
+		t.Errorf("Expected the status to say when to retry, got %+v", status)
+	}
+	app.lock.retryAfter = time.Time{}
+
+	if err := app.UnlockApp("2468"); err != nil {
+		t.Fatalf("UnlockApp failed: %v", err)
+	}
+	if _, err := app.GetImportHistory(10); err != nil {
+		t.Errorf("Expected data once unlocked, got %v", err)
+	}
+
+	if err := app.RemoveAppLock("1357"); newAppError(err).Code != ErrCodeValidation {
+		t.Errorf("Expected a wrong PIN not to remove the lock, got %v", err)
+	}
+	if err := app.RemoveAppLock("2468"); err != nil {
+		t.Fatalf("RemoveAppLock failed: %v", err)
+	}
+	if status, err := app.GetAppLockStatus(); err != nil || status.Enabled || status.Locked {
+		t.Errorf("Expected no lock, got %+v, %v", status, err)
+	}
+}
+
+// Run with -race: bindings running while the app is locked and unlocked must
+// either answer or report the lock, never see a half-locked app
+func TestApp_LockDuringBindings(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	if err := app.SetAppLock("", "2468"); err != nil {
+		t.Fatalf("SetAppLock failed: %v", err)
+	}
+
+	const rounds = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, rounds*3)
+
+	for i := 0; i < rounds; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if err := app.LockApp(); err != nil {
+				errs <- fmt.Errorf("lock: %w", err)
+			}
+			if err := app.UnlockApp("2468"); err != nil {
+				errs <- fmt.Errorf("unlock: %w", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := app.GetImportHistory(10); err != nil && newAppError(err).Code != ErrCodeLocked {
+				errs <- fmt.Errorf("import history: %w", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := app.GetImportStatistics(); err != nil && newAppError(err).Code != ErrCodeLocked {
+				errs <- fmt.Errorf("statistics: %w", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestApp_SettingsTransfer(t *testing.T) {
+	source := setupTestApp(t)
+	defer source.dbService.Close()
+	target := setupTestApp(t)
+	defer target.dbService.Close()
+
+	format := models.ExportFormat{DecimalSeparator: ",", ThousandsSeparator: ".", CSVDelimiter: ";", CurrencySymbol: "€", SymbolPlacement: models.SymbolAfter, DateFormat: "DD.MM.YYYY"}
+	if err := source.SaveExportFormat(format); err != nil {
+		t.Fatalf("SaveExportFormat failed: %v", err)
+	}
+	if _, err := source.SaveIgnoreRules([]models.IgnoreRule{{Field: models.IgnoreFieldDescription, Pattern: "LISTING FEE", FeeCategory: models.FeeCategoryListing}}); err != nil {
+		t.Fatalf("SaveIgnoreRules failed: %v", err)
+	}
+	if _, err := source.SaveNameAlias(models.SaveNameAliasRequest{Kind: models.AliasKindStore, Alias: "DT Branch", Canonical: "Downtown Store"}); err != nil {
+		t.Fatalf("SaveNameAlias failed: %v", err)
+	}
+	if _, err := source.SaveCommissionRule(models.CreateCommissionRuleRequest{Store: "Downtown Store", EffectiveFrom: "2024-01-01", Rate: 0.3}); err != nil {
+		t.Fatalf("SaveCommissionRule failed: %v", err)
+	}
+	if _, err := source.SavePayoutSchedule(models.SavePayoutScheduleRequest{Store: "Downtown Store", PayoutDay: 15, MonthsAfter: 1}); err != nil {
+		t.Fatalf("SavePayoutSchedule failed: %v", err)
+	}
+	if err := source.SaveWeekStart("Sunday"); err != nil {
+		t.Fatalf("SaveWeekStart failed: %v", err)
+	}
+	if err := source.SetAppLock("", "2468"); err != nil {
+		t.Fatalf("SetAppLock failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "settings.json")
+	bundle, err := source.ExportSettings(path)
+	if err != nil {
+		t.Fatalf("ExportSettings failed: %v", err)
+	}
+	if bundle.RetentionPolicy != nil {
+		t.Errorf("Expected a retention policy never saved to be left out, got %+v", bundle.RetentionPolicy)
+	}
+
+	result, err := target.ImportSettings(path)
+	if err != nil {
+		t.Fatalf("ImportSettings failed: %v", err)
+	}
+	want := models.SettingsImportResult{ExportFormat: true, WeekStart: true, IgnoreRules: 1, Aliases: 1, CommissionRules: 1, PayoutSchedules: 1}
+	if *result != want {
+		t.Errorf("Expected %+v, got %+v", want, *result)
+	}
+	if saved, _ := target.GetExportFormat(); saved != format {
+		t.Errorf("Expected export format %+v, got %+v", format, saved)
+	}
+	if weekStart, _ := target.GetWeekStart(); weekStart != models.WeekStartSunday {
+		t.Errorf("Expected weeks to start on Sunday, got %q", weekStart)
+	}
+	if rules, _ := target.GetIgnoreRules(); len(rules) != 1 || rules[0].FeeCategory != models.FeeCategoryListing {
+		t.Errorf("Expected the ignore rule to be imported, got %+v", rules)
+	}
+	if rules, _ := target.dbService.ListCommissionRules(nil); len(rules) != 1 || rules[0].Rate != 0.3 || rules[0].EffectiveFrom.String() != "2024-01-01" {
+		t.Errorf("Expected the commission rule to be imported, got %+v", rules)
+	}
+	if status, _ := target.GetAppLockStatus(); status.Enabled {
+		t.Error("Expected the app lock not to be imported")
+	}
+
+	// Importing again replaces rather than duplicates
+	if _, err := target.ImportSettings(path); err != nil {
+		t.Fatalf("ImportSettings failed: %v", err)
+	}
+	if aliases, _ := target.ListNameAliases(""); len(aliases) != 1 {
+		t.Errorf("Expected 1 alias after importing twice, got %+v", aliases)
+	}
+
+	// An invalid entry saves nothing
+	bundle.Aliases = append(bundle.Aliases, models.SaveNameAliasRequest{Kind: models.AliasKindVendor, Alias: "Jane", Canonical: "Jane Doe"})
+	bundle.PayoutSchedules[0].PayoutDay = 40
+	encoded, _ := json.Marshal(bundle)
+	if err := os.WriteFile(path, encoded, 0o644); err != nil {
+		t.Fatalf("Failed to write settings file: %v", err)
+	}
+	if _, err := target.ImportSettings(path); newAppError(err).Code != ErrCodeValidation {
+		t.Errorf("Expected a validation error, got %v", err)
+	}
+	if aliases, _ := target.ListNameAliases(models.AliasKindVendor); len(aliases) != 0 {
+		t.Errorf("Expected nothing saved from an invalid file, got %+v", aliases)
+	}
+
+	if err := os.WriteFile(path, []byte("<html></html>"), 0o644); err != nil {
+		t.Fatalf("Failed to write settings file: %v", err)
+	}
+	if _, err := target.ImportSettings(path); newAppError(err).Code != ErrCodeValidation {
+		t.Errorf("Expected a validation error for a file that is not settings, got %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"version": 99}`), 0o644); err != nil {
+		t.Fatalf("Failed to write settings file: %v", err)
+	}
+	if _, err := target.ImportSettings(path); newAppError(err).Code != ErrCodeValidation {
+		t.Errorf("Expected a validation error for a newer settings file, got %v", err)
+	}
+}
+
+func TestApp_ErrorCodes(t *testing.T) {
+	if _, err := NewApp().GetImportHistory(10); newAppError(err).Code != ErrCodeNotInitialized {
+		t.Errorf("Expected %s before the database opens, got %v", ErrCodeNotInitialized, err)
+	}
+
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	tests := []struct {
+		name      string
+		call      func() error
+		code      string
+		retryable bool
+	}{
+		{"validation", func() error {
+			_, err := app.SaveExchangeRate(models.CreateExchangeRateRequest{Currency: "usd", Date: "2024-01-02", Rate: -1})
+			return err
+		}, ErrCodeValidation, false},
+		{"not found", func() error { return app.DeleteExchangeRate(999) }, ErrCodeNotFound, false},
+		{"unknown layout", func() error {
+			_, err := app.ImportHTMLDataWithOptions(testHTMLData, ImportOptions{Layout: "nope"})
+			return err
+		}, ErrCodeValidation, false},
+		{"missing file", func() error {
+			_, err := app.ImportHTMLFile(filepath.Join(t.TempDir(), "missing.html"), ImportOptions{})
+			return err
+		}, ErrCodeFile, false},
+		{"maintenance", func() error {
+			return fmt.Errorf("failed to save: %w", database.ErrMaintenanceInProgress)
+		}, ErrCodeMaintenance, true},
+		{"write queue busy", func() error {
+			return fmt.Errorf("failed to save: %w", database.ErrWriteBusy)
+		}, ErrCodeBusy, true},
+		{"query timeout", func() error {
+			return fmt.Errorf("failed to get pivot report: %w", &database.QueryTimeoutError{Class: "read", Timeout: 30 * time.Second})
+		}, ErrCodeTimeout, false},
+		{"closed month", func() error {
+			if _, err := app.ClosePeriod(models.ClosePeriodRequest{Month: "2023-12"}); err != nil {
+				return err
+			}
+			_, err := app.CreateFee(models.CreateFeeRequest{Store: "Store A", Date: "2023-12-31", Description: "Booth rent", Amount: 25})
+			return err
+		}, ErrCodePeriodClosed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envelope, ok := formatBindingError(tt.call()).(*AppError)
+			if !ok || envelope == nil {
+				t.Fatalf("Expected an error envelope, got %v", envelope)
+			}
+			if envelope.Code != tt.code || envelope.Retryable != tt.retryable || envelope.Message == "" {
+				t.Errorf("Expected code %s (retryable %v), got %+v", tt.code, tt.retryable, envelope)
+			}
+		})
+	}
+}
+
+// Benchmark tests
+func BenchmarkApp_ImportHTMLData(b *testing.B) {
+	// Create temporary database
+	tempDir := b.TempDir()
+	dbPath := filepath.Join(tempDir, "bench.db")
+	
+	app := NewApp()
+	config := database.Config{
+		FilePath:    dbPath,
+		InMemory:    false,
+		AutoMigrate: true,
+	}
+	
+	dbService, err := database.NewService(config)
+	if err != nil {
+		b.Fatalf("Failed to create benchmark database service: %v", err)
+	}
+	defer dbService.Close()
+	
+	app.dbService = dbService
+	app.ctx = context.Background()
+
+	b.ResetTimer()
+	
+	for i := 0; i < b.N; i++ {
+		_, err := app.ImportHTMLData(testHTMLData)
 		if err != nil {
-			b.Fatalf("ImportHTMLDataBatch failed: %v", err)
+			b.Fatalf("ImportHTMLData failed: %v", err)
+		}
+	}
+}
+
+// BenchmarkApp_ImportHTMLDataBatch measures batch imports of increasing size.
+// Compare the 2000-row case with BenchmarkApp_ImportHTMLDataLarge/row_by_row to
+// see the effect of chunked multi-row inserts.
+func BenchmarkApp_ImportHTMLDataBatch(b *testing.B) {
+	for _, size := range []int{2, 500, 2000} {
+		htmlData := testHTMLData
+		if size != 2 {
+			htmlData = generateHTMLTable(size)
+		}
+
+		b.Run(fmt.Sprintf("%d_rows", size), func(b *testing.B) {
+			app := setupBenchmarkApp(b)
+			defer app.dbService.Close()
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				result, err := app.ImportHTMLDataBatch(htmlData)
+				if err != nil {
+					b.Fatalf("ImportHTMLDataBatch failed: %v", err)
+				}
+				if result.ImportedRows != size {
+					b.Fatalf("Expected %d imported rows, got %d (%s)", size, result.ImportedRows, result.ErrorMessage)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkApp_ImportHTMLDataLarge compares row-by-row and batch imports of a
+// 2000-row table
+func BenchmarkApp_ImportHTMLDataLarge(b *testing.B) {
+	htmlData := generateHTMLTable(2000)
+
+	b.Run("row_by_row", func(b *testing.B) {
+		app := setupBenchmarkApp(b)
+		defer app.dbService.Close()
+
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			if _, err := app.ImportHTMLData(htmlData); err != nil {
+				b.Fatalf("ImportHTMLData failed: %v", err)
+			}
+		}
+	})
+
+	b.Run("batch", func(b *testing.B) {
+		app := setupBenchmarkApp(b)
+		defer app.dbService.Close()
+
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			if _, err := app.ImportHTMLDataBatch(htmlData); err != nil {
+				b.Fatalf("ImportHTMLDataBatch failed: %v", err)
+			}
+		}
+	})
+}
+
+// setupBenchmarkApp creates an app backed by a file database in a temp directory
+func setupBenchmarkApp(b *testing.B) *App {
+	app := NewApp()
+	config := database.Config{
+		FilePath:    filepath.Join(b.TempDir(), "bench_batch.db"),
+		InMemory:    false,
+		AutoMigrate: true,
+	}
+
+	dbService, err := database.NewService(config)
+	if err != nil {
+		b.Fatalf("Failed to create benchmark database service: %v", err)
+	}
+
+	app.dbService = dbService
+	app.ctx = context.Background()
+	return app
+}
+
+// generateHTMLTable builds an HTML sales table with the given number of rows
+func generateHTMLTable(rows int) string {
+	var sb strings.Builder
+	sb.WriteString("<table>\n<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th><th>Commission</th><th>Remaining</th></tr>\n")
+	for i := 0; i < rows; i++ {
+		price := float64(10 + i%90)
+		fmt.Fprintf(&sb, "<tr><td>Store %d</td><td>Vendor %d</td><td>2024-%02d-%02d</td><td>Product %d</td><td>%.2f</td><td>%.2f</td><td>%.2f</td></tr>\n",
+			i%5, i%20, 1+i%12, 1+i%28, i, price, price*0.1, price*0.9)
+	}
+	sb.WriteString("</table>")
+	return sb.String()
+}
+
+// apiTypesFile holds the TypeScript interfaces for the values App's methods
+// exchange with the frontend
+const apiTypesFile = "frontend/src/types/api.ts"
+
+var updateAPITypes = flag.Bool("update-api-types", false, "rewrite "+apiTypesFile)
+
+// apiTypes generates the contents of apiTypesFile from the parameters and
+// results of App's exported methods
+func apiTypes() string {
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+
+	var types []reflect.Type
+	app := reflect.TypeOf(&App{})
+	for i := 0; i < app.NumMethod(); i++ {
+		method := app.Method(i).Type
+		for j := 1; j < method.NumIn(); j++ { // Skip the receiver
+			types = append(types, method.In(j))
+		}
+		for j := 0; j < method.NumOut(); j++ {
+			if method.Out(j) != errorType {
+				types = append(types, method.Out(j))
+			}
+		}
+	}
+
+	return fmt.Sprintf(`// Code generated by "go test -run TestAPITypes -update-api-types"; DO NOT EDIT.
+
+// SCHEMA_VERSION is the schema_version of the parse and import results these
+// types describe
+export const SCHEMA_VERSION = %d;
+
+%s`, parser.SchemaVersion, tsgen.Generate(types...))
+}
+
+// TestAPITypes fails when the Go types the frontend receives no longer match
+// its TypeScript interfaces
+func TestAPITypes(t *testing.T) {
+	generated := apiTypes()
+	if *updateAPITypes {
+		if err := os.MkdirAll(filepath.Dir(apiTypesFile), 0755); err != nil {
+			t.Fatalf("Failed to create %s: %v", filepath.Dir(apiTypesFile), err)
+		}
+		if err := os.WriteFile(apiTypesFile, []byte(generated), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", apiTypesFile, err)
+		}
+	}
+
+	current, err := os.ReadFile(apiTypesFile)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Failed to read %s: %v", apiTypesFile, err)
+	}
+	if string(current) != generated {
+		t.Fatalf("%s is out of date; run go test -run TestAPITypes -update-api-types", apiTypesFile)
+	}
+}
+
+func TestImportResultSchemaVersion(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	result, err := app.ImportHTMLData(testHTMLData)
+	if err != nil {
+		t.Fatalf("ImportHTMLData failed: %v", err)
+	}
+	if result.SchemaVersion != parser.SchemaVersion {
+		t.Errorf("Expected schema version %d, got %d", parser.SchemaVersion, result.SchemaVersion)
+	}
+}
+
+func TestApp_ExportSalesRecords(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	var progress []ExportProgress
+	app.emit = func(name string, data ...interface{}) {
+		if name == exportProgressEvent {
+			progress = append(progress, data[0].(ExportProgress))
+		}
+	}
+
+	records := make([]models.CreateSalesRecordRequest, exportChunkSize+1)
+	for i := range records {
+		records[i] = models.CreateSalesRecordRequest{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: fmt.Sprintf("Item %d", i), SalePrice: 10}
+	}
+	if _, err := app.dbService.CreateSalesRecordsBatch(records); err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "records.jsonl")
+	count, err := app.ExportSalesRecords(models.SalesRecordFilter{}, path, "jsonl")
+	if err != nil {
+		t.Fatalf("ExportSalesRecords failed: %v", err)
+	}
+	if count != len(records) {
+		t.Errorf("Expected %d records exported, got %d", len(records), count)
+	}
+	want := []ExportProgress{{RecordsWritten: exportChunkSize, Total: int64(len(records))}, {RecordsWritten: len(records), Total: int64(len(records))}}
+	if !reflect.DeepEqual(progress, want) {
+		t.Errorf("Expected progress %+v, got %+v", want, progress)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	var record models.SalesRecord
+	if len(lines) != len(records) || json.Unmarshal([]byte(lines[0]), &record) != nil || record.Store != "Store A" {
+		t.Errorf("Expected %d JSON records, got %d lines starting %.80s", len(records), len(lines), lines[0])
+	}
+
+	unknown := filepath.Join(t.TempDir(), "records.xlsx")
+	_, err = app.ExportSalesRecords(models.SalesRecordFilter{}, unknown, "xlsx")
+	var appErr *AppError
+	if !errors.As(err, &appErr) || appErr.Code != ErrCodeValidation {
+		t.Errorf("Expected a validation error for an unknown format, got %v", err)
+	}
+	if _, err := os.Stat(unknown); !os.IsNotExist(err) {
+		t.Error("Expected no file for an unknown format")
+	}
+
+	// Export takes any registered format
+	formats := app.ListExportFormats()
+	if len(formats) < 2 || formats[0] != (ExportFormatInfo{Name: "csv", ContentType: "text/csv"}) {
+		t.Errorf("Expected the built-in formats, got %+v", formats)
+	}
+	store := "Store A"
+	csvPath := filepath.Join(t.TempDir(), "records.csv")
+	if count, err := app.Export(csvPath, "csv", ExportOptions{Filter: models.SalesRecordFilter{Store: &store}}); err != nil || count != len(records) {
+		t.Errorf("Expected %d records exported as CSV, got %d, %v", len(records), count, err)
+	}
+}
+
+// failingExporter writes the start of a file and then fails, like an export
+// stopped by a full disk
+type failingExporter struct{}
+
+func (failingExporter) Name() string        { return "test-failing" }
+func (failingExporter) ContentType() string { return "text/plain" }
+
+func (failingExporter) Write(w io.Writer, records export.RecordSource, format models.ExportFormat) (int, error) {
+	if _, err := io.WriteString(w, "partial"); err != nil {
+		return 0, err
+	}
+	return 0, errors.New("disk full")
+}
+
+func TestApp_ExportFailureKeepsFile(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	if _, ok := export.LookupExporter(failingExporter{}.Name()); !ok {
+		if err := export.RegisterExporter(failingExporter{}); err != nil {
+			t.Fatalf("RegisterExporter failed: %v", err)
+		}
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "records.txt")
+	if _, err := app.Export(path, "test-failing", ExportOptions{}); err == nil {
+		t.Fatal("Expected the export to fail")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected no partial export file, got %v", err)
+	}
+
+	// An earlier export at the path is kept
+	if err := os.WriteFile(path, []byte("earlier"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := app.Export(path, "test-failing", ExportOptions{}); err == nil {
+		t.Fatal("Expected the export to fail")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "earlier" {
+		t.Errorf("Expected the earlier export kept, got %q, %v", data, err)
+	}
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Errorf("Expected no temporary files left, got %v, %v", entries, err)
+	}
+
+	if count, err := app.Export(path, "jsonl", ExportOptions{}); err != nil || count != 0 {
+		t.Fatalf("Expected an empty export to replace the file, got %d, %v", count, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != 0 || info.Mode().Perm()&0o044 == 0 {
+		t.Errorf("Expected an empty readable export file, got %v, %v", info, err)
+	}
+}
+
+func TestApp_Workspace(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "workspace.stws")
+	if _, err := app.ImportHTMLData(testHTMLData); err != nil {
+		t.Fatalf("ImportHTMLData failed: %v", err)
+	}
+	manifest, err := app.ExportWorkspace(archive, "correct horse")
+	if err != nil || manifest.Records == 0 {
+		t.Fatalf("Expected the records archived, got %+v, %v", manifest, err)
+	}
+
+	// The import is staged for the next start rather than replacing the open database
+	app.dbPath = filepath.Join(dir, "sales_track.db")
+	if _, err := app.ImportWorkspace(archive, "wrong passphrase"); err == nil {
+		t.Error("Expected a wrong passphrase to fail")
+	}
+	staged, err := app.ImportWorkspace(archive, "correct horse")
+	if err != nil || staged.Records != manifest.Records {
+		t.Fatalf("Expected the workspace staged, got %+v, %v", staged, err)
+	}
+	if _, err := os.Stat(app.dbPath + database.StagedWorkspaceSuffix); err != nil {
+		t.Errorf("Expected a staged workspace beside the database, got %v", err)
+	}
+}
+
+func TestApp_Notifications(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	for _, title := range []string{"Backup finished", "Compaction finished"} {
+		if _, err := app.dbService.Notify(models.Notification{Source: "test", Level: models.NotificationInfo, Title: title}); err != nil {
+			t.Fatalf("Notify failed: %v", err)
+		}
+	}
+
+	list, err := app.ListNotifications(models.NotificationFilter{})
+	if err != nil || len(list.Notifications) != 2 || list.Unread != 2 {
+		t.Fatalf("Expected 2 unread notifications, got %+v, %v", list, err)
+	}
+	if err := app.MarkNotificationRead(list.Notifications[0].ID); err != nil {
+		t.Fatalf("MarkNotificationRead failed: %v", err)
+	}
+	if marked, err := app.MarkAllNotificationsRead(); err != nil || marked != 1 {
+		t.Errorf("Expected the remaining notification marked read, got %d, %v", marked, err)
+	}
+}
+
+func TestApp_APITokens(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	created, err := app.CreateAPIToken(models.CreateAPITokenRequest{Name: "Sync", Scopes: []string{models.APITokenScopeRead}})
+	if err != nil || created.Secret == "" {
+		t.Fatalf("CreateAPIToken failed: %+v, %v", created, err)
+	}
+	if _, err := app.RevokeAPIToken(created.Token.ID); err != nil {
+		t.Fatalf("RevokeAPIToken failed: %v", err)
+	}
+	tokens, err := app.ListAPITokens()
+	if err != nil || len(tokens) != 1 || tokens[0].RevokedAt == nil {
+		t.Errorf("Expected the revoked token listed, got %+v, %v", tokens, err)
+	}
+}
+
+func TestApp_CSVMappingWizard(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	data := "Shop,Maker,When,What,Paid\nHarbor Gifts,Maple Lane Pottery,2024-03-15,Stoneware Bowl,$64.00\n"
+	inspection, err := app.InspectCSVColumns(data)
+	if err != nil || len(inspection.Columns) != 5 {
+		t.Fatalf("Expected 5 columns, got %+v, %v", inspection, err)
+	}
+	if _, err := app.InspectCSVColumns(""); err == nil {
+		t.Error("Expected empty data to be refused")
+	}
+
+	mapping := models.CSVMapping{Name: "Harbor Gifts", Delimiter: inspection.Delimiter, Columns: map[string]string{
+		"Shop": "store", "Maker": "vendor", "When": "date", "What": "description", "Paid": "sale_price",
+	}}
+	validation, err := app.ValidateCSVMapping(data, mapping)
+	if err != nil || !validation.Valid || validation.ValidRows != 1 {
+		t.Fatalf("Expected the mapped file valid, got %+v, %v", validation, err)
+	}
+	result, err := app.ImportHTMLDataWithOptions(data, ImportOptions{Mapping: &mapping})
+	if err != nil || result.ImportedRows != 1 {
+		t.Fatalf("Expected the mapped file imported, got %+v, %v", result, err)
+	}
+
+	if _, err := app.SaveMappingProfile(models.CSVMapping{Name: "Broken", Delimiter: ",", Columns: map[string]string{"Paid": "price"}}); err == nil {
+		t.Error("Expected a profile with an unknown field to be refused")
+	}
+	if _, err := app.SaveMappingProfile(mapping); err != nil {
+		t.Fatalf("SaveMappingProfile failed: %v", err)
+	}
+	profiles, err := app.ListMappingProfiles()
+	if err != nil || len(profiles) != 1 || profiles[0].Columns["Paid"] != "sale_price" {
+		t.Errorf("Expected the saved profile, got %+v, %v", profiles, err)
+	}
+	if err := app.DeleteMappingProfile("harbor gifts"); err != nil {
+		t.Errorf("DeleteMappingProfile failed: %v", err)
+	}
+}
+
+func TestApp_DuplicateImportContent(t *testing.T) {
+	app := setupTestApp(t)
+	defer app.dbService.Close()
+
+	htmlData := `<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-03-15</td><td>Lamp</td><td>$40.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-03-16</td><td>Chair</td><td>$75.00</td></tr>
+	</table>`
+
+	first, err := app.ImportHTMLData(htmlData)
+	if err != nil || first.ImportedRows != 2 || first.DuplicateOf != nil {
+		t.Fatalf("Expected the first import without a duplicate, got %+v, %v", first, err)
+	}
+	runs, err := app.dbService.ListImportRuns(0)
+	if err != nil || len(runs) != 1 || runs[0].ContentHash == nil {
+		t.Fatalf("Expected the first import recorded with its content hash, got %+v, %v", runs, err)
+	}
+	firstRun := runs[0].ID
+
+	// By default the same content is imported again with a warning
+	warned, err := app.ImportHTMLDataWithOptions(htmlData, ImportOptions{})
+	if err != nil || warned.ImportedRows != 2 || warned.DuplicateOf == nil || warned.DuplicateOf.ID != firstRun {
+		t.Fatalf("Expected the import warned of the earlier import %d, got %+v, %v", firstRun, warned, err)
+	}
+
+	blocked := ImportOptions{DuplicateContent: DuplicateContentBlock}
+	for _, useBatch := range []bool{false, true} {
+		blocked.UseBatchImport = useBatch
+		result, err := app.ImportHTMLDataWithOptions(htmlData, blocked)
+		if err != nil || result.ImportedRows != 0 || result.Error == nil || result.Error.Code != ErrCodeDuplicateImport {
+			t.Fatalf("Expected the import blocked (batch=%v), got %+v, %v", useBatch, result, err)
 		}
+		if result.DuplicateOf == nil || result.Error.Details["import_run_id"] == nil {
+			t.Errorf("Expected the blocked import to name the earlier import, got %+v", result)
+		}
+	}
+
+	// Streamed records are rolled back once the parse shows the content was imported
+	streamed, err := app.ImportHTMLDataStream(htmlData, ImportOptions{DuplicateContent: DuplicateContentBlock})
+	if err != nil || streamed.ImportedRows != 0 || streamed.Error == nil || streamed.Error.Code != ErrCodeDuplicateImport {
+		t.Fatalf("Expected the streamed import blocked, got %+v, %v", streamed, err)
+	}
+	dryRun, err := app.ImportHTMLDataWithOptions(htmlData, ImportOptions{DuplicateContent: DuplicateContentBlock, DryRun: true})
+	if err != nil || !dryRun.DryRun || dryRun.Error == nil || dryRun.Error.Code != ErrCodeDuplicateImport {
+		t.Errorf("Expected the dry run to report the import blocked, got %+v, %v", dryRun, err)
+	}
+
+	stats, err := app.dbService.GetDatabaseStats()
+	if err != nil {
+		t.Fatalf("GetDatabaseStats failed: %v", err)
+	}
+	if stats.TotalRecords != 4 {
+		t.Errorf("Expected only the first two imports stored, got %d records", stats.TotalRecords)
+	}
+
+	atomic := false
+	_, err = app.ImportHTMLDataStream(htmlData, ImportOptions{DuplicateContent: DuplicateContentBlock, Atomic: &atomic})
+	if appErr := newAppError(err); appErr == nil || appErr.Code != ErrCodeValidation || appErr.Details["field"] != "duplicate_content" {
+		t.Errorf("Expected blocking to require an atomic stream, got %v", err)
+	}
+	_, err = app.ImportHTMLDataWithOptions(htmlData, ImportOptions{DuplicateContent: "skip"})
+	if appErr := newAppError(err); appErr == nil || appErr.Code != ErrCodeValidation || appErr.Details["field"] != "duplicate_content" {
+		t.Errorf("Expected a validation error for unknown duplicate handling, got %v", err)
+	}
+}
+
+func TestApp_ReportPerformanceConfig(t *testing.T) {
+	app := setupTestApp(t)
+
+	status, err := app.GetReportPerformanceStatus()
+	if err != nil {
+		t.Fatalf("GetReportPerformanceStatus failed: %v", err)
+	}
+	if status.Active || status.Config != models.DefaultReportPerformanceConfig() {
+		t.Errorf("Expected the default settings with performance mode off, got %+v", status)
+	}
+
+	config := models.ReportPerformanceConfig{Enabled: false, Threshold: 50000, SampleSize: 5000}
+	if err := app.SaveReportPerformanceConfig(config); err != nil {
+		t.Fatalf("SaveReportPerformanceConfig failed: %v", err)
+	}
+	if status, _ := app.GetReportPerformanceStatus(); status.Config != config {
+		t.Errorf("Expected the saved settings %+v, got %+v", config, status.Config)
+	}
+
+	err = app.SaveReportPerformanceConfig(models.ReportPerformanceConfig{Enabled: true, Threshold: 50000, SampleSize: 10})
+	if appErr := newAppError(err); appErr == nil || appErr.Code != ErrCodeValidation {
+		t.Errorf("Expected a validation error for a small sample size, got %v", err)
+	}
+}
+
+func TestApp_MemoryBudgetChunkedImport(t *testing.T) {
+	app := setupTestApp(t)
+
+	table := `<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-03-15</td><td>Lamp</td><td>40.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 2</td><td>2024-03-16</td><td>Chair</td><td>75.00</td></tr>
+	</table>`
+
+	result, err := app.ImportHTMLDataWithOptions(table, ImportOptions{})
+	if err != nil || result.ImportedRows != 2 || result.Chunked {
+		t.Fatalf("Expected a small paste parsed whole, got %+v, %v", result, err)
+	}
+
+	if err := app.SaveMemoryBudget(models.MemoryBudget{LimitMB: models.MinMemoryBudgetMB}); err != nil {
+		t.Fatalf("SaveMemoryBudget failed: %v", err)
+	}
+	if budget, _ := app.GetMemoryBudget(); budget.LimitMB != models.MinMemoryBudgetMB {
+		t.Errorf("Expected the saved budget, got %+v", budget)
+	}
+
+	// A page too large to parse whole within the budget is streamed
+	padding := "<!--" + strings.Repeat(" ", int(models.MemoryBudget{LimitMB: models.MinMemoryBudgetMB}.Bytes()/parser.EstimateParseMemory(1))) + "-->"
+	large := padding + strings.Replace(strings.Replace(table, "2024-03", "2024-04", -1), "Store A", "Store B", -1)
+	result, err = app.ImportHTMLDataWithOptions(large, ImportOptions{})
+	if err != nil || !result.Chunked || result.ImportedRows != 2 || !result.Success {
+		t.Fatalf("Expected a large paste imported in chunks, got %+v, %v", result, err)
+	}
+
+	// Headerless rows read by position still need the whole page
+	result, err = app.ImportHTMLDataWithOptions(padding+"Store C\tVendor 1\t2024-05-01\tVase\t15.00", ImportOptions{UseConsignableFormat: true})
+	if err != nil || result.Chunked {
+		t.Errorf("Expected headerless rows parsed whole, got %+v, %v", result, err)
+	}
+
+	err = app.SaveMemoryBudget(models.MemoryBudget{LimitMB: 1})
+	if appErr := newAppError(err); appErr == nil || appErr.Code != ErrCodeValidation {
+		t.Errorf("Expected a validation error for a budget below the minimum, got %v", err)
+	}
+}
+
+func TestApp_QueryTimeouts(t *testing.T) {
+	app := setupTestApp(t)
+
+	if timeouts, err := app.GetQueryTimeouts(); err != nil || timeouts != models.DefaultQueryTimeouts() {
+		t.Errorf("Expected the default timeouts, got %+v, %v", timeouts, err)
+	}
+
+	timeouts := models.QueryTimeouts{Read: models.Duration(time.Minute), Write: models.Duration(10 * time.Minute)}
+	if err := app.SaveQueryTimeouts(timeouts); err != nil {
+		t.Fatalf("SaveQueryTimeouts failed: %v", err)
+	}
+	if saved, _ := app.GetQueryTimeouts(); saved != timeouts {
+		t.Errorf("Expected the saved timeouts, got %+v", saved)
+	}
+
+	err := app.SaveQueryTimeouts(models.QueryTimeouts{Write: models.Duration(time.Millisecond)})
+	if appErr := newAppError(err); appErr == nil || appErr.Code != ErrCodeValidation {
+		t.Errorf("Expected a validation error for a timeout below the minimum, got %v", err)
 	}
 }
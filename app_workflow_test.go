@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/csv"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sales-track/internal/models"
+)
+
+// The scenarios below drive the App bindings in the order the frontend calls
+// them, each against a fresh database, so a change in one module that breaks
+// another shows up even when each module's own tests pass.
+
+// workflow is the state a scenario's steps share: the app and what earlier
+// steps created
+type workflow struct {
+	app         *App
+	dir         string
+	imported    []models.SalesRecord
+	adjustments []int64
+	fees        []int64
+}
+
+// workflowStep is one action of a user, with the checks that follow it. Steps
+// run in order and a failed step ends its scenario, since later steps build on
+// it.
+type workflowStep struct {
+	name string
+	run  func(t *testing.T, w *workflow)
+}
+
+func TestApp_Workflows(t *testing.T) {
+	scenarios := []struct {
+		name  string
+		steps []workflowStep
+	}{
+		{"import, report, adjust, undo and export", []workflowStep{
+			importReport(testHTMLData, ImportOptions{UseBatchImport: true}, 2),
+			expectMonthlySales("2024-01", 300, false),
+			adjustSale(0, -25),
+			expectMonthlySales("2024-01", 275, true),
+			expectMonthlySales("2024-01", 300, false),
+			undoAdjustment(),
+			expectMonthlySales("2024-01", 300, true),
+			exportCSV(2, "Test Store", "Another Store"),
+		}},
+		{"a dry run leaves nothing behind", []workflowStep{
+			importReport(testHTMLData, ImportOptions{DryRun: true}, 2),
+			expectMonthlySales("2024-01", 0, false),
+			exportCSV(0),
+		}},
+		{"a report imported twice", []workflowStep{
+			importReport(testHTMLData, ImportOptions{}, 2),
+			expectImportError(testHTMLData, ImportOptions{DuplicateContent: DuplicateContentBlock}, ErrCodeDuplicateImport),
+			expectMonthlySales("2024-01", 300, false),
+			expectDuplicateWarning(testHTMLData),
+			expectMonthlySales("2024-01", 600, false),
+			exportCSV(4),
+		}},
+		{"fees lower net income until deleted", []workflowStep{
+			importReport(testHTMLData, ImportOptions{}, 2),
+			addFee("Test Store", "2024-01-31", 40),
+			expectNetIncome("2024-01", 270-40),
+			undoFee(),
+			expectNetIncome("2024-01", 270),
+		}},
+		{"a closed month refuses changes until reopened", []workflowStep{
+			importReport(testHTMLData, ImportOptions{}, 2),
+			{"close January", func(t *testing.T, w *workflow) {
+				if _, err := w.app.ClosePeriod(models.ClosePeriodRequest{Month: "2024-01"}); err != nil {
+					t.Fatalf("ClosePeriod failed: %v", err)
+				}
+			}},
+			{"fee in January refused", func(t *testing.T, w *workflow) {
+				_, err := w.app.CreateFee(models.CreateFeeRequest{Store: "Test Store", Date: "2024-01-31", Description: "Booth rent", Amount: 40})
+				if appErr := newAppError(err); appErr == nil || appErr.Code != ErrCodePeriodClosed {
+					t.Errorf("Expected %s, got %v", ErrCodePeriodClosed, err)
+				}
+			}},
+			{"reopen January", func(t *testing.T, w *workflow) {
+				if _, err := w.app.ReopenPeriod(models.ReopenPeriodRequest{Month: "2024-01", Reason: "Late booth rent"}); err != nil {
+					t.Fatalf("ReopenPeriod failed: %v", err)
+				}
+			}},
+			addFee("Test Store", "2024-01-31", 40),
+			expectNetIncome("2024-01", 270-40),
+			expectAudited(models.AuditPeriodClosed, models.AuditPeriodReopened),
+		}},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			app := setupTestApp(t)
+			defer app.dbService.Close()
+
+			w := &workflow{app: app, dir: t.TempDir()}
+			for _, step := range scenario.steps {
+				if !t.Run(step.name, func(t *testing.T) { step.run(t, w) }) {
+					t.FailNow()
+				}
+			}
+		})
+	}
+}
+
+// importReport imports data, expecting imported records
+func importReport(data string, options ImportOptions, imported int) workflowStep {
+	return workflowStep{"import", func(t *testing.T, w *workflow) {
+		result, err := w.app.ImportHTMLDataWithOptions(data, options)
+		if err != nil || !result.Success || result.ImportedRows != imported {
+			t.Fatalf("Expected %d rows imported, got %+v, %v", imported, result, err)
+		}
+		if !options.DryRun {
+			w.imported = append(w.imported, result.ImportedRecords...)
+		}
+	}}
+}
+
+// expectImportError imports data, expecting a result reporting code and no
+// records imported
+func expectImportError(data string, options ImportOptions, code string) workflowStep {
+	return workflowStep{"import refused", func(t *testing.T, w *workflow) {
+		result, err := w.app.ImportHTMLDataWithOptions(data, options)
+		if err != nil || result.Success || result.Error == nil || result.Error.Code != code || result.ImportedRows != 0 {
+			t.Fatalf("Expected %s, got %+v, %v", code, result, err)
+		}
+	}}
+}
+
+// expectDuplicateWarning imports data already imported, expecting it
+// imported again with the earlier import named
+func expectDuplicateWarning(data string) workflowStep {
+	return workflowStep{"import again", func(t *testing.T, w *workflow) {
+		result, err := w.app.ImportHTMLDataWithOptions(data, ImportOptions{})
+		if err != nil || !result.Success || result.DuplicateOf == nil {
+			t.Fatalf("Expected the import to succeed naming the earlier one, got %+v, %v", result, err)
+		}
+		w.imported = append(w.imported, result.ImportedRecords...)
+	}}
+}
+
+// expectMonthlySales checks the sales the monthly summary reports for a
+// month, with or without adjustments folded in
+func expectMonthlySales(yearMonth string, total float64, withAdjustments bool) workflowStep {
+	return workflowStep{"monthly summary", func(t *testing.T, w *workflow) {
+		summaries, err := w.app.GetMonthlySummaryByDimension(nil, "", models.ReportOptions{IncludeAdjustments: withAdjustments})
+		if err != nil {
+			t.Fatalf("GetMonthlySummaryByDimension failed: %v", err)
+		}
+		got := 0.0
+		for _, summary := range summaries {
+			if summary.YearMonth == yearMonth {
+				got += summary.TotalSales
+			}
+		}
+		if math.Abs(got-total) > 0.005 {
+			t.Errorf("Expected sales of %.2f in %s (adjustments %v), got %.2f", total, yearMonth, withAdjustments, got)
+		}
+	}}
+}
+
+// adjustSale adjusts the sale price of an imported record in the month of its
+// sale
+func adjustSale(index int, delta float64) workflowStep {
+	return workflowStep{"adjust", func(t *testing.T, w *workflow) {
+		record := w.imported[index]
+		adjustment, err := w.app.CreateAdjustment(models.CreateSalesAdjustmentRequest{
+			SalesRecordID:  record.ID,
+			Date:           record.Date.Format("2006-01-02"),
+			Reason:         "Partial refund",
+			SalePriceDelta: delta,
+		})
+		if err != nil {
+			t.Fatalf("CreateAdjustment failed: %v", err)
+		}
+		w.adjustments = append(w.adjustments, adjustment.ID)
+	}}
+}
+
+// undoAdjustment deletes the last adjustment made
+func undoAdjustment() workflowStep {
+	return workflowStep{"undo adjustment", func(t *testing.T, w *workflow) {
+		last := w.adjustments[len(w.adjustments)-1]
+		if err := w.app.DeleteAdjustment(last); err != nil {
+			t.Fatalf("DeleteAdjustment failed: %v", err)
+		}
+		w.adjustments = w.adjustments[:len(w.adjustments)-1]
+	}}
+}
+
+// addFee records a fee charged to a store
+func addFee(store, date string, amount float64) workflowStep {
+	return workflowStep{"add fee", func(t *testing.T, w *workflow) {
+		fee, err := w.app.CreateFee(models.CreateFeeRequest{Store: store, Date: date, Description: "Booth rent", Amount: amount})
+		if err != nil {
+			t.Fatalf("CreateFee failed: %v", err)
+		}
+		w.fees = append(w.fees, fee.ID)
+	}}
+}
+
+// undoFee deletes the last fee recorded
+func undoFee() workflowStep {
+	return workflowStep{"undo fee", func(t *testing.T, w *workflow) {
+		last := w.fees[len(w.fees)-1]
+		if err := w.app.DeleteFee(last); err != nil {
+			t.Fatalf("DeleteFee failed: %v", err)
+		}
+		w.fees = w.fees[:len(w.fees)-1]
+	}}
+}
+
+// expectNetIncome checks the net income of a month across stores
+func expectNetIncome(month string, net float64) workflowStep {
+	return workflowStep{"net income", func(t *testing.T, w *workflow) {
+		months, err := w.app.GetNetIncome(nil, nil)
+		if err != nil {
+			t.Fatalf("GetNetIncome failed: %v", err)
+		}
+		for _, m := range months {
+			if m.Month == month {
+				if math.Abs(m.NetIncome-net) > 0.005 {
+					t.Errorf("Expected net income of %.2f in %s, got %+v", net, month, m)
+				}
+				return
+			}
+		}
+		t.Errorf("Expected net income for %s, got %+v", month, months)
+	}}
+}
+
+// exportCSV exports every record to a CSV file, expecting rows records and
+// each of stores among their fields
+func exportCSV(rows int, stores ...string) workflowStep {
+	return workflowStep{"export", func(t *testing.T, w *workflow) {
+		path := filepath.Join(w.dir, "export.csv")
+		count, err := w.app.Export(path, "csv", ExportOptions{})
+		if err != nil || count != rows {
+			t.Fatalf("Expected %d records exported, got %d, %v", rows, count, err)
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("Failed to open export: %v", err)
+		}
+		defer file.Close()
+		lines, err := csv.NewReader(file).ReadAll()
+		if err != nil || len(lines) != rows+1 {
+			t.Fatalf("Expected a header and %d rows, got %d lines, %v", rows, len(lines), err)
+		}
+		exported := map[string]bool{}
+		for _, line := range lines[1:] {
+			for _, field := range line {
+				exported[field] = true
+			}
+		}
+		for _, store := range stores {
+			if !exported[store] {
+				t.Errorf("Expected %q in the export, got %v", store, lines)
+			}
+		}
+	}}
+}
+
+// expectAudited checks the audit log holds entries of each action
+func expectAudited(actions ...string) workflowStep {
+	return workflowStep{"audit log", func(t *testing.T, w *workflow) {
+		entries, err := w.app.GetAuditLog(100)
+		if err != nil {
+			t.Fatalf("GetAuditLog failed: %v", err)
+		}
+		logged := map[string]bool{}
+		for _, entry := range entries {
+			logged[entry.Action] = true
+		}
+		for _, action := range actions {
+			if !logged[action] {
+				t.Errorf("Expected a %s entry in the audit log, got %+v", action, entries)
+			}
+		}
+	}}
+}
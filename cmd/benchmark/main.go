@@ -0,0 +1,150 @@
+// Command benchmark measures import throughput and list/report latency against
+// a database filled with synthetic records, optionally capturing pprof profiles.
+//
+// Usage:
+//
+//	go run ./cmd/benchmark -records 1000000 -cpuprofile cpu.out -memprofile mem.out
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"time"
+
+	"sales-track/internal/database"
+	"sales-track/internal/models"
+	"sales-track/internal/synthetic"
+)
+
+func main() {
+	records := flag.Int("records", 100000, "number of synthetic records to import")
+	seed := flag.Int64("seed", 1, "random seed for synthetic data")
+	years := flag.Int("years", 5, "number of years the synthetic data spans")
+	iterations := flag.Int("iterations", 20, "number of times each query is timed")
+	dbPath := flag.String("db", "", "database file to use (default: a temporary file)")
+	cpuProfile := flag.String("cpuprofile", "", "write a CPU profile to this file")
+	memProfile := flag.String("memprofile", "", "write a heap profile to this file on exit")
+	flag.Parse()
+
+	if err := run(*records, *seed, *years, *iterations, *dbPath, *cpuProfile, *memProfile); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(records int, seed int64, years, iterations int, dbPath, cpuProfile, memProfile string) error {
+	if dbPath == "" {
+		dir, err := os.MkdirTemp("", "sales-track-bench")
+		if err != nil {
+			return fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		dbPath = filepath.Join(dir, "bench.db")
+	}
+
+	if cpuProfile != "" {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			return fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	service, err := database.NewService(database.Config{FilePath: dbPath, AutoMigrate: true})
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer service.Close()
+
+	// Import
+	generated := make(chan models.CreateSalesRecordRequest, 1000)
+	go synthetic.NewGenerator(seed, years).Stream(records, generated)
+
+	start := time.Now()
+	inserted, err := service.CreateSalesRecordsStream(generated, 0, nil)
+	if err != nil {
+		return fmt.Errorf("import failed after %d records: %w", inserted, err)
+	}
+	elapsed := time.Since(start)
+	fmt.Printf("import      %d records in %v (%.0f records/s)\n", inserted, elapsed.Round(time.Millisecond), float64(inserted)/elapsed.Seconds())
+
+	// Queries use the repositories directly so the service's stats cache does
+	// not hide the real cost
+	db := service.GetDB()
+	sales := database.NewSalesRepository(db)
+	reports := database.NewReportingRepository(db)
+
+	// Report performance mode reads sales pre-aggregated by day, store and
+	// vendor, and estimates top products from a sample
+	start = time.Now()
+	if err := reports.RebuildAggregates(); err != nil {
+		return fmt.Errorf("failed to build report aggregates: %w", err)
+	}
+	fmt.Printf("aggregate   %v\n", time.Since(start).Round(time.Millisecond))
+	aggregated := reports.Aggregated()
+	sampleSize := models.DefaultReportPerformanceConfig().SampleSize
+
+	store := "Downtown"
+	vendor := "Vendor 042"
+	offset := records / 2
+	year := "2024"
+
+	queries := []struct {
+		name string
+		fn   func() error
+	}{
+		{"list recent", func() error { _, err := sales.List(models.SalesRecordFilter{}); return err }},
+		{"list by store", func() error { _, err := sales.List(models.SalesRecordFilter{Store: &store}); return err }},
+		{"list by vendor", func() error { _, err := sales.List(models.SalesRecordFilter{Vendor: &vendor}); return err }},
+		{"list deep page", func() error { _, err := sales.List(models.SalesRecordFilter{Offset: &offset}); return err }},
+		{"stats", func() error { _, err := sales.GetStats(); return err }},
+		{"yearly summary", func() error { _, err := reports.GetYearlySummary(); return err }},
+		{"monthly summary", func() error { _, err := reports.GetMonthlySummary(&year); return err }},
+		{"store performance", func() error { _, err := reports.GetStorePerformance(); return err }},
+		{"vendor performance", func() error { _, err := reports.GetVendorPerformance(); return err }},
+		{"top products", func() error { _, err := reports.GetTopProducts(10); return err }},
+		{"yearly aggregated", func() error { _, err := aggregated.GetYearlySummary(); return err }},
+		{"store aggregated", func() error { _, err := aggregated.GetStorePerformance(); return err }},
+		{"vendor aggregated", func() error { _, err := aggregated.GetVendorPerformance(); return err }},
+		{"top sampled", func() error { _, err := reports.GetTopProductsSampled(10, sampleSize, int64(records)); return err }},
+	}
+
+	for _, q := range queries {
+		durations := make([]time.Duration, 0, iterations)
+		for i := 0; i < iterations; i++ {
+			start := time.Now()
+			if err := q.fn(); err != nil {
+				return fmt.Errorf("%s failed: %w", q.name, err)
+			}
+			durations = append(durations, time.Since(start))
+		}
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		fmt.Printf("%-18s p50 %-12v p95 %-12v max %v\n", q.name,
+			durations[len(durations)/2].Round(time.Microsecond),
+			durations[len(durations)*95/100].Round(time.Microsecond),
+			durations[len(durations)-1].Round(time.Microsecond))
+	}
+
+	if memProfile != "" {
+		f, err := os.Create(memProfile)
+		if err != nil {
+			return fmt.Errorf("failed to create heap profile: %w", err)
+		}
+		defer f.Close()
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			return fmt.Errorf("failed to write heap profile: %w", err)
+		}
+	}
+
+	return nil
+}
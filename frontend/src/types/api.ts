@@ -0,0 +1,1223 @@
+// Code generated by "go test -run TestAPITypes -update-api-types"; DO NOT EDIT.
+
+// SCHEMA_VERSION is the schema_version of the parse and import results these
+// types describe
+export const SCHEMA_VERSION = 1;
+
+export interface APIToken {
+  id: number;
+  name: string;
+  hint: string;
+  scopes: string[];
+  expires_at?: string;
+  created_at: string;
+  last_used_at?: string;
+  revoked_at?: string;
+}
+
+export interface AppError {
+  code: string;
+  message: string;
+  details?: Record<string, unknown>;
+  retryable: boolean;
+}
+
+export interface AppLockStatus {
+  enabled: boolean;
+  locked: boolean;
+  retry_after?: string;
+}
+
+export interface AuditEntry {
+  id: number;
+  created_at: string;
+  action: string;
+  entity_type?: string;
+  entity_id?: number;
+  details: string;
+}
+
+export interface BusinessDatabase {
+  label: string;
+  path: string;
+}
+
+export interface CSVColumn {
+  index: number;
+  header: string;
+  samples: string[];
+  type: string;
+  suggested_field?: string;
+  confidence?: number;
+}
+
+export interface CSVInspection {
+  delimiter: string;
+  columns: CSVColumn[];
+  fields: string[];
+  required_fields: string[];
+}
+
+export interface CSVMapping {
+  name?: string;
+  delimiter: string;
+  columns: Record<string, string>;
+}
+
+export interface CategoryMapping {
+  line: number;
+  description: string;
+  category: string;
+  updated: number;
+}
+
+export interface CategoryMappingRequest {
+  mappings: string;
+  only_missing?: boolean;
+  dry_run?: boolean;
+}
+
+export interface CheckpointResult {
+  mode: string;
+  at: string;
+  busy: boolean;
+  log_frames: number;
+  copied_frames: number;
+  wal_size_before: number;
+  wal_size: number;
+  error?: string;
+}
+
+export interface ClosePeriodRequest {
+  month: string;
+  note?: string;
+}
+
+export interface CohortFilter {
+  store?: string;
+  months?: number;
+}
+
+export interface CohortReport {
+  as_of: string;
+  months: number;
+  cohorts: VendorCohort[];
+}
+
+export interface ColumnMatch {
+  column: number;
+  header: string;
+  synonym?: string;
+  method: string;
+  confidence: number;
+}
+
+export interface ColumnOverride {
+  header?: string;
+  index?: number;
+  field?: string;
+  type?: string;
+}
+
+export interface CommissionDiscrepancy {
+  sales_record_id: number;
+  store: string;
+  vendor: string;
+  date: string;
+  description: string;
+  is_return: boolean;
+  sale_price: number;
+  rate: number;
+  reported: number;
+  expected: number;
+  difference: number;
+}
+
+export interface CommissionReconciliation {
+  stores: StoreReconciliation[];
+  discrepancies: CommissionDiscrepancy[];
+  overcharged: number;
+  undercharged: number;
+}
+
+export interface CommissionRule {
+  id: number;
+  store: string;
+  effective_from: string;
+  rate: number;
+  created_at: string;
+}
+
+export interface ConnectionStats {
+  max_open: number;
+  open: number;
+  in_use: number;
+  idle: number;
+  wait_count: number;
+  wait_duration: string;
+  max_idle_closed: number;
+  max_lifetime_closed: number;
+}
+
+export interface ConsolidatedReport {
+  businesses: string[];
+  by_business: MonthlySummary[];
+  combined: MonthlySummary[];
+}
+
+export interface ConsolidatedReportRequest {
+  label: string;
+  databases: BusinessDatabase[];
+  year?: string;
+}
+
+export interface CreateAPITokenRequest {
+  name: string;
+  scopes: string[];
+  expires_at?: string;
+}
+
+export interface CreateCommissionRuleRequest {
+  store: string;
+  effective_from: string;
+  rate: number;
+}
+
+export interface CreateExchangeRateRequest {
+  date: string;
+  currency: string;
+  rate: number;
+  source?: string;
+}
+
+export interface CreateFeeRequest {
+  store: string;
+  vendor?: string;
+  date: string;
+  category?: string;
+  description: string;
+  amount: number;
+}
+
+export interface CreateReportSnapshotRequest {
+  name: string;
+  filter: SalesRecordFilter;
+}
+
+export interface CreateSalesAdjustmentRequest {
+  sales_record_id: number;
+  date: string;
+  reason: string;
+  sale_price_delta: number;
+  commission_delta?: number;
+  remaining_delta?: number;
+}
+
+export interface CreateSalesRecordRequest {
+  store: string;
+  vendor: string;
+  date: string;
+  description: string;
+  sale_price: number;
+  commission?: number;
+  remaining?: number;
+  is_return?: boolean;
+  currency?: string;
+  external_id?: string;
+  cost?: number;
+  category?: string;
+  metadata?: Record<string, string>;
+  source_row?: SourceRow;
+}
+
+export interface CreatedAPIToken {
+  token: APIToken;
+  secret: string;
+}
+
+export interface CustomSummaryRequest {
+  group_by: string;
+  year?: string;
+  filter: SalesRecordFilter;
+  min_total_sales?: number;
+  min_items_sold?: number;
+  date_basis?: string;
+}
+
+export interface DashboardKPIs {
+  as_of: string;
+  month_to_date: KPIComparison;
+  year_to_date: KPIComparison;
+  projection: MonthProjection;
+}
+
+export interface DatabaseHealth {
+  connected: boolean;
+  error?: string;
+  status: string;
+  checks: HealthCheck[];
+  wal_size: number;
+  last_checkpoint?: CheckpointResult;
+  metrics?: DatabaseMetrics;
+}
+
+export interface DatabaseMetrics {
+  connections: ConnectionStats;
+  queries: QueryTiming[];
+  slow_queries?: SlowQuery[];
+  slow_query_threshold: string;
+  since: string;
+}
+
+export interface Digest {
+  period: string;
+  current: PeriodTotals;
+  previous: PeriodTotals;
+  top_vendor?: VendorTotal;
+  sales_change?: number;
+}
+
+export interface DigestSchedule {
+  enabled: boolean;
+  period: string;
+}
+
+export interface DrillDownBatch {
+  columns: string[];
+  groups: DrillDownGroup[];
+}
+
+export interface DrillDownBatchRequest {
+  periods: DrillDownPeriod[];
+  sort_by?: string;
+  sort_order?: string;
+  columns?: string[];
+}
+
+export interface DrillDownGroup {
+  period: string;
+  rows: unknown[][];
+}
+
+export interface DrillDownPeriod {
+  year: string;
+  month?: string;
+  day?: string;
+}
+
+export interface DrillDownRequest {
+  year: string;
+  month?: string;
+  day?: string;
+  sort_by?: string;
+  sort_order?: string;
+  columns?: string[];
+}
+
+export interface DrillDownTable {
+  columns: string[];
+  rows: unknown[][];
+}
+
+export interface EnrichmentRequest {
+  filter: SalesRecordFilter;
+  cost?: number;
+  category?: string;
+  add_tags?: string[];
+  remove_tags?: string[];
+  only_missing?: boolean;
+  dry_run?: boolean;
+}
+
+export interface EnrichmentResult {
+  updated: number;
+  mappings?: CategoryMapping[];
+  skipped?: string[];
+  dry_run?: boolean;
+}
+
+export interface ExchangeRate {
+  id: number;
+  date: string;
+  currency: string;
+  rate: number;
+  source: string;
+  created_at: string;
+}
+
+export interface ExchangeRateFilter {
+  currency?: string;
+  date_from?: string;
+  date_to?: string;
+}
+
+export interface ExpectedPayout {
+  store: string;
+  month: string;
+  date: string;
+  items_sold: number;
+  returned_items: number;
+  amount: number;
+}
+
+export interface ExportFormat {
+  decimal_separator: string;
+  thousands_separator: string;
+  csv_delimiter: string;
+  currency_symbol: string;
+  symbol_placement: string;
+  date_format: string;
+}
+
+export interface ExportFormatInfo {
+  name: string;
+  content_type: string;
+}
+
+export interface ExportOptions {
+  filter: SalesRecordFilter;
+}
+
+export interface Fee {
+  id: number;
+  store: string;
+  vendor?: string;
+  date: string;
+  category: string;
+  description: string;
+  amount: number;
+  import_run_id?: number;
+  created_at: string;
+}
+
+export interface FeeFilter {
+  store?: string;
+  category?: string;
+  date_from?: string;
+  date_to?: string;
+}
+
+export interface FieldDefaults {
+  store?: string;
+  vendor?: string;
+  commission_rate?: number;
+}
+
+export interface HealthCheck {
+  name: string;
+  severity: string;
+  message: string;
+  action?: string;
+}
+
+export interface IgnoreRule {
+  field: string;
+  pattern: string;
+  fee_category?: string;
+}
+
+export interface ImportActivity {
+  month: string;
+  imports: number;
+  failed_imports: number;
+  total_rows: number;
+  imported_rows: number;
+  updated_rows: number;
+  error_rows: number;
+  error_rate: number;
+  high_error_rate: boolean;
+}
+
+export interface ImportError {
+  index: number;
+  record: CreateSalesRecordRequest;
+  error: string;
+}
+
+export interface ImportOptions {
+  use_consignable_format: boolean;
+  custom_column_mapping?: string[];
+  strict_mode: boolean;
+  use_batch_import: boolean;
+  dry_run: boolean;
+  atomic?: boolean;
+  upsert: boolean;
+  layout?: string;
+  multi_table: boolean;
+  keep_unmapped_columns: boolean;
+  defaults?: FieldDefaults;
+  column_overrides?: ColumnOverride[];
+  quarantine: boolean;
+  mapping?: CSVMapping;
+  year_inference?: YearInference;
+  duplicate_content?: string;
+}
+
+export interface ImportResult {
+  schema_version: number;
+  success: boolean;
+  total_rows: number;
+  ignored_rows?: number;
+  fee_rows?: number;
+  quarantined_rows?: number;
+  parsed_rows: number;
+  imported_rows: number;
+  error_message?: string;
+  error?: AppError;
+  parse_errors?: ParseError[];
+  import_errors?: ImportError[];
+  processing_time: string;
+  timings: ImportTimings;
+  imported_records?: SalesRecord[];
+  column_mapping: Record<string, number>;
+  data_types_detected: Record<string, string>;
+  dry_run: boolean;
+  updated_rows?: number;
+  unchanged_rows?: number;
+  layout?: string;
+  tables?: TableSection[];
+  title?: string;
+  duplicate_of?: ImportRun;
+  chunked?: boolean;
+}
+
+export interface ImportRun {
+  id: number;
+  started_at: string;
+  source: string;
+  file_name?: string;
+  title?: string;
+  name?: string;
+  method: string;
+  layout?: string;
+  success: boolean;
+  total_rows: number;
+  parsed_rows: number;
+  imported_rows: number;
+  updated_rows: number;
+  unchanged_rows: number;
+  error_rows: number;
+  duration: string;
+  timings?: ImportTimings;
+  error_message?: string;
+  content_hash?: string;
+  created_at: string;
+}
+
+export interface ImportSource {
+  import_run_id: number;
+  content: string;
+  size: number;
+  stored_size: number;
+  created_at: string;
+}
+
+export interface ImportStatistics {
+  total_records: number;
+  recent_records: number;
+  total_sales: number;
+  average_price: number;
+}
+
+export interface ImportTimings {
+  parse: string;
+  validate: string;
+  dedupe: string;
+  insert: string;
+}
+
+export interface KPIComparison {
+  current: PeriodTotals;
+  previous: PeriodTotals;
+  sales_delta: number;
+  commission_delta: number;
+  net_delta: number;
+  items_delta: number;
+  sales_change?: number;
+}
+
+export interface Layout {
+  name: string;
+  label: string;
+  description: string;
+  positional?: string[];
+  headers?: Record<string, string>;
+  defaults?: Record<string, string>;
+}
+
+export interface LayoutMatch {
+  layout: string;
+  confidence: number;
+  reason: string;
+}
+
+export interface MaintenanceProgress {
+  operation: string;
+  started_at: string;
+  pages_done: number;
+  pages_total: number;
+  percent: number;
+  done: boolean;
+  error?: string;
+}
+
+export interface MemoryBudget {
+  limit_mb: number;
+}
+
+export interface MonthProjection {
+  days_elapsed: number;
+  days_in_month: number;
+  projected_sales: number;
+  last_year_sales: number;
+  last_year_change: number | null;
+}
+
+export interface MonthlyFeeSummary {
+  month: string;
+  fees: number;
+  total: number;
+  by_category: Record<string, number>;
+}
+
+export interface MonthlySummary {
+  year: string;
+  month: string;
+  year_month: string;
+  dimension?: string;
+  items_sold: number;
+  returned_items: number;
+  gross_sales: number;
+  total_returns: number;
+  total_sales: number;
+  total_commission: number;
+  total_remaining: number;
+  commission_rate: number;
+  commission_known_items: number;
+  unique_stores: number;
+  unique_vendors: number;
+  closed?: boolean;
+  amended?: boolean;
+}
+
+export interface NameAlias {
+  id: number;
+  kind: string;
+  alias: string;
+  canonical: string;
+  created_at: string;
+}
+
+export interface NetIncomeMonth {
+  month: string;
+  total_sales: number;
+  total_remaining: number;
+  total_fees: number;
+  net_income: number;
+}
+
+export interface Notification {
+  id: number;
+  source: string;
+  level: string;
+  title: string;
+  message: string;
+  created_at: string;
+  read_at?: string;
+}
+
+export interface NotificationFilter {
+  unread_only: boolean;
+  limit?: number;
+}
+
+export interface NotificationList {
+  notifications: Notification[];
+  unread: number;
+}
+
+export interface ParetoEntry {
+  rank: number;
+  group: string;
+  description?: string;
+  revenue: number;
+  share: number;
+  cumulative_share: number;
+}
+
+export interface ParetoFilter {
+  group_by: string;
+  store?: string;
+  date_from?: string;
+  date_to?: string;
+}
+
+export interface ParetoReport {
+  group_by: string;
+  total: number;
+  entries: ParetoEntry[];
+  core_groups: number;
+  core_share: number;
+}
+
+export interface ParseError {
+  table?: number;
+  row: number;
+  column?: string;
+  message: string;
+  value?: string;
+  snippet?: string;
+}
+
+export interface ParseWarning {
+  table?: number;
+  row: number;
+  column?: string;
+  message: string;
+  value?: string;
+}
+
+export interface PayoutFilter {
+  store?: string;
+  date_from?: string;
+  date_to?: string;
+}
+
+export interface PayoutSchedule {
+  store: string;
+  payout_day: number;
+  months_after: number;
+  updated_at: string;
+}
+
+export interface PeriodClose {
+  month: string;
+  snapshot_id?: number;
+  closed_at: string;
+  note?: string;
+  reopened_at?: string;
+  reopen_reason?: string;
+  changed_since_close: boolean;
+}
+
+export interface PeriodTotals {
+  from: string;
+  to: string;
+  items_sold: number;
+  returned_items: number;
+  total_sales: number;
+  total_commission: number;
+  total_remaining: number;
+}
+
+export interface ProfitSummary {
+  group: string;
+  description?: string;
+  costed_items: number;
+  uncosted_items: number;
+  sales: number;
+  proceeds: number;
+  cost: number;
+  profit: number;
+  margin?: number;
+  break_even_price?: number;
+}
+
+export interface ProfitabilityFilter {
+  group_by: string;
+  store?: string;
+  date_from?: string;
+  date_to?: string;
+}
+
+export interface ProfitabilityReport {
+  group_by: string;
+  groups: ProfitSummary[];
+  total: ProfitSummary;
+}
+
+export interface QuarantinedRow {
+  id: number;
+  import_run_id?: number;
+  record: CreateSalesRecordRequest;
+  reasons: string[];
+  created_at: string;
+}
+
+export interface QueryPlanReport {
+  name: string;
+  query: string;
+  steps: QueryPlanStep[];
+  warnings?: string[];
+  error?: string;
+}
+
+export interface QueryPlanStep {
+  id: number;
+  parent: number;
+  detail: string;
+}
+
+export interface QueryTimeouts {
+  read: string;
+  write: string;
+  maintenance: string;
+}
+
+export interface QueryTiming {
+  method: string;
+  count: number;
+  total: string;
+  max: string;
+  buckets: QueryTimingBucket[];
+}
+
+export interface QueryTimingBucket {
+  up_to?: string;
+  count: number;
+}
+
+export interface ReadOnlyQueryResult {
+  columns: string[];
+  rows: unknown[][];
+  truncated: boolean;
+  duration: string;
+}
+
+export interface ReconciliationFilter {
+  store?: string;
+  date_from?: string;
+  date_to?: string;
+  tolerance?: number;
+}
+
+export interface RecordProvenance {
+  record: SalesRecord;
+  import?: ImportRun;
+  source_row?: SourceRow;
+  has_source: boolean;
+  adjustments: SalesAdjustment[];
+  history: AuditEntry[];
+}
+
+export interface RemainingBackfillRequest {
+  filter: SalesRecordFilter;
+  tolerance?: number;
+  fix?: boolean;
+}
+
+export interface RemainingBackfillResult {
+  checked: number;
+  unchecked: number;
+  discrepancies: RemainingDiscrepancy[];
+  fixed: number;
+}
+
+export interface RemainingDiscrepancy {
+  sales_record_id: number;
+  store: string;
+  vendor: string;
+  date: string;
+  description: string;
+  sale_price: number;
+  commission: number;
+  rate?: number;
+  stored: number | null;
+  expected: number;
+  difference?: number;
+}
+
+export interface ReopenPeriodRequest {
+  month: string;
+  reason: string;
+}
+
+export interface ReportMethodology {
+  views: ReportView[];
+  rules: string[];
+}
+
+export interface ReportOptions {
+  base_currency?: string;
+  include_adjustments?: boolean;
+  date_basis?: string;
+}
+
+export interface ReportPerformanceConfig {
+  enabled: boolean;
+  threshold: number;
+  sample_size: number;
+}
+
+export interface ReportPerformanceStatus {
+  config: ReportPerformanceConfig;
+  record_count: number;
+  active: boolean;
+  aggregates_built_at?: string;
+}
+
+export interface ReportSnapshot {
+  id: number;
+  name: string;
+  filter: SalesRecordFilter;
+  totals: ReportTotals;
+  created_at: string;
+}
+
+export interface ReportTotals {
+  records: number;
+  items_sold: number;
+  returned_items: number;
+  total_sales: number;
+  total_commission: number;
+  total_remaining: number;
+}
+
+export interface ReportView {
+  name: string;
+  description: string;
+  sql: string;
+  columns: ReportViewColumn[];
+}
+
+export interface ReportViewColumn {
+  name: string;
+  description: string;
+}
+
+export interface RetentionPolicy {
+  enabled: boolean;
+  purge_deleted_after_days: number;
+  archive_after_years: number;
+  purge_import_history_after_days: number;
+}
+
+export interface RetentionResult {
+  dry_run: boolean;
+  ran_at: string;
+  archived_records: number;
+  archived_adjustments: number;
+  purged_deleted_records: number;
+  purged_import_runs: number;
+}
+
+export interface SalesAdjustment {
+  id: number;
+  sales_record_id: number;
+  date: string;
+  reason: string;
+  sale_price_delta: number;
+  commission_delta: number | null;
+  remaining_delta: number | null;
+  created_at: string;
+}
+
+export interface SalesRecord {
+  id: number;
+  store: string;
+  vendor: string;
+  date: string;
+  description: string;
+  product_key: string;
+  sale_price: number;
+  commission: number | null;
+  remaining: number | null;
+  is_return: boolean;
+  currency?: string;
+  external_id?: string;
+  cost?: number;
+  category?: string;
+  tags?: string[];
+  metadata?: Record<string, string>;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface SalesRecordFilter {
+  store?: string;
+  vendor?: string;
+  search?: string;
+  store_in?: string[];
+  vendor_in?: string[];
+  not_store?: string[];
+  not_vendor?: string[];
+  date_from?: string;
+  date_to?: string;
+  min_price?: number;
+  max_price?: number;
+  category?: string;
+  tag?: string;
+  metadata?: Record<string, string>;
+  limit?: number;
+  offset?: number;
+  sort_by?: string;
+  sort_order?: string;
+}
+
+export interface SalesSummary {
+  period: string;
+  items_sold: number;
+  returned_items: number;
+  gross_sales: number;
+  total_returns: number;
+  total_sales: number;
+  total_commission: number;
+  total_remaining: number;
+  commission_rate: number;
+  unique_stores: number;
+  unique_vendors: number;
+}
+
+export interface SaveNameAliasRequest {
+  kind: string;
+  alias: string;
+  canonical: string;
+}
+
+export interface SavePayoutScheduleRequest {
+  store: string;
+  payout_day: number;
+  months_after: number;
+}
+
+export interface SchemaCompatibility {
+  applied_version: number;
+  supported_version: number;
+  compatible: boolean;
+  message: string;
+}
+
+export interface SettingsBundle {
+  version: number;
+  exported_at: string;
+  export_format?: ExportFormat;
+  retention_policy?: RetentionPolicy;
+  week_start?: string;
+  ignore_rules?: IgnoreRule[];
+  aliases?: SaveNameAliasRequest[];
+  commission_rules?: CreateCommissionRuleRequest[];
+  payout_schedules?: SavePayoutScheduleRequest[];
+}
+
+export interface SettingsImportResult {
+  export_format: boolean;
+  retention_policy: boolean;
+  week_start: boolean;
+  ignore_rules: number;
+  aliases: number;
+  commission_rules: number;
+  payout_schedules: number;
+}
+
+export interface SlowQuery {
+  method: string;
+  query: string;
+  duration: string;
+  at: string;
+}
+
+export interface SnapshotChange {
+  before: SalesRecord;
+  after: SalesRecord;
+  changes: string;
+}
+
+export interface SnapshotComparison {
+  snapshot: ReportSnapshot;
+  current: ReportTotals;
+  added: SalesRecord[];
+  removed: SalesRecord[];
+  changed: SnapshotChange[];
+}
+
+export interface SourceRow {
+  table?: number;
+  row: number;
+  headers: string[];
+  cells: string[];
+}
+
+export interface StoreReconciliation {
+  store: string;
+  month: string;
+  checked_items: number;
+  reported: number;
+  expected: number;
+  difference: number;
+  discrepancies: number;
+  unchecked_items: number;
+}
+
+export interface TableContext {
+  caption?: string;
+  heading?: string;
+  section?: string;
+  period?: string;
+  year?: number;
+}
+
+export interface TableSection {
+  table: number;
+  context?: string;
+  period?: string;
+  first_record: number;
+  total_rows: number;
+  success_count: number;
+  error_count: number;
+}
+
+export interface Timeline {
+  kind: string;
+  name: string;
+  entries: TimelineEntry[];
+}
+
+export interface TimelineEntry {
+  date: string;
+  type: string;
+  store: string;
+  vendor?: string;
+  description: string;
+  amount: number;
+  currency?: string;
+  sales_record_id?: number;
+  adjustment_id?: number;
+  month?: string;
+  remaining?: number;
+}
+
+export interface TimelineFilter {
+  kind: string;
+  name: string;
+  date_from?: string;
+  date_to?: string;
+}
+
+export interface TrailingTwelveMonths {
+  end_month: string;
+  as_of: string;
+  comparison: KPIComparison;
+}
+
+export interface UnmappedColumn {
+  table?: number;
+  column: number;
+  header: string;
+  values: number;
+  sample?: string;
+}
+
+export interface ValidationResult {
+  valid: boolean;
+  total_rows: number;
+  ignored_rows?: number;
+  fee_rows?: number;
+  valid_rows: number;
+  invalid_rows: number;
+  error_message?: string;
+  error?: AppError;
+  errors?: ParseError[];
+  warnings?: ParseWarning[];
+  column_mapping: Record<string, number>;
+  data_types_detected: Record<string, string>;
+  processing_time: string;
+  suggested_layouts: LayoutMatch[];
+  context?: TableContext;
+  column_matches?: Record<string, ColumnMatch>;
+  unmapped_columns?: UnmappedColumn[];
+  records: CreateSalesRecordRequest[];
+}
+
+export interface VelocityFilter {
+  store?: string;
+  weeks?: number;
+  dormant_weeks?: number;
+}
+
+export interface VelocityReport {
+  as_of: string;
+  weeks: number;
+  dormant_weeks: number;
+  vendors: VendorVelocity[];
+  dormant_vendors: number;
+}
+
+export interface VendorCohort {
+  month: string;
+  vendors: number;
+  revenue: number[];
+  active_vendors: number[];
+  retention: number[];
+}
+
+export interface VendorSettlement {
+  store: string;
+  month: string;
+  items_sold: number;
+  returned_items: number;
+  total_sales: number;
+  total_commission: number;
+  total_remaining: number;
+}
+
+export interface VendorStatement {
+  vendor: string;
+  from: string;
+  to: string;
+  generated_at: string;
+  lines: VendorStatementLine[];
+  settlements: VendorSettlement[];
+  total: VendorSettlement;
+}
+
+export interface VendorStatementFilter {
+  vendor: string;
+  date_from?: string;
+  date_to?: string;
+}
+
+export interface VendorStatementLine {
+  date: string;
+  store: string;
+  description: string;
+  is_return: boolean;
+  sale_price: number;
+  commission?: number;
+  remaining?: number;
+  currency?: string;
+}
+
+export interface VendorTotal {
+  vendor: string;
+  items_sold: number;
+  total_sales: number;
+}
+
+export interface VendorVelocity {
+  vendor: string;
+  items_sold: number;
+  weeks: number;
+  items_per_week: number;
+  first_sale_date: string;
+  last_sale_date: string;
+  weeks_since_last_sale: number;
+  dormant: boolean;
+}
+
+export interface WorkspaceManifest {
+  format_version: number;
+  created_at: string;
+  schema_version: number;
+  records: number;
+}
+
+export interface WriteQueueStatus {
+  busy: boolean;
+  since?: string;
+  waiting: number;
+}
+
+export interface YearInference {
+  source?: string;
+  year?: number;
+}
@@ -1,53 +1,152 @@
 package main
 
 import (
-	"time"
-
+	"sales-track/internal/database"
 	"sales-track/internal/models"
 	"sales-track/internal/parser"
 )
 
 // ImportResult represents the result of an HTML data import operation
 type ImportResult struct {
-	Success           bool                      `json:"success"`
-	TotalRows         int                       `json:"total_rows"`
-	ParsedRows        int                       `json:"parsed_rows"`
-	ImportedRows      int                       `json:"imported_rows"`
-	ErrorMessage      string                    `json:"error_message,omitempty"`
-	ParseErrors       []parser.ParseError       `json:"parse_errors,omitempty"`
-	ImportErrors      []ImportError             `json:"import_errors,omitempty"`
-	ProcessingTime    time.Duration             `json:"processing_time"`
-	ImportedRecords   []models.SalesRecord      `json:"imported_records,omitempty"`
-	ColumnMapping     map[string]int            `json:"column_mapping"`
-	DataTypesDetected map[string]string         `json:"data_types_detected"`
+	SchemaVersion     int                   `json:"schema_version"` // parser.SchemaVersion of the encoding
+	Success           bool                  `json:"success"`
+	TotalRows         int                   `json:"total_rows"`
+	IgnoredRows       int                   `json:"ignored_rows,omitempty"`     // Rows skipped by the saved ignore rules, not counted in TotalRows
+	FeeRows           int                   `json:"fee_rows,omitempty"`         // Ignored rows recorded as fees
+	QuarantinedRows   int                   `json:"quarantined_rows,omitempty"` // Rows set aside for review by the Quarantine option, not counted in TotalRows
+	ParsedRows        int                   `json:"parsed_rows"`
+	ImportedRows      int                   `json:"imported_rows"`
+	ErrorMessage      string                `json:"error_message,omitempty"` // Error.Message, kept for older frontends
+	Error             *AppError             `json:"error,omitempty"`
+	ParseErrors       []parser.ParseError   `json:"parse_errors,omitempty"`
+	ImportErrors      []ImportError         `json:"import_errors,omitempty"`
+	ProcessingTime    models.Duration       `json:"processing_time"`
+	Timings           models.ImportTimings  `json:"timings"` // Time spent parsing, validating, looking up existing records and writing
+	ImportedRecords   []models.SalesRecord  `json:"imported_records,omitempty"`
+	ColumnMapping     map[string]int        `json:"column_mapping"`
+	DataTypesDetected map[string]string     `json:"data_types_detected"`
+	DryRun            bool                  `json:"dry_run"`                  // True when nothing was persisted
+	UpdatedRows       int                   `json:"updated_rows,omitempty"`   // Upsert imports: existing records that changed
+	UnchangedRows     int                   `json:"unchanged_rows,omitempty"` // Upsert imports: existing records left as they were
+	Layout            string                `json:"layout,omitempty"`         // Layout used, including one chosen by "auto" detection
+	Tables            []parser.TableSection `json:"tables,omitempty"`         // Multi-table imports: rows and records per table
+	Title             string                `json:"title,omitempty"`          // Caption or heading of the imported table, for naming the import
+	DuplicateOf       *models.ImportRun     `json:"duplicate_of,omitempty"`   // An earlier successful import of the same records, as when a report is imported twice
+	Chunked           bool                  `json:"chunked,omitempty"`        // Parsed and saved in chunks by the streaming pipeline, as the data was too large to parse whole within the memory budget
+
+	fees        []models.CreateFeeRequest // Fees read from rows matching fee rules, recorded once the import succeeds
+	quarantined []models.QuarantinedRow   // Rows failing a business-rule check, kept for review once the import succeeds
+	contentHash string                    // parser.ParseResult.ContentHash, kept in the import history
 }
 
 // ImportError represents an error that occurred during database import
 type ImportError struct {
+	Index  int                             `json:"index"` // Position of the record among the parsed records
 	Record models.CreateSalesRecordRequest `json:"record"`
 	Error  string                          `json:"error"`
 }
 
 // ImportOptions provides configuration options for HTML data import
 type ImportOptions struct {
-	UseConsignableFormat bool     `json:"use_consignable_format"`
-	CustomColumnMapping  []string `json:"custom_column_mapping,omitempty"`
-	StrictMode           bool     `json:"strict_mode"`
-	UseBatchImport       bool     `json:"use_batch_import"`
+	UseConsignableFormat bool                    `json:"use_consignable_format"`
+	CustomColumnMapping  []string                `json:"custom_column_mapping,omitempty"`
+	StrictMode           bool                    `json:"strict_mode"`
+	UseBatchImport       bool                    `json:"use_batch_import"`
+	DryRun               bool                    `json:"dry_run"`                     // Run the full import in a rolled-back transaction
+	Atomic               *bool                   `json:"atomic,omitempty"`            // All-or-nothing; defaults to true for batch imports, false imports valid rows only
+	Upsert               bool                    `json:"upsert"`                      // Update records already imported with the same store and transaction id
+	Layout               string                  `json:"layout,omitempty"`            // Built-in platform preset such as "etsy", or "auto" to detect one; overrides the mapping options above
+	MultiTable           bool                    `json:"multi_table"`                 // Import every table on the page, dating rows without a date from the table's heading
+	KeepUnmappedColumns  bool                    `json:"keep_unmapped_columns"`       // Store columns no field was mapped to as custom fields in each record's metadata
+	Defaults             *parser.FieldDefaults   `json:"defaults,omitempty"`          // Store, vendor and commission rate for rows the report leaves them out of; override a layout's defaults
+	ColumnOverrides      []parser.ColumnOverride `json:"column_overrides,omitempty"`  // Fields and types forced on columns, by header or position, over the automatic mapping
+	Quarantine           bool                    `json:"quarantine"`                  // Set aside rows with a future date or a commission or remaining amount above the sale price for review instead of importing them
+	Mapping              *models.CSVMapping      `json:"mapping,omitempty"`           // Fields of a delimited file's columns, from the mapping wizard or a saved profile; overrides Layout and the mapping options above
+	YearInference        *parser.YearInference   `json:"year_inference,omitempty"`    // Where dates without a year, such as "Mar 15", take it from; by default the report's caption or heading
+	DuplicateContent     string                  `json:"duplicate_content,omitempty"` // DuplicateContentWarn, the default, or DuplicateContentBlock to refuse a report whose records an earlier import already imported
+}
+
+// How an import handles a report whose parsed records match an earlier
+// successful import, complementing the row-level dedupe of upserts
+const (
+	DuplicateContentWarn  = "warn"  // Import it and name the earlier import in ImportResult.DuplicateOf
+	DuplicateContentBlock = "block" // Import nothing and fail with ErrCodeDuplicateImport
+)
+
+// atomic reports whether the import should roll back entirely on any failure
+func (o ImportOptions) atomic() bool {
+	if o.Atomic != nil {
+		return *o.Atomic
+	}
+	return o.UseBatchImport
+}
+
+// importProgressEvent is emitted after each chunk of a streaming import
+const importProgressEvent = "import:progress"
+
+// detectSampleSize is the number of bytes inspected when detecting the layout
+// of a streamed import
+const detectSampleSize = 64 * 1024
+
+// streamChunkSize is the number of records inserted per chunk by streaming imports
+const streamChunkSize = 500
+
+// ImportProgress is the payload of an import progress event
+type ImportProgress struct {
+	RowsInserted int `json:"rows_inserted"`
+	RowsUpdated  int `json:"rows_updated"` // Rows updating a record imported before with the same external id
+	Chunks       int `json:"chunks"`
+}
+
+// exportProgressEvent is emitted after each chunk of a streamed export
+const exportProgressEvent = "export:progress"
+
+// exportChunkSize is the number of records written to disk per chunk by
+// streamed exports
+const exportChunkSize = 5000
+
+// exportSortMemory is about how much memory SQLite needs per record to sort
+// an export in memory. Exports whose records would need more than the memory
+// budget are sorted in a temporary file.
+const exportSortMemory = 512
+
+// ExportProgress is the payload of an export progress event
+type ExportProgress struct {
+	RecordsWritten int   `json:"records_written"`
+	Total          int64 `json:"total"` // Records matching the filter when the export started
+}
+
+// ExportOptions selects the records App.Export writes
+type ExportOptions struct {
+	Filter models.SalesRecordFilter `json:"filter"` // Limit and offset are ignored
+}
+
+// ExportFormatInfo describes a file format App.Export accepts
+type ExportFormatInfo struct {
+	Name        string `json:"name"`         // Passed to Export as its format
+	ContentType string `json:"content_type"` // MIME type of the files written
 }
 
 // ValidationResult represents the result of HTML data validation
 type ValidationResult struct {
-	Valid             bool                      `json:"valid"`
-	TotalRows         int                       `json:"total_rows"`
-	ValidRows         int                       `json:"valid_rows"`
-	InvalidRows       int                       `json:"invalid_rows"`
-	ErrorMessage      string                    `json:"error_message,omitempty"`
-	Errors            []parser.ParseError       `json:"errors,omitempty"`
-	Warnings          []parser.ParseWarning     `json:"warnings,omitempty"`
-	ColumnMapping     map[string]int            `json:"column_mapping"`
-	DataTypesDetected map[string]string         `json:"data_types_detected"`
-	ProcessingTime    time.Duration             `json:"processing_time"`
+	Valid             bool                              `json:"valid"`
+	TotalRows         int                               `json:"total_rows"`
+	IgnoredRows       int                               `json:"ignored_rows,omitempty"` // Rows the saved ignore rules would skip
+	FeeRows           int                               `json:"fee_rows,omitempty"`     // Ignored rows that would be recorded as fees
+	ValidRows         int                               `json:"valid_rows"`
+	InvalidRows       int                               `json:"invalid_rows"`
+	ErrorMessage      string                            `json:"error_message,omitempty"` // Error.Message, kept for older frontends
+	Error             *AppError                         `json:"error,omitempty"`
+	Errors            []parser.ParseError               `json:"errors,omitempty"`
+	Warnings          []parser.ParseWarning             `json:"warnings,omitempty"`
+	ColumnMapping     map[string]int                    `json:"column_mapping"`
+	DataTypesDetected map[string]string                 `json:"data_types_detected"`
+	ProcessingTime    models.Duration                   `json:"processing_time"`
+	SuggestedLayouts  []parser.LayoutMatch              `json:"suggested_layouts"`          // Built-in layouts that match the data, most likely first
+	Context           *parser.TableContext              `json:"context,omitempty"`          // Caption, heading and section title of the table, and the year they name
+	ColumnMatches     map[string]parser.ColumnMatch     `json:"column_matches,omitempty"`   // Header, synonym and confidence behind each mapped field
+	UnmappedColumns   []parser.UnmappedColumn           `json:"unmapped_columns,omitempty"` // Columns with data that no field was mapped to
+	Records           []models.CreateSalesRecordRequest `json:"records"`                    // Valid rows as they would be stored: dates and amounts normalized, ignore rules and name aliases applied
 }
 
 // ImportStatistics provides statistics about imported data
@@ -58,8 +157,14 @@ type ImportStatistics struct {
 	AveragePrice  float64 `json:"average_price"`
 }
 
-// DatabaseHealth represents the health status of the database connection
+// DatabaseHealth represents the health status of the database
 type DatabaseHealth struct {
-	Connected bool   `json:"connected"`
-	Error     string `json:"error,omitempty"`
+	Connected bool                   `json:"connected"`
+	Error     string                 `json:"error,omitempty"`
+	Status    string                 `json:"status"` // Worst severity among the checks: "ok", "warning" or "critical"
+	Checks    []database.HealthCheck `json:"checks"`
+
+	WALSize        int64                      `json:"wal_size"`                  // Bytes in the write-ahead log
+	LastCheckpoint *database.CheckpointResult `json:"last_checkpoint,omitempty"` // Latest scheduled WAL checkpoint
+	Metrics        *database.DatabaseMetrics  `json:"metrics,omitempty"`         // Connection pool statistics, query timings by repository method and recent slow queries
 }
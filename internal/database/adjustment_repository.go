@@ -0,0 +1,116 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// adjustmentColumns is the column list selected for an adjustment, in the
+// order expected by scanAdjustment
+const adjustmentColumns = "id, sales_record_id, date, reason, sale_price_delta, commission_delta, remaining_delta, created_at"
+
+// AdjustmentRepository handles database operations for sales adjustments
+type AdjustmentRepository struct {
+	db *DB
+	q  queryer
+}
+
+// NewAdjustmentRepository creates a new adjustment repository
+func NewAdjustmentRepository(db *DB) *AdjustmentRepository {
+	return &AdjustmentRepository{db: db, q: db.conn}
+}
+
+// WithTx returns a copy of the repository whose operations run inside tx
+func (r *AdjustmentRepository) WithTx(tx *sql.Tx) *AdjustmentRepository {
+	return &AdjustmentRepository{db: r.db, q: tx}
+}
+
+// scanAdjustment scans a row selected with adjustmentColumns
+func scanAdjustment(scanner rowScanner, adjustment *models.SalesAdjustment) error {
+	return scanner.Scan(
+		&adjustment.ID,
+		&adjustment.SalesRecordID,
+		&adjustment.Date,
+		&adjustment.Reason,
+		&adjustment.SalePriceDelta,
+		&adjustment.CommissionDelta,
+		&adjustment.RemainingDelta,
+		&adjustment.CreatedAt,
+	)
+}
+
+// Create records an adjustment against an existing sale
+func (r *AdjustmentRepository) Create(adjustment models.CreateSalesAdjustmentRequest) (*models.SalesAdjustment, error) {
+	date, err := time.Parse("2006-01-02", adjustment.Date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date format: %w", err)
+	}
+
+	query := `
+		INSERT INTO sales_adjustments (sales_record_id, date, reason, sale_price_delta, commission_delta, remaining_delta)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING ` + adjustmentColumns
+
+	var created models.SalesAdjustment
+	err = scanAdjustment(r.q.QueryRow(query,
+		adjustment.SalesRecordID,
+		date,
+		adjustment.Reason,
+		adjustment.SalePriceDelta,
+		adjustment.CommissionDelta,
+		adjustment.RemainingDelta,
+	), &created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create adjustment: %w", err)
+	}
+
+	return &created, nil
+}
+
+// ListForRecord retrieves the adjustments to a sale, oldest first
+func (r *AdjustmentRepository) ListForRecord(salesRecordID int64) ([]models.SalesAdjustment, error) {
+	query := "SELECT " + adjustmentColumns + " FROM sales_adjustments WHERE sales_record_id = ? ORDER BY date, id"
+
+	rows, err := r.q.Query(query, salesRecordID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query adjustments: %w", err)
+	}
+	defer rows.Close()
+
+	var adjustments []models.SalesAdjustment
+	for rows.Next() {
+		var adjustment models.SalesAdjustment
+		if err := scanAdjustment(rows, &adjustment); err != nil {
+			return nil, fmt.Errorf("failed to scan adjustment: %w", err)
+		}
+		adjustments = append(adjustments, adjustment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating adjustments: %w", err)
+	}
+
+	return adjustments, nil
+}
+
+// Delete removes an adjustment
+func (r *AdjustmentRepository) Delete(id int64) error {
+	result, err := r.q.Exec("DELETE FROM sales_adjustments WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete adjustment: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("adjustment with ID %d %w", id, ErrNotFound)
+	}
+
+	return nil
+}
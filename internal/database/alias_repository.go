@@ -0,0 +1,157 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"sales-track/internal/models"
+)
+
+// nameAliasColumns is the column list selected for a name alias, in the order
+// expected by scanNameAlias
+const nameAliasColumns = "id, kind, alias, canonical, created_at"
+
+// AliasRepository handles database operations for store and vendor name
+// aliases
+type AliasRepository struct {
+	db *DB
+	q  queryer
+}
+
+// NewAliasRepository creates a new alias repository
+func NewAliasRepository(db *DB) *AliasRepository {
+	return &AliasRepository{db: db, q: db.conn}
+}
+
+// WithTx returns a copy of the repository whose operations run inside tx
+func (r *AliasRepository) WithTx(tx *sql.Tx) *AliasRepository {
+	return &AliasRepository{db: r.db, q: tx}
+}
+
+// scanNameAlias scans a row selected with nameAliasColumns
+func scanNameAlias(scanner rowScanner, alias *models.NameAlias) error {
+	return scanner.Scan(
+		&alias.ID,
+		&alias.Kind,
+		&alias.Alias,
+		&alias.Canonical,
+		&alias.CreatedAt,
+	)
+}
+
+// Save stores an alias, replacing the name an existing alias of the same kind
+// maps to
+func (r *AliasRepository) Save(alias models.SaveNameAliasRequest) (*models.NameAlias, error) {
+	query := `
+		INSERT INTO name_aliases (kind, alias, canonical)
+		VALUES (?, ?, ?)
+		ON CONFLICT(kind, alias) DO UPDATE SET
+			alias = excluded.alias,
+			canonical = excluded.canonical
+		RETURNING ` + nameAliasColumns
+
+	var saved models.NameAlias
+	err := scanNameAlias(r.q.QueryRow(query, alias.Kind, alias.Alias, alias.Canonical), &saved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save name alias: %w", err)
+	}
+
+	return &saved, nil
+}
+
+// List retrieves the aliases of a kind, or of every kind when kind is empty,
+// ordered by kind and alias
+func (r *AliasRepository) List(kind string) ([]models.NameAlias, error) {
+	query := "SELECT " + nameAliasColumns + " FROM name_aliases"
+	var args []interface{}
+	if kind != "" {
+		query += " WHERE kind = ?"
+		args = append(args, kind)
+	}
+	query += " ORDER BY kind, alias"
+
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query name aliases: %w", err)
+	}
+	defer rows.Close()
+
+	aliases := []models.NameAlias{}
+	for rows.Next() {
+		var alias models.NameAlias
+		if err := scanNameAlias(rows, &alias); err != nil {
+			return nil, fmt.Errorf("failed to scan name alias: %w", err)
+		}
+		aliases = append(aliases, alias)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate name aliases: %w", err)
+	}
+
+	return aliases, nil
+}
+
+// Delete removes an alias by ID
+func (r *AliasRepository) Delete(id int64) error {
+	result, err := r.q.Exec("DELETE FROM name_aliases WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete name alias: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("name alias with ID %d %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+// Load reads every alias into a lookup applied to imported records
+func (r *AliasRepository) Load() (nameAliases, error) {
+	aliases, err := r.List("")
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := make(nameAliases, len(aliases))
+	for _, alias := range aliases {
+		lookup[aliasKey(alias.Kind, alias.Alias)] = alias.Canonical
+	}
+	return lookup, nil
+}
+
+// nameAliases maps the kind and folded alias of each name alias to its
+// canonical name
+type nameAliases map[string]string
+
+// aliasKey is the key of a name in nameAliases
+func aliasKey(kind, name string) string {
+	return kind + "\x00" + strings.ToLower(strings.TrimSpace(name))
+}
+
+// resolve returns the canonical name of a name, if it is an alias
+func (a nameAliases) resolve(kind, name string) (string, bool) {
+	canonical, ok := a[aliasKey(kind, name)]
+	return canonical, ok
+}
+
+// apply files a record under the canonical names of its store and vendor.
+// Aliases never map to another alias, so applying them again changes nothing.
+func (a nameAliases) apply(record models.CreateSalesRecordRequest) models.CreateSalesRecordRequest {
+	if len(a) == 0 {
+		return record
+	}
+	if canonical, ok := a.resolve(models.AliasKindStore, record.Store); ok {
+		record.Store = canonical
+	}
+	if canonical, ok := a.resolve(models.AliasKindVendor, record.Vendor); ok {
+		record.Vendor = canonical
+	}
+	return record
+}
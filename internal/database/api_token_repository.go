@@ -0,0 +1,126 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// apiTokenColumns is the column list selected for an API token, in the order
+// expected by scanAPIToken. The hash is never selected.
+const apiTokenColumns = "id, name, hint, scopes, expires_at, created_at, last_used_at, revoked_at"
+
+// APITokenRepository handles database operations for API tokens
+type APITokenRepository struct {
+	db *DB
+	q  queryer
+}
+
+// NewAPITokenRepository creates a new API token repository
+func NewAPITokenRepository(db *DB) *APITokenRepository {
+	return &APITokenRepository{db: db, q: db.conn}
+}
+
+// WithTx returns a copy of the repository whose operations run inside tx
+func (r *APITokenRepository) WithTx(tx *sql.Tx) *APITokenRepository {
+	return &APITokenRepository{db: r.db, q: tx}
+}
+
+// scanAPIToken scans a row selected with apiTokenColumns
+func scanAPIToken(scanner rowScanner, token *models.APIToken) error {
+	var scopes string
+	if err := scanner.Scan(&token.ID, &token.Name, &token.Hint, &scopes, &token.ExpiresAt, &token.CreatedAt, &token.LastUsedAt, &token.RevokedAt); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(scopes), &token.Scopes); err != nil {
+		return fmt.Errorf("invalid API token scopes: %w", err)
+	}
+	return nil
+}
+
+// Create stores an API token under the hash of its secret
+func (r *APITokenRepository) Create(token models.APIToken, hash string) (*models.APIToken, error) {
+	scopes, err := json.Marshal(token.Scopes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode API token scopes: %w", err)
+	}
+
+	var created models.APIToken
+	err = scanAPIToken(r.q.QueryRow(`
+		INSERT INTO api_tokens (name, token_hash, hint, scopes, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING `+apiTokenColumns, token.Name, hash, token.Hint, string(scopes), token.ExpiresAt), &created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API token: %w", err)
+	}
+
+	return &created, nil
+}
+
+// GetByHash retrieves the API token whose secret has hash, returning
+// ErrNotFound if there is none
+func (r *APITokenRepository) GetByHash(hash string) (*models.APIToken, error) {
+	var token models.APIToken
+	err := scanAPIToken(r.q.QueryRow("SELECT "+apiTokenColumns+" FROM api_tokens WHERE token_hash = ?", hash), &token)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("API token %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// List retrieves every API token, revoked ones included, newest first
+func (r *APITokenRepository) List() ([]models.APIToken, error) {
+	rows, err := r.q.Query("SELECT " + apiTokenColumns + " FROM api_tokens ORDER BY id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		var token models.APIToken
+		if err := scanAPIToken(rows, &token); err != nil {
+			return nil, fmt.Errorf("failed to scan API token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating API tokens: %w", err)
+	}
+
+	return tokens, nil
+}
+
+// Revoke marks an API token revoked at now and returns it. Revoking a
+// revoked token keeps the time it was first revoked.
+func (r *APITokenRepository) Revoke(id int64, now time.Time) (*models.APIToken, error) {
+	var token models.APIToken
+	err := scanAPIToken(r.q.QueryRow(`
+		UPDATE api_tokens SET revoked_at = COALESCE(revoked_at, ?)
+		WHERE id = ?
+		RETURNING `+apiTokenColumns, now.UTC(), id), &token)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("API token with ID %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke API token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// MarkUsed records that an API token was used at now
+func (r *APITokenRepository) MarkUsed(id int64, now time.Time) error {
+	if _, err := r.q.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", now.UTC(), id); err != nil {
+		return fmt.Errorf("failed to record API token use: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// apiTokenHintLength is how many characters of a token, after its prefix,
+// are kept to tell tokens apart
+const apiTokenHintLength = 6
+
+// hashAPIToken returns the hash an API token is kept under. Tokens are 256
+// random bits, so a plain SHA-256 is enough to keep them from being read
+// back; unlike an app lock PIN they cannot be guessed.
+func hashAPIToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// validateAPITokenRequest checks a new token's name, scopes and expiry and
+// returns its scopes without duplicates, in the order of APITokenScopes
+func validateAPITokenRequest(req models.CreateAPITokenRequest, now time.Time) ([]string, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, invalidf("API token name is required")
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return nil, invalidf("API token expiry must be in the future")
+	}
+	requested := make(map[string]bool, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !slices.Contains(models.APITokenScopes, scope) {
+			return nil, invalidf("unknown API token scope %q", scope)
+		}
+		requested[scope] = true
+	}
+	var scopes []string
+	for _, scope := range models.APITokenScopes {
+		if requested[scope] {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, invalidf("an API token needs at least one scope")
+	}
+	return scopes, nil
+}
+
+// CreateAPIToken creates a token for the local HTTP server and returns it
+// with its secret. Only a hash of the secret is kept, so it cannot be shown
+// again.
+func (s *Service) CreateAPIToken(req models.CreateAPITokenRequest, now time.Time) (*models.CreatedAPIToken, error) {
+	scopes, err := validateAPITokenRequest(req, now)
+	if err != nil {
+		return nil, err
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("failed to generate API token: %w", err)
+	}
+	secret := models.APITokenPrefix + base64.RawURLEncoding.EncodeToString(random)
+
+	token := models.APIToken{
+		Name:      strings.TrimSpace(req.Name),
+		Hint:      secret[:len(models.APITokenPrefix)+apiTokenHintLength],
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	var created *models.APIToken
+	err = s.ExecTx(func(tx *Service) error {
+		if created, err = tx.apiTokenRepo.Create(token, hashAPIToken(secret)); err != nil {
+			return err
+		}
+		return tx.auditAPIToken(models.AuditAPITokenCreated, created, "created with scopes "+strings.Join(scopes, ", "))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &models.CreatedAPIToken{Token: *created, Secret: secret}, nil
+}
+
+// auditAPIToken records a change to an API token in the audit log
+func (s *Service) auditAPIToken(action string, token *models.APIToken, change string) error {
+	entityType := "api_token"
+	_, err := s.auditRepo.Create(models.AuditEntry{
+		Action:     action,
+		EntityType: &entityType,
+		EntityID:   &token.ID,
+		Details:    fmt.Sprintf("API token %q (%s...): %s", token.Name, token.Hint, change),
+	})
+	return err
+}
+
+// ListAPITokens retrieves every API token, revoked and expired ones
+// included, newest first
+func (s *Service) ListAPITokens() ([]models.APIToken, error) {
+	return s.apiTokenRepo.List()
+}
+
+// RevokeAPIToken stops an API token from being accepted. The token stays in
+// the list, marked revoked.
+func (s *Service) RevokeAPIToken(id int64, now time.Time) (*models.APIToken, error) {
+	var revoked *models.APIToken
+	err := s.ExecTx(func(tx *Service) error {
+		var err error
+		if revoked, err = tx.apiTokenRepo.Revoke(id, now); err != nil {
+			return err
+		}
+		return tx.auditAPIToken(models.AuditAPITokenRevoked, revoked, "revoked")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revoked, nil
+}
+
+// AuthenticateAPIToken returns the token whose secret was presented to the
+// local HTTP server, failing with ErrUnauthorized unless it is active at now
+// and was granted scope. The time of use is recorded only if the database is
+// free to write at once, so a request never waits behind an import or
+// maintenance to record it.
+func (s *Service) AuthenticateAPIToken(secret, scope string, now time.Time) (*models.APIToken, error) {
+	token, err := s.apiTokenRepo.GetByHash(hashAPIToken(secret))
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: unknown API token", ErrUnauthorized)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !token.Active(now) {
+		return nil, fmt.Errorf("%w: API token %q is revoked or expired", ErrUnauthorized, token.Name)
+	}
+	if !token.HasScope(scope) {
+		return nil, fmt.Errorf("%w: API token %q does not have the %s scope", ErrUnauthorized, token.Name, scope)
+	}
+
+	if release, err := s.tryBeginWrite(); err == nil {
+		defer release()
+		if err := s.apiTokenRepo.MarkUsed(token.ID, now); err != nil {
+			return nil, err
+		}
+		usedAt := now.UTC()
+		token.LastUsedAt = &usedAt
+	}
+	return token, nil
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"sales-track/internal/models"
+)
+
+// appLockIterations is the PBKDF2 work factor for new app lock secrets
+const appLockIterations = 200000
+
+// appLockCredential is the saved form of the app lock's PIN or password: a
+// PBKDF2-SHA256 hash, never the secret itself
+type appLockCredential struct {
+	Salt       []byte `json:"salt"`
+	Hash       []byte `json:"hash"`
+	Iterations int    `json:"iterations"`
+}
+
+// newAppLockCredential hashes secret with a new random salt
+func newAppLockCredential(secret string) (appLockCredential, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return appLockCredential{}, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return appLockCredential{
+		Salt:       salt,
+		Hash:       pbkdf2SHA256([]byte(secret), salt, appLockIterations, sha256.Size),
+		Iterations: appLockIterations,
+	}, nil
+}
+
+// matches reports whether secret is the one the credential was made from
+func (c appLockCredential) matches(secret string) bool {
+	hash := pbkdf2SHA256([]byte(secret), c.Salt, c.Iterations, len(c.Hash))
+	return subtle.ConstantTimeCompare(hash, c.Hash) == 1
+}
+
+// pbkdf2SHA256 derives a key of keyLen bytes from password as in RFC 8018
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	mac := hmac.New(sha256.New, password)
+	var key []byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		mac.Reset()
+		mac.Write(salt)
+		mac.Write(binary.BigEndian.AppendUint32(nil, block))
+		u := mac.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			mac.Reset()
+			mac.Write(u)
+			u = mac.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// appLockCredential returns the saved app lock credential, or nil if no lock
+// is set
+func (s *Service) appLockCredential() (*appLockCredential, error) {
+	var credential appLockCredential
+	found, err := s.settingsRepo.Get(settingAppLock, &credential)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// AppLockEnabled reports whether a PIN or password must be entered before the
+// app shows any data
+func (s *Service) AppLockEnabled() (bool, error) {
+	credential, err := s.appLockCredential()
+	return credential != nil, err
+}
+
+// VerifyAppLock reports whether secret is the app lock's PIN or password. It
+// reports true when no lock is set.
+func (s *Service) VerifyAppLock(secret string) (bool, error) {
+	credential, err := s.appLockCredential()
+	if err != nil || credential == nil {
+		return err == nil, err
+	}
+	return credential.matches(secret), nil
+}
+
+// SetAppLock sets or changes the app lock's PIN or password. Changing it
+// requires the current one.
+func (s *Service) SetAppLock(current, secret string) error {
+	if len([]rune(strings.TrimSpace(secret))) < models.MinAppLockSecretLength {
+		return invalidf("the PIN or password must be at least %d characters", models.MinAppLockSecretLength)
+	}
+	return s.updateAppLock(current, &secret)
+}
+
+// RemoveAppLock turns the app lock off. It requires the current PIN or
+// password.
+func (s *Service) RemoveAppLock(current string) error {
+	return s.updateAppLock(current, nil)
+}
+
+// updateAppLock checks current against the saved lock, if any, and replaces
+// the lock with secret, or removes it if secret is nil, recording the change
+// in the audit log
+func (s *Service) updateAppLock(current string, secret *string) error {
+	var credential *appLockCredential
+	if secret != nil {
+		created, err := newAppLockCredential(*secret)
+		if err != nil {
+			return err
+		}
+		credential = &created
+	}
+
+	return s.ExecTx(func(tx *Service) error {
+		saved, err := tx.appLockCredential()
+		if err != nil {
+			return err
+		}
+		if saved == nil && credential == nil {
+			return invalidf("no app lock is set")
+		}
+		if saved != nil && !saved.matches(current) {
+			return invalidf("the current PIN or password is incorrect")
+		}
+
+		details := "App lock: removed"
+		if credential != nil {
+			if err := tx.settingsRepo.Set(settingAppLock, credential); err != nil {
+				return err
+			}
+			details = "App lock: set"
+			if saved != nil {
+				details = "App lock: changed"
+			}
+		} else if err := tx.settingsRepo.Delete(settingAppLock); err != nil {
+			return err
+		}
+
+		entityType := "setting"
+		_, err = tx.auditRepo.Create(models.AuditEntry{
+			Action:     models.AuditSettingsUpdated,
+			EntityType: &entityType,
+			Details:    details,
+		})
+		return err
+	})
+}
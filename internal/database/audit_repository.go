@@ -0,0 +1,98 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"sales-track/internal/models"
+)
+
+// auditEntryColumns is the column list selected for an audit entry, in the
+// order expected by scanAuditEntry
+const auditEntryColumns = "id, created_at, action, entity_type, entity_id, details"
+
+// AuditRepository handles database operations for the audit log
+type AuditRepository struct {
+	db *DB
+	q  queryer
+}
+
+// NewAuditRepository creates a new audit repository
+func NewAuditRepository(db *DB) *AuditRepository {
+	return &AuditRepository{db: db, q: db.conn}
+}
+
+// WithTx returns a copy of the repository whose operations run inside tx
+func (r *AuditRepository) WithTx(tx *sql.Tx) *AuditRepository {
+	return &AuditRepository{db: r.db, q: tx}
+}
+
+// scanAuditEntry scans a row selected with auditEntryColumns
+func scanAuditEntry(scanner rowScanner, entry *models.AuditEntry) error {
+	return scanner.Scan(
+		&entry.ID,
+		&entry.CreatedAt,
+		&entry.Action,
+		&entry.EntityType,
+		&entry.EntityID,
+		&entry.Details,
+	)
+}
+
+// Create appends an entry to the audit log
+func (r *AuditRepository) Create(entry models.AuditEntry) (*models.AuditEntry, error) {
+	query := `
+		INSERT INTO audit_log (action, entity_type, entity_id, details)
+		VALUES (?, ?, ?, ?)
+		RETURNING ` + auditEntryColumns
+
+	var created models.AuditEntry
+	err := scanAuditEntry(r.q.QueryRow(query, entry.Action, entry.EntityType, entry.EntityID, entry.Details), &created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audit entry: %w", err)
+	}
+
+	return &created, nil
+}
+
+// List retrieves the most recent audit entries, newest first
+func (r *AuditRepository) List(limit int) ([]models.AuditEntry, error) {
+	query := "SELECT " + auditEntryColumns + " FROM audit_log ORDER BY id DESC LIMIT ?"
+
+	rows, err := r.q.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	return scanAuditEntries(rows)
+}
+
+// ListForEntity retrieves the audit entries of one entity, oldest first
+func (r *AuditRepository) ListForEntity(entityType string, entityID int64) ([]models.AuditEntry, error) {
+	query := "SELECT " + auditEntryColumns + " FROM audit_log WHERE entity_type = ? AND entity_id = ? ORDER BY id"
+
+	rows, err := r.q.Query(query, entityType, entityID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	return scanAuditEntries(rows)
+}
+
+// scanAuditEntries scans and closes rows selected with auditEntryColumns
+func scanAuditEntries(rows *sql.Rows) ([]models.AuditEntry, error) {
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var entry models.AuditEntry
+		if err := scanAuditEntry(rows, &entry); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit log: %w", err)
+	}
+
+	return entries, nil
+}
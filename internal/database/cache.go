@@ -0,0 +1,92 @@
+package database
+
+import (
+	"container/list"
+	"sync"
+)
+
+// defaultCacheCapacity is the number of entries kept by a service's query cache
+const defaultCacheCapacity = 64
+
+// queryCache is a small LRU cache for expensive whole-table queries such as
+// database statistics. Every write invalidates it by bumping the generation,
+// and a value loaded before an invalidation is never stored, so a slow reader
+// cannot put stale results back after a concurrent write.
+type queryCache struct {
+	mu         sync.Mutex
+	capacity   int
+	generation uint64
+	entries    map[string]*list.Element
+	order      *list.List // Front is most recently used
+}
+
+// cacheEntry is a key/value pair stored in queryCache.order
+type cacheEntry struct {
+	key   string
+	value interface{}
+}
+
+// newQueryCache creates an empty cache holding at most capacity entries
+func newQueryCache(capacity int) *queryCache {
+	return &queryCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// get returns the cached value for key and the current generation, which must
+// be passed to put when storing a freshly loaded value
+func (c *queryCache) get(key string) (interface{}, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		return elem.Value.(*cacheEntry).value, c.generation, true
+	}
+	return nil, c.generation, false
+}
+
+// put stores value under key unless the cache was invalidated since generation
+// was obtained from get
+func (c *queryCache) put(key string, value interface{}, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheEntry).value = value
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, value: value})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// currentGeneration returns the number of invalidations so far, which
+// changes whenever data the cache holds may have changed
+func (c *queryCache) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// invalidate drops every entry
+func (c *queryCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
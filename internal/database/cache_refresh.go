@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// DefaultImportRefreshDelay is how soon after an import the cached
+	// reports are refreshed
+	DefaultImportRefreshDelay = 2 * time.Second
+
+	// DefaultIdleRefreshDelay is how long the database must go without
+	// other writes before the cached reports are refreshed
+	DefaultIdleRefreshDelay = 30 * time.Second
+)
+
+// ReportsRefreshedEvent is the payload of reports.refreshed, emitted once
+// the cached reports hold fresh data and views showing them can reload
+type ReportsRefreshedEvent struct {
+	At time.Time `json:"at"`
+}
+
+// RefreshReports loads the reports the dashboard opens with into the cache,
+// and rebuilds the pre-aggregated sales in report performance mode, so the
+// first view after a write does not wait for whole-table scans
+func (s *Service) RefreshReports(now time.Time) error {
+	if _, err := s.GetDatabaseStats(); err != nil {
+		return err
+	}
+	if _, err := s.GetRecentRecordCount(30); err != nil {
+		return err
+	}
+	if _, err := s.GetDashboardKPIs("", now); err != nil {
+		return err
+	}
+	if _, err := s.GetTrailingTwelveMonths("", now); err != nil {
+		return err
+	}
+	// In report performance mode the pivot and performance reports read the
+	// pre-aggregated sales, which are rebuilt here rather than when opened
+	status, err := s.GetReportPerformanceStatus()
+	if err != nil {
+		return err
+	}
+	if status.Active {
+		return s.refreshReportAggregates(s.beginWrite)
+	}
+	return nil
+}
+
+// CacheRefresher refreshes the cached reports in the background after the
+// writes that invalidate them: shortly after an import, and otherwise once
+// writes have stopped for a while, so edits in quick succession cause one
+// refresh. It emits reports.refreshed when done.
+type CacheRefresher struct {
+	service     *Service
+	importDelay time.Duration
+	idleDelay   time.Duration
+	report      func(err error)
+	changes     chan string
+}
+
+// NewCacheRefresher creates a refresher watching service's change feed. A
+// failed refresh is passed to report, which may be nil.
+func NewCacheRefresher(service *Service, report func(err error)) *CacheRefresher {
+	r := &CacheRefresher{
+		service:     service,
+		importDelay: DefaultImportRefreshDelay,
+		idleDelay:   DefaultIdleRefreshDelay,
+		report:      report,
+		changes:     make(chan string, 16),
+	}
+	service.feed.watch(func(name string) {
+		switch name {
+		case EventReportsRefreshed, EventMaintenanceProgress:
+			return
+		}
+		select {
+		case r.changes <- name:
+		default:
+			// A refresh is already due
+		}
+	})
+	return r
+}
+
+// SetDelays changes how long after an import and after other writes the
+// reports are refreshed. It must be called before Run.
+func (r *CacheRefresher) SetDelays(importDelay, idleDelay time.Duration) {
+	if importDelay > 0 {
+		r.importDelay = importDelay
+	}
+	if idleDelay > 0 {
+		r.idleDelay = idleDelay
+	}
+}
+
+// Run refreshes the reports after each change until ctx is done. While a
+// write is being saved the refresh waits, since the app is not idle.
+func (r *CacheRefresher) Run(ctx context.Context) {
+	timer := time.NewTimer(r.idleDelay)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case name := <-r.changes:
+			delay := r.idleDelay
+			if name == EventImportCompleted {
+				delay = r.importDelay
+			}
+			timer.Stop()
+			timer.Reset(delay)
+		case <-timer.C:
+			if r.service.WriteQueueStatus().Busy {
+				timer.Reset(r.idleDelay)
+				continue
+			}
+			r.refresh()
+		}
+	}
+}
+
+// refresh reloads the reports and tells listeners fresher data is available
+func (r *CacheRefresher) refresh() {
+	now := time.Now()
+	if err := r.service.RefreshReports(now); err != nil {
+		if r.report != nil {
+			r.report(err)
+		}
+		return
+	}
+	r.service.feed.emit(EventReportsRefreshed, ReportsRefreshedEvent{At: now})
+}
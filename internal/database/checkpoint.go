@@ -0,0 +1,120 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// WAL checkpoint modes used by CheckpointWAL
+const (
+	CheckpointPassive  = "PASSIVE"  // Copies what it can without waiting for readers or writers
+	CheckpointTruncate = "TRUNCATE" // Waits for writers, copies everything and empties the WAL file
+)
+
+const (
+	// DefaultCheckpointInterval is how often the maintenance scheduler
+	// checkpoints the WAL
+	DefaultCheckpointInterval = 5 * time.Minute
+
+	// walTruncateSize is the WAL size from which a scheduled checkpoint
+	// truncates the file rather than only copying it back, since SQLite
+	// reuses the WAL file in place without ever shrinking it
+	walTruncateSize = 16 << 20 // 16MB
+)
+
+// CheckpointResult is the outcome of a WAL checkpoint
+type CheckpointResult struct {
+	Mode          string    `json:"mode"` // CheckpointPassive or CheckpointTruncate
+	At            time.Time `json:"at"`
+	Busy          bool      `json:"busy"`            // Readers or writers kept the checkpoint from finishing
+	LogFrames     int64     `json:"log_frames"`      // Frames in the WAL
+	CopiedFrames  int64     `json:"copied_frames"`   // Frames copied back into the database file
+	WALSizeBefore int64     `json:"wal_size_before"` // Bytes
+	WALSize       int64     `json:"wal_size"`        // Bytes, after the checkpoint
+	Error         string    `json:"error,omitempty"`
+}
+
+// WALSize returns the size in bytes of the write-ahead log file, 0 if there
+// is none, as for an in-memory database
+func (db *DB) WALSize() (int64, error) {
+	if db.filePath == ":memory:" {
+		return 0, nil
+	}
+	info, err := os.Stat(db.filePath + "-wal")
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read write-ahead log size: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// Checkpoint copies the WAL back into the database file in mode, one of
+// CheckpointPassive or CheckpointTruncate
+func (db *DB) Checkpoint(mode string) (*CheckpointResult, error) {
+	if mode != CheckpointPassive && mode != CheckpointTruncate {
+		return nil, invalidf("unknown checkpoint mode %q", mode)
+	}
+
+	result := &CheckpointResult{Mode: mode, At: time.Now()}
+	size, err := db.WALSize()
+	if err != nil {
+		return nil, err
+	}
+	result.WALSizeBefore = size
+
+	var busy int
+	if err := db.conn.QueryRow("PRAGMA wal_checkpoint("+mode+")").Scan(&busy, &result.LogFrames, &result.CopiedFrames); err != nil {
+		return nil, fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+	result.Busy = busy != 0
+
+	if result.WALSize, err = db.WALSize(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CheckpointWAL runs the scheduled WAL checkpoint: passive, so it never
+// holds up the app, or truncating once the WAL has reached walTruncateSize.
+// It is skipped, returning nil, for in-memory databases and while a
+// compaction or backup runs. The outcome is kept for the health report.
+func (s *Service) CheckpointWAL() (*CheckpointResult, error) {
+	if s.db.filePath == ":memory:" {
+		return nil, nil
+	}
+	if !s.maintenance.writes.TryRLock() {
+		return nil, nil
+	}
+	defer s.maintenance.writes.RUnlock()
+
+	mode := CheckpointPassive
+	size, err := s.db.WALSize()
+	if err == nil && size >= walTruncateSize {
+		mode = CheckpointTruncate
+	}
+	result, err := s.db.Checkpoint(mode)
+	if err != nil {
+		result = &CheckpointResult{Mode: mode, At: time.Now(), Error: err.Error()}
+	}
+
+	s.maintenance.mu.Lock()
+	s.maintenance.checkpoint = result
+	s.maintenance.mu.Unlock()
+	return result, err
+}
+
+// LastCheckpoint returns the outcome of the latest scheduled checkpoint, or
+// nil if none has run
+func (s *Service) LastCheckpoint() *CheckpointResult {
+	s.maintenance.mu.Lock()
+	defer s.maintenance.mu.Unlock()
+
+	if s.maintenance.checkpoint == nil {
+		return nil
+	}
+	result := *s.maintenance.checkpoint
+	return &result
+}
@@ -0,0 +1,315 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// commissionRuleColumns is the column list selected for a commission rule, in
+// the order expected by scanCommissionRule
+const commissionRuleColumns = "id, store, effective_from, rate, created_at"
+
+// CommissionRepository handles database operations for commission rules and
+// the reconciliation of reported commissions against them
+type CommissionRepository struct {
+	db *DB
+	q  queryer
+}
+
+// NewCommissionRepository creates a new commission repository
+func NewCommissionRepository(db *DB) *CommissionRepository {
+	return &CommissionRepository{db: db, q: db.conn}
+}
+
+// WithTx returns a copy of the repository whose operations run inside tx
+func (r *CommissionRepository) WithTx(tx *sql.Tx) *CommissionRepository {
+	return &CommissionRepository{db: r.db, q: tx}
+}
+
+// scanCommissionRule scans a row selected with commissionRuleColumns
+func scanCommissionRule(scanner rowScanner, rule *models.CommissionRule) error {
+	return scanner.Scan(
+		&rule.ID,
+		&rule.Store,
+		&rule.EffectiveFrom,
+		&rule.Rate,
+		&rule.CreatedAt,
+	)
+}
+
+// Save stores a rule, replacing any existing rule for the same store and date
+func (r *CommissionRepository) Save(rule models.CreateCommissionRuleRequest) (*models.CommissionRule, error) {
+	date, err := time.Parse("2006-01-02", rule.EffectiveFrom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date format: %w", err)
+	}
+
+	query := `
+		INSERT INTO commission_rules (store, effective_from, rate)
+		VALUES (?, ?, ?)
+		ON CONFLICT(store, effective_from) DO UPDATE SET
+			rate = excluded.rate
+		RETURNING ` + commissionRuleColumns
+
+	var saved models.CommissionRule
+	if err := scanCommissionRule(r.q.QueryRow(query, rule.Store, date, rule.Rate), &saved); err != nil {
+		return nil, fmt.Errorf("failed to save commission rule: %w", err)
+	}
+
+	return &saved, nil
+}
+
+// List retrieves the commission rules, optionally for one store, ordered by
+// store and newest first
+func (r *CommissionRepository) List(store *string) ([]models.CommissionRule, error) {
+	query := "SELECT " + commissionRuleColumns + " FROM commission_rules"
+	var args []interface{}
+	if store != nil {
+		query += " WHERE store = ?"
+		args = append(args, *store)
+	}
+	query += " ORDER BY store, effective_from DESC"
+
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query commission rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []models.CommissionRule
+	for rows.Next() {
+		var rule models.CommissionRule
+		if err := scanCommissionRule(rows, &rule); err != nil {
+			return nil, fmt.Errorf("failed to scan commission rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating commission rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// Delete removes a commission rule
+func (r *CommissionRepository) Delete(id int64) error {
+	result, err := r.q.Exec("DELETE FROM commission_rules WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete commission rule: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("commission rule with ID %d %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+// Reconcile compares the commission reported for each sale matching filter
+// with its sale price times the rate of its store's rule in effect on the
+// sale date. Sales are totalled per store and month, and those differing by
+// more than the filter's tolerance are listed as discrepancies.
+func (r *CommissionRepository) Reconcile(filter models.ReconciliationFilter) (*models.CommissionReconciliation, error) {
+	tolerance := filter.Tolerance
+	if tolerance <= 0 {
+		tolerance = models.DefaultCommissionTolerance
+	}
+
+	var whereParts []string
+	var args []interface{}
+	if filter.Store != nil {
+		whereParts = append(whereParts, "s.store = ?")
+		args = append(args, *filter.Store)
+	}
+	if filter.DateFrom != nil {
+		whereParts = append(whereParts, "s.date >= ?")
+		args = append(args, *filter.DateFrom)
+	}
+	if filter.DateTo != nil {
+		whereParts = append(whereParts, "s.date <= ?")
+		args = append(args, *filter.DateTo)
+	}
+
+	query := `
+		SELECT
+			s.id, s.store, s.vendor, s.date, s.description, s.is_return, s.sale_price, s.commission,
+			(
+				SELECT cr.rate FROM commission_rules cr
+				WHERE cr.store = s.store AND cr.effective_from <= s.date
+				ORDER BY cr.effective_from DESC
+				LIMIT 1
+			) AS rate
+		FROM sales_records s`
+	if len(whereParts) > 0 {
+		query += " WHERE " + strings.Join(whereParts, " AND ")
+	}
+	query += " ORDER BY s.store, s.date, s.id"
+
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query commissions: %w", err)
+	}
+	defer rows.Close()
+
+	result := &models.CommissionReconciliation{
+		Stores:        []models.StoreReconciliation{},
+		Discrepancies: []models.CommissionDiscrepancy{},
+	}
+	var current *models.StoreReconciliation
+	for rows.Next() {
+		var line models.CommissionDiscrepancy
+		var commission, rate sql.NullFloat64
+		err := rows.Scan(
+			&line.SalesRecordID,
+			&line.Store,
+			&line.Vendor,
+			&line.Date,
+			&line.Description,
+			&line.IsReturn,
+			&line.SalePrice,
+			&commission,
+			&rate,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan commission: %w", err)
+		}
+
+		month := line.Date.Format("2006-01")
+		if current == nil || current.Store != line.Store || current.Month != month {
+			result.Stores = append(result.Stores, models.StoreReconciliation{Store: line.Store, Month: month})
+			current = &result.Stores[len(result.Stores)-1]
+		}
+		if !commission.Valid || !rate.Valid {
+			current.UncheckedItems++
+			continue
+		}
+
+		line.Rate = rate.Float64
+		line.Reported = commission.Float64
+		line.Expected = roundCents(line.SalePrice * line.Rate)
+		line.Difference = roundCents(line.Reported - line.Expected)
+
+		sign := 1.0
+		if line.IsReturn {
+			sign = -1
+		}
+		current.CheckedItems++
+		current.Reported = roundCents(current.Reported + sign*line.Reported)
+		current.Expected = roundCents(current.Expected + sign*line.Expected)
+		current.Difference = roundCents(current.Reported - current.Expected)
+
+		if math.Abs(line.Difference) > tolerance+1e-9 {
+			current.Discrepancies++
+			result.Discrepancies = append(result.Discrepancies, line)
+			// A return refunding too much commission under-charges
+			if charged := sign * line.Difference; charged > 0 {
+				result.Overcharged = roundCents(result.Overcharged + charged)
+			} else {
+				result.Undercharged = roundCents(result.Undercharged - charged)
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating commissions: %w", err)
+	}
+
+	return result, nil
+}
+
+// CheckRemaining compares the remaining amount of each sale matching filter
+// with its sale price less commission, taking the commission from the rule of
+// the sale's store in effect on the sale date when none was reported. Sales
+// without a remaining amount, or differing by more than tolerance, are
+// listed, oldest first.
+func (r *CommissionRepository) CheckRemaining(filter models.SalesRecordFilter, tolerance float64) (*models.RemainingBackfillResult, error) {
+	whereClause, args := buildFilterWhere(filter)
+	query := `
+		SELECT
+			id, store, vendor, date, description, sale_price, commission, remaining,
+			(
+				SELECT cr.rate FROM commission_rules cr
+				WHERE cr.store = sales_records.store AND cr.effective_from <= sales_records.date
+				ORDER BY cr.effective_from DESC
+				LIMIT 1
+			) AS rate
+		FROM sales_records ` + whereClause + `
+		ORDER BY date, id`
+
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query remaining amounts: %w", err)
+	}
+	defer rows.Close()
+
+	result := &models.RemainingBackfillResult{Discrepancies: []models.RemainingDiscrepancy{}}
+	for rows.Next() {
+		var line models.RemainingDiscrepancy
+		var commission, remaining, rate sql.NullFloat64
+		err := rows.Scan(
+			&line.SalesRecordID,
+			&line.Store,
+			&line.Vendor,
+			&line.Date,
+			&line.Description,
+			&line.SalePrice,
+			&commission,
+			&remaining,
+			&rate,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan remaining amount: %w", err)
+		}
+
+		switch {
+		case commission.Valid:
+			line.Commission = commission.Float64
+		case rate.Valid:
+			line.Rate = &rate.Float64
+			line.Commission = roundCents(line.SalePrice * rate.Float64)
+		default:
+			result.Unchecked++
+			continue
+		}
+		// A commission above the sale price leaves no remaining amount to
+		// store, as amounts cannot be negative
+		line.Expected = roundCents(line.SalePrice - line.Commission)
+		if line.Expected < 0 {
+			result.Unchecked++
+			continue
+		}
+		result.Checked++
+
+		if remaining.Valid {
+			difference := roundCents(remaining.Float64 - line.Expected)
+			if math.Abs(difference) <= tolerance+1e-9 {
+				continue
+			}
+			line.Stored = &remaining.Float64
+			line.Difference = &difference
+		}
+		result.Discrepancies = append(result.Discrepancies, line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating remaining amounts: %w", err)
+	}
+
+	return result, nil
+}
+
+// roundCents rounds an amount to two decimal places
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,391 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"sales-track/internal/models"
+)
+
+// Maintenance operations reported in MaintenanceProgress
+const (
+	MaintenanceCompact = "compact"
+	MaintenanceBackup  = "backup"
+)
+
+// ErrMaintenanceInProgress is returned for writes attempted while a compaction
+// or backup runs, and when another maintenance operation is already running
+var ErrMaintenanceInProgress = errors.New("database maintenance in progress")
+
+const (
+	// backupStepPages is the number of pages copied per backup step; progress
+	// is published after each step
+	backupStepPages = 256
+
+	// compactionPollInterval is how often compaction progress is estimated
+	compactionPollInterval = 250 * time.Millisecond
+
+	// walFrameHeaderSize is the size of the header before each page in the WAL
+	walFrameHeaderSize = 24
+)
+
+// MaintenanceProgress is the state of a compaction or backup. It is the
+// payload of the maintenance.* events.
+type MaintenanceProgress struct {
+	Operation  string    `json:"operation"` // MaintenanceCompact, MaintenanceBackup or MaintenanceWorkspaceExport
+	StartedAt  time.Time `json:"started_at"`
+	PagesDone  int64     `json:"pages_done"`
+	PagesTotal int64     `json:"pages_total"` // Estimated for compaction
+	Percent    float64   `json:"percent"`
+	Done       bool      `json:"done"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// maintenanceLock keeps writes out while a maintenance operation runs. It is
+// shared by a service and its transaction-bound copies.
+type maintenanceLock struct {
+	writes     sync.RWMutex // Held for reading by writes and for writing by maintenance
+	mu         sync.Mutex
+	progress   *MaintenanceProgress // Latest operation; nil if none has run
+	checkpoint *CheckpointResult    // Latest scheduled WAL checkpoint; nil if none has run
+}
+
+// beginWrite admits a write unless maintenance is running, then waits for
+// its turn in the write queue. The returned function must be called when the
+// write has finished. Services bound to a transaction were admitted when the
+// transaction began.
+func (s *Service) beginWrite() (func(), error) {
+	return s.admitWrite(s.writes.acquire)
+}
+
+// tryBeginWrite is beginWrite without waiting: it fails at once with
+// ErrWriteBusy while another write holds the database, for writes that can
+// be skipped
+func (s *Service) tryBeginWrite() (func(), error) {
+	return s.admitWrite(s.writes.tryAcquire)
+}
+
+// admitWrite admits a write unless maintenance is running and takes its turn
+// in the write queue with acquire
+func (s *Service) admitWrite(acquire func() (func(), error)) (func(), error) {
+	if s.tx != nil {
+		return func() {}, nil
+	}
+	if !s.maintenance.writes.TryRLock() {
+		return nil, s.maintenanceError()
+	}
+	done, err := acquire()
+	if err != nil {
+		s.maintenance.writes.RUnlock()
+		return nil, err
+	}
+	return func() {
+		done()
+		s.maintenance.writes.RUnlock()
+	}, nil
+}
+
+// maintenanceError describes the maintenance operation that blocks a write
+func (s *Service) maintenanceError() error {
+	status := s.MaintenanceStatus()
+	if status == nil || status.Done {
+		return ErrMaintenanceInProgress
+	}
+	return fmt.Errorf("%w: %s running since %s", ErrMaintenanceInProgress, status.Operation, status.StartedAt.Format("15:04:05"))
+}
+
+// MaintenanceStatus returns the progress of the running maintenance operation,
+// or of the last one if none is running. It returns nil if none has run.
+func (s *Service) MaintenanceStatus() *MaintenanceProgress {
+	s.maintenance.mu.Lock()
+	defer s.maintenance.mu.Unlock()
+
+	if s.maintenance.progress == nil {
+		return nil
+	}
+	status := *s.maintenance.progress
+	return &status
+}
+
+// Compact rebuilds the database file to reclaim the space left by deleted
+// rows (VACUUM). Writes fail with ErrMaintenanceInProgress until it finishes,
+// and progress is published as maintenance.progress events.
+func (s *Service) Compact() error {
+	if err := s.claimMaintenance(MaintenanceCompact); err != nil {
+		return err
+	}
+	return s.runMaintenance(s.compact)
+}
+
+// StartCompaction runs Compact in the background. It returns once the
+// compaction has been claimed; completion is published as a
+// maintenance.completed event and recorded as a notification.
+func (s *Service) StartCompaction() error {
+	if err := s.claimMaintenance(MaintenanceCompact); err != nil {
+		return err
+	}
+	go func() {
+		err := s.runMaintenance(s.compact)
+		s.notifyOutcome(models.NotificationSourceCompaction, "Compaction finished", "Compaction failed", "The database was compacted.", err)
+	}()
+	return nil
+}
+
+// Backup copies the database to destPath with SQLite's online backup, which
+// keeps the copy consistent. Writes fail with ErrMaintenanceInProgress until
+// it finishes, and progress is published as maintenance.progress events.
+func (s *Service) Backup(destPath string) error {
+	if err := s.validateBackupPath(destPath); err != nil {
+		return err
+	}
+	if err := s.claimMaintenance(MaintenanceBackup); err != nil {
+		return err
+	}
+	return s.runMaintenance(func() error { return s.backup(destPath) })
+}
+
+// StartBackup runs Backup in the background. It returns once the backup has
+// been claimed; completion is published as a maintenance.completed event and
+// recorded as a notification.
+func (s *Service) StartBackup(destPath string) error {
+	if err := s.validateBackupPath(destPath); err != nil {
+		return err
+	}
+	if err := s.claimMaintenance(MaintenanceBackup); err != nil {
+		return err
+	}
+	go func() {
+		err := s.runMaintenance(func() error { return s.backup(destPath) })
+		s.notifyOutcome(models.NotificationSourceBackup, "Backup finished", "Backup failed", "The database was backed up to "+destPath+".", err)
+	}()
+	return nil
+}
+
+// claimMaintenance records operation as running, failing if another
+// maintenance operation already is
+func (s *Service) claimMaintenance(operation string) error {
+	if s.tx != nil {
+		return fmt.Errorf("%s cannot run inside a transaction", operation)
+	}
+
+	s.maintenance.mu.Lock()
+	defer s.maintenance.mu.Unlock()
+
+	if current := s.maintenance.progress; current != nil && !current.Done {
+		return fmt.Errorf("%w: %s running since %s", ErrMaintenanceInProgress, current.Operation, current.StartedAt.Format("15:04:05"))
+	}
+	s.maintenance.progress = &MaintenanceProgress{Operation: operation, StartedAt: time.Now()}
+	return nil
+}
+
+// runMaintenance waits for running writes to finish, keeps new writes out
+// while operation runs, and publishes its outcome
+func (s *Service) runMaintenance(operation func() error) error {
+	s.maintenance.writes.Lock()
+	err := operation()
+	s.maintenance.writes.Unlock()
+
+	s.maintenance.mu.Lock()
+	s.maintenance.progress.Done = true
+	if err != nil {
+		s.maintenance.progress.Error = err.Error()
+	}
+	status := *s.maintenance.progress
+	s.maintenance.mu.Unlock()
+
+	s.invalidateCache()
+	s.feed.emit(EventMaintenanceCompleted, status)
+	return err
+}
+
+// reportProgress records and publishes the progress of the running operation
+func (s *Service) reportProgress(done, total int64) {
+	if done > total {
+		done = total
+	}
+
+	s.maintenance.mu.Lock()
+	progress := s.maintenance.progress
+	progress.PagesDone = done
+	progress.PagesTotal = total
+	if total > 0 {
+		progress.Percent = float64(done) * 100 / float64(total)
+	}
+	status := *progress
+	s.maintenance.mu.Unlock()
+
+	s.feed.emit(EventMaintenanceProgress, status)
+}
+
+// compact runs VACUUM. SQLite reports no progress for it, but in WAL mode the
+// rebuilt database is written to the WAL first, so the WAL size measured
+// against the pages in use gives an estimate.
+func (s *Service) compact() error {
+	var pageSize, pageCount, freePages int64
+	if err := s.db.conn.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		return fmt.Errorf("failed to get page size: %w", err)
+	}
+	if err := s.db.conn.QueryRow("PRAGMA page_count").Scan(&pageCount); err != nil {
+		return fmt.Errorf("failed to get page count: %w", err)
+	}
+	if err := s.db.conn.QueryRow("PRAGMA freelist_count").Scan(&freePages); err != nil {
+		return fmt.Errorf("failed to get free page count: %w", err)
+	}
+	total := pageCount - freePages
+
+	// Start from an empty WAL so its size only reflects the rebuild
+	if err := s.checkpoint(); err != nil {
+		return err
+	}
+	s.reportProgress(0, total)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	if s.db.filePath != ":memory:" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.pollCompaction(s.db.filePath+"-wal", pageSize, total, stop)
+		}()
+	}
+
+	_, err := s.db.conn.Exec("VACUUM")
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		return fmt.Errorf("failed to compact database: %w", err)
+	}
+
+	// Move the rebuilt pages into the database file and shrink the WAL again
+	if err := s.checkpoint(); err != nil {
+		return err
+	}
+	s.reportProgress(total, total)
+	return nil
+}
+
+// pollCompaction publishes the number of pages written to the WAL until stop is closed
+func (s *Service) pollCompaction(walPath string, pageSize, total int64, stop <-chan struct{}) {
+	ticker := time.NewTicker(compactionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if info, err := os.Stat(walPath); err == nil {
+				s.reportProgress(info.Size()/(pageSize+walFrameHeaderSize), total)
+			}
+		}
+	}
+}
+
+// checkpoint copies the WAL into the database file and truncates it
+func (s *Service) checkpoint() error {
+	if _, err := s.db.conn.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+	return nil
+}
+
+// validateBackupPath checks that a backup would not overwrite the database itself
+func (s *Service) validateBackupPath(destPath string) error {
+	if destPath == "" {
+		return invalidf("backup path is required")
+	}
+	if s.db.filePath == ":memory:" {
+		return nil
+	}
+
+	dest, err := filepath.Abs(destPath)
+	if err != nil {
+		return fmt.Errorf("invalid backup path: %w", err)
+	}
+	source, err := filepath.Abs(s.db.filePath)
+	if err != nil {
+		return fmt.Errorf("invalid database path: %w", err)
+	}
+	if dest == source {
+		return invalidf("backup path must differ from the database path")
+	}
+	return nil
+}
+
+// backupRecord is saved under settingLastBackup after each successful backup
+type backupRecord struct {
+	Path string    `json:"path"`
+	At   time.Time `json:"at"`
+}
+
+// backup copies the database to destPath and records the backup for the
+// health report
+func (s *Service) backup(destPath string) error {
+	if err := s.copyDatabase(destPath); err != nil {
+		return err
+	}
+
+	path, err := filepath.Abs(destPath)
+	if err != nil {
+		path = destPath
+	}
+	return s.settingsRepo.Set(settingLastBackup, backupRecord{Path: path, At: time.Now().UTC()})
+}
+
+// copyDatabase copies the database to destPath in steps of backupStepPages pages
+func (s *Service) copyDatabase(destPath string) error {
+	dest, err := sql.Open(driverName, destPath)
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer dest.Close()
+
+	ctx := context.Background()
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer destConn.Close()
+
+	srcConn, err := s.db.conn.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destDriver interface{}) error {
+		return srcConn.Raw(func(srcDriver interface{}) error {
+			backup, err := destDriver.(*sqlite3.SQLiteConn).Backup("main", sqliteConn(srcDriver), "main")
+			if err != nil {
+				return fmt.Errorf("failed to start backup: %w", err)
+			}
+
+			for {
+				done, err := backup.Step(backupStepPages)
+				if err != nil {
+					backup.Finish()
+					return fmt.Errorf("failed to copy database: %w", err)
+				}
+
+				total := int64(backup.PageCount())
+				s.reportProgress(total-int64(backup.Remaining()), total)
+				if done {
+					break
+				}
+			}
+
+			if err := backup.Finish(); err != nil {
+				return fmt.Errorf("failed to finish backup: %w", err)
+			}
+			return nil
+		})
+	})
+}
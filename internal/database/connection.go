@@ -1,18 +1,60 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3" // SQLite driver
+	"github.com/mattn/go-sqlite3"
 )
 
+// driverName is the SQLite driver registered with per-connection settings
+const driverName = "sqlite3_sales_track"
+
+// connectionPragmas are applied to every connection the pool opens. They are
+// per-connection settings, so running them once on the pool would only
+// configure whichever connection happened to execute them.
+var connectionPragmas = []string{
+	"PRAGMA foreign_keys = ON",
+	"PRAGMA busy_timeout = 5000", // Wait for concurrent writers instead of failing with SQLITE_BUSY
+	"PRAGMA synchronous = NORMAL",
+	"PRAGMA cache_size = -64000", // 64MB cache
+	"PRAGMA temp_store = MEMORY",
+}
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{ConnectHook: configureConnection})
+}
+
+// configureConnection applies connectionPragmas to a newly opened connection
+func configureConnection(conn *sqlite3.SQLiteConn) error {
+	for _, pragma := range connectionPragmas {
+		if _, err := conn.Exec(pragma, nil); err != nil {
+			return fmt.Errorf("failed to apply %q: %w", pragma, err)
+		}
+	}
+	return nil
+}
+
 // DB represents the database connection and configuration
 type DB struct {
 	conn     *sql.DB
 	filePath string
+	metrics  *queryMetrics
+	timeouts *queryTimeouts
+}
+
+// queryer is the subset of *sql.DB and *sql.Tx used by repositories, so the
+// same repository code can run against the connection pool or a transaction
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
 }
 
 // Config represents database configuration options
@@ -20,6 +62,9 @@ type Config struct {
 	FilePath    string // Path to SQLite database file
 	InMemory    bool   // Use in-memory database for testing
 	AutoMigrate bool   // Automatically run migrations on startup
+
+	// Queries taking longer are logged; DefaultSlowQueryThreshold when zero
+	SlowQueryThreshold time.Duration
 }
 
 // New creates a new database connection with the given configuration
@@ -46,14 +91,27 @@ func New(config Config) (*DB, error) {
 			}
 		}
 
-		dsn = config.FilePath
+		// Take the write lock when a transaction begins, so two concurrent
+		// transactions cannot both read and then deadlock upgrading to write
+		dsn = config.FilePath + "?_txlock=immediate"
 		filePath = config.FilePath
 	}
 
-	// Open database connection
-	conn, err := sql.Open("sqlite3", dsn)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+	// Open database connection. Its connections time every query, and stop
+	// queries that run past their timeouts once SetQueryTimeouts is called.
+	metrics := newQueryMetrics(config.SlowQueryThreshold)
+	timeouts := &queryTimeouts{}
+	conn := sql.OpenDB(&timedConnector{
+		dsn:      dsn,
+		driver:   &sqlite3.SQLiteDriver{ConnectHook: configureConnection},
+		metrics:  metrics,
+		timeouts: timeouts,
+	})
+
+	if config.InMemory {
+		// Every connection to ":memory:" is a separate, empty database, so
+		// concurrent callers must share a single connection
+		conn.SetMaxOpenConns(1)
 	}
 
 	// Test the connection
@@ -62,17 +120,30 @@ func New(config Config) (*DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
+	db := &DB{
+		conn:     conn,
+		filePath: filePath,
+		metrics:  metrics,
+		timeouts: timeouts,
+	}
+
+	// Refuse databases migrated by a newer build before touching them
+	compatibility, err := db.CheckSchemaCompatibility()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to check schema version: %w", err)
+	}
+	if err := compatibility.Err(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
 	// Configure SQLite settings
 	if err := configureSQLite(conn); err != nil {
 		conn.Close()
 		return nil, fmt.Errorf("failed to configure SQLite: %w", err)
 	}
 
-	db := &DB{
-		conn:     conn,
-		filePath: filePath,
-	}
-
 	// Run migrations if requested
 	if config.AutoMigrate {
 		if err := db.Migrate(); err != nil {
@@ -84,33 +155,14 @@ func New(config Config) (*DB, error) {
 	return db, nil
 }
 
-// configureSQLite sets up SQLite-specific configuration
+// configureSQLite sets up database-wide SQLite configuration.
+// Per-connection settings are applied by configureConnection.
 func configureSQLite(conn *sql.DB) error {
-	// Enable foreign key constraints
-	if _, err := conn.Exec("PRAGMA foreign_keys = ON"); err != nil {
-		return fmt.Errorf("failed to enable foreign keys: %w", err)
-	}
-
-	// Set journal mode to WAL for better concurrency
+	// Set journal mode to WAL for better concurrency (persisted in the file)
 	if _, err := conn.Exec("PRAGMA journal_mode = WAL"); err != nil {
 		return fmt.Errorf("failed to set journal mode: %w", err)
 	}
 
-	// Set synchronous mode to NORMAL for better performance
-	if _, err := conn.Exec("PRAGMA synchronous = NORMAL"); err != nil {
-		return fmt.Errorf("failed to set synchronous mode: %w", err)
-	}
-
-	// Set cache size (negative value means KB, positive means pages)
-	if _, err := conn.Exec("PRAGMA cache_size = -64000"); err != nil { // 64MB cache
-		return fmt.Errorf("failed to set cache size: %w", err)
-	}
-
-	// Set temp store to memory for better performance
-	if _, err := conn.Exec("PRAGMA temp_store = MEMORY"); err != nil {
-		return fmt.Errorf("failed to set temp store: %w", err)
-	}
-
 	return nil
 }
 
@@ -177,6 +229,15 @@ func (db *DB) ExecTx(fn func(*sql.Tx) error) error {
 	return nil
 }
 
+// restoreConn runs statement to give conn back the setting every connection
+// has. If that fails the connection is discarded instead of going back to the
+// pool with the setting changed.
+func restoreConn(conn *sql.Conn, statement string) {
+	if _, err := conn.ExecContext(context.Background(), statement); err != nil {
+		conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	}
+}
+
 // IsHealthy checks if the database connection is healthy
 func (db *DB) IsHealthy() bool {
 	if db.conn == nil {
@@ -0,0 +1,202 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"sales-track/internal/models"
+)
+
+// Limits of queries run from the SQL console
+const (
+	MaxQueryRows = 1000            // Rows returned; the result is marked truncated past it
+	QueryTimeout = 5 * time.Second // Queries running longer are interrupted
+)
+
+// ReadOnlyQueryResult holds the columns and rows returned by a read-only query
+type ReadOnlyQueryResult struct {
+	Columns   []string        `json:"columns"`
+	Rows      [][]interface{} `json:"rows"`
+	Truncated bool            `json:"truncated"` // More rows matched than were returned
+	Duration  models.Duration `json:"duration"`
+}
+
+// readOnlyKeywords are the statements the console accepts. A WITH clause may
+// still lead to a write, which the query_only connection refuses.
+var readOnlyKeywords = map[string]bool{"select": true, "with": true, "values": true}
+
+// RunReadOnlyQuery runs a single SELECT statement with params on a
+// connection set to refuse writes, returning at most MaxQueryRows rows. ctx
+// bounds how long the query may run.
+func (db *DB) RunReadOnlyQuery(ctx context.Context, query string, params []interface{}) (*ReadOnlyQueryResult, error) {
+	query, err := checkReadOnlyStatement(query)
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	// query_only makes SQLite itself refuse any write on this connection,
+	// whatever the statement turns out to do
+	if _, err := conn.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+		return nil, fmt.Errorf("failed to make connection read-only: %w", err)
+	}
+	defer restoreConn(conn, "PRAGMA query_only = OFF")
+
+	rows, err := conn.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	result := &ReadOnlyQueryResult{Columns: columns, Rows: [][]interface{}{}}
+	for rows.Next() {
+		if len(result.Rows) == MaxQueryRows {
+			result.Truncated = true
+			break
+		}
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		for i, value := range values {
+			values[i] = consoleValue(value)
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, queryError(ctx, err)
+	}
+
+	result.Duration = models.Duration(time.Since(started))
+	return result, nil
+}
+
+// queryError describes a failed query, naming the time limit when the query
+// was interrupted for running too long
+func queryError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("query took longer than %s and was stopped", QueryTimeout)
+	}
+	return fmt.Errorf("failed to run query: %w", err)
+}
+
+// consoleValue converts a scanned value for display. Text is returned as a
+// string and binary data by its size.
+func consoleValue(value interface{}) interface{} {
+	if data, ok := value.([]byte); ok {
+		if utf8.Valid(data) {
+			return string(data)
+		}
+		return fmt.Sprintf("<%d bytes>", len(data))
+	}
+	return value
+}
+
+// checkReadOnlyStatement checks that query is a single statement starting
+// with a read-only keyword and returns it without a trailing semicolon.
+// Semicolons inside string literals, quoted names and comments are ignored.
+func checkReadOnlyStatement(query string) (string, error) {
+	end := len(query)
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			if j := strings.IndexByte(query[i+1:], closing); j >= 0 {
+				i += j + 1
+			} else {
+				i = len(query)
+			}
+		case strings.HasPrefix(query[i:], "--"):
+			if j := strings.IndexByte(query[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(query)
+			}
+		case strings.HasPrefix(query[i:], "/*"):
+			if j := strings.Index(query[i+2:], "*/"); j >= 0 {
+				i += j + 3
+			} else {
+				i = len(query)
+			}
+		case c == ';':
+			if end != len(query) {
+				return "", invalidf("only one statement can be run at a time")
+			}
+			end = i
+		default:
+			if end != len(query) && !isSpace(c) {
+				return "", invalidf("only one statement can be run at a time")
+			}
+		}
+	}
+	query = strings.TrimSpace(query[:end])
+
+	if !readOnlyKeywords[strings.ToLower(firstKeyword(query))] {
+		return "", invalidf("only SELECT statements can be run")
+	}
+	return query, nil
+}
+
+// firstKeyword returns the first word of a statement, after any comments
+func firstKeyword(query string) string {
+	for {
+		query = strings.TrimSpace(query)
+		switch {
+		case strings.HasPrefix(query, "--"):
+			if j := strings.IndexByte(query, '\n'); j >= 0 {
+				query = query[j:]
+				continue
+			}
+			return ""
+		case strings.HasPrefix(query, "/*"):
+			if j := strings.Index(query, "*/"); j >= 0 {
+				query = query[j+2:]
+				continue
+			}
+			return ""
+		}
+		word := strings.FieldsFunc(query, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+		})
+		if len(word) == 0 || !strings.HasPrefix(query, word[0]) {
+			return ""
+		}
+		return word[0]
+	}
+}
+
+// isSpace reports whether c is an ASCII space character
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+// RunReadOnlyQuery runs a single SELECT statement against the database with
+// writes refused, at most QueryTimeout long and returning at most
+// MaxQueryRows rows
+func (s *Service) RunReadOnlyQuery(query string, params []interface{}) (*ReadOnlyQueryResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), QueryTimeout)
+	defer cancel()
+	return s.db.RunReadOnlyQuery(ctx, query, params)
+}
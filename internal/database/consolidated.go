@@ -0,0 +1,163 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sales-track/internal/models"
+)
+
+// GetConsolidatedReport summarizes the sales of the open database and of the
+// other businesses' database files by month, labelled by business and
+// combined, for a household view of businesses kept apart. The other files
+// are attached read-only for the length of the report and must be at the
+// schema version of the open database; opening a file in the app upgrades it.
+func (s *Service) GetConsolidatedReport(req models.ConsolidatedReportRequest) (*models.ConsolidatedReport, error) {
+	if err := s.validateConsolidatedReport(&req); err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	conn, err := s.db.conn.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	// Attached databases belong to the connection, so each is detached before
+	// the connection returns to the pool
+	schemas := []string{"main"}
+	defer func() {
+		for _, schema := range schemas[1:] {
+			conn.ExecContext(context.Background(), "DETACH DATABASE "+schema)
+		}
+	}()
+	for i, database := range req.Databases {
+		schema := fmt.Sprintf("business_%d", i+1)
+		if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS "+schema, "file:"+database.Path+"?mode=ro"); err != nil {
+			return nil, fmt.Errorf("failed to attach %s: %w", database.Path, err)
+		}
+		schemas = append(schemas, schema)
+	}
+
+	report := &models.ConsolidatedReport{Businesses: []string{req.Label}}
+	for _, database := range req.Databases {
+		report.Businesses = append(report.Businesses, database.Label)
+	}
+
+	var lines []string
+	var args []interface{}
+	for i, schema := range schemas {
+		lines = append(lines, fmt.Sprintf(`
+			SELECT ? as business, %d as position, date, store, vendor, sale_price, commission, remaining, is_return, 1 as is_item
+			FROM %s.sales_records`, i, schema))
+		args = append(args, report.Businesses[i])
+	}
+	where := ""
+	if req.Year != nil {
+		where = " WHERE strftime('%Y', date) = ?"
+		args = append(args, *req.Year)
+	}
+
+	query := func(byBusiness bool) ([]models.MonthlySummary, error) {
+		selectBusiness, groupBusiness, orderBusiness := "", "", ""
+		if byBusiness {
+			selectBusiness = "\n\t\t\tbusiness as dimension,"
+			groupBusiness = ", position, business"
+			orderBusiness = ", position"
+		}
+		rows, err := conn.QueryContext(ctx, `
+		WITH report_lines AS (`+strings.Join(lines, "\n\t\t\tUNION ALL")+`
+		)
+		SELECT
+			strftime('%Y', date) as year,
+			strftime('%m', date) as month,
+			strftime('%Y-%m', date) as year_month,`+selectBusiness+reportSummaryColumns+`
+		FROM report_lines`+where+`
+		GROUP BY strftime('%Y-%m', date)`+groupBusiness+`
+		ORDER BY year DESC, month DESC`+orderBusiness, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query consolidated summary: %w", err)
+		}
+		defer rows.Close()
+		return scanMonthlySummaries(rows, byBusiness)
+	}
+
+	if report.ByBusiness, err = query(true); err != nil {
+		return nil, err
+	}
+	if report.Combined, err = query(false); err != nil {
+		return nil, err
+	}
+	if report.ByBusiness == nil {
+		report.ByBusiness = []models.MonthlySummary{}
+	}
+	if report.Combined == nil {
+		report.Combined = []models.MonthlySummary{}
+	}
+	return report, nil
+}
+
+// validateConsolidatedReport checks the labels and files of a consolidated
+// report, trimming the labels, and that each file can be reported on
+func (s *Service) validateConsolidatedReport(req *models.ConsolidatedReportRequest) error {
+	if len(req.Databases) == 0 {
+		return invalidf("at least one other database is required")
+	}
+	if len(req.Databases) > models.MaxConsolidatedDatabases {
+		return invalidf("at most %d other databases can be included", models.MaxConsolidatedDatabases)
+	}
+
+	req.Label = strings.TrimSpace(req.Label)
+	if req.Label == "" {
+		return invalidf("a label for the open database is required")
+	}
+	labels := map[string]bool{strings.ToLower(req.Label): true}
+	paths := map[string]bool{}
+	if s.db.filePath != ":memory:" {
+		if open, err := filepath.Abs(s.db.filePath); err == nil {
+			paths[open] = true
+		}
+	}
+
+	supported, err := s.db.supportedSchemaVersion()
+	if err != nil {
+		return err
+	}
+	for i := range req.Databases {
+		database := &req.Databases[i]
+		database.Label = strings.TrimSpace(database.Label)
+		if database.Label == "" {
+			return invalidf("database %d needs a label", i+1)
+		}
+		if labels[strings.ToLower(database.Label)] {
+			return invalidf("label %q is used more than once", database.Label)
+		}
+		labels[strings.ToLower(database.Label)] = true
+
+		path, err := filepath.Abs(database.Path)
+		if database.Path == "" || err != nil {
+			return invalidf("database %q needs a file path", database.Label)
+		}
+		if paths[path] {
+			return invalidf("database file %s is included more than once", database.Path)
+		}
+		paths[path] = true
+		if _, err := os.Stat(path); err != nil {
+			return invalidf("database file %s of %q was not found", database.Path, database.Label)
+		}
+
+		compatibility, err := InspectSchema(path)
+		if err != nil {
+			return fmt.Errorf("failed to check the schema of %s: %w", database.Path, err)
+		}
+		if compatibility.AppliedVersion != supported {
+			return invalidf("database %q has schema version %d, not %d; open it in the app to upgrade it", database.Label, compatibility.AppliedVersion, supported)
+		}
+		database.Path = path
+	}
+	return nil
+}
@@ -1,10 +1,22 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"sales-track/internal/models"
+	"sales-track/internal/synthetic"
 )
 
 // TestDatabaseConnection tests basic database connection and configuration
@@ -82,8 +94,8 @@ func TestSalesRepository(t *testing.T) {
 		Date:        "2024-01-15",
 		Description: "Test Product",
 		SalePrice:   100.50,
-		Commission:  10.05,
-		Remaining:   90.45,
+		Commission:  floatPtr(10.05),
+		Remaining:   floatPtr(90.45),
 	}
 
 	created, err := repo.Create(createReq)
@@ -148,8 +160,13 @@ func TestSalesRepository(t *testing.T) {
 
 	// Verify deletion
 	_, err = repo.GetByID(created.ID)
-	if err == nil {
-		t.Error("Expected error when getting deleted record")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound when getting deleted record, got %v", err)
+	}
+
+	// Invalid requests are rejected before reaching the database
+	if err := validateSalesRecord(models.CreateSalesRecordRequest{Store: "Test Store"}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected ErrValidation for an incomplete record, got %v", err)
 	}
 }
 
@@ -177,8 +194,8 @@ func TestSalesRepositoryBatch(t *testing.T) {
 			Date:        "2024-01-15",
 			Description: "Product A",
 			SalePrice:   100.00,
-			Commission:  10.00,
-			Remaining:   90.00,
+			Commission:  floatPtr(10.00),
+			Remaining:   floatPtr(90.00),
 		},
 		{
 			Store:       "Store B",
@@ -186,8 +203,8 @@ func TestSalesRepositoryBatch(t *testing.T) {
 			Date:        "2024-01-16",
 			Description: "Product B",
 			SalePrice:   200.00,
-			Commission:  20.00,
-			Remaining:   180.00,
+			Commission:  floatPtr(20.00),
+			Remaining:   floatPtr(180.00),
 		},
 	}
 
@@ -214,344 +231,5336 @@ func TestSalesRepositoryBatch(t *testing.T) {
 	}
 }
 
-// TestReportingRepository tests reporting and analytics operations
-func TestReportingRepository(t *testing.T) {
-	// Setup test database with sample data
-	config := Config{
-		InMemory:    true,
-		AutoMigrate: true,
-	}
-
-	db, err := New(config)
+// TestSalesRepositoryBatchChunking tests batches that span several insert chunks
+func TestSalesRepositoryBatchChunking(t *testing.T) {
+	db, err := New(Config{InMemory: true, AutoMigrate: true})
 	if err != nil {
 		t.Fatalf("Failed to create database: %v", err)
 	}
 	defer db.Close()
 
-	salesRepo := NewSalesRepository(db)
-	reportingRepo := NewReportingRepository(db)
+	repo := NewSalesRepository(db)
 
-	// Insert test data
-	testRecords := []models.CreateSalesRecordRequest{
-		{
+	count := batchInsertChunkSize*2 + 3
+	records := make([]models.CreateSalesRecordRequest, count)
+	for i := range records {
+		records[i] = models.CreateSalesRecordRequest{
 			Store:       "Store A",
 			Vendor:      "Vendor 1",
 			Date:        "2024-01-15",
-			Description: "Product A",
-			SalePrice:   100.00,
-			Commission:  10.00,
-			Remaining:   90.00,
-		},
-		{
-			Store:       "Store A",
-			Vendor:      "Vendor 2",
-			Date:        "2024-02-15",
-			Description: "Product B",
-			SalePrice:   200.00,
-			Commission:  20.00,
-			Remaining:   180.00,
-		},
-		{
-			Store:       "Store B",
-			Vendor:      "Vendor 1",
-			Date:        "2024-01-20",
-			Description: "Product C",
-			SalePrice:   150.00,
-			Commission:  15.00,
-			Remaining:   135.00,
-		},
+			Description: fmt.Sprintf("Product %d", i),
+			SalePrice:   float64(i),
+		}
 	}
 
-	_, err = salesRepo.CreateBatch(testRecords)
+	created, err := repo.CreateBatch(records)
 	if err != nil {
-		t.Fatalf("Failed to create test records: %v", err)
+		t.Fatalf("Failed to create batch records: %v", err)
 	}
 
-	// Test yearly summary
-	yearly, err := reportingRepo.GetYearlySummary()
-	if err != nil {
-		t.Fatalf("Failed to get yearly summary: %v", err)
+	if len(created) != count {
+		t.Fatalf("Expected %d created records, got %d", count, len(created))
 	}
-
-	if len(yearly) != 1 {
-		t.Errorf("Expected 1 year, got %d", len(yearly))
+	for i, record := range created {
+		if record.Description != records[i].Description {
+			t.Fatalf("Record %d out of order: got %q", i, record.Description)
+		}
+		if i > 0 && record.ID != created[i-1].ID+1 {
+			t.Fatalf("Expected consecutive IDs, got %d after %d", record.ID, created[i-1].ID)
+		}
 	}
-	if yearly[0].Year != "2024" {
-		t.Errorf("Expected year 2024, got %s", yearly[0].Year)
+}
+
+// TestSalesRepositoryBatchOrderWithReimport tests that a chunk mixing a
+// re-imported record with new ones returns the records in input order, though
+// the re-imported one keeps its earlier ID
+func TestSalesRepositoryBatchOrderWithReimport(t *testing.T) {
+	db, err := New(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
 	}
-	if yearly[0].ItemsSold != 3 {
-		t.Errorf("Expected 3 items sold, got %d", yearly[0].ItemsSold)
+	defer db.Close()
+
+	repo := NewSalesRepository(db)
+
+	externalID := func(id string) *string { return &id }
+	first, err := repo.CreateBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-10", Description: "Lamp", SalePrice: 40.00, ExternalID: externalID("T-1")},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create batch records: %v", err)
 	}
 
-	// Test monthly summary
-	monthly, err := reportingRepo.GetMonthlySummary(nil)
+	records := []models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-11", Description: "Chair", SalePrice: 100.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-12", Description: "Rug", SalePrice: 30.00, ExternalID: externalID("T-2")},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-10", Description: "Lamp (repriced)", SalePrice: 35.00, ExternalID: externalID("T-1")},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-13", Description: "Desk", SalePrice: 500.00},
+	}
+	created, err := repo.CreateBatch(records)
 	if err != nil {
-		t.Fatalf("Failed to get monthly summary: %v", err)
+		t.Fatalf("Failed to create batch records: %v", err)
 	}
 
-	if len(monthly) != 2 {
-		t.Errorf("Expected 2 months, got %d", len(monthly))
+	if len(created) != len(records) {
+		t.Fatalf("Expected %d records, got %d", len(records), len(created))
 	}
+	for i, record := range created {
+		if record.Description != records[i].Description {
+			t.Errorf("Record %d out of order: got %q, want %q", i, record.Description, records[i].Description)
+		}
+	}
+	if created[2].ID != first[0].ID {
+		t.Errorf("Expected the re-imported record to keep ID %d, got %d", first[0].ID, created[2].ID)
+	}
+}
 
-	// Test store performance
-	storePerf, err := reportingRepo.GetStorePerformance()
+// TestExternalIDReimport tests that re-importing records with the same source
+// transaction id updates them instead of creating duplicates
+func TestExternalIDReimport(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
 	if err != nil {
-		t.Fatalf("Failed to get store performance: %v", err)
+		t.Fatalf("Failed to create service: %v", err)
 	}
+	defer service.Close()
 
-	if len(storePerf) != 2 {
-		t.Errorf("Expected 2 stores, got %d", len(storePerf))
+	id := func(s string) *string { return &s }
+	first := []models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Lamp", SalePrice: 10.00, ExternalID: id("TX-1")},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Chair", SalePrice: 20.00, ExternalID: id("TX-2")},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "No id", SalePrice: 5.00},
 	}
-
-	// Test vendor performance
-	vendorPerf, err := reportingRepo.GetVendorPerformance()
+	created, err := service.CreateSalesRecordsBatch(first)
 	if err != nil {
-		t.Fatalf("Failed to get vendor performance: %v", err)
+		t.Fatalf("First import failed: %v", err)
 	}
 
-	if len(vendorPerf) != 2 {
-		t.Errorf("Expected 2 vendors, got %d", len(vendorPerf))
+	// Overlapping report: TX-2 corrected, TX-3 new, TX-1 in another store is a different sale
+	second := []models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Chair", SalePrice: 25.00, ExternalID: id("TX-2")},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-16", Description: "Table", SalePrice: 30.00, ExternalID: id("TX-3")},
+		{Store: "Store B", Vendor: "Vendor 1", Date: "2024-01-16", Description: "Rug", SalePrice: 40.00, ExternalID: id("TX-1")},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "No id", SalePrice: 5.00},
+	}
+	var completed []ImportCompletedEvent
+	service.SetEventEmitter(func(name string, payload interface{}) {
+		if name == EventImportCompleted {
+			completed = append(completed, payload.(ImportCompletedEvent))
+		}
+	})
+	if _, err := service.CreateSalesRecordsBatch(second); err != nil {
+		t.Fatalf("Second import failed: %v", err)
+	}
+	// Only TX-2 was imported before
+	if want := []ImportCompletedEvent{{Inserted: 3, Updated: 1}}; !reflect.DeepEqual(completed, want) {
+		t.Errorf("Expected %+v announced, got %+v", want, completed)
 	}
 
-	// Test pivot table data
-	pivotData, err := reportingRepo.GetPivotTableData(nil)
+	// Streaming them again inserts only the record without an id and the new
+	// TX-9; a record repeated within a chunk updates the copy written before it
+	completed = nil
+	vase := models.CreateSalesRecordRequest{Store: "Store C", Vendor: "Vendor 1", Date: "2024-01-17", Description: "Vase", SalePrice: 8.00, ExternalID: id("TX-9")}
+	again := append(append([]models.CreateSalesRecordRequest{}, second...), second[0], vase, vase)
+	records := make(chan models.CreateSalesRecordRequest, len(again))
+	for _, record := range again {
+		records <- record
+	}
+	close(records)
+	var progress [2]int
+	written, err := service.CreateSalesRecordsStream(records, 0, func(inserted, updated int) {
+		progress = [2]int{inserted, updated}
+	})
+	if err != nil || written != 7 {
+		t.Fatalf("Expected 7 records written, got %d, %v", written, err)
+	}
+	if progress != [2]int{2, 5} {
+		t.Errorf("Expected 2 inserted and 5 updated, got %v", progress)
+	}
+	if want := []ImportCompletedEvent{{Inserted: 2, Updated: 5}}; !reflect.DeepEqual(completed, want) {
+		t.Errorf("Expected %+v announced, got %+v", want, completed)
+	}
+
+	// Single-record creates follow the same rule
+	updated, err := service.CreateSalesRecord(models.CreateSalesRecordRequest{
+		Store: "Store A", Vendor: "Vendor 2", Date: "2024-01-15", Description: "Lamp", SalePrice: 12.00, ExternalID: id("TX-1"),
+	})
 	if err != nil {
-		t.Fatalf("Failed to get pivot table data: %v", err)
+		t.Fatalf("CreateSalesRecord failed: %v", err)
+	}
+	if updated.ID != created[0].ID || updated.Vendor != "Vendor 2" {
+		t.Errorf("Expected TX-1 to update record %d, got record %d with vendor %q", created[0].ID, updated.ID, updated.Vendor)
 	}
 
-	if len(pivotData.YearlyData) != 1 {
-		t.Errorf("Expected 1 year in pivot data, got %d", len(pivotData.YearlyData))
+	stats, err := service.GetDatabaseStats()
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
 	}
-	if len(pivotData.MonthlyData) != 2 {
-		t.Errorf("Expected 2 months in pivot data, got %d", len(pivotData.MonthlyData))
+	// TX-1, TX-2, TX-3 in Store A, TX-1 in Store B, TX-9 in Store C and three
+	// records without ids
+	if stats.TotalRecords != 8 {
+		t.Errorf("Expected 8 records, got %d", stats.TotalRecords)
 	}
-}
 
-// TestDatabaseService tests the high-level service layer
-func TestDatabaseService(t *testing.T) {
-	config := Config{
-		InMemory:    true,
-		AutoMigrate: true,
+	chair, err := service.GetSalesRecord(created[1].ID)
+	if err != nil {
+		t.Fatalf("Failed to get record: %v", err)
 	}
+	if chair.SalePrice != 25.00 || chair.ExternalID == nil || *chair.ExternalID != "TX-2" {
+		t.Errorf("Expected TX-2 updated to 25.00, got %.2f (%v)", chair.SalePrice, chair.ExternalID)
+	}
+}
 
-	service, err := NewService(config)
+// TestUpsertSalesRecords tests insert/update/unchanged counting keyed on external ids
+func TestUpsertSalesRecords(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
 	if err != nil {
 		t.Fatalf("Failed to create service: %v", err)
 	}
 	defer service.Close()
 
-	// Test health check
-	if err := service.Health(); err != nil {
-		t.Errorf("Service should be healthy: %v", err)
+	id := func(s string) *string { return &s }
+	amount := func(f float64) *float64 { return &f }
+	first := []models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Lamp", SalePrice: 10.00, Commission: amount(2.00), ExternalID: id("TX-1")},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Chair", SalePrice: 20.00, ExternalID: id("TX-2")},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "No id", SalePrice: 5.00},
 	}
 
-	// Test import functionality
-	records := []models.CreateSalesRecordRequest{
-		{
-			Store:       "Test Store",
-			Vendor:      "Test Vendor",
-			Date:        "2024-01-15",
-			Description: "Test Product",
-			SalePrice:   100.00,
-			Commission:  10.00,
-			Remaining:   90.00,
-		},
+	result, err := service.UpsertSalesRecords(first)
+	if err != nil {
+		t.Fatalf("First upsert failed: %v", err)
+	}
+	if *result != (models.UpsertResult{Inserted: 3}) {
+		t.Errorf("Expected 3 inserted, got %+v", *result)
 	}
 
-	result, err := service.ImportSalesData(records)
+	second := []models.CreateSalesRecordRequest{
+		first[0], // Unchanged
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Chair", SalePrice: 20.00, Commission: amount(4.00), ExternalID: id("TX-2")},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-16", Description: "Table", SalePrice: 30.00, ExternalID: id("TX-3")},
+		first[2], // No id, inserted again
+	}
+	result, err = service.UpsertSalesRecords(second)
 	if err != nil {
-		t.Fatalf("Failed to import sales data: %v", err)
+		t.Fatalf("Second upsert failed: %v", err)
+	}
+	if *result != (models.UpsertResult{Inserted: 2, Updated: 1, Unchanged: 1}) {
+		t.Errorf("Expected 2 inserted, 1 updated, 1 unchanged, got %+v", *result)
 	}
 
-	if result.TotalRecords != 1 {
-		t.Errorf("Expected 1 total record, got %d", result.TotalRecords)
+	store := "Store A"
+	list, err := service.ListSalesRecords(models.SalesRecordFilter{Store: &store})
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
 	}
-	if result.SuccessfulRecords != 1 {
-		t.Errorf("Expected 1 successful record, got %d", result.SuccessfulRecords)
+	if list.Total != 5 {
+		t.Errorf("Expected 5 records, got %d", list.Total)
 	}
-	if result.FailedRecords != 0 {
-		t.Errorf("Expected 0 failed records, got %d", result.FailedRecords)
+	for _, record := range list.Records {
+		if record.ExternalID != nil && *record.ExternalID == "TX-2" {
+			if record.Commission == nil || *record.Commission != 4.00 {
+				t.Errorf("Expected TX-2 commission updated to 4.00, got %v", record.Commission)
+			}
+		}
 	}
 
-	// Test database stats
+	// An invalid record rolls back the whole upsert
+	bad := []models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-17", Description: "Desk", SalePrice: 50.00, ExternalID: id("TX-4")},
+		{Store: "Store A", Vendor: "", Date: "2024-01-17", Description: "Bad", SalePrice: 1.00, ExternalID: id("TX-5")},
+	}
+	if _, err := service.UpsertSalesRecords(bad); err == nil {
+		t.Error("Expected upsert with an invalid record to fail")
+	}
 	stats, err := service.GetDatabaseStats()
 	if err != nil {
-		t.Fatalf("Failed to get database stats: %v", err)
+		t.Fatalf("Failed to get stats: %v", err)
 	}
-
-	if stats.TotalRecords != 1 {
-		t.Errorf("Expected 1 total record, got %d", stats.TotalRecords)
+	if stats.TotalRecords != 5 {
+		t.Errorf("Expected failed upsert to leave 5 records, got %d", stats.TotalRecords)
 	}
 }
 
-// TestMigrations tests the migration system
-func TestMigrations(t *testing.T) {
-	config := Config{
-		InMemory:    true,
-		AutoMigrate: false, // Don't auto-migrate
-	}
-
-	db, err := New(config)
+// TestServiceChangeEvents tests that change-feed events follow committed writes only
+func TestRecordMetadata(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
 	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
+		t.Fatalf("Failed to create service: %v", err)
 	}
-	defer db.Close()
+	defer service.Close()
 
-	// Test manual migration
-	err = db.Migrate()
+	id := func(s string) *string { return &s }
+	created, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Lamp", SalePrice: 10.00, ExternalID: id("TX-1"),
+			Metadata: models.Metadata{"Item #": "A-1", "Payout Date": "2024-02-01"}},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-16", Description: "Chair", SalePrice: 20.00,
+			Metadata: models.Metadata{"Item #": "A-2"}},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-17", Description: "Rug", SalePrice: 30.00},
+	})
 	if err != nil {
-		t.Fatalf("Failed to run migrations: %v", err)
+		t.Fatalf("CreateSalesRecordsBatch failed: %v", err)
+	}
+	if created[0].Metadata["Item #"] != "A-1" || created[2].Metadata != nil {
+		t.Errorf("Expected metadata to round-trip, got %v and %v", created[0].Metadata, created[2].Metadata)
 	}
 
-	// Test migration status
-	status, err := db.GetMigrationStatus()
+	// Filters match custom fields by name, including names with spaces and punctuation
+	list, err := service.ListSalesRecords(models.SalesRecordFilter{Metadata: map[string]string{"Item #": "A-2"}})
 	if err != nil {
-		t.Fatalf("Failed to get migration status: %v", err)
+		t.Fatalf("ListSalesRecords failed: %v", err)
 	}
-
-	if len(status) == 0 {
-		t.Error("Expected at least one migration")
+	if list.Total != 1 || list.Records[0].ID != created[1].ID {
+		t.Errorf("Expected only record %d to have Item # A-2, got %+v", created[1].ID, list.Records)
 	}
 
-	// Check that the first migration was applied
-	if !status[0].Applied {
-		t.Error("First migration should be applied")
+	// Quotes and backslashes in a filtered name neither break nor widen the query
+	if _, err := service.UpdateSalesRecord(created[2].ID, models.UpdateSalesRecordRequest{Metadata: models.Metadata{`Shelf\Bin`: "7"}}); err != nil {
+		t.Fatalf("UpdateSalesRecord failed: %v", err)
+	}
+	for key, want := range map[string]int64{`Shelf\Bin`: 1, `Item #" OR "1`: 0, `Item #\`: 0} {
+		list, err := service.ListSalesRecords(models.SalesRecordFilter{Metadata: map[string]string{key: "7"}})
+		if err != nil || list.Total != want {
+			t.Errorf("Expected %d records with %q set, got %+v, %v", want, key, list, err)
+		}
 	}
-}
 
-// TestTransactions tests transaction handling
-func TestTransactions(t *testing.T) {
-	config := Config{
-		InMemory:    true,
-		AutoMigrate: true,
+	// Updates replace the whole set, and an empty set clears it
+	updated, err := service.UpdateSalesRecord(created[1].ID, models.UpdateSalesRecordRequest{Metadata: models.Metadata{"Bin": "7"}})
+	if err != nil {
+		t.Fatalf("UpdateSalesRecord failed: %v", err)
+	}
+	if !updated.Metadata.Equal(models.Metadata{"Bin": "7"}) {
+		t.Errorf("Expected metadata to be replaced, got %v", updated.Metadata)
+	}
+	cleared, err := service.UpdateSalesRecord(created[1].ID, models.UpdateSalesRecordRequest{Metadata: models.Metadata{}})
+	if err != nil {
+		t.Fatalf("UpdateSalesRecord failed: %v", err)
+	}
+	if cleared.Metadata != nil {
+		t.Errorf("Expected metadata to be cleared, got %v", cleared.Metadata)
 	}
 
-	db, err := New(config)
+	// Re-importing with changed custom fields counts as an update
+	result, err := service.UpsertSalesRecords([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Lamp", SalePrice: 10.00, ExternalID: id("TX-1"),
+			Metadata: models.Metadata{"Item #": "A-1", "Payout Date": "2024-02-08"}},
+	})
 	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
+		t.Fatalf("UpsertSalesRecords failed: %v", err)
+	}
+	if result.Updated != 1 {
+		t.Errorf("Expected a changed custom field to update the record, got %+v", result)
 	}
-	defer db.Close()
 
-	repo := NewSalesRepository(db)
+	// Deleted records keep their custom fields in the trash
+	if err := service.DeleteSalesRecord(created[0].ID); err != nil {
+		t.Fatalf("DeleteSalesRecord failed: %v", err)
+	}
+	var trashed string
+	if err := service.GetDB().conn.QueryRow("SELECT metadata FROM deleted_sales_records WHERE id = ?", created[0].ID).Scan(&trashed); err != nil {
+		t.Fatalf("Failed to read trashed metadata: %v", err)
+	}
+	if !strings.Contains(trashed, "2024-02-08") {
+		t.Errorf("Expected trashed record to keep its metadata, got %s", trashed)
+	}
 
-	// Test successful transaction
-	err = db.ExecTx(func(tx *sql.Tx) error {
-		// Create record using the repository (which uses the main connection)
-		// In a real transaction scenario, you'd pass the tx to repository methods
-		// For this test, we'll just verify the transaction mechanism works
-		return nil
+	_, err = service.CreateSalesRecord(models.CreateSalesRecordRequest{
+		Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Lamp", SalePrice: 10.00,
+		Metadata: models.Metadata{`Say "hi"`: "x"},
 	})
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for a quoted field name, got %v", err)
+	}
+}
 
+func TestServiceChangeEvents(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
 	if err != nil {
-		t.Fatalf("Transaction should succeed: %v", err)
+		t.Fatalf("Failed to create service: %v", err)
 	}
+	defer service.Close()
 
-	// Test creating a record normally (outside transaction)
-	_, err = repo.Create(models.CreateSalesRecordRequest{
-		Store:       "TX Test Store",
-		Vendor:      "TX Test Vendor",
-		Date:        "2024-01-15",
-		Description: "TX Test Product",
-		SalePrice:   100.00,
-		Commission:  10.00,
-		Remaining:   90.00,
+	var mu sync.Mutex
+	var events []string
+	service.SetEventEmitter(func(name string, payload interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, name)
 	})
+	takeEvents := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		taken := events
+		events = nil
+		return taken
+	}
+
+	record := models.CreateSalesRecordRequest{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Lamp", SalePrice: 10.00}
 
+	created, err := service.CreateSalesRecord(record)
 	if err != nil {
 		t.Fatalf("Failed to create record: %v", err)
 	}
-
-	// Verify record was created
-	filter := models.SalesRecordFilter{}
-	list, err := repo.List(filter)
-	if err != nil {
-		t.Fatalf("Failed to list records: %v", err)
+	price := 12.00
+	if _, err := service.UpdateSalesRecord(created.ID, models.UpdateSalesRecordRequest{SalePrice: &price}); err != nil {
+		t.Fatalf("Failed to update record: %v", err)
 	}
-
-	if list.Total != 1 {
-		t.Errorf("Expected 1 record after creation, got %d", list.Total)
+	if err := service.DeleteSalesRecord(created.ID); err != nil {
+		t.Fatalf("Failed to delete record: %v", err)
+	}
+	if _, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{record, record}); err != nil {
+		t.Fatalf("Failed to create batch: %v", err)
+	}
+	// Failed writes emit nothing
+	if err := service.DeleteSalesRecord(created.ID); err == nil {
+		t.Error("Expected deleting a missing record to fail")
 	}
-}
 
-// BenchmarkSalesRecordCreate benchmarks sales record creation
-func BenchmarkSalesRecordCreate(b *testing.B) {
-	config := Config{
-		InMemory:    true,
-		AutoMigrate: true,
+	expected := []string{EventRecordCreated, EventRecordUpdated, EventRecordDeleted, EventImportCompleted}
+	if got := takeEvents(); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected events %v, got %v", expected, got)
 	}
 
-	db, err := New(config)
+	// Events inside a transaction wait for the commit
+	err = service.ExecTx(func(txService *Service) error {
+		if _, err := txService.CreateSalesRecord(record); err != nil {
+			return err
+		}
+		if got := takeEvents(); len(got) != 0 {
+			t.Errorf("Expected no events before commit, got %v", got)
+		}
+		return nil
+	})
 	if err != nil {
-		b.Fatalf("Failed to create database: %v", err)
+		t.Fatalf("ExecTx failed: %v", err)
+	}
+	if got := takeEvents(); len(got) != 1 || got[0] != EventRecordCreated {
+		t.Errorf("Expected record.created after commit, got %v", got)
 	}
-	defer db.Close()
 
-	repo := NewSalesRepository(db)
+	// Rows imported one at a time are announced together
+	err = service.ForRowImport().ExecTx(func(txService *Service) error {
+		for i := 0; i < 2; i++ {
+			if _, err := txService.CreateSalesRecord(record); err != nil {
+				return err
+			}
+		}
+		txService.CompleteRowImport(ImportCompletedEvent{Inserted: 2})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExecTx failed: %v", err)
+	}
+	if got := takeEvents(); len(got) != 1 || got[0] != EventImportCompleted {
+		t.Errorf("Expected a single import.completed for a row import, got %v", got)
+	}
 
+	// Rolled-back and dry-run writes are never announced
+	_ = service.ExecTx(func(txService *Service) error {
+		if _, err := txService.CreateSalesRecord(record); err != nil {
+			return err
+		}
+		return fmt.Errorf("roll back")
+	})
+	_ = service.DryRun(func(txService *Service) error {
+		_, err := txService.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{record})
+		return err
+	})
+	if got := takeEvents(); len(got) != 0 {
+		t.Errorf("Expected no events for rolled-back writes, got %v", got)
+	}
+}
+
+// TestExchangeRatesAndConvertedReports tests rate storage and base-currency summaries
+func TestExchangeRatesAndConvertedReports(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	currency := func(s string) *string { return &s }
+	amount := func(f float64) *float64 { return &f }
+	records := []models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Home", SalePrice: 100.00},
+		{Store: "Store B", Vendor: "Vendor 1", Date: "2024-01-20", Description: "Pounds", SalePrice: 100.00, Commission: amount(10.00), Currency: currency("GBP")},
+		{Store: "Store C", Vendor: "Vendor 2", Date: "2024-01-15", Description: "Dollars", SalePrice: 50.00, Currency: currency("USD")},
+		{Store: "Store D", Vendor: "Vendor 2", Date: "2023-06-01", Description: "Euros", SalePrice: 10.00, Currency: currency("EUR")},
+	}
+	if _, err := service.CreateSalesRecordsBatch(records); err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	rates := []models.CreateExchangeRateRequest{
+		{Date: "2024-01-15", Currency: "gbp", Rate: 0.86},
+		{Date: "2024-01-15", Currency: "USD", Rate: 1.10},
+		{Date: "2024-01-19", Currency: "USD", Rate: 1.08},
+	}
+	if _, err := service.SaveExchangeRates(rates); err != nil {
+		t.Fatalf("Failed to save rates: %v", err)
+	}
+
+	// The 2023 EUR sale needs a USD rate that does not exist yet
+	_, err = service.GetYearlySummaryConverted("USD")
+	if err == nil || !strings.Contains(err.Error(), "no exchange rate for USD on or before 2023-06-01") {
+		t.Fatalf("Expected missing USD rate error, got %v", err)
+	}
+
+	// Saving the same currency and date replaces the rate
+	if _, err := service.SaveExchangeRate(models.CreateExchangeRateRequest{Date: "2023-06-01", Currency: "USD", Rate: 1.00}); err != nil {
+		t.Fatalf("Failed to save rate: %v", err)
+	}
+	saved, err := service.SaveExchangeRate(models.CreateExchangeRateRequest{Date: "2023-06-01", Currency: "USD", Rate: 1.07})
+	if err != nil {
+		t.Fatalf("Failed to replace rate: %v", err)
+	}
+	if saved.Source != "manual" || saved.Date.String() != "2023-06-01" {
+		t.Errorf("Unexpected saved rate: %+v", saved)
+	}
+
+	usd := "USD"
+	usdRates, err := service.ListExchangeRates(models.ExchangeRateFilter{Currency: &usd})
+	if err != nil {
+		t.Fatalf("Failed to list rates: %v", err)
+	}
+	if len(usdRates) != 3 || usdRates[2].Rate != 1.07 {
+		t.Errorf("Expected 3 USD rates ending with 1.07, got %+v", usdRates)
+	}
+
+	yearly, err := service.GetYearlySummaryConverted("usd")
+	if err != nil {
+		t.Fatalf("Failed to get converted yearly summary: %v", err)
+	}
+	if len(yearly) != 2 {
+		t.Fatalf("Expected 2 years, got %d", len(yearly))
+	}
+	// 100 in the base currency, 100 GBP at 1.08/0.86 (latest rates on or
+	// before a Saturday), and 50 USD unconverted
+	if yearly[0].Year != "2024" || yearly[0].TotalSales != 275.58 || yearly[0].TotalCommission != 12.56 {
+		t.Errorf("Unexpected 2024 summary: %+v", yearly[0])
+	}
+	// 10 EUR at the implicit EUR rate of 1
+	if yearly[1].Year != "2023" || yearly[1].TotalSales != 10.70 {
+		t.Errorf("Unexpected 2023 summary: %+v", yearly[1])
+	}
+
+	year := "2024"
+	monthly, err := service.GetMonthlySummaryConverted(&year, "USD")
+	if err != nil {
+		t.Fatalf("Failed to get converted monthly summary: %v", err)
+	}
+	if len(monthly) != 1 || monthly[0].YearMonth != "2024-01" || monthly[0].TotalSales != 275.58 {
+		t.Errorf("Unexpected converted monthly summary: %+v", monthly)
+	}
+
+	// Invalid input is rejected
+	if _, err := service.SaveExchangeRate(models.CreateExchangeRateRequest{Date: "2024-01-15", Currency: "EUR", Rate: 1}); err == nil {
+		t.Error("Expected a rate for the reference currency to be rejected")
+	}
+	if _, err := service.SaveExchangeRate(models.CreateExchangeRateRequest{Date: "2024-01-15", Currency: "GBP", Rate: 0}); err == nil {
+		t.Error("Expected a zero rate to be rejected")
+	}
+	if _, err := service.GetYearlySummaryConverted("dollars"); err == nil {
+		t.Error("Expected an invalid base currency to be rejected")
+	}
+
+	if err := service.DeleteExchangeRate(saved.ID); err != nil {
+		t.Fatalf("Failed to delete rate: %v", err)
+	}
+	if err := service.DeleteExchangeRate(saved.ID); err == nil {
+		t.Error("Expected deleting a missing rate to fail")
+	}
+}
+
+// TestReturnsReporting tests that returns are reported separately from sales
+func TestCommissionReconciliation(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	// Store A charges 30% until March, then 25%
+	for _, rule := range []models.CreateCommissionRuleRequest{
+		{Store: "Store A", EffectiveFrom: "2024-01-01", Rate: 0.30},
+		{Store: "Store A", EffectiveFrom: "2024-03-01", Rate: 0.25},
+	} {
+		if _, err := service.SaveCommissionRule(rule); err != nil {
+			t.Fatalf("SaveCommissionRule failed: %v", err)
+		}
+	}
+	if _, err := service.SaveCommissionRule(models.CreateCommissionRuleRequest{Store: "Store A", EffectiveFrom: "2024-01-01", Rate: 30}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a percentage to be rejected as a rate, got %v", err)
+	}
+
+	amount := func(f float64) *float64 { return &f }
+	created, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-02-10", Description: "Lamp", SalePrice: 100.00, Commission: amount(30.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-05", Description: "Chair", SalePrice: 100.00, Commission: amount(30.00)}, // Old rate after the change
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-06", Description: "Rug", SalePrice: 33.33, Commission: amount(8.33)},     // Rounding
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-07", Description: "Vase", SalePrice: 20.00},                              // No commission reported
+		{Store: "Store B", Vendor: "Vendor 1", Date: "2024-03-08", Description: "Desk", SalePrice: 50.00, Commission: amount(20.00)},   // No rule
+	})
+	if err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	result, err := service.ReconcileCommissions(models.ReconciliationFilter{})
+	if err != nil {
+		t.Fatalf("ReconcileCommissions failed: %v", err)
+	}
+	if len(result.Discrepancies) != 1 {
+		t.Fatalf("Expected 1 discrepancy, got %+v", result.Discrepancies)
+	}
+	d := result.Discrepancies[0]
+	if d.SalesRecordID != created[1].ID || d.Rate != 0.25 || d.Expected != 25.00 || d.Difference != 5.00 {
+		t.Errorf("Expected the chair to be over-charged by 5.00 at 25%%, got %+v", d)
+	}
+	if result.Overcharged != 5.00 || result.Undercharged != 0 {
+		t.Errorf("Expected 5.00 over-charged, got %v over and %v under", result.Overcharged, result.Undercharged)
+	}
+
+	expected := []models.StoreReconciliation{
+		{Store: "Store A", Month: "2024-02", CheckedItems: 1, Reported: 30.00, Expected: 30.00},
+		{Store: "Store A", Month: "2024-03", CheckedItems: 2, Reported: 38.33, Expected: 33.33, Difference: 5.00, Discrepancies: 1, UncheckedItems: 1},
+		{Store: "Store B", Month: "2024-03", UncheckedItems: 1},
+	}
+	if len(result.Stores) != len(expected) {
+		t.Fatalf("Expected %d store months, got %+v", len(expected), result.Stores)
+	}
+	for i, want := range expected {
+		if result.Stores[i] != want {
+			t.Errorf("Expected %+v, got %+v", want, result.Stores[i])
+		}
+	}
+
+	// A store filter limits the report
+	store := "Store B"
+	result, err = service.ReconcileCommissions(models.ReconciliationFilter{Store: &store})
+	if err != nil {
+		t.Fatalf("ReconcileCommissions failed: %v", err)
+	}
+	if len(result.Stores) != 1 || len(result.Discrepancies) != 0 {
+		t.Errorf("Expected only Store B without discrepancies, got %+v", result)
+	}
+
+	rules, err := service.ListCommissionRules(nil)
+	if err != nil || len(rules) != 2 || rules[0].EffectiveFrom.String() != "2024-03-01" {
+		t.Errorf("Expected 2 rules newest first, got %+v (%v)", rules, err)
+	}
+	if err := service.DeleteCommissionRule(rules[0].ID); err != nil {
+		t.Errorf("DeleteCommissionRule failed: %v", err)
+	}
+}
+
+func TestReturnsReporting(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	amount := func(f float64) *float64 { return &f }
+	records := []models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Lamp", SalePrice: 100.00, Commission: amount(10.00), Remaining: amount(90.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-16", Description: "Chair", SalePrice: 50.00, Commission: amount(5.00), Remaining: amount(45.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-20", Description: "Lamp", SalePrice: 100.00, Commission: amount(10.00), Remaining: amount(90.00), IsReturn: true},
+	}
+	created, err := service.CreateSalesRecordsBatch(records)
+	if err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+	if !created[2].IsReturn || created[2].SalePrice != 100.00 {
+		t.Errorf("Expected the return to be stored with a positive amount, got %+v", created[2])
+	}
+
+	yearly, err := service.GetYearlySummary()
+	if err != nil {
+		t.Fatalf("Failed to get yearly summary: %v", err)
+	}
+	if len(yearly) != 1 {
+		t.Fatalf("Expected 1 year, got %d", len(yearly))
+	}
+	y := yearly[0]
+	if y.ItemsSold != 2 || y.ReturnedItems != 1 || y.GrossSales != 150.00 || y.TotalReturns != 100.00 || y.TotalSales != 50.00 {
+		t.Errorf("Unexpected yearly sales/returns split: %+v", y)
+	}
+	if y.TotalCommission != 5.00 || y.TotalRemaining != 45.00 || y.CommissionRate != 0.10 {
+		t.Errorf("Expected net commission 5.00, remaining 45.00 and rate 0.10, got %+v", y)
+	}
+
+	stores, err := service.GetStorePerformance()
+	if err != nil {
+		t.Fatalf("Failed to get store performance: %v", err)
+	}
+	if len(stores) != 1 || stores[0].TotalItems != 2 || stores[0].TotalSales != 50.00 || stores[0].AvgSalePrice != 75.00 {
+		t.Errorf("Unexpected store performance: %+v", stores)
+	}
+
+	custom, err := service.GetCustomSummary("store", nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to get custom summary: %v", err)
+	}
+	if len(custom) != 1 || custom[0].GrossSales != 150.00 || custom[0].TotalReturns != 100.00 || custom[0].TotalSales != 50.00 {
+		t.Errorf("Unexpected custom summary: %+v", custom)
+	}
+
+	products, err := service.GetTopProducts(10)
+	if err != nil {
+		t.Fatalf("Failed to get top products: %v", err)
+	}
+	// The returned lamp nets to zero, so the chair leads
+	if len(products) != 2 || products[0].ProductKey != "chair" || products[1].ReturnedItems != 1 || products[1].TotalSales != 0 {
+		t.Errorf("Unexpected top products: %+v", products)
+	}
+
+	stats, err := service.GetDatabaseStats()
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if stats.TotalRecords != 3 || stats.GrossSales != 150.00 || stats.TotalReturns != 100.00 || stats.TotalSales != 50.00 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// A sale can be corrected into a return
+	isReturn := true
+	updated, err := service.UpdateSalesRecord(created[1].ID, models.UpdateSalesRecordRequest{IsReturn: &isReturn})
+	if err != nil {
+		t.Fatalf("Failed to update record: %v", err)
+	}
+	if !updated.IsReturn {
+		t.Error("Expected record to be marked as a return")
+	}
+}
+
+// TestCreateSalesRecordsBatchPartial tests that bad rows are reported without blocking good ones
+func TestSalesAdjustments(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	var events []string
+	service.SetEventEmitter(func(name string, payload interface{}) {
+		events = append(events, name)
+	})
+
+	amount := func(f float64) *float64 { return &f }
+	sale, err := service.CreateSalesRecord(models.CreateSalesRecordRequest{
+		Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Vase",
+		SalePrice: 100.00, Commission: amount(10.00), Remaining: amount(90.00),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create sale: %v", err)
+	}
+	refund, err := service.CreateSalesRecord(models.CreateSalesRecordRequest{
+		Store: "Store A", Vendor: "Vendor 1", Date: "2024-02-01", Description: "Bowl",
+		SalePrice: 20.00, IsReturn: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create return: %v", err)
+	}
+
+	invalid := []models.CreateSalesAdjustmentRequest{
+		{SalesRecordID: sale.ID, Date: "2024-03-05", Reason: "  ", SalePriceDelta: -10},
+		{SalesRecordID: sale.ID, Date: "2024-03-05", Reason: "No change"},
+		{SalesRecordID: 9999, Date: "2024-03-05", Reason: "Missing sale", SalePriceDelta: -10},
+	}
+	for _, adjustment := range invalid {
+		if _, err := service.CreateAdjustment(adjustment); err == nil {
+			t.Errorf("Expected error for adjustment %+v", adjustment)
+		}
+	}
+
+	discount, err := service.CreateAdjustment(models.CreateSalesAdjustmentRequest{
+		SalesRecordID: sale.ID, Date: "2024-03-05", Reason: "Price corrected on March statement",
+		SalePriceDelta: -10.00, CommissionDelta: amount(-1.00), RemainingDelta: amount(-9.00),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create adjustment: %v", err)
+	}
+	if discount.Date.String() != "2024-03-05" || *discount.CommissionDelta != -1.00 {
+		t.Errorf("Unexpected adjustment: %+v", discount)
+	}
+	if _, err := service.CreateAdjustment(models.CreateSalesAdjustmentRequest{
+		SalesRecordID: refund.ID, Date: "2025-01-10", Reason: "Restocking fee waived", SalePriceDelta: 5.00,
+	}); err != nil {
+		t.Fatalf("Failed to create refund adjustment: %v", err)
+	}
+
+	adjustments, err := service.ListAdjustments(sale.ID)
+	if err != nil {
+		t.Fatalf("Failed to list adjustments: %v", err)
+	}
+	if len(adjustments) != 1 || adjustments[0].ID != discount.ID {
+		t.Errorf("Expected the discount adjustment, got %+v", adjustments)
+	}
+
+	// Reports are unchanged unless adjustments are requested
+	yearly, err := service.GetYearlySummary()
+	if err != nil {
+		t.Fatalf("Failed to get yearly summary: %v", err)
+	}
+	if len(yearly) != 1 || yearly[0].TotalSales != 80.00 {
+		t.Errorf("Expected unadjusted 2024 net sales of 80.00, got %+v", yearly)
+	}
+
+	adjusted, err := service.GetYearlySummaryWithOptions(models.ReportOptions{IncludeAdjustments: true})
+	if err != nil {
+		t.Fatalf("Failed to get adjusted yearly summary: %v", err)
+	}
+	if len(adjusted) != 2 {
+		t.Fatalf("Expected 2 adjusted years, got %+v", adjusted)
+	}
+	// The refund adjustment is reported in 2025, when it was recorded
+	if y := adjusted[0]; y.Year != "2025" || y.ItemsSold != 0 || y.TotalSales != -5.00 || y.TotalReturns != 5.00 {
+		t.Errorf("Unexpected 2025 summary: %+v", y)
+	}
+	if y := adjusted[1]; y.ItemsSold != 1 || y.ReturnedItems != 1 || y.TotalSales != 70.00 ||
+		y.GrossSales != 90.00 || y.TotalReturns != 20.00 || y.TotalCommission != 9.00 || y.CommissionKnown != 1 {
+		t.Errorf("Unexpected 2024 summary: %+v", y)
+	}
+
+	year := "2024"
+	monthly, err := service.GetMonthlySummaryWithOptions(&year, models.ReportOptions{IncludeAdjustments: true})
+	if err != nil {
+		t.Fatalf("Failed to get adjusted monthly summary: %v", err)
+	}
+	if len(monthly) != 3 || monthly[0].Month != "03" || monthly[0].ItemsSold != 0 || monthly[0].TotalSales != -10.00 {
+		t.Errorf("Expected a March adjustment month, got %+v", monthly)
+	}
+
+	// Deleting a sale removes its adjustments
+	if err := service.DeleteSalesRecord(sale.ID); err != nil {
+		t.Fatalf("Failed to delete sale: %v", err)
+	}
+	if adjustments, err := service.ListAdjustments(sale.ID); err != nil || len(adjustments) != 0 {
+		t.Errorf("Expected adjustments to be deleted with the sale, got %+v, %v", adjustments, err)
+	}
+
+	if err := service.DeleteAdjustment(discount.ID); err == nil {
+		t.Error("Expected error deleting a cascaded adjustment")
+	}
+
+	created := 0
+	for _, name := range events {
+		if name == EventAdjustmentCreated {
+			created++
+		}
+	}
+	if created != 2 {
+		t.Errorf("Expected 2 %s events, got %v", EventAdjustmentCreated, events)
+	}
+}
+
+func TestImportHistoryActivity(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	if _, err := service.RecordImport(models.ImportRun{Method: "batch"}); err == nil {
+		t.Error("Expected error for missing source")
+	}
+
+	fileName := "/home/user/exports/march.html"
+	runs := []models.ImportRun{
+		{StartedAt: time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC), Source: models.ImportSourcePaste, Method: "batch", Success: true, TotalRows: 100, ParsedRows: 99, ImportedRows: 99, ErrorRows: 1},
+		{StartedAt: time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC), Source: models.ImportSourceFile, FileName: &fileName, Method: "stream", Success: true, TotalRows: 100, ParsedRows: 98, ImportedRows: 98, ErrorRows: 2, Duration: models.Duration(1500 * time.Millisecond)},
+		{StartedAt: time.Date(2024, 4, 3, 10, 0, 0, 0, time.UTC), Source: models.ImportSourcePaste, Method: "options", Success: false, TotalRows: 20, ParsedRows: 10, ErrorRows: 10},
+	}
+	for _, run := range runs {
+		if _, err := service.RecordImport(run); err != nil {
+			t.Fatalf("Failed to record import: %v", err)
+		}
+	}
+
+	history, err := service.ListImportRuns(0)
+	if err != nil {
+		t.Fatalf("Failed to list import history: %v", err)
+	}
+	if len(history) != 3 || history[0].Method != "options" || history[0].Success {
+		t.Fatalf("Expected newest failed import first, got %+v", history)
+	}
+	if file := history[1]; file.FileName == nil || *file.FileName != "march.html" || file.Duration.String() != "1.5s" {
+		t.Errorf("Expected base file name and duration to be kept, got %+v", file)
+	}
+
+	activity, err := service.GetImportActivity()
+	if err != nil {
+		t.Fatalf("Failed to get import activity: %v", err)
+	}
+	months := []string{}
+	for _, month := range activity {
+		months = append(months, month.Month)
+	}
+	if strings.Join(months, ",") != "2024-01,2024-02,2024-03,2024-04" {
+		t.Fatalf("Expected four months including the February gap, got %v", months)
+	}
+	if activity[1].Imports != 0 {
+		t.Errorf("Expected no imports in February, got %+v", activity[1])
+	}
+	if activity[0].HighErrorRate || activity[2].HighErrorRate {
+		t.Errorf("Expected normal error rates for January and March, got %+v", activity)
+	}
+	if april := activity[3]; !april.HighErrorRate || april.FailedImports != 1 || april.ErrorRate != 0.5 {
+		t.Errorf("Expected April to be flagged, got %+v", april)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	amount := func(f float64) *float64 { return &f }
+	_, err = service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		// Week of Monday 2024-03-04
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-04", Description: "Lamp", SalePrice: 40.00, Commission: amount(4.00)},
+		// Week of Monday 2024-03-11, the last complete week before 2024-03-20
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-11", Description: "Chair", SalePrice: 20.00, Commission: amount(2.00)},
+		{Store: "Store A", Vendor: "Vendor 2", Date: "2024-03-15", Description: "Table", SalePrice: 50.00, Commission: amount(5.00)},
+		{Store: "Store A", Vendor: "Vendor 2", Date: "2024-03-17", Description: "Table", SalePrice: 50.00, Commission: amount(5.00), IsReturn: true},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-17", Description: "Rug", SalePrice: 30.00, Commission: amount(3.00)},
+		// The current, incomplete week
+		{Store: "Store A", Vendor: "Vendor 2", Date: "2024-03-18", Description: "Desk", SalePrice: 500.00},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	now := time.Date(2024, 3, 20, 15, 0, 0, 0, time.Local)
+	digest, err := service.GetDigest(models.DigestWeekly, now)
+	if err != nil {
+		t.Fatalf("GetDigest failed: %v", err)
+	}
+	if digest.Current.From.String() != "2024-03-11" || digest.Current.To.String() != "2024-03-17" {
+		t.Errorf("Expected the week of 2024-03-11 to 2024-03-17, got %s to %s", digest.Current.From, digest.Current.To)
+	}
+	if digest.Current.TotalSales != 50.00 || digest.Current.ItemsSold != 3 || digest.Current.ReturnedItems != 1 {
+		t.Errorf("Expected net sales of 50.00 from 3 items and 1 return, got %+v", digest.Current)
+	}
+	if digest.Previous.TotalSales != 40.00 || digest.SalesChange == nil || *digest.SalesChange != 25 {
+		t.Errorf("Expected a 25%% rise from 40.00, got %+v and %v", digest.Previous, digest.SalesChange)
+	}
+	if digest.TopVendor == nil || digest.TopVendor.Vendor != "Vendor 1" || digest.TopVendor.TotalSales != 50.00 {
+		t.Errorf("Expected Vendor 1 as top vendor with 50.00, got %+v", digest.TopVendor)
+	}
+	if text := digest.Text(); !strings.Contains(text, "Top vendor: Vendor 1") || !strings.Contains(text, "+25.0%") {
+		t.Errorf("Expected the text to name the top vendor and the change, got:\n%s", text)
+	}
+
+	monthly, err := service.GetDigest(models.DigestMonthly, now)
+	if err != nil {
+		t.Fatalf("GetDigest failed: %v", err)
+	}
+	if monthly.Current.From.String() != "2024-02-01" || monthly.Current.To.String() != "2024-02-29" {
+		t.Errorf("Expected February 2024, got %s to %s", monthly.Current.From, monthly.Current.To)
+	}
+	if monthly.Current.ItemsSold != 0 || monthly.TopVendor != nil || monthly.SalesChange != nil {
+		t.Errorf("Expected an empty month, got %+v", monthly)
+	}
+
+	if _, err := service.GetDigest("daily", now); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for an unknown period, got %v", err)
+	}
+
+	// Weeks starting on Sunday leave out the sales of Sunday 2024-03-17
+	if err := service.SaveWeekStart(" Sunday "); err != nil {
+		t.Fatalf("SaveWeekStart failed: %v", err)
+	}
+	digest, err = service.GetDigest(models.DigestWeekly, now)
+	if err != nil {
+		t.Fatalf("GetDigest failed: %v", err)
+	}
+	if digest.Current.From.String() != "2024-03-10" || digest.Current.To.String() != "2024-03-16" || digest.Current.TotalSales != 70.00 {
+		t.Errorf("Expected 70.00 in the week of 2024-03-10 to 2024-03-16, got %+v", digest.Current)
+	}
+	if err := service.SaveWeekStart("friday"); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for an unknown week start, got %v", err)
+	}
+}
+
+func TestDigestSchedule(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	_, err = service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-12", Description: "Chair", SalePrice: 20.00},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	if schedule, err := service.GetDigestSchedule(); err != nil || schedule != models.DefaultDigestSchedule() {
+		t.Fatalf("Expected the default schedule, got %+v (%v)", schedule, err)
+	}
+	now := time.Date(2024, 3, 20, 15, 0, 0, 0, time.UTC)
+	if digest, err := service.DeliverScheduledDigest(now); err != nil || digest != nil {
+		t.Fatalf("Expected no digest while the schedule is off, got %+v (%v)", digest, err)
+	}
+
+	if err := service.SaveDigestSchedule(models.DigestSchedule{Enabled: true, Period: "hourly"}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for an unknown period, got %v", err)
+	}
+	if err := service.SaveDigestSchedule(models.DigestSchedule{Enabled: true, Period: " Weekly "}); err != nil {
+		t.Fatalf("SaveDigestSchedule failed: %v", err)
+	}
+	if schedule, err := service.GetDigestSchedule(); err != nil || schedule != (models.DigestSchedule{Enabled: true, Period: models.DigestWeekly}) {
+		t.Fatalf("Expected the saved weekly schedule, got %+v (%v)", schedule, err)
+	}
+
+	digest, err := service.DeliverScheduledDigest(now)
+	if err != nil {
+		t.Fatalf("DeliverScheduledDigest failed: %v", err)
+	}
+	if digest == nil || digest.Current.From.String() != "2024-03-11" || digest.Current.TotalSales != 20.00 {
+		t.Fatalf("Expected the digest of the week of 2024-03-11, got %+v", digest)
+	}
+
+	// The week is delivered once, however often the scheduler runs in it
+	if again, err := service.DeliverScheduledDigest(now.Add(24 * time.Hour)); err != nil || again != nil {
+		t.Errorf("Expected the week not to be delivered again, got %+v (%v)", again, err)
+	}
+	if next, err := service.DeliverScheduledDigest(now.AddDate(0, 0, 7)); err != nil || next == nil || next.Current.From.String() != "2024-03-18" {
+		t.Errorf("Expected the next week's digest, got %+v (%v)", next, err)
+	}
+
+	list, err := service.ListNotifications(models.NotificationFilter{})
+	if err != nil {
+		t.Fatalf("ListNotifications failed: %v", err)
+	}
+	if len(list.Notifications) != 2 {
+		t.Fatalf("Expected 2 digest notifications, got %+v", list.Notifications)
+	}
+	first := list.Notifications[1]
+	if first.Source != models.NotificationSourceDigest || first.Title != "Weekly sales digest" || first.Message != digest.Text() {
+		t.Errorf("Expected the digest text in a weekly digest notification, got %+v", first)
+	}
+}
+
+func TestCustomSummaryByWeek(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	_, err = service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-09", Description: "Lamp", SalePrice: 10.00},  // Saturday
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-10", Description: "Chair", SalePrice: 20.00}, // Sunday
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-11", Description: "Rug", SalePrice: 30.00},   // Monday
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-17", Description: "Desk", SalePrice: 40.00},  // Sunday
+	})
+	if err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	weeks := func() string {
+		summaries, err := service.GetCustomSummaryWithFilter(models.CustomSummaryRequest{GroupBy: "week"})
+		if err != nil {
+			t.Fatalf("GetCustomSummaryWithFilter failed: %v", err)
+		}
+		var got []string
+		for _, summary := range summaries {
+			got = append(got, fmt.Sprintf("%s=%.2f", summary.Period, summary.TotalSales))
+		}
+		return strings.Join(got, ", ")
+	}
+
+	if got := weeks(); got != "2024-03-11=70.00, 2024-03-04=30.00" {
+		t.Errorf("Expected weeks starting on Monday, got %s", got)
+	}
+	if err := service.SaveWeekStart(models.WeekStartSunday); err != nil {
+		t.Fatalf("SaveWeekStart failed: %v", err)
+	}
+	if got := weeks(); got != "2024-03-17=40.00, 2024-03-10=50.00, 2024-03-03=10.00" {
+		t.Errorf("Expected weeks starting on Sunday, got %s", got)
+	}
+}
+
+func TestDashboardKPIs(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	amount := func(f float64) *float64 { return &f }
+	_, err = service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2023-02-10", Description: "Lamp", SalePrice: 100.00, Commission: amount(10.00), Remaining: amount(90.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-05", Description: "Chair", SalePrice: 60.00, Commission: amount(6.00), Remaining: amount(54.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-02-10", Description: "Rug", SalePrice: 40.00, Commission: amount(4.00), Remaining: amount(36.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-02-25", Description: "Desk", SalePrice: 500.00, Commission: amount(50.00), Remaining: amount(450.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-05", Description: "Table", SalePrice: 80.00, Commission: amount(8.00), Remaining: amount(72.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-25", Description: "Vase", SalePrice: 20.00, Commission: amount(2.00), Remaining: amount(18.00)},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	now := time.Date(2024, 3, 20, 9, 0, 0, 0, time.UTC)
+	kpis, err := service.GetDashboardKPIs("", now)
+	if err != nil {
+		t.Fatalf("GetDashboardKPIs failed: %v", err)
+	}
+	if kpis.AsOf.String() != "2024-03-20" {
+		t.Errorf("Expected KPIs as of 2024-03-20, got %s", kpis.AsOf)
+	}
+
+	// March 1-20 against February 1-20
+	mtd := kpis.MonthToDate
+	if mtd.Current.TotalSales != 80.00 || mtd.Previous.TotalSales != 40.00 || mtd.Previous.To.String() != "2024-02-20" {
+		t.Errorf("Unexpected month-to-date totals: %+v", mtd)
+	}
+	if mtd.SalesDelta != 40.00 || mtd.CommissionDelta != 4.00 || mtd.NetDelta != 36.00 || mtd.ItemsDelta != 0 || mtd.SalesChange == nil || *mtd.SalesChange != 100 {
+		t.Errorf("Unexpected month-to-date deltas: %+v", mtd)
+	}
+
+	// January 1 - March 20 against the same days of 2023
+	ytd := kpis.YearToDate
+	if ytd.Current.TotalSales != 680.00 || ytd.Current.ItemsSold != 4 || ytd.Previous.TotalSales != 100.00 {
+		t.Errorf("Unexpected year-to-date totals: %+v", ytd)
+	}
+	if ytd.ItemsDelta != 3 || ytd.NetDelta != 522.00 {
+		t.Errorf("Unexpected year-to-date deltas: %+v", ytd)
+	}
+
+	// 80.00 in 20 days paced over 31, with no sales in March 2023
+	projection := kpis.Projection
+	if projection.DaysElapsed != 20 || projection.DaysInMonth != 31 || projection.ProjectedSales != 124.00 || projection.LastYearChange != nil {
+		t.Errorf("Unexpected projection: %+v", projection)
+	}
+
+	// An earlier month covers all of it and compares with a shorter month
+	kpis, err = service.GetDashboardKPIs("2024-01", now)
+	if err != nil {
+		t.Fatalf("GetDashboardKPIs failed: %v", err)
+	}
+	if kpis.AsOf.String() != "2024-01-31" || kpis.MonthToDate.Previous.To.String() != "2023-12-31" || kpis.MonthToDate.SalesChange != nil {
+		t.Errorf("Unexpected KPIs for January: %+v", kpis)
+	}
+
+	// A finished month projects its own total, against all of February 2023
+	kpis, err = service.GetDashboardKPIs("2024-02", now)
+	if err != nil {
+		t.Fatalf("GetDashboardKPIs failed: %v", err)
+	}
+	projection = kpis.Projection
+	if projection.DaysElapsed != 29 || projection.ProjectedSales != 540.00 || projection.LastYearSales != 100.00 || projection.LastYearChange == nil || int(*projection.LastYearChange+0.5) != 440 {
+		t.Errorf("Unexpected projection for February: %+v", projection)
+	}
+
+	for _, period := range []string{"2024-04", "March"} {
+		if _, err := service.GetDashboardKPIs(period, now); !errors.Is(err, ErrValidation) {
+			t.Errorf("Expected a validation error for period %q, got %v", period, err)
+		}
+	}
+}
+
+func TestItemCostAndProfitability(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	amount := func(f float64) *float64 { return &f }
+	text := func(s string) *string { return &s }
+	created, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-01", Description: "Lamp", SalePrice: 100.00, Commission: amount(20.00), Remaining: amount(80.00),
+			Cost: amount(30.00), Category: text("Lighting"), ExternalID: text("TX-1")},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-02", Description: "Lamp", SalePrice: 100.00, Commission: amount(20.00),
+			Cost: amount(40.00), Category: text("Lighting")},
+		{Store: "Store A", Vendor: "Vendor 2", Date: "2024-03-03", Description: "Chair", SalePrice: 50.00, Remaining: amount(40.00),
+			Cost: amount(45.00), Category: text("Furniture")},
+		{Store: "Store A", Vendor: "Vendor 2", Date: "2024-03-04", Description: "Chair", SalePrice: 50.00, Remaining: amount(40.00),
+			Cost: amount(45.00), Category: text("Furniture")},
+		{Store: "Store A", Vendor: "Vendor 2", Date: "2024-03-05", Description: "Chair", SalePrice: 50.00, Remaining: amount(40.00), IsReturn: true,
+			Cost: amount(45.00), Category: text("Furniture")},
+		{Store: "Store A", Vendor: "Vendor 2", Date: "2024-03-06", Description: "Rug", SalePrice: 20.00},
+	})
+	if err != nil {
+		t.Fatalf("CreateSalesRecordsBatch failed: %v", err)
+	}
+	if created[0].Cost == nil || *created[0].Cost != 30.00 || created[0].Category == nil || *created[0].Category != "Lighting" {
+		t.Errorf("Expected cost and category to round-trip, got %v and %v", created[0].Cost, created[0].Category)
+	}
+
+	report, err := service.GetProfitability(models.ProfitabilityFilter{GroupBy: models.ProfitByCategory})
+	if err != nil {
+		t.Fatalf("GetProfitability failed: %v", err)
+	}
+	if len(report.Groups) != 3 {
+		t.Fatalf("Expected 3 categories, got %+v", report.Groups)
+	}
+	lighting, uncategorized, furniture := report.Groups[0], report.Groups[1], report.Groups[2]
+	if lighting.Group != "Lighting" || lighting.CostedItems != 2 || lighting.Sales != 200.00 || lighting.Proceeds != 160.00 ||
+		lighting.Cost != 70.00 || lighting.Profit != 90.00 {
+		t.Errorf("Unexpected lighting profit: %+v", lighting)
+	}
+	if lighting.Margin == nil || *lighting.Margin != 45 || lighting.BreakEvenPrice == nil || *lighting.BreakEvenPrice != 43.75 {
+		t.Errorf("Expected a 45%% margin and a 43.75 break-even price, got %v and %v", lighting.Margin, lighting.BreakEvenPrice)
+	}
+	if uncategorized.Group != "" || uncategorized.UncostedItems != 1 || uncategorized.Profit != 0 || uncategorized.Margin != nil {
+		t.Errorf("Expected the uncosted rug to be counted but not priced, got %+v", uncategorized)
+	}
+	// The returned chair cancels one sale, leaving one loss-making chair
+	if furniture.Group != "Furniture" || furniture.CostedItems != 1 || furniture.Profit != -5.00 || *furniture.Margin != -10 {
+		t.Errorf("Unexpected furniture profit: %+v", furniture)
+	}
+	if report.Total.CostedItems != 3 || report.Total.UncostedItems != 1 || report.Total.Profit != 85.00 {
+		t.Errorf("Unexpected total: %+v", report.Total)
+	}
+
+	byItem, err := service.GetProfitability(models.ProfitabilityFilter{GroupBy: models.ProfitByItem})
+	if err != nil {
+		t.Fatalf("GetProfitability by item failed: %v", err)
+	}
+	if len(byItem.Groups) != 3 || byItem.Groups[0].Description != "Lamp" {
+		t.Errorf("Expected lamps to be the most profitable item, got %+v", byItem.Groups)
+	}
+	byVendor, err := service.GetProfitability(models.ProfitabilityFilter{GroupBy: models.ProfitByVendor})
+	if err != nil {
+		t.Fatalf("GetProfitability by vendor failed: %v", err)
+	}
+	if len(byVendor.Groups) != 2 || byVendor.Groups[1].Group != "Vendor 2" || byVendor.Groups[1].Profit != -5.00 {
+		t.Errorf("Unexpected vendor profit: %+v", byVendor.Groups)
+	}
+	if _, err := service.GetProfitability(models.ProfitabilityFilter{GroupBy: "store"}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected an unknown grouping to be rejected, got %v", err)
+	}
+
+	// Re-importing without a cost or category keeps the entered ones
+	result, err := service.UpsertSalesRecords([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-01", Description: "Lamp", SalePrice: 100.00, Commission: amount(20.00), Remaining: amount(80.00),
+			ExternalID: text("TX-1")},
+	})
+	if err != nil {
+		t.Fatalf("UpsertSalesRecords failed: %v", err)
+	}
+	kept, err := service.GetSalesRecord(created[0].ID)
+	if err != nil {
+		t.Fatalf("GetSalesRecord failed: %v", err)
+	}
+	if result.Unchanged != 1 || kept.Cost == nil || *kept.Cost != 30.00 || kept.Category == nil {
+		t.Errorf("Expected the re-import to keep cost and category, got %+v and %v, %v", result, kept.Cost, kept.Category)
+	}
+
+	// Updates set the cost, and an empty category clears it
+	updated, err := service.UpdateSalesRecord(created[5].ID, models.UpdateSalesRecordRequest{Cost: amount(5.00), Category: text("Textiles")})
+	if err != nil {
+		t.Fatalf("UpdateSalesRecord failed: %v", err)
+	}
+	if updated.Cost == nil || *updated.Cost != 5.00 || updated.Category == nil || *updated.Category != "Textiles" {
+		t.Errorf("Expected cost and category to be set, got %v and %v", updated.Cost, updated.Category)
+	}
+	list, err := service.ListSalesRecords(models.SalesRecordFilter{Category: text("Textiles")})
+	if err != nil {
+		t.Fatalf("ListSalesRecords failed: %v", err)
+	}
+	if list.Total != 1 || list.Records[0].ID != created[5].ID {
+		t.Errorf("Expected only the rug in Textiles, got %+v", list.Records)
+	}
+	cleared, err := service.UpdateSalesRecord(created[5].ID, models.UpdateSalesRecordRequest{Category: text("")})
+	if err != nil {
+		t.Fatalf("UpdateSalesRecord failed: %v", err)
+	}
+	if cleared.Category != nil {
+		t.Errorf("Expected the category to be cleared, got %q", *cleared.Category)
+	}
+
+	if _, err := service.UpdateSalesRecord(created[5].ID, models.UpdateSalesRecordRequest{Cost: amount(-1)}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a negative cost to be rejected, got %v", err)
+	}
+}
+
+func TestBulkEnrichment(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	amount := func(f float64) *float64 { return &f }
+	text := func(s string) *string { return &s }
+	created, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2022-05-01", Description: "Brass Lamp", SalePrice: 40.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2022-06-01", Description: "lamp, brass", SalePrice: 45.00, Cost: amount(12.00)},
+		{Store: "Store B", Vendor: "Vendor 1", Date: "2023-01-10", Description: "Oak Chair", SalePrice: 80.00, Category: text("Seating")},
+		{Store: "Store B", Vendor: "Vendor 2", Date: "2023-02-10", Description: "Wool Rug", SalePrice: 60.00},
+	})
+	if err != nil {
+		t.Fatalf("CreateSalesRecordsBatch failed: %v", err)
+	}
+
+	// A dry run counts without changing anything
+	vendor := "Vendor 1"
+	preview, err := service.EnrichSalesRecords(models.EnrichmentRequest{
+		Filter: models.SalesRecordFilter{Vendor: &vendor}, Cost: amount(10.00), OnlyMissing: true, DryRun: true,
+	})
+	if err != nil {
+		t.Fatalf("EnrichSalesRecords dry run failed: %v", err)
+	}
+	if preview.Updated != 2 || !preview.DryRun {
+		t.Errorf("Expected a dry run to find 2 records without a cost, got %+v", preview)
+	}
+	unchanged, err := service.GetSalesRecord(created[0].ID)
+	if err != nil {
+		t.Fatalf("GetSalesRecord failed: %v", err)
+	}
+	if unchanged.Cost != nil {
+		t.Errorf("Expected the dry run to leave the cost unset, got %v", *unchanged.Cost)
+	}
+
+	result, err := service.EnrichSalesRecords(models.EnrichmentRequest{
+		Filter: models.SalesRecordFilter{Vendor: &vendor}, Cost: amount(10.00), OnlyMissing: true,
+		AddTags: []string{"estate sale", " estate sale", "2022 haul"},
+	})
+	if err != nil {
+		t.Fatalf("EnrichSalesRecords failed: %v", err)
+	}
+	if result.Updated != 3 {
+		t.Errorf("Expected 3 records to change, got %+v", result)
+	}
+	kept, err := service.GetSalesRecord(created[1].ID)
+	if err != nil {
+		t.Fatalf("GetSalesRecord failed: %v", err)
+	}
+	if *kept.Cost != 12.00 || len(kept.Tags) != 2 || kept.Tags[0] != "2022 haul" || kept.Tags[1] != "estate sale" {
+		t.Errorf("Expected the cost to be kept and tags added once, sorted, got %v and %v", *kept.Cost, kept.Tags)
+	}
+
+	// Re-applying changes nothing, and removing a tag leaves the others
+	again, err := service.EnrichSalesRecords(models.EnrichmentRequest{Filter: models.SalesRecordFilter{Vendor: &vendor}, AddTags: []string{"estate sale"}})
+	if err != nil {
+		t.Fatalf("EnrichSalesRecords failed: %v", err)
+	}
+	if again.Updated != 0 {
+		t.Errorf("Expected no records to change, got %+v", again)
+	}
+	if _, err := service.EnrichSalesRecords(models.EnrichmentRequest{RemoveTags: []string{"2022 haul"}}); err != nil {
+		t.Fatalf("EnrichSalesRecords failed: %v", err)
+	}
+	list, err := service.ListSalesRecords(models.SalesRecordFilter{Tag: text("estate sale")})
+	if err != nil {
+		t.Fatalf("ListSalesRecords failed: %v", err)
+	}
+	if list.Total != 3 || len(list.Records[0].Tags) != 1 {
+		t.Errorf("Expected 3 records tagged estate sale only, got %+v", list.Records)
+	}
+
+	if _, err := service.EnrichSalesRecords(models.EnrichmentRequest{}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected an enrichment without changes to be rejected, got %v", err)
+	}
+
+	// Mappings match descriptions by product key and skip lines without a pair
+	mapped, err := service.ApplyCategoryMappings(models.CategoryMappingRequest{
+		Mappings:    "# description -> category\nBrass lamp -> Lighting\nChair, Oak\tFurniture\nwool rug\nTeak Table => Furniture\n",
+		OnlyMissing: true,
+	})
+	if err != nil {
+		t.Fatalf("ApplyCategoryMappings failed: %v", err)
+	}
+	if mapped.Updated != 2 || len(mapped.Mappings) != 3 || len(mapped.Skipped) != 1 || mapped.Skipped[0] != "wool rug" {
+		t.Errorf("Unexpected mapping result: %+v", mapped)
+	}
+	if mapped.Mappings[0].Updated != 2 || mapped.Mappings[1].Updated != 0 || mapped.Mappings[2].Updated != 0 {
+		t.Errorf("Expected only the lamps to be categorized, keeping the chair's category, got %+v", mapped.Mappings)
+	}
+	lamp, err := service.GetSalesRecord(created[1].ID)
+	if err != nil {
+		t.Fatalf("GetSalesRecord failed: %v", err)
+	}
+	if lamp.Category == nil || *lamp.Category != "Lighting" {
+		t.Errorf("Expected the lamp in Lighting, got %v", lamp.Category)
+	}
+
+	audit, err := service.ListAuditLog(10)
+	if err != nil {
+		t.Fatalf("ListAuditLog failed: %v", err)
+	}
+	var enrichments int
+	for _, entry := range audit {
+		if entry.Action == models.AuditRecordsEnriched {
+			enrichments++
+		}
+	}
+	if enrichments != 3 {
+		t.Errorf("Expected the 3 enrichments that changed records to be audited, got %+v", audit)
+	}
+}
+
+func TestRetentionPolicy(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	var events []string
+	service.SetEventEmitter(func(name string, payload interface{}) { events = append(events, name) })
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	records := []models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2015-03-10", Description: "Old lamp", SalePrice: 40.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-05-20", Description: "New chair", SalePrice: 60.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-05-21", Description: "Mistake", SalePrice: 1.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-05-22", Description: "Recent mistake", SalePrice: 2.00},
+	}
+	created, err := service.CreateSalesRecordsBatch(records)
+	if err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+	if _, err := service.CreateAdjustment(models.CreateSalesAdjustmentRequest{
+		SalesRecordID: created[0].ID, Date: "2015-04-01", Reason: "Late discount", SalePriceDelta: -5.00,
+	}); err != nil {
+		t.Fatalf("Failed to create adjustment: %v", err)
+	}
+
+	// Deleted records go to the trash; backdate one of them past the purge period
+	for _, record := range created[2:] {
+		if err := service.DeleteSalesRecord(record.ID); err != nil {
+			t.Fatalf("Failed to delete record: %v", err)
+		}
+	}
+	if _, err := service.GetDB().Conn().Exec("UPDATE deleted_sales_records SET deleted_at = '2024-01-01 00:00:00' WHERE id = ?", created[2].ID); err != nil {
+		t.Fatalf("Failed to backdate deleted record: %v", err)
+	}
+
+	if _, err := service.RecordImport(models.ImportRun{StartedAt: now.AddDate(-2, 0, 0), Source: models.ImportSourcePaste, Method: "batch"}); err != nil {
+		t.Fatalf("Failed to record import: %v", err)
+	}
+	if _, err := service.RecordImport(models.ImportRun{StartedAt: now.AddDate(0, 0, -1), Source: models.ImportSourcePaste, Method: "batch"}); err != nil {
+		t.Fatalf("Failed to record import: %v", err)
+	}
+
+	if err := service.SaveRetentionPolicy(models.RetentionPolicy{PurgeDeletedAfterDays: -1}); err == nil {
+		t.Error("Expected error for a negative retention period")
+	}
+
+	policy, err := service.GetRetentionPolicy()
+	if err != nil {
+		t.Fatalf("Failed to get retention policy: %v", err)
+	}
+	if policy != models.DefaultRetentionPolicy() {
+		t.Errorf("Expected the default policy before one is saved, got %+v", policy)
+	}
+	policy.PurgeImportHistoryAfterDays = 365
+
+	// The scheduler does nothing until the policy is enabled
+	scheduler := NewMaintenanceScheduler(service, time.Hour, nil)
+	if result, err := scheduler.RunOnce(now); err != nil || result != nil {
+		t.Errorf("Expected no scheduled run for a disabled policy, got %+v, %v", result, err)
+	}
+
+	preview, err := service.PreviewRetention(policy, now)
+	if err != nil {
+		t.Fatalf("Failed to preview retention: %v", err)
+	}
+	expected := models.RetentionResult{DryRun: true, RanAt: now, ArchivedRecords: 1, ArchivedAdjustments: 1, PurgedDeletedRecords: 1, PurgedImportRuns: 1}
+	if *preview != expected {
+		t.Errorf("Expected preview %+v, got %+v", expected, *preview)
+	}
+	if stats, _ := service.GetDatabaseStats(); stats.TotalRecords != 2 {
+		t.Errorf("Expected the preview to leave 2 records, got %d", stats.TotalRecords)
+	}
+	if entries, _ := service.ListAuditLog(0); len(entries) != 0 {
+		t.Errorf("Expected the preview to leave no audit entries, got %+v", entries)
+	}
+
+	policy.Enabled = true
+	if err := service.SaveRetentionPolicy(policy); err != nil {
+		t.Fatalf("Failed to save retention policy: %v", err)
+	}
+	result, err := scheduler.RunOnce(now)
+	if err != nil {
+		t.Fatalf("Failed to run scheduled maintenance: %v", err)
+	}
+	expected.DryRun = false
+	if result == nil || *result != expected {
+		t.Fatalf("Expected result %+v, got %+v", expected, result)
+	}
+
+	yearly, err := service.GetYearlySummary()
+	if err != nil {
+		t.Fatalf("Failed to get yearly summary: %v", err)
+	}
+	if len(yearly) != 1 || yearly[0].Year != "2024" {
+		t.Errorf("Expected archived sales to leave the reports, got %+v", yearly)
+	}
+	var trash, archived int
+	conn := service.GetDB().Conn()
+	conn.QueryRow("SELECT COUNT(*) FROM deleted_sales_records").Scan(&trash)
+	conn.QueryRow("SELECT COUNT(*) FROM sales_records_archive WHERE description = 'Old lamp'").Scan(&archived)
+	if trash != 1 || archived != 1 {
+		t.Errorf("Expected 1 record left in the trash and 1 archived, got %d and %d", trash, archived)
+	}
+
+	entries, err := service.ListAuditLog(0)
+	if err != nil {
+		t.Fatalf("Failed to list audit log: %v", err)
+	}
+	actions := []string{}
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+	}
+	if strings.Join(actions, ",") != "retention.purged_import_history,retention.purged_deleted,retention.archived,settings.updated" {
+		t.Errorf("Unexpected audit log: %+v", entries)
+	}
+
+	// A second run has nothing left to do
+	if again, err := service.ApplyRetention(policy, now); err != nil || again.Changed() {
+		t.Errorf("Expected nothing to change on a second run, got %+v, %v", again, err)
+	}
+	applied := 0
+	for _, name := range events {
+		if name == EventRetentionApplied {
+			applied++
+		}
+	}
+	if applied != 1 {
+		t.Errorf("Expected 1 %s event, got %v", EventRetentionApplied, events)
+	}
+}
+
+func TestCompactionAndBackup(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "sales.db")
+	service, err := NewService(Config{FilePath: dbPath, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	records := make([]models.CreateSalesRecordRequest, 2000)
+	for i := range records {
+		records[i] = models.CreateSalesRecordRequest{
+			Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15",
+			Description: strings.Repeat("Item ", 20) + fmt.Sprint(i), SalePrice: 10.00,
+		}
+	}
+	if _, err := service.CreateSalesRecordsBatch(records); err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+	if service.MaintenanceStatus() != nil {
+		t.Error("Expected no maintenance status before any maintenance ran")
+	}
+
+	// Writes and a second operation attempted while maintenance runs are refused
+	var progress []MaintenanceProgress
+	var completed []MaintenanceProgress
+	var blockedWrites, blockedStarts int
+	service.SetEventEmitter(func(name string, payload interface{}) {
+		switch name {
+		case EventMaintenanceProgress:
+			progress = append(progress, payload.(MaintenanceProgress))
+			if _, err := service.CreateSalesRecord(records[0]); errors.Is(err, ErrMaintenanceInProgress) {
+				blockedWrites++
+			}
+			if err := service.StartCompaction(); errors.Is(err, ErrMaintenanceInProgress) {
+				blockedStarts++
+			}
+		case EventMaintenanceCompleted:
+			completed = append(completed, payload.(MaintenanceProgress))
+		}
+	})
+
+	if err := service.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if len(progress) < 2 || len(completed) != 1 {
+		t.Fatalf("Expected progress and completion events, got %+v and %+v", progress, completed)
+	}
+	if last := progress[len(progress)-1]; last.Operation != MaintenanceCompact || last.Percent != 100 || last.PagesTotal == 0 {
+		t.Errorf("Expected compaction to finish at 100%%, got %+v", last)
+	}
+	if blockedWrites != len(progress) || blockedStarts != len(progress) {
+		t.Errorf("Expected every write and start during compaction to be refused, got %d and %d of %d", blockedWrites, blockedStarts, len(progress))
+	}
+	if status := service.MaintenanceStatus(); status == nil || !status.Done || status.Error != "" {
+		t.Errorf("Expected a finished compaction status, got %+v", status)
+	}
+
+	// Writes are accepted again afterwards
+	if _, err := service.CreateSalesRecord(records[0]); err != nil {
+		t.Fatalf("Expected writes after compaction, got %v", err)
+	}
+
+	if err := service.Backup(dbPath); err == nil {
+		t.Error("Expected error backing up onto the database itself")
+	}
+
+	progress = nil
+	backupPath := filepath.Join(dir, "backup.db")
+	if err := service.Backup(backupPath); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if len(progress) < 2 || progress[0].Operation != MaintenanceBackup || progress[len(progress)-1].Percent != 100 {
+		t.Errorf("Expected stepwise backup progress, got %+v", progress)
+	}
+
+	backup, err := NewService(Config{FilePath: backupPath})
+	if err != nil {
+		t.Fatalf("Failed to open backup: %v", err)
+	}
+	defer backup.Close()
+	stats, err := backup.GetDatabaseStats()
+	if err != nil {
+		t.Fatalf("Failed to read backup: %v", err)
+	}
+	if stats.TotalRecords != int64(len(records)+1) {
+		t.Errorf("Expected %d records in the backup, got %d", len(records)+1, stats.TotalRecords)
+	}
+}
+
+func TestHealthReport(t *testing.T) {
+	dir := t.TempDir()
+	service, err := NewService(Config{FilePath: filepath.Join(dir, "sales.db"), AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	checks := func(report *HealthReport) map[string]HealthCheck {
+		byName := make(map[string]HealthCheck)
+		for _, check := range report.Checks {
+			byName[check.Name] = check
+		}
+		return byName
+	}
+
+	report := service.HealthReport()
+	byName := checks(report)
+	for _, name := range []string{"connection", "schema_version", "pending_migrations", "wal_size", "disk_space", "last_backup"} {
+		if _, ok := byName[name]; !ok {
+			t.Errorf("Expected a %s check, got %+v", name, report.Checks)
+		}
+	}
+	if backup := byName["last_backup"]; backup.Severity != SeverityWarning || backup.Action == "" {
+		t.Errorf("Expected a warning with an action for a database never backed up, got %+v", backup)
+	}
+	if report.Status != SeverityWarning {
+		t.Errorf("Expected overall status %s, got %s", SeverityWarning, report.Status)
+	}
+
+	if err := service.Backup(filepath.Join(dir, "backup.db")); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	report = service.HealthReport()
+	for _, check := range report.Checks {
+		// Free space depends on the machine running the test
+		if check.Name != "disk_space" && (check.Severity != SeverityOK || check.Action != "") {
+			t.Errorf("Expected %s to pass after a backup, got %+v", check.Name, check)
+		}
+	}
+
+	// A database migrated by a newer build is critical
+	if _, err := service.GetDB().Conn().Exec("INSERT INTO migrations (version, name) VALUES (999, 'future')"); err != nil {
+		t.Fatalf("Failed to record future migration: %v", err)
+	}
+	report = service.HealthReport()
+	if schema := checks(report)["schema_version"]; schema.Severity != SeverityCritical || !strings.Contains(schema.Message, "newer version") {
+		t.Errorf("Expected a critical schema check, got %+v", schema)
+	}
+	if report.Status != SeverityCritical {
+		t.Errorf("Expected overall status %s, got %s", SeverityCritical, report.Status)
+	}
+}
+
+func TestCheckpointWAL(t *testing.T) {
+	service, err := NewService(Config{FilePath: filepath.Join(t.TempDir(), "sales.db"), AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	if last := service.LastCheckpoint(); last != nil {
+		t.Errorf("Expected no checkpoint before one runs, got %+v", last)
+	}
+	for i := 0; i < 50; i++ {
+		if _, err := service.CreateSalesRecord(models.CreateSalesRecordRequest{
+			Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: fmt.Sprintf("Item %d", i), SalePrice: 10.00,
+		}); err != nil {
+			t.Fatalf("Failed to create record: %v", err)
+		}
+	}
+
+	result, err := service.CheckpointWAL()
+	if err != nil {
+		t.Fatalf("CheckpointWAL failed: %v", err)
+	}
+	if result.Mode != CheckpointPassive || result.WALSizeBefore == 0 || result.LogFrames == 0 || result.CopiedFrames != result.LogFrames {
+		t.Errorf("Expected a passive checkpoint copying every frame, got %+v", result)
+	}
+	report := service.HealthReport()
+	if report.WALSize != result.WALSize || report.LastCheckpoint == nil || report.LastCheckpoint.Mode != CheckpointPassive {
+		t.Errorf("Expected the health report to carry the WAL size and last checkpoint, got %+v", report)
+	}
+
+	result, err = service.GetDB().Checkpoint(CheckpointTruncate)
+	if err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	if result.Busy || result.WALSize != 0 {
+		t.Errorf("Expected a truncating checkpoint to empty the WAL, got %+v", result)
+	}
+	if _, err := service.GetDB().Checkpoint("FULL; DROP TABLE sales_records"); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected an unknown mode to be rejected, got %v", err)
+	}
+}
+
+func TestWriteQueue(t *testing.T) {
+	service, err := NewService(Config{FilePath: filepath.Join(t.TempDir(), "sales.db"), AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	// Writes made while another is in progress wait their turn
+	release, err := service.beginWrite()
+	if err != nil {
+		t.Fatalf("beginWrite failed: %v", err)
+	}
+	if status := service.WriteQueueStatus(); !status.Busy || status.Since == nil {
+		t.Errorf("Expected the queue to be busy, got %+v", status)
+	}
+
+	const writers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := service.CreateSalesRecord(models.CreateSalesRecordRequest{
+				Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: fmt.Sprintf("Item %d", i), SalePrice: 10.00,
+			})
+			errs <- err
+		}(i)
+	}
+	for deadline := time.Now().Add(5 * time.Second); service.WriteQueueStatus().Waiting < writers; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d waiting writes, got %+v", writers, service.WriteQueueStatus())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Reads do not queue
+	if _, err := service.GetDatabaseStats(); err != nil {
+		t.Errorf("Expected reads to proceed during a write, got %v", err)
+	}
+
+	release()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Expected queued writes to succeed, got %v", err)
+		}
+	}
+	stats, err := service.GetDatabaseStats()
+	if err != nil {
+		t.Fatalf("GetDatabaseStats failed: %v", err)
+	}
+	if stats.TotalRecords != writers {
+		t.Errorf("Expected %d records, got %d", writers, stats.TotalRecords)
+	}
+	if status := service.WriteQueueStatus(); status.Busy || status.Waiting != 0 {
+		t.Errorf("Expected an idle queue, got %+v", status)
+	}
+
+	// A write that waits too long gives up, leaving the queue usable
+	service.writes.timeout = 50 * time.Millisecond
+	release, err = service.beginWrite()
+	if err != nil {
+		t.Fatalf("beginWrite failed: %v", err)
+	}
+	if _, err := service.tryBeginWrite(); !errors.Is(err, ErrWriteBusy) {
+		t.Errorf("Expected tryBeginWrite to fail at once, got %v", err)
+	}
+	if _, err := service.CreateFee(models.CreateFeeRequest{Store: "Store A", Date: "2024-01-31", Description: "Booth rent", Amount: 25}); !errors.Is(err, ErrWriteBusy) {
+		t.Errorf("Expected ErrWriteBusy, got %v", err)
+	}
+	release()
+	if _, err := service.CreateFee(models.CreateFeeRequest{Store: "Store A", Date: "2024-01-31", Description: "Booth rent", Amount: 25}); err != nil {
+		t.Errorf("Expected the write to succeed once the queue is free, got %v", err)
+	}
+	if release, err := service.tryBeginWrite(); err != nil {
+		t.Errorf("Expected tryBeginWrite to succeed on an idle queue, got %v", err)
+	} else {
+		release()
+	}
+}
+
+func TestSchemaCompatibility(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sales.db")
+
+	compatibility, err := InspectSchema(path)
+	if err != nil {
+		t.Fatalf("InspectSchema failed: %v", err)
+	}
+	if !compatibility.Compatible || compatibility.SupportedVersion == 0 {
+		t.Errorf("Expected a missing database to be compatible, got %+v", compatibility)
+	}
+
+	service, err := NewService(Config{FilePath: path, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	compatibility, err = service.CheckSchemaCompatibility()
+	if err != nil {
+		t.Fatalf("CheckSchemaCompatibility failed: %v", err)
+	}
+	if !compatibility.Compatible || compatibility.AppliedVersion != compatibility.SupportedVersion {
+		t.Errorf("Expected a current schema, got %+v", compatibility)
+	}
+
+	// Simulate a database migrated by a newer build
+	future := compatibility.SupportedVersion + 1
+	if _, err := service.GetDB().Conn().Exec("INSERT INTO migrations (version, name) VALUES (?, 'future')", future); err != nil {
+		t.Fatalf("Failed to record future migration: %v", err)
+	}
+	service.Close()
+
+	if _, err := NewService(Config{FilePath: path, AutoMigrate: true}); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("Expected ErrSchemaTooNew when opening a newer database, got %v", err)
+	} else if !strings.Contains(err.Error(), "newer version") {
+		t.Errorf("Expected a clear message, got %v", err)
+	}
+
+	compatibility, err = InspectSchema(path)
+	if err != nil {
+		t.Fatalf("InspectSchema failed: %v", err)
+	}
+	if compatibility.Compatible || compatibility.AppliedVersion != future {
+		t.Errorf("Expected an incompatible schema at version %d, got %+v", future, compatibility)
+	}
+}
+
+func TestCreateSalesRecordsBatchPartial(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	records := []models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Good 1", SalePrice: 100.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "01/16/2024", Description: "Bad date", SalePrice: 50.00},
+		{Store: "", Vendor: "Vendor 1", Date: "2024-01-17", Description: "Missing store", SalePrice: 25.00},
+		{Store: "Store B", Vendor: "Vendor 2", Date: "2024-01-18", Description: "Good 2", SalePrice: 75.00},
+	}
+
+	created, failures, err := service.CreateSalesRecordsBatchPartial(records)
+	if err != nil {
+		t.Fatalf("CreateSalesRecordsBatchPartial failed: %v", err)
+	}
+
+	if len(created) != 2 {
+		t.Errorf("Expected 2 created records, got %d", len(created))
+	}
+	if len(failures) != 2 {
+		t.Fatalf("Expected 2 failures, got %d", len(failures))
+	}
+	if failures[0].Index != 1 || !strings.Contains(failures[0].Reason, "date") {
+		t.Errorf("Expected bad date failure at index 1, got %+v", failures[0])
+	}
+	if failures[1].Index != 2 || failures[1].Record.Description != "Missing store" {
+		t.Errorf("Expected missing store failure at index 2, got %+v", failures[1])
+	}
+
+	stats, err := service.GetDatabaseStats()
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if stats.TotalRecords != 2 {
+		t.Errorf("Expected 2 stored records, got %d", stats.TotalRecords)
+	}
+}
+
+// TestSalesRepositoryMultiValueFilters tests StoreIn/VendorIn and exclusion filters
+func TestSalesRepositoryMultiValueFilters(t *testing.T) {
+	db, err := New(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewSalesRepository(db)
+
+	var records []models.CreateSalesRecordRequest
+	for _, store := range []string{"Store A", "Store B", "Store C"} {
+		for _, vendor := range []string{"Vendor 1", "Vendor 2"} {
+			records = append(records, models.CreateSalesRecordRequest{
+				Store:       store,
+				Vendor:      vendor,
+				Date:        "2024-01-15",
+				Description: "Product",
+				SalePrice:   10.00,
+			})
+		}
+	}
+	if _, err := repo.CreateBatch(records); err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		filter   models.SalesRecordFilter
+		expected int64
+	}{
+		{"store in", models.SalesRecordFilter{StoreIn: []string{"Store A", "Store C"}}, 4},
+		{"vendor in", models.SalesRecordFilter{VendorIn: []string{"Vendor 2"}}, 3},
+		{"not store", models.SalesRecordFilter{NotStore: []string{"Store A"}}, 4},
+		{"not vendor", models.SalesRecordFilter{NotVendor: []string{"Vendor 1", "Vendor 2"}}, 0},
+		{"combined", models.SalesRecordFilter{StoreIn: []string{"Store A", "Store B"}, NotVendor: []string{"Vendor 1"}}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := repo.List(tt.filter)
+			if err != nil {
+				t.Fatalf("Failed to list records: %v", err)
+			}
+			if list.Total != tt.expected {
+				t.Errorf("Expected %d records, got %d", tt.expected, list.Total)
+			}
+		})
+	}
+}
+
+// TestReportingRepository tests reporting and analytics operations
+func TestDrillDownTable(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	if _, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "vendor B", Date: "2024-01-15", Description: "Lamp", SalePrice: 40.00, Commission: floatPtr(4.00)},
+		{Store: "Store A", Vendor: "Vendor A", Date: "2024-01-20", Description: "chair", SalePrice: 25.00},
+		{Store: "Store B", Vendor: "Vendor A", Date: "2024-01-20", Description: "Rug", SalePrice: 60.00, IsReturn: true},
+		{Store: "Store B", Vendor: "Vendor C", Date: "2024-02-01", Description: "Desk", SalePrice: 90.00},
+	}); err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	month := "1"
+	table, err := service.GetDrillDownTable(models.DrillDownRequest{
+		Year: "2024", Month: &month, SortBy: "vendor", SortOrder: "asc",
+		Columns: []string{"vendor", "date", "sale_price", "commission", "is_return"},
+	})
+	if err != nil {
+		t.Fatalf("GetDrillDownTable failed: %v", err)
+	}
+	// Vendors sort ignoring case, newest first within a vendor
+	want := [][]interface{}{
+		{"Vendor A", "2024-01-20", 60.00, nil, true},
+		{"Vendor A", "2024-01-20", 25.00, nil, false},
+		{"vendor B", "2024-01-15", 40.00, 4.00, false},
+	}
+	if !reflect.DeepEqual(table.Rows, want) {
+		t.Errorf("Expected rows %#v, got %#v", want, table.Rows)
+	}
+
+	table, err = service.GetDrillDownTable(models.DrillDownRequest{Year: "2024", SortBy: "description", SortOrder: "asc"})
+	if err != nil {
+		t.Fatalf("GetDrillDownTable failed: %v", err)
+	}
+	if !reflect.DeepEqual(table.Columns, models.DrillDownColumns) || len(table.Rows) != 4 {
+		t.Fatalf("Expected every column for 4 records, got %v with %d rows", table.Columns, len(table.Rows))
+	}
+	if description := table.Rows[0][4]; description != "chair" {
+		t.Errorf("Expected chair first by description, got %v", description)
+	}
+
+	for name, req := range map[string]models.DrillDownRequest{
+		"bad year":        {Year: "24"},
+		"day alone":       {Year: "2024", Day: &month},
+		"unknown sort":    {Year: "2024", SortBy: "cost"},
+		"bad order":       {Year: "2024", SortOrder: "up"},
+		"unknown column":  {Year: "2024", Columns: []string{"date", "tags"}},
+		"repeated column": {Year: "2024", Columns: []string{"date", "date"}},
+	} {
+		if _, err := service.GetDrillDownTable(req); !errors.Is(err, ErrValidation) {
+			t.Errorf("%s: expected a validation error, got %v", name, err)
+		}
+	}
+}
+
+func TestSummariesByImportDate(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	eur := "EUR"
+	created, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2023-05-01", Description: "Lamp", SalePrice: 100.00, Currency: &eur},
+		{Store: "Store A", Vendor: "Vendor 2", Date: "2023-11-20", Description: "Chair", SalePrice: 50.00},
+		{Store: "Store B", Vendor: "Vendor 1", Date: "2024-03-02", Description: "Rug", SalePrice: 30.00},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+	// The first two were entered late, on the same day in March; noon keeps
+	// the local date the same in any time zone
+	for i, createdAt := range []string{"2024-03-10 12:00:00", "2024-03-10 12:00:00", "2024-04-01 12:00:00"} {
+		if _, err := service.GetDB().Conn().Exec("UPDATE sales_records SET created_at = ? WHERE id = ?", createdAt, created[i].ID); err != nil {
+			t.Fatalf("Failed to set created_at: %v", err)
+		}
+	}
+
+	monthly, err := service.GetMonthlySummaryWithOptions(nil, models.ReportOptions{DateBasis: models.DateBasisImport})
+	if err != nil {
+		t.Fatalf("GetMonthlySummaryWithOptions failed: %v", err)
+	}
+	if len(monthly) != 2 || monthly[0].YearMonth != "2024-04" || monthly[1].YearMonth != "2024-03" || monthly[1].ItemsSold != 2 || monthly[1].TotalSales != 150.00 {
+		t.Errorf("Expected March and April 2024 by import date, got %+v", monthly)
+	}
+	yearly, err := service.GetYearlySummaryWithOptions(models.ReportOptions{DateBasis: models.DateBasisSale})
+	if err != nil {
+		t.Fatalf("GetYearlySummaryWithOptions failed: %v", err)
+	}
+	if len(yearly) != 2 {
+		t.Errorf("Expected 2023 and 2024 by sale date, got %+v", yearly)
+	}
+
+	// Exchange rates still apply as of the sale date
+	if _, err := service.SaveExchangeRate(models.CreateExchangeRateRequest{Date: "2023-01-02", Currency: "USD", Rate: 1.10}); err != nil {
+		t.Fatalf("SaveExchangeRate failed: %v", err)
+	}
+	yearly, err = service.GetYearlySummaryWithOptions(models.ReportOptions{DateBasis: models.DateBasisImport, BaseCurrency: "USD"})
+	if err != nil {
+		t.Fatalf("GetYearlySummaryWithOptions failed: %v", err)
+	}
+	if len(yearly) != 1 || yearly[0].TotalSales != 190.00 {
+		t.Errorf("Expected 2024 with the EUR sale converted at the 2023 rate, got %+v", yearly)
+	}
+
+	from := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)
+	entered, err := service.GetCustomSummaryWithFilter(models.CustomSummaryRequest{
+		GroupBy:   "vendor",
+		Filter:    models.SalesRecordFilter{DateFrom: &from, DateTo: &to},
+		DateBasis: models.DateBasisImport,
+	})
+	if err != nil {
+		t.Fatalf("GetCustomSummaryWithFilter failed: %v", err)
+	}
+	if len(entered) != 2 || entered[0].Period != "Vendor 2" || entered[1].Period != "Vendor 1" || entered[1].TotalSales != 100.00 {
+		t.Errorf("Expected the records entered that week by vendor, got %+v", entered)
+	}
+	sold, err := service.GetCustomSummaryWithFilter(models.CustomSummaryRequest{GroupBy: "vendor", Filter: models.SalesRecordFilter{DateFrom: &from, DateTo: &to}})
+	if err != nil {
+		t.Fatalf("GetCustomSummaryWithFilter failed: %v", err)
+	}
+	if len(sold) != 0 {
+		t.Errorf("Expected no sales dated that week, got %+v", sold)
+	}
+
+	if _, err := service.GetMonthlySummaryWithOptions(nil, models.ReportOptions{DateBasis: "created"}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected an unknown date basis to be rejected, got %v", err)
+	}
+}
+
+func TestReportingRepository(t *testing.T) {
+	// Setup test database with sample data
+	config := Config{
+		InMemory:    true,
+		AutoMigrate: true,
+	}
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	salesRepo := NewSalesRepository(db)
+	reportingRepo := NewReportingRepository(db)
+
+	// Insert test data
+	testRecords := []models.CreateSalesRecordRequest{
+		{
+			Store:       "Store A",
+			Vendor:      "Vendor 1",
+			Date:        "2024-01-15",
+			Description: "Product A",
+			SalePrice:   100.00,
+			Commission:  floatPtr(10.00),
+			Remaining:   floatPtr(90.00),
+		},
+		{
+			Store:       "Store A",
+			Vendor:      "Vendor 2",
+			Date:        "2024-02-15",
+			Description: "Product B",
+			SalePrice:   200.00,
+			Commission:  floatPtr(20.00),
+			Remaining:   floatPtr(180.00),
+		},
+		{
+			Store:       "Store B",
+			Vendor:      "Vendor 1",
+			Date:        "2024-01-20",
+			Description: "Product C",
+			SalePrice:   150.00,
+			Commission:  floatPtr(15.00),
+			Remaining:   floatPtr(135.00),
+		},
+	}
+
+	_, err = salesRepo.CreateBatch(testRecords)
+	if err != nil {
+		t.Fatalf("Failed to create test records: %v", err)
+	}
+
+	// Test yearly summary
+	yearly, err := reportingRepo.GetYearlySummary()
+	if err != nil {
+		t.Fatalf("Failed to get yearly summary: %v", err)
+	}
+
+	if len(yearly) != 1 {
+		t.Errorf("Expected 1 year, got %d", len(yearly))
+	}
+	if yearly[0].Year != "2024" {
+		t.Errorf("Expected year 2024, got %s", yearly[0].Year)
+	}
+	if yearly[0].ItemsSold != 3 {
+		t.Errorf("Expected 3 items sold, got %d", yearly[0].ItemsSold)
+	}
+
+	// Test monthly summary
+	monthly, err := reportingRepo.GetMonthlySummary(nil)
+	if err != nil {
+		t.Fatalf("Failed to get monthly summary: %v", err)
+	}
+
+	if len(monthly) != 2 {
+		t.Errorf("Expected 2 months, got %d", len(monthly))
+	}
+
+	// Test store performance
+	storePerf, err := reportingRepo.GetStorePerformance()
+	if err != nil {
+		t.Fatalf("Failed to get store performance: %v", err)
+	}
+
+	if len(storePerf) != 2 {
+		t.Errorf("Expected 2 stores, got %d", len(storePerf))
+	}
+
+	// Test vendor performance
+	vendorPerf, err := reportingRepo.GetVendorPerformance()
+	if err != nil {
+		t.Fatalf("Failed to get vendor performance: %v", err)
+	}
+
+	if len(vendorPerf) != 2 {
+		t.Errorf("Expected 2 vendors, got %d", len(vendorPerf))
+	}
+
+	// Test pivot table data
+	pivotData, err := reportingRepo.GetPivotTableData(nil)
+	if err != nil {
+		t.Fatalf("Failed to get pivot table data: %v", err)
+	}
+
+	if len(pivotData.YearlyData) != 1 {
+		t.Errorf("Expected 1 year in pivot data, got %d", len(pivotData.YearlyData))
+	}
+	if len(pivotData.MonthlyData) != 2 {
+		t.Errorf("Expected 2 months in pivot data, got %d", len(pivotData.MonthlyData))
+	}
+}
+
+// TestUnknownCommission verifies that unknown commissions are stored as NULL
+// and ignored by aggregates instead of being treated as zero
+func TestUnknownCommission(t *testing.T) {
+	db, err := New(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	salesRepo := NewSalesRepository(db)
+	reportingRepo := NewReportingRepository(db)
+
+	records := []models.CreateSalesRecordRequest{
+		{
+			Store:       "Store A",
+			Vendor:      "Vendor 1",
+			Date:        "2024-03-01",
+			Description: "Known commission",
+			SalePrice:   100.00,
+			Commission:  floatPtr(20.00),
+			Remaining:   floatPtr(80.00),
+		},
+		{
+			Store:       "Store A",
+			Vendor:      "Vendor 1",
+			Date:        "2024-03-02",
+			Description: "Unknown commission",
+			SalePrice:   300.00,
+		},
+	}
+
+	created, err := salesRepo.CreateBatch(records)
+	if err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+	if created[1].Commission != nil || created[1].Remaining != nil {
+		t.Errorf("Expected unknown commission and remaining to stay nil, got %v / %v",
+			created[1].Commission, created[1].Remaining)
+	}
+
+	monthly, err := reportingRepo.GetMonthlySummary(nil)
+	if err != nil {
+		t.Fatalf("Failed to get monthly summary: %v", err)
+	}
+	if len(monthly) != 1 {
+		t.Fatalf("Expected 1 month, got %d", len(monthly))
+	}
+	if monthly[0].TotalCommission != 20.00 {
+		t.Errorf("Expected total commission 20.00, got %.2f", monthly[0].TotalCommission)
+	}
+	if monthly[0].CommissionKnown != 1 {
+		t.Errorf("Expected 1 row with known commission, got %d", monthly[0].CommissionKnown)
+	}
+	// 20 / 100, not 20 / 400: the unknown row must not dilute the rate
+	if monthly[0].CommissionRate != 0.2 {
+		t.Errorf("Expected commission rate 0.2, got %f", monthly[0].CommissionRate)
+	}
+}
+
+// TestDateJSONFormatting verifies that dates round-trip through the database
+// and serialize as date-only strings
+func TestDateJSONFormatting(t *testing.T) {
+	db, err := New(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewSalesRepository(db)
+	created, err := repo.Create(models.CreateSalesRecordRequest{
+		Store:       "Store A",
+		Vendor:      "Vendor 1",
+		Date:        "2024-02-29",
+		Description: "Leap day sale",
+		SalePrice:   10.00,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create record: %v", err)
+	}
+
+	data, err := json.Marshal(created)
+	if err != nil {
+		t.Fatalf("Failed to marshal record: %v", err)
+	}
+	if !strings.Contains(string(data), `"date":"2024-02-29"`) {
+		t.Errorf("Expected date-only JSON, got %s", data)
+	}
+
+	daily, err := NewReportingRepository(db).GetDailySummary(nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to get daily summary: %v", err)
+	}
+	data, err = json.Marshal(daily[0])
+	if err != nil {
+		t.Fatalf("Failed to marshal daily summary: %v", err)
+	}
+	if !strings.Contains(string(data), `"date":"2024-02-29"`) {
+		t.Errorf("Expected date-only JSON in daily summary, got %s", data)
+	}
+}
+
+// TestTopProductsGrouping verifies that description variants share a product key
+func TestTopProductsGrouping(t *testing.T) {
+	if models.ProductKey("Dell XPS 13 Laptop") != models.ProductKey("Laptop - Dell XPS13") {
+		t.Errorf("Expected matching keys, got %q and %q",
+			models.ProductKey("Dell XPS 13 Laptop"), models.ProductKey("Laptop - Dell XPS13"))
+	}
+
+	db, err := New(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	records := []models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Dell XPS 13 Laptop", SalePrice: 900.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-16", Description: "Laptop - Dell XPS13", SalePrice: 850.00},
+		{Store: "Store B", Vendor: "Vendor 2", Date: "2024-01-17", Description: "Desk Lamp", SalePrice: 25.00},
+	}
+	if _, err := NewSalesRepository(db).CreateBatch(records); err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	products, err := NewReportingRepository(db).GetTopProducts(10)
+	if err != nil {
+		t.Fatalf("Failed to get top products: %v", err)
+	}
+	if len(products) != 2 {
+		t.Fatalf("Expected 2 products, got %d", len(products))
+	}
+	if products[0].ItemsSold != 2 || products[0].Variants != 2 {
+		t.Errorf("Expected laptop group with 2 items and 2 variants, got %+v", products[0])
+	}
+	if products[0].TotalSales != 1750.00 {
+		t.Errorf("Expected total sales 1750.00, got %.2f", products[0].TotalSales)
+	}
+}
+
+// TestDatabaseService tests the high-level service layer
+func TestDatabaseService(t *testing.T) {
+	config := Config{
+		InMemory:    true,
+		AutoMigrate: true,
+	}
+
+	service, err := NewService(config)
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	// Test health check
+	if err := service.Health(); err != nil {
+		t.Errorf("Service should be healthy: %v", err)
+	}
+
+	// Test import functionality
+	records := []models.CreateSalesRecordRequest{
+		{
+			Store:       "Test Store",
+			Vendor:      "Test Vendor",
+			Date:        "2024-01-15",
+			Description: "Test Product",
+			SalePrice:   100.00,
+			Commission:  floatPtr(10.00),
+			Remaining:   floatPtr(90.00),
+		},
+	}
+
+	result, err := service.ImportSalesData(records)
+	if err != nil {
+		t.Fatalf("Failed to import sales data: %v", err)
+	}
+
+	if result.TotalRecords != 1 {
+		t.Errorf("Expected 1 total record, got %d", result.TotalRecords)
+	}
+	if result.SuccessfulRecords != 1 {
+		t.Errorf("Expected 1 successful record, got %d", result.SuccessfulRecords)
+	}
+	if result.FailedRecords != 0 {
+		t.Errorf("Expected 0 failed records, got %d", result.FailedRecords)
+	}
+
+	// Test database stats
+	stats, err := service.GetDatabaseStats()
+	if err != nil {
+		t.Fatalf("Failed to get database stats: %v", err)
+	}
+
+	if stats.TotalRecords != 1 {
+		t.Errorf("Expected 1 total record, got %d", stats.TotalRecords)
+	}
+}
+
+// TestQueryPlanDiagnostics tests EXPLAIN QUERY PLAN reporting for the common queries
+func TestQueryPlanDiagnostics(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	reports, err := service.GetQueryPlanDiagnostics()
+	if err != nil {
+		t.Fatalf("GetQueryPlanDiagnostics failed: %v", err)
+	}
+
+	if len(reports) != len(diagnosticQueries()) {
+		t.Errorf("Expected %d reports, got %d", len(diagnosticQueries()), len(reports))
+	}
+
+	byName := make(map[string]QueryPlanReport)
+	for _, report := range reports {
+		if report.Error != "" {
+			t.Errorf("Query %q could not be explained: %s", report.Name, report.Error)
+		}
+		if len(report.Steps) == 0 {
+			t.Errorf("Query %q has no plan steps", report.Name)
+		}
+		byName[report.Name] = report
+	}
+
+	// Store filters are covered by idx_sales_records_store_date
+	if warnings := byName["List by store"].Warnings; len(warnings) != 0 {
+		t.Errorf("Expected no warnings for store list, got %v", warnings)
+	}
+	// There is no index on sale_price, so sorting by it scans the table
+	if warnings := byName["List sorted by sale price"].Warnings; len(warnings) == 0 {
+		t.Error("Expected warnings for sale price sort")
+	}
+}
+
+// TestQueryCache tests LRU eviction and generation-based invalidation
+func TestQueryCache(t *testing.T) {
+	cache := newQueryCache(2)
+
+	_, gen, _ := cache.get("a")
+	cache.put("a", 1, gen)
+	cache.put("b", 2, gen)
+	cache.get("a") // a becomes most recently used
+	cache.put("c", 3, gen)
+
+	if _, _, ok := cache.get("b"); ok {
+		t.Error("Expected least recently used entry to be evicted")
+	}
+	if value, _, ok := cache.get("a"); !ok || value != 1 {
+		t.Errorf("Expected a=1 to be cached, got %v (%v)", value, ok)
+	}
+
+	// A value loaded before an invalidation must not be stored
+	_, staleGen, _ := cache.get("d")
+	cache.invalidate()
+	cache.put("d", 4, staleGen)
+	if _, _, ok := cache.get("d"); ok {
+		t.Error("Expected stale value to be discarded")
+	}
+	if _, _, ok := cache.get("a"); ok {
+		t.Error("Expected invalidate to drop all entries")
+	}
+}
+
+// TestServiceStatsCacheInvalidation tests that cached statistics reflect writes
+func TestServiceStatsCacheInvalidation(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	record := models.CreateSalesRecordRequest{
+		Store:       "Store A",
+		Vendor:      "Vendor 1",
+		Date:        time.Now().Format("2006-01-02"),
+		Description: "Product",
+		SalePrice:   10.00,
+	}
+
+	assertCounts := func(step string, total, recent int64) {
+		t.Helper()
+		stats, err := service.GetDatabaseStats()
+		if err != nil {
+			t.Fatalf("%s: GetDatabaseStats failed: %v", step, err)
+		}
+		if stats.TotalRecords != total {
+			t.Errorf("%s: expected %d total records, got %d", step, total, stats.TotalRecords)
+		}
+		count, err := service.GetRecentRecordCount(30)
+		if err != nil {
+			t.Fatalf("%s: GetRecentRecordCount failed: %v", step, err)
+		}
+		if count != recent {
+			t.Errorf("%s: expected %d recent records, got %d", step, recent, count)
+		}
+	}
+
+	assertCounts("empty", 0, 0)
+
+	created, err := service.CreateSalesRecord(record)
+	if err != nil {
+		t.Fatalf("CreateSalesRecord failed: %v", err)
+	}
+	assertCounts("after create", 1, 1)
+
+	err = service.ExecTx(func(tx *Service) error {
+		_, err := tx.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{record, record})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("ExecTx failed: %v", err)
+	}
+	assertCounts("after batch", 3, 3)
+
+	if err := service.DeleteSalesRecord(created.ID); err != nil {
+		t.Fatalf("DeleteSalesRecord failed: %v", err)
+	}
+	assertCounts("after delete", 2, 2)
+
+	// Returned stats are copies, so callers cannot corrupt the cache
+	stats, _ := service.GetDatabaseStats()
+	stats.TotalRecords = 100
+	assertCounts("after caller mutation", 2, 2)
+}
+
+// TestServiceConcurrentAccess exercises concurrent imports, edits, and report
+// reads through one Service, as happens when Wails bindings are invoked in
+// parallel. Run with -race.
+func TestServiceConcurrentAccess(t *testing.T) {
+	for _, inMemory := range []bool{false, true} {
+		t.Run(fmt.Sprintf("in_memory=%v", inMemory), func(t *testing.T) {
+			config := Config{InMemory: inMemory, AutoMigrate: true}
+			if !inMemory {
+				config.FilePath = filepath.Join(t.TempDir(), "concurrent.db")
+			}
+			service, err := NewService(config)
+			if err != nil {
+				t.Fatalf("Failed to create service: %v", err)
+			}
+			defer service.Close()
+
+			const workers = 4
+			const rounds = 10
+
+			generator := synthetic.NewGenerator(7, 1)
+			batches := make([][]models.CreateSalesRecordRequest, workers*rounds)
+			for i := range batches {
+				batches[i] = generator.Records(20)
+			}
+
+			var wg sync.WaitGroup
+			errs := make(chan error, workers*4*rounds)
+
+			for w := 0; w < workers; w++ {
+				wg.Add(4)
+
+				// Batch imports
+				go func(w int) {
+					defer wg.Done()
+					for r := 0; r < rounds; r++ {
+						if _, err := service.CreateSalesRecordsBatch(batches[w*rounds+r]); err != nil {
+							errs <- fmt.Errorf("batch import: %w", err)
+						}
+					}
+				}(w)
+
+				// Create, edit, and delete single records
+				go func(w int) {
+					defer wg.Done()
+					for r := 0; r < rounds; r++ {
+						created, err := service.CreateSalesRecord(batches[w*rounds+r][0])
+						if err != nil {
+							errs <- fmt.Errorf("create: %w", err)
+							continue
+						}
+						price := created.SalePrice + 1
+						if _, err := service.UpdateSalesRecord(created.ID, models.UpdateSalesRecordRequest{SalePrice: &price}); err != nil {
+							errs <- fmt.Errorf("update: %w", err)
+						}
+						if err := service.DeleteSalesRecord(created.ID); err != nil {
+							errs <- fmt.Errorf("delete: %w", err)
+						}
+					}
+				}(w)
+
+				// Transactional imports
+				go func(w int) {
+					defer wg.Done()
+					for r := 0; r < rounds; r++ {
+						err := service.ExecTx(func(tx *Service) error {
+							_, _, err := tx.CreateSalesRecordsBatchPartial(batches[w*rounds+r][:5])
+							return err
+						})
+						if err != nil {
+							errs <- fmt.Errorf("transactional import: %w", err)
+						}
+					}
+				}(w)
+
+				// Report and dashboard reads
+				go func() {
+					defer wg.Done()
+					for r := 0; r < rounds; r++ {
+						if _, err := service.GetDatabaseStats(); err != nil {
+							errs <- fmt.Errorf("stats: %w", err)
+						}
+						if _, err := service.GetYearlySummary(); err != nil {
+							errs <- fmt.Errorf("yearly summary: %w", err)
+						}
+						if _, err := service.ListSalesRecords(models.SalesRecordFilter{Limit: intPtr(10)}); err != nil {
+							errs <- fmt.Errorf("list: %w", err)
+						}
+						if _, err := service.GetTopProducts(5); err != nil {
+							errs <- fmt.Errorf("top products: %w", err)
+						}
+					}
+				}()
+			}
+
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Error(err)
+			}
+
+			// Batches of 20 plus transactional slices of 5; single records were deleted
+			stats, err := service.GetDatabaseStats()
+			if err != nil {
+				t.Fatalf("Failed to get stats: %v", err)
+			}
+			if expected := int64(workers * rounds * 25); stats.TotalRecords != expected {
+				t.Errorf("Expected %d records, got %d", expected, stats.TotalRecords)
+			}
+		})
+	}
+}
+
+// TestMigrations tests the migration system
+func TestMigrations(t *testing.T) {
+	config := Config{
+		InMemory:    true,
+		AutoMigrate: false, // Don't auto-migrate
+	}
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	// Test manual migration
+	err = db.Migrate()
+	if err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	// Test migration status
+	status, err := db.GetMigrationStatus()
+	if err != nil {
+		t.Fatalf("Failed to get migration status: %v", err)
+	}
+
+	if len(status) == 0 {
+		t.Error("Expected at least one migration")
+	}
+
+	// Check that the first migration was applied
+	if !status[0].Applied {
+		t.Error("First migration should be applied")
+	}
+}
+
+// TestTransactions tests transaction handling
+func TestTransactions(t *testing.T) {
+	config := Config{
+		InMemory:    true,
+		AutoMigrate: true,
+	}
+
+	db, err := New(config)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewSalesRepository(db)
+
+	// Test successful transaction
+	err = db.ExecTx(func(tx *sql.Tx) error {
+		// Create record using the repository (which uses the main connection)
+		// In a real transaction scenario, you'd pass the tx to repository methods
+		// For this test, we'll just verify the transaction mechanism works
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("Transaction should succeed: %v", err)
+	}
+
+	// Test creating a record normally (outside transaction)
+	_, err = repo.Create(models.CreateSalesRecordRequest{
+		Store:       "TX Test Store",
+		Vendor:      "TX Test Vendor",
+		Date:        "2024-01-15",
+		Description: "TX Test Product",
+		SalePrice:   100.00,
+		Commission:  floatPtr(10.00),
+		Remaining:   floatPtr(90.00),
+	})
+
+	if err != nil {
+		t.Fatalf("Failed to create record: %v", err)
+	}
+
+	// Verify record was created
+	filter := models.SalesRecordFilter{}
+	list, err := repo.List(filter)
+	if err != nil {
+		t.Fatalf("Failed to list records: %v", err)
+	}
+
+	if list.Total != 1 {
+		t.Errorf("Expected 1 record after creation, got %d", list.Total)
+	}
+}
+
+// BenchmarkSalesRecordCreate benchmarks sales record creation
+func BenchmarkSalesRecordCreate(b *testing.B) {
+	config := Config{
+		InMemory:    true,
+		AutoMigrate: true,
+	}
+
+	db, err := New(config)
+	if err != nil {
+		b.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewSalesRepository(db)
+
+	record := models.CreateSalesRecordRequest{
+		Store:       "Benchmark Store",
+		Vendor:      "Benchmark Vendor",
+		Date:        "2024-01-15",
+		Description: "Benchmark Product",
+		SalePrice:   100.00,
+		Commission:  floatPtr(10.00),
+		Remaining:   floatPtr(90.00),
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := repo.Create(record)
+		if err != nil {
+			b.Fatalf("Failed to create record: %v", err)
+		}
+	}
+}
+
+// BenchmarkSalesRecordQuery benchmarks sales record queries
+func BenchmarkSalesRecordQuery(b *testing.B) {
+	config := Config{
+		InMemory:    true,
+		AutoMigrate: true,
+	}
+
+	db, err := New(config)
+	if err != nil {
+		b.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewSalesRepository(db)
+
+	// Create test data
+	for i := 0; i < 1000; i++ {
+		_, err := repo.Create(models.CreateSalesRecordRequest{
+			Store:       "Store A",
+			Vendor:      "Vendor 1",
+			Date:        "2024-01-15",
+			Description: "Product",
+			SalePrice:   100.00,
+			Commission:  floatPtr(10.00),
+			Remaining:   floatPtr(90.00),
+		})
+		if err != nil {
+			b.Fatalf("Failed to create test record: %v", err)
+		}
+	}
+
+	filter := models.SalesRecordFilter{
+		Limit: intPtr(50),
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := repo.List(filter)
+		if err != nil {
+			b.Fatalf("Failed to query records: %v", err)
+		}
+	}
+}
+
+// largeDatasetSizes returns the record counts used by the large-dataset
+// benchmarks. 100k always runs; set SALES_TRACK_BENCH_1M=1 to add 1M.
+func largeDatasetSizes() []int {
+	sizes := []int{100000}
+	if os.Getenv("SALES_TRACK_BENCH_1M") != "" {
+		sizes = append(sizes, 1000000)
+	}
+	return sizes
+}
+
+// newLargeDataset creates a file database filled with n reproducible synthetic records
+func newLargeDataset(b *testing.B, n int) *Service {
+	b.Helper()
+
+	service, err := NewService(Config{FilePath: filepath.Join(b.TempDir(), "large.db"), AutoMigrate: true})
+	if err != nil {
+		b.Fatalf("Failed to create service: %v", err)
+	}
+
+	records := make(chan models.CreateSalesRecordRequest, 1000)
+	go synthetic.NewGenerator(1, 5).Stream(n, records)
+	if _, err := service.CreateSalesRecordsStream(records, 0, nil); err != nil {
+		service.Close()
+		b.Fatalf("Failed to import synthetic records: %v", err)
+	}
+	return service
+}
+
+// BenchmarkLargeImport measures streaming import throughput
+func BenchmarkLargeImport(b *testing.B) {
+	for _, size := range largeDatasetSizes() {
+		b.Run(fmt.Sprintf("%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				service := newLargeDataset(b, size)
+				service.Close()
+			}
+			b.ReportMetric(float64(size*b.N)/b.Elapsed().Seconds(), "records/s")
+		})
+	}
+}
+
+// BenchmarkLargeQueries measures list and report latency on large datasets
+func BenchmarkLargeQueries(b *testing.B) {
+	for _, size := range largeDatasetSizes() {
+		service := newLargeDataset(b, size)
+
+		store := "Downtown"
+		offset := size / 2
+		year := "2024"
+
+		queries := []struct {
+			name string
+			fn   func() error
+		}{
+			{"ListRecent", func() error { _, err := service.salesRepo.List(models.SalesRecordFilter{}); return err }},
+			{"ListByStore", func() error { _, err := service.salesRepo.List(models.SalesRecordFilter{Store: &store}); return err }},
+			{"ListDeepPage", func() error { _, err := service.salesRepo.List(models.SalesRecordFilter{Offset: &offset}); return err }},
+			{"Stats", func() error { _, err := service.salesRepo.GetStats(); return err }},
+			{"YearlySummary", func() error { _, err := service.GetYearlySummary(); return err }},
+			{"MonthlySummary", func() error { _, err := service.GetMonthlySummary(&year); return err }},
+			{"StorePerformance", func() error { _, err := service.GetStorePerformance(); return err }},
+			{"TopProducts", func() error { _, err := service.GetTopProducts(10); return err }},
+		}
+
+		for _, q := range queries {
+			b.Run(fmt.Sprintf("%d/%s", size, q.name), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if err := q.fn(); err != nil {
+						b.Fatalf("%s failed: %v", q.name, err)
+					}
+				}
+			})
+		}
+
+		service.Close()
+	}
+}
+
+// Helper function to create int pointer
+func intPtr(i int) *int {
+	return &i
+}
+
+// Helper function to create float64 pointer
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestVendorStatement(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	amount := func(f float64) *float64 { return &f }
+	_, err = service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-10", Description: "Lamp", SalePrice: 40.00, Commission: amount(8.00), Remaining: amount(32.00), Cost: amount(5.00)},
+		{Store: "Store B", Vendor: "Vendor 1", Date: "2024-01-20", Description: "Chair", SalePrice: 100.00, Commission: amount(20.00), Remaining: amount(80.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-02-05", Description: "Rug", SalePrice: 60.00, Commission: amount(12.00), Remaining: amount(48.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-02-06", Description: "Rug", SalePrice: 60.00, Commission: amount(12.00), Remaining: amount(48.00), IsReturn: true},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-02-07", Description: "Vase", SalePrice: 15.00},
+		{Store: "Store A", Vendor: "Vendor 2", Date: "2024-01-15", Description: "Desk", SalePrice: 500.00, Commission: amount(100.00), Remaining: amount(400.00)},
+	})
+	if err != nil {
+		t.Fatalf("CreateSalesRecordsBatch failed: %v", err)
+	}
+
+	now := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	statement, err := service.GetVendorStatement(models.VendorStatementFilter{Vendor: "Vendor 1"}, now)
+	if err != nil {
+		t.Fatalf("GetVendorStatement failed: %v", err)
+	}
+	if len(statement.Lines) != 5 || !statement.GeneratedAt.Equal(now) {
+		t.Fatalf("Expected Vendor 1's 5 sales, got %+v", statement.Lines)
+	}
+	for _, line := range statement.Lines {
+		if line.Description == "Desk" {
+			t.Errorf("Expected no other vendor's sales, got %+v", line)
+		}
+	}
+	if statement.From.String() != "2024-01-10" || statement.To.String() != "2024-02-07" {
+		t.Errorf("Expected the statement to span the sales, got %s to %s", statement.From, statement.To)
+	}
+
+	// Settlements are newest month first, then by store
+	expected := []models.VendorSettlement{
+		{Store: "Store A", Month: "2024-02", ItemsSold: 2, ReturnedItems: 1, TotalSales: 15.00},
+		{Store: "Store A", Month: "2024-01", ItemsSold: 1, TotalSales: 40.00, TotalCommission: 8.00, TotalRemaining: 32.00},
+		{Store: "Store B", Month: "2024-01", ItemsSold: 1, TotalSales: 100.00, TotalCommission: 20.00, TotalRemaining: 80.00},
+	}
+	if len(statement.Settlements) != len(expected) {
+		t.Fatalf("Expected settlements %+v, got %+v", expected, statement.Settlements)
+	}
+	for i, want := range expected {
+		if statement.Settlements[i] != want {
+			t.Errorf("Expected settlement %+v, got %+v", want, statement.Settlements[i])
+		}
+	}
+	if statement.Total.ItemsSold != 4 || statement.Total.TotalRemaining != 112.00 {
+		t.Errorf("Unexpected total: %+v", statement.Total)
+	}
+
+	from := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	february, err := service.GetVendorStatement(models.VendorStatementFilter{Vendor: "Vendor 1", DateFrom: &from}, now)
+	if err != nil {
+		t.Fatalf("GetVendorStatement failed: %v", err)
+	}
+	if len(february.Lines) != 3 || february.From.String() != "2024-02-01" {
+		t.Errorf("Expected February's 3 sales from 2024-02-01, got %d from %s", len(february.Lines), february.From)
+	}
+
+	if _, err := service.GetVendorStatement(models.VendorStatementFilter{Vendor: " "}, now); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a statement without a vendor to be rejected, got %v", err)
+	}
+}
+
+func TestExportFormat(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	format, err := service.GetExportFormat()
+	if err != nil {
+		t.Fatalf("GetExportFormat failed: %v", err)
+	}
+	if format != models.DefaultExportFormat() {
+		t.Errorf("Expected the default format before one is saved, got %+v", format)
+	}
+
+	european := models.ExportFormat{
+		DecimalSeparator:   ",",
+		ThousandsSeparator: ".",
+		CSVDelimiter:       ";",
+		CurrencySymbol:     "€",
+		SymbolPlacement:    models.SymbolAfter,
+		DateFormat:         "DD.MM.YYYY",
+	}
+	if err := service.SaveExportFormat(european); err != nil {
+		t.Fatalf("SaveExportFormat failed: %v", err)
+	}
+	format, err = service.GetExportFormat()
+	if err != nil {
+		t.Fatalf("GetExportFormat failed: %v", err)
+	}
+	if format != european {
+		t.Errorf("Expected the saved format, got %+v", format)
+	}
+
+	for name, invalid := range map[string]func(f *models.ExportFormat){
+		"decimal separator":        func(f *models.ExportFormat) { f.DecimalSeparator = ";" },
+		"same separators":          func(f *models.ExportFormat) { f.ThousandsSeparator = "," },
+		"delimiter in numbers":     func(f *models.ExportFormat) { f.CSVDelimiter = "," },
+		"unknown date format":      func(f *models.ExportFormat) { f.DateFormat = "YY/MM/DD" },
+		"unknown placement":        func(f *models.ExportFormat) { f.SymbolPlacement = "middle" },
+		"currency symbol too long": func(f *models.ExportFormat) { f.CurrencySymbol = "dollars and cents" },
+	} {
+		format := european
+		invalid(&format)
+		if err := service.SaveExportFormat(format); !errors.Is(err, ErrValidation) {
+			t.Errorf("Expected a validation error for %s, got %v", name, err)
+		}
+	}
+	if format, _ := service.GetExportFormat(); format != european {
+		t.Errorf("Expected rejected formats not to be saved, got %+v", format)
+	}
+
+	audit, err := service.ListAuditLog(10)
+	if err != nil {
+		t.Fatalf("ListAuditLog failed: %v", err)
+	}
+	if len(audit) != 1 || audit[0].Action != models.AuditSettingsUpdated {
+		t.Errorf("Expected the saved format to be audited once, got %+v", audit)
+	}
+}
+
+func TestPayoutSchedules(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	amount := func(f float64) *float64 { return &f }
+	_, err = service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-10", Description: "Lamp", SalePrice: 40.00, Remaining: amount(32.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-20", Description: "Chair", SalePrice: 100.00, Remaining: amount(80.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-25", Description: "Chair", SalePrice: 100.00, Remaining: amount(80.00), IsReturn: true},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-02-05", Description: "Rug", SalePrice: 60.00},
+		{Store: "Store B", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Desk", SalePrice: 500.00, Remaining: amount(400.00)},
+		{Store: "Store C", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Vase", SalePrice: 15.00, Remaining: amount(12.00)},
+	})
+	if err != nil {
+		t.Fatalf("CreateSalesRecordsBatch failed: %v", err)
+	}
+
+	for _, schedule := range []models.SavePayoutScheduleRequest{
+		{Store: " Store A ", PayoutDay: 31, MonthsAfter: 1},
+		{Store: "Store B", PayoutDay: 5, MonthsAfter: 0},
+		{Store: "Store B", PayoutDay: 20, MonthsAfter: 0}, // Replaces the first
+	} {
+		if _, err := service.SavePayoutSchedule(schedule); err != nil {
+			t.Fatalf("SavePayoutSchedule failed: %v", err)
+		}
+	}
+	for _, invalid := range []models.SavePayoutScheduleRequest{
+		{Store: "", PayoutDay: 1},
+		{Store: "Store C", PayoutDay: 0},
+		{Store: "Store C", PayoutDay: 32},
+		{Store: "Store C", PayoutDay: 1, MonthsAfter: 4},
+	} {
+		if _, err := service.SavePayoutSchedule(invalid); !errors.Is(err, ErrValidation) {
+			t.Errorf("Expected a validation error for %+v, got %v", invalid, err)
+		}
+	}
+
+	schedules, err := service.ListPayoutSchedules()
+	if err != nil {
+		t.Fatalf("ListPayoutSchedules failed: %v", err)
+	}
+	if len(schedules) != 2 || schedules[0].Store != "Store A" || schedules[1].PayoutDay != 20 {
+		t.Fatalf("Expected schedules for Store A and Store B, got %+v", schedules)
+	}
+
+	payouts, err := service.GetExpectedPayouts(models.PayoutFilter{})
+	if err != nil {
+		t.Fatalf("GetExpectedPayouts failed: %v", err)
+	}
+	// Store C has no schedule. Store A pays on the last day of a short month.
+	expected := []struct {
+		store, month, date string
+		items, returned    int64
+		amount             float64
+	}{
+		{"Store B", "2024-01", "2024-01-20", 1, 0, 400.00},
+		{"Store A", "2024-01", "2024-02-29", 2, 1, 32.00},
+		{"Store A", "2024-02", "2024-03-31", 1, 0, 0},
+	}
+	if len(payouts) != len(expected) {
+		t.Fatalf("Expected %d payouts, got %+v", len(expected), payouts)
+	}
+	for i, want := range expected {
+		got := payouts[i]
+		if got.Store != want.store || got.Month != want.month || got.Date.String() != want.date ||
+			got.ItemsSold != want.items || got.ReturnedItems != want.returned || got.Amount != want.amount {
+			t.Errorf("Payout %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+
+	from := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	payouts, err = service.GetExpectedPayouts(models.PayoutFilter{DateFrom: &from})
+	if err != nil {
+		t.Fatalf("GetExpectedPayouts failed: %v", err)
+	}
+	if len(payouts) != 2 || payouts[0].Store != "Store A" {
+		t.Errorf("Expected the 2 payouts from February, got %+v", payouts)
+	}
+
+	if err := service.DeletePayoutSchedule("Store A"); err != nil {
+		t.Fatalf("DeletePayoutSchedule failed: %v", err)
+	}
+	if err := service.DeletePayoutSchedule("Store A"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a missing schedule, got %v", err)
+	}
+}
+
+func TestImportSource(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	run, err := service.RecordImport(models.ImportRun{StartedAt: time.Now(), Source: models.ImportSourcePaste, Method: "batch"})
+	if err != nil {
+		t.Fatalf("RecordImport failed: %v", err)
+	}
+	if _, err := service.GetImportSource(run.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound before a source is saved, got %v", err)
+	}
+
+	content := "<table><tr><th>Store</th></tr>" + strings.Repeat("<tr><td>Störe A</td></tr>", 200) + "</table>"
+	if err := service.SaveImportSource(run.ID, strings.NewReader(content)); err != nil {
+		t.Fatalf("SaveImportSource failed: %v", err)
+	}
+	source, err := service.GetImportSource(run.ID)
+	if err != nil {
+		t.Fatalf("GetImportSource failed: %v", err)
+	}
+	if source.Content != content || source.Size != int64(len(content)) {
+		t.Errorf("Expected the content back unchanged, got %d bytes", source.Size)
+	}
+	if source.StoredSize >= source.Size {
+		t.Errorf("Expected the source to be stored compressed, got %d of %d bytes", source.StoredSize, source.Size)
+	}
+
+	// Sources go with their import run
+	if _, err := service.ApplyRetention(models.RetentionPolicy{PurgeImportHistoryAfterDays: 1}, time.Now().AddDate(0, 0, 2)); err != nil {
+		t.Fatalf("ApplyRetention failed: %v", err)
+	}
+	if _, err := service.GetImportSource(run.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected the source to be purged with its import, got %v", err)
+	}
+}
+
+func TestRecordProvenance(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	first, err := service.RecordImport(models.ImportRun{StartedAt: time.Now(), Source: models.ImportSourcePaste, Method: "upsert"})
+	if err != nil {
+		t.Fatalf("RecordImport failed: %v", err)
+	}
+	externalID := "T-1"
 	record := models.CreateSalesRecordRequest{
-		Store:       "Benchmark Store",
-		Vendor:      "Benchmark Vendor",
-		Date:        "2024-01-15",
-		Description: "Benchmark Product",
-		SalePrice:   100.00,
-		Commission:  10.00,
-		Remaining:   90.00,
+		Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-10", Description: "Lamp", SalePrice: 40.00, ExternalID: &externalID,
+		SourceRow: &models.SourceRow{Row: 2, Headers: []string{"Description", "Sale Price"}, Cells: []string{"Lamp", "$40.00"}},
+	}
+	if _, err := service.ForImport(first.ID).UpsertSalesRecords([]models.CreateSalesRecordRequest{record}); err != nil {
+		t.Fatalf("UpsertSalesRecords failed: %v", err)
+	}
+
+	// A later import that changes the record takes it over
+	second, err := service.RecordImport(models.ImportRun{StartedAt: time.Now(), Source: models.ImportSourcePaste, Method: "upsert"})
+	if err != nil {
+		t.Fatalf("RecordImport failed: %v", err)
+	}
+	record.SalePrice = 45.00
+	record.SourceRow = &models.SourceRow{Row: 5, Headers: []string{"Description", "Sale Price"}, Cells: []string{"Lamp", "$45.00"}}
+	if _, err := service.ForImport(second.ID).UpsertSalesRecords([]models.CreateSalesRecordRequest{record}); err != nil {
+		t.Fatalf("UpsertSalesRecords failed: %v", err)
+	}
+
+	list, err := service.ListSalesRecords(models.SalesRecordFilter{})
+	if err != nil || len(list.Records) != 1 {
+		t.Fatalf("Expected one record, got %+v, %v", list, err)
+	}
+	id := list.Records[0].ID
+
+	commission := 9.00
+	if _, err := service.UpdateSalesRecord(id, models.UpdateSalesRecordRequest{Commission: &commission}); err != nil {
+		t.Fatalf("UpdateSalesRecord failed: %v", err)
+	}
+	// Updates that change nothing are not audited
+	if _, err := service.UpdateSalesRecord(id, models.UpdateSalesRecordRequest{Commission: &commission}); err != nil {
+		t.Fatalf("UpdateSalesRecord failed: %v", err)
+	}
+
+	provenance, err := service.GetRecordProvenance(id)
+	if err != nil {
+		t.Fatalf("GetRecordProvenance failed: %v", err)
+	}
+	if provenance.Import == nil || provenance.Import.ID != second.ID || provenance.HasSource {
+		t.Errorf("Expected the record to trace back to the second import, without a kept source, got %+v", provenance.Import)
+	}
+	if provenance.SourceRow == nil || provenance.SourceRow.Row != 5 || provenance.SourceRow.Cells[1] != "$45.00" {
+		t.Errorf("Expected the second import's source row, got %+v", provenance.SourceRow)
+	}
+	if len(provenance.History) != 1 || provenance.History[0].Details != "commission unknown → 9.00" {
+		t.Errorf("Expected the commission change in the history, got %+v", provenance.History)
+	}
+	if provenance.Adjustments == nil {
+		t.Error("Expected an empty list of adjustments, got nil")
+	}
+
+	// Discarding an import leaves its records without one
+	if err := service.DiscardImport(second.ID); err != nil {
+		t.Fatalf("DiscardImport failed: %v", err)
+	}
+	if provenance, err = service.GetRecordProvenance(id); err != nil || provenance.Import != nil {
+		t.Errorf("Expected no import once it is discarded, got %+v, %v", provenance, err)
+	}
+
+	if _, err := service.GetRecordProvenance(id + 100); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown record, got %v", err)
+	}
+	if err := service.DeleteSalesRecord(id); err != nil {
+		t.Fatalf("DeleteSalesRecord failed: %v", err)
+	}
+}
+
+func TestNameAliases(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	for _, alias := range []models.SaveNameAliasRequest{
+		{Kind: "store", Alias: " DT Branch ", Canonical: "Downtown Store"},
+		{Kind: "store", Alias: "downtown store", Canonical: "Downtown Store"}, // Fixes the case only
+		{Kind: "vendor", Alias: "Smith", Canonical: "Smith & Sons"},
+		{Kind: "vendor", Alias: "SMITH", Canonical: "Smith and Sons"}, // Replaces the previous one
+	} {
+		if _, err := service.SaveNameAlias(alias); err != nil {
+			t.Fatalf("SaveNameAlias failed for %+v: %v", alias, err)
+		}
+	}
+	for _, invalid := range []models.SaveNameAliasRequest{
+		{Kind: "product", Alias: "A", Canonical: "B"},
+		{Kind: "store", Alias: "", Canonical: "B"},
+		{Kind: "store", Alias: "Same", Canonical: "Same"},
+		{Kind: "store", Alias: "Uptown", Canonical: "dt branch"},       // Maps to another alias
+		{Kind: "store", Alias: "Downtown Store", Canonical: "Central"}, // Aliases a canonical name
+	} {
+		if _, err := service.SaveNameAlias(invalid); !errors.Is(err, ErrValidation) {
+			t.Errorf("Expected a validation error for %+v, got %v", invalid, err)
+		}
+	}
+
+	aliases, err := service.ListNameAliases("")
+	if err != nil {
+		t.Fatalf("ListNameAliases failed: %v", err)
+	}
+	// Aliases sort ignoring case
+	if len(aliases) != 3 || aliases[1].Alias != "DT Branch" || aliases[2].Alias != "SMITH" || aliases[2].Canonical != "Smith and Sons" {
+		t.Fatalf("Unexpected aliases: %+v", aliases)
+	}
+	if stores, err := service.ListNameAliases("store"); err != nil || len(stores) != 2 {
+		t.Errorf("Expected 2 store aliases, got %d (%v)", len(stores), err)
+	}
+
+	// Aliases apply to records written through a service that loaded them,
+	// before an upsert looks for the record it updates
+	importer, err := service.WithNameAliases()
+	if err != nil {
+		t.Fatalf("WithNameAliases failed: %v", err)
+	}
+	externalID := "T-1"
+	record := models.CreateSalesRecordRequest{Store: "dt branch", Vendor: "smith", Date: "2024-03-01", Description: "Lamp", SalePrice: 40.00, ExternalID: &externalID}
+	created, err := importer.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{record})
+	if err != nil {
+		t.Fatalf("CreateSalesRecordsBatch failed: %v", err)
+	}
+	if created[0].Store != "Downtown Store" || created[0].Vendor != "Smith and Sons" {
+		t.Errorf("Expected the canonical names, got %q and %q", created[0].Store, created[0].Vendor)
+	}
+	record.Store = "DT Branch"
+	record.SalePrice = 35.00
+	result, err := importer.UpsertSalesRecords([]models.CreateSalesRecordRequest{record})
+	if err != nil {
+		t.Fatalf("UpsertSalesRecords failed: %v", err)
+	}
+	if result.Updated != 1 || result.Inserted != 0 {
+		t.Errorf("Expected the aliased record to update the existing one, got %+v", result)
+	}
+
+	// Other writes keep the names they are given
+	manual, err := service.CreateSalesRecord(models.CreateSalesRecordRequest{Store: "DT Branch", Vendor: "Smith", Date: "2024-03-02", Description: "Rug", SalePrice: 10.00})
+	if err != nil {
+		t.Fatalf("CreateSalesRecord failed: %v", err)
+	}
+	if manual.Store != "DT Branch" {
+		t.Errorf("Expected a record created without aliases to keep its store, got %q", manual.Store)
+	}
+
+	if err := service.DeleteNameAlias(aliases[1].ID); err != nil {
+		t.Fatalf("DeleteNameAlias failed: %v", err)
+	}
+	if err := service.DeleteNameAlias(aliases[1].ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a missing alias, got %v", err)
+	}
+}
+
+func TestCustomSummaryWithFilter(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	antiques := "Antiques"
+	_, err = service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-02", Description: "Brass Lamp", SalePrice: 800.00, Category: &antiques},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-20", Description: "Oak Chair", SalePrice: 400.00},
+		{Store: "Store B", Vendor: "Vendor 2", Date: "2024-03-05", Description: "Lamp_100%", SalePrice: 900.00, Category: &antiques},
+		{Store: "Store B", Vendor: "Vendor 2", Date: "2024-03-06", Description: "Lamp_100%", SalePrice: 300.00, IsReturn: true},
+		{Store: "Store B", Vendor: "Vendor 3", Date: "2024-04-01", Description: "Desk", SalePrice: 2000.00},
+	})
+	if err != nil {
+		t.Fatalf("CreateSalesRecordsBatch failed: %v", err)
+	}
+
+	// Vendors with over $1,000 in March, net of returns
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)
+	minSales := 1000.00
+	summaries, err := service.GetCustomSummaryWithFilter(models.CustomSummaryRequest{
+		GroupBy:       "vendor",
+		Filter:        models.SalesRecordFilter{DateFrom: &from, DateTo: &to},
+		MinTotalSales: &minSales,
+	})
+	if err != nil {
+		t.Fatalf("GetCustomSummaryWithFilter failed: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].Period != "Vendor 1" || summaries[0].TotalSales != 1200.00 {
+		t.Errorf("Expected only Vendor 1 with 1200.00, got %+v", summaries)
+	}
+
+	// Description search is literal and ignores case
+	for search, want := range map[string]float64{"lamp": 1400.00, "_100%": 600.00, "1%": 0} {
+		summaries, err := service.GetCustomSummaryWithFilter(models.CustomSummaryRequest{
+			GroupBy: "year",
+			Filter:  models.SalesRecordFilter{Search: &search},
+		})
+		if err != nil {
+			t.Fatalf("GetCustomSummaryWithFilter failed for %q: %v", search, err)
+		}
+		var total float64
+		for _, summary := range summaries {
+			total += summary.TotalSales
+		}
+		if total != want {
+			t.Errorf("Expected sales of %.2f matching %q, got %.2f", want, search, total)
+		}
+	}
+
+	minItems := int64(2)
+	summaries, err = service.GetCustomSummaryWithFilter(models.CustomSummaryRequest{
+		GroupBy:      "store",
+		Filter:       models.SalesRecordFilter{Category: &antiques, MinPrice: func(f float64) *float64 { return &f }(850)},
+		MinItemsSold: &minItems,
+	})
+	if err != nil {
+		t.Fatalf("GetCustomSummaryWithFilter failed: %v", err)
+	}
+	if len(summaries) != 0 {
+		t.Errorf("Expected no store with two antiques over 850, got %+v", summaries)
+	}
+
+	if _, err := service.GetCustomSummaryWithFilter(models.CustomSummaryRequest{GroupBy: "vendor", Filter: models.SalesRecordFilter{DateFrom: &to, DateTo: &from}}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for reversed dates, got %v", err)
+	}
+	if _, err := service.GetCustomSummaryWithFilter(models.CustomSummaryRequest{GroupBy: "category"}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for an unknown grouping, got %v", err)
+	}
+}
+
+func TestRunReadOnlyQuery(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	_, err = service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-01", Description: "Lamp; brass", SalePrice: 40.00},
+		{Store: "Store B", Vendor: "Vendor 2", Date: "2024-03-02", Description: "Chair", SalePrice: 25.00},
+	})
+	if err != nil {
+		t.Fatalf("CreateSalesRecordsBatch failed: %v", err)
+	}
+
+	result, err := service.RunReadOnlyQuery(`
+		-- Sales by store
+		SELECT store, SUM(sale_price) AS total FROM sales_records
+		WHERE description != 'x;y' AND sale_price > ? GROUP BY store ORDER BY store; `, []interface{}{30})
+	if err != nil {
+		t.Fatalf("RunReadOnlyQuery failed: %v", err)
+	}
+	if len(result.Columns) != 2 || result.Columns[1] != "total" {
+		t.Errorf("Unexpected columns: %v", result.Columns)
+	}
+	if len(result.Rows) != 1 || result.Rows[0][0] != "Store A" || fmt.Sprint(result.Rows[0][1]) != "40" || result.Truncated {
+		t.Errorf("Unexpected rows: %+v", result.Rows)
+	}
+
+	result, err = service.RunReadOnlyQuery("WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 5000) SELECT i FROM n", nil)
+	if err != nil {
+		t.Fatalf("RunReadOnlyQuery failed: %v", err)
+	}
+	if len(result.Rows) != MaxQueryRows || !result.Truncated {
+		t.Errorf("Expected %d rows and a truncated result, got %d (%v)", MaxQueryRows, len(result.Rows), result.Truncated)
+	}
+
+	for _, query := range []string{
+		"DELETE FROM sales_records",
+		"SELECT 1; DELETE FROM sales_records",
+		"/* SELECT */ UPDATE sales_records SET sale_price = 0",
+		"PRAGMA query_only = OFF",
+		"",
+	} {
+		if _, err := service.RunReadOnlyQuery(query, nil); !errors.Is(err, ErrValidation) {
+			t.Errorf("Expected %q to be refused, got %v", query, err)
+		}
+	}
+	// A write behind a WITH clause is refused by the connection
+	if _, err := service.RunReadOnlyQuery("WITH doomed AS (SELECT id FROM sales_records) DELETE FROM sales_records WHERE id IN doomed", nil); err == nil {
+		t.Error("Expected a write behind a WITH clause to fail")
+	}
+
+	// The connection accepts writes again afterwards
+	if _, err := service.CreateSalesRecord(models.CreateSalesRecordRequest{Store: "Store A", Vendor: "Vendor 1", Date: "2024-03-03", Description: "Rug", SalePrice: 10.00}); err != nil {
+		t.Fatalf("CreateSalesRecord failed after a read-only query: %v", err)
+	}
+	if stats, err := service.GetDatabaseStats(); err != nil || stats.TotalRecords != 3 {
+		t.Errorf("Expected 3 records, got %+v (%v)", stats, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = service.GetDB().RunReadOnlyQuery(ctx, "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT COUNT(*) FROM n", nil)
+	if err == nil || !strings.Contains(err.Error(), "longer than") {
+		t.Errorf("Expected the query to be stopped, got %v", err)
+	}
+}
+
+// A connection whose setting cannot be restored is discarded rather than
+// returned to the pool read-only
+func TestRestoreConnDiscardsOnFailure(t *testing.T) {
+	db, err := New(Config{InMemory: true})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+		t.Fatalf("Failed to make connection read-only: %v", err)
+	}
+	restoreConn(conn, "NOT A STATEMENT")
+	conn.Close()
+
+	// The database has a single connection, so this write gets a fresh one
+	if _, err := db.conn.Exec("CREATE TABLE restored (id INTEGER)"); err != nil {
+		t.Errorf("Expected the read-only connection to be discarded, got %v", err)
+	}
+}
+
+func TestReportSnapshots(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	amount := func(f float64) *float64 { return &f }
+	created, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-10", Description: "Lamp", SalePrice: 40.00, Commission: amount(8.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-12", Description: "Chair", SalePrice: 100.00, Commission: amount(20.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-20", Description: "Rug", SalePrice: 30.00},
+		{Store: "Store B", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Desk", SalePrice: 500.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-02-01", Description: "Vase", SalePrice: 15.00},
+	})
+	if err != nil {
+		t.Fatalf("CreateSalesRecordsBatch failed: %v", err)
+	}
+
+	store := "Store A"
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	limit := 1
+	snapshot, err := service.CreateReportSnapshot(models.CreateReportSnapshotRequest{
+		Name:   " January settlement ",
+		Filter: models.SalesRecordFilter{Store: &store, DateFrom: &from, DateTo: &to, Limit: &limit},
+	})
+	if err != nil {
+		t.Fatalf("CreateReportSnapshot failed: %v", err)
+	}
+	if snapshot.Name != "January settlement" || snapshot.Totals.Records != 3 || snapshot.Totals.TotalSales != 170.00 || snapshot.Totals.TotalCommission != 28.00 {
+		t.Errorf("Unexpected snapshot: %+v", snapshot)
+	}
+	if _, err := service.CreateReportSnapshot(models.CreateReportSnapshotRequest{Name: " "}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error without a name, got %v", err)
+	}
+
+	// The store amends January: a price is corrected, a sale is deleted, one
+	// moves into the month and a new sale appears
+	price := 90.00
+	if _, err := service.UpdateSalesRecord(created[1].ID, models.UpdateSalesRecordRequest{SalePrice: &price}); err != nil {
+		t.Fatalf("UpdateSalesRecord failed: %v", err)
+	}
+	if err := service.DeleteSalesRecord(created[2].ID); err != nil {
+		t.Fatalf("DeleteSalesRecord failed: %v", err)
+	}
+	date := "2024-01-31"
+	if _, err := service.UpdateSalesRecord(created[4].ID, models.UpdateSalesRecordRequest{Date: &date}); err != nil {
+		t.Fatalf("UpdateSalesRecord failed: %v", err)
+	}
+
+	comparison, err := service.CompareReportSnapshot(snapshot.ID)
+	if err != nil {
+		t.Fatalf("CompareReportSnapshot failed: %v", err)
+	}
+	if len(comparison.Added) != 1 || comparison.Added[0].ID != created[4].ID {
+		t.Errorf("Expected the moved record to be added, got %+v", comparison.Added)
+	}
+	if len(comparison.Removed) != 1 || comparison.Removed[0].ID != created[2].ID || comparison.Removed[0].Description != "Rug" {
+		t.Errorf("Expected the deleted record to be removed, got %+v", comparison.Removed)
+	}
+	if len(comparison.Changed) != 1 || comparison.Changed[0].Changes != "sale price 100.00 → 90.00" || comparison.Changed[0].Before.SalePrice != 100.00 {
+		t.Errorf("Expected the corrected price, got %+v", comparison.Changed)
+	}
+	if comparison.Current.Records != 3 || comparison.Current.TotalSales != 145.00 || comparison.Snapshot.Totals.TotalSales != 170.00 {
+		t.Errorf("Unexpected totals: then %+v, now %+v", comparison.Snapshot.Totals, comparison.Current)
+	}
+
+	snapshots, err := service.ListReportSnapshots()
+	if err != nil || len(snapshots) != 1 || snapshots[0].Filter.Store == nil || *snapshots[0].Filter.Store != "Store A" {
+		t.Fatalf("Expected the snapshot with its filter, got %+v (%v)", snapshots, err)
+	}
+	if err := service.DeleteReportSnapshot(snapshot.ID); err != nil {
+		t.Fatalf("DeleteReportSnapshot failed: %v", err)
+	}
+	if _, err := service.CompareReportSnapshot(snapshot.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a deleted snapshot, got %v", err)
+	}
+}
+
+func TestImportName(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	run, err := service.RecordImport(models.ImportRun{StartedAt: time.Now(), Source: models.ImportSourcePaste, Method: "batch"})
+	if err != nil {
+		t.Fatalf("RecordImport failed: %v", err)
+	}
+	records := []models.CreateSalesRecordRequest{
+		{Store: "Uptown", Vendor: "Vendor 1", Date: "2024-01-30", Description: "Lamp", SalePrice: 40.00},
+		{Store: "Downtown Store", Vendor: "Vendor 1", Date: "2024-02-02", Description: "Chair", SalePrice: 100.00},
+		{Store: "Downtown Store", Vendor: "Vendor 1", Date: "2024-03-15", Description: "Rug", SalePrice: 30.00},
+	}
+	if _, err := service.ForImport(run.ID).CreateSalesRecordsBatch(records); err != nil {
+		t.Fatalf("CreateSalesRecordsBatch failed: %v", err)
+	}
+
+	label := "Consignable"
+	if err := service.FinishImport(models.ImportRun{ID: run.ID, Success: true, TotalRows: 4, Name: &label}); err != nil {
+		t.Fatalf("FinishImport failed: %v", err)
+	}
+	history, err := service.ListImportRuns(0)
+	if err != nil || len(history) != 1 {
+		t.Fatalf("Expected one import, got %+v, %v", history, err)
+	}
+	if name := history[0].Name; name == nil || *name != "Consignable – January–March 2024 – Downtown Store, 4 rows" {
+		t.Errorf("Unexpected import name: %v", name)
+	}
+
+	for _, tc := range []struct {
+		content importContent
+		rows    int
+		want    string
+	}{
+		{importContent{Records: 1, FirstDate: "2024-03-01", LastDate: "2024-03-31", Store: "Etsy"}, 1, "Etsy – March 2024 – Etsy, 1 row"},
+		{importContent{Records: 2, FirstDate: "2023-12-30", LastDate: "2024-01-02", Store: "Etsy"}, 2, "Etsy – December 2023–January 2024 – Etsy, 2 rows"},
+		{importContent{}, 5, "Etsy, 5 rows"},
+	} {
+		if got := importName("Etsy", tc.content, tc.rows); got != tc.want {
+			t.Errorf("importName(%+v) = %q, want %q", tc.content, got, tc.want)
+		}
+	}
+}
+
+func TestImportTimings(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	run, err := service.RecordImport(models.ImportRun{StartedAt: time.Now(), Source: models.ImportSourcePaste, Method: "upsert"})
+	if err != nil {
+		t.Fatalf("RecordImport failed: %v", err)
+	}
+	if run.Timings != nil {
+		t.Errorf("Expected no timings before the import finished, got %+v", run.Timings)
+	}
+
+	txID := func(s string) *string { return &s }
+	records := []models.CreateSalesRecordRequest{
+		{Store: "Uptown", Vendor: "Vendor 1", Date: "2024-01-30", Description: "Lamp", SalePrice: 40.00, ExternalID: txID("TX-1")},
+		{Store: "Uptown", Vendor: "Vendor 1", Date: "2024-02-02", Description: "Chair", SalePrice: 100.00, ExternalID: txID("TX-2")},
+	}
+	bound := service.ForImport(run.ID)
+	if _, err := bound.UpsertSalesRecords(records); err != nil {
+		t.Fatalf("UpsertSalesRecords failed: %v", err)
+	}
+	records[1].SalePrice = 110.00
+	if _, err := bound.UpsertSalesRecords(records); err != nil {
+		t.Fatalf("UpsertSalesRecords failed: %v", err)
+	}
+	timings := bound.ImportTimings()
+	if timings.Dedupe <= 0 || timings.Insert <= 0 {
+		t.Errorf("Expected lookup and write time to be counted, got %+v", timings)
+	}
+	if unbound := service.ImportTimings(); unbound != (models.ImportTimings{}) {
+		t.Errorf("Expected no timings for a service not bound to an import, got %+v", unbound)
+	}
+
+	timings.Parse = models.Duration(1500 * time.Millisecond)
+	timings.Validate = models.Duration(250 * time.Millisecond)
+	if err := service.FinishImport(models.ImportRun{ID: run.ID, Success: true, TotalRows: 2, Timings: &timings}); err != nil {
+		t.Fatalf("FinishImport failed: %v", err)
+	}
+	history, err := service.ListImportRuns(0)
+	if err != nil || len(history) != 1 {
+		t.Fatalf("Expected one import, got %+v, %v", history, err)
+	}
+	stored := history[0].Timings
+	if stored == nil || stored.Parse != timings.Parse || stored.Validate != timings.Validate {
+		t.Errorf("Expected the timings to be stored in milliseconds, got %+v", stored)
+	}
+}
+
+func TestIgnoreRules(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	rules, err := service.GetIgnoreRules()
+	if err != nil || len(rules) != 0 {
+		t.Fatalf("Expected no rules before any are saved, got %+v, %v", rules, err)
+	}
+
+	saved, err := service.SaveIgnoreRules([]models.IgnoreRule{
+		{Field: models.IgnoreFieldDescription, Pattern: " LISTING FEE "},
+		{Field: models.IgnoreFieldStore, Pattern: "^Online$"},
+	})
+	if err != nil {
+		t.Fatalf("SaveIgnoreRules failed: %v", err)
+	}
+	if saved[0].Pattern != "LISTING FEE" {
+		t.Errorf("Expected the pattern to be trimmed, got %q", saved[0].Pattern)
+	}
+	rules, err = service.GetIgnoreRules()
+	if err != nil || len(rules) != 2 || rules[1].Field != models.IgnoreFieldStore {
+		t.Errorf("Expected the saved rules back, got %+v, %v", rules, err)
+	}
+
+	for _, invalid := range [][]models.IgnoreRule{
+		{{Field: "vendor", Pattern: "x"}},
+		{{Field: models.IgnoreFieldDescription, Pattern: " "}},
+		{{Field: models.IgnoreFieldDescription, Pattern: "fee("}},
+	} {
+		if _, err := service.SaveIgnoreRules(invalid); !errors.Is(err, ErrValidation) {
+			t.Errorf("Expected a validation error for %+v, got %v", invalid, err)
+		}
+	}
+
+	entries, err := service.ListAuditLog(0)
+	if err != nil {
+		t.Fatalf("ListAuditLog failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Details != `Ignore rules: description ~ "LISTING FEE", store ~ "^Online$"` {
+		t.Errorf("Expected the saved rules to be audited, got %+v", entries)
+	}
+}
+
+func TestFees(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	amount := func(f float64) *float64 { return &f }
+	_, err = service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-10", Description: "Lamp", SalePrice: 40.00, Remaining: amount(32.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-12", Description: "Chair", SalePrice: 100.00, Remaining: amount(80.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-20", Description: "Chair", SalePrice: 100.00, Remaining: amount(80.00), IsReturn: true},
+		{Store: "Store B", Vendor: "Vendor 1", Date: "2024-02-15", Description: "Desk", SalePrice: 500.00, Remaining: amount(400.00)},
+	})
+	if err != nil {
+		t.Fatalf("CreateSalesRecordsBatch failed: %v", err)
+	}
+
+	rent, err := service.CreateFee(models.CreateFeeRequest{Store: " Store A ", Date: "2024-01-31", Category: models.FeeCategoryRent, Description: "Booth rent", Amount: 25.00})
+	if err != nil {
+		t.Fatalf("CreateFee failed: %v", err)
+	}
+	if rent.Store != "Store A" || rent.Category != models.FeeCategoryRent || rent.ImportRunID != nil {
+		t.Errorf("Unexpected fee: %+v", rent)
+	}
+	for name, fee := range map[string]models.CreateFeeRequest{
+		"no store":         {Date: "2024-01-31", Description: "Rent", Amount: 1},
+		"bad date":         {Store: "Store A", Date: "01/31/2024", Description: "Rent", Amount: 1},
+		"no description":   {Store: "Store A", Date: "2024-01-31", Amount: 1},
+		"negative amount":  {Store: "Store A", Date: "2024-01-31", Description: "Rent", Amount: -1},
+		"unknown category": {Store: "Store A", Date: "2024-01-31", Description: "Rent", Amount: 1, Category: "tax"},
+	} {
+		if _, err := service.CreateFee(fee); !errors.Is(err, ErrValidation) {
+			t.Errorf("%s: expected a validation error, got %v", name, err)
+		}
+	}
+
+	// Fees read from an import are attributed to it, under canonical names
+	if _, err := service.SaveNameAlias(models.SaveNameAliasRequest{Kind: models.AliasKindStore, Alias: "store b (online)", Canonical: "Store B"}); err != nil {
+		t.Fatalf("SaveNameAlias failed: %v", err)
+	}
+	run, err := service.RecordImport(models.ImportRun{StartedAt: time.Now(), Source: "paste", Method: "ImportHTMLData"})
+	if err != nil {
+		t.Fatalf("RecordImport failed: %v", err)
+	}
+	importer, err := service.WithNameAliases()
+	if err != nil {
+		t.Fatalf("WithNameAliases failed: %v", err)
+	}
+	imported, err := importer.ForImport(run.ID).CreateFees([]models.CreateFeeRequest{
+		{Store: "Store A", Date: "2024-01-15", Category: models.FeeCategoryListing, Description: "Listing fee", Amount: 2.50},
+		{Store: "Store B (Online)", Date: "2024-02-28", Category: models.FeeCategoryPenalty, Description: "Late fee", Amount: 10.00},
+	})
+	if err != nil {
+		t.Fatalf("CreateFees failed: %v", err)
+	}
+	if len(imported) != 2 || imported[1].Store != "Store B" || imported[0].ImportRunID == nil || *imported[0].ImportRunID != run.ID {
+		t.Errorf("Unexpected imported fees: %+v", imported)
+	}
+	if _, err := service.CreateFees([]models.CreateFeeRequest{
+		{Store: "Store A", Date: "2024-01-15", Description: "Listing fee", Amount: 1},
+		{Store: "Store A", Date: "2024-01-15", Description: "Listing fee", Amount: -1},
+	}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for a negative fee, got %v", err)
+	}
+
+	store := "Store A"
+	fees, err := service.ListFees(models.FeeFilter{Store: &store})
+	if err != nil || len(fees) != 2 || fees[0].ID != rent.ID {
+		t.Errorf("Expected Store A's fees newest first, got %+v, %v", fees, err)
+	}
+
+	summary, err := service.GetMonthlyFeeSummary(nil, nil)
+	if err != nil {
+		t.Fatalf("GetMonthlyFeeSummary failed: %v", err)
+	}
+	if len(summary) != 2 || summary[0].Month != "2024-02" || summary[1].Fees != 2 || summary[1].Total != 27.50 || summary[1].ByCategory[models.FeeCategoryRent] != 25.00 {
+		t.Errorf("Unexpected fee summary: %+v", summary)
+	}
+
+	// January's remaining is net of the returned chair
+	year := "2024"
+	income, err := service.GetNetIncome(&year, &store)
+	if err != nil {
+		t.Fatalf("GetNetIncome failed: %v", err)
+	}
+	if len(income) != 1 || income[0].TotalRemaining != 32.00 || income[0].TotalFees != 27.50 || income[0].NetIncome != 4.50 {
+		t.Errorf("Unexpected net income: %+v", income)
+	}
+	income, err = service.GetNetIncome(nil, nil)
+	if err != nil || len(income) != 2 || income[0].Month != "2024-02" || income[0].NetIncome != 390.00 {
+		t.Errorf("Unexpected net income for every store: %+v, %v", income, err)
+	}
+
+	if err := service.DeleteFee(rent.ID); err != nil {
+		t.Fatalf("DeleteFee failed: %v", err)
+	}
+	if err := service.DeleteFee(rent.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a deleted fee, got %v", err)
+	}
+}
+
+func TestPeriodClose(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	created, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-10", Description: "Lamp", SalePrice: 40.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-31", Description: "Chair", SalePrice: 100.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-02-01", Description: "Vase", SalePrice: 15.00},
+	})
+	if err != nil {
+		t.Fatalf("CreateSalesRecordsBatch failed: %v", err)
+	}
+
+	note := " Settled against statement #12 "
+	period, err := service.ClosePeriod(models.ClosePeriodRequest{Month: "2024-01", Note: &note})
+	if err != nil {
+		t.Fatalf("ClosePeriod failed: %v", err)
+	}
+	if period.Month != "2024-01" || !period.Closed() || period.SnapshotID == nil || *period.Note != "Settled against statement #12" {
+		t.Errorf("Unexpected close: %+v", period)
+	}
+	snapshot, err := service.CompareReportSnapshot(*period.SnapshotID)
+	if err != nil || snapshot.Snapshot.Totals.Records != 2 {
+		t.Errorf("Expected the snapshot to keep January's 2 records, got %+v, %v", snapshot, err)
+	}
+	for name, req := range map[string]models.ClosePeriodRequest{
+		"already closed": {Month: "2024-01"},
+		"bad month":      {Month: "January"},
+	} {
+		if _, err := service.ClosePeriod(req); !errors.Is(err, ErrValidation) {
+			t.Errorf("%s: expected a validation error, got %v", name, err)
+		}
+	}
+
+	// January's records, fees and adjustments are read-only, wherever the
+	// change comes from
+	price := 90.00
+	january := "2024-01-15"
+	for name, write := range map[string]func() error{
+		"update": func() error {
+			_, err := service.UpdateSalesRecord(created[1].ID, models.UpdateSalesRecordRequest{SalePrice: &price})
+			return err
+		},
+		"move in": func() error {
+			_, err := service.UpdateSalesRecord(created[2].ID, models.UpdateSalesRecordRequest{Date: &january})
+			return err
+		},
+		"create": func() error {
+			_, err := service.CreateSalesRecord(models.CreateSalesRecordRequest{Store: "Store A", Vendor: "Vendor 1", Date: january, Description: "Rug", SalePrice: 30.00})
+			return err
+		},
+		"delete": func() error { return service.DeleteSalesRecord(created[0].ID) },
+		"fee": func() error {
+			_, err := service.CreateFee(models.CreateFeeRequest{Store: "Store A", Date: january, Description: "Booth rent", Amount: 25.00})
+			return err
+		},
+		"adjustment": func() error {
+			_, err := service.CreateAdjustment(models.CreateSalesAdjustmentRequest{SalesRecordID: created[2].ID, Date: january, Reason: "Price correction", SalePriceDelta: -1})
+			return err
+		},
+	} {
+		if err := write(); !IsPeriodClosed(err) {
+			t.Errorf("%s: expected the closed month to refuse the change, got %v", name, err)
+		}
+	}
+	if _, err := service.UpdateSalesRecord(created[2].ID, models.UpdateSalesRecordRequest{SalePrice: &price}); err != nil {
+		t.Errorf("Expected February to stay open, got %v", err)
+	}
+
+	year := "2024"
+	summary, err := service.GetMonthlySummary(&year)
+	if err != nil || len(summary) != 2 {
+		t.Fatalf("GetMonthlySummary failed: %+v, %v", summary, err)
+	}
+	for _, month := range summary {
+		if month.Closed != (month.YearMonth == "2024-01") || month.Amended {
+			t.Errorf("Expected only January to be flagged closed, got %+v", month)
+		}
+	}
+
+	// Reopening takes a reason, kept in the audit log
+	if _, err := service.ReopenPeriod(models.ReopenPeriodRequest{Month: "2024-01", Reason: " "}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error without a reason, got %v", err)
+	}
+	if _, err := service.ReopenPeriod(models.ReopenPeriodRequest{Month: "2024-03", Reason: "Late sale"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound reopening an open month, got %v", err)
+	}
+	period, err = service.ReopenPeriod(models.ReopenPeriodRequest{Month: "2024-01", Reason: "Store corrected the chair's price"})
+	if err != nil || period.Closed() || *period.ReopenReason != "Store corrected the chair's price" {
+		t.Fatalf("Expected January to be reopened, got %+v, %v", period, err)
+	}
+	if _, err := service.UpdateSalesRecord(created[1].ID, models.UpdateSalesRecordRequest{SalePrice: &price}); err != nil {
+		t.Fatalf("Expected the reopened month to accept changes, got %v", err)
+	}
+
+	periods, err := service.ListPeriodCloses()
+	if err != nil || len(periods) != 1 || !periods[0].Changed {
+		t.Errorf("Expected January to be flagged as changed since its close, got %+v, %v", periods, err)
+	}
+	summary, err = service.GetMonthlySummary(&year)
+	if err != nil || summary[len(summary)-1].YearMonth != "2024-01" || !summary[len(summary)-1].Amended || summary[len(summary)-1].Closed {
+		t.Errorf("Expected January to be flagged as amended, got %+v, %v", summary, err)
+	}
+
+	entries, err := service.ListAuditLog(0)
+	if err != nil {
+		t.Fatalf("ListAuditLog failed: %v", err)
+	}
+	actions := make(map[string]string)
+	for _, entry := range entries {
+		actions[entry.Action] = entry.Details
+	}
+	if actions[models.AuditPeriodClosed] != "Closed 2024-01 with 2 records: Settled against statement #12" ||
+		actions[models.AuditPeriodReopened] != "Reopened 2024-01: Store corrected the chair's price" {
+		t.Errorf("Unexpected audit entries: %+v", actions)
+	}
+
+	// Closing again takes a fresh snapshot
+	period, err = service.ClosePeriod(models.ClosePeriodRequest{Month: "2024-01"})
+	if err != nil || !period.Closed() || period.Note != nil || period.ReopenReason != nil {
+		t.Fatalf("Expected January to be closed again, got %+v, %v", period, err)
+	}
+	if periods, err := service.ListPeriodCloses(); err != nil || periods[0].Changed {
+		t.Errorf("Expected the new close to match the records, got %+v, %v", periods, err)
+	}
+
+	// The retention policy still archives closed months
+	result, err := service.ApplyRetention(models.RetentionPolicy{ArchiveAfterYears: 1}, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil || result.ArchivedRecords != 3 {
+		t.Errorf("Expected every record to be archived, got %+v, %v", result, err)
+	}
+}
+
+func TestAppLock(t *testing.T) {
+	// The PBKDF2-HMAC-SHA256 test vector of RFC 7914, two blocks long
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if got := hex.EncodeToString(pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)); got != want {
+		t.Errorf("pbkdf2SHA256 = %s, want %s", got, want)
+	}
+
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	if enabled, err := service.AppLockEnabled(); err != nil || enabled {
+		t.Fatalf("Expected no app lock by default, got %v, %v", enabled, err)
+	}
+	if err := service.RemoveAppLock(""); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error removing an unset lock, got %v", err)
+	}
+	if err := service.SetAppLock("", "123"); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for a short PIN, got %v", err)
+	}
+	if err := service.SetAppLock("", "1234"); err != nil {
+		t.Fatalf("SetAppLock failed: %v", err)
+	}
+	if ok, err := service.VerifyAppLock("1234"); err != nil || !ok {
+		t.Errorf("Expected the PIN to unlock, got %v, %v", ok, err)
+	}
+	if ok, _ := service.VerifyAppLock("4321"); ok {
+		t.Error("Expected a wrong PIN not to unlock")
+	}
+
+	// The secret itself is never stored
+	var stored string
+	if err := service.db.conn.QueryRow("SELECT value FROM app_settings WHERE key = ?", settingAppLock).Scan(&stored); err != nil || strings.Contains(stored, "1234") {
+		t.Errorf("Expected only a hash to be stored, got %q, %v", stored, err)
+	}
+
+	if err := service.SetAppLock("4321", "correct horse"); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error changing the lock with a wrong PIN, got %v", err)
+	}
+	if err := service.SetAppLock("1234", "correct horse"); err != nil {
+		t.Fatalf("SetAppLock failed changing the lock: %v", err)
+	}
+	if err := service.RemoveAppLock("correct horse"); err != nil {
+		t.Fatalf("RemoveAppLock failed: %v", err)
+	}
+	if enabled, err := service.AppLockEnabled(); err != nil || enabled {
+		t.Errorf("Expected the app lock to be removed, got %v, %v", enabled, err)
+	}
+
+	entries, err := service.ListAuditLog(0)
+	if err != nil {
+		t.Fatalf("ListAuditLog failed: %v", err)
+	}
+	var details []string
+	for _, entry := range entries {
+		details = append(details, entry.Details)
+	}
+	if got := strings.Join(details, ", "); got != "App lock: removed, App lock: changed, App lock: set" {
+		t.Errorf("Unexpected audit entries: %s", got)
+	}
+}
+
+func TestVendorVelocity(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	if _, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor A", Date: "2024-01-02", Description: "Lamp", SalePrice: 40.00},
+		{Store: "Store A", Vendor: "Vendor A", Date: "2024-03-20", Description: "Chair", SalePrice: 25.00},
+		{Store: "Store A", Vendor: "Vendor A", Date: "2024-03-25", Description: "Chair", SalePrice: 25.00, IsReturn: true},
+		{Store: "Store B", Vendor: "Vendor B", Date: "2024-03-12", Description: "Rug", SalePrice: 60.00},
+		{Store: "Store B", Vendor: "Vendor B", Date: "2024-03-19", Description: "Desk", SalePrice: 90.00},
+		{Store: "Store B", Vendor: "Vendor B", Date: "2024-03-26", Description: "Vase", SalePrice: 15.00},
+		{Store: "Store B", Vendor: "Vendor C", Date: "2023-12-01", Description: "Clock", SalePrice: 30.00},
+	}); err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	now := time.Date(2024, 3, 31, 15, 0, 0, 0, time.UTC)
+	report, err := service.GetVendorVelocity(models.VelocityFilter{Weeks: 4}, now)
+	if err != nil {
+		t.Fatalf("GetVendorVelocity failed: %v", err)
+	}
+	if report.DormantWeeks != models.DefaultDormantWeeks || report.DormantVendors != 1 {
+		t.Errorf("Expected 1 dormant vendor at %d weeks, got %d at %d", models.DefaultDormantWeeks, report.DormantVendors, report.DormantWeeks)
+	}
+
+	var vendors []string
+	for _, v := range report.Vendors {
+		vendors = append(vendors, v.Vendor)
+	}
+	if got := strings.Join(vendors, ", "); got != "Vendor C, Vendor A, Vendor B" {
+		t.Fatalf("Expected dormant then slowest vendors, got %s", got)
+	}
+
+	dormant := report.Vendors[0]
+	if !dormant.Dormant || dormant.WeeksSinceLastSale != 17 || dormant.ItemsSold != 0 {
+		t.Errorf("Unexpected dormant vendor: %+v", dormant)
+	}
+	// The return is not an item sold, and the January sale is outside the weeks
+	slow := report.Vendors[1]
+	if slow.ItemsSold != 1 || slow.Weeks != 4 || slow.ItemsPerWeek != 0.25 || slow.WeeksSinceLastSale != 1 {
+		t.Errorf("Unexpected vendor A velocity: %+v", slow)
+	}
+	if slow.FirstSaleDate.Format("2006-01-02") != "2024-01-02" || slow.LastSaleDate.Format("2006-01-02") != "2024-03-20" {
+		t.Errorf("Unexpected vendor A sale dates: %s to %s", slow.FirstSaleDate, slow.LastSaleDate)
+	}
+	// Averaged from the first sale, not the start of the weeks
+	fast := report.Vendors[2]
+	if fast.ItemsSold != 3 || fast.Weeks != 2.9 || fast.ItemsPerWeek != 1.03 || fast.Dormant {
+		t.Errorf("Unexpected vendor B velocity: %+v", fast)
+	}
+
+	store := "Store A"
+	report, err = service.GetVendorVelocity(models.VelocityFilter{Store: &store, DormantWeeks: 1}, now)
+	if err != nil {
+		t.Fatalf("GetVendorVelocity failed: %v", err)
+	}
+	if len(report.Vendors) != 1 || !report.Vendors[0].Dormant || report.Vendors[0].ItemsSold != 2 {
+		t.Errorf("Expected only vendor A, dormant after a week, got %+v", report.Vendors)
+	}
+
+	if _, err := service.GetVendorVelocity(models.VelocityFilter{Weeks: -1}, now); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for negative weeks, got %v", err)
+	}
+
+	// Weeks starting on Sunday make Sunday 2024-03-31 the first day of the
+	// last of the 4 weeks, which then start on 2024-03-10
+	if err := service.SaveWeekStart(models.WeekStartSunday); err != nil {
+		t.Fatalf("SaveWeekStart failed: %v", err)
+	}
+	report, err = service.GetVendorVelocity(models.VelocityFilter{Weeks: 4}, now)
+	if err != nil {
+		t.Fatalf("GetVendorVelocity failed: %v", err)
+	}
+	if slow := report.Vendors[1]; slow.Vendor != "Vendor A" || slow.Weeks != 3.1 || slow.ItemsPerWeek != 0.32 {
+		t.Errorf("Unexpected vendor A velocity with weeks starting on Sunday: %+v", slow)
+	}
+}
+
+func TestVendorCohorts(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	if _, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor A", Date: "2024-01-05", Description: "Lamp", SalePrice: 40.00},
+		{Store: "Store A", Vendor: "Vendor B", Date: "2024-01-20", Description: "Chair", SalePrice: 25.00},
+		{Store: "Store A", Vendor: "Vendor A", Date: "2024-03-02", Description: "Rug", SalePrice: 60.00},
+		{Store: "Store A", Vendor: "Vendor A", Date: "2024-03-09", Description: "Rug", SalePrice: 60.00, IsReturn: true},
+		{Store: "Store B", Vendor: "Vendor B", Date: "2024-03-15", Description: "Desk", SalePrice: 90.00},
+		{Store: "Store B", Vendor: "Vendor C", Date: "2024-02-10", Description: "Vase", SalePrice: 15.00},
+		{Store: "Store B", Vendor: "Vendor C", Date: "2024-04-01", Description: "Clock", SalePrice: 30.00},
+	}); err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	now := time.Date(2024, 4, 10, 0, 0, 0, 0, time.UTC)
+	report, err := service.GetVendorCohorts(models.CohortFilter{Months: 3}, now)
+	if err != nil {
+		t.Fatalf("GetVendorCohorts failed: %v", err)
+	}
+	want := []models.VendorCohort{
+		{Month: "2024-01", Vendors: 2, Revenue: []float64{65, 0, 90}, ActiveVendors: []int{2, 0, 2}, Retention: []float64{100, 0, 100}},
+		{Month: "2024-02", Vendors: 1, Revenue: []float64{15, 0, 30}, ActiveVendors: []int{1, 0, 1}, Retention: []float64{100, 0, 100}},
+	}
+	if !reflect.DeepEqual(report.Cohorts, want) {
+		t.Errorf("Expected cohorts %+v, got %+v", want, report.Cohorts)
+	}
+
+	// Vendor B's first sale at store B is in March; months after now are left out
+	store := "Store B"
+	report, err = service.GetVendorCohorts(models.CohortFilter{Store: &store}, now)
+	if err != nil {
+		t.Fatalf("GetVendorCohorts failed: %v", err)
+	}
+	want = []models.VendorCohort{
+		{Month: "2024-02", Vendors: 1, Revenue: []float64{15, 0, 30}, ActiveVendors: []int{1, 0, 1}, Retention: []float64{100, 0, 100}},
+		{Month: "2024-03", Vendors: 1, Revenue: []float64{90, 0}, ActiveVendors: []int{1, 0}, Retention: []float64{100, 0}},
+	}
+	if report.Months != models.DefaultCohortMonths || !reflect.DeepEqual(report.Cohorts, want) {
+		t.Errorf("Expected cohorts %+v over %d months, got %+v over %d", want, models.DefaultCohortMonths, report.Cohorts, report.Months)
+	}
+
+	if _, err := service.GetVendorCohorts(models.CohortFilter{Months: -1}, now); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for negative months, got %v", err)
+	}
+}
+
+func TestPareto(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	if _, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor A", Date: "2024-01-05", Description: "Sofa", SalePrice: 500.00},
+		{Store: "Store A", Vendor: "Vendor A", Date: "2024-01-06", Description: "Sofa", SalePrice: 300.00},
+		{Store: "Store A", Vendor: "Vendor B", Date: "2024-01-07", Description: "Lamp", SalePrice: 100.00},
+		{Store: "Store A", Vendor: "Vendor C", Date: "2024-01-08", Description: "Vase", SalePrice: 60.00},
+		{Store: "Store B", Vendor: "Vendor C", Date: "2024-01-09", Description: "Vase", SalePrice: 20.00, IsReturn: true},
+		{Store: "Store B", Vendor: "Vendor D", Date: "2024-01-10", Description: "Mug", SalePrice: 60.00},
+	}); err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	report, err := service.GetPareto(models.ParetoFilter{GroupBy: models.ParetoByVendor})
+	if err != nil {
+		t.Fatalf("GetPareto failed: %v", err)
+	}
+	want := []models.ParetoEntry{
+		{Rank: 1, Group: "Vendor A", Revenue: 800, Share: 80, CumulativeShare: 80},
+		{Rank: 2, Group: "Vendor B", Revenue: 100, Share: 10, CumulativeShare: 90},
+		{Rank: 3, Group: "Vendor D", Revenue: 60, Share: 6, CumulativeShare: 96},
+		{Rank: 4, Group: "Vendor C", Revenue: 40, Share: 4, CumulativeShare: 100},
+	}
+	if !reflect.DeepEqual(report.Entries, want) {
+		t.Errorf("Expected entries %+v, got %+v", want, report.Entries)
+	}
+	if report.Total != 1000 || report.CoreGroups != 1 || report.CoreShare != 25 {
+		t.Errorf("Expected 1 of 4 groups making up 80%% of 1000, got %d (%.1f%%) of %.2f", report.CoreGroups, report.CoreShare, report.Total)
+	}
+
+	store := "Store A"
+	report, err = service.GetPareto(models.ParetoFilter{GroupBy: models.ParetoByItem, Store: &store})
+	if err != nil {
+		t.Fatalf("GetPareto failed: %v", err)
+	}
+	if len(report.Entries) != 3 || report.Entries[0].Description != "Sofa" || report.Entries[2].CumulativeShare != 100 {
+		t.Errorf("Unexpected item entries: %+v", report.Entries)
+	}
+
+	if _, err := service.GetPareto(models.ParetoFilter{GroupBy: "store"}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for an unknown grouping, got %v", err)
+	}
+}
+
+func TestTrailingTwelveMonths(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	if _, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2022-06-30", Description: "Lamp", SalePrice: 10.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2022-07-01", Description: "Lamp", SalePrice: 20.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2023-06-30", Description: "Chair", SalePrice: 40.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2023-07-01", Description: "Rug", SalePrice: 100.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-06-10", Description: "Desk", SalePrice: 200.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-06-20", Description: "Vase", SalePrice: 5.00},
+	}); err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	now := time.Date(2024, 6, 15, 9, 0, 0, 0, time.UTC)
+
+	// July 2023 to June 15 2024 against July 2022 to June 15 2023
+	ttm, err := service.GetTrailingTwelveMonths("", now)
+	if err != nil {
+		t.Fatalf("GetTrailingTwelveMonths failed: %v", err)
+	}
+	current, previous := ttm.Comparison.Current, ttm.Comparison.Previous
+	if ttm.EndMonth != "2024-06" || ttm.AsOf.String() != "2024-06-15" {
+		t.Errorf("Expected the twelve months to 2024-06-15, got %s to %s", ttm.EndMonth, ttm.AsOf)
+	}
+	if current.From.String() != "2023-07-01" || current.TotalSales != 300.00 || previous.From.String() != "2022-07-01" || previous.To.String() != "2023-06-15" || previous.TotalSales != 20.00 {
+		t.Errorf("Unexpected trailing totals: %+v", ttm.Comparison)
+	}
+
+	// A past month is covered to its last day
+	ttm, err = service.GetTrailingTwelveMonths("2023-06", now)
+	if err != nil {
+		t.Fatalf("GetTrailingTwelveMonths failed: %v", err)
+	}
+	if ttm.AsOf.String() != "2023-06-30" || ttm.Comparison.Current.TotalSales != 60.00 || ttm.Comparison.Previous.TotalSales != 10.00 {
+		t.Errorf("Unexpected trailing totals to June 2023: %+v", ttm.Comparison)
+	}
+
+	if _, err := service.GetTrailingTwelveMonths("2024-07", now); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for a future month, got %v", err)
+	}
+}
+
+func TestCacheRefresher(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	refreshed := make(chan ReportsRefreshedEvent, 4)
+	service.SetEventEmitter(func(name string, payload interface{}) {
+		if name == EventReportsRefreshed {
+			refreshed <- payload.(ReportsRefreshedEvent)
+		}
+	})
+
+	refresher := NewCacheRefresher(service, func(err error) {
+		t.Errorf("Refresh failed: %v", err)
+	})
+	refresher.SetDelays(10*time.Millisecond, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go refresher.Run(ctx)
+
+	if _, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Lamp", SalePrice: 40.00},
+	}); err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	select {
+	case event := <-refreshed:
+		if event.At.IsZero() {
+			t.Error("Expected the refresh time")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected reports to be refreshed after the import")
+	}
+	if _, _, ok := service.cache.get("stats"); !ok {
+		t.Error("Expected database statistics to be cached after the refresh")
+	}
+
+	// Other writes wait for the app to go idle
+	if _, err := service.CreateSalesRecord(models.CreateSalesRecordRequest{
+		Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-16", Description: "Chair", SalePrice: 25.00,
+	}); err != nil {
+		t.Fatalf("Failed to create record: %v", err)
+	}
+	select {
+	case <-refreshed:
+		t.Error("Expected no refresh before the app is idle")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDrillDownBatch(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	if _, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor A", Date: "2024-01-15", Description: "Lamp", SalePrice: 40.00},
+		{Store: "Store A", Vendor: "Vendor B", Date: "2024-01-20", Description: "Chair", SalePrice: 25.00},
+		{Store: "Store B", Vendor: "Vendor C", Date: "2024-03-01", Description: "Desk", SalePrice: 90.00},
+	}); err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	text := func(s string) *string { return &s }
+	batch, err := service.GetDrillDownBatch(models.DrillDownBatchRequest{
+		Periods: []models.DrillDownPeriod{
+			{Year: "2024", Month: text("3")},
+			{Year: "2024", Month: text("02")},
+			{Year: "2024", Month: text("01")},
+		},
+		SortBy:    "sale_price",
+		SortOrder: "asc",
+		Columns:   []string{"vendor", "sale_price"},
+	})
+	if err != nil {
+		t.Fatalf("GetDrillDownBatch failed: %v", err)
+	}
+	want := []models.DrillDownGroup{
+		{Period: "2024-03", Rows: [][]interface{}{{"Vendor C", 90.00}}},
+		{Period: "2024-02", Rows: [][]interface{}{}},
+		{Period: "2024-01", Rows: [][]interface{}{{"Vendor B", 25.00}, {"Vendor A", 40.00}}},
+	}
+	if !reflect.DeepEqual(batch.Columns, []string{"vendor", "sale_price"}) || !reflect.DeepEqual(batch.Groups, want) {
+		t.Errorf("Expected groups %+v, got %v %+v", want, batch.Columns, batch.Groups)
+	}
+
+	for _, periods := range [][]models.DrillDownPeriod{
+		nil,
+		{{Year: "2024", Month: text("13")}},
+		{{Year: "2024", Month: text("1")}, {Year: "2024", Month: text("01")}},
+	} {
+		if _, err := service.GetDrillDownBatch(models.DrillDownBatchRequest{Periods: periods}); !errors.Is(err, ErrValidation) {
+			t.Errorf("Expected a validation error for periods %+v, got %v", periods, err)
+		}
+	}
+}
+
+func TestMonthlySummaryByDimension(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	if _, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store B", Vendor: "Vendor 1", Date: "2024-01-05", Description: "Lamp", SalePrice: 40.00},
+		{Store: "Store A", Vendor: "Vendor 2", Date: "2024-01-10", Description: "Chair", SalePrice: 25.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-12", Description: "Rug", SalePrice: 60.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-02-01", Description: "Desk", SalePrice: 90.00},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-02-03", Description: "Desk", SalePrice: 90.00, IsReturn: true},
+	}); err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	year := "2024"
+	summaries, err := service.GetMonthlySummaryByDimension(&year, models.DimensionStore, models.ReportOptions{})
+	if err != nil {
+		t.Fatalf("GetMonthlySummaryByDimension failed: %v", err)
+	}
+	var rows []string
+	for _, s := range summaries {
+		rows = append(rows, fmt.Sprintf("%s %s %.2f %d", s.YearMonth, s.Dimension, s.TotalSales, s.ItemsSold))
+	}
+	want := "2024-02 Store A 0.00 1, 2024-01 Store A 85.00 2, 2024-01 Store B 40.00 1"
+	if got := strings.Join(rows, ", "); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+
+	summaries, err = service.GetMonthlySummaryByDimension(nil, models.DimensionVendor, models.ReportOptions{})
+	if err != nil {
+		t.Fatalf("GetMonthlySummaryByDimension failed: %v", err)
+	}
+	if len(summaries) != 3 || summaries[1].Dimension != "Vendor 1" || summaries[1].TotalSales != 100.00 || summaries[1].UniqueStores != 2 {
+		t.Errorf("Unexpected vendor rows: %+v", summaries)
+	}
+
+	// Without a dimension the months are not split
+	summaries, err = service.GetMonthlySummaryByDimension(nil, "", models.ReportOptions{})
+	if err != nil {
+		t.Fatalf("GetMonthlySummaryByDimension failed: %v", err)
+	}
+	if len(summaries) != 2 || summaries[0].Dimension != "" {
+		t.Errorf("Expected 2 unsplit months, got %+v", summaries)
+	}
+
+	if _, err := service.GetMonthlySummaryByDimension(nil, "category", models.ReportOptions{}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for an unknown dimension, got %v", err)
+	}
+}
+
+func TestGetTimeline(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	amount := func(f float64) *float64 { return &f }
+	records, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-10", Description: "Lamp", SalePrice: 40.00, Remaining: amount(32.00)},
+		{Store: "Store A", Vendor: "Vendor 2", Date: "2024-01-12", Description: "Chair", SalePrice: 100.00, Remaining: amount(80.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-20", Description: "Lamp", SalePrice: 40.00, Remaining: amount(32.00), IsReturn: true},
+		{Store: "Store B", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Desk", SalePrice: 500.00, Remaining: amount(400.00)},
+	})
+	if err != nil {
+		t.Fatalf("CreateSalesRecordsBatch failed: %v", err)
+	}
+	if _, err := service.CreateAdjustment(models.CreateSalesAdjustmentRequest{
+		SalesRecordID: records[1].ID, Date: "2024-01-20", Reason: "Price corrected", SalePriceDelta: -10.00,
+	}); err != nil {
+		t.Fatalf("CreateAdjustment failed: %v", err)
+	}
+	if _, err := service.SavePayoutSchedule(models.SavePayoutScheduleRequest{Store: "Store A", PayoutDay: 20, MonthsAfter: 1}); err != nil {
+		t.Fatalf("SavePayoutSchedule failed: %v", err)
+	}
+
+	describe := func(timeline *models.Timeline) string {
+		var entries []string
+		for _, entry := range timeline.Entries {
+			entries = append(entries, fmt.Sprintf("%s %s %s %.2f", entry.Date, entry.Type, entry.Description, entry.Amount))
+		}
+		return strings.Join(entries, ", ")
+	}
+
+	// The adjustment comes after the return of the same day; the payout
+	// totals the store's remaining amounts net of returns
+	timeline, err := service.GetTimeline(models.TimelineFilter{Kind: " Store ", Name: "Store A"})
+	if err != nil {
+		t.Fatalf("GetTimeline failed: %v", err)
+	}
+	want := "2024-01-10 sale Lamp 40.00, 2024-01-12 sale Chair 100.00, 2024-01-20 return Lamp -40.00, " +
+		"2024-01-20 adjustment Price corrected -10.00, 2024-02-20 payout Payout for 2024-01 sales 80.00"
+	if got := describe(timeline); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if timeline.Kind != models.AliasKindStore || timeline.Entries[3].AdjustmentID == nil || *timeline.Entries[3].SalesRecordID != records[1].ID {
+		t.Errorf("Unexpected timeline %+v", timeline)
+	}
+
+	// A vendor's payouts are its share of each store's; Store B has no schedule
+	timeline, err = service.GetTimeline(models.TimelineFilter{Kind: models.AliasKindVendor, Name: "Vendor 1"})
+	if err != nil {
+		t.Fatalf("GetTimeline failed: %v", err)
+	}
+	want = "2024-01-10 sale Lamp 40.00, 2024-01-15 sale Desk 500.00, 2024-01-20 return Lamp -40.00, " +
+		"2024-02-20 payout Payout for 2024-01 sales 0.00"
+	if got := describe(timeline); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+
+	from := time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+	timeline, err = service.GetTimeline(models.TimelineFilter{Kind: models.AliasKindStore, Name: "Store A", DateFrom: &from, DateTo: &to})
+	if err != nil {
+		t.Fatalf("GetTimeline failed: %v", err)
+	}
+	if len(timeline.Entries) != 2 || timeline.Entries[1].Type != models.TimelineAdjustment {
+		t.Errorf("Expected the return and adjustment of January 20, got %s", describe(timeline))
+	}
+
+	for _, invalid := range []models.TimelineFilter{
+		{Kind: "category", Name: "Furniture"},
+		{Kind: models.AliasKindStore, Name: " "},
+		{Kind: models.AliasKindStore, Name: "Store A", DateFrom: &to, DateTo: &from},
+	} {
+		if _, err := service.GetTimeline(invalid); !errors.Is(err, ErrValidation) {
+			t.Errorf("Expected a validation error for %+v, got %v", invalid, err)
+		}
+	}
+}
+
+func TestGetReportMethodology(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	methodology, err := service.GetReportMethodology()
+	if err != nil {
+		t.Fatalf("GetReportMethodology failed: %v", err)
+	}
+	if len(methodology.Rules) == 0 {
+		t.Error("Expected the summary rules")
+	}
+
+	var names []string
+	for _, view := range methodology.Views {
+		names = append(names, view.Name)
+		if !strings.HasPrefix(view.SQL, "CREATE VIEW "+view.Name) || view.Description == "" || len(view.Columns) == 0 {
+			t.Errorf("Expected the definition and description of %s, got %+v", view.Name, view)
+		}
+		// A view added or changed by a migration needs its columns described
+		for _, column := range view.Columns {
+			if column.Description == "" {
+				t.Errorf("Column %s of %s has no description", column.Name, view.Name)
+			}
+		}
+	}
+	want := "v_daily_sales_summary, v_import_activity_monthly, v_monthly_sales_summary, v_store_performance, v_vendor_performance, v_yearly_sales_summary"
+	if got := strings.Join(names, ", "); got != want {
+		t.Errorf("Expected views %s, got %s", want, got)
+	}
+	if column := methodology.Views[1].Columns[0]; column.Name != "month" || !strings.Contains(column.Description, "imports ran") {
+		t.Errorf("Expected the import activity month to be described as such, got %+v", column)
+	}
+}
+
+func TestBackfillRemaining(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	if _, err := service.SaveCommissionRule(models.CreateCommissionRuleRequest{Store: "Store A", EffectiveFrom: "2024-01-01", Rate: 0.25}); err != nil {
+		t.Fatalf("SaveCommissionRule failed: %v", err)
+	}
+
+	amount := func(f float64) *float64 { return &f }
+	created, err := service.CreateSalesRecordsBatch([]models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-02-01", Description: "Lamp", SalePrice: 100.00, Commission: amount(30.00), Remaining: amount(70.00)},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-02-02", Description: "Chair", SalePrice: 100.00, Commission: amount(30.00), Remaining: amount(70.01)}, // Rounding
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-02-03", Description: "Rug", SalePrice: 100.00, Commission: amount(30.00), Remaining: amount(60.00)},
+		{Store: "Store A", Vendor: "Vendor 2", Date: "2024-02-04", Description: "Vase", SalePrice: 40.00},                           // From the rule
+		{Store: "Store B", Vendor: "Vendor 1", Date: "2024-02-05", Description: "Desk", SalePrice: 50.00, Remaining: amount(10.00)}, // No rule
+	})
+	if err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	result, err := service.BackfillRemaining(models.RemainingBackfillRequest{})
+	if err != nil {
+		t.Fatalf("BackfillRemaining failed: %v", err)
+	}
+	if result.Checked != 4 || result.Unchecked != 1 || result.Fixed != 0 || len(result.Discrepancies) != 2 {
+		t.Fatalf("Expected 2 discrepancies in 4 records checked, got %+v", result)
+	}
+	rug, vase := result.Discrepancies[0], result.Discrepancies[1]
+	if rug.SalesRecordID != created[2].ID || rug.Expected != 70.00 || rug.Difference == nil || *rug.Difference != -10.00 {
+		t.Errorf("Expected the rug to be 10.00 short, got %+v", rug)
+	}
+	if vase.Stored != nil || vase.Rate == nil || vase.Commission != 10.00 || vase.Expected != 30.00 {
+		t.Errorf("Expected the vase's missing amount from the 25%% rule, got %+v", vase)
+	}
+	if record, _ := service.GetSalesRecord(created[3].ID); record.Remaining != nil {
+		t.Errorf("Expected a check without fixing to leave records alone, got %v", *record.Remaining)
+	}
+
+	vendor := "Vendor 1"
+	result, err = service.BackfillRemaining(models.RemainingBackfillRequest{Filter: models.SalesRecordFilter{Vendor: &vendor}, Fix: true})
+	if err != nil {
+		t.Fatalf("BackfillRemaining failed: %v", err)
+	}
+	if result.Fixed != 1 || result.Discrepancies[0].SalesRecordID != created[2].ID {
+		t.Errorf("Expected only the rug to be fixed, got %+v", result)
+	}
+	if record, _ := service.GetSalesRecord(created[2].ID); record.Remaining == nil || *record.Remaining != 70.00 {
+		t.Errorf("Expected the rug's remaining amount to be 70.00, got %+v", record)
+	}
+	if record, _ := service.GetSalesRecord(created[3].ID); record.Remaining != nil {
+		t.Errorf("Expected records outside the filter to be left alone, got %v", *record.Remaining)
+	}
+
+	if _, err := service.BackfillRemaining(models.RemainingBackfillRequest{Tolerance: -1}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for a negative tolerance, got %v", err)
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	commission := 50.0
+	rows, err := service.QuarantineRows([]models.QuarantinedRow{{
+		Record:  models.CreateSalesRecordRequest{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Lamp", SalePrice: 40, Commission: &commission},
+		Reasons: []string{"commission 50.00 is more than the sale price 40.00"},
+	}})
+	if err != nil || len(rows) != 1 || rows[0].ImportRunID != nil || rows[0].Record.Commission == nil || len(rows[0].Reasons) != 1 {
+		t.Fatalf("Expected the row kept with its record and reasons, got %+v, %v", rows, err)
+	}
+
+	// A correction that fails validation leaves the row in quarantine
+	invalid := rows[0].Record
+	invalid.SalePrice = -1
+	if _, err := service.ApproveQuarantinedRow(rows[0].ID, &invalid); err == nil {
+		t.Fatal("Expected an invalid correction to be rejected")
+	}
+	if listed, err := service.ListQuarantinedRows(); err != nil || len(listed) != 1 {
+		t.Fatalf("Expected the row still quarantined, got %+v, %v", listed, err)
+	}
+
+	record, err := service.ApproveQuarantinedRow(rows[0].ID, nil)
+	if err != nil || record.Commission == nil || *record.Commission != 50 {
+		t.Fatalf("Expected the row imported as read, got %+v, %v", record, err)
+	}
+	if listed, err := service.ListQuarantinedRows(); err != nil || len(listed) != 0 {
+		t.Errorf("Expected the quarantine to be empty, got %+v, %v", listed, err)
+	}
+	if err := service.DiscardQuarantinedRow(rows[0].ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected an approved row to be gone, got %v", err)
+	}
+}
+
+func TestGetConsolidatedReport(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	path := filepath.Join(t.TempDir(), "crafts.db")
+	other, err := NewService(Config{FilePath: path, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create the other database: %v", err)
+	}
+	for _, record := range []models.CreateSalesRecordRequest{
+		{Store: "Market", Vendor: "Vendor 2", Date: "2024-01-20", Description: "Quilt", SalePrice: 100},
+		{Store: "Market", Vendor: "Vendor 2", Date: "2024-02-03", Description: "Scarf", SalePrice: 30},
+	} {
+		if _, err := other.CreateSalesRecord(record); err != nil {
+			t.Fatalf("Failed to create record: %v", err)
+		}
+	}
+	other.Close()
+
+	for _, record := range []models.CreateSalesRecordRequest{
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Lamp", SalePrice: 40},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-16", Description: "Lamp", SalePrice: 40, IsReturn: true},
+		{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-17", Description: "Rug", SalePrice: 60},
+	} {
+		if _, err := service.CreateSalesRecord(record); err != nil {
+			t.Fatalf("Failed to create record: %v", err)
+		}
+	}
+
+	report, err := service.GetConsolidatedReport(models.ConsolidatedReportRequest{
+		Label:     " Antiques ",
+		Databases: []models.BusinessDatabase{{Label: "Crafts", Path: path}},
+	})
+	if err != nil {
+		t.Fatalf("GetConsolidatedReport failed: %v", err)
+	}
+	if len(report.Businesses) != 2 || report.Businesses[0] != "Antiques" || report.Businesses[1] != "Crafts" {
+		t.Errorf("Expected both businesses, got %v", report.Businesses)
+	}
+	if len(report.ByBusiness) != 3 {
+		t.Fatalf("Expected February for Crafts and January for both, got %+v", report.ByBusiness)
+	}
+	if feb := report.ByBusiness[0]; feb.YearMonth != "2024-02" || feb.Dimension != "Crafts" || feb.TotalSales != 30 {
+		t.Errorf("Expected Crafts' February first, got %+v", feb)
+	}
+	if jan := report.ByBusiness[1]; jan.Dimension != "Antiques" || jan.TotalSales != 60 || jan.ItemsSold != 2 || jan.ReturnedItems != 1 {
+		t.Errorf("Expected Antiques' January net of the return, got %+v", jan)
+	}
+	if len(report.Combined) != 2 || report.Combined[1].TotalSales != 160 || report.Combined[1].UniqueStores != 2 || report.Combined[1].Dimension != "" {
+		t.Errorf("Expected January combined across both businesses, got %+v", report.Combined)
+	}
+
+	year := "2023"
+	if report, err := service.GetConsolidatedReport(models.ConsolidatedReportRequest{Label: "Antiques", Databases: []models.BusinessDatabase{{Label: "Crafts", Path: path}}, Year: &year}); err != nil || len(report.Combined) != 0 {
+		t.Errorf("Expected no months in 2023, got %+v, %v", report, err)
+	}
+
+	for name, req := range map[string]models.ConsolidatedReportRequest{
+		"no databases":    {Label: "Antiques"},
+		"no label":        {Databases: []models.BusinessDatabase{{Label: "Crafts", Path: path}}},
+		"duplicate label": {Label: "Crafts", Databases: []models.BusinessDatabase{{Label: "crafts", Path: path}}},
+		"duplicate file":  {Label: "Antiques", Databases: []models.BusinessDatabase{{Label: "Crafts", Path: path}, {Label: "Crafts 2", Path: path}}},
+		"missing file":    {Label: "Antiques", Databases: []models.BusinessDatabase{{Label: "Crafts", Path: filepath.Join(t.TempDir(), "missing.db")}}},
+	} {
+		if _, err := service.GetConsolidatedReport(req); !errors.Is(err, ErrValidation) {
+			t.Errorf("%s: expected a validation error, got %v", name, err)
+		}
+	}
+}
+
+func TestWorkspaceArchive(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	if _, err := service.CreateSalesRecord(models.CreateSalesRecordRequest{Store: "Store A", Vendor: "Vendor 1", Date: "2024-01-15", Description: "Lamp", SalePrice: 40}); err != nil {
+		t.Fatalf("Failed to create record: %v", err)
+	}
+	if err := service.SaveWeekStart(models.WeekStartSunday); err != nil {
+		t.Fatalf("SaveWeekStart failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "workspace.stws")
+	if _, err := service.ExportWorkspace(archive, "short", time.Now()); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a short passphrase to be refused, got %v", err)
+	}
+	manifest, err := service.ExportWorkspace(archive, "correct horse", time.Now())
+	if err != nil {
+		t.Fatalf("ExportWorkspace failed: %v", err)
+	}
+	if manifest.Records != 1 || manifest.SchemaVersion == 0 || manifest.FormatVersion != models.WorkspaceFormatVersion {
+		t.Errorf("Expected a manifest of 1 record, got %+v", manifest)
+	}
+
+	// The database being replaced is set aside once the staged workspace is applied
+	dbPath := filepath.Join(dir, "sales_track.db")
+	if err := os.WriteFile(dbPath, []byte("old"), 0o600); err != nil {
+		t.Fatalf("Failed to write database: %v", err)
+	}
+	if _, err := StageWorkspace(archive, "wrong passphrase", dbPath); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a wrong passphrase to be refused, got %v", err)
+	}
+	if staged, err := StageWorkspace(archive, "correct horse", dbPath); err != nil || staged.Records != 1 {
+		t.Fatalf("Expected the workspace staged, got %+v, %v", staged, err)
+	}
+	if data, err := os.ReadFile(dbPath); err != nil || string(data) != "old" {
+		t.Errorf("Expected staging to leave the database alone, got %q, %v", data, err)
+	}
+	if applied, err := ApplyStagedWorkspace(dbPath); err != nil || !applied {
+		t.Fatalf("Expected the staged workspace applied, got %v, %v", applied, err)
+	}
+	if data, err := os.ReadFile(dbPath + ReplacedDatabaseSuffix); err != nil || string(data) != "old" {
+		t.Errorf("Expected the old database kept, got %q, %v", data, err)
+	}
+	if applied, err := ApplyStagedWorkspace(dbPath); err != nil || applied {
+		t.Errorf("Expected nothing left to apply, got %v, %v", applied, err)
+	}
+
+	imported, err := NewService(Config{FilePath: dbPath, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to open the imported workspace: %v", err)
+	}
+	defer imported.Close()
+	if count, err := imported.CountSalesRecords(models.SalesRecordFilter{}); err != nil || count != 1 {
+		t.Errorf("Expected the record imported, got %d, %v", count, err)
+	}
+	if weekStart, err := imported.GetWeekStart(); err != nil || weekStart != models.WeekStartSunday {
+		t.Errorf("Expected the week start imported, got %q, %v", weekStart, err)
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	dir := t.TempDir()
+	service, err := NewService(Config{FilePath: filepath.Join(dir, "sales.db"), AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
 	}
+	defer service.Close()
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_, err := repo.Create(record)
-		if err != nil {
-			b.Fatalf("Failed to create record: %v", err)
+	if _, err := service.Notify(models.Notification{Source: "test", Level: "warning", Title: "Odd level"}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for an unknown level, got %v", err)
+	}
+
+	// A background backup records its outcome once it finishes
+	created := make(chan models.Notification, 1)
+	service.SetEventEmitter(func(name string, payload interface{}) {
+		if name == EventNotificationCreated {
+			created <- payload.(models.Notification)
+		}
+	})
+	backupPath := filepath.Join(dir, "backup.db")
+	if err := service.StartBackup(backupPath); err != nil {
+		t.Fatalf("Failed to start backup: %v", err)
+	}
+	select {
+	case n := <-created:
+		if n.Source != models.NotificationSourceBackup || n.Level != models.NotificationInfo || !strings.Contains(n.Message, backupPath) {
+			t.Errorf("Expected a backup notification, got %+v", n)
 		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected a notification when the backup finished")
+	}
+	service.SetEventEmitter(nil)
+
+	// Retention runs are recorded only when they fail or change data
+	service.NotifyRetention(nil, nil)
+	service.NotifyRetention(&models.RetentionResult{}, nil)
+	service.NotifyRetention(&models.RetentionResult{ArchivedRecords: 3}, nil)
+	service.NotifyRetention(nil, errors.New("disk full"))
+
+	list, err := service.ListNotifications(models.NotificationFilter{})
+	if err != nil {
+		t.Fatalf("Failed to list notifications: %v", err)
+	}
+	if len(list.Notifications) != 3 || list.Unread != 3 {
+		t.Fatalf("Expected 3 unread notifications, got %+v", list)
+	}
+	newest := list.Notifications[0]
+	if newest.Source != models.NotificationSourceRetention || newest.Level != models.NotificationError || newest.Message != "disk full" {
+		t.Errorf("Expected the failed retention run first, got %+v", newest)
+	}
+	if !strings.Contains(list.Notifications[1].Message, "Archived 3 records") {
+		t.Errorf("Expected the archived count in the message, got %q", list.Notifications[1].Message)
+	}
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	if err := service.MarkNotificationRead(newest.ID, now); err != nil {
+		t.Fatalf("Failed to mark notification read: %v", err)
+	}
+	if err := service.MarkNotificationRead(newest.ID, now.Add(time.Hour)); err != nil {
+		t.Fatalf("Failed to mark notification read again: %v", err)
+	}
+	if err := service.MarkNotificationRead(9999, now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing notification, got %v", err)
+	}
+
+	unread, err := service.ListNotifications(models.NotificationFilter{UnreadOnly: true, Limit: 1})
+	if err != nil {
+		t.Fatalf("Failed to list unread notifications: %v", err)
+	}
+	if len(unread.Notifications) != 1 || unread.Unread != 2 || unread.Notifications[0].ReadAt != nil {
+		t.Errorf("Expected one of 2 unread notifications, got %+v", unread)
+	}
+
+	marked, err := service.MarkAllNotificationsRead(now.Add(2 * time.Hour))
+	if err != nil || marked != 2 {
+		t.Fatalf("Expected 2 notifications marked read, got %d, %v", marked, err)
+	}
+	list, _ = service.ListNotifications(models.NotificationFilter{})
+	if list.Unread != 0 {
+		t.Errorf("Expected no unread notifications, got %d", list.Unread)
+	}
+	if readAt := list.Notifications[0].ReadAt; readAt == nil || !readAt.Equal(now) {
+		t.Errorf("Expected the first read time to be kept, got %v", readAt)
 	}
 }
 
-// BenchmarkSalesRecordQuery benchmarks sales record queries
-func BenchmarkSalesRecordQuery(b *testing.B) {
-	config := Config{
-		InMemory:    true,
-		AutoMigrate: true,
+func TestAPITokens(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	for _, req := range []models.CreateAPITokenRequest{
+		{Name: " ", Scopes: []string{models.APITokenScopeRead}},
+		{Name: "Sync", Scopes: nil},
+		{Name: "Sync", Scopes: []string{"admin"}},
+		{Name: "Sync", Scopes: []string{models.APITokenScopeRead}, ExpiresAt: &past},
+	} {
+		if _, err := service.CreateAPIToken(req, now); !errors.Is(err, ErrValidation) {
+			t.Errorf("Expected a validation error for %+v, got %v", req, err)
+		}
 	}
 
-	db, err := New(config)
+	expires := now.Add(24 * time.Hour)
+	created, err := service.CreateAPIToken(models.CreateAPITokenRequest{
+		Name: "Spreadsheet sync", Scopes: []string{models.APITokenScopeWrite, models.APITokenScopeRead, models.APITokenScopeRead}, ExpiresAt: &expires,
+	}, now)
 	if err != nil {
-		b.Fatalf("Failed to create database: %v", err)
+		t.Fatalf("Failed to create API token: %v", err)
+	}
+	if !strings.HasPrefix(created.Secret, models.APITokenPrefix) || !strings.HasPrefix(created.Secret, created.Token.Hint) {
+		t.Errorf("Expected a prefixed secret starting with its hint, got %q and %q", created.Secret, created.Token.Hint)
+	}
+	if !reflect.DeepEqual(created.Token.Scopes, models.APITokenScopes) {
+		t.Errorf("Expected the scopes deduplicated in order, got %v", created.Token.Scopes)
 	}
-	defer db.Close()
 
-	repo := NewSalesRepository(db)
+	// Only the hash is kept
+	var stored int
+	service.db.conn.QueryRow("SELECT COUNT(*) FROM api_tokens WHERE token_hash = ?", created.Secret).Scan(&stored)
+	if stored != 0 {
+		t.Error("Expected the secret not to be stored")
+	}
 
-	// Create test data
-	for i := 0; i < 1000; i++ {
-		_, err := repo.Create(models.CreateSalesRecordRequest{
-			Store:       "Store A",
-			Vendor:      "Vendor 1",
-			Date:        "2024-01-15",
-			Description: "Product",
-			SalePrice:   100.00,
-			Commission:  10.00,
-			Remaining:   90.00,
-		})
+	token, err := service.AuthenticateAPIToken(created.Secret, models.APITokenScopeRead, now)
+	if err != nil || token.ID != created.Token.ID || token.LastUsedAt == nil {
+		t.Fatalf("Expected the token accepted and its use recorded, got %+v, %v", token, err)
+	}
+
+	// A request does not wait behind another write to record its use
+	release, err := service.beginWrite()
+	if err != nil {
+		t.Fatalf("beginWrite failed: %v", err)
+	}
+	token, err = service.AuthenticateAPIToken(created.Secret, models.APITokenScopeRead, now.Add(time.Minute))
+	release()
+	if err != nil || token.LastUsedAt == nil || !token.LastUsedAt.Equal(now) {
+		t.Errorf("Expected the token accepted without recording its use, got %+v, %v", token, err)
+	}
+
+	if _, err := service.AuthenticateAPIToken(created.Secret+"x", models.APITokenScopeRead, now); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected an unknown token refused, got %v", err)
+	}
+	if _, err := service.AuthenticateAPIToken(created.Secret, models.APITokenScopeRead, expires); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected an expired token refused, got %v", err)
+	}
+
+	readOnly, err := service.CreateAPIToken(models.CreateAPITokenRequest{Name: "Dashboard", Scopes: []string{models.APITokenScopeRead}}, now)
+	if err != nil {
+		t.Fatalf("Failed to create API token: %v", err)
+	}
+	if _, err := service.AuthenticateAPIToken(readOnly.Secret, models.APITokenScopeWrite, now); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected a missing scope refused, got %v", err)
+	}
+
+	revoked, err := service.RevokeAPIToken(created.Token.ID, now)
+	if err != nil || revoked.RevokedAt == nil {
+		t.Fatalf("Expected the token revoked, got %+v, %v", revoked, err)
+	}
+	if _, err := service.AuthenticateAPIToken(created.Secret, models.APITokenScopeRead, now); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected a revoked token refused, got %v", err)
+	}
+	if _, err := service.RevokeAPIToken(9999, now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound revoking a missing token, got %v", err)
+	}
+
+	tokens, err := service.ListAPITokens()
+	if err != nil || len(tokens) != 2 || tokens[0].Name != "Dashboard" || tokens[1].RevokedAt == nil {
+		t.Errorf("Expected both tokens listed newest first, got %+v, %v", tokens, err)
+	}
+	entries, _ := service.ListAuditLog(0)
+	if len(entries) != 3 {
+		t.Errorf("Expected 3 audit entries for the token changes, got %+v", entries)
+	}
+}
+
+func TestMappingProfiles(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	if _, err := service.SaveMappingProfile(models.CSVMapping{Name: " ", Delimiter: ","}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for a blank name, got %v", err)
+	}
+
+	for _, name := range []string{"Riverside", "harbor gifts", "Harbor Gifts"} {
+		if _, err := service.SaveMappingProfile(models.CSVMapping{Name: name, Delimiter: ";", Columns: map[string]string{"Paid": "sale_price"}}); err != nil {
+			t.Fatalf("Failed to save mapping profile %q: %v", name, err)
+		}
+	}
+	profiles, err := service.ListMappingProfiles()
+	if err != nil {
+		t.Fatalf("Failed to list mapping profiles: %v", err)
+	}
+	if len(profiles) != 2 || profiles[0].Name != "Harbor Gifts" || profiles[1].Name != "Riverside" {
+		t.Errorf("Expected the same name replaced and profiles sorted, got %+v", profiles)
+	}
+
+	if err := service.DeleteMappingProfile("RIVERSIDE"); err != nil {
+		t.Fatalf("Failed to delete mapping profile: %v", err)
+	}
+	if err := service.DeleteMappingProfile("Riverside"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a missing profile, got %v", err)
+	}
+	if entries, _ := service.ListAuditLog(0); len(entries) != 4 {
+		t.Errorf("Expected 4 audit entries for the profile changes, got %+v", entries)
+	}
+}
+
+func TestFindDuplicateImport(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	hash := strings.Repeat("ab", 32)
+	record := func(startedAt time.Time, success bool) *models.ImportRun {
+		run, err := service.RecordImport(models.ImportRun{StartedAt: startedAt, Source: models.ImportSourcePaste, Method: "batch"})
 		if err != nil {
-			b.Fatalf("Failed to create test record: %v", err)
+			t.Fatalf("Failed to record import: %v", err)
 		}
+		run.Success = success
+		run.ContentHash = &hash
+		if err := service.FinishImport(*run); err != nil {
+			t.Fatalf("Failed to finish import: %v", err)
+		}
+		return run
 	}
 
-	filter := models.SalesRecordFilter{
-		Limit: intPtr(50),
+	if earlier, err := service.FindDuplicateImport(hash); err != nil || earlier != nil {
+		t.Fatalf("Expected no duplicate before any import, got %+v %v", earlier, err)
 	}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		_, err := repo.List(filter)
+	now := time.Now()
+	first := record(now.Add(-2*time.Hour), true)
+	record(now.Add(-time.Hour), false)
+
+	// Failed imports wrote nothing, so only the successful one matches
+	earlier, err := service.FindDuplicateImport(hash)
+	if err != nil {
+		t.Fatalf("FindDuplicateImport failed: %v", err)
+	}
+	if earlier == nil || earlier.ID != first.ID || earlier.ContentHash == nil || *earlier.ContentHash != hash {
+		t.Errorf("Expected the successful import %d, got %+v", first.ID, earlier)
+	}
+
+	if earlier, err := service.FindDuplicateImport(strings.Repeat("cd", 32)); err != nil || earlier != nil {
+		t.Errorf("Expected no duplicate for other content, got %+v %v", earlier, err)
+	}
+	if earlier, err := service.FindDuplicateImport(""); err != nil || earlier != nil {
+		t.Errorf("Expected no duplicate for imports without records, got %+v %v", earlier, err)
+	}
+}
+
+// roundedJSON decodes the JSON encoding of v with numbers rounded to six
+// decimals, for comparing reports summed in different orders
+func roundedJSON(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Failed to encode %T: %v", v, err)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Failed to decode %T: %v", v, err)
+	}
+
+	var round func(interface{}) interface{}
+	round = func(value interface{}) interface{} {
+		switch value := value.(type) {
+		case float64:
+			return float64(int64(value*1e6+0.5)) / 1e6
+		case []interface{}:
+			for i := range value {
+				value[i] = round(value[i])
+			}
+		case map[string]interface{}:
+			for key := range value {
+				value[key] = round(value[key])
+			}
+		}
+		return value
+	}
+	return round(decoded)
+}
+
+func TestReportPerformanceMode(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	records := synthetic.NewGenerator(3, 2).Records(2000)
+	for i := range records {
+		records[i].IsReturn = i%9 == 0
+	}
+	if _, err := service.CreateSalesRecordsBatch(records); err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+
+	status, err := service.GetReportPerformanceStatus()
+	if err != nil {
+		t.Fatalf("GetReportPerformanceStatus failed: %v", err)
+	}
+	if status.Active || status.RecordCount != 2000 || status.Config != models.DefaultReportPerformanceConfig() {
+		t.Errorf("Expected performance mode off below the default threshold, got %+v", status)
+	}
+	if err := service.SaveReportPerformanceConfig(models.ReportPerformanceConfig{Enabled: true, Threshold: 10, SampleSize: 5000}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for a threshold below the minimum, got %v", err)
+	}
+
+	year := "2024"
+	pivot, _ := service.GetPivotTableData(&year)
+	stores, _ := service.GetStorePerformance()
+	vendors, _ := service.GetVendorPerformance()
+	products, _ := service.GetTopProducts(5)
+
+	// The minimum threshold is far above what a test should insert
+	if err := service.settingsRepo.Set(settingReportPerformance, models.ReportPerformanceConfig{Enabled: true, Threshold: 1000, SampleSize: 1000}); err != nil {
+		t.Fatalf("Failed to save report performance settings: %v", err)
+	}
+
+	// Aggregated reports give the same figures
+	aggregatedPivot, err := service.GetPivotTableData(&year)
+	if err != nil {
+		t.Fatalf("GetPivotTableData failed: %v", err)
+	}
+	if !reflect.DeepEqual(roundedJSON(t, aggregatedPivot), roundedJSON(t, pivot)) {
+		t.Errorf("Expected the aggregated pivot to match, got %+v want %+v", aggregatedPivot.YearlyData, pivot.YearlyData)
+	}
+	aggregatedStores, err := service.GetStorePerformance()
+	if err != nil || !reflect.DeepEqual(roundedJSON(t, aggregatedStores), roundedJSON(t, stores)) {
+		t.Errorf("Expected the aggregated store performance to match, got %+v, %v", aggregatedStores, err)
+	}
+	aggregatedVendors, err := service.GetVendorPerformance()
+	if err != nil || !reflect.DeepEqual(roundedJSON(t, aggregatedVendors), roundedJSON(t, vendors)) {
+		t.Errorf("Expected the aggregated vendor performance to match, got %v", err)
+	}
+
+	status, _ = service.GetReportPerformanceStatus()
+	if !status.Active || status.AggregatesBuiltAt == nil {
+		t.Errorf("Expected performance mode on with the aggregates built, got %+v", status)
+	}
+
+	// Top products are estimated from about half the records
+	sampled, err := service.GetTopProducts(5)
+	if err != nil || len(sampled) != 5 || !sampled[0].Estimated {
+		t.Fatalf("Expected estimated top products, got %+v, %v", sampled, err)
+	}
+	if sampled[0].TotalSales < products[0].TotalSales/2 || sampled[0].TotalSales > products[0].TotalSales*2 {
+		t.Errorf("Expected an estimate near %.2f, got %.2f", products[0].TotalSales, sampled[0].TotalSales)
+	}
+
+	// A change makes the aggregates stale until the next report rebuilds them
+	if _, err := service.CreateSalesRecord(models.CreateSalesRecordRequest{
+		Store: "Pop-up", Vendor: "Vendor 999", Date: "2024-06-01", Description: "Lamp", SalePrice: 40,
+	}); err != nil {
+		t.Fatalf("Failed to create record: %v", err)
+	}
+	if status, _ := service.GetReportPerformanceStatus(); status.AggregatesBuiltAt != nil {
+		t.Errorf("Expected the aggregates stale after a change, built at %v", status.AggregatesBuiltAt)
+	}
+	aggregatedStores, err = service.GetStorePerformance()
+	if err != nil {
+		t.Fatalf("GetStorePerformance failed: %v", err)
+	}
+	var found bool
+	for _, store := range aggregatedStores {
+		found = found || store.Store == "Pop-up" && store.TotalSales == 40
+	}
+	if !found {
+		t.Errorf("Expected the new store in the rebuilt aggregates, got %+v", aggregatedStores)
+	}
+
+	// While another write holds the database the reports read sales_records
+	// rather than wait for it to rebuild the aggregates
+	if _, err := service.CreateSalesRecord(models.CreateSalesRecordRequest{
+		Store: "Market", Vendor: "Vendor 999", Date: "2024-06-01", Description: "Rug", SalePrice: 25,
+	}); err != nil {
+		t.Fatalf("Failed to create record: %v", err)
+	}
+	release, err := service.beginWrite()
+	if err != nil {
+		t.Fatalf("beginWrite failed: %v", err)
+	}
+	aggregatedStores, err = service.GetStorePerformance()
+	status, _ = service.GetReportPerformanceStatus()
+	release()
+	if err != nil {
+		t.Fatalf("GetStorePerformance failed: %v", err)
+	}
+	found = false
+	for _, store := range aggregatedStores {
+		found = found || store.Store == "Market" && store.TotalSales == 25
+	}
+	if !found || status.AggregatesBuiltAt != nil {
+		t.Errorf("Expected the new store read from sales_records without a rebuild, got %+v, built at %v", aggregatedStores, status.AggregatesBuiltAt)
+	}
+
+	// Reports inside a transaction see its uncommitted writes
+	err = service.ExecTx(func(tx *Service) error {
+		if _, err := tx.CreateSalesRecord(models.CreateSalesRecordRequest{
+			Store: "Kiosk", Vendor: "Vendor 999", Date: "2024-06-02", Description: "Vase", SalePrice: 15,
+		}); err != nil {
+			return err
+		}
+		stores, err := tx.GetStorePerformance()
 		if err != nil {
-			b.Fatalf("Failed to query records: %v", err)
+			return err
 		}
+		for _, store := range stores {
+			if store.Store == "Kiosk" {
+				return nil
+			}
+		}
+		return fmt.Errorf("store missing from %+v", stores)
+	})
+	if err != nil {
+		t.Errorf("Expected the transaction's own record in its report: %v", err)
 	}
 }
 
-// Helper function to create int pointer
-func intPtr(i int) *int {
-	return &i
+func TestMemoryBudget(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	if budget, err := service.GetMemoryBudget(); err != nil || budget != models.DefaultMemoryBudget() {
+		t.Errorf("Expected the default budget, got %+v, %v", budget, err)
+	}
+	if err := service.SaveMemoryBudget(models.MemoryBudget{LimitMB: 16}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for a budget below the minimum, got %v", err)
+	}
+	if err := service.SaveMemoryBudget(models.MemoryBudget{LimitMB: 256}); err != nil {
+		t.Fatalf("SaveMemoryBudget failed: %v", err)
+	}
+	if budget, _ := service.GetMemoryBudget(); budget.LimitMB != 256 || budget.Bytes() != 256<<20 {
+		t.Errorf("Expected the saved budget of 256 MB, got %+v", budget)
+	}
+
+	// Spilled reads return the same records in the same order
+	if _, err := service.CreateSalesRecordsBatch(synthetic.NewGenerator(5, 1).Records(300)); err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+	sortBy, sortOrder := "sale_price", "desc"
+	filter := models.SalesRecordFilter{SortBy: &sortBy, SortOrder: &sortOrder}
+	collect := func(each func(models.SalesRecordFilter, func(models.SalesRecord) error) error) []int64 {
+		var ids []int64
+		if err := each(filter, func(record models.SalesRecord) error {
+			ids = append(ids, record.ID)
+			return nil
+		}); err != nil {
+			t.Fatalf("Failed to read records: %v", err)
+		}
+		return ids
+	}
+	if spilled, want := collect(service.EachSalesRecordSpilled), collect(service.EachSalesRecord); !reflect.DeepEqual(spilled, want) || len(want) != 300 {
+		t.Errorf("Expected the spilled read to match, got %d records and %d", len(spilled), len(want))
+	}
+
+	// The connection goes back to the pool keeping temporary storage in memory
+	var tempStore int
+	if err := service.GetDB().Conn().QueryRow("PRAGMA temp_store").Scan(&tempStore); err != nil || tempStore != 2 {
+		t.Errorf("Expected temp_store MEMORY (2) after a spilled read, got %d, %v", tempStore, err)
+	}
+}
+
+func TestDatabaseMetrics(t *testing.T) {
+	// Every query is slow
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true, SlowQueryThreshold: time.Nanosecond})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	if _, err := service.CreateSalesRecordsBatch(synthetic.NewGenerator(2, 1).Records(50)); err != nil {
+		t.Fatalf("Failed to create records: %v", err)
+	}
+	if _, err := service.ListSalesRecords(models.SalesRecordFilter{}); err != nil {
+		t.Fatalf("ListSalesRecords failed: %v", err)
+	}
+
+	metrics := service.GetDatabaseMetrics()
+	if metrics.Connections.Open != 1 || metrics.Connections.MaxOpen != 1 {
+		t.Errorf("Expected the in-memory database's single connection, got %+v", metrics.Connections)
+	}
+	if metrics.SlowQueryThreshold != models.Duration(time.Nanosecond) || metrics.Since.IsZero() {
+		t.Errorf("Expected the configured threshold and start, got %v since %v", metrics.SlowQueryThreshold, metrics.Since)
+	}
+
+	timings := map[string]QueryTiming{}
+	for _, timing := range metrics.Queries {
+		timings[timing.Method] = timing
+	}
+	list, ok := timings["SalesRepository.List"]
+	if !ok || list.Count < 2 || list.Total <= 0 || list.Max > list.Total {
+		t.Fatalf("Expected the count and page queries of SalesRepository.List, got %+v in %v", list, metrics.Queries)
+	}
+	var bucketed int64
+	for _, bucket := range list.Buckets {
+		bucketed += bucket.Count
+	}
+	if bucketed != list.Count || len(list.Buckets) != len(queryTimingBounds)+1 || list.Buckets[len(list.Buckets)-1].UpTo != 0 {
+		t.Errorf("Expected every query in one bucket, got %+v", list.Buckets)
+	}
+	if _, ok := timings["SalesRepository.insertChunk"]; !ok {
+		t.Errorf("Expected batch inserts attributed to the innermost repository method, got %v", metrics.Queries)
+	}
+
+	if len(metrics.SlowQueries) != maxSlowQueries {
+		t.Fatalf("Expected the %d most recent slow queries, got %d", maxSlowQueries, len(metrics.SlowQueries))
+	}
+	latest := metrics.SlowQueries[0]
+	if latest.Method != "SalesRepository.List" || !strings.HasPrefix(latest.Query, "SELECT ") || strings.Contains(latest.Query, "\n") {
+		t.Errorf("Expected the last query of the list first, compacted, got %+v", latest)
+	}
+	if metrics.SlowQueries[1].At.After(latest.At) {
+		t.Error("Expected the most recent slow query first")
+	}
+
+	if report := service.HealthReport(); report.Metrics == nil || len(report.Metrics.Queries) == 0 {
+		t.Errorf("Expected the metrics in the health report, got %+v", report.Metrics)
+	}
+}
+
+func TestQueryMetricNames(t *testing.T) {
+	names := map[string]string{
+		"(*SalesRepository).CreateBatch.func1": "SalesRepository.CreateBatch",
+		"(*Service).ExecTx.func2.1":            "Service.ExecTx",
+		"copyTable":                            "copyTable",
+	}
+	for name, want := range names {
+		if got := methodName(name); got != want {
+			t.Errorf("methodName(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	if got := compactQuery("SELECT *\n\t\tFROM sales_records\n  WHERE id = ?"); got != "SELECT * FROM sales_records WHERE id = ?" {
+		t.Errorf("Expected whitespace collapsed, got %q", got)
+	}
+	if got := compactQuery(strings.Repeat("x ", maxLoggedQueryLength)); len(got) > maxLoggedQueryLength+len("…") {
+		t.Errorf("Expected a long query cut, got %d bytes", len(got))
+	}
+}
+
+func TestQueryTimeouts(t *testing.T) {
+	service, err := NewService(Config{InMemory: true, AutoMigrate: true})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	defer service.Close()
+
+	if timeouts := service.db.timeouts.Load(); timeouts == nil || *timeouts != models.DefaultQueryTimeouts() {
+		t.Errorf("Expected the default timeouts to apply once the service is created, got %+v", timeouts)
+	}
+	if err := service.SaveQueryTimeouts(models.QueryTimeouts{Read: models.Duration(500 * time.Millisecond)}); !errors.Is(err, ErrValidation) {
+		t.Errorf("Expected a validation error for a timeout below the minimum, got %v", err)
+	}
+	saved := models.QueryTimeouts{Read: models.Duration(10 * time.Second), Maintenance: models.Duration(time.Hour)}
+	if err := service.SaveQueryTimeouts(saved); err != nil {
+		t.Fatalf("SaveQueryTimeouts failed: %v", err)
+	}
+	if timeouts, _ := service.GetQueryTimeouts(); timeouts != saved {
+		t.Errorf("Expected the saved timeouts, got %+v", timeouts)
+	}
+	if timeouts := service.db.timeouts.Load(); *timeouts != saved {
+		t.Errorf("Expected the saved timeouts to apply at once, got %+v", timeouts)
+	}
+
+	// A read running past its timeout is interrupted
+	service.db.SetQueryTimeouts(models.QueryTimeouts{Read: models.Duration(50 * time.Millisecond), Write: models.Duration(50 * time.Millisecond)})
+	slowCount := "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 1000000000) SELECT COUNT(*) FROM c"
+	start := time.Now()
+	var count int64
+	err = service.db.conn.QueryRow(slowCount).Scan(&count)
+	var timeoutErr *QueryTimeoutError
+	if !errors.Is(err, ErrQueryTimeout) || !errors.As(err, &timeoutErr) || timeoutErr.Class != "read" {
+		t.Fatalf("Expected a read timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the query stopped soon after its timeout, took %v", elapsed)
+	}
+	if err.Error() != "read query timed out after 50ms" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+
+	// So is a write
+	_, err = service.db.conn.Exec("CREATE TEMP TABLE slow AS " + slowCount)
+	if !errors.As(err, &timeoutErr) || timeoutErr.Class != "write" {
+		t.Errorf("Expected a write timeout, got %v", err)
+	}
+
+	// The connection is still usable, and time spent on each row outside
+	// SQLite does not count
+	if _, err := service.CountSalesRecords(models.SalesRecordFilter{}); err != nil {
+		t.Errorf("Expected queries to run after a timeout, got %v", err)
+	}
+	rows, err := service.db.conn.Query("SELECT value FROM json_each('[1, 2, 3, 4]')")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	for rows.Next() {
+		time.Sleep(30 * time.Millisecond)
+	}
+	if err := rows.Err(); err != nil {
+		t.Errorf("Expected slow row processing not to time out, got %v", err)
+	}
+	rows.Close()
+
+	classes := map[string]string{
+		"SELECT * FROM sales_records":              "read",
+		"  with recursive c AS (SELECT 1) SELECT":  "read",
+		"PRAGMA user_version":                      "read",
+		"PRAGMA main.integrity_check":              "maintenance",
+		"PRAGMA wal_checkpoint(TRUNCATE)":          "maintenance",
+		"VACUUM":                                   "maintenance",
+		"INSERT INTO sales_records DEFAULT VALUES": "write",
+		"DELETE FROM sales_records":                "write",
+	}
+	for query, want := range classes {
+		if got := classifyQuery(query); got != want {
+			t.Errorf("classifyQuery(%q) = %q, want %q", query, got, want)
+		}
+	}
 }
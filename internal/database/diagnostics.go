@@ -0,0 +1,138 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// QueryPlanStep is one row of EXPLAIN QUERY PLAN output
+type QueryPlanStep struct {
+	ID     int    `json:"id"`
+	Parent int    `json:"parent"`
+	Detail string `json:"detail"`
+}
+
+// QueryPlanReport describes how SQLite executes one of the application's
+// common queries, with warnings for plans that are likely to be slow
+type QueryPlanReport struct {
+	Name     string          `json:"name"`
+	Query    string          `json:"query"`
+	Steps    []QueryPlanStep `json:"steps"`
+	Warnings []string        `json:"warnings,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// diagnosticQuery is a named query checked by GetQueryPlanDiagnostics
+type diagnosticQuery struct {
+	name      string
+	query     string
+	args      []interface{}
+	selective bool // Expected to read a few rows via an index rather than aggregate the table
+}
+
+// diagnosticQueries returns the list and report queries the UI runs most often,
+// built with the same helpers the repositories use
+func diagnosticQueries() []diagnosticQuery {
+	listQuery := func(name string, filter models.SalesRecordFilter) diagnosticQuery {
+		where, args := buildFilterWhere(filter)
+		return diagnosticQuery{
+			name:      name,
+			query:     fmt.Sprintf("SELECT %s FROM sales_records %s %s LIMIT 50 OFFSET 0", salesRecordColumns, where, buildListOrderBy(filter)),
+			args:      args,
+			selective: true,
+		}
+	}
+
+	store := "Store"
+	vendor := "Vendor"
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	sortBy, sortOrder := "sale_price", "desc"
+
+	return []diagnosticQuery{
+		listQuery("List recent records", models.SalesRecordFilter{}),
+		listQuery("List by store", models.SalesRecordFilter{Store: &store}),
+		listQuery("List by vendor", models.SalesRecordFilter{Vendor: &vendor}),
+		listQuery("List by date range", models.SalesRecordFilter{DateFrom: &from, DateTo: &to}),
+		listQuery("List sorted by sale price", models.SalesRecordFilter{SortBy: &sortBy, SortOrder: &sortOrder}),
+		{name: "Database statistics", query: "SELECT COUNT(*), MIN(date), MAX(date), SUM(CASE WHEN is_return = 0 THEN sale_price END), SUM(CASE WHEN is_return = 1 THEN sale_price END), COUNT(DISTINCT store), COUNT(DISTINCT vendor) FROM sales_records"},
+		{name: "Yearly summary", query: "SELECT * FROM v_yearly_sales_summary"},
+		{name: "Monthly summary", query: "SELECT * FROM v_monthly_sales_summary WHERE year = ?", args: []interface{}{"2024"}},
+		{name: "Daily summary", query: "SELECT * FROM v_daily_sales_summary WHERE year = ? AND month = ?", args: []interface{}{"2024", "01"}},
+		{name: "Store performance", query: "SELECT * FROM v_store_performance"},
+		{name: "Vendor performance", query: "SELECT * FROM v_vendor_performance"},
+		{name: "Drill-down by day", query: "SELECT " + salesRecordColumns + " FROM sales_records WHERE date = ? ORDER BY date DESC", args: []interface{}{"2024-01-15"}, selective: true},
+		{name: "Top products", query: "SELECT product_key, COUNT(*), SUM(CASE WHEN is_return = 1 THEN -sale_price ELSE sale_price END) AS total_sales FROM sales_records WHERE product_key != '' GROUP BY product_key ORDER BY total_sales DESC LIMIT 10"},
+	}
+}
+
+// ExplainQueryPlan runs EXPLAIN QUERY PLAN for a query and returns its steps
+func (db *DB) ExplainQueryPlan(query string, args ...interface{}) ([]QueryPlanStep, error) {
+	rows, err := db.conn.Query("EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain query: %w", err)
+	}
+	defer rows.Close()
+
+	var steps []QueryPlanStep
+	for rows.Next() {
+		var step QueryPlanStep
+		var notUsed int
+		if err := rows.Scan(&step.ID, &step.Parent, &notUsed, &step.Detail); err != nil {
+			return nil, fmt.Errorf("failed to scan query plan: %w", err)
+		}
+		steps = append(steps, step)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating query plan: %w", err)
+	}
+
+	return steps, nil
+}
+
+// planWarnings flags query plan steps that indicate a missing or unused index.
+// Full scans and sorts are only flagged for selective queries, since report
+// aggregates have to read the whole table anyway.
+func planWarnings(steps []QueryPlanStep, selective bool) []string {
+	var warnings []string
+	for _, step := range steps {
+		detail := step.Detail
+		switch {
+		case selective && strings.HasPrefix(detail, "SCAN ") && !strings.Contains(detail, " INDEX "):
+			warnings = append(warnings, fmt.Sprintf("Full table scan (%s); consider an index on the filtered or sorted columns", detail))
+		case selective && strings.Contains(detail, "USE TEMP B-TREE FOR ORDER BY"):
+			warnings = append(warnings, fmt.Sprintf("Sorting without an index (%s)", detail))
+		case strings.Contains(detail, "AUTOMATIC") && strings.Contains(detail, "INDEX"):
+			warnings = append(warnings, fmt.Sprintf("SQLite built a temporary index (%s); a permanent one may help", detail))
+		}
+	}
+	return warnings
+}
+
+// GetQueryPlanDiagnostics explains the common list and report queries against
+// the live database and reports plans that are likely to be slow. A query that
+// cannot be explained is reported with its error rather than failing the whole run.
+func (s *Service) GetQueryPlanDiagnostics() ([]QueryPlanReport, error) {
+	queries := diagnosticQueries()
+	reports := make([]QueryPlanReport, 0, len(queries))
+
+	for _, q := range queries {
+		report := QueryPlanReport{Name: q.name, Query: q.query}
+
+		steps, err := s.db.ExplainQueryPlan(q.query, q.args...)
+		if err != nil {
+			report.Error = err.Error()
+		} else {
+			report.Steps = steps
+			report.Warnings = planWarnings(steps, q.selective)
+		}
+
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
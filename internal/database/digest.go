@@ -0,0 +1,98 @@
+package database
+
+import (
+	"strings"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// digestRecord is saved under settingLastDigest after each scheduled digest,
+// so a period's digest is delivered once
+type digestRecord struct {
+	Period string `json:"period"`
+	From   string `json:"from"` // First day of the period the digest covered
+}
+
+// GetDigestSchedule returns the saved digest schedule, or the default if
+// none has been saved
+func (s *Service) GetDigestSchedule() (models.DigestSchedule, error) {
+	schedule := models.DefaultDigestSchedule()
+	if _, err := s.settingsRepo.Get(settingDigestSchedule, &schedule); err != nil {
+		return schedule, err
+	}
+	return schedule, nil
+}
+
+// SaveDigestSchedule validates and stores the digest schedule and records
+// the change in the audit log
+func (s *Service) SaveDigestSchedule(schedule models.DigestSchedule) error {
+	schedule.Period = strings.ToLower(strings.TrimSpace(schedule.Period))
+	if schedule.Period != models.DigestWeekly && schedule.Period != models.DigestMonthly {
+		return invalidf("digest period must be %q or %q", models.DigestWeekly, models.DigestMonthly)
+	}
+
+	return s.ExecTx(func(tx *Service) error {
+		if err := tx.settingsRepo.Set(settingDigestSchedule, schedule); err != nil {
+			return err
+		}
+
+		details := "Digest: off"
+		if schedule.Enabled {
+			details = "Digest: " + schedule.Period
+		}
+		entityType := "setting"
+		_, err := tx.auditRepo.Create(models.AuditEntry{
+			Action:     models.AuditSettingsUpdated,
+			EntityType: &entityType,
+			Details:    details,
+		})
+		return err
+	})
+}
+
+// DeliverScheduledDigest records the digest of the last complete period
+// before now in the notification center, if the saved schedule is enabled
+// and that period's digest has not been delivered yet. It returns the
+// delivered digest, or nil when none was due.
+func (s *Service) DeliverScheduledDigest(now time.Time) (*models.Digest, error) {
+	schedule, err := s.GetDigestSchedule()
+	if err != nil || !schedule.Enabled {
+		return nil, err
+	}
+	digest, err := s.GetDigest(schedule.Period, now)
+	if err != nil {
+		return nil, err
+	}
+
+	delivered := false
+	err = s.ExecTx(func(tx *Service) error {
+		var last digestRecord
+		found, err := tx.settingsRepo.Get(settingLastDigest, &last)
+		if err != nil {
+			return err
+		}
+		if found && last.Period == digest.Period && last.From == digest.Current.From.String() {
+			return nil
+		}
+
+		title := "Weekly sales digest"
+		if digest.Period == models.DigestMonthly {
+			title = "Monthly sales digest"
+		}
+		if _, err := tx.Notify(models.Notification{
+			Source:  models.NotificationSourceDigest,
+			Level:   models.NotificationInfo,
+			Title:   title,
+			Message: digest.Text(),
+		}); err != nil {
+			return err
+		}
+		delivered = true
+		return tx.settingsRepo.Set(settingLastDigest, digestRecord{Period: digest.Period, From: digest.Current.From.String()})
+	})
+	if err != nil || !delivered {
+		return nil, err
+	}
+	return digest, nil
+}
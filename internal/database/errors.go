@@ -0,0 +1,44 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors that callers can match with errors.Is to tell failures apart
+var (
+	// ErrNotFound is matched by errors for records that do not exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrValidation is matched by errors for input rejected before it reaches
+	// the database
+	ErrValidation = errors.New("validation failed")
+
+	// ErrSchemaTooNew is matched by errors for databases migrated by a newer
+	// version of the app
+	ErrSchemaTooNew = errors.New("database was created by a newer version of the app")
+
+	// ErrUnauthorized is matched by errors for API tokens that are unknown,
+	// revoked, expired or not granted the scope a request needs
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrQueryTimeout is matched by errors for queries stopped for running
+	// longer than the timeout of their operation class
+	ErrQueryTimeout = errors.New("query timed out")
+)
+
+// validationError is an input error. Its message is shown as is, and it
+// matches ErrValidation.
+type validationError struct {
+	message string
+}
+
+func (e *validationError) Error() string { return e.message }
+
+// Is reports whether target is ErrValidation
+func (e *validationError) Is(target error) bool { return target == ErrValidation }
+
+// invalidf returns a validation error with a formatted message
+func invalidf(format string, args ...interface{}) error {
+	return &validationError{message: fmt.Sprintf(format, args...)}
+}
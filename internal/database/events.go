@@ -0,0 +1,133 @@
+package database
+
+import (
+	"sync"
+
+	"sales-track/internal/models"
+)
+
+// Change-feed event names, emitted after the write they describe is committed
+const (
+	EventRecordCreated   = "record.created"
+	EventRecordUpdated   = "record.updated"
+	EventRecordDeleted   = "record.deleted"
+	EventImportCompleted = "import.completed"
+	EventRecordsEnriched = "records.enriched" // Payload is the models.EnrichmentResult of a change to many records
+
+	EventAdjustmentCreated = "adjustment.created"
+	EventAdjustmentDeleted = "adjustment.deleted"
+
+	EventFeeCreated = "fee.created"
+	EventFeeDeleted = "fee.deleted"
+
+	EventPeriodClosed   = "period.closed"   // Payload is the models.PeriodClose
+	EventPeriodReopened = "period.reopened" // Payload is the models.PeriodClose
+
+	EventRetentionApplied = "retention.applied" // Payload is the models.RetentionResult of a run that changed data
+
+	EventMaintenanceProgress  = "maintenance.progress"  // Payload is a MaintenanceProgress
+	EventMaintenanceCompleted = "maintenance.completed" // Payload is the final MaintenanceProgress, with Error set on failure
+
+	EventReportsRefreshed = "reports.refreshed" // Payload is a ReportsRefreshedEvent
+
+	EventNotificationCreated = "notification.created" // Payload is the models.Notification
+)
+
+// RecordChangeEvent is the payload of the record.* events
+type RecordChangeEvent struct {
+	ID     int64               `json:"id"`
+	Record *models.SalesRecord `json:"record,omitempty"` // Not set for deletions
+}
+
+// ImportCompletedEvent is the payload of import.completed. Bulk writes emit a
+// single import.completed instead of one record.* event per row.
+type ImportCompletedEvent struct {
+	Inserted  int `json:"inserted"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Failed    int `json:"failed"`
+}
+
+// AdjustmentChangeEvent is the payload of the adjustment.* events
+type AdjustmentChangeEvent struct {
+	ID            int64                   `json:"id"`
+	SalesRecordID int64                   `json:"sales_record_id,omitempty"` // Not set for deletions
+	Adjustment    *models.SalesAdjustment `json:"adjustment,omitempty"`      // Not set for deletions
+}
+
+// FeeChangeEvent is the payload of the fee.* events
+type FeeChangeEvent struct {
+	ID  int64       `json:"id"`
+	Fee *models.Fee `json:"fee,omitempty"` // Not set for deletions
+}
+
+// EventEmitter delivers a change-feed event to listeners such as the frontend
+type EventEmitter func(name string, payload interface{})
+
+// changeEvent is an event waiting for its transaction to commit
+type changeEvent struct {
+	name    string
+	payload interface{}
+}
+
+// changeFeed holds the emitter shared by a service and its transaction-bound
+// copies, and the watchers the service itself notifies of each event
+type changeFeed struct {
+	mu       sync.RWMutex
+	emitter  EventEmitter
+	watchers []func(name string)
+}
+
+// emit delivers an event to the watchers and, if one is registered, the
+// emitter
+func (f *changeFeed) emit(name string, payload interface{}) {
+	f.mu.RLock()
+	emitter := f.emitter
+	watchers := f.watchers
+	f.mu.RUnlock()
+
+	for _, watch := range watchers {
+		watch(name)
+	}
+	if emitter != nil {
+		emitter(name, payload)
+	}
+}
+
+// watch registers a function called with the name of every event. It must
+// not block.
+func (f *changeFeed) watch(watcher func(name string)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.watchers = append(f.watchers, watcher)
+}
+
+// SetEventEmitter registers the function that receives change-feed events.
+// Pass nil to stop emitting.
+func (s *Service) SetEventEmitter(emitter EventEmitter) {
+	s.feed.mu.Lock()
+	defer s.feed.mu.Unlock()
+	s.feed.emitter = emitter
+}
+
+// emitChange emits an event, or queues it until commit when the service is
+// bound to a transaction, so listeners never see writes that are rolled back.
+// The cache is invalidated first so listeners that reload see the change.
+func (s *Service) emitChange(name string, payload interface{}) {
+	if s.pending != nil {
+		*s.pending = append(*s.pending, changeEvent{name: name, payload: payload})
+		return
+	}
+	s.invalidateCache()
+	s.feed.emit(name, payload)
+}
+
+// flushChanges emits the events queued by a committed transaction
+func (s *Service) flushChanges(events []changeEvent) {
+	if len(events) > 0 {
+		s.invalidateCache()
+	}
+	for _, event := range events {
+		s.feed.emit(event.name, event.payload)
+	}
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// exchangeRateColumns is the column list selected for an exchange rate, in the
+// order expected by scanExchangeRate
+const exchangeRateColumns = "id, date, currency, rate, source, created_at"
+
+// ExchangeRateRepository handles database operations for historical exchange rates
+type ExchangeRateRepository struct {
+	db *DB
+	q  queryer
+	tx *sql.Tx // non-nil when the repository is bound to a transaction
+}
+
+// NewExchangeRateRepository creates a new exchange rate repository
+func NewExchangeRateRepository(db *DB) *ExchangeRateRepository {
+	return &ExchangeRateRepository{db: db, q: db.conn}
+}
+
+// WithTx returns a copy of the repository whose operations run inside tx
+func (r *ExchangeRateRepository) WithTx(tx *sql.Tx) *ExchangeRateRepository {
+	return &ExchangeRateRepository{db: r.db, q: tx, tx: tx}
+}
+
+// scanExchangeRate scans a row selected with exchangeRateColumns
+func scanExchangeRate(scanner rowScanner, rate *models.ExchangeRate) error {
+	return scanner.Scan(
+		&rate.ID,
+		&rate.Date,
+		&rate.Currency,
+		&rate.Rate,
+		&rate.Source,
+		&rate.CreatedAt,
+	)
+}
+
+// Save stores a rate, replacing any existing rate for the same currency and date
+func (r *ExchangeRateRepository) Save(rate models.CreateExchangeRateRequest) (*models.ExchangeRate, error) {
+	date, err := time.Parse("2006-01-02", rate.Date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date format: %w", err)
+	}
+
+	source := rate.Source
+	if source == "" {
+		source = "manual"
+	}
+
+	query := `
+		INSERT INTO exchange_rates (date, currency, rate, source)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(currency, date) DO UPDATE SET
+			rate = excluded.rate,
+			source = excluded.source
+		RETURNING ` + exchangeRateColumns
+
+	var saved models.ExchangeRate
+	err = scanExchangeRate(r.q.QueryRow(query, date, rate.Currency, rate.Rate, source), &saved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save exchange rate: %w", err)
+	}
+
+	return &saved, nil
+}
+
+// SaveBatch stores rates in a single transaction and returns how many were saved
+func (r *ExchangeRateRepository) SaveBatch(rates []models.CreateExchangeRateRequest) (int, error) {
+	save := func(tx *sql.Tx) error {
+		txRepo := r.WithTx(tx)
+		for i, rate := range rates {
+			if _, err := txRepo.Save(rate); err != nil {
+				return fmt.Errorf("rate %d (%s %s): %w", i+1, rate.Currency, rate.Date, err)
+			}
+		}
+		return nil
+	}
+
+	var err error
+	if r.tx != nil {
+		err = save(r.tx)
+	} else {
+		err = r.db.ExecTx(save)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return len(rates), nil
+}
+
+// List retrieves exchange rates matching the filter, newest first
+func (r *ExchangeRateRepository) List(filter models.ExchangeRateFilter) ([]models.ExchangeRate, error) {
+	var whereParts []string
+	var args []interface{}
+
+	if filter.Currency != nil {
+		whereParts = append(whereParts, "currency = ?")
+		args = append(args, *filter.Currency)
+	}
+	if filter.DateFrom != nil {
+		whereParts = append(whereParts, "date >= ?")
+		args = append(args, *filter.DateFrom)
+	}
+	if filter.DateTo != nil {
+		whereParts = append(whereParts, "date <= ?")
+		args = append(args, *filter.DateTo)
+	}
+
+	query := "SELECT " + exchangeRateColumns + " FROM exchange_rates"
+	if len(whereParts) > 0 {
+		query += " WHERE " + strings.Join(whereParts, " AND ")
+	}
+	query += " ORDER BY date DESC, currency"
+
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exchange rates: %w", err)
+	}
+	defer rows.Close()
+
+	var rates []models.ExchangeRate
+	for rows.Next() {
+		var rate models.ExchangeRate
+		if err := scanExchangeRate(rows, &rate); err != nil {
+			return nil, fmt.Errorf("failed to scan exchange rate: %w", err)
+		}
+		rates = append(rates, rate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating exchange rates: %w", err)
+	}
+
+	return rates, nil
+}
+
+// Delete removes an exchange rate
+func (r *ExchangeRateRepository) Delete(id int64) error {
+	result, err := r.q.Exec("DELETE FROM exchange_rates WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete exchange rate: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("exchange rate with ID %d %w", id, ErrNotFound)
+	}
+
+	return nil
+}
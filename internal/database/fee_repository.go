@@ -0,0 +1,259 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// feeColumns is the column list selected for a fee, in the order expected by
+// scanFee
+const feeColumns = "id, store, vendor, date, category, description, amount, import_run_id, created_at"
+
+// FeeRepository handles database operations for fees
+type FeeRepository struct {
+	db *DB
+	q  queryer
+}
+
+// NewFeeRepository creates a new fee repository
+func NewFeeRepository(db *DB) *FeeRepository {
+	return &FeeRepository{db: db, q: db.conn}
+}
+
+// WithTx returns a copy of the repository whose operations run inside tx
+func (r *FeeRepository) WithTx(tx *sql.Tx) *FeeRepository {
+	return &FeeRepository{db: r.db, q: tx}
+}
+
+// scanFee scans a row selected with feeColumns
+func scanFee(scanner rowScanner, fee *models.Fee) error {
+	return scanner.Scan(
+		&fee.ID,
+		&fee.Store,
+		&fee.Vendor,
+		&fee.Date,
+		&fee.Category,
+		&fee.Description,
+		&fee.Amount,
+		&fee.ImportRunID,
+		&fee.CreatedAt,
+	)
+}
+
+// Create records a fee, attributed to importRunID when it was read from an
+// import
+func (r *FeeRepository) Create(fee models.CreateFeeRequest, importRunID *int64) (*models.Fee, error) {
+	date, err := time.Parse("2006-01-02", fee.Date)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date format: %w", err)
+	}
+
+	query := `
+		INSERT INTO fees (store, vendor, date, category, description, amount, import_run_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING ` + feeColumns
+
+	var created models.Fee
+	err = scanFee(r.q.QueryRow(query,
+		fee.Store,
+		fee.Vendor,
+		date,
+		fee.Category,
+		fee.Description,
+		fee.Amount,
+		importRunID,
+	), &created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fee: %w", err)
+	}
+
+	return &created, nil
+}
+
+// feeWhere builds the WHERE clause selecting the fees of a filter
+func feeWhere(filter models.FeeFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if filter.Store != nil {
+		conditions = append(conditions, "store = ?")
+		args = append(args, *filter.Store)
+	}
+	if filter.Category != nil {
+		conditions = append(conditions, "category = ?")
+		args = append(args, *filter.Category)
+	}
+	if filter.DateFrom != nil {
+		conditions = append(conditions, "date >= ?")
+		args = append(args, *filter.DateFrom)
+	}
+	if filter.DateTo != nil {
+		conditions = append(conditions, "date <= ?")
+		args = append(args, *filter.DateTo)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// List retrieves the fees matching a filter, newest first
+func (r *FeeRepository) List(filter models.FeeFilter) ([]models.Fee, error) {
+	where, args := feeWhere(filter)
+	rows, err := r.q.Query("SELECT "+feeColumns+" FROM fees"+where+" ORDER BY date DESC, id DESC", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fees: %w", err)
+	}
+	defer rows.Close()
+
+	fees := []models.Fee{}
+	for rows.Next() {
+		var fee models.Fee
+		if err := scanFee(rows, &fee); err != nil {
+			return nil, fmt.Errorf("failed to scan fee: %w", err)
+		}
+		fees = append(fees, fee)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating fees: %w", err)
+	}
+
+	return fees, nil
+}
+
+// Delete removes a fee
+func (r *FeeRepository) Delete(id int64) error {
+	result, err := r.q.Exec("DELETE FROM fees WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete fee: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("fee with ID %d %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+// periodWhere builds the WHERE clause selecting the rows of a year and store,
+// either of which may be nil to select all
+func periodWhere(year, store *string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if year != nil {
+		conditions = append(conditions, "strftime('%Y', date) = ?")
+		args = append(args, *year)
+	}
+	if store != nil {
+		conditions = append(conditions, "store = ?")
+		args = append(args, *store)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// MonthlySummary totals the fees of a year and store by month, newest first
+func (r *FeeRepository) MonthlySummary(year, store *string) ([]models.MonthlyFeeSummary, error) {
+	where, args := periodWhere(year, store)
+	rows, err := r.q.Query(`
+		SELECT strftime('%Y-%m', date) as month, category, COUNT(*), ROUND(SUM(amount), 2)
+		FROM fees`+where+`
+		GROUP BY month, category
+		ORDER BY month DESC, category`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fee summary: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := []models.MonthlyFeeSummary{}
+	for rows.Next() {
+		var month, category string
+		var fees int64
+		var total float64
+		if err := rows.Scan(&month, &category, &fees, &total); err != nil {
+			return nil, fmt.Errorf("failed to scan fee summary: %w", err)
+		}
+		if len(summaries) == 0 || summaries[len(summaries)-1].Month != month {
+			summaries = append(summaries, models.MonthlyFeeSummary{Month: month, ByCategory: make(map[string]float64)})
+		}
+		summary := &summaries[len(summaries)-1]
+		summary.Fees += fees
+		summary.Total = roundCents(summary.Total + total)
+		summary.ByCategory[category] = total
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating fee summary: %w", err)
+	}
+
+	return summaries, nil
+}
+
+// NetIncome returns the remaining amount of the sales of a year and store,
+// less the fees charged, by month, newest first. Months with only sales or
+// only fees are included.
+func (r *FeeRepository) NetIncome(year, store *string) ([]models.NetIncomeMonth, error) {
+	months := make(map[string]*models.NetIncomeMonth)
+	month := func(name string) *models.NetIncomeMonth {
+		if months[name] == nil {
+			months[name] = &models.NetIncomeMonth{Month: name}
+		}
+		return months[name]
+	}
+
+	where, args := periodWhere(year, store)
+	rows, err := r.q.Query(`
+		SELECT
+			strftime('%Y-%m', date) as month,
+			ROUND(SUM(CASE WHEN is_return = 1 THEN -sale_price ELSE sale_price END), 2),
+			ROUND(COALESCE(SUM(CASE WHEN is_return = 1 THEN -remaining ELSE remaining END), 0), 2)
+		FROM sales_records`+where+`
+		GROUP BY month`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query net income: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var sales, remaining float64
+		if err := rows.Scan(&name, &sales, &remaining); err != nil {
+			return nil, fmt.Errorf("failed to scan net income: %w", err)
+		}
+		income := month(name)
+		income.TotalSales, income.TotalRemaining = sales, remaining
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating net income: %w", err)
+	}
+
+	fees, err := r.MonthlySummary(year, store)
+	if err != nil {
+		return nil, err
+	}
+	for _, summary := range fees {
+		month(summary.Month).TotalFees = summary.Total
+	}
+
+	result := make([]models.NetIncomeMonth, 0, len(months))
+	for _, income := range months {
+		income.NetIncome = roundCents(income.TotalRemaining - income.TotalFees)
+		result = append(result, *income)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Month > result[j].Month
+	})
+	return result, nil
+}
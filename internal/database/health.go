@@ -0,0 +1,265 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Health check severities, from best to worst
+const (
+	SeverityOK       = "ok"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Health check thresholds
+const (
+	walWarningSize    = 64 << 20  // 64MB
+	walCriticalSize   = 512 << 20 // 512MB
+	diskWarningFree   = 1 << 30   // 1GB
+	diskCriticalFree  = 100 << 20 // 100MB
+	backupWarningAge  = 7 * 24 * time.Hour
+	backupCriticalAge = 30 * 24 * time.Hour
+)
+
+// HealthCheck is the outcome of a single health check
+type HealthCheck struct {
+	Name     string `json:"name"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Action   string `json:"action,omitempty"` // Suggested fix; empty when the check passed
+}
+
+// HealthReport is the outcome of every health check
+type HealthReport struct {
+	Status         string            `json:"status"` // Worst severity among the checks
+	CheckedAt      time.Time         `json:"checked_at"`
+	Checks         []HealthCheck     `json:"checks"`
+	WALSize        int64             `json:"wal_size"`                  // Bytes in the write-ahead log
+	LastCheckpoint *CheckpointResult `json:"last_checkpoint,omitempty"` // Latest scheduled checkpoint; nil if none has run
+	Metrics        *DatabaseMetrics  `json:"metrics"`                   // Connection pool statistics and query timings
+}
+
+// severityRank orders severities so the worst can be found
+var severityRank = map[string]int{
+	SeverityOK:       0,
+	SeverityWarning:  1,
+	SeverityCritical: 2,
+}
+
+// HealthReport runs every health check. Checks that cannot run, such as the
+// disk space of an in-memory database, pass with an explanatory message.
+func (s *Service) HealthReport() *HealthReport {
+	report := &HealthReport{Status: SeverityOK, CheckedAt: time.Now()}
+
+	connection := s.checkConnection()
+	report.add(connection)
+	if connection.Severity == SeverityOK {
+		report.add(s.checkSchemaVersion())
+		report.add(s.checkPendingMigrations())
+		report.add(s.checkLastBackup(report.CheckedAt))
+	}
+	report.add(s.checkWALSize())
+	report.add(s.checkDiskSpace())
+	report.WALSize, _ = s.db.WALSize()
+	report.LastCheckpoint = s.LastCheckpoint()
+	report.Metrics = s.GetDatabaseMetrics()
+
+	return report
+}
+
+// add appends a check and raises the report status to its severity
+func (r *HealthReport) add(check HealthCheck) {
+	r.Checks = append(r.Checks, check)
+	if severityRank[check.Severity] > severityRank[r.Status] {
+		r.Status = check.Severity
+	}
+}
+
+// checkConnection verifies that the database answers queries
+func (s *Service) checkConnection() HealthCheck {
+	check := HealthCheck{Name: "connection"}
+	if err := s.Health(); err != nil {
+		check.Severity = SeverityCritical
+		check.Message = err.Error()
+		check.Action = "Restart the app. If the problem persists, check that the database file exists and is not locked by another program."
+		return check
+	}
+	check.Severity = SeverityOK
+	check.Message = "Database is responding"
+	return check
+}
+
+// checkSchemaVersion compares the applied schema version with this build's
+func (s *Service) checkSchemaVersion() HealthCheck {
+	check := HealthCheck{Name: "schema_version"}
+	applied, expected, err := s.db.SchemaVersion()
+	switch {
+	case err != nil:
+		check.Severity = SeverityCritical
+		check.Message = err.Error()
+		check.Action = "Restore the database from a backup."
+	case applied > expected:
+		check.Severity = SeverityCritical
+		check.Message = fmt.Sprintf("Schema version %d was created by a newer version of the app, which expects %d", applied, expected)
+		check.Action = "Update the app before making changes to this database."
+	case applied < expected:
+		check.Severity = SeverityWarning
+		check.Message = fmt.Sprintf("Schema version %d is older than the expected version %d", applied, expected)
+		check.Action = "Run the pending migrations."
+	default:
+		check.Severity = SeverityOK
+		check.Message = fmt.Sprintf("Schema version %d is current", applied)
+	}
+	return check
+}
+
+// checkPendingMigrations lists embedded migrations that have not been applied
+func (s *Service) checkPendingMigrations() HealthCheck {
+	check := HealthCheck{Name: "pending_migrations"}
+	status, err := s.db.GetMigrationStatus()
+	if err != nil {
+		check.Severity = SeverityWarning
+		check.Message = err.Error()
+		check.Action = "Run the pending migrations."
+		return check
+	}
+
+	var pending []string
+	for _, migration := range status {
+		if !migration.Applied {
+			pending = append(pending, fmt.Sprintf("%03d_%s", migration.Version, migration.Name))
+		}
+	}
+	if len(pending) > 0 {
+		check.Severity = SeverityWarning
+		check.Message = fmt.Sprintf("%d migrations pending: %s", len(pending), strings.Join(pending, ", "))
+		check.Action = "Run the pending migrations."
+		return check
+	}
+	check.Severity = SeverityOK
+	check.Message = "All migrations are applied"
+	return check
+}
+
+// checkWALSize flags a write-ahead log that has grown without being checkpointed
+func (s *Service) checkWALSize() HealthCheck {
+	check := HealthCheck{Name: "wal_size", Severity: SeverityOK}
+	if s.db.filePath == ":memory:" {
+		check.Message = "In-memory databases have no write-ahead log"
+		return check
+	}
+
+	info, err := os.Stat(s.db.filePath + "-wal")
+	if os.IsNotExist(err) {
+		check.Message = "Write-ahead log is empty"
+		return check
+	}
+	if err != nil {
+		check.Severity = SeverityWarning
+		check.Message = fmt.Sprintf("Could not read the write-ahead log: %v", err)
+		return check
+	}
+
+	size := info.Size()
+	check.Message = fmt.Sprintf("Write-ahead log is %s", formatBytes(uint64(size)))
+	if last := s.LastCheckpoint(); last != nil {
+		if last.Error != "" {
+			check.Message += fmt.Sprintf("; the last checkpoint failed: %s", last.Error)
+		} else {
+			check.Message += fmt.Sprintf("; last checkpointed at %s", last.At.Format("15:04"))
+		}
+	}
+	switch {
+	case size >= walCriticalSize:
+		check.Severity = SeverityCritical
+	case size >= walWarningSize:
+		check.Severity = SeverityWarning
+	default:
+		return check
+	}
+	check.Action = "Compact the database, or restart the app, to fold the write-ahead log back into the database file."
+	return check
+}
+
+// checkDiskSpace flags low free space on the drive holding the database
+func (s *Service) checkDiskSpace() HealthCheck {
+	check := HealthCheck{Name: "disk_space", Severity: SeverityOK}
+	if s.db.filePath == ":memory:" {
+		check.Message = "In-memory databases use no disk space"
+		return check
+	}
+
+	dir := filepath.Dir(s.db.filePath)
+	free, err := freeDiskSpace(dir)
+	if err != nil {
+		check.Severity = SeverityWarning
+		check.Message = fmt.Sprintf("Could not determine free disk space: %v", err)
+		return check
+	}
+
+	check.Message = fmt.Sprintf("%s free on the drive holding the database", formatBytes(free))
+	switch {
+	case free < diskCriticalFree:
+		check.Severity = SeverityCritical
+	case free < diskWarningFree:
+		check.Severity = SeverityWarning
+	default:
+		return check
+	}
+	check.Action = fmt.Sprintf("Free up space on the drive holding %s. Imports and backups fail when it runs out.", dir)
+	return check
+}
+
+// checkLastBackup flags a database that has not been backed up recently
+func (s *Service) checkLastBackup(now time.Time) HealthCheck {
+	check := HealthCheck{Name: "last_backup"}
+
+	var last backupRecord
+	found, err := s.settingsRepo.Get(settingLastBackup, &last)
+	switch {
+	case err != nil:
+		check.Severity = SeverityWarning
+		check.Message = err.Error()
+		check.Action = "Back up the database."
+		return check
+	case !found:
+		check.Severity = SeverityWarning
+		check.Message = "The database has never been backed up"
+		check.Action = "Back up the database."
+		return check
+	}
+
+	age := now.Sub(last.At)
+	check.Message = fmt.Sprintf("Last backed up %s to %s", last.At.Local().Format("2006-01-02 15:04"), last.Path)
+	switch {
+	case age >= backupCriticalAge:
+		check.Severity = SeverityCritical
+	case age >= backupWarningAge:
+		check.Severity = SeverityWarning
+	default:
+		check.Severity = SeverityOK
+		return check
+	}
+	check.Action = fmt.Sprintf("Back up the database; the last backup is %d days old.", int(age.Hours()/24))
+	return check
+}
+
+// formatBytes formats a byte count with a binary unit, such as "1.5 GB"
+func formatBytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	value := float64(bytes)
+	suffixes := []string{"KB", "MB", "GB", "TB"}
+	i := -1
+	for value >= unit && i < len(suffixes)-1 {
+		value /= unit
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", value, suffixes[i])
+}
@@ -0,0 +1,467 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// importRunColumns is the column list selected for an import run, in the
+// order expected by scanImportRun
+const importRunColumns = "id, started_at, source, file_name, title, name, method, layout, success, total_rows, parsed_rows, imported_rows, updated_rows, unchanged_rows, error_rows, duration_ms, parse_ms, validate_ms, dedupe_ms, insert_ms, error_message, content_hash, created_at"
+
+// A month's error rate is flagged as high when it is at least
+// highErrorRateFactor times the overall rate and at least minHighErrorRate
+const (
+	highErrorRateFactor = 2.0
+	minHighErrorRate    = 0.05
+)
+
+// ImportHistoryRepository handles database operations for the import history
+type ImportHistoryRepository struct {
+	db *DB
+	q  queryer
+}
+
+// NewImportHistoryRepository creates a new import history repository
+func NewImportHistoryRepository(db *DB) *ImportHistoryRepository {
+	return &ImportHistoryRepository{db: db, q: db.conn}
+}
+
+// WithTx returns a copy of the repository whose operations run inside tx
+func (r *ImportHistoryRepository) WithTx(tx *sql.Tx) *ImportHistoryRepository {
+	return &ImportHistoryRepository{db: r.db, q: tx}
+}
+
+// scanImportRun scans a row selected with importRunColumns
+func scanImportRun(scanner rowScanner, run *models.ImportRun) error {
+	var durationMs int64
+	var parseMs, validateMs, dedupeMs, insertMs sql.NullInt64
+	err := scanner.Scan(
+		&run.ID,
+		&run.StartedAt,
+		&run.Source,
+		&run.FileName,
+		&run.Title,
+		&run.Name,
+		&run.Method,
+		&run.Layout,
+		&run.Success,
+		&run.TotalRows,
+		&run.ParsedRows,
+		&run.ImportedRows,
+		&run.UpdatedRows,
+		&run.UnchangedRows,
+		&run.ErrorRows,
+		&durationMs,
+		&parseMs,
+		&validateMs,
+		&dedupeMs,
+		&insertMs,
+		&run.ErrorMessage,
+		&run.ContentHash,
+		&run.CreatedAt,
+	)
+	run.Duration = models.Duration(time.Duration(durationMs) * time.Millisecond)
+	run.Timings = nil
+	if parseMs.Valid {
+		run.Timings = &models.ImportTimings{
+			Parse:    models.Duration(time.Duration(parseMs.Int64) * time.Millisecond),
+			Validate: models.Duration(time.Duration(validateMs.Int64) * time.Millisecond),
+			Dedupe:   models.Duration(time.Duration(dedupeMs.Int64) * time.Millisecond),
+			Insert:   models.Duration(time.Duration(insertMs.Int64) * time.Millisecond),
+		}
+	}
+	return err
+}
+
+// timingValues returns the parse, validate, dedupe and insert milliseconds of
+// a run, all nil when it has no timings
+func timingValues(run models.ImportRun) []interface{} {
+	if run.Timings == nil {
+		return []interface{}{nil, nil, nil, nil}
+	}
+	return []interface{}{
+		time.Duration(run.Timings.Parse).Milliseconds(),
+		time.Duration(run.Timings.Validate).Milliseconds(),
+		time.Duration(run.Timings.Dedupe).Milliseconds(),
+		time.Duration(run.Timings.Insert).Milliseconds(),
+	}
+}
+
+// Create records the summary of an import
+func (r *ImportHistoryRepository) Create(run models.ImportRun) (*models.ImportRun, error) {
+	if run.FileName != nil {
+		name := filepath.Base(*run.FileName)
+		run.FileName = &name
+	}
+
+	query := `
+		INSERT INTO import_runs (
+			started_at, source, file_name, title, method, layout, success,
+			total_rows, parsed_rows, imported_rows, updated_rows, unchanged_rows,
+			error_rows, duration_ms, parse_ms, validate_ms, dedupe_ms, insert_ms, error_message
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING ` + importRunColumns
+
+	args := []interface{}{
+		run.StartedAt.UTC(),
+		run.Source,
+		run.FileName,
+		run.Title,
+		run.Method,
+		run.Layout,
+		run.Success,
+		run.TotalRows,
+		run.ParsedRows,
+		run.ImportedRows,
+		run.UpdatedRows,
+		run.UnchangedRows,
+		run.ErrorRows,
+		time.Duration(run.Duration).Milliseconds(),
+	}
+	args = append(args, timingValues(run)...)
+	args = append(args, run.ErrorMessage)
+
+	var created models.ImportRun
+	err := scanImportRun(r.q.QueryRow(query, args...), &created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record import: %w", err)
+	}
+
+	return &created, nil
+}
+
+// Update stores the counts and outcome of an import recorded when it started
+func (r *ImportHistoryRepository) Update(run models.ImportRun) error {
+	args := []interface{}{
+		run.Title,
+		run.Name,
+		run.Layout,
+		run.Success,
+		run.TotalRows,
+		run.ParsedRows,
+		run.ImportedRows,
+		run.UpdatedRows,
+		run.UnchangedRows,
+		run.ErrorRows,
+		time.Duration(run.Duration).Milliseconds(),
+	}
+	args = append(args, timingValues(run)...)
+	args = append(args, run.ErrorMessage, run.ContentHash, run.ID)
+
+	result, err := r.q.Exec(`
+		UPDATE import_runs SET
+			title = ?, name = ?, layout = ?, success = ?, total_rows = ?, parsed_rows = ?, imported_rows = ?,
+			updated_rows = ?, unchanged_rows = ?, error_rows = ?, duration_ms = ?,
+			parse_ms = ?, validate_ms = ?, dedupe_ms = ?, insert_ms = ?, error_message = ?, content_hash = ?
+		WHERE id = ?`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update import: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("import with ID %d %w", run.ID, ErrNotFound)
+	}
+
+	return nil
+}
+
+// importContent summarizes the records an import wrote
+type importContent struct {
+	Records   int
+	FirstDate string // YYYY-MM-DD
+	LastDate  string
+	Store     string // Store with the most records, first by name on a tie
+}
+
+// Content summarizes the records last written by an import
+func (r *ImportHistoryRepository) Content(runID int64) (importContent, error) {
+	var content importContent
+	var first, last sql.NullString
+	err := r.q.QueryRow(`
+		SELECT COUNT(*), date(MIN(date)), date(MAX(date))
+		FROM sales_records
+		WHERE import_run_id = ?`, runID).Scan(&content.Records, &first, &last)
+	if err != nil {
+		return content, fmt.Errorf("failed to summarize import: %w", err)
+	}
+	if content.Records == 0 {
+		return content, nil
+	}
+	content.FirstDate, content.LastDate = first.String, last.String
+
+	err = r.q.QueryRow(`
+		SELECT store
+		FROM sales_records
+		WHERE import_run_id = ?
+		GROUP BY store
+		ORDER BY COUNT(*) DESC, store
+		LIMIT 1`, runID).Scan(&content.Store)
+	if err != nil {
+		return content, fmt.Errorf("failed to find main store of import: %w", err)
+	}
+
+	return content, nil
+}
+
+// importName names an import by its source label, the months and main store
+// of the records it wrote and its row count, such as "Consignable – March
+// 2024 – Downtown Store, 182 rows"
+func importName(label string, content importContent, rows int) string {
+	parts := []string{label}
+	if content.Records > 0 {
+		if months := monthRange(content.FirstDate, content.LastDate); months != "" {
+			parts = append(parts, months)
+		}
+		parts = append(parts, content.Store)
+	}
+
+	unit := "rows"
+	if rows == 1 {
+		unit = "row"
+	}
+	return fmt.Sprintf("%s, %d %s", strings.Join(parts, " – "), rows, unit)
+}
+
+// monthRange describes the months between two dates, such as "March 2024",
+// "January–March 2024" or "December 2023–January 2024"
+func monthRange(first, last string) string {
+	from, err := time.Parse("2006-01-02", first)
+	if err != nil {
+		return ""
+	}
+	to, err := time.Parse("2006-01-02", last)
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case from.Year() == to.Year() && from.Month() == to.Month():
+		return from.Format("January 2006")
+	case from.Year() == to.Year():
+		return from.Format("January") + "–" + to.Format("January 2006")
+	default:
+		return from.Format("January 2006") + "–" + to.Format("January 2006")
+	}
+}
+
+// Delete removes an import from the history
+func (r *ImportHistoryRepository) Delete(id int64) error {
+	if _, err := r.q.Exec("DELETE FROM import_runs WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete import: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves an import by its ID
+func (r *ImportHistoryRepository) GetByID(id int64) (*models.ImportRun, error) {
+	var run models.ImportRun
+	err := scanImportRun(r.q.QueryRow("SELECT "+importRunColumns+" FROM import_runs WHERE id = ?", id), &run)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("import with ID %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import: %w", err)
+	}
+
+	return &run, nil
+}
+
+// FindByContentHash retrieves the most recent successful import whose parsed
+// records have the content hash hash
+func (r *ImportHistoryRepository) FindByContentHash(hash string) (*models.ImportRun, error) {
+	query := "SELECT " + importRunColumns + " FROM import_runs WHERE content_hash = ? AND success = 1 ORDER BY started_at DESC, id DESC LIMIT 1"
+
+	var run models.ImportRun
+	err := scanImportRun(r.q.QueryRow(query, hash), &run)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("import with content hash %s %w", hash, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find import by content hash: %w", err)
+	}
+
+	return &run, nil
+}
+
+// List retrieves the most recent imports, newest first
+func (r *ImportHistoryRepository) List(limit int) ([]models.ImportRun, error) {
+	query := "SELECT " + importRunColumns + " FROM import_runs ORDER BY started_at DESC, id DESC LIMIT ?"
+
+	rows, err := r.q.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import history: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []models.ImportRun
+	for rows.Next() {
+		var run models.ImportRun
+		if err := scanImportRun(rows, &run); err != nil {
+			return nil, fmt.Errorf("failed to scan import run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating import history: %w", err)
+	}
+
+	return runs, nil
+}
+
+// SaveSource compresses and stores the raw content of an import run. Content
+// longer than models.MaxImportSourceSize is not stored.
+func (r *ImportHistoryRepository) SaveSource(runID int64, content io.Reader) error {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	size, err := io.Copy(zw, io.LimitReader(content, models.MaxImportSourceSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read import source: %w", err)
+	}
+	if size > models.MaxImportSourceSize {
+		return fmt.Errorf("import source exceeds %d bytes", models.MaxImportSourceSize)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress import source: %w", err)
+	}
+
+	_, err = r.q.Exec("INSERT INTO import_sources (import_run_id, content, size) VALUES (?, ?, ?)",
+		runID, compressed.Bytes(), size)
+	if err != nil {
+		return fmt.Errorf("failed to save import source: %w", err)
+	}
+	return nil
+}
+
+// HasSource reports whether the raw content of an import run is kept
+func (r *ImportHistoryRepository) HasSource(runID int64) (bool, error) {
+	var exists bool
+	err := r.q.QueryRow("SELECT EXISTS (SELECT 1 FROM import_sources WHERE import_run_id = ?)", runID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check import source: %w", err)
+	}
+	return exists, nil
+}
+
+// GetSource retrieves and decompresses the raw content of an import run
+func (r *ImportHistoryRepository) GetSource(runID int64) (*models.ImportSource, error) {
+	var compressed []byte
+	source := models.ImportSource{ImportRunID: runID}
+	err := r.q.QueryRow("SELECT content, size, created_at FROM import_sources WHERE import_run_id = ?", runID).
+		Scan(&compressed, &source.Size, &source.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("source of import %d %w", runID, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import source: %w", err)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress import source: %w", err)
+	}
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress import source: %w", err)
+	}
+	source.Content = string(content)
+	source.StoredSize = int64(len(compressed))
+
+	return &source, nil
+}
+
+// GetActivity returns import activity by month, oldest first, with months
+// that had no imports filled in and unusually high error rates flagged
+func (r *ImportHistoryRepository) GetActivity() ([]models.ImportActivity, error) {
+	query := `
+		SELECT month, imports, failed_imports, total_rows, imported_rows, updated_rows, error_rows, error_rate
+		FROM v_import_activity_monthly
+		ORDER BY month`
+
+	rows, err := r.q.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import activity: %w", err)
+	}
+	defer rows.Close()
+
+	var months []models.ImportActivity
+	for rows.Next() {
+		var activity models.ImportActivity
+		err := rows.Scan(
+			&activity.Month,
+			&activity.Imports,
+			&activity.FailedImports,
+			&activity.TotalRows,
+			&activity.ImportedRows,
+			&activity.UpdatedRows,
+			&activity.ErrorRows,
+			&activity.ErrorRate,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan import activity: %w", err)
+		}
+		months = append(months, activity)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating import activity: %w", err)
+	}
+
+	flagHighErrorRates(months)
+	return fillActivityGaps(months)
+}
+
+// flagHighErrorRates marks months whose error rate stands out from the overall rate
+func flagHighErrorRates(months []models.ImportActivity) {
+	var totalRows, errorRows int64
+	for _, month := range months {
+		totalRows += month.TotalRows
+		errorRows += month.ErrorRows
+	}
+	if totalRows == 0 {
+		return
+	}
+
+	overall := float64(errorRows) / float64(totalRows)
+	for i := range months {
+		rate := months[i].ErrorRate
+		months[i].HighErrorRate = rate >= minHighErrorRate && rate >= overall*highErrorRateFactor
+	}
+}
+
+// fillActivityGaps inserts empty months between the first and last month
+func fillActivityGaps(months []models.ImportActivity) ([]models.ImportActivity, error) {
+	if len(months) < 2 {
+		return months, nil
+	}
+
+	filled := make([]models.ImportActivity, 0, len(months))
+	for i, month := range months {
+		if i > 0 {
+			previous, err := time.Parse("2006-01", months[i-1].Month)
+			if err != nil {
+				return nil, fmt.Errorf("invalid import month %q: %w", months[i-1].Month, err)
+			}
+			for gap := previous.AddDate(0, 1, 0); gap.Format("2006-01") < month.Month; gap = gap.AddDate(0, 1, 0) {
+				filled = append(filled, models.ImportActivity{Month: gap.Format("2006-01")})
+			}
+		}
+		filled = append(filled, month)
+	}
+
+	return filled, nil
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"sync"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// importTimer adds up the time an import's writes spend looking up records
+// already imported and writing records. Streaming imports write from more
+// than one goroutine.
+type importTimer struct {
+	mu     sync.Mutex
+	dedupe time.Duration
+	insert time.Duration
+}
+
+// addDedupe counts the time since start as looking up existing records
+func (t *importTimer) addDedupe(start time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.dedupe += time.Since(start)
+	t.mu.Unlock()
+}
+
+// addInsert counts the time since start as writing records
+func (t *importTimer) addInsert(start time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.insert += time.Since(start)
+	t.mu.Unlock()
+}
+
+// timings returns the dedupe and insert time counted so far
+func (t *importTimer) timings() models.ImportTimings {
+	if t == nil {
+		return models.ImportTimings{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return models.ImportTimings{Dedupe: models.Duration(t.dedupe), Insert: models.Duration(t.insert)}
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// DefaultMaintenanceInterval is how often the maintenance scheduler runs
+const DefaultMaintenanceInterval = 24 * time.Hour
+
+// MaintenanceReport receives the outcome of each scheduled maintenance run.
+// result is nil when the retention policy is not enabled.
+type MaintenanceReport func(result *models.RetentionResult, err error)
+
+// MaintenanceScheduler periodically applies the saved retention policy while
+// it is enabled, delivers the scheduled digest once its period is complete,
+// and checkpoints the WAL so it does not grow through a long session
+type MaintenanceScheduler struct {
+	service            *Service
+	interval           time.Duration
+	checkpointInterval time.Duration
+	report             MaintenanceReport
+}
+
+// NewMaintenanceScheduler creates a scheduler that runs every interval and
+// passes the outcome of each run to report, which may be nil. It checkpoints
+// the WAL every DefaultCheckpointInterval.
+func NewMaintenanceScheduler(service *Service, interval time.Duration, report MaintenanceReport) *MaintenanceScheduler {
+	if interval <= 0 {
+		interval = DefaultMaintenanceInterval
+	}
+	return &MaintenanceScheduler{service: service, interval: interval, checkpointInterval: DefaultCheckpointInterval, report: report}
+}
+
+// SetCheckpointInterval changes how often Run checkpoints the WAL. It must
+// be called before Run.
+func (m *MaintenanceScheduler) SetCheckpointInterval(interval time.Duration) {
+	if interval > 0 {
+		m.checkpointInterval = interval
+	}
+}
+
+// Run performs maintenance and delivers any digest due immediately and then
+// every interval, and checkpoints the WAL every checkpoint interval, until
+// ctx is done
+func (m *MaintenanceScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	checkpoints := time.NewTicker(m.checkpointInterval)
+	defer checkpoints.Stop()
+
+	m.runRetention()
+	m.runDigest()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.runRetention()
+			m.runDigest()
+		case <-checkpoints.C:
+			// A failed checkpoint is kept for the health report
+			m.service.CheckpointWAL()
+		}
+	}
+}
+
+// runRetention applies the retention policy, records the outcome as a
+// notification when the run failed or changed data, and reports it
+func (m *MaintenanceScheduler) runRetention() {
+	result, err := m.RunOnce(time.Now())
+	m.service.NotifyRetention(result, err)
+	if m.report != nil {
+		m.report(result, err)
+	}
+}
+
+// runDigest delivers the scheduled digest if one is due, recording a
+// notification when that fails
+func (m *MaintenanceScheduler) runDigest() {
+	if _, err := m.service.DeliverScheduledDigest(time.Now()); err != nil {
+		m.service.notifyOutcome(models.NotificationSourceDigest, "", "Sales digest failed", "", err)
+	}
+}
+
+// RunOnce applies the saved retention policy relative to now if it is
+// enabled. It returns a nil result when the policy is disabled.
+func (m *MaintenanceScheduler) RunOnce(now time.Time) (*models.RetentionResult, error) {
+	policy, err := m.service.GetRetentionPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if !policy.Enabled {
+		return nil, nil
+	}
+	return m.service.ApplyRetention(policy, now)
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"embed"
 	"fmt"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -166,6 +167,111 @@ func (db *DB) applyMigration(migration Migration) error {
 	})
 }
 
+// SchemaVersion returns the highest applied migration version, 0 for a new
+// database, and the highest migration version embedded in this build
+func (db *DB) SchemaVersion() (applied int, expected int, err error) {
+	expected, err = db.supportedSchemaVersion()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var tables int
+	err = db.conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'migrations'").Scan(&tables)
+	if err != nil {
+		return 0, expected, fmt.Errorf("failed to check migrations table: %w", err)
+	}
+	if tables == 0 {
+		return 0, expected, nil
+	}
+
+	if err := db.conn.QueryRow("SELECT COALESCE(MAX(version), 0) FROM migrations").Scan(&applied); err != nil {
+		return 0, expected, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return applied, expected, nil
+}
+
+// SchemaCompatibility compares the schema version of a database with the
+// versions this build can work with
+type SchemaCompatibility struct {
+	AppliedVersion   int    `json:"applied_version"`   // Highest migration applied to the database
+	SupportedVersion int    `json:"supported_version"` // Highest migration embedded in this build
+	Compatible       bool   `json:"compatible"`        // False when a newer app version created the database
+	Message          string `json:"message"`
+}
+
+// Err returns an error matching ErrSchemaTooNew if the database is not compatible
+func (c *SchemaCompatibility) Err() error {
+	if c.Compatible {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrSchemaTooNew, c.Message)
+}
+
+// CheckSchemaCompatibility reports whether this build can open the database.
+// Databases with older schemas are compatible because migrations upgrade
+// them; a database migrated by a newer build is not, since this build would
+// fail on tables and columns it does not know.
+func (db *DB) CheckSchemaCompatibility() (*SchemaCompatibility, error) {
+	applied, expected, err := db.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	compatibility := &SchemaCompatibility{
+		AppliedVersion:   applied,
+		SupportedVersion: expected,
+		Compatible:       applied <= expected,
+	}
+	switch {
+	case applied > expected:
+		compatibility.Message = fmt.Sprintf("This database was created by a newer version of the app (schema version %d; this version supports up to %d). Update the app to open it.", applied, expected)
+	case applied < expected:
+		compatibility.Message = fmt.Sprintf("Schema version %d will be upgraded to %d", applied, expected)
+	default:
+		compatibility.Message = fmt.Sprintf("Schema version %d is current", applied)
+	}
+	return compatibility, nil
+}
+
+// InspectSchema checks the schema compatibility of the database file at
+// filePath without migrating or otherwise changing it. A missing file is
+// compatible, since it would be created with the current schema.
+func InspectSchema(filePath string) (*SchemaCompatibility, error) {
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		db := &DB{filePath: filePath}
+		expected, err := db.supportedSchemaVersion()
+		if err != nil {
+			return nil, err
+		}
+		return &SchemaCompatibility{
+			SupportedVersion: expected,
+			Compatible:       true,
+			Message:          "A new database will be created",
+		}, nil
+	}
+
+	conn, err := sql.Open(driverName, "file:"+filePath+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer conn.Close()
+
+	db := &DB{conn: conn, filePath: filePath}
+	return db.CheckSchemaCompatibility()
+}
+
+// supportedSchemaVersion returns the highest migration version embedded in this build
+func (db *DB) supportedSchemaVersion() (int, error) {
+	migrations, err := db.loadMigrations()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
 // GetMigrationStatus returns the current migration status
 func (db *DB) GetMigrationStatus() ([]MigrationStatus, error) {
 	// Get all available migrations
@@ -0,0 +1,172 @@
+-- Migration: 002_nullable_commission.sql
+-- Description: Allow commission and remaining to be unknown (NULL)
+-- Created: 2026-10-16
+-- Version: 1.1
+
+-- Commission and remaining used to default to 0.00 whether the source report
+-- omitted them or genuinely reported zero. SQLite cannot drop a NOT NULL
+-- constraint in place, so the table is rebuilt and the dependent trigger and
+-- views are recreated. Existing rows keep their stored values.
+
+-- ============================================================================
+-- DROP DEPENDENT OBJECTS
+-- ============================================================================
+
+DROP VIEW IF EXISTS v_yearly_sales_summary;
+DROP VIEW IF EXISTS v_monthly_sales_summary;
+DROP VIEW IF EXISTS v_daily_sales_summary;
+DROP VIEW IF EXISTS v_store_performance;
+DROP VIEW IF EXISTS v_vendor_performance;
+DROP TRIGGER IF EXISTS trg_sales_records_updated_at;
+
+-- ============================================================================
+-- REBUILD SALES RECORDS TABLE
+-- ============================================================================
+
+CREATE TABLE sales_records_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    store VARCHAR(100) NOT NULL,
+    vendor VARCHAR(100) NOT NULL,
+    date DATE NOT NULL,
+    description TEXT NOT NULL,
+    sale_price DECIMAL(10,2) NOT NULL,
+    commission DECIMAL(10,2),
+    remaining DECIMAL(10,2),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    -- Constraints
+    CONSTRAINT chk_sale_price_positive CHECK (sale_price >= 0),
+    CONSTRAINT chk_commission_positive CHECK (commission IS NULL OR commission >= 0),
+    CONSTRAINT chk_remaining_positive CHECK (remaining IS NULL OR remaining >= 0),
+    CONSTRAINT chk_date_format CHECK (date IS NOT NULL AND date != ''),
+    CONSTRAINT chk_store_not_empty CHECK (LENGTH(TRIM(store)) > 0),
+    CONSTRAINT chk_vendor_not_empty CHECK (LENGTH(TRIM(vendor)) > 0),
+    CONSTRAINT chk_description_not_empty CHECK (LENGTH(TRIM(description)) > 0)
+);
+
+INSERT INTO sales_records_new (id, store, vendor, date, description, sale_price, commission, remaining, created_at, updated_at)
+SELECT id, store, vendor, date, description, sale_price, commission, remaining, created_at, updated_at
+FROM sales_records;
+
+DROP TABLE sales_records;
+ALTER TABLE sales_records_new RENAME TO sales_records;
+
+-- ============================================================================
+-- RECREATE INDEXES
+-- ============================================================================
+
+CREATE INDEX idx_sales_records_date ON sales_records(date DESC);
+CREATE INDEX idx_sales_records_store ON sales_records(store);
+CREATE INDEX idx_sales_records_vendor ON sales_records(vendor);
+CREATE INDEX idx_sales_records_store_date ON sales_records(store, date DESC);
+CREATE INDEX idx_sales_records_vendor_date ON sales_records(vendor, date DESC);
+CREATE INDEX idx_sales_records_date_store_vendor ON sales_records(date DESC, store, vendor);
+CREATE INDEX idx_sales_records_remaining ON sales_records(remaining DESC) WHERE remaining > 0;
+CREATE INDEX idx_sales_records_created_at ON sales_records(created_at DESC);
+
+-- ============================================================================
+-- RECREATE TRIGGERS
+-- ============================================================================
+
+CREATE TRIGGER trg_sales_records_updated_at
+    AFTER UPDATE ON sales_records
+    FOR EACH ROW
+BEGIN
+    UPDATE sales_records
+    SET updated_at = CURRENT_TIMESTAMP
+    WHERE id = NEW.id;
+END;
+
+-- ============================================================================
+-- RECREATE VIEWS
+-- ============================================================================
+
+-- SUM/COUNT ignore NULLs, so unknown commissions no longer count as zero.
+-- commission_rate only considers rows whose commission is known; the * 1.0
+-- avoids integer division since NUMERIC affinity stores 20.00 as 20.
+
+CREATE VIEW v_yearly_sales_summary AS
+SELECT
+    strftime('%Y', date) as year,
+    COUNT(*) as items_sold,
+    SUM(sale_price) as total_sales,
+    COALESCE(SUM(commission), 0) as total_commission,
+    COALESCE(SUM(remaining), 0) as total_remaining,
+    COALESCE(SUM(commission) * 1.0 / NULLIF(SUM(CASE WHEN commission IS NOT NULL THEN sale_price END), 0), 0) as commission_rate,
+    COUNT(commission) as commission_known_items,
+    COUNT(DISTINCT store) as unique_stores,
+    COUNT(DISTINCT vendor) as unique_vendors
+FROM sales_records
+GROUP BY strftime('%Y', date)
+ORDER BY year DESC;
+
+CREATE VIEW v_monthly_sales_summary AS
+SELECT
+    strftime('%Y', date) as year,
+    strftime('%m', date) as month,
+    strftime('%Y-%m', date) as year_month,
+    COUNT(*) as items_sold,
+    SUM(sale_price) as total_sales,
+    COALESCE(SUM(commission), 0) as total_commission,
+    COALESCE(SUM(remaining), 0) as total_remaining,
+    COALESCE(SUM(commission) * 1.0 / NULLIF(SUM(CASE WHEN commission IS NOT NULL THEN sale_price END), 0), 0) as commission_rate,
+    COUNT(commission) as commission_known_items,
+    COUNT(DISTINCT store) as unique_stores,
+    COUNT(DISTINCT vendor) as unique_vendors
+FROM sales_records
+GROUP BY strftime('%Y-%m', date)
+ORDER BY year DESC, month DESC;
+
+CREATE VIEW v_daily_sales_summary AS
+SELECT
+    date,
+    strftime('%Y', date) as year,
+    strftime('%m', date) as month,
+    strftime('%d', date) as day,
+    strftime('%Y-%m', date) as year_month,
+    COUNT(*) as items_sold,
+    SUM(sale_price) as total_sales,
+    COALESCE(SUM(commission), 0) as total_commission,
+    COALESCE(SUM(remaining), 0) as total_remaining,
+    COALESCE(SUM(commission) * 1.0 / NULLIF(SUM(CASE WHEN commission IS NOT NULL THEN sale_price END), 0), 0) as commission_rate,
+    COUNT(commission) as commission_known_items,
+    COUNT(DISTINCT store) as unique_stores,
+    COUNT(DISTINCT vendor) as unique_vendors
+FROM sales_records
+GROUP BY date
+ORDER BY date DESC;
+
+CREATE VIEW v_store_performance AS
+SELECT
+    store,
+    COUNT(*) as total_items,
+    SUM(sale_price) as total_sales,
+    COALESCE(SUM(commission), 0) as total_commission,
+    COALESCE(SUM(remaining), 0) as total_remaining,
+    AVG(sale_price) as avg_sale_price,
+    COALESCE(SUM(commission) * 1.0 / NULLIF(SUM(CASE WHEN commission IS NOT NULL THEN sale_price END), 0), 0) as commission_rate,
+    COUNT(commission) as commission_known_items,
+    MIN(date) as first_sale_date,
+    MAX(date) as last_sale_date,
+    COUNT(DISTINCT vendor) as unique_vendors
+FROM sales_records
+GROUP BY store
+ORDER BY total_sales DESC;
+
+CREATE VIEW v_vendor_performance AS
+SELECT
+    vendor,
+    COUNT(*) as total_items,
+    SUM(sale_price) as total_sales,
+    COALESCE(SUM(commission), 0) as total_commission,
+    COALESCE(SUM(remaining), 0) as total_remaining,
+    AVG(sale_price) as avg_sale_price,
+    COALESCE(SUM(commission) * 1.0 / NULLIF(SUM(CASE WHEN commission IS NOT NULL THEN sale_price END), 0), 0) as commission_rate,
+    COUNT(commission) as commission_known_items,
+    MIN(date) as first_sale_date,
+    MAX(date) as last_sale_date,
+    COUNT(DISTINCT store) as unique_stores
+FROM sales_records
+GROUP BY vendor
+ORDER BY total_sales DESC;
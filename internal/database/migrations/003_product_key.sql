@@ -0,0 +1,13 @@
+-- Migration: 003_product_key.sql
+-- Description: Add canonical product key derived from description
+-- Created: 2026-10-16
+-- Version: 1.2
+
+-- product_key holds a normalized form of the description (case-folded,
+-- punctuation stripped, tokens sorted) so that "Dell XPS 13 Laptop" and
+-- "Laptop - Dell XPS13" can be grouped together. Keys are computed in Go;
+-- existing rows are backfilled by the service on startup.
+
+ALTER TABLE sales_records ADD COLUMN product_key TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_sales_records_product_key ON sales_records(product_key);
@@ -0,0 +1,15 @@
+-- Migration: 004_external_id.sql
+-- Description: Add source transaction id for idempotent re-imports
+-- Created: 2026-10-16
+-- Version: 1.3
+
+-- external_id holds the transaction or line id from the source report, when
+-- the report provides one. Ids are only unique within a store's reports, so
+-- the unique index is on (store, external_id). Rows without an id are not
+-- constrained, so reports that lack ids keep importing as before.
+
+ALTER TABLE sales_records ADD COLUMN external_id TEXT;
+
+CREATE UNIQUE INDEX idx_sales_records_store_external_id
+    ON sales_records(store, external_id)
+    WHERE external_id IS NOT NULL;
@@ -0,0 +1,26 @@
+-- Migration: 005_exchange_rates.sql
+-- Description: Add record currency and historical exchange rates for converted reporting
+-- Created: 2026-10-16
+-- Version: 1.4
+
+-- currency is the ISO 4217 code a record was reported in. NULL means the
+-- record is already in the reporting base currency and is never converted.
+
+ALTER TABLE sales_records ADD COLUMN currency TEXT;
+
+-- Rates are quoted as units of currency per 1 EUR, the convention used by the
+-- European Central Bank reference rates, so EUR itself has an implicit rate of
+-- 1 and any two currencies can be converted through it. Conversions use the
+-- latest rate on or before the sale date, since ECB publishes no weekend rates.
+
+CREATE TABLE exchange_rates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    date DATE NOT NULL,
+    currency TEXT NOT NULL,
+    rate DECIMAL(18,8) NOT NULL,
+    source TEXT NOT NULL DEFAULT 'manual',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT chk_exchange_rate_positive CHECK (rate > 0),
+    CONSTRAINT uq_exchange_rates_currency_date UNIQUE (currency, date)
+);
@@ -0,0 +1,117 @@
+-- Migration: 006_returns.sql
+-- Description: Flag returns and report gross sales, returns and net sales separately
+-- Created: 2026-10-16
+-- Version: 1.5
+
+-- Returns are stored with positive amounts and is_return = 1, so the existing
+-- non-negative checks still hold. Reports subtract them: total_sales,
+-- total_commission and total_remaining are net of returns, gross_sales and
+-- total_returns show the two sides, and items_sold counts sales only.
+
+ALTER TABLE sales_records ADD COLUMN is_return INTEGER NOT NULL DEFAULT 0 CHECK (is_return IN (0, 1));
+
+DROP VIEW IF EXISTS v_yearly_sales_summary;
+DROP VIEW IF EXISTS v_monthly_sales_summary;
+DROP VIEW IF EXISTS v_daily_sales_summary;
+DROP VIEW IF EXISTS v_store_performance;
+DROP VIEW IF EXISTS v_vendor_performance;
+
+CREATE VIEW v_yearly_sales_summary AS
+SELECT
+    strftime('%Y', date) as year,
+    COUNT(*) - SUM(is_return) as items_sold,
+    SUM(CASE WHEN is_return = 1 THEN -sale_price ELSE sale_price END) as total_sales,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN -commission ELSE commission END), 0) as total_commission,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN -remaining ELSE remaining END), 0) as total_remaining,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN -commission ELSE commission END) * 1.0 / NULLIF(SUM(CASE WHEN commission IS NULL THEN NULL WHEN is_return = 1 THEN -sale_price ELSE sale_price END), 0), 0) as commission_rate,
+    COUNT(commission) as commission_known_items,
+    COUNT(DISTINCT store) as unique_stores,
+    COUNT(DISTINCT vendor) as unique_vendors,
+    SUM(is_return) as returned_items,
+    COALESCE(SUM(CASE WHEN is_return = 0 THEN sale_price END), 0) as gross_sales,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN sale_price END), 0) as total_returns
+FROM sales_records
+GROUP BY strftime('%Y', date)
+ORDER BY year DESC;
+
+CREATE VIEW v_monthly_sales_summary AS
+SELECT
+    strftime('%Y', date) as year,
+    strftime('%m', date) as month,
+    strftime('%Y-%m', date) as year_month,
+    COUNT(*) - SUM(is_return) as items_sold,
+    SUM(CASE WHEN is_return = 1 THEN -sale_price ELSE sale_price END) as total_sales,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN -commission ELSE commission END), 0) as total_commission,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN -remaining ELSE remaining END), 0) as total_remaining,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN -commission ELSE commission END) * 1.0 / NULLIF(SUM(CASE WHEN commission IS NULL THEN NULL WHEN is_return = 1 THEN -sale_price ELSE sale_price END), 0), 0) as commission_rate,
+    COUNT(commission) as commission_known_items,
+    COUNT(DISTINCT store) as unique_stores,
+    COUNT(DISTINCT vendor) as unique_vendors,
+    SUM(is_return) as returned_items,
+    COALESCE(SUM(CASE WHEN is_return = 0 THEN sale_price END), 0) as gross_sales,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN sale_price END), 0) as total_returns
+FROM sales_records
+GROUP BY strftime('%Y-%m', date)
+ORDER BY year DESC, month DESC;
+
+CREATE VIEW v_daily_sales_summary AS
+SELECT
+    date,
+    strftime('%Y', date) as year,
+    strftime('%m', date) as month,
+    strftime('%d', date) as day,
+    strftime('%Y-%m', date) as year_month,
+    COUNT(*) - SUM(is_return) as items_sold,
+    SUM(CASE WHEN is_return = 1 THEN -sale_price ELSE sale_price END) as total_sales,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN -commission ELSE commission END), 0) as total_commission,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN -remaining ELSE remaining END), 0) as total_remaining,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN -commission ELSE commission END) * 1.0 / NULLIF(SUM(CASE WHEN commission IS NULL THEN NULL WHEN is_return = 1 THEN -sale_price ELSE sale_price END), 0), 0) as commission_rate,
+    COUNT(commission) as commission_known_items,
+    COUNT(DISTINCT store) as unique_stores,
+    COUNT(DISTINCT vendor) as unique_vendors,
+    SUM(is_return) as returned_items,
+    COALESCE(SUM(CASE WHEN is_return = 0 THEN sale_price END), 0) as gross_sales,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN sale_price END), 0) as total_returns
+FROM sales_records
+GROUP BY date
+ORDER BY date DESC;
+
+CREATE VIEW v_store_performance AS
+SELECT
+    store,
+    COUNT(*) - SUM(is_return) as total_items,
+    SUM(CASE WHEN is_return = 1 THEN -sale_price ELSE sale_price END) as total_sales,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN -commission ELSE commission END), 0) as total_commission,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN -remaining ELSE remaining END), 0) as total_remaining,
+    COALESCE(AVG(CASE WHEN is_return = 0 THEN sale_price END), 0) as avg_sale_price,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN -commission ELSE commission END) * 1.0 / NULLIF(SUM(CASE WHEN commission IS NULL THEN NULL WHEN is_return = 1 THEN -sale_price ELSE sale_price END), 0), 0) as commission_rate,
+    COUNT(commission) as commission_known_items,
+    MIN(date) as first_sale_date,
+    MAX(date) as last_sale_date,
+    COUNT(DISTINCT vendor) as unique_vendors,
+    SUM(is_return) as returned_items,
+    COALESCE(SUM(CASE WHEN is_return = 0 THEN sale_price END), 0) as gross_sales,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN sale_price END), 0) as total_returns
+FROM sales_records
+GROUP BY store
+ORDER BY total_sales DESC;
+
+CREATE VIEW v_vendor_performance AS
+SELECT
+    vendor,
+    COUNT(*) - SUM(is_return) as total_items,
+    SUM(CASE WHEN is_return = 1 THEN -sale_price ELSE sale_price END) as total_sales,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN -commission ELSE commission END), 0) as total_commission,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN -remaining ELSE remaining END), 0) as total_remaining,
+    COALESCE(AVG(CASE WHEN is_return = 0 THEN sale_price END), 0) as avg_sale_price,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN -commission ELSE commission END) * 1.0 / NULLIF(SUM(CASE WHEN commission IS NULL THEN NULL WHEN is_return = 1 THEN -sale_price ELSE sale_price END), 0), 0) as commission_rate,
+    COUNT(commission) as commission_known_items,
+    MIN(date) as first_sale_date,
+    MAX(date) as last_sale_date,
+    COUNT(DISTINCT store) as unique_stores,
+    SUM(is_return) as returned_items,
+    COALESCE(SUM(CASE WHEN is_return = 0 THEN sale_price END), 0) as gross_sales,
+    COALESCE(SUM(CASE WHEN is_return = 1 THEN sale_price END), 0) as total_returns
+FROM sales_records
+GROUP BY vendor
+ORDER BY total_sales DESC;
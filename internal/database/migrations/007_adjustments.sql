@@ -0,0 +1,29 @@
+-- Migration: 007_adjustments.sql
+-- Description: Add price adjustments that correct a sale without editing it
+-- Created: 2026-10-16
+-- Version: 1.6
+
+-- An adjustment records a correction reported on a later store statement, such
+-- as a price change or a revised commission. The original sale is left as it
+-- was recorded; reports can optionally fold adjustments in, dated on the day
+-- the adjustment was recorded so closed periods are not rewritten.
+--
+-- Deltas are applied to the amounts as stored on the sale, so for a return
+-- they change the refunded amount. A NULL commission or remaining delta means
+-- that amount was not adjusted.
+
+CREATE TABLE sales_adjustments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sales_record_id INTEGER NOT NULL REFERENCES sales_records(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    reason TEXT NOT NULL,
+    sale_price_delta DECIMAL(10,2) NOT NULL DEFAULT 0,
+    commission_delta DECIMAL(10,2),
+    remaining_delta DECIMAL(10,2),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT chk_adjustment_reason CHECK (length(trim(reason)) > 0)
+);
+
+CREATE INDEX idx_sales_adjustments_record ON sales_adjustments(sales_record_id);
+CREATE INDEX idx_sales_adjustments_date ON sales_adjustments(date);
@@ -0,0 +1,42 @@
+-- Migration: 008_import_history.sql
+-- Description: Persist a summary of every import and report import activity by month
+-- Created: 2026-10-16
+-- Version: 1.7
+
+-- One row per import run, including failed imports, so error rates can be
+-- tracked. Dry runs persist nothing and are not recorded.
+
+CREATE TABLE import_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    started_at DATETIME NOT NULL,
+    source TEXT NOT NULL,
+    file_name TEXT,
+    method TEXT NOT NULL,
+    layout TEXT,
+    success INTEGER NOT NULL DEFAULT 0 CHECK (success IN (0, 1)),
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    parsed_rows INTEGER NOT NULL DEFAULT 0,
+    imported_rows INTEGER NOT NULL DEFAULT 0,
+    updated_rows INTEGER NOT NULL DEFAULT 0,
+    unchanged_rows INTEGER NOT NULL DEFAULT 0,
+    error_rows INTEGER NOT NULL DEFAULT 0,
+    duration_ms INTEGER NOT NULL DEFAULT 0,
+    error_message TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_import_runs_started_at ON import_runs(started_at);
+
+-- Import activity by the month the imports ran in
+CREATE VIEW v_import_activity_monthly AS
+SELECT
+    strftime('%Y-%m', started_at) as month,
+    COUNT(*) as imports,
+    SUM(CASE WHEN success = 0 THEN 1 ELSE 0 END) as failed_imports,
+    SUM(total_rows) as total_rows,
+    SUM(imported_rows) as imported_rows,
+    SUM(updated_rows) as updated_rows,
+    SUM(error_rows) as error_rows,
+    COALESCE(SUM(error_rows) * 1.0 / NULLIF(SUM(total_rows), 0), 0) as error_rate
+FROM import_runs
+GROUP BY strftime('%Y-%m', started_at);
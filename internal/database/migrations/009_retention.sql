@@ -0,0 +1,92 @@
+-- Migration: 009_retention.sql
+-- Description: Add settings, an audit log, a trash for deleted records and archives for retention
+-- Created: 2026-10-16
+-- Version: 1.8
+
+-- ============================================================================
+-- SETTINGS AND AUDIT LOG
+-- ============================================================================
+
+-- Application settings stored as JSON values, such as the retention policy
+CREATE TABLE app_settings (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Append-only record of maintenance and configuration changes
+CREATE TABLE audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    action TEXT NOT NULL,
+    entity_type TEXT,
+    entity_id INTEGER,
+    details TEXT
+);
+
+CREATE INDEX idx_audit_log_created_at ON audit_log(created_at DESC);
+
+-- ============================================================================
+-- TRASH AND ARCHIVES
+-- ============================================================================
+
+-- Deleted sales records are kept here until the retention policy purges them.
+-- Rows keep their original id. Tables without constraints accept any row
+-- that was valid when it was moved.
+
+CREATE TABLE deleted_sales_records (
+    id INTEGER PRIMARY KEY,
+    store VARCHAR(100) NOT NULL,
+    vendor VARCHAR(100) NOT NULL,
+    date DATE NOT NULL,
+    description TEXT NOT NULL,
+    product_key TEXT,
+    sale_price DECIMAL(10,2) NOT NULL,
+    commission DECIMAL(10,2),
+    remaining DECIMAL(10,2),
+    is_return INTEGER NOT NULL DEFAULT 0,
+    currency TEXT,
+    external_id TEXT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    deleted_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_deleted_sales_records_deleted_at ON deleted_sales_records(deleted_at);
+
+-- Records older than the retention period are moved out of reports into the
+-- archive, together with their adjustments
+
+CREATE TABLE sales_records_archive (
+    id INTEGER PRIMARY KEY,
+    store VARCHAR(100) NOT NULL,
+    vendor VARCHAR(100) NOT NULL,
+    date DATE NOT NULL,
+    description TEXT NOT NULL,
+    product_key TEXT,
+    sale_price DECIMAL(10,2) NOT NULL,
+    commission DECIMAL(10,2),
+    remaining DECIMAL(10,2),
+    is_return INTEGER NOT NULL DEFAULT 0,
+    currency TEXT,
+    external_id TEXT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    archived_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_sales_records_archive_date ON sales_records_archive(date);
+
+CREATE TABLE sales_adjustments_archive (
+    id INTEGER PRIMARY KEY,
+    sales_record_id INTEGER NOT NULL,
+    date DATE NOT NULL,
+    reason TEXT NOT NULL,
+    sale_price_delta DECIMAL(10,2) NOT NULL,
+    commission_delta DECIMAL(10,2),
+    remaining_delta DECIMAL(10,2),
+    created_at DATETIME NOT NULL,
+    archived_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_sales_adjustments_archive_record ON sales_adjustments_archive(sales_record_id);
@@ -0,0 +1,10 @@
+-- Migration: 010_import_title.sql
+-- Description: Name imports after the caption or heading of the imported table
+-- Created: 2026-10-16
+-- Version: 1.9
+
+-- title holds the caption, heading or section title found near the imported
+-- table, such as "Consignment Statement - March 2024". Imports of data
+-- without one keep a NULL title and are named by source and date.
+
+ALTER TABLE import_runs ADD COLUMN title TEXT;
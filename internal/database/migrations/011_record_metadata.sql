@@ -0,0 +1,16 @@
+-- Migration: 011_record_metadata.sql
+-- Description: Add custom fields to sales records as a JSON object
+-- Created: 2026-10-16
+-- Version: 2.0
+
+-- metadata holds custom fields as a JSON object of strings, such as
+-- {"Item #": "A-12", "Payout Date": "2024-04-01"}: report columns that no
+-- field was mapped to, or fields entered by hand. Records without custom
+-- fields keep a NULL metadata. Filters read fields with json_extract.
+
+ALTER TABLE sales_records ADD COLUMN metadata TEXT
+    CHECK (metadata IS NULL OR json_valid(metadata));
+
+-- The trash and the archive hold copies of full records
+ALTER TABLE deleted_sales_records ADD COLUMN metadata TEXT;
+ALTER TABLE sales_records_archive ADD COLUMN metadata TEXT;
@@ -0,0 +1,20 @@
+-- Migration: 012_commission_rules.sql
+-- Description: Add per-store commission rate rules for reconciling reported commissions
+-- Created: 2026-10-16
+-- Version: 2.1
+
+-- A rule sets the commission rate a store charges from effective_from until
+-- the store's next rule. Rates are fractions of the sale price, so 0.3 is 30%.
+-- Reconciliation compares each sale's reported commission with its sale price
+-- times the rate in effect on the sale date.
+
+CREATE TABLE commission_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    store TEXT NOT NULL,
+    effective_from DATE NOT NULL,
+    rate DECIMAL(6,4) NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT chk_commission_rule_rate CHECK (rate >= 0 AND rate <= 1),
+    CONSTRAINT uq_commission_rules_store_date UNIQUE (store, effective_from)
+);
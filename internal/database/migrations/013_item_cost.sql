@@ -0,0 +1,20 @@
+-- Migration: 013_item_cost.sql
+-- Description: Add what the seller paid for each item and a category for profitability reports
+-- Created: 2026-10-16
+-- Version: 2.2
+
+-- cost is what the seller paid for the item, in the record's currency. NULL
+-- means unknown, and such records are left out of profit figures rather than
+-- counted as free. category is a free-form grouping such as "Furniture".
+
+ALTER TABLE sales_records ADD COLUMN cost DECIMAL(10,2)
+    CHECK (cost IS NULL OR cost >= 0);
+ALTER TABLE sales_records ADD COLUMN category TEXT;
+
+CREATE INDEX idx_sales_records_category ON sales_records(category) WHERE category IS NOT NULL;
+
+-- The trash and the archive hold copies of full records
+ALTER TABLE deleted_sales_records ADD COLUMN cost DECIMAL(10,2);
+ALTER TABLE deleted_sales_records ADD COLUMN category TEXT;
+ALTER TABLE sales_records_archive ADD COLUMN cost DECIMAL(10,2);
+ALTER TABLE sales_records_archive ADD COLUMN category TEXT;
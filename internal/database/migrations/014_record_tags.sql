@@ -0,0 +1,13 @@
+-- Migration: 014_record_tags.sql
+-- Description: Add free-form tags to sales records for bulk enrichment
+-- Created: 2026-10-16
+-- Version: 2.3
+
+-- tags is a JSON array of strings such as ["estate sale","consigned"], kept
+-- sorted and without duplicates. NULL means no tags.
+
+ALTER TABLE sales_records ADD COLUMN tags TEXT;
+
+-- The trash and the archive hold copies of full records
+ALTER TABLE deleted_sales_records ADD COLUMN tags TEXT;
+ALTER TABLE sales_records_archive ADD COLUMN tags TEXT;
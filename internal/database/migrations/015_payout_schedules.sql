@@ -0,0 +1,19 @@
+-- Migration: 015_payout_schedules.sql
+-- Description: Add per-store payout schedules for forecasting payout dates
+-- Created: 2026-10-16
+-- Version: 2.4
+
+-- A store pays out each month's sales on payout_day of the month
+-- months_after the sale month, so a store paying on the 15th of the next
+-- month has payout_day 15 and months_after 1. Days past the end of a short
+-- month fall on its last day.
+
+CREATE TABLE store_payout_schedules (
+    store TEXT PRIMARY KEY,
+    payout_day INTEGER NOT NULL,
+    months_after INTEGER NOT NULL DEFAULT 1,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT chk_payout_day CHECK (payout_day >= 1 AND payout_day <= 31),
+    CONSTRAINT chk_payout_months_after CHECK (months_after >= 0 AND months_after <= 3)
+);
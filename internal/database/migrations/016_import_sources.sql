@@ -0,0 +1,17 @@
+-- Migration: 016_import_sources.sql
+-- Description: Keep the raw content of each import with its import history
+-- Created: 2026-10-16
+-- Version: 2.5
+
+-- content is the pasted or uploaded data exactly as imported, gzip
+-- compressed, so disputed numbers can be traced back to what the store's
+-- portal showed. size is its length before compression. Sources are kept
+-- apart from import_runs so listing the history does not read them, and are
+-- removed with their import run.
+
+CREATE TABLE import_sources (
+    import_run_id INTEGER PRIMARY KEY REFERENCES import_runs(id) ON DELETE CASCADE,
+    content BLOB NOT NULL,
+    size INTEGER NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
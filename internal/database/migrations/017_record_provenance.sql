@@ -0,0 +1,27 @@
+-- Migration: 017_record_provenance.sql
+-- Description: Link sales records to the import and source row they came from
+-- Created: 2026-10-16
+-- Version: 2.6
+
+-- import_run_id is the import that last wrote a record; NULL for records
+-- entered by hand or imported before provenance was kept. source_row is the
+-- row of the import's source the record was parsed from, as a JSON object
+-- such as {"row": 3, "headers": ["Date", ...], "cells": ["2024-03-01", ...]}.
+
+ALTER TABLE sales_records ADD COLUMN import_run_id INTEGER
+    REFERENCES import_runs(id) ON DELETE SET NULL;
+ALTER TABLE sales_records ADD COLUMN source_row TEXT
+    CHECK (source_row IS NULL OR json_valid(source_row));
+
+CREATE INDEX idx_sales_records_import_run ON sales_records(import_run_id)
+    WHERE import_run_id IS NOT NULL;
+
+-- The changes made to a record since are read from the audit log
+CREATE INDEX idx_audit_log_entity ON audit_log(entity_type, entity_id)
+    WHERE entity_id IS NOT NULL;
+
+-- The trash and the archive hold copies of full records
+ALTER TABLE deleted_sales_records ADD COLUMN import_run_id INTEGER;
+ALTER TABLE deleted_sales_records ADD COLUMN source_row TEXT;
+ALTER TABLE sales_records_archive ADD COLUMN import_run_id INTEGER;
+ALTER TABLE sales_records_archive ADD COLUMN source_row TEXT;
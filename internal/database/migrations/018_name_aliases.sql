@@ -0,0 +1,20 @@
+-- Migration: 018_name_aliases.sql
+-- Description: Add store and vendor name aliases applied when importing
+-- Created: 2026-10-16
+-- Version: 2.7
+
+-- An alias maps a store or vendor name as it appears in imported reports,
+-- such as "DT Branch", to the name records are kept under, such as
+-- "Downtown Store". Aliases are matched ignoring case and apply to records
+-- imported after they are saved.
+
+CREATE TABLE name_aliases (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    alias TEXT NOT NULL COLLATE NOCASE,
+    canonical TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT chk_name_alias_kind CHECK (kind IN ('store', 'vendor')),
+    CONSTRAINT uq_name_aliases_kind_alias UNIQUE (kind, alias)
+);
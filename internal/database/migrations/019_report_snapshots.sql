@@ -0,0 +1,30 @@
+-- Migration: 019_report_snapshots.sql
+-- Description: Add snapshots of report results for comparing against later numbers
+-- Created: 2026-10-16
+-- Version: 2.8
+
+-- A snapshot keeps the records a report covered when it was taken, such as
+-- January's sales at settlement time, so amendments made since can be found.
+-- filter is the SalesRecordFilter and totals the ReportTotals as JSON, and
+-- each record is kept as the JSON of the SalesRecord. record_id has no foreign key: records deleted
+-- since the snapshot are reported as removed.
+
+CREATE TABLE report_snapshots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    filter TEXT NOT NULL DEFAULT '{}',
+    totals TEXT NOT NULL DEFAULT '{}',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT chk_report_snapshot_filter CHECK (json_valid(filter)),
+    CONSTRAINT chk_report_snapshot_totals CHECK (json_valid(totals))
+);
+
+CREATE TABLE report_snapshot_records (
+    snapshot_id INTEGER NOT NULL REFERENCES report_snapshots(id) ON DELETE CASCADE,
+    record_id INTEGER NOT NULL,
+    record TEXT NOT NULL,
+
+    PRIMARY KEY (snapshot_id, record_id),
+    CONSTRAINT chk_report_snapshot_record CHECK (json_valid(record))
+) WITHOUT ROWID;
@@ -0,0 +1,11 @@
+-- Migration: 020_import_name.sql
+-- Description: Name imports after the records they wrote
+-- Created: 2026-10-16
+-- Version: 2.9
+
+-- name describes an import by its source, the months and main store of the
+-- records it wrote and its row count, such as "Consignable – March 2024 –
+-- Downtown Store, 182 rows". Imports recorded before it was kept have a
+-- NULL name and are still named by source and date.
+
+ALTER TABLE import_runs ADD COLUMN name TEXT;
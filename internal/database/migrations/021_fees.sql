@@ -0,0 +1,30 @@
+-- Migration: 021_fees.sql
+-- Description: Add fees charged by stores, kept apart from sales
+-- Created: 2026-10-16
+-- Version: 3.0
+
+-- A fee is a non-sale line item of a store report, such as a listing fee,
+-- booth rent or a penalty. Amounts are the positive sum charged. Net income
+-- reports subtract them from the remaining amount of the month's sales.
+--
+-- import_run_id is the import the fee was read from; NULL for fees entered
+-- by hand.
+
+CREATE TABLE fees (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    store TEXT NOT NULL,
+    vendor TEXT,
+    date DATE NOT NULL,
+    category TEXT NOT NULL DEFAULT 'other',
+    description TEXT NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    import_run_id INTEGER REFERENCES import_runs(id) ON DELETE SET NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT chk_fee_category CHECK (category IN ('listing', 'rent', 'penalty', 'other')),
+    CONSTRAINT chk_fee_amount CHECK (amount >= 0),
+    CONSTRAINT chk_fee_description CHECK (length(trim(description)) > 0)
+);
+
+CREATE INDEX idx_fees_date ON fees(date);
+CREATE INDEX idx_fees_store_date ON fees(store, date);
@@ -0,0 +1,88 @@
+-- Migration: 022_period_closes.sql
+-- Description: Add closing months so their records become read-only
+-- Created: 2026-10-16
+-- Version: 3.1
+
+-- A month is closed once it has been settled, as in accounting practice.
+-- Closing takes a report snapshot of the month's records; while the month is
+-- closed its sales, fees and adjustments cannot be added, edited or deleted.
+-- Reopening keeps the row, with when and why, so reports can flag months
+-- whose records no longer match the snapshot taken at close.
+--
+-- month is YYYY-MM. snapshot_id is the snapshot of the latest close.
+
+CREATE TABLE period_closes (
+    month TEXT PRIMARY KEY,
+    snapshot_id INTEGER REFERENCES report_snapshots(id) ON DELETE SET NULL,
+    closed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    note TEXT,
+    reopened_at DATETIME,
+    reopen_reason TEXT,
+
+    CONSTRAINT chk_period_close_month CHECK (month GLOB '[0-9][0-9][0-9][0-9]-[0-1][0-9]')
+) WITHOUT ROWID;
+
+-- The triggers refuse writes to rows dated in a closed month, whichever part
+-- of the app makes them. Updates of bookkeeping columns, such as the import a
+-- record came from being cleared when its history is purged, are let
+-- through, as are rows deleted after the retention policy copied them to the
+-- archive: archiving keeps them unchanged.
+
+CREATE TRIGGER trg_sales_records_closed_insert
+    BEFORE INSERT ON sales_records
+    WHEN EXISTS (SELECT 1 FROM period_closes WHERE month = strftime('%Y-%m', NEW.date) AND reopened_at IS NULL)
+BEGIN
+    SELECT RAISE(ABORT, 'month is closed');
+END;
+
+CREATE TRIGGER trg_sales_records_closed_update
+    BEFORE UPDATE OF store, vendor, date, description, sale_price, commission, remaining, is_return,
+        currency, external_id, metadata, cost, category, tags ON sales_records
+    WHEN EXISTS (SELECT 1 FROM period_closes WHERE month IN (strftime('%Y-%m', OLD.date), strftime('%Y-%m', NEW.date)) AND reopened_at IS NULL)
+BEGIN
+    SELECT RAISE(ABORT, 'month is closed');
+END;
+
+CREATE TRIGGER trg_sales_records_closed_delete
+    BEFORE DELETE ON sales_records
+    WHEN EXISTS (SELECT 1 FROM period_closes WHERE month = strftime('%Y-%m', OLD.date) AND reopened_at IS NULL)
+        AND NOT EXISTS (SELECT 1 FROM sales_records_archive WHERE id = OLD.id)
+BEGIN
+    SELECT RAISE(ABORT, 'month is closed');
+END;
+
+CREATE TRIGGER trg_sales_adjustments_closed_insert
+    BEFORE INSERT ON sales_adjustments
+    WHEN EXISTS (SELECT 1 FROM period_closes WHERE month = strftime('%Y-%m', NEW.date) AND reopened_at IS NULL)
+BEGIN
+    SELECT RAISE(ABORT, 'month is closed');
+END;
+
+CREATE TRIGGER trg_sales_adjustments_closed_delete
+    BEFORE DELETE ON sales_adjustments
+    WHEN EXISTS (SELECT 1 FROM period_closes WHERE month = strftime('%Y-%m', OLD.date) AND reopened_at IS NULL)
+        AND NOT EXISTS (SELECT 1 FROM sales_adjustments_archive WHERE id = OLD.id)
+BEGIN
+    SELECT RAISE(ABORT, 'month is closed');
+END;
+
+CREATE TRIGGER trg_fees_closed_insert
+    BEFORE INSERT ON fees
+    WHEN EXISTS (SELECT 1 FROM period_closes WHERE month = strftime('%Y-%m', NEW.date) AND reopened_at IS NULL)
+BEGIN
+    SELECT RAISE(ABORT, 'month is closed');
+END;
+
+CREATE TRIGGER trg_fees_closed_update
+    BEFORE UPDATE OF store, vendor, date, category, description, amount ON fees
+    WHEN EXISTS (SELECT 1 FROM period_closes WHERE month IN (strftime('%Y-%m', OLD.date), strftime('%Y-%m', NEW.date)) AND reopened_at IS NULL)
+BEGIN
+    SELECT RAISE(ABORT, 'month is closed');
+END;
+
+CREATE TRIGGER trg_fees_closed_delete
+    BEFORE DELETE ON fees
+    WHEN EXISTS (SELECT 1 FROM period_closes WHERE month = strftime('%Y-%m', OLD.date) AND reopened_at IS NULL)
+BEGIN
+    SELECT RAISE(ABORT, 'month is closed');
+END;
@@ -0,0 +1,13 @@
+-- Migration: 023_import_timings.sql
+-- Description: Keep the time each import spent in each stage
+-- Created: 2026-10-16
+-- Version: 3.2
+
+-- The milliseconds an import spent reading the table, checking its rows,
+-- looking up records already imported and writing records. Imports recorded
+-- before they were kept have NULL timings.
+
+ALTER TABLE import_runs ADD COLUMN parse_ms INTEGER;
+ALTER TABLE import_runs ADD COLUMN validate_ms INTEGER;
+ALTER TABLE import_runs ADD COLUMN dedupe_ms INTEGER;
+ALTER TABLE import_runs ADD COLUMN insert_ms INTEGER;
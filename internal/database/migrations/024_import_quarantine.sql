@@ -0,0 +1,18 @@
+-- Migration: 024_import_quarantine.sql
+-- Description: Keep imported rows that failed a business-rule check for review
+-- Created: 2026-10-16
+-- Version: 3.3
+
+-- Rows an import set aside instead of importing, such as sales dated in the
+-- future or with a commission above the sale price. record holds the parsed
+-- models.CreateSalesRecordRequest and reasons the checks it failed, both as
+-- JSON. Rows stay until they are approved into sales_records or discarded.
+CREATE TABLE quarantined_rows (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    import_run_id INTEGER REFERENCES import_runs(id) ON DELETE SET NULL,
+    record TEXT NOT NULL CHECK (json_valid(record)),
+    reasons TEXT NOT NULL CHECK (json_valid(reasons)),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_quarantined_rows_import_run_id ON quarantined_rows(import_run_id);
@@ -0,0 +1,19 @@
+-- Migration: 025_notifications.sql
+-- Description: Keep the outcomes of background work as notifications
+-- Created: 2026-10-16
+-- Version: 3.4
+
+-- Outcomes of background work such as backups and scheduled maintenance,
+-- kept until the user reads them. read_at is NULL while unread. Only the
+-- newest notifications are kept.
+CREATE TABLE notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source TEXT NOT NULL,
+    level TEXT NOT NULL CHECK (level IN ('info', 'error')),
+    title TEXT NOT NULL,
+    message TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    read_at DATETIME
+);
+
+CREATE INDEX idx_notifications_unread ON notifications(read_at) WHERE read_at IS NULL;
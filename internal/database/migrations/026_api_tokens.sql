@@ -0,0 +1,20 @@
+-- Migration: 026_api_tokens.sql
+-- Description: Keep API tokens for the local HTTP server, hashed
+-- Created: 2026-10-16
+-- Version: 3.5
+
+-- Tokens are kept as their SHA-256 hash, never in the clear. hint holds the
+-- first characters of the token to tell tokens apart, scopes a JSON array of
+-- scope names. Revoked tokens are kept, with revoked_at set, so the list
+-- shows what was revoked and when.
+CREATE TABLE api_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    hint TEXT NOT NULL,
+    scopes TEXT NOT NULL,
+    expires_at DATETIME,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME,
+    revoked_at DATETIME
+);
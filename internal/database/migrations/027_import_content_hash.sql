@@ -0,0 +1,13 @@
+-- Migration: 027_import_content_hash.sql
+-- Description: Keep a hash of the records each import parsed
+-- Created: 2026-10-16
+-- Version: 3.6
+
+-- content_hash is the SHA-256 of the records an import parsed, the same
+-- whenever the same report is imported again, so an import can be matched
+-- to an earlier one with the same content. Imports recorded before it was
+-- kept, and imports that parsed no records, have a NULL content_hash.
+
+ALTER TABLE import_runs ADD COLUMN content_hash TEXT;
+
+CREATE INDEX idx_import_runs_content_hash ON import_runs(content_hash);
@@ -0,0 +1,49 @@
+-- Migration: 028_report_aggregates.sql
+-- Description: Pre-aggregate sales by month, store and vendor and by day for reports on huge datasets
+-- Created: 2026-10-16
+-- Version: 3.7
+
+-- The sums the reporting views are calculated from, so the pivot and
+-- performance reports read thousands of rows instead of millions once report
+-- performance mode is on. Amounts follow the views: returns are subtracted,
+-- and commission, remaining and commission_sales stay NULL when no record
+-- reported them. commission_sales is the net sale price of the records with
+-- a known commission, which commission_rate divides by. Both tables are
+-- rebuilt from sales_records when a report needs them after records changed.
+
+-- One row per month, store and vendor, for the yearly and monthly summaries
+-- and store and vendor performance
+CREATE TABLE report_monthly_aggregates (
+    year_month TEXT NOT NULL,
+    store TEXT NOT NULL,
+    vendor TEXT NOT NULL,
+    items_sold INTEGER NOT NULL,
+    returned_items INTEGER NOT NULL,
+    total_sales DECIMAL(10,2) NOT NULL,
+    gross_sales DECIMAL(10,2) NOT NULL,
+    total_returns DECIMAL(10,2) NOT NULL,
+    commission DECIMAL(10,2),
+    remaining DECIMAL(10,2),
+    commission_known_items INTEGER NOT NULL,
+    commission_sales DECIMAL(10,2),
+    first_sale_date DATE NOT NULL,
+    last_sale_date DATE NOT NULL,
+    PRIMARY KEY (year_month, store, vendor)
+);
+
+-- One row per sale date, with its distinct stores and vendors counted, for
+-- the daily summary
+CREATE TABLE report_daily_aggregates (
+    date DATE PRIMARY KEY,
+    items_sold INTEGER NOT NULL,
+    returned_items INTEGER NOT NULL,
+    total_sales DECIMAL(10,2) NOT NULL,
+    gross_sales DECIMAL(10,2) NOT NULL,
+    total_returns DECIMAL(10,2) NOT NULL,
+    commission DECIMAL(10,2),
+    remaining DECIMAL(10,2),
+    commission_known_items INTEGER NOT NULL,
+    commission_sales DECIMAL(10,2),
+    unique_stores INTEGER NOT NULL,
+    unique_vendors INTEGER NOT NULL
+);
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// notificationColumns is the column list selected for a notification, in the
+// order expected by scanNotification
+const notificationColumns = "id, source, level, title, message, created_at, read_at"
+
+// maxNotifications is the number of notifications kept; older ones are
+// removed as new ones are recorded
+const maxNotifications = 500
+
+// NotificationRepository handles database operations for notifications
+type NotificationRepository struct {
+	db *DB
+	q  queryer
+}
+
+// NewNotificationRepository creates a new notification repository
+func NewNotificationRepository(db *DB) *NotificationRepository {
+	return &NotificationRepository{db: db, q: db.conn}
+}
+
+// WithTx returns a copy of the repository whose operations run inside tx
+func (r *NotificationRepository) WithTx(tx *sql.Tx) *NotificationRepository {
+	return &NotificationRepository{db: r.db, q: tx}
+}
+
+// scanNotification scans a row selected with notificationColumns
+func scanNotification(scanner rowScanner, notification *models.Notification) error {
+	return scanner.Scan(
+		&notification.ID,
+		&notification.Source,
+		&notification.Level,
+		&notification.Title,
+		&notification.Message,
+		&notification.CreatedAt,
+		&notification.ReadAt,
+	)
+}
+
+// Create records a notification and removes those beyond the newest
+// maxNotifications
+func (r *NotificationRepository) Create(notification models.Notification) (*models.Notification, error) {
+	var created models.Notification
+	err := scanNotification(r.q.QueryRow(`
+		INSERT INTO notifications (source, level, title, message)
+		VALUES (?, ?, ?, ?)
+		RETURNING `+notificationColumns,
+		notification.Source, notification.Level, notification.Title, notification.Message,
+	), &created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create notification: %w", err)
+	}
+
+	if _, err := r.q.Exec("DELETE FROM notifications WHERE id <= ?", created.ID-maxNotifications); err != nil {
+		return nil, fmt.Errorf("failed to remove old notifications: %w", err)
+	}
+	return &created, nil
+}
+
+// List retrieves the notifications of a filter, newest first
+func (r *NotificationRepository) List(filter models.NotificationFilter) ([]models.Notification, error) {
+	query := "SELECT " + notificationColumns + " FROM notifications"
+	if filter.UnreadOnly {
+		query += " WHERE read_at IS NULL"
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = models.DefaultNotificationLimit
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+
+	rows, err := r.q.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var notification models.Notification
+		if err := scanNotification(rows, &notification); err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		notifications = append(notifications, notification)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// CountUnread returns the number of notifications not yet read
+func (r *NotificationRepository) CountUnread() (int64, error) {
+	var count int64
+	if err := r.q.QueryRow("SELECT COUNT(*) FROM notifications WHERE read_at IS NULL").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count unread notifications: %w", err)
+	}
+	return count, nil
+}
+
+// MarkRead marks a notification read at now. Marking a read notification
+// again keeps the time it was first read.
+func (r *NotificationRepository) MarkRead(id int64, now time.Time) error {
+	result, err := r.q.Exec("UPDATE notifications SET read_at = COALESCE(read_at, ?) WHERE id = ?", now.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification read: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("notification with ID %d %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+// MarkAllRead marks every unread notification read at now and returns how
+// many were
+func (r *NotificationRepository) MarkAllRead(now time.Time) (int64, error) {
+	result, err := r.q.Exec("UPDATE notifications SET read_at = ? WHERE read_at IS NULL", now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark notifications read: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected, nil
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// Notify records the outcome of background work in the notification center
+// and publishes it as a notification.created event
+func (s *Service) Notify(notification models.Notification) (*models.Notification, error) {
+	if notification.Level != models.NotificationInfo && notification.Level != models.NotificationError {
+		return nil, invalidf("unknown notification level %q", notification.Level)
+	}
+	if notification.Title == "" {
+		return nil, invalidf("notification title is required")
+	}
+
+	release, err := s.beginWrite()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	created, err := s.notificationRepo.Create(notification)
+	if err != nil {
+		return nil, err
+	}
+	s.emitChange(EventNotificationCreated, *created)
+	return created, nil
+}
+
+// notifyOutcome records a notification for background work that finished
+// with err, titled done or failed. Failing to record it is only logged, as
+// the work itself is over.
+func (s *Service) notifyOutcome(source, done, failed, message string, err error) {
+	notification := models.Notification{Source: source, Level: models.NotificationInfo, Title: done, Message: message}
+	if err != nil {
+		notification.Level = models.NotificationError
+		notification.Title = failed
+		notification.Message = err.Error()
+	}
+	if _, notifyErr := s.Notify(notification); notifyErr != nil {
+		log.Printf("Failed to record %s notification: %v", source, notifyErr)
+	}
+}
+
+// NotifyRetention records the outcome of a scheduled retention run. Runs
+// that changed nothing are not recorded.
+func (s *Service) NotifyRetention(result *models.RetentionResult, err error) {
+	if err == nil && (result == nil || !result.Changed()) {
+		return
+	}
+	var message string
+	if result != nil {
+		message = fmt.Sprintf("Archived %d records and %d adjustments, and purged %d deleted records and %d import summaries.",
+			result.ArchivedRecords, result.ArchivedAdjustments, result.PurgedDeletedRecords, result.PurgedImportRuns)
+	}
+	s.notifyOutcome(models.NotificationSourceRetention, "Scheduled maintenance finished", "Scheduled maintenance failed", message, err)
+}
+
+// ListNotifications retrieves the notifications of a filter, newest first,
+// with the number unread
+func (s *Service) ListNotifications(filter models.NotificationFilter) (*models.NotificationList, error) {
+	notifications, err := s.notificationRepo.List(filter)
+	if err != nil {
+		return nil, err
+	}
+	unread, err := s.notificationRepo.CountUnread()
+	if err != nil {
+		return nil, err
+	}
+	return &models.NotificationList{Notifications: notifications, Unread: unread}, nil
+}
+
+// MarkNotificationRead marks a notification read
+func (s *Service) MarkNotificationRead(id int64, now time.Time) error {
+	release, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return s.notificationRepo.MarkRead(id, now)
+}
+
+// MarkAllNotificationsRead marks every unread notification read and returns
+// how many were
+func (s *Service) MarkAllNotificationsRead(now time.Time) (int64, error) {
+	release, err := s.beginWrite()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	return s.notificationRepo.MarkAllRead(now)
+}
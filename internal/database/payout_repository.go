@@ -0,0 +1,193 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// payoutScheduleColumns is the column list selected for a payout schedule, in
+// the order expected by scanPayoutSchedule
+const payoutScheduleColumns = "store, payout_day, months_after, updated_at"
+
+// PayoutRepository handles database operations for store payout schedules
+// and the payouts expected from them
+type PayoutRepository struct {
+	db *DB
+	q  queryer
+}
+
+// NewPayoutRepository creates a new payout repository
+func NewPayoutRepository(db *DB) *PayoutRepository {
+	return &PayoutRepository{db: db, q: db.conn}
+}
+
+// WithTx returns a copy of the repository whose operations run inside tx
+func (r *PayoutRepository) WithTx(tx *sql.Tx) *PayoutRepository {
+	return &PayoutRepository{db: r.db, q: tx}
+}
+
+// scanPayoutSchedule scans a row selected with payoutScheduleColumns
+func scanPayoutSchedule(scanner rowScanner, schedule *models.PayoutSchedule) error {
+	return scanner.Scan(
+		&schedule.Store,
+		&schedule.PayoutDay,
+		&schedule.MonthsAfter,
+		&schedule.UpdatedAt,
+	)
+}
+
+// Save stores a store's payout schedule, replacing any existing one
+func (r *PayoutRepository) Save(schedule models.SavePayoutScheduleRequest) (*models.PayoutSchedule, error) {
+	query := `
+		INSERT INTO store_payout_schedules (store, payout_day, months_after)
+		VALUES (?, ?, ?)
+		ON CONFLICT(store) DO UPDATE SET
+			payout_day = excluded.payout_day,
+			months_after = excluded.months_after,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING ` + payoutScheduleColumns
+
+	var saved models.PayoutSchedule
+	err := scanPayoutSchedule(r.q.QueryRow(query, schedule.Store, schedule.PayoutDay, schedule.MonthsAfter), &saved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save payout schedule: %w", err)
+	}
+
+	return &saved, nil
+}
+
+// List retrieves the payout schedules ordered by store
+func (r *PayoutRepository) List() ([]models.PayoutSchedule, error) {
+	rows, err := r.q.Query("SELECT " + payoutScheduleColumns + " FROM store_payout_schedules ORDER BY store")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payout schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []models.PayoutSchedule
+	for rows.Next() {
+		var schedule models.PayoutSchedule
+		if err := scanPayoutSchedule(rows, &schedule); err != nil {
+			return nil, fmt.Errorf("failed to scan payout schedule: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating payout schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+// Delete removes a store's payout schedule
+func (r *PayoutRepository) Delete(store string) error {
+	result, err := r.q.Exec("DELETE FROM store_payout_schedules WHERE store = ?", store)
+	if err != nil {
+		return fmt.Errorf("failed to delete payout schedule: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("payout schedule for store %q %w", store, ErrNotFound)
+	}
+
+	return nil
+}
+
+// Expected totals each month's sales of the stores with a payout schedule
+// and returns the payouts falling within the filter's dates, ordered by date
+// and store. Stores without a schedule are left out.
+func (r *PayoutRepository) Expected(filter models.PayoutFilter) ([]models.ExpectedPayout, error) {
+	return r.expected(filter, nil)
+}
+
+// ExpectedForVendor is Expected counting only vendor's sales, so each payout
+// is the vendor's share of what the store pays out
+func (r *PayoutRepository) ExpectedForVendor(vendor string, filter models.PayoutFilter) ([]models.ExpectedPayout, error) {
+	return r.expected(filter, &vendor)
+}
+
+// expected implements Expected, counting only the sales of vendor when it is
+// not nil
+func (r *PayoutRepository) expected(filter models.PayoutFilter, vendor *string) ([]models.ExpectedPayout, error) {
+	schedules, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	byStore := make(map[string]models.PayoutSchedule, len(schedules))
+	for _, schedule := range schedules {
+		byStore[schedule.Store] = schedule
+	}
+
+	whereParts := []string{"s.store = r.store"}
+	var args []interface{}
+	if filter.Store != nil {
+		whereParts = append(whereParts, "r.store = ?")
+		args = append(args, *filter.Store)
+	}
+	if vendor != nil {
+		whereParts = append(whereParts, "r.vendor = ?")
+		args = append(args, *vendor)
+	}
+
+	query := `
+		SELECT
+			r.store,
+			strftime('%Y-%m', r.date) AS month,
+			SUM(CASE WHEN r.is_return THEN 0 ELSE 1 END),
+			SUM(CASE WHEN r.is_return THEN 1 ELSE 0 END),
+			SUM(CASE WHEN r.is_return THEN -COALESCE(r.remaining, 0) ELSE COALESCE(r.remaining, 0) END)
+		FROM sales_records r
+		JOIN store_payout_schedules s ON ` + strings.Join(whereParts, " AND ") + `
+		GROUP BY r.store, month`
+
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expected payouts: %w", err)
+	}
+	defer rows.Close()
+
+	payouts := []models.ExpectedPayout{}
+	for rows.Next() {
+		var payout models.ExpectedPayout
+		if err := rows.Scan(&payout.Store, &payout.Month, &payout.ItemsSold, &payout.ReturnedItems, &payout.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan expected payout: %w", err)
+		}
+		month, err := time.Parse("2006-01", payout.Month)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sales month %q: %w", payout.Month, err)
+		}
+
+		date := byStore[payout.Store].PayoutDate(month)
+		if (filter.DateFrom != nil && date.Before(*filter.DateFrom)) || (filter.DateTo != nil && date.After(*filter.DateTo)) {
+			continue
+		}
+		payout.Date = models.NewDate(date)
+		payout.Amount = roundCents(payout.Amount)
+		payouts = append(payouts, payout)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating expected payouts: %w", err)
+	}
+
+	sort.Slice(payouts, func(i, j int) bool {
+		a, b := payouts[i], payouts[j]
+		if !a.Date.Equal(b.Date.Time) {
+			return a.Date.Before(b.Date.Time)
+		}
+		return a.Store < b.Store
+	})
+
+	return payouts, nil
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+
+	"sales-track/internal/models"
+)
+
+// periodClosedMessage is the error raised by the triggers refusing changes to
+// the records of a closed month
+const periodClosedMessage = "month is closed"
+
+// IsPeriodClosed reports whether err is the database refusing a change to a
+// record dated in a closed month
+func IsPeriodClosed(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) &&
+		sqliteErr.ExtendedCode == sqlite3.ErrConstraintTrigger &&
+		strings.Contains(sqliteErr.Error(), periodClosedMessage)
+}
+
+// periodCloseColumns is the column list selected for a period close, in the
+// order expected by scanPeriodClose
+const periodCloseColumns = "month, snapshot_id, closed_at, note, reopened_at, reopen_reason"
+
+// PeriodRepository handles database operations for closed months
+type PeriodRepository struct {
+	db *DB
+	q  queryer
+}
+
+// NewPeriodRepository creates a new period repository
+func NewPeriodRepository(db *DB) *PeriodRepository {
+	return &PeriodRepository{db: db, q: db.conn}
+}
+
+// WithTx returns a copy of the repository whose operations run inside tx
+func (r *PeriodRepository) WithTx(tx *sql.Tx) *PeriodRepository {
+	return &PeriodRepository{db: r.db, q: tx}
+}
+
+// scanPeriodClose scans a row selected with periodCloseColumns
+func scanPeriodClose(scanner rowScanner, period *models.PeriodClose) error {
+	return scanner.Scan(
+		&period.Month,
+		&period.SnapshotID,
+		&period.ClosedAt,
+		&period.Note,
+		&period.ReopenedAt,
+		&period.ReopenReason,
+	)
+}
+
+// Close closes a month, recording the snapshot taken of its records. A month
+// closed before is closed again with the new snapshot.
+func (r *PeriodRepository) Close(month string, snapshotID int64, note *string) (*models.PeriodClose, error) {
+	var period models.PeriodClose
+	err := scanPeriodClose(r.q.QueryRow(`
+		INSERT INTO period_closes (month, snapshot_id, note)
+		VALUES (?, ?, ?)
+		ON CONFLICT (month) DO UPDATE SET
+			snapshot_id = excluded.snapshot_id,
+			closed_at = CURRENT_TIMESTAMP,
+			note = excluded.note,
+			reopened_at = NULL,
+			reopen_reason = NULL
+		RETURNING `+periodCloseColumns, month, snapshotID, note), &period)
+	if err != nil {
+		return nil, fmt.Errorf("failed to close month: %w", err)
+	}
+
+	return &period, nil
+}
+
+// Reopen reopens a closed month, keeping why
+func (r *PeriodRepository) Reopen(month, reason string) (*models.PeriodClose, error) {
+	var period models.PeriodClose
+	err := scanPeriodClose(r.q.QueryRow(`
+		UPDATE period_closes SET reopened_at = CURRENT_TIMESTAMP, reopen_reason = ?
+		WHERE month = ? AND reopened_at IS NULL
+		RETURNING `+periodCloseColumns, reason, month), &period)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("closed month %s %w", month, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to reopen month: %w", err)
+	}
+
+	return &period, nil
+}
+
+// Get retrieves the close of a month, closed or reopened
+func (r *PeriodRepository) Get(month string) (*models.PeriodClose, error) {
+	var period models.PeriodClose
+	err := scanPeriodClose(r.q.QueryRow("SELECT "+periodCloseColumns+" FROM period_closes WHERE month = ?", month), &period)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("closed month %s %w", month, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get closed month: %w", err)
+	}
+
+	return &period, nil
+}
+
+// List retrieves every month closed or reopened, newest first
+func (r *PeriodRepository) List() ([]models.PeriodClose, error) {
+	rows, err := r.q.Query("SELECT " + periodCloseColumns + " FROM period_closes ORDER BY month DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query closed months: %w", err)
+	}
+	defer rows.Close()
+
+	periods := []models.PeriodClose{}
+	for rows.Next() {
+		var period models.PeriodClose
+		if err := scanPeriodClose(rows, &period); err != nil {
+			return nil, fmt.Errorf("failed to scan closed month: %w", err)
+		}
+		periods = append(periods, period)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate closed months: %w", err)
+	}
+
+	return periods, nil
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"sales-track/internal/models"
+)
+
+// quarantinedRowColumns is the column list selected for a quarantined row, in
+// the order expected by scanQuarantinedRow
+const quarantinedRowColumns = "id, import_run_id, record, reasons, created_at"
+
+// QuarantineRepository handles database operations for imported rows set
+// aside for review
+type QuarantineRepository struct {
+	db *DB
+	q  queryer
+}
+
+// NewQuarantineRepository creates a new quarantine repository
+func NewQuarantineRepository(db *DB) *QuarantineRepository {
+	return &QuarantineRepository{db: db, q: db.conn}
+}
+
+// WithTx returns a copy of the repository whose operations run inside tx
+func (r *QuarantineRepository) WithTx(tx *sql.Tx) *QuarantineRepository {
+	return &QuarantineRepository{db: r.db, q: tx}
+}
+
+// scanQuarantinedRow scans a row selected with quarantinedRowColumns
+func scanQuarantinedRow(scanner rowScanner, row *models.QuarantinedRow) error {
+	var record, reasons string
+	if err := scanner.Scan(&row.ID, &row.ImportRunID, &record, &reasons, &row.CreatedAt); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(record), &row.Record); err != nil {
+		return fmt.Errorf("invalid quarantined record: %w", err)
+	}
+	if err := json.Unmarshal([]byte(reasons), &row.Reasons); err != nil {
+		return fmt.Errorf("invalid quarantine reasons: %w", err)
+	}
+	return nil
+}
+
+// Create stores a quarantined row, attributed to importRunID when it was read
+// from an import
+func (r *QuarantineRepository) Create(row models.QuarantinedRow, importRunID *int64) (*models.QuarantinedRow, error) {
+	record, err := json.Marshal(row.Record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode quarantined record: %w", err)
+	}
+	reasons, err := json.Marshal(row.Reasons)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode quarantine reasons: %w", err)
+	}
+
+	var created models.QuarantinedRow
+	err = scanQuarantinedRow(r.q.QueryRow(`
+		INSERT INTO quarantined_rows (import_run_id, record, reasons)
+		VALUES (?, ?, ?)
+		RETURNING `+quarantinedRowColumns, importRunID, string(record), string(reasons)), &created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quarantined row: %w", err)
+	}
+
+	return &created, nil
+}
+
+// GetByID retrieves a quarantined row by its ID
+func (r *QuarantineRepository) GetByID(id int64) (*models.QuarantinedRow, error) {
+	var row models.QuarantinedRow
+	err := scanQuarantinedRow(r.q.QueryRow("SELECT "+quarantinedRowColumns+" FROM quarantined_rows WHERE id = ?", id), &row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("quarantined row with ID %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quarantined row: %w", err)
+	}
+	return &row, nil
+}
+
+// List retrieves the quarantined rows, oldest first, in the order they were
+// read
+func (r *QuarantineRepository) List() ([]models.QuarantinedRow, error) {
+	rows, err := r.q.Query("SELECT " + quarantinedRowColumns + " FROM quarantined_rows ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quarantined rows: %w", err)
+	}
+	defer rows.Close()
+
+	quarantined := []models.QuarantinedRow{}
+	for rows.Next() {
+		var row models.QuarantinedRow
+		if err := scanQuarantinedRow(rows, &row); err != nil {
+			return nil, fmt.Errorf("failed to scan quarantined row: %w", err)
+		}
+		quarantined = append(quarantined, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating quarantined rows: %w", err)
+	}
+
+	return quarantined, nil
+}
+
+// Delete removes a quarantined row
+func (r *QuarantineRepository) Delete(id int64) error {
+	result, err := r.q.Exec("DELETE FROM quarantined_rows WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete quarantined row: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("quarantined row with ID %d %w", id, ErrNotFound)
+	}
+
+	return nil
+}
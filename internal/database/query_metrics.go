@@ -0,0 +1,407 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"log"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"sales-track/internal/models"
+)
+
+// DefaultSlowQueryThreshold is how long a query runs before it is logged as
+// slow, unless Config sets another threshold
+const DefaultSlowQueryThreshold = 250 * time.Millisecond
+
+// maxSlowQueries is the number of recent slow queries kept for diagnostics
+const maxSlowQueries = 20
+
+// maxLoggedQueryLength is the length slow queries are cut to in the log and
+// in diagnostics
+const maxLoggedQueryLength = 300
+
+// queryTimingBounds are the upper bounds of the buckets of the query timing
+// histograms. A last bucket counts the slower queries.
+var queryTimingBounds = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	25 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	2 * time.Second,
+}
+
+// ConnectionStats are the connection pool statistics of sql.DBStats
+type ConnectionStats struct {
+	MaxOpen           int             `json:"max_open"` // 0 when unlimited
+	Open              int             `json:"open"`
+	InUse             int             `json:"in_use"`
+	Idle              int             `json:"idle"`
+	WaitCount         int64           `json:"wait_count"`    // Queries that waited for a free connection
+	WaitDuration      models.Duration `json:"wait_duration"` // Total time they waited
+	MaxIdleClosed     int64           `json:"max_idle_closed"`
+	MaxLifetimeClosed int64           `json:"max_lifetime_closed"`
+}
+
+// QueryTimingBucket counts the queries that took up to a duration
+type QueryTimingBucket struct {
+	UpTo  models.Duration `json:"up_to,omitempty"` // Zero for the bucket of queries slower than every bound
+	Count int64           `json:"count"`
+}
+
+// QueryTiming is the timing histogram of the queries one repository method
+// ran. Times are spent in SQLite: running statements and stepping through
+// rows, but not what callers do with each row.
+type QueryTiming struct {
+	Method  string              `json:"method"` // Such as "SalesRepository.List"
+	Count   int64               `json:"count"`
+	Total   models.Duration     `json:"total"`
+	Max     models.Duration     `json:"max"`
+	Buckets []QueryTimingBucket `json:"buckets"`
+}
+
+// SlowQuery is a query that took longer than the slow query threshold
+type SlowQuery struct {
+	Method   string          `json:"method"`
+	Query    string          `json:"query"` // Whitespace collapsed, cut to a few hundred characters
+	Duration models.Duration `json:"duration"`
+	At       time.Time       `json:"at"`
+}
+
+// DatabaseMetrics are the connection pool statistics and the timings of the
+// queries run since the database was opened
+type DatabaseMetrics struct {
+	Connections        ConnectionStats `json:"connections"`
+	Queries            []QueryTiming   `json:"queries"`                // Slowest in total first
+	SlowQueries        []SlowQuery     `json:"slow_queries,omitempty"` // Most recent first
+	SlowQueryThreshold models.Duration `json:"slow_query_threshold"`
+	Since              time.Time       `json:"since"` // When the database was opened
+}
+
+// Metrics returns the connection pool statistics and query timings
+func (db *DB) Metrics() *DatabaseMetrics {
+	stats := db.conn.Stats()
+	metrics := &DatabaseMetrics{
+		Connections: ConnectionStats{
+			MaxOpen:           stats.MaxOpenConnections,
+			Open:              stats.OpenConnections,
+			InUse:             stats.InUse,
+			Idle:              stats.Idle,
+			WaitCount:         stats.WaitCount,
+			WaitDuration:      models.Duration(stats.WaitDuration),
+			MaxIdleClosed:     stats.MaxIdleClosed,
+			MaxLifetimeClosed: stats.MaxLifetimeClosed,
+		},
+		Queries: []QueryTiming{},
+	}
+	if db.metrics != nil {
+		metrics.Queries, metrics.SlowQueries = db.metrics.snapshot()
+		metrics.SlowQueryThreshold = models.Duration(db.metrics.threshold)
+		metrics.Since = db.metrics.since
+	}
+	return metrics
+}
+
+// GetDatabaseMetrics returns the connection pool statistics and the timings of
+// the queries each repository method ran, with the recent slow queries
+func (s *Service) GetDatabaseMetrics() *DatabaseMetrics {
+	return s.db.Metrics()
+}
+
+// queryMetrics collects the time queries take, by the repository method that
+// ran them
+type queryMetrics struct {
+	threshold time.Duration
+	since     time.Time
+
+	mu      sync.Mutex
+	timings map[string]*queryTiming
+	slow    []SlowQuery // Oldest first
+}
+
+// queryTiming accumulates the histogram of one method
+type queryTiming struct {
+	count   int64
+	total   time.Duration
+	max     time.Duration
+	buckets []int64 // One per bound, and one for slower queries
+}
+
+// newQueryMetrics creates a collector logging queries slower than threshold
+func newQueryMetrics(threshold time.Duration) *queryMetrics {
+	if threshold <= 0 {
+		threshold = DefaultSlowQueryThreshold
+	}
+	return &queryMetrics{
+		threshold: threshold,
+		since:     time.Now(),
+		timings:   make(map[string]*queryTiming),
+	}
+}
+
+// record adds a query run by method that took elapsed, logging it if slow
+func (m *queryMetrics) record(method, query string, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	timing, ok := m.timings[method]
+	if !ok {
+		timing = &queryTiming{buckets: make([]int64, len(queryTimingBounds)+1)}
+		m.timings[method] = timing
+	}
+	timing.count++
+	timing.total += elapsed
+	if elapsed > timing.max {
+		timing.max = elapsed
+	}
+	bucket := sort.Search(len(queryTimingBounds), func(i int) bool { return elapsed <= queryTimingBounds[i] })
+	timing.buckets[bucket]++
+
+	if elapsed <= m.threshold {
+		return
+	}
+	slow := SlowQuery{Method: method, Query: compactQuery(query), Duration: models.Duration(elapsed), At: time.Now()}
+	log.Printf("Slow query in %s took %v: %s", method, elapsed.Round(time.Microsecond), slow.Query)
+	if len(m.slow) == maxSlowQueries {
+		m.slow = m.slow[1:]
+	}
+	m.slow = append(m.slow, slow)
+}
+
+// snapshot returns the histograms, slowest in total first, and the recent
+// slow queries, most recent first
+func (m *queryMetrics) snapshot() ([]QueryTiming, []SlowQuery) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	timings := make([]QueryTiming, 0, len(m.timings))
+	for method, timing := range m.timings {
+		buckets := make([]QueryTimingBucket, len(timing.buckets))
+		for i, count := range timing.buckets {
+			buckets[i].Count = count
+			if i < len(queryTimingBounds) {
+				buckets[i].UpTo = models.Duration(queryTimingBounds[i])
+			}
+		}
+		timings = append(timings, QueryTiming{
+			Method:  method,
+			Count:   timing.count,
+			Total:   models.Duration(timing.total),
+			Max:     models.Duration(timing.max),
+			Buckets: buckets,
+		})
+	}
+	sort.Slice(timings, func(i, j int) bool {
+		if timings[i].Total != timings[j].Total {
+			return timings[i].Total > timings[j].Total
+		}
+		return timings[i].Method < timings[j].Method
+	})
+
+	slow := make([]SlowQuery, len(m.slow))
+	for i, query := range m.slow {
+		slow[len(m.slow)-1-i] = query
+	}
+	return timings, slow
+}
+
+// compactQuery collapses the whitespace of a query and cuts it to
+// maxLoggedQueryLength characters
+func compactQuery(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > maxLoggedQueryLength {
+		query = query[:maxLoggedQueryLength] + "…"
+	}
+	return query
+}
+
+// databasePackage is the import path of this package, the prefix of the
+// names of its functions in stack traces
+var databasePackage = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	slash := strings.LastIndex(name, "/")
+	return name[:slash+strings.Index(name[slash:], ".")]
+}()
+
+// queryCaller names the repository method that ran a query, such as
+// "SalesRepository.List", or else the function of this package that did, or
+// "other" for queries from outside it
+func queryCaller() string {
+	pcs := make([]uintptr, 24)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+
+	caller := ""
+	for {
+		frame, more := frames.Next()
+		name, ok := strings.CutPrefix(frame.Function, databasePackage+".")
+		if ok && !strings.HasPrefix(name, "(*timed") {
+			name = methodName(name)
+			if strings.Contains(name, "Repository.") {
+				return name
+			}
+			if caller == "" {
+				caller = name
+			}
+		}
+		if !more {
+			break
+		}
+	}
+	if caller == "" {
+		return "other"
+	}
+	return caller
+}
+
+// methodName turns the name of a function in a stack trace, such as
+// "(*SalesRepository).CreateBatch.func1", into "SalesRepository.CreateBatch"
+func methodName(name string) string {
+	name = strings.NewReplacer("(*", "", ")", "").Replace(name)
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		if strings.HasPrefix(part, "func") && i > 0 {
+			parts = parts[:i]
+			break
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// timedConnector opens connections whose queries are timed into metrics and
+// stopped once they run past their timeouts
+type timedConnector struct {
+	dsn      string
+	driver   *sqlite3.SQLiteDriver
+	metrics  *queryMetrics
+	timeouts *queryTimeouts
+}
+
+// Connect implements driver.Connector
+func (c *timedConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &timedConn{SQLiteConn: conn.(*sqlite3.SQLiteConn), metrics: c.metrics, timeouts: c.timeouts}, nil
+}
+
+// Driver implements driver.Connector
+func (c *timedConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// timedConn is a SQLite connection timing the statements run on it
+type timedConn struct {
+	*sqlite3.SQLiteConn
+	metrics  *queryMetrics
+	timeouts *queryTimeouts
+}
+
+// ExecContext implements driver.ExecerContext
+func (c *timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ctx, budget := c.timeouts.begin(ctx, query)
+	start := time.Now()
+	result, err := c.SQLiteConn.ExecContext(ctx, query, args)
+	c.metrics.record(queryCaller(), query, time.Since(start))
+	return result, budget.end(err)
+}
+
+// QueryContext implements driver.QueryerContext
+func (c *timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, budget := c.timeouts.begin(ctx, query)
+	start := time.Now()
+	rows, err := c.SQLiteConn.QueryContext(ctx, query, args)
+	return timeRows(c.metrics, budget, queryCaller(), query, rows, time.Since(start)), budget.check(err)
+}
+
+// PrepareContext implements driver.ConnPrepareContext
+func (c *timedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.SQLiteConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &timedStmt{SQLiteStmt: stmt.(*sqlite3.SQLiteStmt), metrics: c.metrics, timeouts: c.timeouts, query: query}, nil
+}
+
+// sqliteConn returns the SQLite connection of a driver connection from
+// sql.Conn.Raw
+func sqliteConn(driverConn interface{}) *sqlite3.SQLiteConn {
+	if timed, ok := driverConn.(*timedConn); ok {
+		return timed.SQLiteConn
+	}
+	return driverConn.(*sqlite3.SQLiteConn)
+}
+
+// timedStmt is a prepared statement timing its runs
+type timedStmt struct {
+	*sqlite3.SQLiteStmt
+	metrics  *queryMetrics
+	timeouts *queryTimeouts
+	query    string
+}
+
+// ExecContext implements driver.StmtExecContext
+func (s *timedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	ctx, budget := s.timeouts.begin(ctx, s.query)
+	start := time.Now()
+	result, err := s.SQLiteStmt.ExecContext(ctx, args)
+	s.metrics.record(queryCaller(), s.query, time.Since(start))
+	return result, budget.end(err)
+}
+
+// QueryContext implements driver.StmtQueryContext
+func (s *timedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, budget := s.timeouts.begin(ctx, s.query)
+	start := time.Now()
+	rows, err := s.SQLiteStmt.QueryContext(ctx, args)
+	return timeRows(s.metrics, budget, queryCaller(), s.query, rows, time.Since(start)), budget.check(err)
+}
+
+// timeRows returns rows that add the time spent stepping through them to the
+// time the query took to start, and record the total when closed. The budget
+// of the query, if any, is paused until the next step.
+func timeRows(metrics *queryMetrics, budget *queryBudget, method, query string, rows driver.Rows, started time.Duration) driver.Rows {
+	sqliteRows, ok := rows.(*sqlite3.SQLiteRows)
+	if !ok {
+		metrics.record(method, query, started)
+		budget.end(nil)
+		return rows
+	}
+	budget.pause()
+	return &timedRows{SQLiteRows: sqliteRows, metrics: metrics, budget: budget, method: method, query: query, elapsed: started}
+}
+
+// timedRows are the rows of a query, timing the steps through them
+type timedRows struct {
+	*sqlite3.SQLiteRows
+	metrics *queryMetrics
+	budget  *queryBudget
+	method  string
+	query   string
+	elapsed time.Duration
+}
+
+// Next implements driver.Rows
+func (r *timedRows) Next(dest []driver.Value) error {
+	r.budget.resume(r.elapsed)
+	start := time.Now()
+	err := r.SQLiteRows.Next(dest)
+	r.elapsed += time.Since(start)
+	r.budget.pause()
+	return r.budget.check(err)
+}
+
+// Close implements driver.Rows
+func (r *timedRows) Close() error {
+	err := r.SQLiteRows.Close()
+	r.metrics.record(r.method, r.query, r.elapsed)
+	r.budget.end(nil)
+	return err
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// Operation classes of queries, each with its own timeout
+const (
+	queryClassRead        = "read"
+	queryClassWrite       = "write"
+	queryClassMaintenance = "maintenance"
+)
+
+// maintenancePragmas are the pragmas that check or reorganize the database
+// file rather than read a setting
+var maintenancePragmas = []string{
+	"integrity_check",
+	"quick_check",
+	"foreign_key_check",
+	"wal_checkpoint",
+	"optimize",
+	"incremental_vacuum",
+}
+
+// QueryTimeoutError is returned by queries stopped for running longer than
+// the timeout of their operation class. It matches ErrQueryTimeout.
+type QueryTimeoutError struct {
+	Class   string // "read", "write" or "maintenance"
+	Timeout time.Duration
+}
+
+func (e *QueryTimeoutError) Error() string {
+	return fmt.Sprintf("%s query timed out after %v", e.Class, e.Timeout)
+}
+
+// Is reports whether target is ErrQueryTimeout
+func (e *QueryTimeoutError) Is(target error) bool { return target == ErrQueryTimeout }
+
+// GetQueryTimeouts returns the saved query timeouts, or the defaults if none
+// have been saved
+func (s *Service) GetQueryTimeouts() (models.QueryTimeouts, error) {
+	timeouts := models.DefaultQueryTimeouts()
+	if _, err := s.settingsRepo.Get(settingQueryTimeouts, &timeouts); err != nil {
+		return timeouts, err
+	}
+	return timeouts, nil
+}
+
+// SaveQueryTimeouts validates and stores the query timeouts, applies them to
+// the queries run from now on and records the change in the audit log
+func (s *Service) SaveQueryTimeouts(timeouts models.QueryTimeouts) error {
+	for class, timeout := range map[string]models.Duration{
+		queryClassRead:        timeouts.Read,
+		queryClassWrite:       timeouts.Write,
+		queryClassMaintenance: timeouts.Maintenance,
+	} {
+		if timeout != 0 && time.Duration(timeout) < models.MinQueryTimeout {
+			return invalidf("%s timeout must be zero for none or at least %v", class, models.MinQueryTimeout)
+		}
+	}
+
+	err := s.ExecTx(func(tx *Service) error {
+		if err := tx.settingsRepo.Set(settingQueryTimeouts, timeouts); err != nil {
+			return err
+		}
+
+		entityType := "setting"
+		_, err := tx.auditRepo.Create(models.AuditEntry{
+			Action:     models.AuditSettingsUpdated,
+			EntityType: &entityType,
+			Details: fmt.Sprintf("Query timeouts: read %s, write %s, maintenance %s",
+				describeTimeout(timeouts.Read), describeTimeout(timeouts.Write), describeTimeout(timeouts.Maintenance)),
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.db.SetQueryTimeouts(timeouts)
+	return nil
+}
+
+// describeTimeout formats a timeout for the audit log
+func describeTimeout(timeout models.Duration) string {
+	if timeout == 0 {
+		return "none"
+	}
+	return timeout.String()
+}
+
+// SetQueryTimeouts sets the timeouts of the queries run from now on. Until it
+// is called queries have no timeout, so migrations run as long as they need.
+func (db *DB) SetQueryTimeouts(timeouts models.QueryTimeouts) {
+	db.timeouts.Store(&timeouts)
+}
+
+// queryTimeouts holds the timeouts shared by a database and its connections
+type queryTimeouts struct {
+	atomic.Pointer[models.QueryTimeouts]
+}
+
+// begin returns the budget of a query about to run, with the context to run
+// it in, or a nil budget if its class has no timeout. The budget is running.
+func (t *queryTimeouts) begin(ctx context.Context, query string) (context.Context, *queryBudget) {
+	timeouts := t.Load()
+	if timeouts == nil {
+		return ctx, nil
+	}
+
+	class := classifyQuery(query)
+	var limit time.Duration
+	switch class {
+	case queryClassRead:
+		limit = time.Duration(timeouts.Read)
+	case queryClassWrite:
+		limit = time.Duration(timeouts.Write)
+	case queryClassMaintenance:
+		limit = time.Duration(timeouts.Maintenance)
+	}
+	if limit <= 0 {
+		return ctx, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	budget := &queryBudget{class: class, limit: limit, cancel: cancel}
+	budget.timer = time.AfterFunc(limit, budget.expire)
+	return ctx, budget
+}
+
+// classifyQuery returns the operation class of a query from its first
+// keyword. Statements that are neither reads nor maintenance are writes.
+func classifyQuery(query string) string {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	end := strings.IndexAny(query, " \t\r\n(;")
+	if end < 0 {
+		end = len(query)
+	}
+	keyword := query[:end]
+
+	switch {
+	case strings.EqualFold(keyword, "SELECT"), strings.EqualFold(keyword, "WITH"),
+		strings.EqualFold(keyword, "EXPLAIN"), strings.EqualFold(keyword, "VALUES"):
+		return queryClassRead
+	case strings.EqualFold(keyword, "VACUUM"), strings.EqualFold(keyword, "ANALYZE"),
+		strings.EqualFold(keyword, "REINDEX"):
+		return queryClassMaintenance
+	case strings.EqualFold(keyword, "PRAGMA"):
+		pragma := strings.ToLower(strings.TrimSpace(query[end:]))
+		if dot := strings.IndexByte(pragma, '.'); dot >= 0 && !strings.ContainsAny(pragma[:dot], " (=") {
+			pragma = pragma[dot+1:] // Schema-qualified, such as "main.integrity_check"
+		}
+		for _, name := range maintenancePragmas {
+			if strings.HasPrefix(pragma, name) {
+				return queryClassMaintenance
+			}
+		}
+		return queryClassRead
+	}
+	return queryClassWrite
+}
+
+// queryBudget stops a query once it has spent its timeout in SQLite. The
+// timer runs only while SQLite does: while the statement starts and while
+// each row is stepped to, but not while the caller processes a row.
+type queryBudget struct {
+	class   string
+	limit   time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	expired atomic.Bool
+}
+
+// expire cancels the query's context, which interrupts SQLite
+func (b *queryBudget) expire() {
+	b.expired.Store(true)
+	b.cancel()
+}
+
+// pause stops the timer while the caller works
+func (b *queryBudget) pause() {
+	if b != nil {
+		b.timer.Stop()
+	}
+}
+
+// resume restarts the timer for what is left of the timeout after used
+func (b *queryBudget) resume(used time.Duration) {
+	if b != nil && !b.expired.Load() {
+		b.timer.Reset(max(b.limit-used, 0))
+	}
+}
+
+// check returns a timeout error in place of err if the budget ran out
+func (b *queryBudget) check(err error) error {
+	if b != nil && err != nil && b.expired.Load() {
+		return &QueryTimeoutError{Class: b.class, Timeout: b.limit}
+	}
+	return err
+}
+
+// end releases the budget once its query is done, returning err as check
+// does
+func (b *queryBudget) end(err error) error {
+	if b == nil {
+		return err
+	}
+	b.timer.Stop()
+	err = b.check(err)
+	b.cancel()
+	return err
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"sales-track/internal/models"
+)
+
+// aggregateSums is the select list of the sums kept in both aggregate
+// tables, calculated from sales_records
+const aggregateSums = `
+	COUNT(*) - SUM(is_return),
+	SUM(is_return),
+	SUM(CASE WHEN is_return = 1 THEN -sale_price ELSE sale_price END),
+	COALESCE(SUM(CASE WHEN is_return = 0 THEN sale_price END), 0),
+	COALESCE(SUM(CASE WHEN is_return = 1 THEN sale_price END), 0),
+	SUM(CASE WHEN is_return = 1 THEN -commission ELSE commission END),
+	SUM(CASE WHEN is_return = 1 THEN -remaining ELSE remaining END),
+	COUNT(commission),
+	SUM(CASE WHEN commission IS NULL THEN NULL WHEN is_return = 1 THEN -sale_price ELSE sale_price END)`
+
+// aggregateTotals is the select list of the totals calculated from the sums
+// of either aggregate table, in the column order of the summary views from
+// items_sold to commission_known_items
+const aggregateTotals = `
+	SUM(items_sold) as items_sold,
+	SUM(total_sales) as total_sales,
+	COALESCE(SUM(commission), 0) as total_commission,
+	COALESCE(SUM(remaining), 0) as total_remaining,
+	COALESCE(SUM(commission) * 1.0 / NULLIF(SUM(commission_sales), 0), 0) as commission_rate,
+	SUM(commission_known_items) as commission_known_items`
+
+// aggregateSplit is the select list of the returns columns ending every
+// summary view
+const aggregateSplit = `
+	SUM(returned_items) as returned_items,
+	SUM(gross_sales) as gross_sales,
+	SUM(total_returns) as total_returns`
+
+// aggregatePerformance is the select list of the performance summaries after
+// the store or vendor, in the column order of v_store_performance, with other
+// counting the distinct vendors of a store or stores of a vendor
+func aggregatePerformance(other string) string {
+	return `
+	SUM(items_sold) as total_items,
+	SUM(total_sales) as total_sales,
+	COALESCE(SUM(commission), 0) as total_commission,
+	COALESCE(SUM(remaining), 0) as total_remaining,
+	COALESCE(SUM(gross_sales) * 1.0 / NULLIF(SUM(items_sold), 0), 0) as avg_sale_price,
+	COALESCE(SUM(commission) * 1.0 / NULLIF(SUM(commission_sales), 0), 0) as commission_rate,
+	SUM(commission_known_items) as commission_known_items,
+	MIN(first_sale_date) as first_sale_date,
+	MAX(last_sale_date) as last_sale_date,
+	COUNT(DISTINCT ` + other + `) as unique_` + other + `s,` + aggregateSplit
+}
+
+// aggregateViews calculates each summary view from the aggregate tables
+// with the same columns, so the queries reading a view can read these instead
+var aggregateViews = map[string]string{
+	"v_yearly_sales_summary": `SELECT substr(year_month, 1, 4) as year,` + aggregateTotals + `,
+		COUNT(DISTINCT store) as unique_stores, COUNT(DISTINCT vendor) as unique_vendors,` + aggregateSplit + `
+		FROM report_monthly_aggregates GROUP BY substr(year_month, 1, 4)`,
+	"v_monthly_sales_summary": `SELECT substr(year_month, 1, 4) as year, substr(year_month, 6, 2) as month, year_month,` + aggregateTotals + `,
+		COUNT(DISTINCT store) as unique_stores, COUNT(DISTINCT vendor) as unique_vendors,` + aggregateSplit + `
+		FROM report_monthly_aggregates GROUP BY year_month`,
+	"v_daily_sales_summary": `SELECT date, strftime('%Y', date) as year, strftime('%m', date) as month, strftime('%d', date) as day, strftime('%Y-%m', date) as year_month,` + aggregateTotals + `,
+		SUM(unique_stores) as unique_stores, SUM(unique_vendors) as unique_vendors,` + aggregateSplit + `
+		FROM report_daily_aggregates GROUP BY date`,
+	"v_store_performance": `SELECT store,` + aggregatePerformance("vendor") + `
+		FROM report_monthly_aggregates GROUP BY store`,
+	"v_vendor_performance": `SELECT vendor,` + aggregatePerformance("store") + `
+		FROM report_monthly_aggregates GROUP BY vendor`,
+}
+
+// Aggregated returns a copy of the repository whose yearly, monthly, daily
+// and performance summaries are calculated from the aggregate tables instead
+// of sales_records. The tables must have been rebuilt since records last
+// changed.
+func (r *ReportingRepository) Aggregated() *ReportingRepository {
+	return &ReportingRepository{db: r.db, q: r.q, aggregated: true}
+}
+
+// from returns what a summary query reads for view: the view itself, or the
+// same columns calculated from the aggregate tables
+func (r *ReportingRepository) from(view string) string {
+	if !r.aggregated {
+		return view
+	}
+	return "(" + aggregateViews[view] + ") AS " + view
+}
+
+// RebuildAggregates replaces the contents of report_monthly_aggregates and
+// report_daily_aggregates with the sums of the current sales records
+func (r *ReportingRepository) RebuildAggregates() error {
+	return r.db.ExecTx(func(tx *sql.Tx) error {
+		for _, table := range []string{"report_monthly_aggregates", "report_daily_aggregates"} {
+			if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+				return fmt.Errorf("failed to clear %s: %w", table, err)
+			}
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO report_monthly_aggregates (
+				year_month, store, vendor, items_sold, returned_items, total_sales, gross_sales, total_returns,
+				commission, remaining, commission_known_items, commission_sales, first_sale_date, last_sale_date
+			)
+			SELECT strftime('%Y-%m', date), store, vendor,` + aggregateSums + `, MIN(date), MAX(date)
+			FROM sales_records
+			GROUP BY strftime('%Y-%m', date), store, vendor`)
+		if err != nil {
+			return fmt.Errorf("failed to build monthly report aggregates: %w", err)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO report_daily_aggregates (
+				date, items_sold, returned_items, total_sales, gross_sales, total_returns,
+				commission, remaining, commission_known_items, commission_sales, unique_stores, unique_vendors
+			)
+			SELECT date,` + aggregateSums + `, COUNT(DISTINCT store), COUNT(DISTINCT vendor)
+			FROM sales_records
+			GROUP BY date`)
+		if err != nil {
+			return fmt.Errorf("failed to build daily report aggregates: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetTopProductsSampled estimates the best-selling products of total records
+// from about sampleSize of them, picked at even steps of their IDs so the
+// sample spreads over all imports and is read by ID instead of scanning the
+// table. Counts and totals are scaled up from the sample; variants are the
+// descriptions seen in it.
+func (r *ReportingRepository) GetTopProductsSampled(limit int, sampleSize, total int64) ([]models.ProductSummary, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	if sampleSize < 1 {
+		sampleSize = 1
+	}
+
+	query := `
+		WITH RECURSIVE
+			bounds AS (
+				SELECT MIN(id) AS first, MAX(id) AS last, MAX((MAX(id) - MIN(id) + 1) / ?, 1) AS step
+				FROM sales_records
+			),
+			sampled(id) AS (
+				SELECT first FROM bounds WHERE first IS NOT NULL
+				UNION ALL
+				SELECT sampled.id + bounds.step FROM sampled, bounds WHERE sampled.id + bounds.step <= bounds.last
+			),
+			sample AS (
+				SELECT s.product_key, s.description, s.is_return, s.sale_price
+				FROM sampled JOIN sales_records s ON s.id = sampled.id
+			),
+			scale AS (
+				SELECT ? * 1.0 / COUNT(*) AS factor FROM sample
+			)
+		SELECT
+			product_key,
+			MIN(description) as description,
+			COUNT(DISTINCT description) as variants,
+			CAST(ROUND((COUNT(*) - SUM(is_return)) * factor) AS INTEGER) as items_sold,
+			CAST(ROUND(SUM(is_return) * factor) AS INTEGER) as returned_items,
+			SUM(CASE WHEN is_return = 1 THEN -sale_price ELSE sale_price END) * factor as total_sales,
+			COALESCE(AVG(CASE WHEN is_return = 0 THEN sale_price END), 0) as avg_sale_price
+		FROM sample, scale
+		WHERE product_key != ''
+		GROUP BY product_key
+		ORDER BY total_sales DESC, items_sold DESC
+		LIMIT ?
+	`
+
+	rows, err := r.q.Query(query, sampleSize, total, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sampled top products: %w", err)
+	}
+	defer rows.Close()
+
+	var products []models.ProductSummary
+	for rows.Next() {
+		product := models.ProductSummary{Estimated: true}
+		err := rows.Scan(
+			&product.ProductKey,
+			&product.Description,
+			&product.Variants,
+			&product.ItemsSold,
+			&product.ReturnedItems,
+			&product.TotalSales,
+			&product.AvgSalePrice,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan sampled top product: %w", err)
+		}
+		products = append(products, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sampled top products: %w", err)
+	}
+
+	return products, nil
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// reportAggregates tracks whether the aggregate tables hold the current sales
+// records. It is shared by a service and the copies made from it.
+type reportAggregates struct {
+	mu         sync.Mutex
+	built      bool
+	generation uint64 // Query cache generation the tables were built in
+	builtAt    time.Time
+}
+
+// current reports whether the tables were built in the given query cache
+// generation
+func (a *reportAggregates) current(generation uint64) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.built && a.generation == generation
+}
+
+// GetReportPerformanceConfig returns the saved report performance settings,
+// or the defaults if none have been saved
+func (s *Service) GetReportPerformanceConfig() (models.ReportPerformanceConfig, error) {
+	config := models.DefaultReportPerformanceConfig()
+	if _, err := s.settingsRepo.Get(settingReportPerformance, &config); err != nil {
+		return config, err
+	}
+	return config, nil
+}
+
+// SaveReportPerformanceConfig validates and stores the report performance
+// settings and records the change in the audit log
+func (s *Service) SaveReportPerformanceConfig(config models.ReportPerformanceConfig) error {
+	if config.Threshold < models.MinPerformanceThreshold {
+		return invalidf("threshold must be at least %d records", models.MinPerformanceThreshold)
+	}
+	if config.SampleSize < models.MinPerformanceSampleSize {
+		return invalidf("sample size must be at least %d records", models.MinPerformanceSampleSize)
+	}
+
+	return s.ExecTx(func(tx *Service) error {
+		if err := tx.settingsRepo.Set(settingReportPerformance, config); err != nil {
+			return err
+		}
+
+		details := "Report performance mode: off"
+		if config.Enabled {
+			details = fmt.Sprintf("Report performance mode: above %d records, estimating from %d", config.Threshold, config.SampleSize)
+		}
+		entityType := "setting"
+		_, err := tx.auditRepo.Create(models.AuditEntry{
+			Action:     models.AuditSettingsUpdated,
+			EntityType: &entityType,
+			Details:    details,
+		})
+		return err
+	})
+}
+
+// GetReportPerformanceStatus tells whether the heavy reports are in
+// performance mode, for the record count now, and when the pre-aggregated
+// sales they read were built
+func (s *Service) GetReportPerformanceStatus() (*models.ReportPerformanceStatus, error) {
+	config, err := s.GetReportPerformanceConfig()
+	if err != nil {
+		return nil, err
+	}
+	value, err := s.cached("sales_record_count", func() (interface{}, error) {
+		return s.salesRepo.Count(models.SalesRecordFilter{})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.ReportPerformanceStatus{
+		Config:      config,
+		RecordCount: value.(int64),
+		// Services bound to a transaction read sales_records directly, since
+		// the aggregate tables do not hold their uncommitted writes
+		Active: s.tx == nil && config.Enabled && value.(int64) > config.Threshold,
+	}
+
+	s.aggregates.mu.Lock()
+	if s.aggregates.built && s.aggregates.generation == s.cache.currentGeneration() {
+		builtAt := s.aggregates.builtAt
+		status.AggregatesBuiltAt = &builtAt
+	}
+	s.aggregates.mu.Unlock()
+
+	return status, nil
+}
+
+// heavyReports returns the repository the pivot and performance reports
+// read: in performance mode one reading the aggregate tables, rebuilt first if
+// records changed since it was built. While another write, a backup or a
+// compaction holds the database the reports read sales_records instead of
+// waiting for it, and the rebuild is left to RefreshReports.
+func (s *Service) heavyReports() (*ReportingRepository, error) {
+	status, err := s.GetReportPerformanceStatus()
+	if err != nil || !status.Active {
+		return s.reportingRepo, err
+	}
+
+	if err := s.refreshReportAggregates(s.tryBeginWrite); err != nil {
+		if errors.Is(err, ErrWriteBusy) || errors.Is(err, ErrMaintenanceInProgress) {
+			return s.reportingRepo, nil
+		}
+		return nil, err
+	}
+	return s.reportingRepo.Aggregated(), nil
+}
+
+// refreshReportAggregates rebuilds the aggregate tables unless they were built
+// since the records last changed, taking a turn in the write queue with
+// begin. Writes bump the query cache generation before they release the
+// write queue, so the generation read while holding the queue matches the
+// records the rebuild reads. The queue also keeps rebuilds from overlapping.
+func (s *Service) refreshReportAggregates(begin func() (func(), error)) error {
+	if s.aggregates.current(s.cache.currentGeneration()) {
+		return nil
+	}
+
+	release, err := begin()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	generation := s.cache.currentGeneration()
+	if s.aggregates.current(generation) {
+		return nil // Rebuilt while this one waited for its turn
+	}
+	if err := s.reportingRepo.RebuildAggregates(); err != nil {
+		return err
+	}
+
+	s.aggregates.mu.Lock()
+	defer s.aggregates.mu.Unlock()
+	s.aggregates.built = true
+	s.aggregates.generation = generation
+	s.aggregates.builtAt = time.Now()
+	return nil
+}
@@ -0,0 +1,158 @@
+package database
+
+import "fmt"
+
+// ReportViewColumn is a column of a reporting view and what it holds
+type ReportViewColumn struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ReportView is a v_* reporting view as defined in the database, for users
+// checking how the summaries are calculated or reproducing them elsewhere
+type ReportView struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	SQL         string             `json:"sql"` // The CREATE VIEW statement stored by SQLite
+	Columns     []ReportViewColumn `json:"columns"`
+}
+
+// ReportMethodology holds the reporting views and the rules every summary
+// follows, whether it reads a view or is calculated in Go
+type ReportMethodology struct {
+	Views []ReportView `json:"views"`
+	Rules []string     `json:"rules"`
+}
+
+// reportMethodologyRules are the rules shared by all sales summaries
+var reportMethodologyRules = []string{
+	"Returns are stored with positive amounts and is_return = 1, and are subtracted from every total: total_sales, total_commission and total_remaining are net of returns.",
+	"items_sold and total_items count sales only; returns are counted in returned_items. gross_sales and total_returns show the two sides of total_sales.",
+	"Commission and remaining amounts a store did not report are stored as NULL and count as zero in totals. commission_rate divides the net commission by the net sales of the records whose commission is known, so unreported commission does not lower it; commission_known_items counts those records.",
+	"unique_stores and unique_vendors count distinct names exactly as stored, returns included. Names are kept as imported after aliases are applied, so names differing only in case count separately.",
+	"Records are bucketed by their sale date. Reports by import date, converted into a base currency or with adjustments folded in are calculated in Go from sales_records and follow the same rules.",
+	"Deleted records, kept in the trash, and records archived by the retention policy are no longer in sales_records and are not counted.",
+}
+
+// reportViewDescriptions says what each reporting view summarizes
+var reportViewDescriptions = map[string]string{
+	"v_yearly_sales_summary":    "Sales totals per year of the sale date",
+	"v_monthly_sales_summary":   "Sales totals per month of the sale date",
+	"v_daily_sales_summary":     "Sales totals per sale date",
+	"v_store_performance":       "Sales totals per store over all dates",
+	"v_vendor_performance":      "Sales totals per vendor over all dates",
+	"v_import_activity_monthly": "Import runs per month they ran in",
+}
+
+// reportColumnDescriptions says what each column of the reporting views
+// holds. Keys of the form view.column override the description of a column
+// in one view.
+var reportColumnDescriptions = map[string]string{
+	"year":                   "Year of the sale date, YYYY",
+	"month":                  "Month of the sale date, MM",
+	"day":                    "Day of the sale date, DD",
+	"date":                   "Sale date",
+	"year_month":             "Month of the sale date, YYYY-MM",
+	"store":                  "Store name",
+	"vendor":                 "Vendor name",
+	"items_sold":             "Records that are not returns",
+	"total_items":            "Records that are not returns",
+	"returned_items":         "Records that are returns",
+	"total_sales":            "Sale prices less returned sale prices",
+	"gross_sales":            "Sale prices of records that are not returns",
+	"total_returns":          "Sale prices of returns",
+	"total_commission":       "Commission less returned commission; unreported commission counts as zero",
+	"total_remaining":        "Remaining amounts less returned remaining amounts; unreported amounts count as zero",
+	"commission_rate":        "Net commission divided by the net sales of records with a known commission, 0 when there are none",
+	"commission_known_items": "Records with a reported commission, returns included",
+	"avg_sale_price":         "Average sale price of records that are not returns",
+	"first_sale_date":        "Earliest sale date, returns included",
+	"last_sale_date":         "Latest sale date, returns included",
+	"unique_stores":          "Distinct store names, returns included",
+	"unique_vendors":         "Distinct vendor names, returns included",
+
+	"v_import_activity_monthly.month": "Month the imports ran in, YYYY-MM",
+	"imports":                         "Import runs",
+	"failed_imports":                  "Import runs that did not succeed",
+	"total_rows":                      "Rows read from the imported data",
+	"imported_rows":                   "Rows added as new records",
+	"updated_rows":                    "Rows that updated existing records",
+	"error_rows":                      "Rows that could not be imported",
+	"error_rate":                      "error_rows divided by total_rows, 0 when no rows were read",
+}
+
+// reportColumnDescription returns the description of column in view
+func reportColumnDescription(view, column string) string {
+	if description, ok := reportColumnDescriptions[view+"."+column]; ok {
+		return description
+	}
+	return reportColumnDescriptions[column]
+}
+
+// ReportViews returns the v_* views as SQLite stores them, ordered by name,
+// with their columns described
+func (db *DB) ReportViews() ([]ReportView, error) {
+	rows, err := db.conn.Query(`SELECT name, sql FROM sqlite_master WHERE type = 'view' AND name LIKE 'v\_%' ESCAPE '\' ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query report views: %w", err)
+	}
+	defer rows.Close()
+
+	var views []ReportView
+	for rows.Next() {
+		var view ReportView
+		if err := rows.Scan(&view.Name, &view.SQL); err != nil {
+			return nil, fmt.Errorf("failed to scan report view: %w", err)
+		}
+		view.Description = reportViewDescriptions[view.Name]
+		views = append(views, view)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating report views: %w", err)
+	}
+
+	for i := range views {
+		if views[i].Columns, err = db.viewColumns(views[i].Name); err != nil {
+			return nil, err
+		}
+	}
+
+	return views, nil
+}
+
+// viewColumns returns the columns of view in order, with their descriptions
+func (db *DB) viewColumns(view string) ([]ReportViewColumn, error) {
+	rows, err := db.conn.Query("SELECT name FROM pragma_table_info(?) ORDER BY cid", view)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns of %s: %w", view, err)
+	}
+	defer rows.Close()
+
+	var columns []ReportViewColumn
+	for rows.Next() {
+		var column ReportViewColumn
+		if err := rows.Scan(&column.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan column of %s: %w", view, err)
+		}
+		column.Description = reportColumnDescription(view, column.Name)
+		columns = append(columns, column)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating columns of %s: %w", view, err)
+	}
+
+	return columns, nil
+}
+
+// GetReportMethodology returns the definitions of the reporting views and the
+// rules the summaries follow, such as what counts as an item sold, so the
+// figures can be checked or reproduced outside the app
+func (s *Service) GetReportMethodology() (*ReportMethodology, error) {
+	views, err := s.db.ReportViews()
+	if err != nil {
+		return nil, err
+	}
+	return &ReportMethodology{Views: views, Rules: reportMethodologyRules}, nil
+}
@@ -1,7 +1,10 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"sales-track/internal/models"
@@ -9,12 +12,19 @@ import (
 
 // ReportingRepository handles database operations for reporting and analytics
 type ReportingRepository struct {
-	db *DB
+	db         *DB
+	q          queryer
+	aggregated bool // Summaries read the aggregate tables; see Aggregated
 }
 
 // NewReportingRepository creates a new reporting repository
 func NewReportingRepository(db *DB) *ReportingRepository {
-	return &ReportingRepository{db: db}
+	return &ReportingRepository{db: db, q: db.conn}
+}
+
+// WithTx returns a copy of the repository whose queries run inside tx
+func (r *ReportingRepository) WithTx(tx *sql.Tx) *ReportingRepository {
+	return &ReportingRepository{db: r.db, q: tx}
 }
 
 // GetYearlySummary returns yearly sales summary data
@@ -26,18 +36,28 @@ func (r *ReportingRepository) GetYearlySummary() ([]models.YearlySummary, error)
 			total_sales,
 			total_commission,
 			total_remaining,
+			commission_rate,
+			commission_known_items,
 			unique_stores,
-			unique_vendors
-		FROM v_yearly_sales_summary
+			unique_vendors,
+			returned_items,
+			gross_sales,
+			total_returns
+		FROM ` + r.from("v_yearly_sales_summary") + `
 		ORDER BY year DESC
 	`
 
-	rows, err := r.db.conn.Query(query)
+	rows, err := r.q.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query yearly summary: %w", err)
 	}
 	defer rows.Close()
 
+	return scanYearlySummaries(rows)
+}
+
+// scanYearlySummaries scans rows selected in the column order of v_yearly_sales_summary
+func scanYearlySummaries(rows *sql.Rows) ([]models.YearlySummary, error) {
 	var summaries []models.YearlySummary
 	for rows.Next() {
 		var summary models.YearlySummary
@@ -47,8 +67,13 @@ func (r *ReportingRepository) GetYearlySummary() ([]models.YearlySummary, error)
 			&summary.TotalSales,
 			&summary.TotalCommission,
 			&summary.TotalRemaining,
+			&summary.CommissionRate,
+			&summary.CommissionKnown,
 			&summary.UniqueStores,
 			&summary.UniqueVendors,
+			&summary.ReturnedItems,
+			&summary.GrossSales,
+			&summary.TotalReturns,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan yearly summary: %w", err)
@@ -74,9 +99,14 @@ func (r *ReportingRepository) GetMonthlySummary(year *string) ([]models.MonthlyS
 			total_sales,
 			total_commission,
 			total_remaining,
+			commission_rate,
+			commission_known_items,
 			unique_stores,
-			unique_vendors
-		FROM v_monthly_sales_summary
+			unique_vendors,
+			returned_items,
+			gross_sales,
+			total_returns
+		FROM ` + r.from("v_monthly_sales_summary") + `
 	`
 
 	args := []interface{}{}
@@ -87,26 +117,39 @@ func (r *ReportingRepository) GetMonthlySummary(year *string) ([]models.MonthlyS
 
 	query += " ORDER BY year DESC, month DESC"
 
-	rows, err := r.db.conn.Query(query, args...)
+	rows, err := r.q.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query monthly summary: %w", err)
 	}
 	defer rows.Close()
 
+	return scanMonthlySummaries(rows, false)
+}
+
+// scanMonthlySummaries scans rows selected in the column order of
+// v_monthly_sales_summary, with the dimension after year_month when
+// withDimension is set
+func scanMonthlySummaries(rows *sql.Rows, withDimension bool) ([]models.MonthlySummary, error) {
 	var summaries []models.MonthlySummary
 	for rows.Next() {
 		var summary models.MonthlySummary
-		err := rows.Scan(
-			&summary.Year,
-			&summary.Month,
-			&summary.YearMonth,
+		dest := []interface{}{&summary.Year, &summary.Month, &summary.YearMonth}
+		if withDimension {
+			dest = append(dest, &summary.Dimension)
+		}
+		err := rows.Scan(append(dest,
 			&summary.ItemsSold,
 			&summary.TotalSales,
 			&summary.TotalCommission,
 			&summary.TotalRemaining,
+			&summary.CommissionRate,
+			&summary.CommissionKnown,
 			&summary.UniqueStores,
 			&summary.UniqueVendors,
-		)
+			&summary.ReturnedItems,
+			&summary.GrossSales,
+			&summary.TotalReturns,
+		)...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan monthly summary: %w", err)
 		}
@@ -133,9 +176,14 @@ func (r *ReportingRepository) GetDailySummary(year *string, month *string) ([]mo
 			total_sales,
 			total_commission,
 			total_remaining,
+			commission_rate,
+			commission_known_items,
 			unique_stores,
-			unique_vendors
-		FROM v_daily_sales_summary
+			unique_vendors,
+			returned_items,
+			gross_sales,
+			total_returns
+		FROM ` + r.from("v_daily_sales_summary") + `
 	`
 
 	args := []interface{}{}
@@ -159,7 +207,7 @@ func (r *ReportingRepository) GetDailySummary(year *string, month *string) ([]mo
 
 	query += " ORDER BY date DESC"
 
-	rows, err := r.db.conn.Query(query, args...)
+	rows, err := r.q.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query daily summary: %w", err)
 	}
@@ -178,8 +226,13 @@ func (r *ReportingRepository) GetDailySummary(year *string, month *string) ([]mo
 			&summary.TotalSales,
 			&summary.TotalCommission,
 			&summary.TotalRemaining,
+			&summary.CommissionRate,
+			&summary.CommissionKnown,
 			&summary.UniqueStores,
 			&summary.UniqueVendors,
+			&summary.ReturnedItems,
+			&summary.GrossSales,
+			&summary.TotalReturns,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan daily summary: %w", err)
@@ -204,14 +257,19 @@ func (r *ReportingRepository) GetStorePerformance() ([]models.StorePerformance,
 			total_commission,
 			total_remaining,
 			avg_sale_price,
+			commission_rate,
+			commission_known_items,
 			first_sale_date,
 			last_sale_date,
-			unique_vendors
-		FROM v_store_performance
+			unique_vendors,
+			returned_items,
+			gross_sales,
+			total_returns
+		FROM ` + r.from("v_store_performance") + `
 		ORDER BY total_sales DESC
 	`
 
-	rows, err := r.db.conn.Query(query)
+	rows, err := r.q.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query store performance: %w", err)
 	}
@@ -229,19 +287,24 @@ func (r *ReportingRepository) GetStorePerformance() ([]models.StorePerformance,
 			&performance.TotalCommission,
 			&performance.TotalRemaining,
 			&performance.AvgSalePrice,
+			&performance.CommissionRate,
+			&performance.CommissionKnown,
 			&firstSaleDateStr,
 			&lastSaleDateStr,
 			&performance.UniqueVendors,
+			&performance.ReturnedItems,
+			&performance.GrossSales,
+			&performance.TotalReturns,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan store performance: %w", err)
 		}
 		
 		// Parse date strings
-		if parsed, err := time.Parse("2006-01-02", firstSaleDateStr); err == nil {
+		if parsed, err := models.ParseDate(firstSaleDateStr); err == nil {
 			performance.FirstSaleDate = parsed
 		}
-		if parsed, err := time.Parse("2006-01-02", lastSaleDateStr); err == nil {
+		if parsed, err := models.ParseDate(lastSaleDateStr); err == nil {
 			performance.LastSaleDate = parsed
 		}
 		
@@ -265,14 +328,19 @@ func (r *ReportingRepository) GetVendorPerformance() ([]models.VendorPerformance
 			total_commission,
 			total_remaining,
 			avg_sale_price,
+			commission_rate,
+			commission_known_items,
 			first_sale_date,
 			last_sale_date,
-			unique_stores
-		FROM v_vendor_performance
+			unique_stores,
+			returned_items,
+			gross_sales,
+			total_returns
+		FROM ` + r.from("v_vendor_performance") + `
 		ORDER BY total_sales DESC
 	`
 
-	rows, err := r.db.conn.Query(query)
+	rows, err := r.q.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query vendor performance: %w", err)
 	}
@@ -290,19 +358,24 @@ func (r *ReportingRepository) GetVendorPerformance() ([]models.VendorPerformance
 			&performance.TotalCommission,
 			&performance.TotalRemaining,
 			&performance.AvgSalePrice,
+			&performance.CommissionRate,
+			&performance.CommissionKnown,
 			&firstSaleDateStr,
 			&lastSaleDateStr,
 			&performance.UniqueStores,
+			&performance.ReturnedItems,
+			&performance.GrossSales,
+			&performance.TotalReturns,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan vendor performance: %w", err)
 		}
 		
 		// Parse date strings
-		if parsed, err := time.Parse("2006-01-02", firstSaleDateStr); err == nil {
+		if parsed, err := models.ParseDate(firstSaleDateStr); err == nil {
 			performance.FirstSaleDate = parsed
 		}
-		if parsed, err := time.Parse("2006-01-02", lastSaleDateStr); err == nil {
+		if parsed, err := models.ParseDate(lastSaleDateStr); err == nil {
 			performance.LastSaleDate = parsed
 		}
 		
@@ -366,7 +439,7 @@ type PivotTableData struct {
 // GetDrillDownData returns detailed records for a specific time period
 func (r *ReportingRepository) GetDrillDownData(year string, month *string, day *string) ([]models.SalesRecord, error) {
 	query := `
-		SELECT id, store, vendor, date, description, sale_price, commission, remaining, created_at, updated_at
+		SELECT ` + salesRecordColumns + `
 		FROM sales_records
 		WHERE strftime('%Y', date) = ?
 	`
@@ -384,7 +457,7 @@ func (r *ReportingRepository) GetDrillDownData(year string, month *string, day *
 
 	query += " ORDER BY date DESC, id DESC"
 
-	rows, err := r.db.conn.Query(query, args...)
+	rows, err := r.q.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query drill-down data: %w", err)
 	}
@@ -393,19 +466,7 @@ func (r *ReportingRepository) GetDrillDownData(year string, month *string, day *
 	var records []models.SalesRecord
 	for rows.Next() {
 		var record models.SalesRecord
-		err := rows.Scan(
-			&record.ID,
-			&record.Store,
-			&record.Vendor,
-			&record.Date,
-			&record.Description,
-			&record.SalePrice,
-			&record.Commission,
-			&record.Remaining,
-			&record.CreatedAt,
-			&record.UpdatedAt,
-		)
-		if err != nil {
+		if err := scanSalesRecord(rows, &record); err != nil {
 			return nil, fmt.Errorf("failed to scan sales record: %w", err)
 		}
 		records = append(records, record)
@@ -418,60 +479,259 @@ func (r *ReportingRepository) GetDrillDownData(year string, month *string, day *
 	return records, nil
 }
 
-// GetCustomSummary returns custom aggregated data based on grouping criteria
-func (r *ReportingRepository) GetCustomSummary(groupBy string, year *string, store *string, vendor *string) ([]models.SalesSummary, error) {
+// drillDownColumnExprs are the SQL expressions selecting each of
+// models.DrillDownColumns
+var drillDownColumnExprs = map[string]string{
+	"id":          "id",
+	"date":        "strftime('%Y-%m-%d', date)",
+	"store":       "store",
+	"vendor":      "vendor",
+	"description": "description",
+	"sale_price":  "CAST(sale_price AS REAL)", // Whole amounts are stored as integers
+	"commission":  "CAST(commission AS REAL)",
+	"remaining":   "CAST(remaining AS REAL)",
+	"is_return":   "is_return",
+	"currency":    "currency",
+	"category":    "category",
+}
+
+// drillDownSortExprs are the ORDER BY expressions for each of
+// models.DrillDownSortFields. Names sort ignoring case.
+var drillDownSortExprs = map[string]string{
+	"date":        "date",
+	"sale_price":  "sale_price",
+	"description": "description COLLATE NOCASE",
+	"vendor":      "vendor COLLATE NOCASE",
+	"store":       "store COLLATE NOCASE",
+}
+
+// GetDrillDownTable returns the columns of req for the records of a year,
+// month or day, in the requested order. Records tied on the sort field are
+// newest first. The request must have been validated.
+func (r *ReportingRepository) GetDrillDownTable(req models.DrillDownRequest) (*models.DrillDownTable, error) {
+	exprs := make([]string, len(req.Columns))
+	for i, column := range req.Columns {
+		exprs[i] = drillDownColumnExprs[column]
+	}
+
+	query := "SELECT " + strings.Join(exprs, ", ") + " FROM sales_records WHERE strftime('%Y', date) = ?"
+	args := []interface{}{req.Year}
+	if req.Month != nil {
+		query += " AND strftime('%m', date) = ?"
+		args = append(args, *req.Month)
+	}
+	if req.Day != nil {
+		query += " AND strftime('%d', date) = ?"
+		args = append(args, *req.Day)
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, date DESC, id DESC", drillDownSortExprs[req.SortBy], strings.ToUpper(req.SortOrder))
+
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query drill-down data: %w", err)
+	}
+	defer rows.Close()
+
+	table := &models.DrillDownTable{Columns: req.Columns, Rows: [][]interface{}{}}
+	for rows.Next() {
+		values := make([]interface{}, len(req.Columns))
+		dest := make([]interface{}, len(values))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan drill-down row: %w", err)
+		}
+		for i, column := range req.Columns {
+			if flag, ok := values[i].(int64); ok && column == "is_return" {
+				values[i] = flag != 0
+			}
+		}
+		table.Rows = append(table.Rows, values)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating drill-down records: %w", err)
+	}
+
+	return table, nil
+}
+
+// GetVendorVelocity returns, for each vendor with sales on or before asOf,
+// the items sold on or after windowStart and the dates of its first and last
+// sales. A zero windowStart counts every sale. The velocity itself is left to
+// VendorVelocity.Calculate.
+func (r *ReportingRepository) GetVendorVelocity(store *string, windowStart, asOf time.Time) ([]models.VendorVelocity, error) {
+	query := `
+		SELECT
+			vendor,
+			SUM(CASE WHEN date >= COALESCE(?, date) THEN 1 ELSE 0 END) as items_sold,
+			date(MIN(date)) as first_sale_date,
+			date(MAX(date)) as last_sale_date
+		FROM sales_records
+		WHERE is_return = 0 AND date <= ?`
+	args := []interface{}{models.NewDate(windowStart), models.NewDate(asOf)}
+	if store != nil {
+		query += " AND store = ?"
+		args = append(args, *store)
+	}
+	query += " GROUP BY vendor"
+
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vendor velocity: %w", err)
+	}
+	defer rows.Close()
+
+	vendors := []models.VendorVelocity{}
+	for rows.Next() {
+		var vendor models.VendorVelocity
+		var firstSaleDateStr, lastSaleDateStr string
+		if err := rows.Scan(&vendor.Vendor, &vendor.ItemsSold, &firstSaleDateStr, &lastSaleDateStr); err != nil {
+			return nil, fmt.Errorf("failed to scan vendor velocity: %w", err)
+		}
+		if vendor.FirstSaleDate, err = models.ParseDate(firstSaleDateStr); err != nil {
+			return nil, fmt.Errorf("failed to parse first sale date: %w", err)
+		}
+		if vendor.LastSaleDate, err = models.ParseDate(lastSaleDateStr); err != nil {
+			return nil, fmt.Errorf("failed to parse last sale date: %w", err)
+		}
+		vendors = append(vendors, vendor)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating vendor velocity: %w", err)
+	}
+
+	return vendors, nil
+}
+
+// GetVendorCohorts returns, for each month since the month of each vendor's
+// first record, the vendors of that cohort with records in the month and
+// their revenue net of returns, ordered by cohort and month
+func (r *ReportingRepository) GetVendorCohorts(store *string) ([]models.CohortCell, error) {
+	where := ""
+	var args []interface{}
+	if store != nil {
+		where = " WHERE store = ?"
+		args = append(args, *store, *store)
+	}
+
+	query := `
+		WITH firsts AS (
+			SELECT vendor, strftime('%Y-%m', MIN(date)) as cohort
+			FROM sales_records` + where + `
+			GROUP BY vendor
+		)
+		SELECT
+			f.cohort,
+			(CAST(strftime('%Y', s.date) AS INTEGER) - CAST(substr(f.cohort, 1, 4) AS INTEGER)) * 12
+				+ CAST(strftime('%m', s.date) AS INTEGER) - CAST(substr(f.cohort, 6, 2) AS INTEGER) as month_offset,
+			COUNT(DISTINCT s.vendor) as vendors,
+			CAST(SUM(CASE WHEN s.is_return = 1 THEN -s.sale_price ELSE s.sale_price END) AS REAL) as revenue
+		FROM sales_records s
+		JOIN firsts f ON f.vendor = s.vendor` + strings.Replace(where, "store", "s.store", 1) + `
+		GROUP BY f.cohort, month_offset
+		ORDER BY f.cohort, month_offset`
+
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vendor cohorts: %w", err)
+	}
+	defer rows.Close()
+
+	var cells []models.CohortCell
+	for rows.Next() {
+		var cell models.CohortCell
+		if err := rows.Scan(&cell.Cohort, &cell.Offset, &cell.Vendors, &cell.Revenue); err != nil {
+			return nil, fmt.Errorf("failed to scan vendor cohort: %w", err)
+		}
+		cells = append(cells, cell)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating vendor cohorts: %w", err)
+	}
+
+	return cells, nil
+}
+
+// GetCustomSummaryWithFilter returns aggregated data for the records matching
+// the request's filter, grouped by its criterion and limited to the groups
+// reaching its thresholds. Weeks start on weekStart.
+func (r *ReportingRepository) GetCustomSummaryWithFilter(req models.CustomSummaryRequest, weekStart time.Weekday) ([]models.SalesSummary, error) {
 	// Validate groupBy parameter
 	validGroupBy := map[string]string{
 		"year":   "strftime('%Y', date)",
 		"month":  "strftime('%Y-%m', date)",
+		"week":   weekStartExpr("date", weekStart),
 		"day":    "date",
 		"store":  "store",
 		"vendor": "vendor",
 	}
 
-	groupByClause, valid := validGroupBy[groupBy]
+	groupByClause, valid := validGroupBy[req.GroupBy]
 	if !valid {
-		return nil, fmt.Errorf("invalid groupBy parameter: %s", groupBy)
+		return nil, invalidf("invalid groupBy parameter: %s", req.GroupBy)
+	}
+
+	// By import, date stands for when each record was created, so the
+	// grouping and the year and date filters all apply to it
+	source := "sales_records"
+	if req.DateBasis == models.DateBasisImport {
+		source = `(
+			SELECT store, vendor, description, sale_price, commission, remaining, is_return, category, tags, metadata,
+				` + importDateExpr("created_at") + ` AS date
+			FROM sales_records
+		)`
 	}
 
 	query := fmt.Sprintf(`
 		SELECT 
 			%s as period,
-			COUNT(*) as items_sold,
-			SUM(sale_price) as total_sales,
-			SUM(commission) as total_commission,
-			SUM(remaining) as total_remaining,
+			COUNT(*) - SUM(is_return) as items_sold,
+			SUM(CASE WHEN is_return = 1 THEN -sale_price ELSE sale_price END) as total_sales,
+			COALESCE(SUM(CASE WHEN is_return = 1 THEN -commission ELSE commission END), 0) as total_commission,
+			COALESCE(SUM(CASE WHEN is_return = 1 THEN -remaining ELSE remaining END), 0) as total_remaining,
+			COALESCE(SUM(CASE WHEN is_return = 1 THEN -commission ELSE commission END) * 1.0 / NULLIF(SUM(CASE WHEN commission IS NULL THEN NULL WHEN is_return = 1 THEN -sale_price ELSE sale_price END), 0), 0) as commission_rate,
 			COUNT(DISTINCT store) as unique_stores,
-			COUNT(DISTINCT vendor) as unique_vendors
-		FROM sales_records
-	`, groupByClause)
+			COUNT(DISTINCT vendor) as unique_vendors,
+			SUM(is_return) as returned_items,
+			COALESCE(SUM(CASE WHEN is_return = 0 THEN sale_price END), 0) as gross_sales,
+			COALESCE(SUM(CASE WHEN is_return = 1 THEN sale_price END), 0) as total_returns
+		FROM %s
+	`, groupByClause, source)
 
-	args := []interface{}{}
-	whereParts := []string{}
-
-	if year != nil {
-		whereParts = append(whereParts, "strftime('%Y', date) = ?")
-		args = append(args, *year)
+	whereClause, args := buildFilterWhere(req.Filter)
+	if req.Year != nil {
+		if whereClause == "" {
+			whereClause = "WHERE strftime('%Y', date) = ?"
+		} else {
+			whereClause += " AND strftime('%Y', date) = ?"
+		}
+		args = append(args, *req.Year)
 	}
-	if store != nil {
-		whereParts = append(whereParts, "store = ?")
-		args = append(args, *store)
+	query += whereClause
+
+	query += fmt.Sprintf(" GROUP BY %s", groupByClause)
+
+	// Thresholds apply to each group's totals
+	havingParts := []string{}
+	if req.MinTotalSales != nil {
+		havingParts = append(havingParts, "SUM(CASE WHEN is_return = 1 THEN -sale_price ELSE sale_price END) >= ?")
+		args = append(args, *req.MinTotalSales)
 	}
-	if vendor != nil {
-		whereParts = append(whereParts, "vendor = ?")
-		args = append(args, *vendor)
+	if req.MinItemsSold != nil {
+		havingParts = append(havingParts, "COUNT(*) - SUM(is_return) >= ?")
+		args = append(args, *req.MinItemsSold)
 	}
-
-	if len(whereParts) > 0 {
-		query += " WHERE " + whereParts[0]
-		for i := 1; i < len(whereParts); i++ {
-			query += " AND " + whereParts[i]
-		}
+	if len(havingParts) > 0 {
+		query += " HAVING " + strings.Join(havingParts, " AND ")
 	}
 
-	query += fmt.Sprintf(" GROUP BY %s ORDER BY period DESC", groupByClause)
+	query += " ORDER BY period DESC"
 
-	rows, err := r.db.conn.Query(query, args...)
+	rows, err := r.q.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query custom summary: %w", err)
 	}
@@ -486,8 +746,12 @@ func (r *ReportingRepository) GetCustomSummary(groupBy string, year *string, sto
 			&summary.TotalSales,
 			&summary.TotalCommission,
 			&summary.TotalRemaining,
+			&summary.CommissionRate,
 			&summary.UniqueStores,
 			&summary.UniqueVendors,
+			&summary.ReturnedItems,
+			&summary.GrossSales,
+			&summary.TotalReturns,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan custom summary: %w", err)
@@ -501,3 +765,702 @@ func (r *ReportingRepository) GetCustomSummary(groupBy string, year *string, sto
 
 	return summaries, nil
 }
+
+// GetTopProducts returns the best-selling products grouped by canonical product key
+func (r *ReportingRepository) GetTopProducts(limit int) ([]models.ProductSummary, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	query := `
+		SELECT
+			product_key,
+			MIN(description) as description,
+			COUNT(DISTINCT description) as variants,
+			COUNT(*) - SUM(is_return) as items_sold,
+			SUM(is_return) as returned_items,
+			SUM(CASE WHEN is_return = 1 THEN -sale_price ELSE sale_price END) as total_sales,
+			COALESCE(AVG(CASE WHEN is_return = 0 THEN sale_price END), 0) as avg_sale_price
+		FROM sales_records
+		WHERE product_key != ''
+		GROUP BY product_key
+		ORDER BY total_sales DESC, items_sold DESC
+		LIMIT ?
+	`
+
+	rows, err := r.q.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top products: %w", err)
+	}
+	defer rows.Close()
+
+	var products []models.ProductSummary
+	for rows.Next() {
+		var product models.ProductSummary
+		err := rows.Scan(
+			&product.ProductKey,
+			&product.Description,
+			&product.Variants,
+			&product.ItemsSold,
+			&product.ReturnedItems,
+			&product.TotalSales,
+			&product.AvgSalePrice,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan top product: %w", err)
+		}
+		products = append(products, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating top products: %w", err)
+	}
+
+	return products, nil
+}
+
+// exchangeRateOn returns SQL for the latest rate of currencyExpr on or before
+// the sale date of report line s, or NULL when no rate is known
+func exchangeRateOn(currencyExpr string) string {
+	return `CASE WHEN ` + currencyExpr + ` = '` + models.ReferenceCurrency + `' THEN 1.0 ELSE (
+				SELECT er.rate FROM exchange_rates er
+				WHERE er.currency = ` + currencyExpr + ` AND er.date <= s.sale_date
+				ORDER BY er.date DESC
+				LIMIT 1
+			) END`
+}
+
+// importDateExpr is the local date a row was created, for summaries by
+// models.DateBasisImport. It is written like the sale dates stored by the
+// driver, so date filters and grouping by day treat both alike.
+func importDateExpr(createdAt string) string {
+	return "(date(" + createdAt + ", 'localtime') || ' 00:00:00+00:00')"
+}
+
+// weekStartExpr is the first day of the week of a date, YYYY-MM-DD, for
+// weeks starting on weekStart: the next weekStart after the day before the
+// date, a week earlier
+func weekStartExpr(date string, weekStart time.Weekday) string {
+	return fmt.Sprintf("date(%s, '+1 day', 'weekday %d', '-7 days')", date, weekStart)
+}
+
+// sourceLinesCTE selects one line per sales record and, when adjustments are
+// included, one line per adjustment dated when the adjustment was recorded.
+// Adjustment lines inherit the store, vendor, currency and return flag of the
+// sale they correct and are not counted as items. Lines are dated by sale or
+// by import as opts asks; sale_date keeps the sale date for exchange rates.
+func sourceLinesCTE(opts models.ReportOptions) string {
+	recordDate, adjustmentDate := "date", "a.date"
+	if opts.DateBasis == models.DateBasisImport {
+		recordDate, adjustmentDate = importDateExpr("created_at"), importDateExpr("a.created_at")
+	}
+
+	query := `
+	WITH source_lines AS (
+		SELECT ` + recordDate + ` AS date, date AS sale_date, store, vendor, is_return, currency, sale_price, commission, remaining, 1 AS is_item
+		FROM sales_records`
+	if opts.IncludeAdjustments {
+		query += `
+		UNION ALL
+		SELECT ` + adjustmentDate + `, a.date, r.store, r.vendor, r.is_return, r.currency, a.sale_price_delta, a.commission_delta, a.remaining_delta, 0
+		FROM sales_adjustments a
+		JOIN sales_records r ON r.id = a.sales_record_id`
+	}
+	return query + `
+	)`
+}
+
+// ratedLinesCTE extends sourceLinesCTE with the rates needed to convert each
+// line into the :base currency. Lines without a currency, or already in the
+// base currency, use a rate of 1 on both sides.
+func ratedLinesCTE(opts models.ReportOptions) string {
+	return sourceLinesCTE(opts) + `,
+	rated AS (
+		SELECT
+			s.*,
+			CASE WHEN s.currency IS NULL OR s.currency = :base THEN 1.0
+				ELSE ` + exchangeRateOn(":base") + ` END AS base_rate,
+			CASE WHEN s.currency IS NULL OR s.currency = :base THEN 1.0
+				ELSE ` + exchangeRateOn("s.currency") + ` END AS record_rate
+		FROM source_lines s
+	)`
+}
+
+// reportLinesCTE defines report_lines, the lines a summary aggregates, with
+// amounts converted into the base currency when one is set
+func reportLinesCTE(opts models.ReportOptions) string {
+	if opts.BaseCurrency == "" {
+		return sourceLinesCTE(opts) + `,
+	report_lines AS (SELECT * FROM source_lines)`
+	}
+
+	return ratedLinesCTE(opts) + `,
+	report_lines AS (
+		SELECT
+			date, store, vendor, is_return, is_item,
+			sale_price * base_rate / record_rate AS sale_price,
+			commission * base_rate / record_rate AS commission,
+			remaining * base_rate / record_rate AS remaining
+		FROM rated
+	)`
+}
+
+// reportSummaryColumns mirrors the aggregate columns of the summary views over
+// report_lines, rounding amounts to cents
+const reportSummaryColumns = `
+			SUM(CASE WHEN is_item = 1 AND is_return = 0 THEN 1 ELSE 0 END) as items_sold,
+			ROUND(SUM(CASE WHEN is_return = 1 THEN -sale_price ELSE sale_price END), 2) as total_sales,
+			ROUND(COALESCE(SUM(CASE WHEN is_return = 1 THEN -commission ELSE commission END), 0), 2) as total_commission,
+			ROUND(COALESCE(SUM(CASE WHEN is_return = 1 THEN -remaining ELSE remaining END), 0), 2) as total_remaining,
+			COALESCE(SUM(CASE WHEN is_return = 1 THEN -commission ELSE commission END) * 1.0 / NULLIF(SUM(CASE WHEN commission IS NULL THEN NULL WHEN is_return = 1 THEN -sale_price ELSE sale_price END), 0), 0) as commission_rate,
+			SUM(CASE WHEN is_item = 1 AND commission IS NOT NULL THEN 1 ELSE 0 END) as commission_known_items,
+			COUNT(DISTINCT store) as unique_stores,
+			COUNT(DISTINCT vendor) as unique_vendors,
+			SUM(CASE WHEN is_item = 1 THEN is_return ELSE 0 END) as returned_items,
+			ROUND(COALESCE(SUM(CASE WHEN is_return = 0 THEN sale_price END), 0), 2) as gross_sales,
+			ROUND(COALESCE(SUM(CASE WHEN is_return = 1 THEN sale_price END), 0), 2) as total_returns`
+
+// reportArgs returns the named parameters used by reportLinesCTE
+func reportArgs(opts models.ReportOptions) []interface{} {
+	if opts.BaseCurrency == "" {
+		return nil
+	}
+	return []interface{}{sql.Named("base", opts.BaseCurrency)}
+}
+
+// checkExchangeRates returns an error naming the first currency and date that
+// has no exchange rate, since such lines cannot be converted into the base
+// currency. It does nothing when no base currency is set.
+func (r *ReportingRepository) checkExchangeRates(opts models.ReportOptions) error {
+	if opts.BaseCurrency == "" {
+		return nil
+	}
+
+	query := ratedLinesCTE(opts) + `
+		SELECT
+			CASE WHEN record_rate IS NULL THEN currency ELSE :base END AS missing,
+			date(MIN(sale_date))
+		FROM rated
+		WHERE base_rate IS NULL OR record_rate IS NULL
+		GROUP BY missing
+		ORDER BY missing
+		LIMIT 1`
+
+	var currency, date string
+	err := r.q.QueryRow(query, reportArgs(opts)...).Scan(&currency, &date)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check exchange rates: %w", err)
+	}
+
+	return fmt.Errorf("no exchange rate for %s on or before %s", currency, date)
+}
+
+// GetYearlySummaryWithOptions returns the yearly summary, optionally converted
+// into a base currency and with price adjustments folded in
+func (r *ReportingRepository) GetYearlySummaryWithOptions(opts models.ReportOptions) ([]models.YearlySummary, error) {
+	if err := r.checkExchangeRates(opts); err != nil {
+		return nil, err
+	}
+
+	query := reportLinesCTE(opts) + `
+		SELECT
+			strftime('%Y', date) as year,` + reportSummaryColumns + `
+		FROM report_lines
+		GROUP BY strftime('%Y', date)
+		ORDER BY year DESC`
+
+	rows, err := r.q.Query(query, reportArgs(opts)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query yearly summary: %w", err)
+	}
+	defer rows.Close()
+
+	return scanYearlySummaries(rows)
+}
+
+// GetMonthlySummaryWithOptions returns the monthly summary, optionally filtered
+// by year, converted into a base currency and with price adjustments folded in
+func (r *ReportingRepository) GetMonthlySummaryWithOptions(year *string, opts models.ReportOptions) ([]models.MonthlySummary, error) {
+	return r.GetMonthlySummaryByDimension(year, "", opts)
+}
+
+// GetMonthlySummaryByDimension returns the monthly summary split into one row
+// per store or vendor within each month, in one query, or not split when
+// dimension is empty
+func (r *ReportingRepository) GetMonthlySummaryByDimension(year *string, dimension string, opts models.ReportOptions) ([]models.MonthlySummary, error) {
+	validDimensions := map[string]string{
+		"":                     "",
+		models.DimensionStore:  "store",
+		models.DimensionVendor: "vendor",
+	}
+
+	column, valid := validDimensions[dimension]
+	if !valid {
+		return nil, invalidf("invalid dimension parameter: %s", dimension)
+	}
+
+	if err := r.checkExchangeRates(opts); err != nil {
+		return nil, err
+	}
+
+	selectDimension, groupDimension, orderDimension := "", "", ""
+	if column != "" {
+		selectDimension = "\n\t\t\t" + column + " as dimension,"
+		groupDimension = ", " + column
+		orderDimension = ", " + column + " COLLATE NOCASE"
+	}
+
+	query := reportLinesCTE(opts) + `
+		SELECT
+			strftime('%Y', date) as year,
+			strftime('%m', date) as month,
+			strftime('%Y-%m', date) as year_month,` + selectDimension + reportSummaryColumns + `
+		FROM report_lines`
+
+	args := reportArgs(opts)
+	if year != nil {
+		query += " WHERE strftime('%Y', date) = :year"
+		args = append(args, sql.Named("year", *year))
+	}
+
+	query += " GROUP BY strftime('%Y-%m', date)" + groupDimension + " ORDER BY year DESC, month DESC" + orderDimension
+
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query monthly summary: %w", err)
+	}
+	defer rows.Close()
+
+	return scanMonthlySummaries(rows, column != "")
+}
+
+// GetPeriodTotals sums the sales dated on or after from and before to
+func (r *ReportingRepository) GetPeriodTotals(from, to time.Time) (models.PeriodTotals, error) {
+	totals := models.PeriodTotals{
+		From: models.NewDate(from),
+		To:   models.NewDate(to.AddDate(0, 0, -1)),
+	}
+
+	query := `
+		SELECT
+			COUNT(*) - COALESCE(SUM(is_return), 0) as items_sold,
+			COALESCE(SUM(is_return), 0) as returned_items,
+			ROUND(COALESCE(SUM(CASE WHEN is_return = 1 THEN -sale_price ELSE sale_price END), 0), 2) as total_sales,
+			ROUND(COALESCE(SUM(CASE WHEN is_return = 1 THEN -commission ELSE commission END), 0), 2) as total_commission,
+			ROUND(COALESCE(SUM(CASE WHEN is_return = 1 THEN -remaining ELSE remaining END), 0), 2) as total_remaining
+		FROM sales_records
+		WHERE date >= ? AND date < ?
+	`
+
+	err := r.q.QueryRow(query, models.NewDate(from), models.NewDate(to)).Scan(
+		&totals.ItemsSold,
+		&totals.ReturnedItems,
+		&totals.TotalSales,
+		&totals.TotalCommission,
+		&totals.TotalRemaining,
+	)
+	if err != nil {
+		return totals, fmt.Errorf("failed to query period totals: %w", err)
+	}
+	return totals, nil
+}
+
+// GetTopVendor returns the vendor with the highest sales dated on or after
+// from and before to, or nil if there were none
+func (r *ReportingRepository) GetTopVendor(from, to time.Time) (*models.VendorTotal, error) {
+	query := `
+		SELECT
+			vendor,
+			COUNT(*) - SUM(is_return) as items_sold,
+			ROUND(SUM(CASE WHEN is_return = 1 THEN -sale_price ELSE sale_price END), 2) as total_sales
+		FROM sales_records
+		WHERE date >= ? AND date < ?
+		GROUP BY vendor
+		ORDER BY total_sales DESC, items_sold DESC, vendor
+		LIMIT 1
+	`
+
+	var vendor models.VendorTotal
+	err := r.q.QueryRow(query, models.NewDate(from), models.NewDate(to)).Scan(&vendor.Vendor, &vendor.ItemsSold, &vendor.TotalSales)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top vendor: %w", err)
+	}
+	return &vendor, nil
+}
+
+// GetProfitability returns the profit made on the sales matching filter,
+// grouped by item, vendor or category. A sale's proceeds are its remaining
+// amount or, when that is unknown, its sale price less any commission.
+func (r *ReportingRepository) GetProfitability(filter models.ProfitabilityFilter) (*models.ProfitabilityReport, error) {
+	validGroupBy := map[string]string{
+		models.ProfitByItem:     "product_key",
+		models.ProfitByVendor:   "vendor",
+		models.ProfitByCategory: "COALESCE(category, '')",
+	}
+
+	groupByClause, valid := validGroupBy[filter.GroupBy]
+	if !valid {
+		return nil, invalidf("invalid groupBy parameter: %s", filter.GroupBy)
+	}
+
+	var whereParts []string
+	var args []interface{}
+	if filter.Store != nil {
+		whereParts = append(whereParts, "store = ?")
+		args = append(args, *filter.Store)
+	}
+	if filter.DateFrom != nil {
+		whereParts = append(whereParts, "date >= ?")
+		args = append(args, *filter.DateFrom)
+	}
+	if filter.DateTo != nil {
+		whereParts = append(whereParts, "date <= ?")
+		args = append(args, *filter.DateTo)
+	}
+	where := ""
+	if len(whereParts) > 0 {
+		where = "WHERE " + strings.Join(whereParts, " AND ")
+	}
+
+	query := fmt.Sprintf(`
+		WITH lines AS (
+			SELECT
+				%s as grp,
+				description,
+				is_return,
+				CASE WHEN is_return = 1 THEN -1 ELSE 1 END as sign,
+				sale_price,
+				COALESCE(remaining, sale_price - COALESCE(commission, 0)) as proceeds,
+				cost
+			FROM sales_records
+			%s
+		)
+		SELECT
+			grp,
+			MIN(description) as description,
+			SUM(CASE WHEN cost IS NOT NULL THEN sign ELSE 0 END) as costed_items,
+			SUM(CASE WHEN cost IS NULL THEN sign ELSE 0 END) as uncosted_items,
+			ROUND(COALESCE(SUM(CASE WHEN cost IS NOT NULL THEN sign * sale_price END), 0), 2) as total_sales,
+			ROUND(COALESCE(SUM(CASE WHEN cost IS NOT NULL THEN sign * proceeds END), 0), 2) as total_proceeds,
+			ROUND(COALESCE(SUM(sign * cost), 0), 2) as total_cost
+		FROM lines
+		GROUP BY grp
+		ORDER BY total_proceeds - total_cost DESC, grp
+	`, groupByClause, where)
+
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query profitability: %w", err)
+	}
+	defer rows.Close()
+
+	report := &models.ProfitabilityReport{
+		GroupBy: filter.GroupBy,
+		Groups:  []models.ProfitSummary{},
+	}
+	for rows.Next() {
+		var summary models.ProfitSummary
+		err := rows.Scan(
+			&summary.Group,
+			&summary.Description,
+			&summary.CostedItems,
+			&summary.UncostedItems,
+			&summary.Sales,
+			&summary.Proceeds,
+			&summary.Cost,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan profitability: %w", err)
+		}
+		if filter.GroupBy != models.ProfitByItem {
+			summary.Description = ""
+		}
+		summary.CalculateProfit()
+		report.Groups = append(report.Groups, summary)
+		report.Total.Add(summary)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating profitability: %w", err)
+	}
+
+	report.Total.CalculateProfit()
+	return report, nil
+}
+
+// GetPareto returns the revenue of each vendor or item, net of returns,
+// highest first, with its share of the total
+func (r *ReportingRepository) GetPareto(filter models.ParetoFilter) (*models.ParetoReport, error) {
+	validGroupBy := map[string]string{
+		models.ParetoByVendor: "vendor",
+		models.ParetoByItem:   "product_key",
+	}
+
+	groupByClause, valid := validGroupBy[filter.GroupBy]
+	if !valid {
+		return nil, invalidf("invalid groupBy parameter: %s", filter.GroupBy)
+	}
+
+	var whereParts []string
+	var args []interface{}
+	if filter.Store != nil {
+		whereParts = append(whereParts, "store = ?")
+		args = append(args, *filter.Store)
+	}
+	if filter.DateFrom != nil {
+		whereParts = append(whereParts, "date >= ?")
+		args = append(args, *filter.DateFrom)
+	}
+	if filter.DateTo != nil {
+		whereParts = append(whereParts, "date <= ?")
+		args = append(args, *filter.DateTo)
+	}
+	where := ""
+	if len(whereParts) > 0 {
+		where = "WHERE " + strings.Join(whereParts, " AND ")
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			%s as grp,
+			MIN(description) as description,
+			ROUND(SUM(CASE WHEN is_return = 1 THEN -sale_price ELSE sale_price END), 2) as revenue
+		FROM sales_records
+		%s
+		GROUP BY grp
+		ORDER BY revenue DESC, grp
+	`, groupByClause, where)
+
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pareto: %w", err)
+	}
+	defer rows.Close()
+
+	report := &models.ParetoReport{
+		GroupBy: filter.GroupBy,
+		Entries: []models.ParetoEntry{},
+	}
+	for rows.Next() {
+		var entry models.ParetoEntry
+		if err := rows.Scan(&entry.Group, &entry.Description, &entry.Revenue); err != nil {
+			return nil, fmt.Errorf("failed to scan pareto: %w", err)
+		}
+		if filter.GroupBy != models.ParetoByItem {
+			entry.Description = ""
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating pareto: %w", err)
+	}
+
+	report.CalculateShares()
+	return report, nil
+}
+
+// GetVendorStatement returns the sales of one vendor matching filter, oldest
+// first, with their totals per store and month. Only the vendor's own
+// records are read, so the statement can be shared with the vendor.
+func (r *ReportingRepository) GetVendorStatement(filter models.VendorStatementFilter) (*models.VendorStatement, error) {
+	whereParts := []string{"vendor = ?"}
+	args := []interface{}{filter.Vendor}
+	if filter.DateFrom != nil {
+		whereParts = append(whereParts, "date >= ?")
+		args = append(args, *filter.DateFrom)
+	}
+	if filter.DateTo != nil {
+		whereParts = append(whereParts, "date <= ?")
+		args = append(args, *filter.DateTo)
+	}
+
+	query := `
+		SELECT date, store, description, is_return, sale_price, commission, remaining, currency
+		FROM sales_records
+		WHERE ` + strings.Join(whereParts, " AND ") + `
+		ORDER BY date, id`
+
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query vendor statement: %w", err)
+	}
+	defer rows.Close()
+
+	statement := &models.VendorStatement{
+		Vendor:      filter.Vendor,
+		Lines:       []models.VendorStatementLine{},
+		Settlements: []models.VendorSettlement{},
+	}
+	settlements := make(map[[2]string]*models.VendorSettlement)
+	for rows.Next() {
+		var line models.VendorStatementLine
+		err := rows.Scan(
+			&line.Date,
+			&line.Store,
+			&line.Description,
+			&line.IsReturn,
+			&line.SalePrice,
+			&line.Commission,
+			&line.Remaining,
+			&line.Currency,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan vendor statement line: %w", err)
+		}
+		statement.Lines = append(statement.Lines, line)
+
+		key := [2]string{line.Date.Format("2006-01"), line.Store}
+		settlement, ok := settlements[key]
+		if !ok {
+			settlement = &models.VendorSettlement{Store: line.Store, Month: key[0]}
+			settlements[key] = settlement
+		}
+		addToSettlement(settlement, line)
+		addToSettlement(&statement.Total, line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating vendor statement: %w", err)
+	}
+
+	for _, settlement := range settlements {
+		statement.Settlements = append(statement.Settlements, *settlement)
+	}
+	sort.Slice(statement.Settlements, func(i, j int) bool {
+		a, b := statement.Settlements[i], statement.Settlements[j]
+		if a.Month != b.Month {
+			return a.Month > b.Month
+		}
+		return a.Store < b.Store
+	})
+
+	switch {
+	case filter.DateFrom != nil:
+		statement.From = models.NewDate(*filter.DateFrom)
+	case len(statement.Lines) > 0:
+		statement.From = statement.Lines[0].Date
+	}
+	switch {
+	case filter.DateTo != nil:
+		statement.To = models.NewDate(*filter.DateTo)
+	case len(statement.Lines) > 0:
+		statement.To = statement.Lines[len(statement.Lines)-1].Date
+	}
+
+	return statement, nil
+}
+
+// GetTimeline returns the sales and adjustments of the store or vendor named
+// by filter, oldest first. Adjustments are dated when they were recorded and
+// follow the sales of the same day. Payouts are left to the caller.
+func (r *ReportingRepository) GetTimeline(filter models.TimelineFilter) ([]models.TimelineEntry, error) {
+	column := "r.store"
+	if filter.Kind == models.AliasKindVendor {
+		column = "r.vendor"
+	}
+
+	// Each half of the union filters on its own date: the sale's, or the
+	// adjustment's
+	where := func(date string) (string, []interface{}) {
+		whereParts := []string{column + " = ?"}
+		args := []interface{}{filter.Name}
+		if filter.DateFrom != nil {
+			whereParts = append(whereParts, date+" >= ?")
+			args = append(args, *filter.DateFrom)
+		}
+		if filter.DateTo != nil {
+			whereParts = append(whereParts, date+" <= ?")
+			args = append(args, *filter.DateTo)
+		}
+		return strings.Join(whereParts, " AND "), args
+	}
+	salesWhere, args := where("r.date")
+	adjustmentsWhere, adjustmentArgs := where("a.date")
+	args = append(args, adjustmentArgs...)
+
+	query := `
+		SELECT day, type, store, vendor, description, amount, currency, sales_record_id, adjustment_id, remaining
+		FROM (
+			SELECT
+				strftime('%Y-%m-%d', r.date) AS day,
+				0 AS position,
+				CASE WHEN r.is_return THEN '` + models.TimelineReturn + `' ELSE '` + models.TimelineSale + `' END AS type,
+				r.store, r.vendor, r.description,
+				CASE WHEN r.is_return THEN -r.sale_price ELSE r.sale_price END AS amount,
+				r.currency,
+				r.id AS sales_record_id,
+				NULL AS adjustment_id,
+				r.remaining,
+				r.id AS seq
+			FROM sales_records r
+			WHERE ` + salesWhere + `
+			UNION ALL
+			SELECT
+				strftime('%Y-%m-%d', a.date), 1, '` + models.TimelineAdjustment + `',
+				r.store, r.vendor, a.reason, a.sale_price_delta, r.currency,
+				r.id, a.id, NULL, a.id
+			FROM sales_adjustments a
+			JOIN sales_records r ON r.id = a.sales_record_id
+			WHERE ` + adjustmentsWhere + `
+		)
+		ORDER BY day, position, seq`
+
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query timeline: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.TimelineEntry{}
+	for rows.Next() {
+		var entry models.TimelineEntry
+		err := rows.Scan(
+			&entry.Date,
+			&entry.Type,
+			&entry.Store,
+			&entry.Vendor,
+			&entry.Description,
+			&entry.Amount,
+			&entry.Currency,
+			&entry.SalesRecordID,
+			&entry.AdjustmentID,
+			&entry.Remaining,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan timeline entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating timeline: %w", err)
+	}
+
+	return entries, nil
+}
+
+// addToSettlement adds a statement line to a settlement's totals, subtracting
+// returns. Unknown commission and remaining amounts count as zero.
+func addToSettlement(settlement *models.VendorSettlement, line models.VendorStatementLine) {
+	sign := 1.0
+	if line.IsReturn {
+		sign = -1
+		settlement.ReturnedItems++
+	} else {
+		settlement.ItemsSold++
+	}
+	settlement.TotalSales = roundCents(settlement.TotalSales + sign*line.SalePrice)
+	if line.Commission != nil {
+		settlement.TotalCommission = roundCents(settlement.TotalCommission + sign*(*line.Commission))
+	}
+	if line.Remaining != nil {
+		settlement.TotalRemaining = roundCents(settlement.TotalRemaining + sign*(*line.Remaining))
+	}
+}
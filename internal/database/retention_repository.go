@@ -0,0 +1,135 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// RetentionRepository moves and purges data according to a retention policy
+type RetentionRepository struct {
+	db *DB
+	tx *sql.Tx // non-nil when the repository is bound to a transaction
+}
+
+// NewRetentionRepository creates a new retention repository
+func NewRetentionRepository(db *DB) *RetentionRepository {
+	return &RetentionRepository{db: db}
+}
+
+// WithTx returns a copy of the repository whose operations run inside tx
+func (r *RetentionRepository) WithTx(tx *sql.Tx) *RetentionRepository {
+	return &RetentionRepository{db: r.db, tx: tx}
+}
+
+// execTx runs fn in the bound transaction, or in a new one if the repository
+// is not bound to a transaction
+func (r *RetentionRepository) execTx(fn func(*sql.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return r.db.ExecTx(fn)
+}
+
+// Apply runs every enabled rule of the policy relative to now in a single
+// transaction and reports the number of rows each rule affected
+func (r *RetentionRepository) Apply(policy models.RetentionPolicy, now time.Time) (*models.RetentionResult, error) {
+	result := &models.RetentionResult{RanAt: now}
+
+	err := r.execTx(func(tx *sql.Tx) error {
+		if policy.ArchiveAfterYears > 0 {
+			cutoff := archiveCutoff(policy, now)
+			adjustments, records, err := archiveRecords(tx, cutoff)
+			if err != nil {
+				return err
+			}
+			result.ArchivedAdjustments = adjustments
+			result.ArchivedRecords = records
+		}
+
+		if policy.PurgeDeletedAfterDays > 0 {
+			purged, err := execCount(tx, "DELETE FROM deleted_sales_records WHERE datetime(deleted_at) < datetime(?)",
+				daysCutoff(now, policy.PurgeDeletedAfterDays))
+			if err != nil {
+				return fmt.Errorf("failed to purge deleted records: %w", err)
+			}
+			result.PurgedDeletedRecords = purged
+		}
+
+		if policy.PurgeImportHistoryAfterDays > 0 {
+			purged, err := execCount(tx, "DELETE FROM import_runs WHERE datetime(started_at) < datetime(?)",
+				daysCutoff(now, policy.PurgeImportHistoryAfterDays))
+			if err != nil {
+				return fmt.Errorf("failed to purge import history: %w", err)
+			}
+			result.PurgedImportRuns = purged
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// archiveCutoff returns the date before which sales are archived
+func archiveCutoff(policy models.RetentionPolicy, now time.Time) string {
+	return now.AddDate(-policy.ArchiveAfterYears, 0, 0).Format("2006-01-02")
+}
+
+// daysCutoff returns the UTC timestamp the given number of days before now
+func daysCutoff(now time.Time, days int) string {
+	return now.AddDate(0, 0, -days).UTC().Format("2006-01-02 15:04:05")
+}
+
+// archiveRecords moves sales dated before cutoff, and their adjustments, into
+// the archive tables
+func archiveRecords(tx *sql.Tx, cutoff string) (int64, int64, error) {
+	adjustments, err := execCount(tx, `
+		INSERT INTO sales_adjustments_archive (`+adjustmentColumns+`)
+		SELECT `+prefixColumns("a.", adjustmentColumns)+`
+		FROM sales_adjustments a
+		JOIN sales_records s ON s.id = a.sales_record_id
+		WHERE date(s.date) < date(?)`, cutoff)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to archive adjustments: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO sales_records_archive (`+salesRecordColumns+`, `+provenanceColumns+`)
+		SELECT `+salesRecordColumns+`, `+provenanceColumns+` FROM sales_records
+		WHERE date(date) < date(?)`, cutoff); err != nil {
+		return 0, 0, fmt.Errorf("failed to archive sales records: %w", err)
+	}
+
+	// Adjustments of the removed records are deleted by the foreign key cascade
+	records, err := execCount(tx, "DELETE FROM sales_records WHERE date(date) < date(?)", cutoff)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to remove archived sales records: %w", err)
+	}
+
+	return adjustments, records, nil
+}
+
+// prefixColumns qualifies each column of a comma-separated column list with prefix
+func prefixColumns(prefix, columns string) string {
+	names := strings.Split(columns, ", ")
+	for i, name := range names {
+		names[i] = prefix + name
+	}
+	return strings.Join(names, ", ")
+}
+
+// execCount executes a statement and returns the number of rows it affected
+func execCount(tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -1,8 +1,11 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -24,76 +27,201 @@ var (
 	}
 )
 
+// batchInsertChunkSize is the number of rows per multi-row INSERT in CreateBatch.
+// Each row binds 16 parameters, keeping a chunk well under SQLite's variable limit.
+const batchInsertChunkSize = 500
+
+// salesRecordColumns is the column list selected for a full sales record,
+// in the order expected by scanSalesRecord
+const salesRecordColumns = "id, store, vendor, date, description, product_key, sale_price, commission, remaining, is_return, currency, external_id, metadata, cost, category, tags, created_at, updated_at"
+
+// insertColumns is the column list written when creating a sales record, in
+// the order produced by insertValues
+const insertColumns = "store, vendor, date, description, product_key, sale_price, commission, remaining, is_return, currency, metadata, cost, category, import_run_id, source_row, external_id"
+
+// insertPlaceholders holds a placeholder for each of insertColumns
+const insertPlaceholders = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+// provenanceColumns link a record to the import and source row it came from.
+// They are copied with a record into the trash and the archive, but are not
+// part of SalesRecord; GetProvenance reads them.
+const provenanceColumns = "import_run_id, source_row"
+
+// upsertOnExternalID makes an INSERT idempotent for records that carry a
+// source transaction id: re-importing the same (store, external_id) updates
+// the existing row instead of creating a duplicate. Reports rarely carry a
+// cost or category, so a re-import without them keeps the values entered.
+const upsertOnExternalID = `
+		ON CONFLICT(store, external_id) WHERE external_id IS NOT NULL DO UPDATE SET
+			vendor = excluded.vendor,
+			date = excluded.date,
+			description = excluded.description,
+			product_key = excluded.product_key,
+			sale_price = excluded.sale_price,
+			commission = excluded.commission,
+			remaining = excluded.remaining,
+			is_return = excluded.is_return,
+			currency = excluded.currency,
+			metadata = excluded.metadata,
+			cost = COALESCE(excluded.cost, cost),
+			category = COALESCE(excluded.category, category),
+			import_run_id = COALESCE(excluded.import_run_id, import_run_id),
+			source_row = COALESCE(excluded.source_row, source_row)`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSalesRecord scans a row selected with salesRecordColumns
+func scanSalesRecord(scanner rowScanner, record *models.SalesRecord) error {
+	return scanner.Scan(
+		&record.ID,
+		&record.Store,
+		&record.Vendor,
+		&record.Date,
+		&record.Description,
+		&record.ProductKey,
+		&record.SalePrice,
+		&record.Commission,
+		&record.Remaining,
+		&record.IsReturn,
+		&record.Currency,
+		&record.ExternalID,
+		&record.Metadata,
+		&record.Cost,
+		&record.Category,
+		&record.Tags,
+		&record.CreatedAt,
+		&record.UpdatedAt,
+	)
+}
+
 // SalesRepository handles database operations for sales records
 type SalesRepository struct {
-	db *DB
+	db          *DB
+	q           queryer
+	tx          *sql.Tx     // non-nil when the repository is bound to a transaction
+	importRunID *int64      // non-nil when records written are attributed to an import
+	aliases     nameAliases // store and vendor aliases applied to the records written
+	timer       *importTimer // non-nil when bound to an import; times its writes
 }
 
 // NewSalesRepository creates a new sales repository
 func NewSalesRepository(db *DB) *SalesRepository {
-	return &SalesRepository{db: db}
+	return &SalesRepository{db: db, q: db.conn}
+}
+
+// WithTx returns a copy of the repository whose operations run inside tx
+func (r *SalesRepository) WithTx(tx *sql.Tx) *SalesRepository {
+	return &SalesRepository{db: r.db, q: tx, tx: tx, importRunID: r.importRunID, aliases: r.aliases, timer: r.timer}
+}
+
+// ForImport returns a copy of the repository that attributes the records it
+// creates or updates to an import run and times its writes
+func (r *SalesRepository) ForImport(runID int64) *SalesRepository {
+	return &SalesRepository{db: r.db, q: r.q, tx: r.tx, importRunID: &runID, aliases: r.aliases, timer: &importTimer{}}
+}
+
+// WithAliases returns a copy of the repository that files the records it
+// creates or updates under the canonical names of their stores and vendors
+func (r *SalesRepository) WithAliases(aliases nameAliases) *SalesRepository {
+	return &SalesRepository{db: r.db, q: r.q, tx: r.tx, importRunID: r.importRunID, aliases: aliases, timer: r.timer}
+}
+
+// execTx runs fn in the bound transaction, or in a new one if the repository
+// is not bound to a transaction
+func (r *SalesRepository) execTx(fn func(*sql.Tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	return r.db.ExecTx(fn)
 }
 
 // Create inserts a new sales record into the database
 func (r *SalesRepository) Create(record models.CreateSalesRecordRequest) (*models.SalesRecord, error) {
-	// Parse the date string
-	date, err := time.Parse("2006-01-02", record.Date)
+	defer r.timer.addInsert(time.Now())
+	values, err := r.insertValues(record)
 	if err != nil {
 		return nil, fmt.Errorf("invalid date format: %w", err)
 	}
 
 	query := `
-		INSERT INTO sales_records (store, vendor, date, description, sale_price, commission, remaining)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`
+		INSERT INTO sales_records (` + insertColumns + `)
+		VALUES ` + insertPlaceholders + upsertOnExternalID + `
+		RETURNING ` + salesRecordColumns
 
-	result, err := r.db.conn.Exec(query,
-		record.Store,
-		record.Vendor,
-		date,
-		record.Description,
-		record.SalePrice,
-		record.Commission,
-		record.Remaining,
-	)
+	var created models.SalesRecord
+	err = scanSalesRecord(r.q.QueryRow(query, values...), &created)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert sales record: %w", err)
 	}
 
-	id, err := result.LastInsertId()
+	return &created, nil
+}
+
+// insertValues returns the values for insertColumns from a create request
+func (r *SalesRepository) insertValues(record models.CreateSalesRecordRequest) ([]interface{}, error) {
+	record = r.aliases.apply(record)
+	date, err := time.Parse("2006-01-02", record.Date)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get last insert ID: %w", err)
+		return nil, err
 	}
 
-	// Fetch and return the created record
-	return r.GetByID(id)
+	var currency interface{}
+	if record.Currency != nil && *record.Currency != "" {
+		currency = *record.Currency
+	}
+
+	var category interface{}
+	if record.Category != nil && *record.Category != "" {
+		category = *record.Category
+	}
+
+	var sourceRow interface{}
+	if record.SourceRow != nil {
+		data, err := json.Marshal(record.SourceRow)
+		if err != nil {
+			return nil, err
+		}
+		sourceRow = string(data)
+	}
+
+	var externalID interface{}
+	if record.ExternalID != nil && *record.ExternalID != "" {
+		externalID = *record.ExternalID
+	}
+
+	return []interface{}{
+		record.Store,
+		record.Vendor,
+		date,
+		record.Description,
+		models.ProductKey(record.Description),
+		record.SalePrice,
+		record.Commission,
+		record.Remaining,
+		record.IsReturn,
+		currency,
+		record.Metadata,
+		record.Cost,
+		category,
+		r.importRunID,
+		sourceRow,
+		externalID,
+	}, nil
 }
 
 // GetByID retrieves a sales record by its ID
 func (r *SalesRepository) GetByID(id int64) (*models.SalesRecord, error) {
-	query := `
-		SELECT id, store, vendor, date, description, sale_price, commission, remaining, created_at, updated_at
-		FROM sales_records
-		WHERE id = ?
-	`
+	query := "SELECT " + salesRecordColumns + " FROM sales_records WHERE id = ?"
 
 	var record models.SalesRecord
-	err := r.db.conn.QueryRow(query, id).Scan(
-		&record.ID,
-		&record.Store,
-		&record.Vendor,
-		&record.Date,
-		&record.Description,
-		&record.SalePrice,
-		&record.Commission,
-		&record.Remaining,
-		&record.CreatedAt,
-		&record.UpdatedAt,
-	)
+	err := scanSalesRecord(r.q.QueryRow(query, id), &record)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("sales record with ID %d not found", id)
+			return nil, fmt.Errorf("sales record with ID %d %w", id, ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get sales record: %w", err)
 	}
@@ -124,8 +252,8 @@ func (r *SalesRepository) Update(id int64, updates models.UpdateSalesRecordReque
 		args = append(args, date)
 	}
 	if updates.Description != nil {
-		setParts = append(setParts, "description = ?")
-		args = append(args, *updates.Description)
+		setParts = append(setParts, "description = ?", "product_key = ?")
+		args = append(args, *updates.Description, models.ProductKey(*updates.Description))
 	}
 	if updates.SalePrice != nil {
 		setParts = append(setParts, "sale_price = ?")
@@ -139,6 +267,26 @@ func (r *SalesRepository) Update(id int64, updates models.UpdateSalesRecordReque
 		setParts = append(setParts, "remaining = ?")
 		args = append(args, *updates.Remaining)
 	}
+	if updates.IsReturn != nil {
+		setParts = append(setParts, "is_return = ?")
+		args = append(args, *updates.IsReturn)
+	}
+	if updates.Cost != nil {
+		setParts = append(setParts, "cost = ?")
+		args = append(args, *updates.Cost)
+	}
+	if updates.Category != nil {
+		setParts = append(setParts, "category = NULLIF(?, '')")
+		args = append(args, *updates.Category)
+	}
+	if updates.Tags != nil {
+		setParts = append(setParts, "tags = ?")
+		args = append(args, models.NormalizeTags(updates.Tags))
+	}
+	if updates.Metadata != nil {
+		setParts = append(setParts, "metadata = ?")
+		args = append(args, updates.Metadata)
+	}
 
 	if len(setParts) == 0 {
 		return r.GetByID(id) // No updates, return existing record
@@ -150,7 +298,7 @@ func (r *SalesRepository) Update(id int64, updates models.UpdateSalesRecordReque
 
 	query := fmt.Sprintf("UPDATE sales_records SET %s WHERE id = ?", strings.Join(setParts, ", "))
 
-	_, err := r.db.conn.Exec(query, args...)
+	_, err := r.q.Exec(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update sales record: %w", err)
 	}
@@ -159,29 +307,60 @@ func (r *SalesRepository) Update(id int64, updates models.UpdateSalesRecordReque
 	return r.GetByID(id)
 }
 
-// Delete removes a sales record from the database
-func (r *SalesRepository) Delete(id int64) error {
-	query := "DELETE FROM sales_records WHERE id = ?"
-	result, err := r.db.conn.Exec(query, id)
-	if err != nil {
-		return fmt.Errorf("failed to delete sales record: %w", err)
+// GetProvenance retrieves the import that last wrote a record and the row of
+// its source the record was parsed from. Both are nil for records entered by
+// hand or imported before provenance was kept.
+func (r *SalesRepository) GetProvenance(id int64) (*int64, *models.SourceRow, error) {
+	var importRunID *int64
+	var data *string
+	err := r.q.QueryRow("SELECT "+provenanceColumns+" FROM sales_records WHERE id = ?", id).Scan(&importRunID, &data)
+	if err == sql.ErrNoRows {
+		return nil, nil, fmt.Errorf("sales record with ID %d %w", id, ErrNotFound)
 	}
-
-	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, nil, fmt.Errorf("failed to get record provenance: %w", err)
+	}
+	if data == nil {
+		return importRunID, nil, nil
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("sales record with ID %d not found", id)
+	var row models.SourceRow
+	if err := json.Unmarshal([]byte(*data), &row); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse source row: %w", err)
 	}
+	return importRunID, &row, nil
+}
 
-	return nil
+// Delete removes a sales record from the database. A copy is kept in the
+// trash until the retention policy purges it.
+func (r *SalesRepository) Delete(id int64) error {
+	return r.execTx(func(tx *sql.Tx) error {
+		columns := salesRecordColumns + ", " + provenanceColumns
+		trash := "INSERT INTO deleted_sales_records (" + columns + ") SELECT " + columns + " FROM sales_records WHERE id = ?"
+		if _, err := tx.Exec(trash, id); err != nil {
+			return fmt.Errorf("failed to move sales record to trash: %w", err)
+		}
+
+		result, err := tx.Exec("DELETE FROM sales_records WHERE id = ?", id)
+		if err != nil {
+			return fmt.Errorf("failed to delete sales record: %w", err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+
+		if rowsAffected == 0 {
+			return fmt.Errorf("sales record with ID %d %w", id, ErrNotFound)
+		}
+
+		return nil
+	})
 }
 
-// List retrieves sales records with optional filtering and pagination
-func (r *SalesRepository) List(filter models.SalesRecordFilter) (*models.SalesRecordList, error) {
-	// Build WHERE clause
+// buildFilterWhere builds the WHERE clause and arguments for a sales record filter
+func buildFilterWhere(filter models.SalesRecordFilter) (string, []interface{}) {
 	whereParts := []string{}
 	args := []interface{}{}
 
@@ -193,6 +372,26 @@ func (r *SalesRepository) List(filter models.SalesRecordFilter) (*models.SalesRe
 		whereParts = append(whereParts, "vendor = ?")
 		args = append(args, *filter.Vendor)
 	}
+	if filter.Search != nil && *filter.Search != "" {
+		whereParts = append(whereParts, `description LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(*filter.Search)+"%")
+	}
+	if len(filter.StoreIn) > 0 {
+		whereParts = append(whereParts, "store IN ("+placeholderList(len(filter.StoreIn))+")")
+		args = appendStrings(args, filter.StoreIn)
+	}
+	if len(filter.VendorIn) > 0 {
+		whereParts = append(whereParts, "vendor IN ("+placeholderList(len(filter.VendorIn))+")")
+		args = appendStrings(args, filter.VendorIn)
+	}
+	if len(filter.NotStore) > 0 {
+		whereParts = append(whereParts, "store NOT IN ("+placeholderList(len(filter.NotStore))+")")
+		args = appendStrings(args, filter.NotStore)
+	}
+	if len(filter.NotVendor) > 0 {
+		whereParts = append(whereParts, "vendor NOT IN ("+placeholderList(len(filter.NotVendor))+")")
+		args = appendStrings(args, filter.NotVendor)
+	}
 	if filter.DateFrom != nil {
 		whereParts = append(whereParts, "date >= ?")
 		args = append(args, *filter.DateFrom)
@@ -209,24 +408,86 @@ func (r *SalesRepository) List(filter models.SalesRecordFilter) (*models.SalesRe
 		whereParts = append(whereParts, "sale_price <= ?")
 		args = append(args, *filter.MaxPrice)
 	}
+	if filter.Category != nil {
+		whereParts = append(whereParts, "category = ?")
+		args = append(args, *filter.Category)
+	}
+	if filter.Tag != nil {
+		whereParts = append(whereParts, "EXISTS (SELECT 1 FROM json_each(tags) WHERE json_each.value = ?)")
+		args = append(args, *filter.Tag)
+	}
+	for _, key := range sortedKeys(filter.Metadata) {
+		whereParts = append(whereParts, "json_extract(metadata, ?) = ?")
+		args = append(args, metadataPath(key), filter.Metadata[key])
+	}
 
-	whereClause := ""
-	if len(whereParts) > 0 {
-		whereClause = "WHERE " + strings.Join(whereParts, " AND ")
+	if len(whereParts) == 0 {
+		return "", args
 	}
+	return "WHERE " + strings.Join(whereParts, " AND "), args
+}
 
-	// Build ORDER BY clause
-	orderBy := "ORDER BY date DESC" // Default sort
+// likeEscaper escapes the wildcards of a LIKE pattern, so searched text is
+// matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// metadataPath returns the JSON path of a custom field. Quoting the name lets
+// it contain spaces, dots and other punctuation; quotes and backslashes in it
+// are escaped as in a JSON string.
+func metadataPath(key string) string {
+	return `$."` + jsonLabelEscaper.Replace(key) + `"`
+}
+
+// jsonLabelEscaper escapes a name for a quoted label of a JSON path
+var jsonLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// sortedKeys returns the keys of a map in order, so queries built from it
+// are stable
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// placeholderList returns n comma-separated "?" placeholders
+func placeholderList(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// appendStrings appends string values to a query argument list
+func appendStrings(args []interface{}, values []string) []interface{} {
+	for _, value := range values {
+		args = append(args, value)
+	}
+	return args
+}
+
+// buildListOrderBy returns the ORDER BY clause for a list filter, falling back
+// to newest first when the requested sort is missing or invalid
+func buildListOrderBy(filter models.SalesRecordFilter) string {
 	if filter.SortBy != nil && filter.SortOrder != nil {
 		if validSortFields[*filter.SortBy] && validSortOrders[*filter.SortOrder] {
-			orderBy = fmt.Sprintf("ORDER BY %s %s", *filter.SortBy, strings.ToUpper(*filter.SortOrder))
+			return fmt.Sprintf("ORDER BY %s %s", *filter.SortBy, strings.ToUpper(*filter.SortOrder))
 		}
 	}
+	return "ORDER BY date DESC"
+}
+
+// List retrieves sales records with optional filtering and pagination
+func (r *SalesRepository) List(filter models.SalesRecordFilter) (*models.SalesRecordList, error) {
+	// Build WHERE clause
+	whereClause, args := buildFilterWhere(filter)
+
+	// Build ORDER BY clause
+	orderBy := buildListOrderBy(filter)
 
 	// Get total count
 	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM sales_records %s", whereClause)
 	var total int64
-	err := r.db.conn.QueryRow(countQuery, args...).Scan(&total)
+	err := r.q.QueryRow(countQuery, args...).Scan(&total)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get total count: %w", err)
 	}
@@ -244,15 +505,15 @@ func (r *SalesRepository) List(filter models.SalesRecordFilter) (*models.SalesRe
 
 	// Build main query
 	query := fmt.Sprintf(`
-		SELECT id, store, vendor, date, description, sale_price, commission, remaining, created_at, updated_at
+		SELECT %s
 		FROM sales_records
 		%s
 		%s
 		LIMIT ? OFFSET ?
-	`, whereClause, orderBy)
+	`, salesRecordColumns, whereClause, orderBy)
 
 	queryArgs := append(args, limit, offset)
-	rows, err := r.db.conn.Query(query, queryArgs...)
+	rows, err := r.q.Query(query, queryArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query sales records: %w", err)
 	}
@@ -261,19 +522,7 @@ func (r *SalesRepository) List(filter models.SalesRecordFilter) (*models.SalesRe
 	var records []models.SalesRecord
 	for rows.Next() {
 		var record models.SalesRecord
-		err := rows.Scan(
-			&record.ID,
-			&record.Store,
-			&record.Vendor,
-			&record.Date,
-			&record.Description,
-			&record.SalePrice,
-			&record.Commission,
-			&record.Remaining,
-			&record.CreatedAt,
-			&record.UpdatedAt,
-		)
-		if err != nil {
+		if err := scanSalesRecord(rows, &record); err != nil {
 			return nil, fmt.Errorf("failed to scan sales record: %w", err)
 		}
 		records = append(records, record)
@@ -296,82 +545,335 @@ func (r *SalesRepository) List(filter models.SalesRecordFilter) (*models.SalesRe
 	}, nil
 }
 
-// CreateBatch inserts multiple sales records in a single transaction
-func (r *SalesRepository) CreateBatch(records []models.CreateSalesRecordRequest) ([]models.SalesRecord, error) {
-	var createdRecords []models.SalesRecord
+// Each calls fn with every record matching filter, in the filter's order,
+// without loading them all into memory. Limit and offset are ignored.
+func (r *SalesRepository) Each(filter models.SalesRecordFilter, fn func(models.SalesRecord) error) error {
+	whereClause, args := buildFilterWhere(filter)
+	query := fmt.Sprintf("SELECT %s FROM sales_records %s %s", salesRecordColumns, whereClause, buildListOrderBy(filter))
+
+	rows, err := r.q.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query sales records: %w", err)
+	}
+	return eachSalesRecord(rows, fn)
+}
 
-	err := r.db.ExecTx(func(tx *sql.Tx) error {
-		// Build bulk insert query
-		if len(records) == 0 {
-			return nil
+// EachSpilled is Each with SQLite's temporary storage in a file while the
+// records are read, so sorting them does not hold them all in memory. The
+// query runs on a connection of its own, so it must not be called inside a
+// transaction.
+func (r *SalesRepository) EachSpilled(filter models.SalesRecordFilter, fn func(models.SalesRecord) error) error {
+	whereClause, args := buildFilterWhere(filter)
+	query := fmt.Sprintf("SELECT %s FROM sales_records %s %s", salesRecordColumns, whereClause, buildListOrderBy(filter))
+
+	ctx := context.Background()
+	conn, err := r.db.conn.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA temp_store = FILE"); err != nil {
+		return fmt.Errorf("failed to move temporary storage to a file: %w", err)
+	}
+	// The connection goes back to the pool with the setting every connection has
+	defer restoreConn(conn, "PRAGMA temp_store = MEMORY")
+
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query sales records: %w", err)
+	}
+	return eachSalesRecord(rows, fn)
+}
+
+// eachSalesRecord calls fn with every record in rows and closes them
+func eachSalesRecord(rows *sql.Rows, fn func(models.SalesRecord) error) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		var record models.SalesRecord
+		if err := scanSalesRecord(rows, &record); err != nil {
+			return fmt.Errorf("failed to scan sales record: %w", err)
+		}
+		if err := fn(record); err != nil {
+			return err
 		}
+	}
 
-		placeholders := make([]string, 0, len(records))
-		values := make([]interface{}, 0, len(records)*7)
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating sales records: %w", err)
+	}
+	return nil
+}
 
-		for _, record := range records {
-			// Parse the date string
-			date, err := time.Parse("2006-01-02", record.Date)
-			if err != nil {
-				return fmt.Errorf("invalid date format for record: %w", err)
+// CreateBatch creates multiple sales records in a single transaction.
+// Records are inserted in chunks of batchInsertChunkSize rows using multi-row
+// VALUES, and the created rows come back from the INSERT itself via RETURNING.
+func (r *SalesRepository) CreateBatch(records []models.CreateSalesRecordRequest) ([]models.SalesRecord, error) {
+	created, _, err := r.CreateBatchCounted(records)
+	return created, err
+}
+
+// CreateBatchCounted is CreateBatch that also returns how many of the records
+// updated one imported before with the same store and external id rather than
+// inserting a new one
+func (r *SalesRepository) CreateBatchCounted(records []models.CreateSalesRecordRequest) ([]models.SalesRecord, int, error) {
+	if len(records) == 0 {
+		return nil, 0, nil
+	}
+
+	createdRecords := make([]models.SalesRecord, 0, len(records))
+	updated := 0
+
+	err := r.execTx(func(tx *sql.Tx) error {
+		for start := 0; start < len(records); start += batchInsertChunkSize {
+			end := start + batchInsertChunkSize
+			if end > len(records) {
+				end = len(records)
 			}
 
-			placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?)")
-			values = append(values, record.Store, record.Vendor, date, record.Description, record.SalePrice, record.Commission, record.Remaining)
+			chunk, chunkUpdated, err := r.insertChunk(tx, records[start:end])
+			if err != nil {
+				return fmt.Errorf("failed to insert records %d-%d: %w", start+1, end, err)
+			}
+			createdRecords = append(createdRecords, chunk...)
+			updated += chunkUpdated
 		}
+		return nil
+	})
 
-		query := fmt.Sprintf(`
-			INSERT INTO sales_records (store, vendor, date, description, sale_price, commission, remaining)
-			VALUES %s
-		`, strings.Join(placeholders, ","))
+	if err != nil {
+		return nil, 0, err
+	}
 
-		_, err := tx.Exec(query, values...)
+	return createdRecords, updated, nil
+}
+
+// insertChunk inserts records with a single multi-row INSERT and returns the
+// stored rows in the order of records, with how many of them updated a record
+// already stored under the same store and external id
+func (r *SalesRepository) insertChunk(tx *sql.Tx, records []models.CreateSalesRecordRequest) ([]models.SalesRecord, int, error) {
+	defer r.timer.addInsert(time.Now())
+	placeholders := make([]string, 0, len(records))
+	values := make([]interface{}, 0, len(records)*16)
+
+	for i, record := range records {
+		recordValues, err := r.insertValues(record)
 		if err != nil {
-			return fmt.Errorf("failed to insert sales records: %w", err)
+			return nil, 0, fmt.Errorf("invalid date format for record %d: %w", i+1, err)
 		}
 
-		// Fetch all created records in a single query
-		// Get the records that were just inserted by ordering by ID DESC and limiting to the number of records
-		rows, err := tx.Query(`
-			SELECT id, store, vendor, date, description, sale_price, commission, remaining, created_at, updated_at
-			FROM sales_records
-			ORDER BY id DESC
-			LIMIT ?
-		`, len(records))
-		if err != nil {
-			return fmt.Errorf("failed to fetch created records: %w", err)
+		placeholders = append(placeholders, insertPlaceholders)
+		values = append(values, recordValues...)
+	}
+
+	// New rows get IDs above every stored one, so rows returned with a lower
+	// ID were updated
+	var lastID int64
+	if err := tx.QueryRow("SELECT COALESCE(MAX(id), 0) FROM sales_records").Scan(&lastID); err != nil {
+		return nil, 0, fmt.Errorf("failed to read the last record id: %w", err)
+	}
+
+	// RETURNING hands back the stored rows (including generated IDs and
+	// timestamps), so no follow-up SELECT is needed
+	rows, err := tx.Query(`
+		INSERT INTO sales_records (`+insertColumns+`)
+		VALUES `+strings.Join(placeholders, ",")+upsertOnExternalID+`
+		RETURNING `+salesRecordColumns, values...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to insert sales records: %w", err)
+	}
+	defer rows.Close()
+
+	var returned []models.SalesRecord
+	for rows.Next() {
+		var record models.SalesRecord
+		if err := scanSalesRecord(rows, &record); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan created record: %w", err)
 		}
-		defer rows.Close()
-
-		for rows.Next() {
-			var createdRecord models.SalesRecord
-			err := rows.Scan(
-				&createdRecord.ID,
-				&createdRecord.Store,
-				&createdRecord.Vendor,
-				&createdRecord.Date,
-				&createdRecord.Description,
-				&createdRecord.SalePrice,
-				&createdRecord.Commission,
-				&createdRecord.Remaining,
-				&createdRecord.CreatedAt,
-				&createdRecord.UpdatedAt,
-			)
+		returned = append(returned, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to insert sales records: %w", err)
+	}
+
+	created, err := r.matchReturned(records, returned)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// A record repeated within the chunk updates the row its first copy inserted
+	updated := 0
+	seen := make(map[int64]bool, len(created))
+	for _, record := range created {
+		if record.ID <= lastID || seen[record.ID] {
+			updated++
+		}
+		seen[record.ID] = true
+	}
+	return created, updated, nil
+}
+
+// externalKey identifies a record that re-importing updates in place
+type externalKey struct {
+	store, externalID string
+}
+
+// matchReturned puts the rows returned by insertChunk in the order of the
+// records they were written from. SQLite does not guarantee RETURNING order.
+// A record with an external id is matched by its store and id, since when it
+// was imported before its row keeps its earlier, lower ID. The other records
+// were all inserted, so their IDs follow insertion order.
+func (r *SalesRepository) matchReturned(records []models.CreateSalesRecordRequest, returned []models.SalesRecord) ([]models.SalesRecord, error) {
+	if len(returned) != len(records) {
+		return nil, fmt.Errorf("failed to insert sales records: %d rows returned for %d records", len(returned), len(records))
+	}
+
+	byKey := map[externalKey][]int{}
+	var inserted []int
+	for i, record := range records {
+		if record.ExternalID != nil && *record.ExternalID != "" {
+			key := externalKey{r.aliases.apply(record).Store, *record.ExternalID}
+			byKey[key] = append(byKey[key], i)
+		} else {
+			inserted = append(inserted, i)
+		}
+	}
+
+	var withoutKey []models.SalesRecord
+	ordered := make([]models.SalesRecord, len(records))
+	for _, record := range returned {
+		if record.ExternalID == nil {
+			withoutKey = append(withoutKey, record)
+			continue
+		}
+		key := externalKey{record.Store, *record.ExternalID}
+		indexes := byKey[key]
+		if len(indexes) == 0 {
+			return nil, fmt.Errorf("failed to insert sales records: unexpected row for external id %q", key.externalID)
+		}
+		ordered[indexes[0]] = record
+		byKey[key] = indexes[1:]
+	}
+
+	sort.Slice(withoutKey, func(a, b int) bool { return withoutKey[a].ID < withoutKey[b].ID })
+	if len(withoutKey) != len(inserted) {
+		return nil, fmt.Errorf("failed to insert sales records: %d rows without an external id returned for %d records", len(withoutKey), len(inserted))
+	}
+	for i, index := range inserted {
+		ordered[index] = withoutKey[i]
+	}
+	return ordered, nil
+}
+
+// CreateBatchPartial inserts records in a single transaction, skipping rows that
+// fail instead of aborting the batch. Failed rows are returned with their index
+// in records and the reason they were rejected.
+func (r *SalesRepository) CreateBatchPartial(records []models.CreateSalesRecordRequest) ([]models.SalesRecord, []models.BatchRowError, error) {
+	var createdRecords []models.SalesRecord
+	var failures []models.BatchRowError
+
+	err := r.execTx(func(tx *sql.Tx) error {
+		txRepo := r.WithTx(tx)
+		for i, record := range records {
+			// A failed INSERT only rolls back its own statement, so the rest of
+			// the transaction is unaffected
+			created, err := txRepo.Create(record)
 			if err != nil {
-				return fmt.Errorf("failed to scan created record: %w", err)
+				failures = append(failures, models.BatchRowError{
+					Index:  i,
+					Record: record,
+					Reason: err.Error(),
+				})
+				continue
 			}
-			createdRecords = append(createdRecords, createdRecord)
+			createdRecords = append(createdRecords, *created)
 		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return createdRecords, failures, nil
+}
+
+// Upsert inserts records whose store and external id are not yet in the
+// database and updates existing ones whose fields changed, all in a single
+// transaction. Records without an external id are always inserted.
+func (r *SalesRepository) Upsert(records []models.CreateSalesRecordRequest) (*models.UpsertResult, error) {
+	result := &models.UpsertResult{}
 
-		if err := rows.Err(); err != nil {
-			return fmt.Errorf("error iterating created records: %w", err)
+	err := r.execTx(func(tx *sql.Tx) error {
+		txRepo := r.WithTx(tx)
+
+		lookup, err := tx.Prepare("SELECT " + salesRecordColumns + " FROM sales_records WHERE store = ? AND external_id = ?")
+		if err != nil {
+			return fmt.Errorf("failed to prepare external id lookup: %w", err)
 		}
+		defer lookup.Close()
 
-		// Reverse the slice to maintain insertion order
-		for i, j := 0, len(createdRecords)-1; i < j; i, j = i+1, j-1 {
-			createdRecords[i], createdRecords[j] = createdRecords[j], createdRecords[i]
+		update, err := tx.Prepare(`
+			UPDATE sales_records
+			SET vendor = ?, date = ?, description = ?, product_key = ?, sale_price = ?,
+				commission = ?, remaining = ?, is_return = ?, currency = ?, metadata = ?,
+				cost = COALESCE(?, cost), category = COALESCE(?, category),
+				import_run_id = COALESCE(?, import_run_id), source_row = COALESCE(?, source_row),
+				updated_at = CURRENT_TIMESTAMP
+			WHERE id = ?`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare upsert update: %w", err)
 		}
+		defer update.Close()
+
+		for i, record := range records {
+			// The lookup and comparison need the names the record is filed under
+			record = r.aliases.apply(record)
+			if record.ExternalID == nil || *record.ExternalID == "" {
+				if _, err := txRepo.Create(record); err != nil {
+					return fmt.Errorf("record %d: %w", i+1, err)
+				}
+				result.Inserted++
+				continue
+			}
+
+			looking := time.Now()
+			var existing models.SalesRecord
+			err := scanSalesRecord(lookup.QueryRow(record.Store, *record.ExternalID), &existing)
+			matches := err == nil && recordMatches(existing, record)
+			r.timer.addDedupe(looking)
+			if err == sql.ErrNoRows {
+				if _, err := txRepo.Create(record); err != nil {
+					return fmt.Errorf("record %d: %w", i+1, err)
+				}
+				result.Inserted++
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("record %d: failed to look up external id: %w", i+1, err)
+			}
+
+			if matches {
+				result.Unchanged++
+				continue
+			}
 
+			writing := time.Now()
+			values, err := r.insertValues(record)
+			if err != nil {
+				return fmt.Errorf("record %d: invalid date format: %w", i+1, err)
+			}
+			// insertValues starts with store and ends with external_id, which
+			// identify the row and are not updated
+			args := append(values[1:len(values)-1], existing.ID)
+			if _, err := update.Exec(args...); err != nil {
+				return fmt.Errorf("record %d: failed to update sales record: %w", i+1, err)
+			}
+			r.timer.addInsert(writing)
+			result.Updated++
+		}
 		return nil
 	})
 
@@ -379,7 +881,233 @@ func (r *SalesRepository) CreateBatch(records []models.CreateSalesRecordRequest)
 		return nil, err
 	}
 
-	return createdRecords, nil
+	return result, nil
+}
+
+// recordMatches reports whether an existing record already holds the values
+// of an incoming request. A cost or category the request leaves out keeps the
+// existing one, so it never counts as a change.
+func recordMatches(existing models.SalesRecord, record models.CreateSalesRecordRequest) bool {
+	return existing.Vendor == record.Vendor &&
+		existing.Date.String() == record.Date &&
+		existing.Description == record.Description &&
+		existing.SalePrice == record.SalePrice &&
+		equalFloatPtr(existing.Commission, record.Commission) &&
+		equalFloatPtr(existing.Remaining, record.Remaining) &&
+		existing.IsReturn == record.IsReturn &&
+		equalStringPtr(existing.Currency, record.Currency) &&
+		existing.Metadata.Equal(record.Metadata) &&
+		(record.Cost == nil || equalFloatPtr(existing.Cost, record.Cost)) &&
+		(record.Category == nil || *record.Category == "" || equalStringPtr(existing.Category, record.Category))
+}
+
+// equalStringPtr compares two optional strings, treating nil and empty as equal
+func equalStringPtr(a, b *string) bool {
+	var x, y string
+	if a != nil {
+		x = *a
+	}
+	if b != nil {
+		y = *b
+	}
+	return x == y
+}
+
+// equalFloatPtr compares two optional amounts, treating nil as unknown
+func equalFloatPtr(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// Enrich applies the cost, category and tags of req to the records matching
+// its filter and returns the number of records it changed. Records that
+// already hold the new values are not counted or touched.
+func (r *SalesRepository) Enrich(req models.EnrichmentRequest) (int64, error) {
+	type assignment struct {
+		column string
+		expr   string
+		args   []interface{}
+	}
+	var assignments []assignment
+
+	if req.Cost != nil {
+		expr := "?"
+		if req.OnlyMissing {
+			expr = "COALESCE(cost, ?)"
+		}
+		assignments = append(assignments, assignment{"cost", expr, []interface{}{*req.Cost}})
+	}
+	if req.Category != nil {
+		expr := "NULLIF(?, '')"
+		if req.OnlyMissing {
+			expr = "COALESCE(category, NULLIF(?, ''))"
+		}
+		assignments = append(assignments, assignment{"category", expr, []interface{}{*req.Category}})
+	}
+	if len(req.AddTags) > 0 || len(req.RemoveTags) > 0 {
+		// Tags are rebuilt sorted, without the removed ones and with the added ones
+		expr := `(
+			SELECT NULLIF(json_group_array(value), '[]') FROM (
+				SELECT value FROM json_each(COALESCE(tags, '[]'))
+				WHERE value NOT IN (SELECT value FROM json_each(?))
+				UNION
+				SELECT value FROM json_each(?)
+				ORDER BY value
+			)
+		)`
+		assignments = append(assignments, assignment{"tags", expr, []interface{}{models.Tags(req.RemoveTags), models.Tags(req.AddTags)}})
+	}
+	if len(assignments) == 0 {
+		return 0, nil
+	}
+
+	var setParts, changed []string
+	var args, changedArgs []interface{}
+	for _, a := range assignments {
+		setParts = append(setParts, a.column+" = "+a.expr)
+		args = append(args, a.args...)
+		changed = append(changed, a.column+" IS NOT "+a.expr)
+		changedArgs = append(changedArgs, a.args...)
+	}
+	setParts = append(setParts, "updated_at = CURRENT_TIMESTAMP")
+
+	whereClause, whereArgs := buildFilterWhere(req.Filter)
+	if whereClause == "" {
+		whereClause = "WHERE "
+	} else {
+		whereClause += " AND "
+	}
+	whereClause += "(" + strings.Join(changed, " OR ") + ")"
+	args = append(append(args, whereArgs...), changedArgs...)
+
+	query := fmt.Sprintf("UPDATE sales_records SET %s %s", strings.Join(setParts, ", "), whereClause)
+	result, err := r.q.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enrich sales records: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return updated, nil
+}
+
+// SetRemaining stores the remaining amount of a sales record
+func (r *SalesRepository) SetRemaining(id int64, remaining float64) error {
+	result, err := r.q.Exec("UPDATE sales_records SET remaining = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", remaining, id)
+	if err != nil {
+		return fmt.Errorf("failed to set remaining amount: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("sales record with ID %d %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+// SetCategoryByProductKey sets the category of the records with a product
+// key and returns the number of records it changed. With onlyMissing,
+// records that already have a category keep it.
+func (r *SalesRepository) SetCategoryByProductKey(productKey, category string, onlyMissing bool) (int64, error) {
+	query := `
+		UPDATE sales_records SET category = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE product_key = ? AND category IS NOT ?`
+	if onlyMissing {
+		query += " AND category IS NULL"
+	}
+
+	result, err := r.q.Exec(query, category, productKey, category)
+	if err != nil {
+		return 0, fmt.Errorf("failed to set category: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return updated, nil
+}
+
+// BackfillProductKeys computes product keys for records that don't have one yet,
+// such as rows created before the product_key column existed
+func (r *SalesRepository) BackfillProductKeys() (int, error) {
+	rows, err := r.q.Query("SELECT id, description FROM sales_records WHERE product_key = ''")
+	if err != nil {
+		return 0, fmt.Errorf("failed to query records without product keys: %w", err)
+	}
+
+	keys := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var description string
+		if err := rows.Scan(&id, &description); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan record description: %w", err)
+		}
+		keys[id] = models.ProductKey(description)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating record descriptions: %w", err)
+	}
+
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	err = r.execTx(func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare("UPDATE sales_records SET product_key = ? WHERE id = ?")
+		if err != nil {
+			return fmt.Errorf("failed to prepare product key update: %w", err)
+		}
+		defer stmt.Close()
+
+		for id, key := range keys {
+			if _, err := stmt.Exec(key, id); err != nil {
+				return fmt.Errorf("failed to update product key for record %d: %w", id, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(keys), nil
+}
+
+// Count returns the number of records matching filter, ignoring its limit and
+// offset
+func (r *SalesRepository) Count(filter models.SalesRecordFilter) (int64, error) {
+	whereClause, args := buildFilterWhere(filter)
+
+	var count int64
+	query := fmt.Sprintf("SELECT COUNT(*) FROM sales_records %s", whereClause)
+	if err := r.q.QueryRow(query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count sales records: %w", err)
+	}
+	return count, nil
+}
+
+// CountSince returns the number of records dated on or after since
+func (r *SalesRepository) CountSince(since time.Time) (int64, error) {
+	whereClause, args := buildFilterWhere(models.SalesRecordFilter{DateFrom: &since})
+
+	var count int64
+	err := r.q.QueryRow("SELECT COUNT(*) FROM sales_records "+whereClause, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recent records: %w", err)
+	}
+	return count, nil
 }
 
 // GetStats returns basic statistics about the sales records
@@ -389,8 +1117,9 @@ func (r *SalesRepository) GetStats() (*models.DatabaseStats, error) {
 			COUNT(*) as total_records,
 			COALESCE(MIN(date), '') as earliest_date,
 			COALESCE(MAX(date), '') as latest_date,
-			COALESCE(SUM(sale_price), 0) as total_sales,
-			COALESCE(AVG(sale_price), 0) as avg_sale_price,
+			COALESCE(SUM(CASE WHEN is_return = 0 THEN sale_price END), 0) as gross_sales,
+			COALESCE(SUM(CASE WHEN is_return = 1 THEN sale_price END), 0) as total_returns,
+			COALESCE(AVG(CASE WHEN is_return = 0 THEN sale_price END), 0) as avg_sale_price,
 			COUNT(DISTINCT store) as unique_stores,
 			COUNT(DISTINCT vendor) as unique_vendors,
 			COALESCE(MAX(updated_at), '') as last_updated
@@ -400,11 +1129,12 @@ func (r *SalesRepository) GetStats() (*models.DatabaseStats, error) {
 	var stats models.DatabaseStats
 	var earliestDateStr, latestDateStr, lastUpdatedStr string
 	
-	err := r.db.conn.QueryRow(query).Scan(
+	err := r.q.QueryRow(query).Scan(
 		&stats.TotalRecords,
 		&earliestDateStr,
 		&latestDateStr,
-		&stats.TotalSales,
+		&stats.GrossSales,
+		&stats.TotalReturns,
 		&stats.AvgSalePrice,
 		&stats.UniqueStores,
 		&stats.UniqueVendors,
@@ -414,15 +1144,16 @@ func (r *SalesRepository) GetStats() (*models.DatabaseStats, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get database stats: %w", err)
 	}
+	stats.TotalSales = stats.GrossSales - stats.TotalReturns
 
 	// Parse date strings
 	if earliestDateStr != "" {
-		if parsed, err := time.Parse("2006-01-02", earliestDateStr); err == nil {
+		if parsed, err := models.ParseDate(earliestDateStr); err == nil {
 			stats.EarliestDate = parsed
 		}
 	}
 	if latestDateStr != "" {
-		if parsed, err := time.Parse("2006-01-02", latestDateStr); err == nil {
+		if parsed, err := models.ParseDate(latestDateStr); err == nil {
 			stats.LatestDate = parsed
 		}
 	}
@@ -2,7 +2,16 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"sales-track/internal/models"
 )
@@ -11,8 +20,31 @@ import (
 // It combines multiple repositories and provides a unified API
 type Service struct {
 	db                *DB
+	tx                *sql.Tx // non-nil when the service is bound to a transaction
+	cache             *queryCache
+	aggregates        *reportAggregates
+	feed              *changeFeed
+	maintenance       *maintenanceLock
+	writes            *writeQueue
+	pending           *[]changeEvent // events awaiting commit; non-nil when bound to a transaction
+	rowImport         bool           // CreateSalesRecord leaves its event to CompleteRowImport; set by ForRowImport
 	salesRepo         *SalesRepository
 	reportingRepo     *ReportingRepository
+	exchangeRepo      *ExchangeRateRepository
+	commissionRepo    *CommissionRepository
+	payoutRepo        *PayoutRepository
+	aliasRepo         *AliasRepository
+	snapshotRepo      *SnapshotRepository
+	adjustmentRepo    *AdjustmentRepository
+	feeRepo           *FeeRepository
+	quarantineRepo    *QuarantineRepository
+	notificationRepo  *NotificationRepository
+	apiTokenRepo      *APITokenRepository
+	periodRepo        *PeriodRepository
+	importRepo        *ImportHistoryRepository
+	retentionRepo     *RetentionRepository
+	settingsRepo      *SettingsRepository
+	auditRepo         *AuditRepository
 }
 
 // NewService creates a new database service
@@ -22,15 +54,56 @@ func NewService(config Config) (*Service, error) {
 		return nil, fmt.Errorf("failed to create database connection: %w", err)
 	}
 
-	return &Service{
+	service := &Service{
 		db:                db,
 		salesRepo:         NewSalesRepository(db),
 		reportingRepo:     NewReportingRepository(db),
-	}, nil
+		exchangeRepo:      NewExchangeRateRepository(db),
+		commissionRepo:    NewCommissionRepository(db),
+		payoutRepo:        NewPayoutRepository(db),
+		aliasRepo:         NewAliasRepository(db),
+		snapshotRepo:      NewSnapshotRepository(db),
+		adjustmentRepo:    NewAdjustmentRepository(db),
+		feeRepo:           NewFeeRepository(db),
+		quarantineRepo:    NewQuarantineRepository(db),
+		notificationRepo:  NewNotificationRepository(db),
+		apiTokenRepo:      NewAPITokenRepository(db),
+		periodRepo:        NewPeriodRepository(db),
+		importRepo:        NewImportHistoryRepository(db),
+		retentionRepo:     NewRetentionRepository(db),
+		settingsRepo:      NewSettingsRepository(db),
+		auditRepo:         NewAuditRepository(db),
+		cache:             newQueryCache(defaultCacheCapacity),
+		aggregates:        &reportAggregates{},
+		feed:              &changeFeed{},
+		maintenance:       &maintenanceLock{},
+		writes:            newWriteQueue(),
+	}
+
+	// Compute product keys for rows imported before keys existed
+	if config.AutoMigrate {
+		if _, err := service.salesRepo.BackfillProductKeys(); err != nil {
+			service.Close()
+			return nil, fmt.Errorf("failed to backfill product keys: %w", err)
+		}
+	}
+
+	// Stop queries that run past their timeouts from here on, once migrations
+	// and the backfill are done. An unmigrated database without settings uses
+	// the defaults.
+	timeouts, err := service.GetQueryTimeouts()
+	if err != nil && config.AutoMigrate {
+		service.Close()
+		return nil, fmt.Errorf("failed to load query timeouts: %w", err)
+	}
+	db.SetQueryTimeouts(timeouts)
+
+	return service, nil
 }
 
 // Close closes the database connection
 func (s *Service) Close() error {
+	s.writes.close()
 	return s.db.Close()
 }
 
@@ -51,7 +124,130 @@ func (s *Service) GetDB() *DB {
 
 // CreateSalesRecord creates a new sales record
 func (s *Service) CreateSalesRecord(record models.CreateSalesRecordRequest) (*models.SalesRecord, error) {
-	return s.salesRepo.Create(record)
+	if err := validateMetadata(record.Metadata); err != nil {
+		return nil, err
+	}
+	if err := validateCostAndCategory(record.Cost, record.Category); err != nil {
+		return nil, err
+	}
+
+	release, err := s.beginWrite()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	defer s.invalidateCache()
+
+	created, err := s.salesRepo.Create(record)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.rowImport {
+		s.emitChange(EventRecordCreated, RecordChangeEvent{ID: created.ID, Record: created})
+	}
+	return created, nil
+}
+
+// ForRowImport returns a copy of the service whose CreateSalesRecord emits no
+// record.created event, for imports that write their rows one at a time. They
+// announce themselves once with CompleteRowImport, as the bulk writes do.
+func (s *Service) ForRowImport() *Service {
+	bound := *s
+	bound.rowImport = true
+	return &bound
+}
+
+// CompleteRowImport emits the import.completed event of an import written
+// through ForRowImport. Inside a transaction it waits for the commit.
+func (s *Service) CompleteRowImport(event ImportCompletedEvent) {
+	s.emitChange(EventImportCompleted, event)
+}
+
+// ForImport returns a copy of the service that attributes the sales records
+// it creates or updates to an import run, so they can be traced back to it
+func (s *Service) ForImport(runID int64) *Service {
+	bound := *s
+	bound.salesRepo = s.salesRepo.ForImport(runID)
+	return &bound
+}
+
+// ImportTimings returns the time a service bound by ForImport has spent
+// looking up records already imported and writing records. Parse and
+// validate times are left for the caller, which ran the parser.
+func (s *Service) ImportTimings() models.ImportTimings {
+	return s.salesRepo.timer.timings()
+}
+
+// WithNameAliases returns a copy of the service that files the sales records
+// it creates or updates under the canonical names of their stores and
+// vendors, using the aliases saved when it is called
+func (s *Service) WithNameAliases() (*Service, error) {
+	aliases, err := s.aliasRepo.Load()
+	if err != nil {
+		return nil, err
+	}
+	bound := *s
+	bound.salesRepo = s.salesRepo.WithAliases(aliases)
+	return &bound, nil
+}
+
+// ApplyNameAliases returns records with their stores and vendors under the
+// canonical names the saved aliases file them under, as they would be stored
+func (s *Service) ApplyNameAliases(records []models.CreateSalesRecordRequest) ([]models.CreateSalesRecordRequest, error) {
+	aliases, err := s.aliasRepo.Load()
+	if err != nil {
+		return nil, err
+	}
+	applied := make([]models.CreateSalesRecordRequest, len(records))
+	for i, record := range records {
+		applied[i] = aliases.apply(record)
+	}
+	return applied, nil
+}
+
+// GetRecordProvenance traces a sales record back to where its numbers came
+// from: the import that last wrote it, the source row it was parsed from, its
+// adjustments and the changes made to it since
+func (s *Service) GetRecordProvenance(id int64) (*models.RecordProvenance, error) {
+	record, err := s.salesRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	importRunID, sourceRow, err := s.salesRepo.GetProvenance(id)
+	if err != nil {
+		return nil, err
+	}
+
+	provenance := &models.RecordProvenance{
+		Record:      *record,
+		SourceRow:   sourceRow,
+		Adjustments: []models.SalesAdjustment{},
+		History:     []models.AuditEntry{},
+	}
+	if importRunID != nil {
+		if provenance.Import, err = s.importRepo.GetByID(*importRunID); err != nil {
+			return nil, err
+		}
+		if provenance.HasSource, err = s.importRepo.HasSource(*importRunID); err != nil {
+			return nil, err
+		}
+	}
+
+	adjustments, err := s.adjustmentRepo.ListForRecord(id)
+	if err != nil {
+		return nil, err
+	}
+	provenance.Adjustments = append(provenance.Adjustments, adjustments...)
+
+	history, err := s.auditRepo.ListForEntity("sales_record", id)
+	if err != nil {
+		return nil, err
+	}
+	provenance.History = append(provenance.History, history...)
+
+	return provenance, nil
 }
 
 // GetSalesRecord retrieves a sales record by ID
@@ -61,75 +257,2215 @@ func (s *Service) GetSalesRecord(id int64) (*models.SalesRecord, error) {
 
 // UpdateSalesRecord updates an existing sales record
 func (s *Service) UpdateSalesRecord(id int64, updates models.UpdateSalesRecordRequest) (*models.SalesRecord, error) {
-	return s.salesRepo.Update(id, updates)
+	if err := validateMetadata(updates.Metadata); err != nil {
+		return nil, err
+	}
+	if err := validateCostAndCategory(updates.Cost, updates.Category); err != nil {
+		return nil, err
+	}
+	if err := validateTags(updates.Tags); err != nil {
+		return nil, err
+	}
+
+	// The change is audited with the record, so its history can be traced
+	var updated *models.SalesRecord
+	err := s.ExecTx(func(tx *Service) error {
+		before, err := tx.salesRepo.GetByID(id)
+		if err != nil {
+			return err
+		}
+		updated, err = tx.salesRepo.Update(id, updates)
+		if err != nil {
+			return err
+		}
+
+		if changes := describeRecordChanges(*before, *updated); changes != "" {
+			entityType := "sales_record"
+			if _, err := tx.auditRepo.Create(models.AuditEntry{
+				Action:     models.AuditRecordUpdated,
+				EntityType: &entityType,
+				EntityID:   &id,
+				Details:    changes,
+			}); err != nil {
+				return err
+			}
+		}
+
+		tx.emitChange(EventRecordUpdated, RecordChangeEvent{ID: id, Record: updated})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// DeleteSalesRecord removes a sales record
+func (s *Service) DeleteSalesRecord(id int64) error {
+	release, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	defer s.invalidateCache()
+
+	if err := s.salesRepo.Delete(id); err != nil {
+		return err
+	}
+	s.emitChange(EventRecordDeleted, RecordChangeEvent{ID: id})
+	return nil
+}
+
+// ListSalesRecords retrieves sales records with filtering and pagination
+func (s *Service) ListSalesRecords(filter models.SalesRecordFilter) (*models.SalesRecordList, error) {
+	return s.salesRepo.List(filter)
+}
+
+// CountSalesRecords returns the number of records matching filter, ignoring
+// its limit and offset
+func (s *Service) CountSalesRecords(filter models.SalesRecordFilter) (int64, error) {
+	return s.salesRepo.Count(filter)
+}
+
+// EachSalesRecord calls fn with every record matching filter, for exports too
+// large to list in pages
+func (s *Service) EachSalesRecord(filter models.SalesRecordFilter, fn func(models.SalesRecord) error) error {
+	return s.salesRepo.Each(filter, fn)
+}
+
+// EachSalesRecordSpilled is EachSalesRecord for exports too large to sort in
+// memory: SQLite keeps the sorted records in a temporary file instead. Inside
+// a transaction the records are read as by EachSalesRecord.
+func (s *Service) EachSalesRecordSpilled(filter models.SalesRecordFilter, fn func(models.SalesRecord) error) error {
+	if s.tx != nil {
+		return s.salesRepo.Each(filter, fn)
+	}
+	return s.salesRepo.EachSpilled(filter, fn)
+}
+
+// CreateSalesRecordsBatch creates multiple sales records in a single transaction
+func (s *Service) CreateSalesRecordsBatch(records []models.CreateSalesRecordRequest) ([]models.SalesRecord, error) {
+	release, err := s.beginWrite()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	defer s.invalidateCache()
+
+	created, updated, err := s.salesRepo.CreateBatchCounted(records)
+	if err != nil {
+		return nil, err
+	}
+
+	s.emitChange(EventImportCompleted, ImportCompletedEvent{Inserted: len(created) - updated, Updated: updated})
+	return created, nil
+}
+
+// CreateSalesRecordsBatchPartial creates the valid records from a batch and
+// returns per-row failures for the rest, rather than aborting on the first bad row
+func (s *Service) CreateSalesRecordsBatchPartial(records []models.CreateSalesRecordRequest) ([]models.SalesRecord, []models.BatchRowError, error) {
+	release, err := s.beginWrite()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	defer s.invalidateCache()
+
+	var failures []models.BatchRowError
+	var validRecords []models.CreateSalesRecordRequest
+	var validIndexes []int
+
+	for i, record := range records {
+		if err := validateSalesRecord(record); err != nil {
+			failures = append(failures, models.BatchRowError{Index: i, Record: record, Reason: err.Error()})
+			continue
+		}
+		validRecords = append(validRecords, record)
+		validIndexes = append(validIndexes, i)
+	}
+
+	createdRecords, insertFailures, err := s.salesRepo.CreateBatchPartial(validRecords)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to import sales records: %w", err)
+	}
+
+	// Map insert failures back to their position in the original batch
+	for _, failure := range insertFailures {
+		failure.Index = validIndexes[failure.Index]
+		failures = append(failures, failure)
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].Index < failures[j].Index })
+
+	s.emitChange(EventImportCompleted, ImportCompletedEvent{Inserted: len(createdRecords), Failed: len(failures)})
+	return createdRecords, failures, nil
+}
+
+// UpsertSalesRecords inserts new records and updates changed ones, matching
+// on store and external id, in a single transaction. Any invalid record aborts
+// the whole upsert.
+func (s *Service) UpsertSalesRecords(records []models.CreateSalesRecordRequest) (*models.UpsertResult, error) {
+	release, err := s.beginWrite()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	defer s.invalidateCache()
+
+	for i, record := range records {
+		if err := validateSalesRecord(record); err != nil {
+			return nil, fmt.Errorf("record %d: %w", i+1, err)
+		}
+	}
+
+	result, err := s.salesRepo.Upsert(records)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert sales records: %w", err)
+	}
+
+	s.emitChange(EventImportCompleted, ImportCompletedEvent{
+		Inserted:  result.Inserted,
+		Updated:   result.Updated,
+		Unchanged: result.Unchanged,
+	})
+	return result, nil
+}
+
+// CreateSalesRecordsStream inserts records received from the channel in chunks
+// of chunkSize until it is closed, so only one chunk is held in memory at a time.
+// It returns the number of records written, inserted or updating one imported
+// before with the same store and external id. progress, if non-nil, is called
+// with the running totals of each after each chunk.
+func (s *Service) CreateSalesRecordsStream(records <-chan models.CreateSalesRecordRequest, chunkSize int, progress func(inserted, updated int)) (int, error) {
+	release, err := s.beginWrite()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	defer s.invalidateCache()
+
+	if chunkSize <= 0 {
+		chunkSize = batchInsertChunkSize
+	}
+
+	inserted, updated := 0, 0
+	chunk := make([]models.CreateSalesRecordRequest, 0, chunkSize)
+
+	// Chunks committed before a failure stay in the database when the stream is
+	// not inside a transaction, so they are still announced
+	defer func() {
+		if inserted+updated > 0 {
+			s.emitChange(EventImportCompleted, ImportCompletedEvent{Inserted: inserted, Updated: updated})
+		}
+	}()
+
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		written := inserted + updated
+		created, chunkUpdated, err := s.salesRepo.CreateBatchCounted(chunk)
+		if err != nil {
+			return fmt.Errorf("failed to insert records %d-%d: %w", written+1, written+len(chunk), err)
+		}
+		inserted += len(created) - chunkUpdated
+		updated += chunkUpdated
+		chunk = chunk[:0]
+		if progress != nil {
+			progress(inserted, updated)
+		}
+		return nil
+	}
+
+	for record := range records {
+		chunk = append(chunk, record)
+		if len(chunk) == chunkSize {
+			if err := flush(); err != nil {
+				return inserted + updated, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return inserted + updated, err
+	}
+	return inserted + updated, nil
+}
+
+// GetDatabaseStats returns overall database statistics.
+// Results are cached until the next write through this service.
+func (s *Service) GetDatabaseStats() (*models.DatabaseStats, error) {
+	value, err := s.cached("stats", func() (interface{}, error) {
+		stats, err := s.salesRepo.GetStats()
+		if err != nil {
+			return nil, err
+		}
+		return *stats, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := value.(models.DatabaseStats)
+	return &stats, nil
+}
+
+// GetRecentRecordCount returns the number of records dated within the last
+// days days. Results are cached until the next write or the next calendar day.
+func (s *Service) GetRecentRecordCount(days int) (int64, error) {
+	since := time.Now().AddDate(0, 0, -days)
+	key := fmt.Sprintf("recent:%d:%s", days, time.Now().Format("2006-01-02"))
+
+	value, err := s.cached(key, func() (interface{}, error) {
+		return s.salesRepo.CountSince(since)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return value.(int64), nil
+}
+
+// cached returns the cached value for key, loading and caching it on a miss.
+// Services bound to a transaction bypass the cache, since they can see
+// uncommitted writes.
+func (s *Service) cached(key string, load func() (interface{}, error)) (interface{}, error) {
+	if s.cache == nil || s.tx != nil {
+		return load()
+	}
+
+	value, generation, ok := s.cache.get(key)
+	if ok {
+		return value, nil
+	}
+
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+	s.cache.put(key, value, generation)
+	return value, nil
+}
+
+// invalidateCache drops cached query results after a write
+func (s *Service) invalidateCache() {
+	if s.cache != nil {
+		s.cache.invalidate()
+	}
+}
+
+// ===== REPORTING OPERATIONS =====
+
+// GetYearlySummary returns yearly sales summary
+func (s *Service) GetYearlySummary() ([]models.YearlySummary, error) {
+	return s.reportingRepo.GetYearlySummary()
+}
+
+// GetMonthlySummary returns monthly sales summary, optionally filtered by year
+func (s *Service) GetMonthlySummary(year *string) ([]models.MonthlySummary, error) {
+	summaries, err := s.reportingRepo.GetMonthlySummary(year)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.flagClosedMonths(summaries); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// GetDailySummary returns daily sales summary, optionally filtered by year and month
+func (s *Service) GetDailySummary(year *string, month *string) ([]models.DailySummary, error) {
+	return s.reportingRepo.GetDailySummary(year, month)
+}
+
+// GetStorePerformance returns store performance analytics, calculated from
+// the pre-aggregated sales in report performance mode
+func (s *Service) GetStorePerformance() ([]models.StorePerformance, error) {
+	reports, err := s.heavyReports()
+	if err != nil {
+		return nil, err
+	}
+	return reports.GetStorePerformance()
+}
+
+// GetVendorPerformance returns vendor performance analytics, calculated from
+// the pre-aggregated sales in report performance mode
+func (s *Service) GetVendorPerformance() ([]models.VendorPerformance, error) {
+	reports, err := s.heavyReports()
+	if err != nil {
+		return nil, err
+	}
+	return reports.GetVendorPerformance()
+}
+
+// GetPivotTableData returns hierarchical data for pivot table display,
+// calculated from the pre-aggregated sales in report performance mode
+func (s *Service) GetPivotTableData(year *string) (*PivotTableData, error) {
+	reports, err := s.heavyReports()
+	if err != nil {
+		return nil, err
+	}
+	return reports.GetPivotTableData(year)
+}
+
+// GetDrillDownData returns detailed records for a specific time period
+func (s *Service) GetDrillDownData(year string, month *string, day *string) ([]models.SalesRecord, error) {
+	return s.reportingRepo.GetDrillDownData(year, month, day)
+}
+
+// GetDrillDownTable returns the records of a year, month or day as a table of
+// the requested columns, sorted as requested, so a grid can show them as is
+func (s *Service) GetDrillDownTable(req models.DrillDownRequest) (*models.DrillDownTable, error) {
+	req, err := validateDrillDownRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return s.reportingRepo.GetDrillDownTable(req)
+}
+
+// GetDrillDownBatch returns the records of several periods in one call, each
+// as the rows GetDrillDownTable would return for it
+func (s *Service) GetDrillDownBatch(req models.DrillDownBatchRequest) (*models.DrillDownBatch, error) {
+	if len(req.Periods) == 0 {
+		return nil, invalidf("at least one period is required")
+	}
+	if len(req.Periods) > models.MaxDrillDownPeriods {
+		return nil, invalidf("at most %d periods can be requested at once", models.MaxDrillDownPeriods)
+	}
+
+	batch := &models.DrillDownBatch{Groups: make([]models.DrillDownGroup, 0, len(req.Periods))}
+	seen := make(map[string]bool)
+	for i, period := range req.Periods {
+		periodReq, err := validateDrillDownRequest(models.DrillDownRequest{
+			Year:      period.Year,
+			Month:     period.Month,
+			Day:       period.Day,
+			SortBy:    req.SortBy,
+			SortOrder: req.SortOrder,
+			Columns:   req.Columns,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("period %d: %w", i+1, err)
+		}
+
+		key := periodReq.Year
+		if periodReq.Month != nil {
+			key += "-" + *periodReq.Month
+		}
+		if periodReq.Day != nil {
+			key += "-" + *periodReq.Day
+		}
+		if seen[key] {
+			return nil, invalidf("period %s is requested more than once", key)
+		}
+		seen[key] = true
+
+		table, err := s.reportingRepo.GetDrillDownTable(periodReq)
+		if err != nil {
+			return nil, err
+		}
+		batch.Columns = table.Columns
+		batch.Groups = append(batch.Groups, models.DrillDownGroup{Period: key, Rows: table.Rows})
+	}
+	return batch, nil
+}
+
+// GetTopProducts returns the best-selling products grouped by canonical product
+// key. In report performance mode they are estimated from a sample of the
+// records and marked as estimated.
+func (s *Service) GetTopProducts(limit int) ([]models.ProductSummary, error) {
+	status, err := s.GetReportPerformanceStatus()
+	if err != nil {
+		return nil, err
+	}
+	if status.Active && status.RecordCount > status.Config.SampleSize {
+		return s.reportingRepo.GetTopProductsSampled(limit, status.Config.SampleSize, status.RecordCount)
+	}
+	return s.reportingRepo.GetTopProducts(limit)
+}
+
+// GetCustomSummary returns custom aggregated data
+func (s *Service) GetCustomSummary(groupBy string, year *string, store *string, vendor *string) ([]models.SalesSummary, error) {
+	return s.GetCustomSummaryWithFilter(models.CustomSummaryRequest{
+		GroupBy: groupBy,
+		Year:    year,
+		Filter:  models.SalesRecordFilter{Store: store, Vendor: vendor},
+	})
+}
+
+// GetCustomSummaryWithFilter returns aggregated data for the records matching
+// a filter, limited to the groups reaching the request's thresholds. Weeks
+// start on the saved week start day.
+func (s *Service) GetCustomSummaryWithFilter(req models.CustomSummaryRequest) ([]models.SalesSummary, error) {
+	filter := req.Filter
+	if filter.DateFrom != nil && filter.DateTo != nil && filter.DateFrom.After(*filter.DateTo) {
+		return nil, invalidf("date_from must not be after date_to")
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return nil, invalidf("min_price must not be above max_price")
+	}
+	var err error
+	if req.DateBasis, err = validateDateBasis(req.DateBasis); err != nil {
+		return nil, err
+	}
+	weekStart, err := s.GetWeekStart()
+	if err != nil {
+		return nil, err
+	}
+	return s.reportingRepo.GetCustomSummaryWithFilter(req, models.WeekStartDay(weekStart))
+}
+
+// GetProfitability returns the profit made on sales whose item cost is known,
+// grouped by item, vendor or category
+func (s *Service) GetProfitability(filter models.ProfitabilityFilter) (*models.ProfitabilityReport, error) {
+	return s.reportingRepo.GetProfitability(filter)
+}
+
+// GetPareto ranks vendors or items by revenue with their cumulative share
+func (s *Service) GetPareto(filter models.ParetoFilter) (*models.ParetoReport, error) {
+	return s.reportingRepo.GetPareto(filter)
+}
+
+// GetVendorVelocity reports the items each vendor sells per week and the
+// weeks since its last sale as of now, flagging dormant vendors. A number of
+// weeks covers the week of now and those before it, starting on the saved
+// week start day.
+func (s *Service) GetVendorVelocity(filter models.VelocityFilter, now time.Time) (*models.VelocityReport, error) {
+	if filter.Weeks < 0 {
+		return nil, invalidf("weeks must not be negative")
+	}
+	if filter.DormantWeeks < 0 {
+		return nil, invalidf("dormant weeks must not be negative")
+	}
+	if filter.DormantWeeks == 0 {
+		filter.DormantWeeks = models.DefaultDormantWeeks
+	}
+
+	asOf := models.NewDate(now)
+	report := &models.VelocityReport{AsOf: asOf, Weeks: filter.Weeks, DormantWeeks: filter.DormantWeeks}
+	var windowStart time.Time
+	if filter.Weeks > 0 {
+		weekStart, err := s.GetWeekStart()
+		if err != nil {
+			return nil, err
+		}
+		windowStart = models.StartOfWeek(asOf.Time, models.WeekStartDay(weekStart)).AddDate(0, 0, -7*(filter.Weeks-1))
+	}
+
+	vendors, err := s.reportingRepo.GetVendorVelocity(filter.Store, windowStart, asOf.Time)
+	if err != nil {
+		return nil, err
+	}
+	for i := range vendors {
+		vendors[i].Calculate(asOf.Time, windowStart, filter.DormantWeeks)
+		if vendors[i].Dormant {
+			report.DormantVendors++
+		}
+	}
+	sort.SliceStable(vendors, func(i, j int) bool {
+		a, b := vendors[i], vendors[j]
+		if a.Dormant != b.Dormant {
+			return a.Dormant
+		}
+		if a.ItemsPerWeek != b.ItemsPerWeek {
+			return a.ItemsPerWeek < b.ItemsPerWeek
+		}
+		return a.Vendor < b.Vendor
+	})
+	report.Vendors = vendors
+	return report, nil
+}
+
+// GetVendorCohorts groups vendors by the month of their first sale and
+// follows their revenue in the months after, up to now
+func (s *Service) GetVendorCohorts(filter models.CohortFilter, now time.Time) (*models.CohortReport, error) {
+	if filter.Months < 0 {
+		return nil, invalidf("months must not be negative")
+	}
+	if filter.Months == 0 {
+		filter.Months = models.DefaultCohortMonths
+	}
+
+	cells, err := s.reportingRepo.GetVendorCohorts(filter.Store)
+	if err != nil {
+		return nil, err
+	}
+	asOf := models.NewDate(now)
+	return &models.CohortReport{
+		AsOf:    asOf,
+		Months:  filter.Months,
+		Cohorts: models.BuildCohorts(cells, filter.Months, asOf.Time),
+	}, nil
+}
+
+// GetVendorStatement returns a vendor's sales and monthly settlements, dated
+// as generated at now
+func (s *Service) GetVendorStatement(filter models.VendorStatementFilter, now time.Time) (*models.VendorStatement, error) {
+	if strings.TrimSpace(filter.Vendor) == "" {
+		return nil, invalidf("vendor is required")
+	}
+	if filter.DateFrom != nil && filter.DateTo != nil && filter.DateFrom.After(*filter.DateTo) {
+		return nil, invalidf("date_from must not be after date_to")
+	}
+
+	statement, err := s.reportingRepo.GetVendorStatement(filter)
+	if err != nil {
+		return nil, err
+	}
+	statement.GeneratedAt = now
+	return statement, nil
+}
+
+// GetTimeline returns a store's or vendor's sales, adjustments and expected
+// payouts merged into one list, oldest first. A vendor's payouts are its
+// share of what each store is expected to pay out.
+func (s *Service) GetTimeline(filter models.TimelineFilter) (*models.Timeline, error) {
+	filter.Kind = strings.ToLower(strings.TrimSpace(filter.Kind))
+	filter.Name = strings.TrimSpace(filter.Name)
+	if filter.Kind != models.AliasKindStore && filter.Kind != models.AliasKindVendor {
+		return nil, invalidf("timeline kind must be %q or %q", models.AliasKindStore, models.AliasKindVendor)
+	}
+	if filter.Name == "" {
+		return nil, invalidf("%s is required", filter.Kind)
+	}
+	if filter.DateFrom != nil && filter.DateTo != nil && filter.DateFrom.After(*filter.DateTo) {
+		return nil, invalidf("date_from must not be after date_to")
+	}
+
+	entries, err := s.reportingRepo.GetTimeline(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	payoutFilter := models.PayoutFilter{DateFrom: filter.DateFrom, DateTo: filter.DateTo}
+	var payouts []models.ExpectedPayout
+	if filter.Kind == models.AliasKindVendor {
+		payouts, err = s.payoutRepo.ExpectedForVendor(filter.Name, payoutFilter)
+	} else {
+		payoutFilter.Store = &filter.Name
+		payouts, err = s.payoutRepo.Expected(payoutFilter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, payout := range payouts {
+		entry := models.TimelineEntry{
+			Date:        payout.Date,
+			Type:        models.TimelinePayout,
+			Store:       payout.Store,
+			Description: fmt.Sprintf("Payout for %s sales", payout.Month),
+			Amount:      payout.Amount,
+			Month:       payout.Month,
+		}
+		if filter.Kind == models.AliasKindVendor {
+			entry.Vendor = filter.Name
+		}
+		entries = append(entries, entry)
+	}
+	// Payouts were appended last, so a stable sort keeps them after the
+	// sales and adjustments of their day
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Date.Before(entries[j].Date.Time)
+	})
+
+	return &models.Timeline{Kind: filter.Kind, Name: filter.Name, Entries: entries}, nil
+}
+
+// GetYearlySummaryConverted returns the yearly summary with amounts converted
+// into baseCurrency. It fails if any record lacks the exchange rate it needs.
+func (s *Service) GetYearlySummaryConverted(baseCurrency string) ([]models.YearlySummary, error) {
+	return s.GetYearlySummaryWithOptions(models.ReportOptions{BaseCurrency: baseCurrency})
+}
+
+// GetMonthlySummaryConverted returns the monthly summary with amounts converted
+// into baseCurrency. It fails if any record lacks the exchange rate it needs.
+func (s *Service) GetMonthlySummaryConverted(year *string, baseCurrency string) ([]models.MonthlySummary, error) {
+	return s.GetMonthlySummaryWithOptions(year, models.ReportOptions{BaseCurrency: baseCurrency})
+}
+
+// GetYearlySummaryWithOptions returns the yearly summary, optionally converted
+// into a base currency and with adjustments folded in
+func (s *Service) GetYearlySummaryWithOptions(opts models.ReportOptions) ([]models.YearlySummary, error) {
+	opts, err := validateReportOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	return s.reportingRepo.GetYearlySummaryWithOptions(opts)
+}
+
+// GetMonthlySummaryWithOptions returns the monthly summary, optionally filtered
+// by year, converted into a base currency and with adjustments folded in
+func (s *Service) GetMonthlySummaryWithOptions(year *string, opts models.ReportOptions) ([]models.MonthlySummary, error) {
+	opts, err := validateReportOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := s.reportingRepo.GetMonthlySummaryWithOptions(year, opts)
+	if err != nil {
+		return nil, err
+	}
+	// Months are closed by sale date, so import months carry no flags
+	if opts.DateBasis == models.DateBasisSale {
+		if err := s.flagClosedMonths(summaries); err != nil {
+			return nil, err
+		}
+	}
+	return summaries, nil
+}
+
+// GetMonthlySummaryByDimension returns the monthly summary split into one row
+// per store or vendor within each month, for stacked charts, with the same
+// options as GetMonthlySummaryWithOptions
+func (s *Service) GetMonthlySummaryByDimension(year *string, dimension string, opts models.ReportOptions) ([]models.MonthlySummary, error) {
+	opts, err := validateReportOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := s.reportingRepo.GetMonthlySummaryByDimension(year, dimension, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DateBasis == models.DateBasisSale {
+		if err := s.flagClosedMonths(summaries); err != nil {
+			return nil, err
+		}
+	}
+	return summaries, nil
+}
+
+// GetDigest summarizes the last complete week or month before now: its
+// totals, top vendor and the change in sales from the period before. Weeks
+// start on the saved week start day.
+func (s *Service) GetDigest(period string, now time.Time) (*models.Digest, error) {
+	weekStart, err := s.GetWeekStart()
+	if err != nil {
+		return nil, err
+	}
+	previousStart, currentStart, end, err := models.DigestPeriods(period, now, models.WeekStartDay(weekStart))
+	if err != nil {
+		return nil, invalidf("%v", err)
+	}
+
+	digest := &models.Digest{Period: period}
+	if digest.Current, err = s.reportingRepo.GetPeriodTotals(currentStart, end); err != nil {
+		return nil, err
+	}
+	if digest.Previous, err = s.reportingRepo.GetPeriodTotals(previousStart, currentStart); err != nil {
+		return nil, err
+	}
+	if digest.TopVendor, err = s.reportingRepo.GetTopVendor(currentStart, end); err != nil {
+		return nil, err
+	}
+
+	digest.SalesChange = models.PercentChange(digest.Current.TotalSales, digest.Previous.TotalSales)
+	return digest, nil
+}
+
+// GetDashboardKPIs returns month-to-date and year-to-date totals for period,
+// a month in YYYY-MM form or "" for the current one, each compared with the
+// same days of the prior month or year, and the month's sales paced to its
+// end. Results are cached until the next write.
+func (s *Service) GetDashboardKPIs(period string, now time.Time) (*models.DashboardKPIs, error) {
+	asOf, err := models.DashboardAsOf(period, now)
+	if err != nil {
+		return nil, invalidf("%v", err)
+	}
+
+	value, err := s.cached("kpis:"+asOf.Format("2006-01-02"), func() (interface{}, error) {
+		monthStart := time.Date(asOf.Year(), asOf.Month(), 1, 0, 0, 0, 0, time.UTC)
+		priorMonth := monthStart.AddDate(0, -1, 0)
+		yearStart := time.Date(asOf.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+		priorYear := yearStart.AddDate(-1, 0, 0)
+
+		month, err := s.comparePeriods(monthStart, asOf, priorMonth, models.SameDayIn(priorMonth.Year(), priorMonth.Month(), asOf.Day()))
+		if err != nil {
+			return nil, err
+		}
+		year, err := s.comparePeriods(yearStart, asOf, priorYear, models.SameDayIn(priorYear.Year(), asOf.Month(), asOf.Day()))
+		if err != nil {
+			return nil, err
+		}
+		lastYearMonth := monthStart.AddDate(-1, 0, 0)
+		lastYear, err := s.reportingRepo.GetPeriodTotals(lastYearMonth, lastYearMonth.AddDate(0, 1, 0))
+		if err != nil {
+			return nil, err
+		}
+		return models.DashboardKPIs{
+			AsOf:        models.NewDate(asOf),
+			MonthToDate: month,
+			YearToDate:  year,
+			Projection:  models.NewMonthProjection(month.Current.TotalSales, lastYear.TotalSales, asOf),
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	kpis := value.(models.DashboardKPIs)
+	return &kpis, nil
+}
+
+// GetTrailingTwelveMonths returns the totals of the twelve months ending with
+// endMonth, in YYYY-MM form or "" for the current month, compared with the
+// twelve months before. The current month counts up to today, and the prior
+// period ends on the same day a year earlier. Results are cached until the
+// next write.
+func (s *Service) GetTrailingTwelveMonths(endMonth string, now time.Time) (*models.TrailingTwelveMonths, error) {
+	asOf, err := models.DashboardAsOf(endMonth, now)
+	if err != nil {
+		return nil, invalidf("%v", err)
+	}
+
+	value, err := s.cached("ttm:"+asOf.Format("2006-01-02"), func() (interface{}, error) {
+		from := time.Date(asOf.Year(), asOf.Month()-11, 1, 0, 0, 0, 0, time.UTC)
+		priorFrom := from.AddDate(-1, 0, 0)
+		priorTo := models.SameDayIn(asOf.Year()-1, asOf.Month(), asOf.Day())
+
+		comparison, err := s.comparePeriods(from, asOf, priorFrom, priorTo)
+		if err != nil {
+			return nil, err
+		}
+		return models.TrailingTwelveMonths{
+			EndMonth:   asOf.Format("2006-01"),
+			AsOf:       models.NewDate(asOf),
+			Comparison: comparison,
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ttm := value.(models.TrailingTwelveMonths)
+	return &ttm, nil
+}
+
+// comparePeriods compares the totals of two periods, each given by its first
+// and last day
+func (s *Service) comparePeriods(from, to, priorFrom, priorTo time.Time) (models.KPIComparison, error) {
+	current, err := s.reportingRepo.GetPeriodTotals(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return models.KPIComparison{}, err
+	}
+	previous, err := s.reportingRepo.GetPeriodTotals(priorFrom, priorTo.AddDate(0, 0, 1))
+	if err != nil {
+		return models.KPIComparison{}, err
+	}
+	return models.NewKPIComparison(current, previous), nil
+}
+
+// ===== EXCHANGE RATE OPERATIONS =====
+
+// SaveExchangeRate stores a rate, replacing any existing rate for the same
+// currency and date
+func (s *Service) SaveExchangeRate(rate models.CreateExchangeRateRequest) (*models.ExchangeRate, error) {
+	release, err := s.beginWrite()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	rate, err = validateExchangeRate(rate)
+	if err != nil {
+		return nil, err
+	}
+	return s.exchangeRepo.Save(rate)
+}
+
+// SaveExchangeRates stores several rates in a single transaction
+func (s *Service) SaveExchangeRates(rates []models.CreateExchangeRateRequest) (int, error) {
+	release, err := s.beginWrite()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	validRates := make([]models.CreateExchangeRateRequest, 0, len(rates))
+	for i, rate := range rates {
+		rate, err := validateExchangeRate(rate)
+		if err != nil {
+			return 0, fmt.Errorf("rate %d: %w", i+1, err)
+		}
+		validRates = append(validRates, rate)
+	}
+
+	saved, err := s.exchangeRepo.SaveBatch(validRates)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save exchange rates: %w", err)
+	}
+	return saved, nil
+}
+
+// ListExchangeRates retrieves exchange rates, newest first
+func (s *Service) ListExchangeRates(filter models.ExchangeRateFilter) ([]models.ExchangeRate, error) {
+	return s.exchangeRepo.List(filter)
+}
+
+// DeleteExchangeRate removes an exchange rate
+func (s *Service) DeleteExchangeRate(id int64) error {
+	release, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return s.exchangeRepo.Delete(id)
+}
+
+// ===== COMMISSION OPERATIONS =====
+
+// SaveCommissionRule stores the commission rate a store charges from a date,
+// replacing any existing rule for the same store and date
+func (s *Service) SaveCommissionRule(rule models.CreateCommissionRuleRequest) (*models.CommissionRule, error) {
+	release, err := s.beginWrite()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	rule, err = validateCommissionRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	return s.commissionRepo.Save(rule)
+}
+
+// ListCommissionRules retrieves the commission rules, optionally for one store
+func (s *Service) ListCommissionRules(store *string) ([]models.CommissionRule, error) {
+	return s.commissionRepo.List(store)
+}
+
+// DeleteCommissionRule removes a commission rule
+func (s *Service) DeleteCommissionRule(id int64) error {
+	release, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return s.commissionRepo.Delete(id)
+}
+
+// ReconcileCommissions compares reported commissions with those computed from
+// the commission rules, per store and month, listing the sales that differ
+func (s *Service) ReconcileCommissions(filter models.ReconciliationFilter) (*models.CommissionReconciliation, error) {
+	if filter.Tolerance < 0 {
+		return nil, invalidf("tolerance cannot be negative")
+	}
+	return s.commissionRepo.Reconcile(filter)
+}
+
+// BackfillRemaining checks the remaining amount of the records matching the
+// request's filter against their sale price less commission, using the
+// commission rules where no commission was reported. With Fix set, records
+// whose amount is missing or off by more than the tolerance get the expected
+// amount, and the change is recorded in the audit log.
+func (s *Service) BackfillRemaining(req models.RemainingBackfillRequest) (*models.RemainingBackfillResult, error) {
+	if req.Tolerance < 0 {
+		return nil, invalidf("tolerance cannot be negative")
+	}
+	if req.Tolerance == 0 {
+		req.Tolerance = models.DefaultCommissionTolerance
+	}
+	if !req.Fix {
+		return s.commissionRepo.CheckRemaining(req.Filter, req.Tolerance)
+	}
+
+	var result *models.RemainingBackfillResult
+	err := s.ExecTx(func(tx *Service) error {
+		var err error
+		if result, err = tx.commissionRepo.CheckRemaining(req.Filter, req.Tolerance); err != nil {
+			return err
+		}
+		for _, line := range result.Discrepancies {
+			if err := tx.salesRepo.SetRemaining(line.SalesRecordID, line.Expected); err != nil {
+				return err
+			}
+			result.Fixed++
+		}
+		details := fmt.Sprintf("Recomputed the remaining amount of %d of %d sales records checked", result.Fixed, result.Checked)
+		return tx.auditEnrichment(&models.EnrichmentResult{Updated: result.Fixed}, details)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ===== PAYOUT OPERATIONS =====
+
+// SavePayoutSchedule stores when a store pays out each month's sales,
+// replacing any existing schedule for the store
+func (s *Service) SavePayoutSchedule(schedule models.SavePayoutScheduleRequest) (*models.PayoutSchedule, error) {
+	release, err := s.beginWrite()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	schedule, err = validatePayoutSchedule(schedule)
+	if err != nil {
+		return nil, err
+	}
+	return s.payoutRepo.Save(schedule)
+}
+
+// ListPayoutSchedules retrieves the stores' payout schedules
+func (s *Service) ListPayoutSchedules() ([]models.PayoutSchedule, error) {
+	return s.payoutRepo.List()
+}
+
+// DeletePayoutSchedule removes a store's payout schedule
+func (s *Service) DeletePayoutSchedule(store string) error {
+	release, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return s.payoutRepo.Delete(store)
+}
+
+// GetExpectedPayouts returns the payouts expected from stores with a payout
+// schedule, one per store and month of sales, dated by the schedule
+func (s *Service) GetExpectedPayouts(filter models.PayoutFilter) ([]models.ExpectedPayout, error) {
+	if filter.DateFrom != nil && filter.DateTo != nil && filter.DateFrom.After(*filter.DateTo) {
+		return nil, invalidf("start date must not be after end date")
+	}
+	return s.payoutRepo.Expected(filter)
+}
+
+// ===== ALIAS OPERATIONS =====
+
+// SaveNameAlias maps a store or vendor name as it appears in imported reports
+// to the name records are kept under. Records already imported keep their
+// names.
+func (s *Service) SaveNameAlias(alias models.SaveNameAliasRequest) (*models.NameAlias, error) {
+	alias, err := validateNameAlias(alias)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved *models.NameAlias
+	err = s.ExecTx(func(tx *Service) error {
+		existing, err := tx.aliasRepo.List(alias.Kind)
+		if err != nil {
+			return err
+		}
+		// Aliases map straight to a canonical name, never to another alias,
+		// though a canonical name may have an alias differing only in case
+		for _, other := range existing {
+			if strings.EqualFold(other.Alias, alias.Alias) || other.Canonical == alias.Canonical {
+				continue
+			}
+			if strings.EqualFold(other.Alias, alias.Canonical) {
+				return invalidf("%q is itself an alias of %q", alias.Canonical, other.Canonical)
+			}
+			if strings.EqualFold(other.Canonical, alias.Alias) {
+				return invalidf("%q is the name %q is an alias of", alias.Alias, other.Alias)
+			}
+		}
+
+		saved, err = tx.aliasRepo.Save(alias)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// ListNameAliases retrieves the aliases of a kind, or all aliases when kind is
+// empty
+func (s *Service) ListNameAliases(kind string) ([]models.NameAlias, error) {
+	if kind != "" && kind != models.AliasKindStore && kind != models.AliasKindVendor {
+		return nil, invalidf("alias kind must be %q or %q", models.AliasKindStore, models.AliasKindVendor)
+	}
+	return s.aliasRepo.List(kind)
+}
+
+// DeleteNameAlias removes an alias
+func (s *Service) DeleteNameAlias(id int64) error {
+	release, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return s.aliasRepo.Delete(id)
+}
+
+// ===== SNAPSHOT OPERATIONS =====
+
+// CreateReportSnapshot keeps the records a report's filter selects as they
+// are now, such as a month's sales at settlement time, so later amendments
+// can be found with CompareReportSnapshot
+func (s *Service) CreateReportSnapshot(req models.CreateReportSnapshotRequest) (*models.ReportSnapshot, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, invalidf("snapshot name is required")
+	}
+	if len(req.Name) > 200 {
+		return nil, invalidf("snapshot name must be at most 200 characters")
+	}
+	// Every matching record is kept, in no particular order
+	req.Filter.Limit, req.Filter.Offset = nil, nil
+	req.Filter.SortBy, req.Filter.SortOrder = nil, nil
+
+	var snapshot *models.ReportSnapshot
+	err := s.ExecTx(func(tx *Service) error {
+		var records []models.SalesRecord
+		err := tx.salesRepo.Each(req.Filter, func(record models.SalesRecord) error {
+			records = append(records, record)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		snapshot, err = tx.snapshotRepo.Create(req.Name, req.Filter, records)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// ListReportSnapshots retrieves the report snapshots, newest first
+func (s *Service) ListReportSnapshots() ([]models.ReportSnapshot, error) {
+	return s.snapshotRepo.List()
+}
+
+// CompareReportSnapshot compares the records a snapshot kept with the records
+// its report's filter selects now, listing those added, removed and changed
+// since the snapshot was taken
+func (s *Service) CompareReportSnapshot(id int64) (*models.SnapshotComparison, error) {
+	snapshot, err := s.snapshotRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	kept, err := s.snapshotRepo.Records(id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := make(map[int64]models.SalesRecord, len(kept))
+	for _, record := range kept {
+		before[record.ID] = record
+	}
+
+	comparison := &models.SnapshotComparison{
+		Snapshot: *snapshot,
+		Added:    []models.SalesRecord{},
+		Removed:  []models.SalesRecord{},
+		Changed:  []models.SnapshotChange{},
+	}
+	current := make(map[int64]bool, len(kept))
+	err = s.salesRepo.Each(snapshot.Filter, func(record models.SalesRecord) error {
+		current[record.ID] = true
+		comparison.Current.Add(record)
+
+		old, ok := before[record.ID]
+		if !ok {
+			comparison.Added = append(comparison.Added, record)
+		} else if changes := describeRecordChanges(old, record); changes != "" {
+			comparison.Changed = append(comparison.Changed, models.SnapshotChange{Before: old, After: record, Changes: changes})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range kept {
+		if !current[record.ID] {
+			comparison.Removed = append(comparison.Removed, record)
+		}
+	}
+	sort.Slice(comparison.Added, func(i, j int) bool { return comparison.Added[i].ID < comparison.Added[j].ID })
+	sort.Slice(comparison.Changed, func(i, j int) bool { return comparison.Changed[i].After.ID < comparison.Changed[j].After.ID })
+
+	return comparison, nil
+}
+
+// DeleteReportSnapshot removes a snapshot and the records it kept
+func (s *Service) DeleteReportSnapshot(id int64) error {
+	release, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return s.snapshotRepo.Delete(id)
+}
+
+// ===== PERIOD CLOSE OPERATIONS =====
+
+// ClosePeriod closes a month once it has been settled, taking a report
+// snapshot of its records. Its sales, fees and adjustments are read-only
+// until it is reopened.
+func (s *Service) ClosePeriod(req models.ClosePeriodRequest) (*models.PeriodClose, error) {
+	start, err := time.Parse("2006-01", strings.TrimSpace(req.Month))
+	if err != nil {
+		return nil, invalidf("month must be YYYY-MM")
+	}
+	month := start.Format("2006-01")
+	if req.Note != nil {
+		note := strings.TrimSpace(*req.Note)
+		req.Note = &note
+		if note == "" {
+			req.Note = nil
+		}
+	}
+
+	var period *models.PeriodClose
+	err = s.ExecTx(func(tx *Service) error {
+		existing, err := tx.periodRepo.Get(month)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if existing != nil && existing.Closed() {
+			return invalidf("%s is already closed", month)
+		}
+
+		end := start.AddDate(0, 1, -1)
+		snapshot, err := tx.CreateReportSnapshot(models.CreateReportSnapshotRequest{
+			Name:   "Close of " + month,
+			Filter: models.SalesRecordFilter{DateFrom: &start, DateTo: &end},
+		})
+		if err != nil {
+			return err
+		}
+
+		period, err = tx.periodRepo.Close(month, snapshot.ID, req.Note)
+		if err != nil {
+			return err
+		}
+
+		details := fmt.Sprintf("Closed %s with %d records", month, snapshot.Totals.Records)
+		if req.Note != nil {
+			details += ": " + *req.Note
+		}
+		entityType := "period"
+		if _, err := tx.auditRepo.Create(models.AuditEntry{
+			Action:     models.AuditPeriodClosed,
+			EntityType: &entityType,
+			Details:    details,
+		}); err != nil {
+			return err
+		}
+
+		tx.emitChange(EventPeriodClosed, *period)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return period, nil
+}
+
+// ReopenPeriod reopens a closed month so its records can be changed again.
+// The reason is kept with the close and in the audit log.
+func (s *Service) ReopenPeriod(req models.ReopenPeriodRequest) (*models.PeriodClose, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, invalidf("a reason is required to reopen a month")
+	}
+	start, err := time.Parse("2006-01", strings.TrimSpace(req.Month))
+	if err != nil {
+		return nil, invalidf("month must be YYYY-MM")
+	}
+	month := start.Format("2006-01")
+
+	var period *models.PeriodClose
+	err = s.ExecTx(func(tx *Service) error {
+		var err error
+		period, err = tx.periodRepo.Reopen(month, reason)
+		if err != nil {
+			return err
+		}
+
+		entityType := "period"
+		if _, err := tx.auditRepo.Create(models.AuditEntry{
+			Action:     models.AuditPeriodReopened,
+			EntityType: &entityType,
+			Details:    fmt.Sprintf("Reopened %s: %s", month, reason),
+		}); err != nil {
+			return err
+		}
+
+		tx.emitChange(EventPeriodReopened, *period)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return period, nil
+}
+
+// ListPeriodCloses retrieves every month closed or reopened, newest first,
+// flagging reopened months whose records changed since they were closed
+func (s *Service) ListPeriodCloses() ([]models.PeriodClose, error) {
+	periods, err := s.periodRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range periods {
+		if periods[i].Changed, err = s.changedSinceClose(periods[i]); err != nil {
+			return nil, err
+		}
+	}
+	return periods, nil
+}
+
+// changedSinceClose reports whether a month's records differ from the
+// snapshot taken when it was last closed. A closed month cannot change.
+func (s *Service) changedSinceClose(period models.PeriodClose) (bool, error) {
+	if period.Closed() || period.SnapshotID == nil {
+		return false, nil
+	}
+	comparison, err := s.CompareReportSnapshot(*period.SnapshotID)
+	if err != nil {
+		return false, err
+	}
+	return len(comparison.Added)+len(comparison.Removed)+len(comparison.Changed) > 0, nil
+}
+
+// flagClosedMonths marks the months of a monthly summary that are closed, or
+// were reopened and changed since
+func (s *Service) flagClosedMonths(summaries []models.MonthlySummary) error {
+	periods, err := s.periodRepo.List()
+	if err != nil || len(periods) == 0 {
+		return err
+	}
+
+	byMonth := make(map[string]models.PeriodClose, len(periods))
+	for _, period := range periods {
+		byMonth[period.Month] = period
+	}
+	for i := range summaries {
+		period, ok := byMonth[summaries[i].YearMonth]
+		if !ok {
+			continue
+		}
+		summaries[i].Closed = period.Closed()
+		if summaries[i].Amended, err = s.changedSinceClose(period); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ===== ENRICHMENT OPERATIONS =====
+
+// EnrichSalesRecords applies a cost, category or tags to every record matching
+// the request's filter and records the change in the audit log. A dry run
+// reports how many records would change without changing them.
+func (s *Service) EnrichSalesRecords(req models.EnrichmentRequest) (*models.EnrichmentResult, error) {
+	req, err := validateEnrichment(req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.EnrichmentResult{DryRun: req.DryRun}
+	err = s.runEnrichment(req.DryRun, func(tx *Service) error {
+		updated, err := tx.salesRepo.Enrich(req)
+		if err != nil {
+			return err
+		}
+		result.Updated = updated
+		return tx.auditEnrichment(result, describeEnrichment(req, updated))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ApplyCategoryMappings sets the category of records from a pasted list of
+// description and category pairs, matching records by product key, and
+// records the change in the audit log
+func (s *Service) ApplyCategoryMappings(req models.CategoryMappingRequest) (*models.EnrichmentResult, error) {
+	mappings, skipped := models.ParseCategoryMappings(req.Mappings)
+	if len(mappings) == 0 {
+		return nil, invalidf("no description and category pairs found")
+	}
+	for _, mapping := range mappings {
+		if err := validateCostAndCategory(nil, &mapping.Category); err != nil {
+			return nil, invalidf("line %d: %v", mapping.Line, err)
+		}
+	}
+
+	result := &models.EnrichmentResult{Skipped: skipped, DryRun: req.DryRun}
+	err := s.runEnrichment(req.DryRun, func(tx *Service) error {
+		result.Mappings = mappings
+		for i := range result.Mappings {
+			mapping := &result.Mappings[i]
+			key := models.ProductKey(mapping.Description)
+			if key == "" {
+				continue
+			}
+			updated, err := tx.salesRepo.SetCategoryByProductKey(key, mapping.Category, req.OnlyMissing)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", mapping.Line, err)
+			}
+			mapping.Updated = updated
+			result.Updated += updated
+		}
+		details := fmt.Sprintf("Set categories on %d sales records from %d description mappings", result.Updated, len(mappings))
+		return tx.auditEnrichment(result, details)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// runEnrichment runs fn in a transaction that is rolled back for a dry run
+func (s *Service) runEnrichment(dryRun bool, fn func(*Service) error) error {
+	if dryRun {
+		return s.DryRun(fn)
+	}
+	return s.ExecTx(fn)
+}
+
+// auditEnrichment records an enrichment that changed records in the audit
+// log and the change feed
+func (s *Service) auditEnrichment(result *models.EnrichmentResult, details string) error {
+	if result.Updated == 0 {
+		return nil
+	}
+
+	entityType := "sales_record"
+	if _, err := s.auditRepo.Create(models.AuditEntry{
+		Action:     models.AuditRecordsEnriched,
+		EntityType: &entityType,
+		Details:    details,
+	}); err != nil {
+		return err
+	}
+	s.emitChange(EventRecordsEnriched, *result)
+	return nil
+}
+
+// ===== ADJUSTMENT OPERATIONS =====
+
+// CreateAdjustment records a correction to an existing sale without editing it
+func (s *Service) CreateAdjustment(adjustment models.CreateSalesAdjustmentRequest) (*models.SalesAdjustment, error) {
+	release, err := s.beginWrite()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	adjustment, err = validateAdjustment(adjustment)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.salesRepo.GetByID(adjustment.SalesRecordID); err != nil {
+		return nil, err
+	}
+
+	created, err := s.adjustmentRepo.Create(adjustment)
+	if err != nil {
+		return nil, err
+	}
+
+	s.emitChange(EventAdjustmentCreated, AdjustmentChangeEvent{ID: created.ID, SalesRecordID: created.SalesRecordID, Adjustment: created})
+	return created, nil
+}
+
+// ListAdjustments retrieves the adjustments to a sale, oldest first
+func (s *Service) ListAdjustments(salesRecordID int64) ([]models.SalesAdjustment, error) {
+	return s.adjustmentRepo.ListForRecord(salesRecordID)
+}
+
+// DeleteAdjustment removes an adjustment
+func (s *Service) DeleteAdjustment(id int64) error {
+	release, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := s.adjustmentRepo.Delete(id); err != nil {
+		return err
+	}
+
+	s.emitChange(EventAdjustmentDeleted, AdjustmentChangeEvent{ID: id})
+	return nil
+}
+
+// ===== FEE OPERATIONS =====
+
+// CreateFee records a fee charged by a store
+func (s *Service) CreateFee(fee models.CreateFeeRequest) (*models.Fee, error) {
+	release, err := s.beginWrite()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	fee, err = validateFee(fee)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := s.feeRepo.Create(fee, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.emitChange(EventFeeCreated, FeeChangeEvent{ID: created.ID, Fee: created})
+	return created, nil
+}
+
+// CreateFees records the fee lines of an import, attributed to the import the
+// service is bound to and under the canonical names of aliased stores and
+// vendors. Either every fee is recorded or none is.
+func (s *Service) CreateFees(fees []models.CreateFeeRequest) ([]models.Fee, error) {
+	validated := make([]models.CreateFeeRequest, len(fees))
+	for i, fee := range fees {
+		fee, err := validateFee(fee)
+		if err != nil {
+			return nil, fmt.Errorf("fee %d: %w", i+1, err)
+		}
+		if canonical, ok := s.salesRepo.aliases.resolve(models.AliasKindStore, fee.Store); ok {
+			fee.Store = canonical
+		}
+		if fee.Vendor != nil {
+			if canonical, ok := s.salesRepo.aliases.resolve(models.AliasKindVendor, *fee.Vendor); ok {
+				fee.Vendor = &canonical
+			}
+		}
+		validated[i] = fee
+	}
+
+	created := make([]models.Fee, 0, len(validated))
+	err := s.ExecTx(func(tx *Service) error {
+		for _, fee := range validated {
+			saved, err := tx.feeRepo.Create(fee, s.salesRepo.importRunID)
+			if err != nil {
+				return err
+			}
+			created = append(created, *saved)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// ListFees retrieves the fees matching a filter, newest first
+func (s *Service) ListFees(filter models.FeeFilter) ([]models.Fee, error) {
+	return s.feeRepo.List(filter)
+}
+
+// DeleteFee removes a fee
+func (s *Service) DeleteFee(id int64) error {
+	release, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := s.feeRepo.Delete(id); err != nil {
+		return err
+	}
+
+	s.emitChange(EventFeeDeleted, FeeChangeEvent{ID: id})
+	return nil
+}
+
+// GetMonthlyFeeSummary totals the fees charged by month, newest first, for a
+// year and store; nil selects every year or store
+func (s *Service) GetMonthlyFeeSummary(year, store *string) ([]models.MonthlyFeeSummary, error) {
+	return s.feeRepo.MonthlySummary(year, store)
+}
+
+// GetNetIncome returns the remaining amount of each month's sales less the
+// fees charged that month, newest first, for a year and store; nil selects
+// every year or store
+func (s *Service) GetNetIncome(year, store *string) ([]models.NetIncomeMonth, error) {
+	return s.feeRepo.NetIncome(year, store)
+}
+
+// ===== QUARANTINE OPERATIONS =====
+
+// QuarantineRows keeps the rows an import set aside for review, attributed to
+// the import the service is bound to and under the canonical names of aliased
+// stores and vendors. Either every row is kept or none is.
+func (s *Service) QuarantineRows(rows []models.QuarantinedRow) ([]models.QuarantinedRow, error) {
+	created := make([]models.QuarantinedRow, 0, len(rows))
+	err := s.ExecTx(func(tx *Service) error {
+		for _, row := range rows {
+			row.Record = s.salesRepo.aliases.apply(row.Record)
+			saved, err := tx.quarantineRepo.Create(row, s.salesRepo.importRunID)
+			if err != nil {
+				return err
+			}
+			created = append(created, *saved)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// ListQuarantinedRows retrieves the rows awaiting review, oldest first
+func (s *Service) ListQuarantinedRows() ([]models.QuarantinedRow, error) {
+	return s.quarantineRepo.List()
+}
+
+// ApproveQuarantinedRow imports a quarantined row as a sales record and
+// removes it from quarantine. A non-nil record replaces the row's values, for
+// rows that needed correcting; the record keeps the row's import and source
+// row either way.
+func (s *Service) ApproveQuarantinedRow(id int64, record *models.CreateSalesRecordRequest) (*models.SalesRecord, error) {
+	var created *models.SalesRecord
+	err := s.ExecTx(func(tx *Service) error {
+		row, err := tx.quarantineRepo.GetByID(id)
+		if err != nil {
+			return err
+		}
+
+		approved := row.Record
+		if record != nil {
+			approved = *record
+			approved.SourceRow = row.Record.SourceRow
+		}
+		importer := tx
+		if row.ImportRunID != nil {
+			importer = tx.ForImport(*row.ImportRunID)
+		}
+		if created, err = importer.CreateSalesRecord(approved); err != nil {
+			return err
+		}
+		return tx.quarantineRepo.Delete(id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// DiscardQuarantinedRow removes a quarantined row without importing it
+func (s *Service) DiscardQuarantinedRow(id int64) error {
+	release, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return s.quarantineRepo.Delete(id)
+}
+
+// ===== IMPORT HISTORY OPERATIONS =====
+
+// RecordImport persists the summary of a finished import
+func (s *Service) RecordImport(run models.ImportRun) (*models.ImportRun, error) {
+	release, err := s.beginWrite()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if run.Source == "" {
+		return nil, invalidf("import source is required")
+	}
+	if run.Method == "" {
+		return nil, invalidf("import method is required")
+	}
+	return s.importRepo.Create(run)
+}
+
+// SaveImportSource keeps the raw content of a recorded import, compressed, so
+// its numbers can be traced back to exactly what was imported
+func (s *Service) SaveImportSource(runID int64, content io.Reader) error {
+	release, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return s.importRepo.SaveSource(runID, content)
+}
+
+// GetImportSource retrieves the raw content of an import
+func (s *Service) GetImportSource(runID int64) (*models.ImportSource, error) {
+	return s.importRepo.GetSource(runID)
+}
+
+// FinishImport stores the counts and outcome of an import recorded with
+// RecordImport when it started. When run.Name is set to a source label, such
+// as the layout or file name, the import is named after that label and the
+// records it wrote.
+func (s *Service) FinishImport(run models.ImportRun) error {
+	release, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if run.Name != nil {
+		content, err := s.importRepo.Content(run.ID)
+		if err != nil {
+			return err
+		}
+		name := importName(*run.Name, content, run.TotalRows)
+		run.Name = &name
+	}
+	return s.importRepo.Update(run)
+}
+
+// DiscardImport removes an import recorded when it started that turned out to
+// persist nothing, such as a dry run
+func (s *Service) DiscardImport(runID int64) error {
+	release, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return s.importRepo.Delete(runID)
+}
+
+// ListImportRuns retrieves the most recent imports, newest first
+func (s *Service) ListImportRuns(limit int) ([]models.ImportRun, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	return s.importRepo.List(limit)
+}
+
+// FindDuplicateImport returns the most recent successful import whose parsed
+// records had the content hash hash, or nil when there is none, so a report
+// imported again can be recognized
+func (s *Service) FindDuplicateImport(hash string) (*models.ImportRun, error) {
+	if hash == "" {
+		return nil, nil
+	}
+	run, err := s.importRepo.FindByContentHash(hash)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return run, err
+}
+
+// GetImportActivity returns import activity by month, including months
+// without imports, with unusually high error rates flagged
+func (s *Service) GetImportActivity() ([]models.ImportActivity, error) {
+	return s.importRepo.GetActivity()
+}
+
+// ===== EXPORT SETTINGS =====
+
+// GetExportFormat returns the saved export format, or the default format if
+// none has been saved
+func (s *Service) GetExportFormat() (models.ExportFormat, error) {
+	format := models.DefaultExportFormat()
+	if _, err := s.settingsRepo.Get(settingExportFormat, &format); err != nil {
+		return format, err
+	}
+	return format, nil
+}
+
+// SaveExportFormat validates and stores the export format and records the
+// change in the audit log
+func (s *Service) SaveExportFormat(format models.ExportFormat) error {
+	if err := validateExportFormat(format); err != nil {
+		return err
+	}
+
+	return s.ExecTx(func(tx *Service) error {
+		if err := tx.settingsRepo.Set(settingExportFormat, format); err != nil {
+			return err
+		}
+
+		entityType := "setting"
+		_, err := tx.auditRepo.Create(models.AuditEntry{
+			Action:     models.AuditSettingsUpdated,
+			EntityType: &entityType,
+			Details:    "Export format: " + describeExportFormat(format),
+		})
+		return err
+	})
+}
+
+// ===== WEEK START =====
+
+// GetWeekStart returns the saved day weeks start on, or
+// models.DefaultWeekStart if none has been saved
+func (s *Service) GetWeekStart() (string, error) {
+	weekStart := models.DefaultWeekStart
+	if _, err := s.settingsRepo.Get(settingWeekStart, &weekStart); err != nil {
+		return models.DefaultWeekStart, err
+	}
+	return weekStart, nil
+}
+
+// SaveWeekStart stores the day weeks start on, models.WeekStartMonday or
+// models.WeekStartSunday, and records the change in the audit log
+func (s *Service) SaveWeekStart(weekStart string) error {
+	weekStart = strings.ToLower(strings.TrimSpace(weekStart))
+	if weekStart != models.WeekStartMonday && weekStart != models.WeekStartSunday {
+		return invalidf("week start must be %q or %q", models.WeekStartMonday, models.WeekStartSunday)
+	}
+
+	return s.ExecTx(func(tx *Service) error {
+		if err := tx.settingsRepo.Set(settingWeekStart, weekStart); err != nil {
+			return err
+		}
+
+		entityType := "setting"
+		_, err := tx.auditRepo.Create(models.AuditEntry{
+			Action:     models.AuditSettingsUpdated,
+			EntityType: &entityType,
+			Details:    "Week start: " + weekStart,
+		})
+		return err
+	})
+}
+
+// ===== MEMORY BUDGET =====
+
+// GetMemoryBudget returns the saved memory budget of parses and exports, or
+// the default budget if none has been saved
+func (s *Service) GetMemoryBudget() (models.MemoryBudget, error) {
+	budget := models.DefaultMemoryBudget()
+	if _, err := s.settingsRepo.Get(settingMemoryBudget, &budget); err != nil {
+		return models.DefaultMemoryBudget(), err
+	}
+	return budget, nil
+}
+
+// SaveMemoryBudget validates and stores the memory budget of parses and
+// exports and records the change in the audit log
+func (s *Service) SaveMemoryBudget(budget models.MemoryBudget) error {
+	if budget.LimitMB < models.MinMemoryBudgetMB {
+		return invalidf("memory budget must be at least %d MB", models.MinMemoryBudgetMB)
+	}
+
+	return s.ExecTx(func(tx *Service) error {
+		if err := tx.settingsRepo.Set(settingMemoryBudget, budget); err != nil {
+			return err
+		}
+
+		entityType := "setting"
+		_, err := tx.auditRepo.Create(models.AuditEntry{
+			Action:     models.AuditSettingsUpdated,
+			EntityType: &entityType,
+			Details:    fmt.Sprintf("Memory budget: %d MB", budget.LimitMB),
+		})
+		return err
+	})
+}
+
+// ===== IGNORE RULES =====
+
+// GetIgnoreRules returns the saved rules for rows skipped during import, or
+// none if no rules have been saved
+func (s *Service) GetIgnoreRules() ([]models.IgnoreRule, error) {
+	rules := []models.IgnoreRule{}
+	if _, err := s.settingsRepo.Get(settingIgnoreRules, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// SaveIgnoreRules validates and stores the rules for rows skipped during
+// import, replacing the saved rules, and records the change in the audit log
+func (s *Service) SaveIgnoreRules(rules []models.IgnoreRule) ([]models.IgnoreRule, error) {
+	rules, err := validateIgnoreRules(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.ExecTx(func(tx *Service) error {
+		if err := tx.settingsRepo.Set(settingIgnoreRules, rules); err != nil {
+			return err
+		}
+
+		entityType := "setting"
+		_, err := tx.auditRepo.Create(models.AuditEntry{
+			Action:     models.AuditSettingsUpdated,
+			EntityType: &entityType,
+			Details:    "Ignore rules: " + describeIgnoreRules(rules),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ===== MAPPING PROFILES =====
+
+// ListMappingProfiles returns the saved mappings of delimited files, sorted
+// by name, or none if none have been saved
+func (s *Service) ListMappingProfiles() ([]models.CSVMapping, error) {
+	profiles := []models.CSVMapping{}
+	if _, err := s.settingsRepo.Get(settingMappingProfiles, &profiles); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// SaveMappingProfile saves a mapping under its name, replacing a profile of
+// the same name ignoring case, and records the change in the audit log. The
+// caller checks the mapping's fields with parser.ValidateCSVMapping.
+func (s *Service) SaveMappingProfile(profile models.CSVMapping) (*models.CSVMapping, error) {
+	profile.Name = strings.TrimSpace(profile.Name)
+	if profile.Name == "" {
+		return nil, invalidf("mapping profile name is required")
+	}
+
+	err := s.ExecTx(func(tx *Service) error {
+		profiles, err := tx.ListMappingProfiles()
+		if err != nil {
+			return err
+		}
+		details := "saved"
+		if i := mappingProfileIndex(profiles, profile.Name); i >= 0 {
+			profiles[i] = profile
+			details = "replaced"
+		} else if len(profiles) >= models.MaxMappingProfiles {
+			return invalidf("at most %d mapping profiles can be saved", models.MaxMappingProfiles)
+		} else {
+			profiles = append(profiles, profile)
+		}
+		sort.Slice(profiles, func(i, j int) bool {
+			return strings.ToLower(profiles[i].Name) < strings.ToLower(profiles[j].Name)
+		})
+		return tx.saveMappingProfiles(profiles, fmt.Sprintf("Mapping profile %q: %s", profile.Name, details))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// DeleteMappingProfile removes the mapping profile named name, ignoring case
+func (s *Service) DeleteMappingProfile(name string) error {
+	return s.ExecTx(func(tx *Service) error {
+		profiles, err := tx.ListMappingProfiles()
+		if err != nil {
+			return err
+		}
+		i := mappingProfileIndex(profiles, name)
+		if i < 0 {
+			return fmt.Errorf("mapping profile %q %w", name, ErrNotFound)
+		}
+		deleted := profiles[i].Name
+		profiles = append(profiles[:i], profiles[i+1:]...)
+		return tx.saveMappingProfiles(profiles, fmt.Sprintf("Mapping profile %q: deleted", deleted))
+	})
+}
+
+// saveMappingProfiles stores profiles, replacing the saved ones, and records
+// details in the audit log
+func (s *Service) saveMappingProfiles(profiles []models.CSVMapping, details string) error {
+	if err := s.settingsRepo.Set(settingMappingProfiles, profiles); err != nil {
+		return err
+	}
+
+	entityType := "setting"
+	_, err := s.auditRepo.Create(models.AuditEntry{
+		Action:     models.AuditSettingsUpdated,
+		EntityType: &entityType,
+		Details:    details,
+	})
+	return err
+}
+
+// mappingProfileIndex returns the position of the profile named name,
+// ignoring case and surrounding spaces, or -1 if there is none
+func mappingProfileIndex(profiles []models.CSVMapping, name string) int {
+	name = strings.TrimSpace(name)
+	for i, profile := range profiles {
+		if strings.EqualFold(profile.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ===== SETTINGS TRANSFER =====
+
+// ExportSettings collects the settings, aliases and rules the user has saved,
+// for copying to another computer with ImportSettings. Settings never saved
+// are left out, so importing them keeps the other computer's.
+func (s *Service) ExportSettings(now time.Time) (*models.SettingsBundle, error) {
+	bundle := &models.SettingsBundle{Version: models.SettingsBundleVersion, ExportedAt: now}
+
+	var format models.ExportFormat
+	if found, err := s.settingsRepo.Get(settingExportFormat, &format); err != nil {
+		return nil, err
+	} else if found {
+		bundle.ExportFormat = &format
+	}
+	var policy models.RetentionPolicy
+	if found, err := s.settingsRepo.Get(settingRetentionPolicy, &policy); err != nil {
+		return nil, err
+	} else if found {
+		bundle.RetentionPolicy = &policy
+	}
+	var weekStart string
+	if found, err := s.settingsRepo.Get(settingWeekStart, &weekStart); err != nil {
+		return nil, err
+	} else if found {
+		bundle.WeekStart = &weekStart
+	}
+
+	var err error
+	if bundle.IgnoreRules, err = s.GetIgnoreRules(); err != nil {
+		return nil, err
+	}
+
+	aliases, err := s.aliasRepo.List("")
+	if err != nil {
+		return nil, err
+	}
+	for _, alias := range aliases {
+		bundle.Aliases = append(bundle.Aliases, models.SaveNameAliasRequest{Kind: alias.Kind, Alias: alias.Alias, Canonical: alias.Canonical})
+	}
+
+	rules, err := s.commissionRepo.List(nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		bundle.CommissionRules = append(bundle.CommissionRules, models.CreateCommissionRuleRequest{Store: rule.Store, EffectiveFrom: rule.EffectiveFrom.String(), Rate: rule.Rate})
+	}
+
+	schedules, err := s.payoutRepo.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, schedule := range schedules {
+		bundle.PayoutSchedules = append(bundle.PayoutSchedules, models.SavePayoutScheduleRequest{Store: schedule.Store, PayoutDay: schedule.PayoutDay, MonthsAfter: schedule.MonthsAfter})
+	}
+
+	return bundle, nil
+}
+
+// ImportSettings saves the settings, aliases and rules of a bundle written by
+// ExportSettings, all together or, if any is invalid, none of them. Saved
+// aliases, commission rules and payout schedules for the same names, stores
+// and dates are replaced and others are kept; the bundle's ignore rules
+// replace the saved ones.
+func (s *Service) ImportSettings(bundle models.SettingsBundle) (*models.SettingsImportResult, error) {
+	if bundle.Version < 1 {
+		return nil, invalidf("not a settings file")
+	}
+	if bundle.Version > models.SettingsBundleVersion {
+		return nil, invalidf("the settings file is from a newer version of the app")
+	}
+
+	result := &models.SettingsImportResult{}
+	err := s.ExecTx(func(tx *Service) error {
+		if bundle.ExportFormat != nil {
+			if err := tx.SaveExportFormat(*bundle.ExportFormat); err != nil {
+				return fmt.Errorf("export format: %w", err)
+			}
+			result.ExportFormat = true
+		}
+		if bundle.RetentionPolicy != nil {
+			if err := tx.SaveRetentionPolicy(*bundle.RetentionPolicy); err != nil {
+				return fmt.Errorf("retention policy: %w", err)
+			}
+			result.RetentionPolicy = true
+		}
+		if bundle.WeekStart != nil {
+			if err := tx.SaveWeekStart(*bundle.WeekStart); err != nil {
+				return fmt.Errorf("week start: %w", err)
+			}
+			result.WeekStart = true
+		}
+		if bundle.IgnoreRules != nil {
+			if _, err := tx.SaveIgnoreRules(bundle.IgnoreRules); err != nil {
+				return err
+			}
+			result.IgnoreRules = len(bundle.IgnoreRules)
+		}
+		for i, alias := range bundle.Aliases {
+			if _, err := tx.SaveNameAlias(alias); err != nil {
+				return fmt.Errorf("alias %d: %w", i+1, err)
+			}
+			result.Aliases++
+		}
+		for i, rule := range bundle.CommissionRules {
+			if _, err := tx.SaveCommissionRule(rule); err != nil {
+				return fmt.Errorf("commission rule %d: %w", i+1, err)
+			}
+			result.CommissionRules++
+		}
+		for i, schedule := range bundle.PayoutSchedules {
+			if _, err := tx.SavePayoutSchedule(schedule); err != nil {
+				return fmt.Errorf("payout schedule %d: %w", i+1, err)
+			}
+			result.PayoutSchedules++
+		}
+
+		entityType := "setting"
+		_, err := tx.auditRepo.Create(models.AuditEntry{
+			Action:     models.AuditSettingsUpdated,
+			EntityType: &entityType,
+			Details: fmt.Sprintf("Imported settings: %d aliases, %d commission rules, %d payout schedules",
+				result.Aliases, result.CommissionRules, result.PayoutSchedules),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-// DeleteSalesRecord removes a sales record
-func (s *Service) DeleteSalesRecord(id int64) error {
-	return s.salesRepo.Delete(id)
-}
+// ===== RETENTION OPERATIONS =====
 
-// ListSalesRecords retrieves sales records with filtering and pagination
-func (s *Service) ListSalesRecords(filter models.SalesRecordFilter) (*models.SalesRecordList, error) {
-	return s.salesRepo.List(filter)
+// GetRetentionPolicy returns the saved retention policy, or the default policy
+// if none has been saved
+func (s *Service) GetRetentionPolicy() (models.RetentionPolicy, error) {
+	policy := models.DefaultRetentionPolicy()
+	if _, err := s.settingsRepo.Get(settingRetentionPolicy, &policy); err != nil {
+		return policy, err
+	}
+	return policy, nil
 }
 
-// CreateSalesRecordsBatch creates multiple sales records in a single transaction
-func (s *Service) CreateSalesRecordsBatch(records []models.CreateSalesRecordRequest) ([]models.SalesRecord, error) {
-	return s.salesRepo.CreateBatch(records)
-}
+// SaveRetentionPolicy validates and stores the retention policy and records
+// the change in the audit log
+func (s *Service) SaveRetentionPolicy(policy models.RetentionPolicy) error {
+	if err := validateRetentionPolicy(policy); err != nil {
+		return err
+	}
 
-// GetDatabaseStats returns overall database statistics
-func (s *Service) GetDatabaseStats() (*models.DatabaseStats, error) {
-	return s.salesRepo.GetStats()
+	return s.ExecTx(func(tx *Service) error {
+		if err := tx.settingsRepo.Set(settingRetentionPolicy, policy); err != nil {
+			return err
+		}
+
+		entityType := "setting"
+		_, err := tx.auditRepo.Create(models.AuditEntry{
+			Action:     models.AuditSettingsUpdated,
+			EntityType: &entityType,
+			Details:    "Retention policy: " + describeRetentionPolicy(policy),
+		})
+		return err
+	})
 }
 
-// ===== REPORTING OPERATIONS =====
+// ApplyRetention archives and purges data according to policy relative to
+// now, and records each rule that changed data in the audit log. Rules set to
+// 0 are skipped; Enabled only controls the maintenance scheduler.
+func (s *Service) ApplyRetention(policy models.RetentionPolicy, now time.Time) (*models.RetentionResult, error) {
+	if err := validateRetentionPolicy(policy); err != nil {
+		return nil, err
+	}
 
-// GetYearlySummary returns yearly sales summary
-func (s *Service) GetYearlySummary() ([]models.YearlySummary, error) {
-	return s.reportingRepo.GetYearlySummary()
-}
+	var result *models.RetentionResult
+	err := s.ExecTx(func(tx *Service) error {
+		var err error
+		result, err = tx.retentionRepo.Apply(policy, now)
+		if err != nil {
+			return err
+		}
 
-// GetMonthlySummary returns monthly sales summary, optionally filtered by year
-func (s *Service) GetMonthlySummary(year *string) ([]models.MonthlySummary, error) {
-	return s.reportingRepo.GetMonthlySummary(year)
-}
+		for _, entry := range retentionAuditEntries(policy, *result, now) {
+			if _, err := tx.auditRepo.Create(entry); err != nil {
+				return err
+			}
+		}
 
-// GetDailySummary returns daily sales summary, optionally filtered by year and month
-func (s *Service) GetDailySummary(year *string, month *string) ([]models.DailySummary, error) {
-	return s.reportingRepo.GetDailySummary(year, month)
-}
+		if result.Changed() {
+			tx.emitChange(EventRetentionApplied, *result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-// GetStorePerformance returns store performance analytics
-func (s *Service) GetStorePerformance() ([]models.StorePerformance, error) {
-	return s.reportingRepo.GetStorePerformance()
+	return result, nil
 }
 
-// GetVendorPerformance returns vendor performance analytics
-func (s *Service) GetVendorPerformance() ([]models.VendorPerformance, error) {
-	return s.reportingRepo.GetVendorPerformance()
-}
+// PreviewRetention reports what ApplyRetention would change without
+// changing anything
+func (s *Service) PreviewRetention(policy models.RetentionPolicy, now time.Time) (*models.RetentionResult, error) {
+	var result *models.RetentionResult
+	err := s.DryRun(func(tx *Service) error {
+		var err error
+		result, err = tx.ApplyRetention(policy, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 
-// GetPivotTableData returns hierarchical data for pivot table display
-func (s *Service) GetPivotTableData(year *string) (*PivotTableData, error) {
-	return s.reportingRepo.GetPivotTableData(year)
+	result.DryRun = true
+	return result, nil
 }
 
-// GetDrillDownData returns detailed records for a specific time period
-func (s *Service) GetDrillDownData(year string, month *string, day *string) ([]models.SalesRecord, error) {
-	return s.reportingRepo.GetDrillDownData(year, month, day)
+// ListAuditLog retrieves the most recent audit entries, newest first
+func (s *Service) ListAuditLog(limit int) ([]models.AuditEntry, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	return s.auditRepo.List(limit)
 }
 
-// GetCustomSummary returns custom aggregated data
-func (s *Service) GetCustomSummary(groupBy string, year *string, store *string, vendor *string) ([]models.SalesSummary, error) {
-	return s.reportingRepo.GetCustomSummary(groupBy, year, store, vendor)
+// retentionAuditEntries returns an audit entry for each rule of a retention
+// run that changed data
+func retentionAuditEntries(policy models.RetentionPolicy, result models.RetentionResult, now time.Time) []models.AuditEntry {
+	var entries []models.AuditEntry
+	if result.ArchivedRecords > 0 || result.ArchivedAdjustments > 0 {
+		entries = append(entries, models.AuditEntry{
+			Action: models.AuditRetentionArchived,
+			Details: fmt.Sprintf("Archived %d sales records and %d adjustments dated before %s",
+				result.ArchivedRecords, result.ArchivedAdjustments, archiveCutoff(policy, now)),
+		})
+	}
+	if result.PurgedDeletedRecords > 0 {
+		entries = append(entries, models.AuditEntry{
+			Action: models.AuditRetentionPurgedDeleted,
+			Details: fmt.Sprintf("Purged %d sales records deleted more than %d days ago",
+				result.PurgedDeletedRecords, policy.PurgeDeletedAfterDays),
+		})
+	}
+	if result.PurgedImportRuns > 0 {
+		entries = append(entries, models.AuditEntry{
+			Action: models.AuditRetentionPurgedImports,
+			Details: fmt.Sprintf("Purged %d import summaries older than %d days",
+				result.PurgedImportRuns, policy.PurgeImportHistoryAfterDays),
+		})
+	}
+	return entries
 }
 
 // ===== MIGRATION OPERATIONS =====
 
 // RunMigrations executes all pending database migrations
 func (s *Service) RunMigrations() error {
+	release, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	defer s.invalidateCache()
 	return s.db.Migrate()
 }
 
@@ -138,8 +2474,21 @@ func (s *Service) GetMigrationStatus() ([]MigrationStatus, error) {
 	return s.db.GetMigrationStatus()
 }
 
+// CheckSchemaCompatibility reports whether this build can work with the database schema
+func (s *Service) CheckSchemaCompatibility() (*SchemaCompatibility, error) {
+	return s.db.CheckSchemaCompatibility()
+}
+
 // ResetDatabase drops all tables and re-runs migrations (USE WITH CAUTION)
 func (s *Service) ResetDatabase() error {
+	release, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	defer s.invalidateCache()
+
 	if err := s.db.ResetDatabase(); err != nil {
 		return err
 	}
@@ -158,18 +2507,95 @@ func (s *Service) GetTableInfo() ([]string, error) {
 	return s.db.GetTableInfo()
 }
 
+// withTx returns a copy of the service whose repositories run inside tx and
+// whose change events are queued in pending
+func (s *Service) withTx(tx *sql.Tx, pending *[]changeEvent) *Service {
+	return &Service{
+		db:               s.db,
+		tx:               tx,
+		cache:            s.cache,
+		aggregates:       s.aggregates,
+		feed:             s.feed,
+		maintenance:      s.maintenance,
+		writes:           s.writes,
+		pending:          pending,
+		rowImport:        s.rowImport,
+		salesRepo:        s.salesRepo.WithTx(tx),
+		reportingRepo:    s.reportingRepo.WithTx(tx),
+		exchangeRepo:     s.exchangeRepo.WithTx(tx),
+		commissionRepo:   s.commissionRepo.WithTx(tx),
+		payoutRepo:       s.payoutRepo.WithTx(tx),
+		aliasRepo:        s.aliasRepo.WithTx(tx),
+		snapshotRepo:     s.snapshotRepo.WithTx(tx),
+		adjustmentRepo:   s.adjustmentRepo.WithTx(tx),
+		feeRepo:          s.feeRepo.WithTx(tx),
+		quarantineRepo:   s.quarantineRepo.WithTx(tx),
+		notificationRepo: s.notificationRepo.WithTx(tx),
+		apiTokenRepo:     s.apiTokenRepo.WithTx(tx),
+		periodRepo:       s.periodRepo.WithTx(tx),
+		importRepo:       s.importRepo.WithTx(tx),
+		retentionRepo:    s.retentionRepo.WithTx(tx),
+		settingsRepo:     s.settingsRepo.WithTx(tx),
+		auditRepo:        s.auditRepo.WithTx(tx),
+	}
+}
+
 // ExecTx executes a function within a transaction
-// NOTE: Current limitation - the callback receives the original Service which uses
-// the main connection, not the transaction. For true transactional operations,
-// use the database layer's ExecTx method directly or implement transaction-aware repositories.
-// This method is primarily for coordinating multiple service-level operations.
+// The callback receives a Service bound to the transaction, so every operation
+// it performs is committed together or rolled back together. If the service is
+// already bound to a transaction the callback joins it. Change events are
+// emitted only once the transaction commits.
 func (s *Service) ExecTx(fn func(*Service) error) error {
-	return s.db.ExecTx(func(tx *sql.Tx) error {
-		// The service passed to the callback uses the main connection, not the transaction
-		// This is a design limitation that should be addressed in future versions
-		// by implementing transaction-aware repositories
+	if s.tx != nil {
 		return fn(s)
+	}
+
+	release, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Invalidate again once the transaction has ended, so results read while it
+	// was in progress are not served afterwards
+	defer s.invalidateCache()
+
+	var pending []changeEvent
+	err = s.db.ExecTx(func(tx *sql.Tx) error {
+		return fn(s.withTx(tx, &pending))
 	})
+	if err != nil {
+		return err
+	}
+
+	s.flushChanges(pending)
+	return nil
+}
+
+// DryRun executes a function within a transaction that is always rolled back.
+// The callback sees its own writes (generated IDs, defaults, constraint checks)
+// but nothing is persisted. The callback's error, if any, is returned.
+func (s *Service) DryRun(fn func(*Service) error) error {
+	if s.tx != nil {
+		return fmt.Errorf("dry run cannot be nested inside another transaction")
+	}
+
+	release, err := s.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	tx, err := s.db.BeginTx()
+	if err != nil {
+		return fmt.Errorf("failed to begin dry-run transaction: %w", err)
+	}
+	defer tx.Rollback()
+	defer s.invalidateCache()
+
+	// Nothing is persisted, so change events are discarded
+	var discarded []changeEvent
+	return fn(s.withTx(tx, &discarded))
 }
 
 // ===== CONVENIENCE METHODS =====
@@ -177,6 +2603,14 @@ func (s *Service) ExecTx(fn func(*Service) error) error {
 // ImportSalesData is a convenience method for importing sales data
 // It validates the data and creates records in batches for better performance
 func (s *Service) ImportSalesData(records []models.CreateSalesRecordRequest) (*ImportResult, error) {
+	release, err := s.beginWrite()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	defer s.invalidateCache()
+
 	if len(records) == 0 {
 		return &ImportResult{
 			TotalRecords:    0,
@@ -200,14 +2634,21 @@ func (s *Service) ImportSalesData(records []models.CreateSalesRecordRequest) (*I
 
 	// Import valid records
 	var createdRecords []models.SalesRecord
+	var updated int
 	if len(validRecords) > 0 {
 		var err error
-		createdRecords, err = s.salesRepo.CreateBatch(validRecords)
+		createdRecords, updated, err = s.salesRepo.CreateBatchCounted(validRecords)
 		if err != nil {
 			return nil, fmt.Errorf("failed to import sales data: %w", err)
 		}
 	}
 
+	s.emitChange(EventImportCompleted, ImportCompletedEvent{
+		Inserted: len(createdRecords) - updated,
+		Updated:  updated,
+		Failed:   len(records) - len(createdRecords),
+	})
+
 	return &ImportResult{
 		TotalRecords:      len(records),
 		SuccessfulRecords: len(createdRecords),
@@ -226,28 +2667,511 @@ type ImportResult struct {
 	CreatedRecords    []models.SalesRecord `json:"created_records,omitempty"`
 }
 
+// validateExchangeRate checks a rate and returns it with its currency normalized
+func validateExchangeRate(rate models.CreateExchangeRateRequest) (models.CreateExchangeRateRequest, error) {
+	rate.Currency = models.NormalizeCurrency(rate.Currency)
+	if !models.IsCurrencyCode(rate.Currency) {
+		return rate, invalidf("currency must be a three-letter ISO 4217 code")
+	}
+	if rate.Currency == models.ReferenceCurrency {
+		return rate, invalidf("rates are quoted per 1 %s, so %s cannot have its own rate", models.ReferenceCurrency, models.ReferenceCurrency)
+	}
+	if rate.Date == "" {
+		return rate, invalidf("date is required")
+	}
+	if rate.Rate <= 0 {
+		return rate, invalidf("rate must be positive")
+	}
+	return rate, nil
+}
+
+// validateCommissionRule trims and checks a commission rule
+func validateCommissionRule(rule models.CreateCommissionRuleRequest) (models.CreateCommissionRuleRequest, error) {
+	rule.Store = strings.TrimSpace(rule.Store)
+	if rule.Store == "" {
+		return rule, invalidf("store is required")
+	}
+	if rule.EffectiveFrom == "" {
+		return rule, invalidf("effective date is required")
+	}
+	if rule.Rate < 0 || rule.Rate > 1 {
+		return rule, invalidf("rate must be a fraction between 0 and 1")
+	}
+	return rule, nil
+}
+
+// validatePayoutSchedule trims and checks a payout schedule
+func validatePayoutSchedule(schedule models.SavePayoutScheduleRequest) (models.SavePayoutScheduleRequest, error) {
+	schedule.Store = strings.TrimSpace(schedule.Store)
+	if schedule.Store == "" {
+		return schedule, invalidf("store is required")
+	}
+	if schedule.PayoutDay < 1 || schedule.PayoutDay > 31 {
+		return schedule, invalidf("payout day must be between 1 and 31")
+	}
+	if schedule.MonthsAfter < 0 || schedule.MonthsAfter > models.MaxPayoutMonthsAfter {
+		return schedule, invalidf("payouts must follow the sale month by 0 to %d months", models.MaxPayoutMonthsAfter)
+	}
+	return schedule, nil
+}
+
+// validateNameAlias trims and checks an alias to save
+func validateNameAlias(alias models.SaveNameAliasRequest) (models.SaveNameAliasRequest, error) {
+	alias.Kind = strings.ToLower(strings.TrimSpace(alias.Kind))
+	alias.Alias = strings.TrimSpace(alias.Alias)
+	alias.Canonical = strings.TrimSpace(alias.Canonical)
+	if alias.Kind != models.AliasKindStore && alias.Kind != models.AliasKindVendor {
+		return alias, invalidf("alias kind must be %q or %q", models.AliasKindStore, models.AliasKindVendor)
+	}
+	if alias.Alias == "" || alias.Canonical == "" {
+		return alias, invalidf("alias and canonical name are required")
+	}
+	if len(alias.Alias) > 100 || len(alias.Canonical) > 100 {
+		return alias, invalidf("alias and canonical name must be at most 100 characters")
+	}
+	if alias.Alias == alias.Canonical {
+		return alias, invalidf("alias must differ from the canonical name")
+	}
+	return alias, nil
+}
+
+// validateBaseCurrency normalizes and checks a reporting base currency
+func validateBaseCurrency(currency string) (string, error) {
+	base := models.NormalizeCurrency(currency)
+	if !models.IsCurrencyCode(base) {
+		return "", invalidf("base currency must be a three-letter ISO 4217 code")
+	}
+	return base, nil
+}
+
+// validateReportOptions normalizes the base currency and date basis of report
+// options
+func validateReportOptions(opts models.ReportOptions) (models.ReportOptions, error) {
+	basis, err := validateDateBasis(opts.DateBasis)
+	if err != nil {
+		return opts, err
+	}
+	opts.DateBasis = basis
+	if opts.BaseCurrency == "" {
+		return opts, nil
+	}
+	base, err := validateBaseCurrency(opts.BaseCurrency)
+	if err != nil {
+		return opts, err
+	}
+	opts.BaseCurrency = base
+	return opts, nil
+}
+
+// validateDrillDownRequest checks the period, sort and columns of a
+// drill-down, padding the month and day to two digits and filling in the
+// default sort and columns
+func validateDrillDownRequest(req models.DrillDownRequest) (models.DrillDownRequest, error) {
+	if year, err := strconv.Atoi(req.Year); err != nil || len(req.Year) != 4 || year < 1 {
+		return req, invalidf("year must be YYYY")
+	}
+	if req.Month != nil {
+		month, err := strconv.Atoi(*req.Month)
+		if err != nil || month < 1 || month > 12 {
+			return req, invalidf("month must be between 01 and 12")
+		}
+		padded := fmt.Sprintf("%02d", month)
+		req.Month = &padded
+	}
+	if req.Day != nil {
+		if req.Month == nil {
+			return req, invalidf("a day requires a month")
+		}
+		day, err := strconv.Atoi(*req.Day)
+		if err != nil || day < 1 || day > 31 {
+			return req, invalidf("day must be between 01 and 31")
+		}
+		padded := fmt.Sprintf("%02d", day)
+		req.Day = &padded
+	}
+
+	req.SortBy = strings.ToLower(strings.TrimSpace(req.SortBy))
+	if req.SortBy == "" {
+		req.SortBy = "date"
+	}
+	if !slices.Contains(models.DrillDownSortFields, req.SortBy) {
+		return req, invalidf("drill-down cannot be sorted by %q", req.SortBy)
+	}
+	req.SortOrder = strings.ToLower(strings.TrimSpace(req.SortOrder))
+	if req.SortOrder == "" {
+		req.SortOrder = "desc"
+	}
+	if !validSortOrders[req.SortOrder] {
+		return req, invalidf("sort order must be asc or desc")
+	}
+
+	if len(req.Columns) == 0 {
+		req.Columns = slices.Clone(models.DrillDownColumns)
+		return req, nil
+	}
+	for i, column := range req.Columns {
+		if !slices.Contains(models.DrillDownColumns, column) {
+			return req, invalidf("unknown drill-down column %q", column)
+		}
+		if slices.Contains(req.Columns[:i], column) {
+			return req, invalidf("drill-down column %q is repeated", column)
+		}
+	}
+	return req, nil
+}
+
+// validateDateBasis checks the date a summary buckets records by, defaulting
+// to the sale date
+func validateDateBasis(basis string) (string, error) {
+	switch basis {
+	case "":
+		return models.DateBasisSale, nil
+	case models.DateBasisSale, models.DateBasisImport:
+		return basis, nil
+	}
+	return basis, invalidf("date basis must be %q or %q", models.DateBasisSale, models.DateBasisImport)
+}
+
+// validateRetentionPolicy checks that no retention period is negative
+func validateRetentionPolicy(policy models.RetentionPolicy) error {
+	if policy.PurgeDeletedAfterDays < 0 || policy.ArchiveAfterYears < 0 || policy.PurgeImportHistoryAfterDays < 0 {
+		return invalidf("retention periods cannot be negative")
+	}
+	return nil
+}
+
+// describeRetentionPolicy returns a human-readable summary of a policy for the audit log
+func describeRetentionPolicy(policy models.RetentionPolicy) string {
+	rule := func(days int, unit string) string {
+		if days == 0 {
+			return "never"
+		}
+		return fmt.Sprintf("after %d %s", days, unit)
+	}
+	schedule := "manual"
+	if policy.Enabled {
+		schedule = "automatic"
+	}
+	return fmt.Sprintf("purge deleted records %s, archive sales %s, purge import history %s (%s)",
+		rule(policy.PurgeDeletedAfterDays, "days"),
+		rule(policy.ArchiveAfterYears, "years"),
+		rule(policy.PurgeImportHistoryAfterDays, "days"),
+		schedule)
+}
+
+// maxCurrencySymbolLen is the longest currency symbol, in bytes, such as "CHF"
+const maxCurrencySymbolLen = 8
+
+// validateExportFormat checks that an export format's separators are known
+// and cannot be confused with each other
+func validateExportFormat(format models.ExportFormat) error {
+	switch format.DecimalSeparator {
+	case ".", ",":
+	default:
+		return invalidf("decimal separator must be \".\" or \",\"")
+	}
+	switch format.ThousandsSeparator {
+	case "", ",", ".", " ", "'":
+	default:
+		return invalidf("thousands separator must be empty, \",\", \".\", a space or \"'\"")
+	}
+	if format.ThousandsSeparator == format.DecimalSeparator {
+		return invalidf("thousands and decimal separators must differ")
+	}
+	switch format.CSVDelimiter {
+	case ",", ";", "\t":
+	default:
+		return invalidf("CSV delimiter must be \",\", \";\" or a tab")
+	}
+	if format.CSVDelimiter == format.DecimalSeparator || format.CSVDelimiter == format.ThousandsSeparator {
+		return invalidf("CSV delimiter %q cannot also separate the digits of amounts", format.CSVDelimiter)
+	}
+	if format.SymbolPlacement != models.SymbolBefore && format.SymbolPlacement != models.SymbolAfter {
+		return invalidf("symbol placement must be %q or %q", models.SymbolBefore, models.SymbolAfter)
+	}
+	if len(format.CurrencySymbol) > maxCurrencySymbolLen {
+		return invalidf("currency symbol is longer than %d bytes", maxCurrencySymbolLen)
+	}
+	if _, ok := models.ExportDateFormats[format.DateFormat]; !ok {
+		return invalidf("unknown date format %q", format.DateFormat)
+	}
+	return nil
+}
+
+// describeExportFormat returns a human-readable summary of an export format
+// for the audit log
+func describeExportFormat(format models.ExportFormat) string {
+	delimiter := format.CSVDelimiter
+	if delimiter == "\t" {
+		delimiter = "tab"
+	}
+	return fmt.Sprintf("amounts as %s, dates as %s, CSV delimited by %s",
+		format.FormatMoney(-1234.5), format.DateFormat, delimiter)
+}
+
+// validateIgnoreRules checks that each rule names a field it can match and a
+// valid regular expression, returning the rules with patterns trimmed
+func validateIgnoreRules(rules []models.IgnoreRule) ([]models.IgnoreRule, error) {
+	if len(rules) > models.MaxIgnoreRules {
+		return nil, invalidf("at most %d ignore rules can be saved", models.MaxIgnoreRules)
+	}
+
+	validated := make([]models.IgnoreRule, 0, len(rules))
+	for i, rule := range rules {
+		rule.Pattern = strings.TrimSpace(rule.Pattern)
+		if rule.Field != models.IgnoreFieldDescription && rule.Field != models.IgnoreFieldStore {
+			return nil, invalidf("ignore rule %d must match the %s or %s", i+1, models.IgnoreFieldDescription, models.IgnoreFieldStore)
+		}
+		if rule.Pattern == "" {
+			return nil, invalidf("ignore rule %d has no pattern", i+1)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return nil, invalidf("ignore rule %d has an invalid pattern: %v", i+1, err)
+		}
+		if rule.FeeCategory != "" && !models.IsFeeCategory(rule.FeeCategory) {
+			return nil, invalidf("ignore rule %d has an unknown fee category %q", i+1, rule.FeeCategory)
+		}
+		validated = append(validated, rule)
+	}
+	return validated, nil
+}
+
+// describeIgnoreRules summarizes ignore rules for the audit log, such as
+// `description ~ "LISTING FEE"`
+func describeIgnoreRules(rules []models.IgnoreRule) string {
+	if len(rules) == 0 {
+		return "none"
+	}
+	described := make([]string, len(rules))
+	for i, rule := range rules {
+		described[i] = fmt.Sprintf("%s ~ %q", rule.Field, rule.Pattern)
+		if rule.FeeCategory != "" {
+			described[i] += " as " + rule.FeeCategory + " fees"
+		}
+	}
+	return strings.Join(described, ", ")
+}
+
+// validateFee checks a fee and returns it with its text trimmed and its
+// category defaulted
+func validateFee(fee models.CreateFeeRequest) (models.CreateFeeRequest, error) {
+	fee.Store = strings.TrimSpace(fee.Store)
+	fee.Description = strings.TrimSpace(fee.Description)
+	if fee.Vendor != nil {
+		vendor := strings.TrimSpace(*fee.Vendor)
+		fee.Vendor = &vendor
+		if vendor == "" {
+			fee.Vendor = nil
+		}
+	}
+	if fee.Category == "" {
+		fee.Category = models.FeeCategoryOther
+	}
+
+	if fee.Store == "" {
+		return fee, invalidf("store is required")
+	}
+	if fee.Date == "" {
+		return fee, invalidf("date is required")
+	}
+	if _, err := time.Parse("2006-01-02", fee.Date); err != nil {
+		return fee, invalidf("date must be YYYY-MM-DD")
+	}
+	if fee.Description == "" {
+		return fee, invalidf("description is required")
+	}
+	if fee.Amount < 0 || math.IsNaN(fee.Amount) {
+		return fee, invalidf("fee amount cannot be negative")
+	}
+	if !models.IsFeeCategory(fee.Category) {
+		return fee, invalidf("unknown fee category %q", fee.Category)
+	}
+	return fee, nil
+}
+
+// validateAdjustment performs basic validation on an adjustment
+func validateAdjustment(adjustment models.CreateSalesAdjustmentRequest) (models.CreateSalesAdjustmentRequest, error) {
+	adjustment.Reason = strings.TrimSpace(adjustment.Reason)
+	if adjustment.SalesRecordID <= 0 {
+		return adjustment, invalidf("sales record ID is required")
+	}
+	if adjustment.Date == "" {
+		return adjustment, invalidf("date is required")
+	}
+	if adjustment.Reason == "" {
+		return adjustment, invalidf("reason is required")
+	}
+	if adjustment.SalePriceDelta == 0 && adjustment.CommissionDelta == nil && adjustment.RemainingDelta == nil {
+		return adjustment, invalidf("adjustment must change at least one amount")
+	}
+	return adjustment, nil
+}
+
 // validateSalesRecord performs basic validation on a sales record
 func validateSalesRecord(record models.CreateSalesRecordRequest) error {
 	if record.Store == "" {
-		return fmt.Errorf("store is required")
+		return invalidf("store is required")
 	}
 	if record.Vendor == "" {
-		return fmt.Errorf("vendor is required")
+		return invalidf("vendor is required")
 	}
 	if record.Date == "" {
-		return fmt.Errorf("date is required")
+		return invalidf("date is required")
 	}
 	if record.Description == "" {
-		return fmt.Errorf("description is required")
+		return invalidf("description is required")
 	}
 	if record.SalePrice < 0 {
-		return fmt.Errorf("sale price cannot be negative")
+		return invalidf("sale price cannot be negative")
+	}
+	if record.Commission != nil && *record.Commission < 0 {
+		return invalidf("commission cannot be negative")
+	}
+	if record.Remaining != nil && *record.Remaining < 0 {
+		return invalidf("remaining cannot be negative")
+	}
+	if record.Currency != nil && *record.Currency != "" && !models.IsCurrencyCode(*record.Currency) {
+		return invalidf("currency must be a three-letter ISO 4217 code")
+	}
+	if err := validateCostAndCategory(record.Cost, record.Category); err != nil {
+		return err
+	}
+	return validateMetadata(record.Metadata)
+}
+
+// maxCategoryLen is the longest category a record can have
+const maxCategoryLen = 100
+
+// validateCostAndCategory checks a record's optional item cost and category
+func validateCostAndCategory(cost *float64, category *string) error {
+	if cost != nil && *cost < 0 {
+		return invalidf("cost cannot be negative")
+	}
+	if category != nil && len(*category) > maxCategoryLen {
+		return invalidf("category is longer than %d characters", maxCategoryLen)
+	}
+	return nil
+}
+
+// Limits on tags
+const (
+	maxTags      = 50
+	maxTagLength = 50
+)
+
+// validateTags checks the tags of a record
+func validateTags(tags []string) error {
+	if len(tags) > maxTags {
+		return invalidf("a record can have at most %d tags", maxTags)
+	}
+	for _, tag := range tags {
+		if len(tag) > maxTagLength {
+			return invalidf("tag %q is longer than %d characters", tag, maxTagLength)
+		}
+	}
+	return nil
+}
+
+// validateEnrichment normalizes the tags of an enrichment and checks that it
+// changes something
+func validateEnrichment(req models.EnrichmentRequest) (models.EnrichmentRequest, error) {
+	req.AddTags = models.NormalizeTags(req.AddTags)
+	req.RemoveTags = models.NormalizeTags(req.RemoveTags)
+	if req.Cost == nil && req.Category == nil && len(req.AddTags) == 0 && len(req.RemoveTags) == 0 {
+		return req, invalidf("enrichment must set a cost, a category or tags")
+	}
+	if err := validateCostAndCategory(req.Cost, req.Category); err != nil {
+		return req, err
+	}
+	if err := validateTags(req.AddTags); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// describeEnrichment returns a human-readable summary of an enrichment for
+// the audit log
+func describeEnrichment(req models.EnrichmentRequest, updated int64) string {
+	var changes []string
+	if req.Cost != nil {
+		changes = append(changes, fmt.Sprintf("cost %.2f", *req.Cost))
+	}
+	if req.Category != nil {
+		changes = append(changes, fmt.Sprintf("category %q", *req.Category))
+	}
+	if len(req.AddTags) > 0 {
+		changes = append(changes, "added tags "+strings.Join(req.AddTags, ", "))
+	}
+	if len(req.RemoveTags) > 0 {
+		changes = append(changes, "removed tags "+strings.Join(req.RemoveTags, ", "))
 	}
-	if record.Commission < 0 {
-		return fmt.Errorf("commission cannot be negative")
+	details := fmt.Sprintf("Enriched %d sales records: %s", updated, strings.Join(changes, "; "))
+	if req.OnlyMissing {
+		details += ", keeping existing costs and categories"
 	}
-	if record.Remaining < 0 {
-		return fmt.Errorf("remaining cannot be negative")
+	return details
+}
+
+// describeRecordChanges lists the fields of a record that changed, with their
+// old and new values, or returns "" if none did
+func describeRecordChanges(before, after models.SalesRecord) string {
+	var changes []string
+	change := func(field, old, new string) {
+		if old != new {
+			changes = append(changes, fmt.Sprintf("%s %s → %s", field, old, new))
+		}
+	}
+	amount := func(value *float64) string {
+		if value == nil {
+			return "unknown"
+		}
+		return fmt.Sprintf("%.2f", *value)
+	}
+	text := func(value *string) string {
+		if value == nil || *value == "" {
+			return "none"
+		}
+		return strconv.Quote(*value)
+	}
+
+	change("store", strconv.Quote(before.Store), strconv.Quote(after.Store))
+	change("vendor", strconv.Quote(before.Vendor), strconv.Quote(after.Vendor))
+	change("date", before.Date.String(), after.Date.String())
+	change("description", strconv.Quote(before.Description), strconv.Quote(after.Description))
+	change("sale price", amount(&before.SalePrice), amount(&after.SalePrice))
+	change("commission", amount(before.Commission), amount(after.Commission))
+	change("remaining", amount(before.Remaining), amount(after.Remaining))
+	change("return", strconv.FormatBool(before.IsReturn), strconv.FormatBool(after.IsReturn))
+	change("cost", amount(before.Cost), amount(after.Cost))
+	change("category", text(before.Category), text(after.Category))
+	change("tags", "["+strings.Join(before.Tags, ", ")+"]", "["+strings.Join(after.Tags, ", ")+"]")
+	if !before.Metadata.Equal(after.Metadata) {
+		changes = append(changes, "custom fields")
+	}
+	return strings.Join(changes, "; ")
+}
+
+// Limits on custom fields
+const (
+	maxMetadataFields  = 50
+	maxMetadataNameLen = 100
+)
+
+// validateMetadata checks the names of custom fields. Names are quoted in
+// JSON paths, so they cannot contain double quotes.
+func validateMetadata(metadata models.Metadata) error {
+	if len(metadata) > maxMetadataFields {
+		return invalidf("a record can have at most %d custom fields", maxMetadataFields)
+	}
+	for name := range metadata {
+		switch {
+		case strings.TrimSpace(name) == "":
+			return invalidf("custom field names cannot be empty")
+		case len(name) > maxMetadataNameLen:
+			return invalidf("custom field name %q is longer than %d characters", name, maxMetadataNameLen)
+		case strings.Contains(name, `"`):
+			return invalidf("custom field name %q cannot contain double quotes", name)
+		}
 	}
 	return nil
 }
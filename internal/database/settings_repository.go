@@ -0,0 +1,84 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// Setting keys stored in app_settings
+const (
+	settingRetentionPolicy   = "retention_policy"
+	settingLastBackup        = "last_backup"
+	settingExportFormat      = "export_format"
+	settingIgnoreRules       = "ignore_rules"
+	settingAppLock           = "app_lock"
+	settingWeekStart         = "week_start"
+	settingMappingProfiles   = "mapping_profiles"
+	settingReportPerformance = "report_performance"
+	settingMemoryBudget      = "memory_budget"
+	settingQueryTimeouts     = "query_timeouts"
+	settingDigestSchedule    = "digest_schedule"
+	settingLastDigest        = "last_digest"
+)
+
+// SettingsRepository stores application settings as JSON values
+type SettingsRepository struct {
+	db *DB
+	q  queryer
+}
+
+// NewSettingsRepository creates a new settings repository
+func NewSettingsRepository(db *DB) *SettingsRepository {
+	return &SettingsRepository{db: db, q: db.conn}
+}
+
+// WithTx returns a copy of the repository whose operations run inside tx
+func (r *SettingsRepository) WithTx(tx *sql.Tx) *SettingsRepository {
+	return &SettingsRepository{db: r.db, q: tx}
+}
+
+// Get decodes the setting stored under key into dest. It reports false, and
+// leaves dest untouched, when the setting has never been saved.
+func (r *SettingsRepository) Get(key string, dest interface{}) (bool, error) {
+	var value string
+	err := r.q.QueryRow("SELECT value FROM app_settings WHERE key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get setting %s: %w", key, err)
+	}
+
+	if err := json.Unmarshal([]byte(value), dest); err != nil {
+		return false, fmt.Errorf("failed to decode setting %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// Set stores value under key, replacing any previous value
+func (r *SettingsRepository) Set(key string, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode setting %s: %w", key, err)
+	}
+
+	query := `
+		INSERT INTO app_settings (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET
+			value = excluded.value,
+			updated_at = CURRENT_TIMESTAMP`
+
+	if _, err := r.q.Exec(query, key, string(encoded)); err != nil {
+		return fmt.Errorf("failed to save setting %s: %w", key, err)
+	}
+	return nil
+}
+
+// Delete removes the setting stored under key, if any
+func (r *SettingsRepository) Delete(key string) error {
+	if _, err := r.q.Exec("DELETE FROM app_settings WHERE key = ?", key); err != nil {
+		return fmt.Errorf("failed to delete setting %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"sales-track/internal/models"
+)
+
+// reportSnapshotColumns is the column list selected for a report snapshot, in
+// the order expected by scanReportSnapshot
+const reportSnapshotColumns = "id, name, filter, totals, created_at"
+
+// SnapshotRepository handles database operations for report snapshots and
+// the records they keep
+type SnapshotRepository struct {
+	db *DB
+	q  queryer
+}
+
+// NewSnapshotRepository creates a new snapshot repository
+func NewSnapshotRepository(db *DB) *SnapshotRepository {
+	return &SnapshotRepository{db: db, q: db.conn}
+}
+
+// WithTx returns a copy of the repository whose operations run inside tx
+func (r *SnapshotRepository) WithTx(tx *sql.Tx) *SnapshotRepository {
+	return &SnapshotRepository{db: r.db, q: tx}
+}
+
+// scanReportSnapshot scans a row selected with reportSnapshotColumns
+func scanReportSnapshot(scanner rowScanner, snapshot *models.ReportSnapshot) error {
+	var filter, totals string
+	if err := scanner.Scan(&snapshot.ID, &snapshot.Name, &filter, &totals, &snapshot.CreatedAt); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(filter), &snapshot.Filter); err != nil {
+		return fmt.Errorf("invalid snapshot filter: %w", err)
+	}
+	if err := json.Unmarshal([]byte(totals), &snapshot.Totals); err != nil {
+		return fmt.Errorf("invalid snapshot totals: %w", err)
+	}
+	return nil
+}
+
+// Create stores a snapshot of records, taken by the report filter selects.
+// Callers run it in a transaction so the snapshot is kept whole or not at all.
+func (r *SnapshotRepository) Create(name string, filter models.SalesRecordFilter, records []models.SalesRecord) (*models.ReportSnapshot, error) {
+	var totals models.ReportTotals
+	for _, record := range records {
+		totals.Add(record)
+	}
+
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode snapshot filter: %w", err)
+	}
+	totalsJSON, err := json.Marshal(totals)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode snapshot totals: %w", err)
+	}
+
+	var snapshot models.ReportSnapshot
+	err = scanReportSnapshot(r.q.QueryRow(`
+		INSERT INTO report_snapshots (name, filter, totals)
+		VALUES (?, ?, ?)
+		RETURNING `+reportSnapshotColumns, name, string(filterJSON), string(totalsJSON)), &snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert report snapshot: %w", err)
+	}
+
+	insert, err := r.q.Prepare("INSERT INTO report_snapshot_records (snapshot_id, record_id, record) VALUES (?, ?, ?)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare snapshot record insert: %w", err)
+	}
+	defer insert.Close()
+
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode record %d: %w", record.ID, err)
+		}
+		if _, err := insert.Exec(snapshot.ID, record.ID, string(data)); err != nil {
+			return nil, fmt.Errorf("failed to insert snapshot record: %w", err)
+		}
+	}
+
+	return &snapshot, nil
+}
+
+// List retrieves the snapshots, newest first
+func (r *SnapshotRepository) List() ([]models.ReportSnapshot, error) {
+	rows, err := r.q.Query("SELECT " + reportSnapshotColumns + " FROM report_snapshots ORDER BY created_at DESC, id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query report snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := []models.ReportSnapshot{}
+	for rows.Next() {
+		var snapshot models.ReportSnapshot
+		if err := scanReportSnapshot(rows, &snapshot); err != nil {
+			return nil, fmt.Errorf("failed to scan report snapshot: %w", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate report snapshots: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+// GetByID retrieves a snapshot by its ID
+func (r *SnapshotRepository) GetByID(id int64) (*models.ReportSnapshot, error) {
+	var snapshot models.ReportSnapshot
+	err := scanReportSnapshot(r.q.QueryRow("SELECT "+reportSnapshotColumns+" FROM report_snapshots WHERE id = ?", id), &snapshot)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("report snapshot with ID %d %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get report snapshot: %w", err)
+	}
+
+	return &snapshot, nil
+}
+
+// Records retrieves the records kept by a snapshot, as they were when it was
+// taken, ordered by ID
+func (r *SnapshotRepository) Records(snapshotID int64) ([]models.SalesRecord, error) {
+	rows, err := r.q.Query("SELECT record FROM report_snapshot_records WHERE snapshot_id = ? ORDER BY record_id", snapshotID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshot records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []models.SalesRecord
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot record: %w", err)
+		}
+		var record models.SalesRecord
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			return nil, fmt.Errorf("invalid snapshot record: %w", err)
+		}
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate snapshot records: %w", err)
+	}
+
+	return records, nil
+}
+
+// Delete removes a snapshot and the records it keeps
+func (r *SnapshotRepository) Delete(id int64) error {
+	result, err := r.q.Exec("DELETE FROM report_snapshots WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete report snapshot: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("report snapshot with ID %d %w", id, ErrNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,275 @@
+package database
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// MaintenanceWorkspaceExport is the maintenance operation of a workspace
+// export, reported in MaintenanceProgress while the database is copied
+const MaintenanceWorkspaceExport = "workspace_export"
+
+const (
+	// workspaceMagic starts every workspace archive
+	workspaceMagic = "STWSPC01"
+
+	// workspaceKeyIterations is the PBKDF2 work factor for archive keys
+	workspaceKeyIterations = 200000
+
+	// minWorkspacePassphrase is the shortest passphrase an archive is
+	// encrypted with
+	minWorkspacePassphrase = 8
+
+	// Entries of the zip archive inside the encryption
+	workspaceManifestEntry = "manifest.json"
+	workspaceDatabaseEntry = "sales_track.db"
+
+	// StagedWorkspaceSuffix is appended to the database path for a workspace
+	// imported with StageWorkspace, waiting for ApplyStagedWorkspace
+	StagedWorkspaceSuffix = ".workspace"
+
+	// ReplacedDatabaseSuffix is appended to the database path for the
+	// database a staged workspace replaced, kept in case the import was a
+	// mistake
+	ReplacedDatabaseSuffix = ".before-import"
+)
+
+// ExportWorkspace writes the whole database, which holds every record,
+// setting and rule, to destPath as one archive encrypted with passphrase, for
+// moving to another computer with StageWorkspace. The archive is an
+// AES-256-GCM encrypted zip of the database and a manifest, keyed with
+// PBKDF2-SHA256. Writes fail with ErrMaintenanceInProgress while the database
+// is copied.
+func (s *Service) ExportWorkspace(destPath, passphrase string, now time.Time) (*models.WorkspaceManifest, error) {
+	if len(passphrase) < minWorkspacePassphrase {
+		return nil, invalidf("passphrase must be at least %d characters", minWorkspacePassphrase)
+	}
+	if err := s.validateBackupPath(destPath); err != nil {
+		return nil, err
+	}
+
+	records, err := s.salesRepo.Count(models.SalesRecordFilter{})
+	if err != nil {
+		return nil, err
+	}
+	applied, _, err := s.db.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	manifest := &models.WorkspaceManifest{
+		FormatVersion: models.WorkspaceFormatVersion,
+		CreatedAt:     now.UTC(),
+		SchemaVersion: applied,
+		Records:       records,
+	}
+
+	dir, err := os.MkdirTemp("", "sales-track-workspace")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	copyPath := filepath.Join(dir, workspaceDatabaseEntry)
+	if err := s.claimMaintenance(MaintenanceWorkspaceExport); err != nil {
+		return nil, err
+	}
+	if err := s.runMaintenance(func() error { return s.copyDatabase(copyPath) }); err != nil {
+		return nil, err
+	}
+
+	database, err := os.ReadFile(copyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database copy: %w", err)
+	}
+	archive, err := sealWorkspace(manifest, database, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(destPath, archive, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write workspace archive: %w", err)
+	}
+	return manifest, nil
+}
+
+// sealWorkspace zips the manifest and database and encrypts them with a key
+// derived from passphrase. The header, magic, salt and iterations, is
+// authenticated along with the contents.
+func sealWorkspace(manifest *models.WorkspaceManifest, database []byte, passphrase string) ([]byte, error) {
+	var contents bytes.Buffer
+	archive := zip.NewWriter(&contents)
+	encoded, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode workspace manifest: %w", err)
+	}
+	for _, entry := range []struct {
+		name string
+		data []byte
+	}{{workspaceManifestEntry, encoded}, {workspaceDatabaseEntry, database}} {
+		w, err := archive.Create(entry.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to workspace archive: %w", entry.name, err)
+		}
+		if _, err := w.Write(entry.data); err != nil {
+			return nil, fmt.Errorf("failed to add %s to workspace archive: %w", entry.name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write workspace archive: %w", err)
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	header := binary.BigEndian.AppendUint32(append([]byte(workspaceMagic), salt...), workspaceKeyIterations)
+	aead, err := workspaceCipher(passphrase, salt, workspaceKeyIterations)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := append(header, nonce...)
+	return aead.Seal(sealed, nonce, contents.Bytes(), header), nil
+}
+
+// workspaceCipher returns the AES-256-GCM cipher keyed from passphrase
+func workspaceCipher(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2SHA256([]byte(passphrase), salt, iterations, sha256.Size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return aead, nil
+}
+
+// openWorkspace decrypts a workspace archive and returns its manifest and
+// database
+func openWorkspace(sealed []byte, passphrase string) (*models.WorkspaceManifest, []byte, error) {
+	const saltSize, headerSize = 16, len(workspaceMagic) + 16 + 4
+	if len(sealed) < headerSize || string(sealed[:len(workspaceMagic)]) != workspaceMagic {
+		return nil, nil, invalidf("not a workspace archive")
+	}
+	header := sealed[:headerSize]
+	salt := header[len(workspaceMagic) : len(workspaceMagic)+saltSize]
+	iterations := binary.BigEndian.Uint32(header[len(workspaceMagic)+saltSize:])
+	if iterations == 0 || iterations > 10*workspaceKeyIterations {
+		return nil, nil, invalidf("workspace archive is damaged")
+	}
+
+	aead, err := workspaceCipher(passphrase, salt, int(iterations))
+	if err != nil {
+		return nil, nil, err
+	}
+	rest := sealed[headerSize:]
+	if len(rest) < aead.NonceSize() {
+		return nil, nil, invalidf("workspace archive is damaged")
+	}
+	contents, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], header)
+	if err != nil {
+		return nil, nil, invalidf("wrong passphrase, or the workspace archive is damaged")
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(contents), int64(len(contents)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read workspace archive: %w", err)
+	}
+	read := func(name string) ([]byte, error) {
+		f, err := archive.Open(name)
+		if err != nil {
+			return nil, invalidf("workspace archive has no %s", name)
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+
+	encoded, err := read(workspaceManifestEntry)
+	if err != nil {
+		return nil, nil, err
+	}
+	var manifest models.WorkspaceManifest
+	if err := json.Unmarshal(encoded, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("invalid workspace manifest: %w", err)
+	}
+	if manifest.FormatVersion > models.WorkspaceFormatVersion {
+		return nil, nil, invalidf("workspace archive was written by a newer version of the app (format %d); update the app to import it", manifest.FormatVersion)
+	}
+	database, err := read(workspaceDatabaseEntry)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &manifest, database, nil
+}
+
+// StageWorkspace decrypts the workspace archive at archivePath and places its
+// database beside the one at dbPath, with StagedWorkspaceSuffix, for
+// ApplyStagedWorkspace to swap in before the database is next opened. The
+// open database is not touched, and a database from a newer version of the
+// app is refused.
+func StageWorkspace(archivePath, passphrase, dbPath string) (*models.WorkspaceManifest, error) {
+	sealed, err := os.ReadFile(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace archive: %w", err)
+	}
+	manifest, database, err := openWorkspace(sealed, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	staged := dbPath + StagedWorkspaceSuffix
+	if err := os.WriteFile(staged, database, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to stage workspace: %w", err)
+	}
+	compatibility, err := InspectSchema(staged)
+	if err == nil {
+		err = compatibility.Err()
+	}
+	if err != nil {
+		os.Remove(staged)
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ApplyStagedWorkspace swaps a database staged by StageWorkspace in for the
+// one at dbPath, which must not be open. The replaced database and its
+// write-ahead log are kept with ReplacedDatabaseSuffix, replacing any kept
+// before. It reports whether a staged workspace was applied.
+func ApplyStagedWorkspace(dbPath string) (bool, error) {
+	staged := dbPath + StagedWorkspaceSuffix
+	if _, err := os.Stat(staged); os.IsNotExist(err) {
+		return false, nil
+	}
+
+	replaced := dbPath + ReplacedDatabaseSuffix
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Remove(replaced + suffix); err != nil && !os.IsNotExist(err) {
+			return false, fmt.Errorf("failed to remove the previously replaced database: %w", err)
+		}
+		if err := os.Rename(dbPath+suffix, replaced+suffix); err != nil && !os.IsNotExist(err) {
+			return false, fmt.Errorf("failed to set the current database aside: %w", err)
+		}
+	}
+	if err := os.Rename(staged, dbPath); err != nil {
+		return false, fmt.Errorf("failed to apply staged workspace: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrWriteBusy is returned for writes that waited writeQueueTimeout for an
+// earlier write, such as a long import, to finish
+var ErrWriteBusy = errors.New("another change is still being saved")
+
+// writeQueueTimeout is how long a write waits for its turn before giving up
+const writeQueueTimeout = 2 * time.Minute
+
+// writeHandoffTimeout is how long a write that must not wait gives an idle
+// queue to hand it the turn, which takes a moment after the last write ends
+const writeHandoffTimeout = 50 * time.Millisecond
+
+// WriteQueueStatus describes the writes being saved and waiting to be saved
+type WriteQueueStatus struct {
+	Busy    bool       `json:"busy"`            // A write is being saved
+	Since   *time.Time `json:"since,omitempty"` // When the current write started
+	Waiting int        `json:"waiting"`         // Writes queued behind it
+}
+
+// writeTurn is a write's place in the queue
+type writeTurn struct {
+	granted   chan struct{} // Receives once it is the write's turn
+	done      chan struct{} // Closed by the write when it finishes
+	abandoned chan struct{} // Closed by a write that gave up waiting
+}
+
+// writeQueue hands the database to one write at a time, in the order they
+// asked for it. SQLite allows a single writer, so without it a write arriving
+// during another, such as an edit while an import runs, fails with
+// SQLITE_BUSY once the busy timeout runs out. Reads do not queue. It is
+// shared by a service and its transaction-bound copies.
+type writeQueue struct {
+	requests chan writeTurn
+	stop     chan struct{}
+	stopOnce sync.Once
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	since   *time.Time
+	waiting int
+}
+
+// newWriteQueue creates a queue and starts the goroutine handing out turns
+func newWriteQueue() *writeQueue {
+	q := &writeQueue{
+		requests: make(chan writeTurn),
+		stop:     make(chan struct{}),
+		timeout:  writeQueueTimeout,
+	}
+	go q.run()
+	return q
+}
+
+// run hands out turns until the queue is closed, waiting for each write to
+// finish before starting the next
+func (q *writeQueue) run() {
+	for {
+		var turn writeTurn
+		select {
+		case <-q.stop:
+			return
+		case turn = <-q.requests:
+		}
+
+		select {
+		case turn.granted <- struct{}{}:
+		case <-turn.abandoned:
+			continue
+		}
+
+		<-turn.done
+	}
+}
+
+// acquire waits for the write's turn and returns the function ending it. It
+// fails with ErrWriteBusy after the queue's timeout.
+func (q *writeQueue) acquire() (func(), error) {
+	return q.acquireWithin(q.timeout)
+}
+
+// tryAcquire is acquire for writes that can be skipped: it fails at once with
+// ErrWriteBusy unless the queue is idle
+func (q *writeQueue) tryAcquire() (func(), error) {
+	if status := q.status(); status.Busy || status.Waiting > 0 {
+		return nil, q.busyError()
+	}
+	return q.acquireWithin(writeHandoffTimeout)
+}
+
+// acquireWithin waits up to timeout for the write's turn
+func (q *writeQueue) acquireWithin(timeout time.Duration) (func(), error) {
+	turn := writeTurn{
+		granted:   make(chan struct{}),
+		done:      make(chan struct{}),
+		abandoned: make(chan struct{}),
+	}
+
+	q.mu.Lock()
+	q.waiting++
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		q.waiting--
+		q.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case q.requests <- turn:
+	case <-q.stop:
+		return nil, fmt.Errorf("database is closed")
+	case <-timer.C:
+		return nil, q.busyError()
+	}
+
+	select {
+	case <-turn.granted:
+	case <-timer.C:
+		close(turn.abandoned)
+		return nil, q.busyError()
+	}
+
+	now := time.Now()
+	q.mu.Lock()
+	q.since = &now
+	q.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			q.since = nil
+			q.mu.Unlock()
+			close(turn.done)
+		})
+	}, nil
+}
+
+// busyError describes the write holding up the queue
+func (q *writeQueue) busyError() error {
+	status := q.status()
+	if status.Since == nil {
+		return ErrWriteBusy
+	}
+	return fmt.Errorf("%w: started %s", ErrWriteBusy, status.Since.Format("15:04:05"))
+}
+
+// status returns the state of the queue
+func (q *writeQueue) status() WriteQueueStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	status := WriteQueueStatus{Busy: q.since != nil, Waiting: q.waiting}
+	if q.since != nil {
+		since := *q.since
+		status.Since = &since
+	}
+	return status
+}
+
+// close stops handing out turns. The write in progress, if any, finishes.
+func (q *writeQueue) close() {
+	q.stopOnce.Do(func() { close(q.stop) })
+}
+
+// WriteQueueStatus reports whether a write is being saved and how many are
+// waiting behind it, so the frontend can show that a change is pending
+func (s *Service) WriteQueueStatus() WriteQueueStatus {
+	return s.writes.status()
+}
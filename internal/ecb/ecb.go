@@ -0,0 +1,89 @@
+// Package ecb fetches euro foreign exchange reference rates published by the
+// European Central Bank.
+package ecb
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// Reference rate feeds. Each lists the units of a currency worth 1 EUR for
+// every business day in the period.
+const (
+	DailyURL      = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-daily.xml"
+	Last90DaysURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist-90d.xml"
+	HistoryURL    = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist.xml"
+)
+
+// Source is the source recorded for rates fetched from the ECB
+const Source = "ecb"
+
+// envelope is the layout of the ECB reference rate XML feeds
+type envelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+// Fetch downloads the feed at url and returns its rates. A nil client uses a
+// client with a 30 second timeout.
+func Fetch(ctx context.Context, client *http.Client, url string) ([]models.CreateExchangeRateRequest, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ECB request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch ECB rates: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch ECB rates: unexpected status %s", resp.Status)
+	}
+
+	var feed envelope
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("failed to decode ECB rates: %w", err)
+	}
+
+	var rates []models.CreateExchangeRateRequest
+	for _, day := range feed.Days {
+		if _, err := time.Parse(models.DateLayout, day.Time); err != nil {
+			return nil, fmt.Errorf("invalid ECB rate date %q: %w", day.Time, err)
+		}
+		for _, r := range day.Rates {
+			rate, err := strconv.ParseFloat(r.Rate, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ECB rate for %s on %s: %w", r.Currency, day.Time, err)
+			}
+			rates = append(rates, models.CreateExchangeRateRequest{
+				Date:     day.Time,
+				Currency: r.Currency,
+				Rate:     rate,
+				Source:   Source,
+			})
+		}
+	}
+
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("ECB feed contained no rates")
+	}
+
+	return rates, nil
+}
@@ -0,0 +1,77 @@
+package ecb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<gesmes:Sender>
+		<gesmes:name>European Central Bank</gesmes:name>
+	</gesmes:Sender>
+	<Cube>
+		<Cube time="2024-01-16">
+			<Cube currency="USD" rate="1.0882"/>
+			<Cube currency="GBP" rate="0.85950"/>
+		</Cube>
+		<Cube time="2024-01-15">
+			<Cube currency="USD" rate="1.0945"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(testFeed))
+	}))
+	defer server.Close()
+
+	rates, err := Fetch(context.Background(), server.Client(), server.URL)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+
+	if len(rates) != 3 {
+		t.Fatalf("Expected 3 rates, got %d", len(rates))
+	}
+
+	first := rates[0]
+	if first.Date != "2024-01-16" || first.Currency != "USD" || first.Rate != 1.0882 || first.Source != Source {
+		t.Errorf("Unexpected first rate: %+v", first)
+	}
+	if rates[2].Date != "2024-01-15" || rates[2].Rate != 1.0945 {
+		t.Errorf("Unexpected last rate: %+v", rates[2])
+	}
+}
+
+func TestFetch_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"server error", http.StatusInternalServerError, ""},
+		{"not xml", http.StatusOK, "not xml"},
+		{"no rates", http.StatusOK, `<Envelope><Cube></Cube></Envelope>`},
+		{"bad rate", http.StatusOK, `<Envelope><Cube><Cube time="2024-01-16"><Cube currency="USD" rate="abc"/></Cube></Cube></Envelope>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			if _, err := Fetch(context.Background(), server.Client(), server.URL); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
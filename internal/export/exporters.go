@@ -0,0 +1,119 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"sales-track/internal/models"
+)
+
+// salesFlushInterval is the number of records the built-in exporters write
+// between flushes, so long exports reach the disk as they are read
+const salesFlushInterval = 5000
+
+// RecordSource calls fn with each sales record to export, in order, and
+// stops at the first error fn returns
+type RecordSource func(fn func(models.SalesRecord) error) error
+
+// Exporter writes sales records in one file format. Exporters are registered
+// by name with RegisterExporter, and the app exports through whichever the
+// user picks.
+type Exporter interface {
+	Name() string        // File format, such as "csv", unique among exporters
+	ContentType() string // MIME type of the files written
+	// Write writes every record from records to w, using format for the
+	// numbers and dates of formats meant for people, and returns the number
+	// of records written
+	Write(w io.Writer, records RecordSource, format models.ExportFormat) (int, error)
+}
+
+var (
+	exportersMu sync.RWMutex
+	exporters   = map[string]Exporter{
+		FileFormatCSV: salesExporter{
+			name:        FileFormatCSV,
+			contentType: "text/csv",
+			newWriter: func(w io.Writer, format models.ExportFormat) SalesWriter {
+				return NewSalesCSVWriter(w, format)
+			},
+		},
+		FileFormatJSONL: salesExporter{
+			name:        FileFormatJSONL,
+			contentType: "application/x-ndjson",
+			newWriter: func(w io.Writer, format models.ExportFormat) SalesWriter {
+				return NewSalesJSONLWriter(w)
+			},
+		},
+	}
+)
+
+// RegisterExporter adds an exporter under its name, for file formats beyond
+// the built-in CSV and JSONL. It fails if the name is empty or taken.
+func RegisterExporter(exporter Exporter) error {
+	name := exporter.Name()
+	if name == "" {
+		return fmt.Errorf("exporter name is required")
+	}
+
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	if _, exists := exporters[name]; exists {
+		return fmt.Errorf("exporter %q is already registered", name)
+	}
+	exporters[name] = exporter
+	return nil
+}
+
+// LookupExporter returns the exporter registered under name
+func LookupExporter(name string) (Exporter, bool) {
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+	exporter, ok := exporters[name]
+	return exporter, ok
+}
+
+// Exporters returns the registered exporters sorted by name
+func Exporters() []Exporter {
+	exportersMu.RLock()
+	defer exportersMu.RUnlock()
+	list := make([]Exporter, 0, len(exporters))
+	for _, exporter := range exporters {
+		list = append(list, exporter)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// salesExporter is an Exporter writing through a SalesWriter
+type salesExporter struct {
+	name        string
+	contentType string
+	newWriter   func(w io.Writer, format models.ExportFormat) SalesWriter
+}
+
+func (e salesExporter) Name() string        { return e.name }
+func (e salesExporter) ContentType() string { return e.contentType }
+
+// Write writes the records through a new SalesWriter, flushing every
+// salesFlushInterval records
+func (e salesExporter) Write(w io.Writer, records RecordSource, format models.ExportFormat) (int, error) {
+	writer := e.newWriter(w, format)
+	err := records(func(record models.SalesRecord) error {
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+		if writer.Count()%salesFlushInterval != 0 {
+			return nil
+		}
+		return writer.Flush()
+	})
+	if err != nil {
+		return writer.Count(), err
+	}
+	if err := writer.Flush(); err != nil {
+		return writer.Count(), err
+	}
+	return writer.Count(), nil
+}
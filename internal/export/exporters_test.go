@@ -0,0 +1,74 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"sales-track/internal/models"
+)
+
+// tsvExporter writes descriptions one per line, as a third-party exporter
+type tsvExporter struct{}
+
+func (tsvExporter) Name() string        { return "test-tsv" }
+func (tsvExporter) ContentType() string { return "text/tab-separated-values" }
+
+func (tsvExporter) Write(w io.Writer, records RecordSource, format models.ExportFormat) (int, error) {
+	count := 0
+	err := records(func(record models.SalesRecord) error {
+		count++
+		_, err := fmt.Fprintf(w, "%s\t%s\n", record.Description, format.FormatMoney(record.SalePrice))
+		return err
+	})
+	return count, err
+}
+
+func TestExporters(t *testing.T) {
+	records := []models.SalesRecord{
+		{Store: "Store A", Vendor: "Vendor 1", Description: "Lamp", SalePrice: 40},
+		{Store: "Store A", Vendor: "Vendor 1", Description: "Rug", SalePrice: 60},
+	}
+	source := func(fn func(models.SalesRecord) error) error {
+		for _, record := range records {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	csv, ok := LookupExporter(FileFormatCSV)
+	if !ok || csv.ContentType() != "text/csv" {
+		t.Fatalf("Expected the built-in CSV exporter, got %v", csv)
+	}
+	var out strings.Builder
+	count, err := csv.Write(&out, source, models.DefaultExportFormat())
+	if err != nil || count != 2 || strings.Count(out.String(), "\r\n") != 3 {
+		t.Errorf("Expected a header and 2 CSV rows, got %d, %q, %v", count, out.String(), err)
+	}
+
+	if err := RegisterExporter(tsvExporter{}); err != nil {
+		t.Fatalf("RegisterExporter failed: %v", err)
+	}
+	if err := RegisterExporter(tsvExporter{}); err == nil {
+		t.Error("Expected a second exporter with the same name to be refused")
+	}
+	tsv, ok := LookupExporter("test-tsv")
+	if !ok {
+		t.Fatal("Expected the registered exporter to be found")
+	}
+	out.Reset()
+	if count, err := tsv.Write(&out, source, models.DefaultExportFormat()); err != nil || count != 2 || out.String() != "Lamp\t40.00\nRug\t60.00\n" {
+		t.Errorf("Expected the registered exporter to write both records, got %d, %q, %v", count, out.String(), err)
+	}
+
+	var names []string
+	for _, exporter := range Exporters() {
+		names = append(names, exporter.Name())
+	}
+	if strings.Join(names, ",") != "csv,jsonl,test-tsv" {
+		t.Errorf("Expected the exporters sorted by name, got %v", names)
+	}
+}
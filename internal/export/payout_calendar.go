@@ -0,0 +1,80 @@
+package export
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"sales-track/internal/models"
+)
+
+// icsMaxLineOctets is the longest line iCalendar allows before folding
+const icsMaxLineOctets = 75
+
+// icsEscaper escapes iCalendar TEXT values
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// WritePayoutCalendar writes expected payouts as an iCalendar file of
+// all-day events, with amounts in format. Each payout keeps the same UID
+// across exports, so calendar apps update events rather than duplicating
+// them when a file is imported again or subscribed to.
+func WritePayoutCalendar(w io.Writer, payouts []models.ExpectedPayout, format models.ExportFormat, now time.Time) error {
+	out := bufio.NewWriter(w)
+	line := func(content string) {
+		writeFoldedICSLine(out, content)
+	}
+
+	stamp := now.UTC().Format("20060102T150405Z")
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//Sales Track//Payouts//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:Store payouts")
+	for _, payout := range payouts {
+		items := fmt.Sprintf("%d items sold", payout.ItemsSold)
+		if payout.ReturnedItems > 0 {
+			items += fmt.Sprintf(", %d returned", payout.ReturnedItems)
+		}
+
+		line("BEGIN:VEVENT")
+		line("UID:payout-" + payout.Month + "-" + hex.EncodeToString([]byte(payout.Store)) + "@sales-track")
+		line("DTSTAMP:" + stamp)
+		line("DTSTART;VALUE=DATE:" + payout.Date.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + payout.Date.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + icsEscaper.Replace(fmt.Sprintf("Payout from %s: %s", payout.Store, format.FormatMoney(payout.Amount))))
+		line("DESCRIPTION:" + icsEscaper.Replace(fmt.Sprintf("Sales of %s: %s", payout.Month, items)))
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write payout calendar: %w", err)
+	}
+	return nil
+}
+
+// writeFoldedICSLine writes a content line ending in CRLF, folding it onto
+// continuation lines starting with a space so no line exceeds
+// icsMaxLineOctets. Lines are only folded between characters. Write errors
+// are kept by out and reported by its Flush.
+func writeFoldedICSLine(out *bufio.Writer, content string) {
+	limit := icsMaxLineOctets
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		out.WriteString(content[:cut])
+		out.WriteString("\r\n ")
+		content = content[cut:]
+		limit = icsMaxLineOctets - 1 // The leading space counts
+	}
+	out.WriteString(content)
+	out.WriteString("\r\n")
+}
@@ -0,0 +1,58 @@
+package export
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"sales-track/internal/models"
+)
+
+func TestWritePayoutCalendar(t *testing.T) {
+	date, err := models.ParseDate("2024-02-15")
+	if err != nil {
+		t.Fatalf("ParseDate failed: %v", err)
+	}
+	payouts := []models.ExpectedPayout{
+		{Store: "Smith, Jones; Co", Month: "2024-01", Date: date, ItemsSold: 3, ReturnedItems: 1, Amount: 1234.5},
+		{Store: strings.Repeat("Ü", 60), Month: "2024-01", Date: date, ItemsSold: 1, Amount: 10},
+	}
+	now := time.Date(2024, 2, 1, 9, 30, 0, 0, time.UTC)
+
+	var out strings.Builder
+	if err := WritePayoutCalendar(&out, payouts, models.DefaultExportFormat(), now); err != nil {
+		t.Fatalf("WritePayoutCalendar failed: %v", err)
+	}
+	calendar := out.String()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"UID:payout-2024-01-536d6974682c204a6f6e65733b20436f@sales-track\r\n",
+		"DTSTAMP:20240201T093000Z\r\n",
+		"DTSTART;VALUE=DATE:20240215\r\nDTEND;VALUE=DATE:20240216\r\n",
+		`SUMMARY:Payout from Smith\, Jones\; Co: 1234.50` + "\r\n",
+		`DESCRIPTION:Sales of 2024-01: 3 items sold\, 1 returned` + "\r\n",
+		"END:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(calendar, want) {
+			t.Errorf("Expected the calendar to contain %q, got:\n%s", want, calendar)
+		}
+	}
+	if strings.Count(calendar, "BEGIN:VEVENT") != 2 {
+		t.Errorf("Expected 2 events, got:\n%s", calendar)
+	}
+
+	// Long lines are folded between characters, within the length limit
+	for _, line := range strings.Split(strings.TrimSuffix(calendar, "\r\n"), "\r\n") {
+		if len(line) > icsMaxLineOctets {
+			t.Errorf("Expected lines of at most %d octets, got %d: %q", icsMaxLineOctets, len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("Expected a line folded between characters, got %q", line)
+		}
+	}
+	if unfolded := strings.ReplaceAll(calendar, "\r\n ", ""); !strings.Contains(unfolded, "SUMMARY:Payout from "+strings.Repeat("Ü", 60)+": 10.00\r\n") {
+		t.Errorf("Expected the folded summary to unfold intact, got:\n%s", unfolded)
+	}
+}
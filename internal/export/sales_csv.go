@@ -0,0 +1,133 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"sales-track/internal/models"
+)
+
+// utf8BOM marks a CSV file as UTF-8, without which Excel reads symbols such
+// as "€" in the system code page
+const utf8BOM = "\uFEFF"
+
+// salesCSVHeader names the columns written by SalesCSVWriter. The names are
+// ones the import parser recognizes, so an export can be imported again.
+var salesCSVHeader = []string{
+	"Date", "Store", "Vendor", "Description", "Sale Price", "Commission", "Remaining",
+	"Type", "Currency", "Item Cost", "Category", "Tags",
+}
+
+// SalesCSVWriter writes sales records as CSV in an export format. Returns are
+// written with negative amounts and a "Return" type.
+type SalesCSVWriter struct {
+	w       *csv.Writer
+	format  models.ExportFormat
+	started bool
+	count   int
+}
+
+// NewSalesCSVWriter creates a writer of sales records to w
+func NewSalesCSVWriter(w io.Writer, format models.ExportFormat) *SalesCSVWriter {
+	writer := csv.NewWriter(&bomWriter{w: w})
+	if delimiter, _ := utf8.DecodeRuneInString(format.CSVDelimiter); delimiter != utf8.RuneError {
+		writer.Comma = delimiter
+	}
+	writer.UseCRLF = true // As Excel writes them
+	return &SalesCSVWriter{w: writer, format: format}
+}
+
+// Write writes a record, preceded by the header for the first one
+func (s *SalesCSVWriter) Write(record models.SalesRecord) error {
+	if err := s.writeHeader(); err != nil {
+		return err
+	}
+
+	sign := 1.0
+	recordType := "Sale"
+	if record.IsReturn {
+		sign = -1
+		recordType = "Return"
+	}
+	optional := func(amount *float64, sign float64) string {
+		if amount == nil {
+			return ""
+		}
+		return s.format.FormatMoney(sign * *amount)
+	}
+	text := func(value *string) string {
+		if value == nil {
+			return ""
+		}
+		return *value
+	}
+
+	row := []string{
+		s.format.FormatDate(record.Date.Time),
+		record.Store,
+		record.Vendor,
+		record.Description,
+		s.format.FormatMoney(sign * record.SalePrice),
+		optional(record.Commission, sign),
+		optional(record.Remaining, sign),
+		recordType,
+		text(record.Currency),
+		optional(record.Cost, 1),
+		text(record.Category),
+		strings.Join(record.Tags, "; "),
+	}
+	if err := s.w.Write(row); err != nil {
+		return fmt.Errorf("failed to write CSV row: %w", err)
+	}
+	s.count++
+	return nil
+}
+
+// writeHeader writes the header unless it has been written
+func (s *SalesCSVWriter) writeHeader() error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	if err := s.w.Write(salesCSVHeader); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	return nil
+}
+
+// Count returns the number of records written
+func (s *SalesCSVWriter) Count() int {
+	return s.count
+}
+
+// Flush writes the header if no record was written and flushes buffered rows
+func (s *SalesCSVWriter) Flush() error {
+	if err := s.writeHeader(); err != nil {
+		return err
+	}
+	s.w.Flush()
+	if err := s.w.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+// bomWriter writes utf8BOM before the first bytes written to w
+type bomWriter struct {
+	w       io.Writer
+	written bool
+}
+
+// Write implements io.Writer
+func (b *bomWriter) Write(p []byte) (int, error) {
+	if !b.written {
+		b.written = true
+		if _, err := io.WriteString(b.w, utf8BOM); err != nil {
+			return 0, err
+		}
+	}
+	return b.w.Write(p)
+}
@@ -0,0 +1,64 @@
+package export
+
+import (
+	"strings"
+	"testing"
+
+	"sales-track/internal/models"
+)
+
+func TestSalesCSVWriter(t *testing.T) {
+	amount := func(f float64) *float64 { return &f }
+	category := "Lighting"
+	date, err := models.ParseDate("2024-03-05")
+	if err != nil {
+		t.Fatalf("ParseDate failed: %v", err)
+	}
+
+	format := models.ExportFormat{
+		DecimalSeparator:   ",",
+		ThousandsSeparator: ".",
+		CSVDelimiter:       ";",
+		CurrencySymbol:     "€",
+		SymbolPlacement:    models.SymbolAfter,
+		DateFormat:         "DD.MM.YYYY",
+	}
+
+	var out strings.Builder
+	writer := NewSalesCSVWriter(&out, format)
+	records := []models.SalesRecord{
+		{Date: date, Store: "Store A", Vendor: "Vendor 1", Description: "Lamp; brass", SalePrice: 1250.50, Commission: amount(250.10),
+			Cost: amount(40), Category: &category, Tags: models.Tags{"estate sale", "fragile"}},
+		{Date: date, Store: "Store A", Vendor: "Vendor 1", Description: "Rug", SalePrice: 60, Remaining: amount(48), IsReturn: true},
+	}
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	expected := utf8BOM +
+		"Date;Store;Vendor;Description;Sale Price;Commission;Remaining;Type;Currency;Item Cost;Category;Tags\r\n" +
+		"05.03.2024;Store A;Vendor 1;\"Lamp; brass\";1.250,50 €;250,10 €;;Sale;;40,00 €;Lighting;\"estate sale; fragile\"\r\n" +
+		"05.03.2024;Store A;Vendor 1;Rug;-60,00 €;;-48,00 €;Return;;;;\r\n"
+	if out.String() != expected {
+		t.Errorf("Unexpected CSV:\n%q\nExpected:\n%q", out.String(), expected)
+	}
+	if writer.Count() != 2 {
+		t.Errorf("Expected 2 records written, got %d", writer.Count())
+	}
+}
+
+func TestSalesCSVWriter_Empty(t *testing.T) {
+	var out strings.Builder
+	writer := NewSalesCSVWriter(&out, models.DefaultExportFormat())
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), utf8BOM+"Date,Store,") {
+		t.Errorf("Expected a header row even without records, got %q", out.String())
+	}
+}
@@ -0,0 +1,76 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"sales-track/internal/models"
+)
+
+// Sales record file formats
+const (
+	FileFormatCSV   = "csv"
+	FileFormatJSONL = "jsonl"
+)
+
+// FileFormats are the file formats NewSalesWriter accepts
+var FileFormats = []string{FileFormatCSV, FileFormatJSONL}
+
+// SalesWriter writes sales records to a file one at a time, so exports of any
+// size are never held in memory
+type SalesWriter interface {
+	Write(record models.SalesRecord) error
+	Count() int
+	Flush() error // Writes anything buffered to the underlying writer
+}
+
+// NewSalesWriter creates a writer of sales records to w in fileFormat,
+// FileFormatCSV or FileFormatJSONL. Only CSV uses the export format.
+func NewSalesWriter(w io.Writer, fileFormat string, format models.ExportFormat) (SalesWriter, error) {
+	switch fileFormat {
+	case FileFormatCSV:
+		return NewSalesCSVWriter(w, format), nil
+	case FileFormatJSONL:
+		return NewSalesJSONLWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unknown export file format %q", fileFormat)
+	}
+}
+
+// SalesJSONLWriter writes sales records as JSON Lines, one record object per
+// line with every field as stored, for loading into other tools
+type SalesJSONLWriter struct {
+	w     *bufio.Writer
+	enc   *json.Encoder
+	count int
+}
+
+// NewSalesJSONLWriter creates a writer of sales records to w
+func NewSalesJSONLWriter(w io.Writer) *SalesJSONLWriter {
+	buffered := bufio.NewWriter(w)
+	return &SalesJSONLWriter{w: buffered, enc: json.NewEncoder(buffered)}
+}
+
+// Write writes a record as a line of JSON
+func (s *SalesJSONLWriter) Write(record models.SalesRecord) error {
+	if err := s.enc.Encode(record); err != nil {
+		return fmt.Errorf("failed to write JSON line: %w", err)
+	}
+	s.count++
+	return nil
+}
+
+// Count returns the number of records written
+func (s *SalesJSONLWriter) Count() int {
+	return s.count
+}
+
+// Flush writes buffered lines
+func (s *SalesJSONLWriter) Flush() error {
+	if err := s.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write JSON lines: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package export
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"sales-track/internal/models"
+)
+
+func TestSalesJSONLWriter(t *testing.T) {
+	amount := func(f float64) *float64 { return &f }
+	date, err := models.ParseDate("2024-03-05")
+	if err != nil {
+		t.Fatalf("ParseDate failed: %v", err)
+	}
+
+	var out strings.Builder
+	writer, err := NewSalesWriter(&out, FileFormatJSONL, models.DefaultExportFormat())
+	if err != nil {
+		t.Fatalf("NewSalesWriter failed: %v", err)
+	}
+	records := []models.SalesRecord{
+		{ID: 1, Date: date, Store: "Store A", Vendor: "Vendor 1", Description: "Lamp\nbrass", SalePrice: 1250.50, Commission: amount(250.10)},
+		{ID: 2, Date: date, Store: "Store A", Vendor: "Vendor 1", Description: "Rug", SalePrice: 60, IsReturn: true},
+	}
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2 || writer.Count() != 2 {
+		t.Fatalf("Expected 2 lines for 2 records, got %d lines and a count of %d", len(lines), writer.Count())
+	}
+	var first models.SalesRecord
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("Line is not a record: %v", err)
+	}
+	if first.Description != "Lamp\nbrass" || first.SalePrice != 1250.50 || *first.Commission != 250.10 || first.Date.String() != "2024-03-05" {
+		t.Errorf("Unexpected record read back: %+v", first)
+	}
+	if !strings.Contains(lines[1], `"is_return":true`) {
+		t.Errorf("Expected the return flag kept, got %s", lines[1])
+	}
+
+	if _, err := NewSalesWriter(&out, "xlsx", models.DefaultExportFormat()); err == nil {
+		t.Error("Expected an error for an unknown file format")
+	}
+}
@@ -0,0 +1,64 @@
+// Package export renders data from the database as files to share outside
+// the app.
+package export
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+
+	"sales-track/internal/models"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// vendorStatementTemplate renders a statement as a standalone page: styles
+// are inline and nothing is loaded from elsewhere, so the file can be mailed
+// to a vendor and opened or printed to PDF anywhere. Its functions format
+// amounts and dates and are replaced for each export format.
+var vendorStatementTemplate = template.Must(template.New("vendor_statement.html").
+	Funcs(statementFuncs(models.DefaultExportFormat())).
+	ParseFS(templateFS, "templates/vendor_statement.html"))
+
+// WriteVendorStatementHTML writes a vendor statement as an HTML page, with
+// amounts and dates in format. Text from the database is escaped.
+func WriteVendorStatementHTML(w io.Writer, statement *models.VendorStatement, format models.ExportFormat) error {
+	tmpl, err := vendorStatementTemplate.Clone()
+	if err != nil {
+		return fmt.Errorf("failed to prepare vendor statement: %w", err)
+	}
+	if err := tmpl.Funcs(statementFuncs(format)).Execute(w, statement); err != nil {
+		return fmt.Errorf("failed to render vendor statement: %w", err)
+	}
+	return nil
+}
+
+// statementFuncs returns the template functions writing values in format
+func statementFuncs(format models.ExportFormat) template.FuncMap {
+	// lineAmount writes an amount of a line, negated for returns. Lines in
+	// another currency show its code instead of the currency symbol.
+	lineAmount := func(line models.VendorStatementLine, amount float64) string {
+		if line.IsReturn {
+			amount = -amount
+		}
+		if line.Currency != nil && *line.Currency != "" {
+			return format.FormatNumber(amount) + " " + *line.Currency
+		}
+		return format.FormatMoney(amount)
+	}
+
+	return template.FuncMap{
+		"money":      format.FormatMoney,
+		"date":       format.FormatDate,
+		"lineAmount": lineAmount,
+		// lineOptional writes an amount a store may not have reported, or a dash
+		"lineOptional": func(line models.VendorStatementLine, amount *float64) string {
+			if amount == nil {
+				return "—"
+			}
+			return lineAmount(line, *amount)
+		},
+	}
+}
@@ -0,0 +1,96 @@
+package export
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+func TestWriteVendorStatementHTML(t *testing.T) {
+	amount := func(f float64) *float64 { return &f }
+	gbp := "GBP"
+	date := func(s string) models.Date {
+		d, err := models.ParseDate(s)
+		if err != nil {
+			t.Fatalf("ParseDate failed: %v", err)
+		}
+		return d
+	}
+
+	statement := &models.VendorStatement{
+		Vendor:      "Smith & <Sons>",
+		From:        date("2024-01-01"),
+		To:          date("2024-01-31"),
+		GeneratedAt: time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC),
+		Lines: []models.VendorStatementLine{
+			{Date: date("2024-01-10"), Store: "Store A", Description: "Lamp", SalePrice: 40.00, Commission: amount(8.00), Remaining: amount(32.00)},
+			{Date: date("2024-01-12"), Store: "Store A", Description: "Lamp", SalePrice: 40.00, Commission: amount(8.00), Remaining: amount(32.00), IsReturn: true},
+			{Date: date("2024-01-20"), Store: "Store B", Description: "<script>alert(1)</script>", SalePrice: 12.50, Currency: &gbp},
+		},
+		Settlements: []models.VendorSettlement{
+			{Store: "Store A", Month: "2024-01", ItemsSold: 1, ReturnedItems: 1},
+			{Store: "Store B", Month: "2024-01", ItemsSold: 1, TotalSales: 12.50},
+		},
+		Total: models.VendorSettlement{ItemsSold: 2, ReturnedItems: 1, TotalSales: 12.50},
+	}
+
+	var page strings.Builder
+	if err := WriteVendorStatementHTML(&page, statement, models.DefaultExportFormat()); err != nil {
+		t.Fatalf("WriteVendorStatementHTML failed: %v", err)
+	}
+	html := page.String()
+
+	for _, want := range []string{
+		"<title>Sales statement for Smith &amp; &lt;Sons&gt;</title>",
+		"2024-01-01 to 2024-01-31",
+		`<tr class="return"><td>2024-01-12</td><td>Store A</td><td>Return: Lamp</td><td class="amount">-40.00</td>`,
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		`<td class="amount">12.50 GBP</td><td class="amount">—</td>`,
+		"Generated 2024-02-01 09:00",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected the page to contain %q, got:\n%s", want, html)
+		}
+	}
+	// The page must open anywhere, so nothing is loaded from elsewhere
+	for _, unwanted := range []string{"<script>", "<link", "src=", "http"} {
+		if strings.Contains(html, unwanted) {
+			t.Errorf("Expected a standalone page, found %q", unwanted)
+		}
+	}
+}
+
+func TestWriteVendorStatementHTML_ExportFormat(t *testing.T) {
+	date, err := models.ParseDate("2024-01-10")
+	if err != nil {
+		t.Fatalf("ParseDate failed: %v", err)
+	}
+	statement := &models.VendorStatement{
+		Vendor:      "Vendor 1",
+		From:        date,
+		To:          date,
+		GeneratedAt: date.Time,
+		Lines:       []models.VendorStatementLine{{Date: date, Store: "Store A", Description: "Desk", SalePrice: 1250.00}},
+		Total:       models.VendorSettlement{ItemsSold: 1, TotalSales: 1250.00},
+	}
+	format := models.ExportFormat{
+		DecimalSeparator:   ",",
+		ThousandsSeparator: ".",
+		CSVDelimiter:       ";",
+		CurrencySymbol:     "€",
+		SymbolPlacement:    models.SymbolAfter,
+		DateFormat:         "DD.MM.YYYY",
+	}
+
+	var page strings.Builder
+	if err := WriteVendorStatementHTML(&page, statement, format); err != nil {
+		t.Fatalf("WriteVendorStatementHTML failed: %v", err)
+	}
+	for _, want := range []string{"10.01.2024 to 10.01.2024", "<td>10.01.2024</td>", "1.250,00 €"} {
+		if !strings.Contains(page.String(), want) {
+			t.Errorf("Expected the page to contain %q, got:\n%s", want, page.String())
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// Package membudget watches the memory long parses and exports use, so they
+// can switch to chunked processing or temporary files, or stop with an error,
+// before the app is killed for running out of memory on a modest machine.
+package membudget
+
+import (
+	"errors"
+	"runtime"
+	"runtime/metrics"
+)
+
+// heapObjectsMetric is the runtime metric of the memory held by heap objects,
+// live or not yet collected. Reading it does not stop the world, unlike
+// runtime.ReadMemStats.
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+// checkInterval is the number of Check calls between heap readings
+const checkInterval = 1000
+
+// ErrExceeded is returned by Check when the heap stays above the budget after
+// a garbage collection
+var ErrExceeded = errors.New("memory budget exceeded")
+
+// HeapInUse returns the bytes held by objects on the Go heap, including
+// garbage not yet collected
+func HeapInUse() uint64 {
+	sample := []metrics.Sample{{Name: heapObjectsMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// Monitor checks the heap against a budget while an operation runs, reading it
+// every checkInterval calls to Check so per-record checks stay cheap
+type Monitor struct {
+	limit uint64
+	calls int
+}
+
+// NewMonitor creates a monitor of a budget of limit bytes
+func NewMonitor(limit int64) *Monitor {
+	if limit < 0 {
+		limit = 0
+	}
+	return &Monitor{limit: uint64(limit)}
+}
+
+// Check returns ErrExceeded once the heap grows past the budget. Garbage is
+// collected before failing, so only memory still in use counts.
+func (m *Monitor) Check() error {
+	m.calls++
+	if m.calls%checkInterval != 0 || HeapInUse() <= m.limit {
+		return nil
+	}
+	runtime.GC()
+	if HeapInUse() > m.limit {
+		return ErrExceeded
+	}
+	return nil
+}
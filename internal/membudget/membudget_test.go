@@ -0,0 +1,36 @@
+package membudget
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMonitor(t *testing.T) {
+	if HeapInUse() == 0 {
+		t.Fatal("Expected the heap in use to be reported")
+	}
+
+	check := func(m *Monitor) error {
+		for i := 0; i < checkInterval; i++ {
+			if err := m.Check(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := check(NewMonitor(1)); !errors.Is(err, ErrExceeded) {
+		t.Errorf("Expected a budget of one byte to be exceeded, got %v", err)
+	}
+	if err := check(NewMonitor(1 << 40)); err != nil {
+		t.Errorf("Expected a budget of a terabyte not to be exceeded, got %v", err)
+	}
+
+	// The heap is only read every checkInterval calls
+	m := NewMonitor(1)
+	for i := 1; i < checkInterval; i++ {
+		if err := m.Check(); err != nil {
+			t.Fatalf("Expected no reading before call %d, got %v", checkInterval, err)
+		}
+	}
+}
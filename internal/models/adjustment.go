@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// SalesAdjustment is a correction to a recorded sale, such as a price change
+// reported on a later store statement. The sale itself is never edited.
+type SalesAdjustment struct {
+	ID              int64     `json:"id" db:"id"`
+	SalesRecordID   int64     `json:"sales_record_id" db:"sales_record_id"`
+	Date            Date      `json:"date" db:"date"`
+	Reason          string    `json:"reason" db:"reason"`
+	SalePriceDelta  float64   `json:"sale_price_delta" db:"sale_price_delta"`
+	CommissionDelta *float64  `json:"commission_delta" db:"commission_delta"` // Nil when commission was not adjusted
+	RemainingDelta  *float64  `json:"remaining_delta" db:"remaining_delta"`   // Nil when remaining was not adjusted
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateSalesAdjustmentRequest represents a new adjustment to a sale
+type CreateSalesAdjustmentRequest struct {
+	SalesRecordID   int64    `json:"sales_record_id" validate:"required"`
+	Date            string   `json:"date" validate:"required"` // Date as string for parsing
+	Reason          string   `json:"reason" validate:"required"`
+	SalePriceDelta  float64  `json:"sale_price_delta"`
+	CommissionDelta *float64 `json:"commission_delta,omitempty"`
+	RemainingDelta  *float64 `json:"remaining_delta,omitempty"`
+}
+
+// Dates summaries can bucket records by
+const (
+	DateBasisSale   = "sale"   // When the item sold
+	DateBasisImport = "import" // When the record was imported or entered, in local time
+)
+
+// ReportOptions controls how summary reports are calculated
+type ReportOptions struct {
+	BaseCurrency       string `json:"base_currency,omitempty"`       // Convert amounts into this currency; empty reports them as recorded
+	IncludeAdjustments bool   `json:"include_adjustments,omitempty"` // Fold adjustments into the period they were recorded in
+	DateBasis          string `json:"date_basis,omitempty"`          // DateBasisSale or DateBasisImport; defaults to DateBasisSale
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Kinds of names an alias can map
+const (
+	AliasKindStore  = "store"
+	AliasKindVendor = "vendor"
+)
+
+// NameAlias maps a store or vendor name as it appears in imported reports to
+// the name records are kept under, such as "DT Branch" to "Downtown Store"
+type NameAlias struct {
+	ID        int64     `json:"id" db:"id"`
+	Kind      string    `json:"kind" db:"kind"`   // AliasKindStore or AliasKindVendor
+	Alias     string    `json:"alias" db:"alias"` // Matched ignoring case
+	Canonical string    `json:"canonical" db:"canonical"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// SaveNameAliasRequest represents an alias to save. Saving an alias that
+// exists changes the name it maps to.
+type SaveNameAliasRequest struct {
+	Kind      string `json:"kind" validate:"required,oneof=store vendor"`
+	Alias     string `json:"alias" validate:"required,max=100"`
+	Canonical string `json:"canonical" validate:"required,max=100"`
+}
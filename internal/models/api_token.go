@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// API token scopes: what a token lets a client of the local HTTP server do
+const (
+	APITokenScopeRead  = "read"  // Query records and reports
+	APITokenScopeWrite = "write" // Create, change and delete records
+)
+
+// APITokenScopes lists the scopes a token can be granted
+var APITokenScopes = []string{APITokenScopeRead, APITokenScopeWrite}
+
+// APITokenPrefix starts every API token, so a leaked token is recognizable
+const APITokenPrefix = "st_"
+
+// APIToken is a credential for the local HTTP server. Only a hash of the
+// token is kept; the token itself is shown once, when it is created.
+type APIToken struct {
+	ID         int64      `json:"id" db:"id"`
+	Name       string     `json:"name" db:"name"`
+	Hint       string     `json:"hint" db:"hint"` // The first characters of the token, to tell tokens apart
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"` // nil for a token that does not expire
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+// Active reports whether the token can still be used at now
+func (t APIToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
+
+// HasScope reports whether the token was granted scope
+func (t APIToken) HasScope(scope string) bool {
+	for _, granted := range t.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPITokenRequest names a new API token and what it may do
+type CreateAPITokenRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`               // Some of APITokenScopes
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // nil for a token that does not expire
+}
+
+// CreatedAPIToken is a new API token with its secret, which cannot be shown
+// again
+type CreatedAPIToken struct {
+	Token  APIToken `json:"token"`
+	Secret string   `json:"secret"`
+}
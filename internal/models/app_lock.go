@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// MinAppLockSecretLength is the fewest characters an app lock PIN or
+// password can have
+const MinAppLockSecretLength = 4
+
+// AppLockStatus tells the frontend whether to show the lock screen
+type AppLockStatus struct {
+	Enabled    bool       `json:"enabled"`               // A PIN or password is set
+	Locked     bool       `json:"locked"`                // It must be entered before any data is shown
+	RetryAfter *time.Time `json:"retry_after,omitempty"` // Set after too many wrong attempts; unlocking is refused until then
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Audit log actions
+const (
+	AuditSettingsUpdated        = "settings.updated"
+	AuditRetentionArchived      = "retention.archived"
+	AuditRetentionPurgedDeleted = "retention.purged_deleted"
+	AuditRetentionPurgedImports = "retention.purged_import_history"
+	AuditRecordsEnriched        = "records.enriched"
+	AuditRecordUpdated          = "record.updated"
+	AuditPeriodClosed           = "period.closed"
+	AuditPeriodReopened         = "period.reopened"
+	AuditAPITokenCreated        = "api_token.created"
+	AuditAPITokenRevoked        = "api_token.revoked"
+)
+
+// AuditEntry is an entry in the append-only audit log
+type AuditEntry struct {
+	ID         int64     `json:"id" db:"id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	Action     string    `json:"action" db:"action"`
+	EntityType *string   `json:"entity_type,omitempty" db:"entity_type"` // Kind of entity affected, such as "setting"
+	EntityID   *int64    `json:"entity_id,omitempty" db:"entity_id"`
+	Details    string    `json:"details" db:"details"` // Human-readable description of the change
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// DefaultCohortMonths is how many months after their first a cohort's
+// vendors are followed when the filter does not say
+const DefaultCohortMonths = 12
+
+// CohortFilter selects the sales a vendor cohort report covers
+type CohortFilter struct {
+	Store  *string `json:"store,omitempty"`
+	Months int     `json:"months,omitempty"` // Months followed, counting the first; defaults to DefaultCohortMonths
+}
+
+// CohortCell is the activity of a cohort's vendors in one month
+type CohortCell struct {
+	Cohort  string  // YYYY-MM of the vendors' first sale
+	Offset  int     // Months since the cohort's month
+	Vendors int     // Vendors with records in the month
+	Revenue float64 // Net of returns
+}
+
+// VendorCohort follows the vendors whose first sale was in the same month.
+// Revenue, ActiveVendors and Retention hold one entry per month from the
+// cohort's month on, stopping at the report date or the months followed.
+type VendorCohort struct {
+	Month         string    `json:"month"`   // YYYY-MM
+	Vendors       int       `json:"vendors"` // Vendors whose first sale was in the month
+	Revenue       []float64 `json:"revenue"` // Net of returns
+	ActiveVendors []int     `json:"active_vendors"`
+	Retention     []float64 `json:"retention"` // Active vendors as a percentage of the cohort, to one decimal
+}
+
+// CohortReport groups vendors by the month of their first sale and follows
+// their revenue in later months, oldest cohort first
+type CohortReport struct {
+	AsOf    Date           `json:"as_of"`
+	Months  int            `json:"months"`
+	Cohorts []VendorCohort `json:"cohorts"`
+}
+
+// BuildCohorts lays out cells, ordered by cohort, as one row per cohort,
+// following each for up to months months and none after asOf. Months
+// without records are zero.
+func BuildCohorts(cells []CohortCell, months int, asOf time.Time) []VendorCohort {
+	cohorts := []VendorCohort{}
+	for _, cell := range cells {
+		if len(cohorts) == 0 || cohorts[len(cohorts)-1].Month != cell.Cohort {
+			start, err := time.Parse("2006-01", cell.Cohort)
+			if err != nil {
+				continue
+			}
+			elapsed := (asOf.Year()-start.Year())*12 + int(asOf.Month()-start.Month()) + 1
+			length := min(max(elapsed, 1), months)
+			cohorts = append(cohorts, VendorCohort{
+				Month:         cell.Cohort,
+				Revenue:       make([]float64, length),
+				ActiveVendors: make([]int, length),
+				Retention:     make([]float64, length),
+			})
+		}
+
+		cohort := &cohorts[len(cohorts)-1]
+		if cell.Offset == 0 {
+			cohort.Vendors = cell.Vendors
+		}
+		if cell.Offset < 0 || cell.Offset >= len(cohort.Revenue) {
+			continue
+		}
+		cohort.Revenue[cell.Offset] = math.Round(cell.Revenue*100) / 100
+		cohort.ActiveVendors[cell.Offset] = cell.Vendors
+	}
+
+	for i := range cohorts {
+		if cohorts[i].Vendors == 0 {
+			continue
+		}
+		for j, active := range cohorts[i].ActiveVendors {
+			cohorts[i].Retention[j] = math.Round(float64(active)/float64(cohorts[i].Vendors)*1000) / 10
+		}
+	}
+	return cohorts
+}
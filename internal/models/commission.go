@@ -0,0 +1,105 @@
+package models
+
+import "time"
+
+// DefaultCommissionTolerance is the largest difference between a reported
+// and an expected commission that reconciliation treats as rounding
+const DefaultCommissionTolerance = 0.01
+
+// CommissionRule is the commission rate a store charges from EffectiveFrom
+// until the store's next rule
+type CommissionRule struct {
+	ID            int64     `json:"id" db:"id"`
+	Store         string    `json:"store" db:"store"`
+	EffectiveFrom Date      `json:"effective_from" db:"effective_from"`
+	Rate          float64   `json:"rate" db:"rate"` // Fraction of the sale price, such as 0.3 for 30%
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateCommissionRuleRequest represents a commission rule to save
+type CreateCommissionRuleRequest struct {
+	Store         string  `json:"store" validate:"required"`
+	EffectiveFrom string  `json:"effective_from" validate:"required"` // Date as string for parsing
+	Rate          float64 `json:"rate" validate:"gte=0,lte=1"`
+}
+
+// ReconciliationFilter selects the sales to reconcile
+type ReconciliationFilter struct {
+	Store     *string    `json:"store,omitempty"`
+	DateFrom  *time.Time `json:"date_from,omitempty"`
+	DateTo    *time.Time `json:"date_to,omitempty"`
+	Tolerance float64    `json:"tolerance,omitempty"` // Defaults to DefaultCommissionTolerance
+}
+
+// CommissionDiscrepancy is a sale whose reported commission differs from the
+// one computed from its store's rule
+type CommissionDiscrepancy struct {
+	SalesRecordID int64   `json:"sales_record_id"`
+	Store         string  `json:"store"`
+	Vendor        string  `json:"vendor"`
+	Date          Date    `json:"date"`
+	Description   string  `json:"description"`
+	IsReturn      bool    `json:"is_return"`
+	SalePrice     float64 `json:"sale_price"`
+	Rate          float64 `json:"rate"`
+	Reported      float64 `json:"reported"`
+	Expected      float64 `json:"expected"`
+	Difference    float64 `json:"difference"` // Reported minus expected; positive when the store over-charged
+}
+
+// StoreReconciliation totals a store's reported and expected commissions for
+// one month, net of returns
+type StoreReconciliation struct {
+	Store          string  `json:"store"`
+	Month          string  `json:"month"` // YYYY-MM
+	CheckedItems   int64   `json:"checked_items"`
+	Reported       float64 `json:"reported"`
+	Expected       float64 `json:"expected"`
+	Difference     float64 `json:"difference"`
+	Discrepancies  int64   `json:"discrepancies"`
+	UncheckedItems int64   `json:"unchecked_items"` // Sales without a reported commission or a rule for their date
+}
+
+// CommissionReconciliation compares reported commissions with those computed
+// from the commission rules
+type CommissionReconciliation struct {
+	Stores        []StoreReconciliation   `json:"stores"`
+	Discrepancies []CommissionDiscrepancy `json:"discrepancies"`
+	Overcharged   float64                 `json:"overcharged"`  // Sum of positive differences
+	Undercharged  float64                 `json:"undercharged"` // Sum of negative differences, as a positive amount
+}
+
+// RemainingBackfillRequest checks the remaining amount of the records matching
+// Filter against their sale price less commission, and optionally fixes it.
+// The commission is the reported one or, when none was reported, the one
+// computed from the store's rule for the sale date.
+type RemainingBackfillRequest struct {
+	Filter    SalesRecordFilter `json:"filter"`              // Records to check; limit, offset and sorting are ignored
+	Tolerance float64           `json:"tolerance,omitempty"` // Defaults to DefaultCommissionTolerance
+	Fix       bool              `json:"fix,omitempty"`       // Store the expected amount on the records listed; otherwise only report them
+}
+
+// RemainingDiscrepancy is a record whose stored remaining amount is missing or
+// differs from its sale price less commission by more than the tolerance
+type RemainingDiscrepancy struct {
+	SalesRecordID int64    `json:"sales_record_id"`
+	Store         string   `json:"store"`
+	Vendor        string   `json:"vendor"`
+	Date          Date     `json:"date"`
+	Description   string   `json:"description"`
+	SalePrice     float64  `json:"sale_price"`
+	Commission    float64  `json:"commission"`
+	Rate          *float64 `json:"rate,omitempty"`       // The rule's rate, when no commission was reported
+	Stored        *float64 `json:"stored"`               // Nil when no remaining amount was stored
+	Expected      float64  `json:"expected"`             // Sale price less commission
+	Difference    *float64 `json:"difference,omitempty"` // Stored minus expected; nil when nothing was stored
+}
+
+// RemainingBackfillResult reports the records checked by a remaining amount
+// backfill and those that were, or would be, fixed
+type RemainingBackfillResult struct {
+	Checked       int64                  `json:"checked"`
+	Unchecked     int64                  `json:"unchecked"` // Without a reported commission or a rule for their date, or with a commission above the sale price
+	Discrepancies []RemainingDiscrepancy `json:"discrepancies"`
+	Fixed         int64                  `json:"fixed"` // Records whose remaining amount was set; 0 unless Fix was requested
+}
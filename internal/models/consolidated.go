@@ -0,0 +1,28 @@
+package models
+
+// MaxConsolidatedDatabases is the number of other database files a
+// consolidated report can include, SQLite's limit on attached databases
+const MaxConsolidatedDatabases = 10
+
+// BusinessDatabase is the database file of another business, included in a
+// consolidated report under its label
+type BusinessDatabase struct {
+	Label string `json:"label"`
+	Path  string `json:"path"`
+}
+
+// ConsolidatedReportRequest selects the businesses of a consolidated report:
+// the open database, under Label, and the database files of the others
+type ConsolidatedReportRequest struct {
+	Label     string             `json:"label"` // The business of the open database
+	Databases []BusinessDatabase `json:"databases"`
+	Year      *string            `json:"year,omitempty"` // nil reports every year
+}
+
+// ConsolidatedReport is the monthly summary of several businesses, each kept
+// in its own database, per business and combined
+type ConsolidatedReport struct {
+	Businesses []string         `json:"businesses"`  // Labels in the order requested, the open database's first
+	ByBusiness []MonthlySummary `json:"by_business"` // One row per business within each month; Dimension is the label
+	Combined   []MonthlySummary `json:"combined"`    // One row per month across every business
+}
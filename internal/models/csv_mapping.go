@@ -0,0 +1,14 @@
+package models
+
+// MaxMappingProfiles is the most mapping profiles that can be saved
+const MaxMappingProfiles = 50
+
+// CSVMapping assigns fields to the columns of a delimited file by header, as
+// chosen in the mapping wizard for files that match no built-in layout.
+// Saved under a name it is a mapping profile, for importing files from the
+// same source again.
+type CSVMapping struct {
+	Name      string            `json:"name,omitempty"` // Required to save the mapping as a profile
+	Delimiter string            `json:"delimiter"`      // One character, such as "," or ";"
+	Columns   map[string]string `json:"columns"`        // Field each column holds, such as "sale_price", by header; other columns are not imported
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Digest periods
+const (
+	DigestWeekly  = "weekly"
+	DigestMonthly = "monthly"
+)
+
+// DigestSchedule decides whether the digest is delivered and for which
+// period. While enabled, each period's digest is recorded in the
+// notification center once the period is complete.
+type DigestSchedule struct {
+	Enabled bool   `json:"enabled"`
+	Period  string `json:"period"` // DigestWeekly or DigestMonthly
+}
+
+// DefaultDigestSchedule returns the schedule used until another is saved:
+// weekly, but not enabled
+func DefaultDigestSchedule() DigestSchedule {
+	return DigestSchedule{Period: DigestWeekly}
+}
+
+// Days a week can start on
+const (
+	WeekStartMonday = "monday"
+	WeekStartSunday = "sunday"
+)
+
+// DefaultWeekStart is the day weeks start on until another is saved
+const DefaultWeekStart = WeekStartMonday
+
+// WeekStartDay returns the weekday weekStart names: Sunday for
+// WeekStartSunday, otherwise Monday
+func WeekStartDay(weekStart string) time.Weekday {
+	if weekStart == WeekStartSunday {
+		return time.Sunday
+	}
+	return time.Monday
+}
+
+// StartOfWeek returns the first day of the week of t, for weeks starting on
+// weekStart
+func StartOfWeek(t time.Time, weekStart time.Weekday) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -(int(day.Weekday()-weekStart)+7)%7)
+}
+
+// PeriodTotals sums the sales dated in a period, net of returns
+type PeriodTotals struct {
+	From            Date    `json:"from"`
+	To              Date    `json:"to"` // Last day of the period, inclusive
+	ItemsSold       int64   `json:"items_sold"`
+	ReturnedItems   int64   `json:"returned_items"`
+	TotalSales      float64 `json:"total_sales"`
+	TotalCommission float64 `json:"total_commission"`
+	TotalRemaining  float64 `json:"total_remaining"`
+}
+
+// VendorTotal is a vendor's sales in a period
+type VendorTotal struct {
+	Vendor     string  `json:"vendor"`
+	ItemsSold  int64   `json:"items_sold"`
+	TotalSales float64 `json:"total_sales"`
+}
+
+// Digest summarizes the last complete week or month against the one before,
+// for owners who do not open the app daily
+type Digest struct {
+	Period      string       `json:"period"` // DigestWeekly or DigestMonthly
+	Current     PeriodTotals `json:"current"`
+	Previous    PeriodTotals `json:"previous"`
+	TopVendor   *VendorTotal `json:"top_vendor,omitempty"`   // Vendor with the highest sales in the current period
+	SalesChange *float64     `json:"sales_change,omitempty"` // Percentage change in sales; nil when the previous period had none
+}
+
+// DigestPeriods returns the first days of the last complete period before
+// now and of the period before it, and the day after the last one. Weeks
+// start on weekStart.
+func DigestPeriods(period string, now time.Time, weekStart time.Weekday) (previous, current, end time.Time, err error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case DigestWeekly:
+		end = StartOfWeek(today, weekStart)
+		return end.AddDate(0, 0, -14), end.AddDate(0, 0, -7), end, nil
+	case DigestMonthly:
+		end = time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return end.AddDate(0, -2, 0), end.AddDate(0, -1, 0), end, nil
+	default:
+		return time.Time{}, time.Time{}, time.Time{}, fmt.Errorf("unknown digest period %q", period)
+	}
+}
+
+// Text renders the digest as a plain-text message
+func (d Digest) Text() string {
+	var b strings.Builder
+	label := "Week"
+	if d.Period == DigestMonthly {
+		label = "Month"
+	}
+
+	fmt.Fprintf(&b, "%s of %s to %s\n\n", label, d.Current.From, d.Current.To)
+	fmt.Fprintf(&b, "Sales: %.2f from %d items", d.Current.TotalSales, d.Current.ItemsSold)
+	if d.Current.ReturnedItems > 0 {
+		fmt.Fprintf(&b, " (%d returned)", d.Current.ReturnedItems)
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "Commission: %.2f\n", d.Current.TotalCommission)
+	fmt.Fprintf(&b, "Remaining: %.2f\n", d.Current.TotalRemaining)
+
+	if d.SalesChange != nil {
+		fmt.Fprintf(&b, "Compared to the previous %s: %+.1f%% (%.2f)\n", strings.ToLower(label), *d.SalesChange, d.Previous.TotalSales)
+	} else {
+		fmt.Fprintf(&b, "No sales in the previous %s\n", strings.ToLower(label))
+	}
+
+	if d.TopVendor != nil {
+		fmt.Fprintf(&b, "Top vendor: %s, %.2f from %d items\n", d.TopVendor.Vendor, d.TopVendor.TotalSales, d.TopVendor.ItemsSold)
+	}
+	return b.String()
+}
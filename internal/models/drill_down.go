@@ -0,0 +1,63 @@
+package models
+
+// DrillDownColumns are the columns a drill-down table can hold, in the order
+// used when none are chosen
+var DrillDownColumns = []string{
+	"id", "date", "store", "vendor", "description", "sale_price",
+	"commission", "remaining", "is_return", "currency", "category",
+}
+
+// DrillDownSortFields are the fields a drill-down table can be sorted by
+var DrillDownSortFields = []string{"date", "sale_price", "description", "vendor", "store"}
+
+// DrillDownRequest selects the records of a year, month or day for the
+// drill-down grid, sorted and holding only the columns the grid shows
+type DrillDownRequest struct {
+	Year      string   `json:"year"`                 // YYYY
+	Month     *string  `json:"month,omitempty"`      // MM
+	Day       *string  `json:"day,omitempty"`        // DD; requires Month
+	SortBy    string   `json:"sort_by,omitempty"`    // One of DrillDownSortFields; defaults to date
+	SortOrder string   `json:"sort_order,omitempty"` // asc or desc; defaults to desc
+	Columns   []string `json:"columns,omitempty"`    // Columns of DrillDownColumns to return, in order; all when empty
+}
+
+// DrillDownTable holds the records of a drill-down as rows of values in the
+// order of Columns, so the grid can show them without reshaping. Dates are
+// YYYY-MM-DD and unknown amounts are null.
+type DrillDownTable struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// MaxDrillDownPeriods is the most periods a batched drill-down may request
+const MaxDrillDownPeriods = 36
+
+// DrillDownPeriod is a year, month or day of a batched drill-down
+type DrillDownPeriod struct {
+	Year  string  `json:"year"`            // YYYY
+	Month *string `json:"month,omitempty"` // MM
+	Day   *string `json:"day,omitempty"`   // DD; requires Month
+}
+
+// DrillDownBatchRequest selects the records of several periods at once, as
+// when the user expands several months, sorted and holding the same columns
+// for each
+type DrillDownBatchRequest struct {
+	Periods   []DrillDownPeriod `json:"periods"`
+	SortBy    string            `json:"sort_by,omitempty"`
+	SortOrder string            `json:"sort_order,omitempty"`
+	Columns   []string          `json:"columns,omitempty"`
+}
+
+// DrillDownGroup holds the rows of one period of a batched drill-down
+type DrillDownGroup struct {
+	Period string          `json:"period"` // YYYY, YYYY-MM or YYYY-MM-DD
+	Rows   [][]interface{} `json:"rows"`
+}
+
+// DrillDownBatch holds the rows of each requested period, in the order
+// requested, with values in the order of Columns
+type DrillDownBatch struct {
+	Columns []string         `json:"columns"`
+	Groups  []DrillDownGroup `json:"groups"`
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+// EnrichmentRequest applies a cost, category or tags to every record matching
+// a filter, for enriching existing data without editing records one by one
+type EnrichmentRequest struct {
+	Filter      SalesRecordFilter `json:"filter"` // Records to change; limit, offset and sorting are ignored
+	Cost        *float64          `json:"cost,omitempty"`
+	Category    *string           `json:"category,omitempty"` // "" clears the category
+	AddTags     []string          `json:"add_tags,omitempty"`
+	RemoveTags  []string          `json:"remove_tags,omitempty"`
+	OnlyMissing bool              `json:"only_missing,omitempty"` // Keep costs and categories records already have
+	DryRun      bool              `json:"dry_run,omitempty"`      // Report what would change without changing it
+}
+
+// CategoryMappingRequest sets the category of records by their description,
+// from a list pasted with one "description → category" pair per line
+type CategoryMappingRequest struct {
+	Mappings    string `json:"mappings"`
+	OnlyMissing bool   `json:"only_missing,omitempty"` // Keep categories records already have
+	DryRun      bool   `json:"dry_run,omitempty"`
+}
+
+// CategoryMapping is a pair read from a mapping list. Records match when
+// their product key equals the key of the description, so case, punctuation
+// and word order do not matter.
+type CategoryMapping struct {
+	Line        int    `json:"line"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+	Updated     int64  `json:"updated"` // Records whose category was set by this pair
+}
+
+// EnrichmentResult reports what a bulk enrichment changed
+type EnrichmentResult struct {
+	Updated  int64             `json:"updated"`            // Records changed
+	Mappings []CategoryMapping `json:"mappings,omitempty"` // Outcome of each pair of a mapping list
+	Skipped  []string          `json:"skipped,omitempty"`  // Lines of a mapping list that are not a pair
+	DryRun   bool              `json:"dry_run,omitempty"`
+}
+
+// mappingSeparators separate a description from its category, in order of
+// preference. The last occurrence is used, so descriptions may contain commas.
+var mappingSeparators = []string{"→", "->", "=>", "\t", ","}
+
+// ParseCategoryMappings reads one description and category pair per line,
+// separated by an arrow (→, -> or =>), a tab or a comma. Blank lines and
+// lines starting with # are ignored; other lines that are not a pair are
+// returned as skipped.
+func ParseCategoryMappings(text string) ([]CategoryMapping, []string) {
+	var mappings []CategoryMapping
+	var skipped []string
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		mapping, ok := parseCategoryMapping(line)
+		if !ok {
+			skipped = append(skipped, line)
+			continue
+		}
+		mapping.Line = i + 1
+		mappings = append(mappings, mapping)
+	}
+	return mappings, skipped
+}
+
+// parseCategoryMapping splits a line at the last occurrence of the first
+// separator it contains
+func parseCategoryMapping(line string) (CategoryMapping, bool) {
+	for _, sep := range mappingSeparators {
+		idx := strings.LastIndex(line, sep)
+		if idx < 0 {
+			continue
+		}
+		description := strings.TrimSpace(line[:idx])
+		category := strings.TrimSpace(line[idx+len(sep):])
+		if description == "" || category == "" {
+			return CategoryMapping{}, false
+		}
+		return CategoryMapping{Description: description, Category: category}, true
+	}
+	return CategoryMapping{}, false
+}
+
+// NormalizeTags trims tags and returns them sorted without blanks or
+// duplicates
+func NormalizeTags(tags []string) Tags {
+	seen := make(map[string]bool, len(tags))
+	var normalized Tags
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// ReferenceCurrency is the currency exchange rates are quoted against.
+// It matches the European Central Bank reference rates, so ECB data can be
+// stored as published.
+const ReferenceCurrency = "EUR"
+
+// ExchangeRate is the number of units of Currency worth 1 EUR on Date
+type ExchangeRate struct {
+	ID        int64     `json:"id" db:"id"`
+	Date      Date      `json:"date" db:"date"`
+	Currency  string    `json:"currency" db:"currency"`
+	Rate      float64   `json:"rate" db:"rate"`
+	Source    string    `json:"source" db:"source"` // "manual" or "ecb"
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateExchangeRateRequest represents an exchange rate entered manually or fetched
+type CreateExchangeRateRequest struct {
+	Date     string  `json:"date" validate:"required"`     // Date as string for parsing
+	Currency string  `json:"currency" validate:"required"` // ISO 4217 code
+	Rate     float64 `json:"rate" validate:"required,gt=0"`
+	Source   string  `json:"source,omitempty"` // Defaults to "manual"
+}
+
+// ExchangeRateFilter represents filtering options for listing exchange rates
+type ExchangeRateFilter struct {
+	Currency *string    `json:"currency,omitempty"`
+	DateFrom *time.Time `json:"date_from,omitempty"`
+	DateTo   *time.Time `json:"date_to,omitempty"`
+}
+
+// NormalizeCurrency trims and upper-cases a currency code
+func NormalizeCurrency(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// IsCurrencyCode reports whether code looks like an ISO 4217 code (three letters)
+func IsCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Currency symbol placements
+const (
+	SymbolBefore = "before" // $1,234.50
+	SymbolAfter  = "after"  // 1.234,50 €
+)
+
+// ExportDateFormats are the date formats exporters can write, keyed by the
+// pattern shown to the user
+var ExportDateFormats = map[string]string{
+	"YYYY-MM-DD": "2006-01-02",
+	"MM/DD/YYYY": "01/02/2006",
+	"DD/MM/YYYY": "02/01/2006",
+	"DD.MM.YYYY": "02.01.2006",
+	"DD-MM-YYYY": "02-01-2006",
+}
+
+// ExportFormat controls how exporters write numbers, amounts and dates, so
+// exports open correctly in spreadsheets set up for the user's region
+type ExportFormat struct {
+	DecimalSeparator   string `json:"decimal_separator"`   // "." or ","
+	ThousandsSeparator string `json:"thousands_separator"` // "", ",", ".", " " or "'"
+	CSVDelimiter       string `json:"csv_delimiter"`       // ",", ";" or a tab; regional Excel expects ";" where the decimal separator is ","
+	CurrencySymbol     string `json:"currency_symbol"`     // Such as "$" or "€"; empty writes plain numbers
+	SymbolPlacement    string `json:"symbol_placement"`    // SymbolBefore or SymbolAfter
+	DateFormat         string `json:"date_format"`         // A key of ExportDateFormats
+}
+
+// DefaultExportFormat returns the format used until one is saved: plain
+// numbers and ISO dates, which every spreadsheet reads
+func DefaultExportFormat() ExportFormat {
+	return ExportFormat{
+		DecimalSeparator: ".",
+		CSVDelimiter:     ",",
+		SymbolPlacement:  SymbolBefore,
+		DateFormat:       "YYYY-MM-DD",
+	}
+}
+
+// FormatNumber writes an amount with two decimals and the format's separators
+func (f ExportFormat) FormatNumber(amount float64) string {
+	cents := int64(math.Round(math.Abs(amount) * 100))
+	whole := strconv.FormatInt(cents/100, 10)
+	if f.ThousandsSeparator != "" {
+		var grouped strings.Builder
+		for i, digit := range whole {
+			if i > 0 && (len(whole)-i)%3 == 0 {
+				grouped.WriteString(f.ThousandsSeparator)
+			}
+			grouped.WriteRune(digit)
+		}
+		whole = grouped.String()
+	}
+
+	decimal := f.DecimalSeparator
+	if decimal == "" {
+		decimal = "."
+	}
+	number := whole + decimal + strconv.FormatInt(100+cents%100, 10)[1:]
+	if amount < 0 && cents != 0 {
+		number = "-" + number
+	}
+	return number
+}
+
+// FormatMoney writes an amount with the format's currency symbol, if any
+func (f ExportFormat) FormatMoney(amount float64) string {
+	number := f.FormatNumber(amount)
+	switch {
+	case f.CurrencySymbol == "":
+		return number
+	case f.SymbolPlacement == SymbolAfter:
+		return number + " " + f.CurrencySymbol
+	case strings.HasPrefix(number, "-"):
+		return "-" + f.CurrencySymbol + number[1:]
+	default:
+		return f.CurrencySymbol + number
+	}
+}
+
+// FormatDate writes a date in the format's date format
+func (f ExportFormat) FormatDate(t time.Time) string {
+	layout, ok := ExportDateFormats[f.DateFormat]
+	if !ok {
+		layout = DateLayout
+	}
+	return t.Format(layout)
+}
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// Fee categories
+const (
+	FeeCategoryListing = "listing" // Charged per item listed
+	FeeCategoryRent    = "rent"    // Booth or shelf rent
+	FeeCategoryPenalty = "penalty" // Such as a late or restocking penalty
+	FeeCategoryOther   = "other"
+)
+
+// FeeCategories lists the fee categories in display order
+var FeeCategories = []string{FeeCategoryListing, FeeCategoryRent, FeeCategoryPenalty, FeeCategoryOther}
+
+// IsFeeCategory reports whether category is one of FeeCategories
+func IsFeeCategory(category string) bool {
+	for _, c := range FeeCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// Fee is a non-sale line item charged by a store, such as a listing fee,
+// kept apart from sales so it does not count towards sales totals
+type Fee struct {
+	ID          int64     `json:"id" db:"id"`
+	Store       string    `json:"store" db:"store"`
+	Vendor      *string   `json:"vendor,omitempty" db:"vendor"`
+	Date        Date      `json:"date" db:"date"`
+	Category    string    `json:"category" db:"category"`
+	Description string    `json:"description" db:"description"`
+	Amount      float64   `json:"amount" db:"amount"`                         // Positive amount charged
+	ImportRunID *int64    `json:"import_run_id,omitempty" db:"import_run_id"` // Import the fee was read from; nil when entered by hand
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateFeeRequest represents a new fee
+type CreateFeeRequest struct {
+	Store       string  `json:"store" validate:"required"`
+	Vendor      *string `json:"vendor,omitempty"`
+	Date        string  `json:"date" validate:"required"` // Date as string for parsing
+	Category    string  `json:"category,omitempty"`       // One of FeeCategories; FeeCategoryOther when empty
+	Description string  `json:"description" validate:"required"`
+	Amount      float64 `json:"amount"`
+}
+
+// FeeFilter selects fees to list
+type FeeFilter struct {
+	Store    *string    `json:"store,omitempty"`
+	Category *string    `json:"category,omitempty"`
+	DateFrom *time.Time `json:"date_from,omitempty"`
+	DateTo   *time.Time `json:"date_to,omitempty"`
+}
+
+// MonthlyFeeSummary totals the fees charged in one month
+type MonthlyFeeSummary struct {
+	Month      string             `json:"month"` // YYYY-MM
+	Fees       int64              `json:"fees"`
+	Total      float64            `json:"total"`
+	ByCategory map[string]float64 `json:"by_category"` // Total per fee category charged in the month
+}
+
+// NetIncomeMonth is the income of one month after fees: the remaining amount
+// of its sales, net of returns, less the fees charged
+type NetIncomeMonth struct {
+	Month          string  `json:"month"` // YYYY-MM
+	TotalSales     float64 `json:"total_sales"`
+	TotalRemaining float64 `json:"total_remaining"`
+	TotalFees      float64 `json:"total_fees"`
+	NetIncome      float64 `json:"net_income"` // TotalRemaining - TotalFees
+}
@@ -0,0 +1,20 @@
+package models
+
+// Fields an ignore rule can match
+const (
+	IgnoreFieldDescription = "description"
+	IgnoreFieldStore       = "store"
+)
+
+// MaxIgnoreRules is the most ignore rules that can be saved
+const MaxIgnoreRules = 100
+
+// IgnoreRule skips report rows whose description or store matches a regular
+// expression during import, keeping lines such as "LISTING FEE" or
+// "SHIPPING" out of sales totals. A rule with a fee category records the rows
+// it matches as fees instead of dropping them.
+type IgnoreRule struct {
+	Field       string `json:"field"`                  // IgnoreFieldDescription or IgnoreFieldStore
+	Pattern     string `json:"pattern"`                // Regular expression, matched ignoring case anywhere in the value
+	FeeCategory string `json:"fee_category,omitempty"` // One of FeeCategories to record matching rows as fees; empty drops them
+}
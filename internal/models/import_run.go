@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// Import sources recorded in the import history
+const (
+	ImportSourcePaste = "paste"
+	ImportSourceFile  = "file"
+)
+
+// ImportRun is the persisted summary of a single import
+type ImportRun struct {
+	ID            int64          `json:"id" db:"id"`
+	StartedAt     time.Time      `json:"started_at" db:"started_at"`
+	Source        string         `json:"source" db:"source"`                 // "paste" or "file"
+	FileName      *string        `json:"file_name,omitempty" db:"file_name"` // Base name of the imported file
+	Title         *string        `json:"title,omitempty" db:"title"`         // Caption or heading of the imported table
+	Name          *string        `json:"name,omitempty" db:"name"`           // Describes the records imported, such as "Consignable – March 2024 – Downtown Store, 182 rows"
+	Method        string         `json:"method" db:"method"`                 // Import API used, such as "batch" or "stream"
+	Layout        *string        `json:"layout,omitempty" db:"layout"`       // Built-in layout used, if any
+	Success       bool           `json:"success" db:"success"`
+	TotalRows     int            `json:"total_rows" db:"total_rows"`
+	ParsedRows    int            `json:"parsed_rows" db:"parsed_rows"`
+	ImportedRows  int            `json:"imported_rows" db:"imported_rows"`
+	UpdatedRows   int            `json:"updated_rows" db:"updated_rows"`
+	UnchangedRows int            `json:"unchanged_rows" db:"unchanged_rows"`
+	ErrorRows     int            `json:"error_rows" db:"error_rows"` // Rows that failed to parse or to import
+	Duration      Duration       `json:"duration" db:"duration_ms"`
+	Timings       *ImportTimings `json:"timings,omitempty"` // Time in each stage; nil for imports recorded before timings were kept
+	ErrorMessage  *string        `json:"error_message,omitempty" db:"error_message"`
+	ContentHash   *string        `json:"content_hash,omitempty" db:"content_hash"` // SHA-256 of the parsed records, for recognizing the same report imported again
+	CreatedAt     time.Time      `json:"created_at" db:"created_at"`
+}
+
+// ImportTimings is the time an import spent in each stage, for finding where
+// a slow import's time goes. A streaming import inserts while it parses, so
+// its stages overlap and can add up to more than its duration.
+type ImportTimings struct {
+	Parse    Duration `json:"parse"`    // Reading the table and mapping its headers
+	Validate Duration `json:"validate"` // Reading and checking the values of each row
+	Dedupe   Duration `json:"dedupe"`   // Upsert imports: looking up and comparing records already imported
+	Insert   Duration `json:"insert"`   // Writing the records
+}
+
+// MaxImportSourceSize is the largest import whose raw content is kept
+const MaxImportSourceSize = 64 << 20
+
+// ImportSource is the raw content of an import, exactly as pasted or read
+// from the imported file
+type ImportSource struct {
+	ImportRunID int64     `json:"import_run_id" db:"import_run_id"`
+	Content     string    `json:"content"`
+	Size        int64     `json:"size" db:"size"`               // Bytes of content
+	StoredSize  int64     `json:"stored_size" db:"stored_size"` // Bytes stored after compression
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// ImportActivity summarizes the imports run in one month. Months without any
+// imports between the first and last import are included with zero counts so
+// gaps are visible.
+type ImportActivity struct {
+	Month         string  `json:"month"` // YYYY-MM
+	Imports       int64   `json:"imports"`
+	FailedImports int64   `json:"failed_imports"`
+	TotalRows     int64   `json:"total_rows"`
+	ImportedRows  int64   `json:"imported_rows"`
+	UpdatedRows   int64   `json:"updated_rows"`
+	ErrorRows     int64   `json:"error_rows"`
+	ErrorRate     float64 `json:"error_rate"`      // ErrorRows / TotalRows
+	HighErrorRate bool    `json:"high_error_rate"` // Error rate well above the overall rate
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// KPIComparison compares a period's totals with those of the prior
+// comparable period
+type KPIComparison struct {
+	Current         PeriodTotals `json:"current"`
+	Previous        PeriodTotals `json:"previous"`
+	SalesDelta      float64      `json:"sales_delta"`
+	CommissionDelta float64      `json:"commission_delta"`
+	NetDelta        float64      `json:"net_delta"` // Change in remaining, what is left after commission
+	ItemsDelta      int64        `json:"items_delta"`
+	SalesChange     *float64     `json:"sales_change,omitempty"` // Percentage change in sales; nil when the prior period had none
+}
+
+// NewKPIComparison computes the deltas between current and previous
+func NewKPIComparison(current, previous PeriodTotals) KPIComparison {
+	return KPIComparison{
+		Current:         current,
+		Previous:        previous,
+		SalesDelta:      math.Round((current.TotalSales-previous.TotalSales)*100) / 100,
+		CommissionDelta: math.Round((current.TotalCommission-previous.TotalCommission)*100) / 100,
+		NetDelta:        math.Round((current.TotalRemaining-previous.TotalRemaining)*100) / 100,
+		ItemsDelta:      current.ItemsSold - previous.ItemsSold,
+		SalesChange:     PercentChange(current.TotalSales, previous.TotalSales),
+	}
+}
+
+// DashboardKPIs holds the month-to-date and year-to-date figures shown on the
+// dashboard, each compared with the same days of the prior month or year
+type DashboardKPIs struct {
+	AsOf        Date            `json:"as_of"` // Last day included
+	MonthToDate KPIComparison   `json:"month_to_date"`
+	YearToDate  KPIComparison   `json:"year_to_date"`
+	Projection  MonthProjection `json:"projection"`
+}
+
+// MonthProjection paces the month's sales to its end, assuming the rest of
+// the month sells at the daily rate so far, next to the whole of the same
+// month a year earlier
+type MonthProjection struct {
+	DaysElapsed    int      `json:"days_elapsed"`
+	DaysInMonth    int      `json:"days_in_month"`
+	ProjectedSales float64  `json:"projected_sales"`  // Month-to-date sales ÷ days elapsed × days in month
+	LastYearSales  float64  `json:"last_year_sales"`  // The same month a year earlier, all of it
+	LastYearChange *float64 `json:"last_year_change"` // Percentage change from LastYearSales; nil when it had none
+}
+
+// NewMonthProjection paces monthToDate, the sales of the month up to and
+// including asOf, to the end of the month
+func NewMonthProjection(monthToDate, lastYear float64, asOf time.Time) MonthProjection {
+	projection := MonthProjection{
+		DaysElapsed:   asOf.Day(),
+		DaysInMonth:   time.Date(asOf.Year(), asOf.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day(),
+		LastYearSales: lastYear,
+	}
+	projected := monthToDate / float64(projection.DaysElapsed) * float64(projection.DaysInMonth)
+	projection.ProjectedSales = math.Round(projected*100) / 100
+	projection.LastYearChange = PercentChange(projection.ProjectedSales, lastYear)
+	return projection
+}
+
+// TrailingTwelveMonths holds the totals of the twelve months ending with a
+// month, compared with the twelve months before them, for trends smoother
+// than calendar years give
+type TrailingTwelveMonths struct {
+	EndMonth   string        `json:"end_month"` // YYYY-MM
+	AsOf       Date          `json:"as_of"`     // Last day included: today for the current month
+	Comparison KPIComparison `json:"comparison"`
+}
+
+// PercentChange returns the change from previous to current as a
+// percentage of previous, or nil if previous is zero
+func PercentChange(current, previous float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := (current - previous) / math.Abs(previous) * 100
+	return &change
+}
+
+// DashboardAsOf returns the last day the dashboard covers for period, a month
+// written as YYYY-MM: today for the current month, or the month's last day for
+// an earlier one. An empty period is the current month.
+func DashboardAsOf(period string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if period == "" {
+		return today, nil
+	}
+
+	month, err := time.Parse("2006-01", period)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("period %q is not a month in YYYY-MM form", period)
+	}
+	switch {
+	case month.Year() == today.Year() && month.Month() == today.Month():
+		return today, nil
+	case month.After(today):
+		return time.Time{}, fmt.Errorf("period %s is in the future", period)
+	default:
+		return month.AddDate(0, 1, -1), nil
+	}
+}
+
+// SameDayIn returns the day numbered like day in the given month, or the
+// month's last day when it is shorter, as for March 31 in February
+func SameDayIn(year int, month time.Month, day int) time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,23 @@
+package models
+
+// MinMemoryBudgetMB is the smallest memory budget that can be saved
+const MinMemoryBudgetMB = 64
+
+// MemoryBudget caps the memory a parse or export of large data may use before
+// the app switches to chunked processing or temporary files. Pasted data too
+// large to parse whole within the budget is imported through the streaming
+// pipeline, and exports too large to sort within it are sorted in a temporary
+// file.
+type MemoryBudget struct {
+	LimitMB int64 `json:"limit_mb"`
+}
+
+// DefaultMemoryBudget returns the budget used until another is saved
+func DefaultMemoryBudget() MemoryBudget {
+	return MemoryBudget{LimitMB: 512}
+}
+
+// Bytes returns the budget in bytes
+func (b MemoryBudget) Bytes() int64 {
+	return b.LimitMB << 20
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Notification levels
+const (
+	NotificationInfo  = "info"
+	NotificationError = "error"
+)
+
+// Notification sources: the background work that recorded a notification
+const (
+	NotificationSourceBackup     = "backup"
+	NotificationSourceCompaction = "compaction"
+	NotificationSourceDigest     = "digest"    // The scheduled sales digest
+	NotificationSourceRetention  = "retention" // Scheduled maintenance applying the retention policy
+)
+
+// Notification records the outcome of background work, kept until read so
+// it is not missed by a user who was not watching when it finished
+type Notification struct {
+	ID        int64      `json:"id" db:"id"`
+	Source    string     `json:"source" db:"source"` // One of the NotificationSource* values
+	Level     string     `json:"level" db:"level"`   // NotificationInfo or NotificationError
+	Title     string     `json:"title" db:"title"`
+	Message   string     `json:"message" db:"message"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty" db:"read_at"` // nil until marked read
+}
+
+// NotificationFilter selects the notifications to list
+type NotificationFilter struct {
+	UnreadOnly bool `json:"unread_only"`
+	Limit      int  `json:"limit,omitempty"` // 0 lists up to DefaultNotificationLimit
+}
+
+// DefaultNotificationLimit is the number of notifications listed when a
+// filter sets no limit
+const DefaultNotificationLimit = 50
+
+// NotificationList is a page of notifications, newest first, and the number
+// unread, for the badge on the notification center
+type NotificationList struct {
+	Notifications []Notification `json:"notifications"`
+	Unread        int64          `json:"unread"`
+}
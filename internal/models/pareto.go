@@ -0,0 +1,79 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Pareto report groupings
+const (
+	ParetoByVendor = "vendor"
+	ParetoByItem   = "item"
+)
+
+// paretoCutoff is the cumulative share of revenue, as a percentage, the core
+// groups of a Pareto report make up
+const paretoCutoff = 80
+
+// ParetoFilter selects the sales a Pareto report covers and how they are
+// grouped
+type ParetoFilter struct {
+	GroupBy  string     `json:"group_by"` // ParetoByVendor or ParetoByItem
+	Store    *string    `json:"store,omitempty"`
+	DateFrom *time.Time `json:"date_from,omitempty"`
+	DateTo   *time.Time `json:"date_to,omitempty"`
+}
+
+// ParetoEntry is a group's revenue, net of returns, and its share of the
+// total alone and together with every group ranked above it
+type ParetoEntry struct {
+	Rank            int     `json:"rank"`
+	Group           string  `json:"group"`                 // Vendor or product key
+	Description     string  `json:"description,omitempty"` // A description of the item, when grouped by item
+	Revenue         float64 `json:"revenue"`
+	Share           float64 `json:"share"`            // Percentage of total revenue, to one decimal
+	CumulativeShare float64 `json:"cumulative_share"` // Percentage of total revenue up to and including this group, to one decimal
+}
+
+// ParetoReport ranks groups by revenue, highest first, showing how
+// concentrated revenue is: CoreGroups groups, CoreShare percent of them,
+// bring in 80% of it
+type ParetoReport struct {
+	GroupBy    string        `json:"group_by"`
+	Total      float64       `json:"total"`
+	Entries    []ParetoEntry `json:"entries"`
+	CoreGroups int           `json:"core_groups"` // Top groups needed to reach 80% of revenue
+	CoreShare  float64       `json:"core_share"`  // CoreGroups as a percentage of all groups, to one decimal
+}
+
+// CalculateShares ranks the entries, already ordered by revenue, and sets the
+// total, their shares of it and the core groups. Shares are zero when there
+// is no revenue.
+func (r *ParetoReport) CalculateShares() {
+	r.Total = 0
+	for _, entry := range r.Entries {
+		r.Total += entry.Revenue
+	}
+	r.Total = math.Round(r.Total*100) / 100
+
+	r.CoreGroups = 0
+	r.CoreShare = 0
+	var cumulative float64
+	for i := range r.Entries {
+		entry := &r.Entries[i]
+		entry.Rank = i + 1
+		entry.Share, entry.CumulativeShare = 0, 0
+		if r.Total <= 0 {
+			continue
+		}
+		cumulative += entry.Revenue
+		entry.Share = math.Round(entry.Revenue/r.Total*1000) / 10
+		entry.CumulativeShare = math.Round(cumulative/r.Total*1000) / 10
+		if r.CoreGroups == 0 && entry.CumulativeShare >= paretoCutoff {
+			r.CoreGroups = entry.Rank
+		}
+	}
+	if r.CoreGroups > 0 {
+		r.CoreShare = math.Round(float64(r.CoreGroups)/float64(len(r.Entries))*1000) / 10
+	}
+}
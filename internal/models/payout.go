@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// MaxPayoutMonthsAfter is the longest a store may take to pay out a month's sales
+const MaxPayoutMonthsAfter = 3
+
+// PayoutSchedule is when a store pays out a month's sales: on PayoutDay of
+// the month MonthsAfter the sale month
+type PayoutSchedule struct {
+	Store       string    `json:"store" db:"store"`
+	PayoutDay   int       `json:"payout_day" db:"payout_day"`     // 1 to 31; falls on the last day of shorter months
+	MonthsAfter int       `json:"months_after" db:"months_after"` // 0 pays in the sale month, 1 in the next
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SavePayoutScheduleRequest represents a store's payout schedule to save
+type SavePayoutScheduleRequest struct {
+	Store       string `json:"store" validate:"required"`
+	PayoutDay   int    `json:"payout_day" validate:"gte=1,lte=31"`
+	MonthsAfter int    `json:"months_after" validate:"gte=0,lte=3"`
+}
+
+// PayoutDate returns the day the store pays out the sales of month
+func (s PayoutSchedule) PayoutDate(month time.Time) time.Time {
+	first := time.Date(month.Year(), month.Month()+time.Month(s.MonthsAfter), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(s.PayoutDay, lastDay)-1)
+}
+
+// PayoutFilter selects expected payouts by payout date
+type PayoutFilter struct {
+	Store    *string    `json:"store,omitempty"`
+	DateFrom *time.Time `json:"date_from,omitempty"`
+	DateTo   *time.Time `json:"date_to,omitempty"`
+}
+
+// ExpectedPayout is what a store is expected to pay out for a month's sales,
+// net of returns
+type ExpectedPayout struct {
+	Store         string  `json:"store"`
+	Month         string  `json:"month"` // YYYY-MM of the sales paid out
+	Date          Date    `json:"date"`
+	ItemsSold     int64   `json:"items_sold"`
+	ReturnedItems int64   `json:"returned_items"`
+	Amount        float64 `json:"amount"` // Sum of remaining amounts; unreported ones count as zero
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// PeriodClose is a month closed once settled. While it is closed the month's
+// sales, fees and adjustments are read-only; reopening it keeps the close on
+// record so reports can flag changes made since.
+type PeriodClose struct {
+	Month        string     `json:"month" db:"month"`                           // YYYY-MM
+	SnapshotID   *int64     `json:"snapshot_id,omitempty" db:"snapshot_id"`     // Report snapshot of the month's records, taken at close
+	ClosedAt     time.Time  `json:"closed_at" db:"closed_at"`                   // Latest close
+	Note         *string    `json:"note,omitempty" db:"note"`                   // Such as the statement the month was settled against
+	ReopenedAt   *time.Time `json:"reopened_at,omitempty" db:"reopened_at"`     // Set while the month is open again
+	ReopenReason *string    `json:"reopen_reason,omitempty" db:"reopen_reason"` // Why it was reopened
+	Changed      bool       `json:"changed_since_close" db:"-"`                 // The month's records differ from the snapshot taken at close
+}
+
+// Closed reports whether the month is closed now rather than reopened
+func (p PeriodClose) Closed() bool {
+	return p.ReopenedAt == nil
+}
+
+// ClosePeriodRequest closes a month
+type ClosePeriodRequest struct {
+	Month string  `json:"month" validate:"required"` // YYYY-MM
+	Note  *string `json:"note,omitempty"`
+}
+
+// ReopenPeriodRequest reopens a closed month. A reason is required and kept
+// in the audit log.
+type ReopenPeriodRequest struct {
+	Month  string `json:"month" validate:"required"` // YYYY-MM
+	Reason string `json:"reason" validate:"required"`
+}
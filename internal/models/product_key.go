@@ -0,0 +1,63 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ProductKey derives a canonical grouping key from a free-text description.
+// The description is case-folded, punctuation is stripped, letter/digit runs
+// are split ("XPS13" becomes "xps 13"), and the tokens are de-duplicated and
+// sorted, so "Dell XPS 13 Laptop" and "Laptop - Dell XPS13" share a key.
+func ProductKey(description string) string {
+	tokens := tokenizeDescription(description)
+	if len(tokens) == 0 {
+		return ""
+	}
+
+	sort.Strings(tokens)
+
+	unique := tokens[:1]
+	for _, token := range tokens[1:] {
+		if token != unique[len(unique)-1] {
+			unique = append(unique, token)
+		}
+	}
+
+	return strings.Join(unique, " ")
+}
+
+// tokenizeDescription splits a description into lower-case alphanumeric tokens,
+// breaking on punctuation, whitespace, and letter/digit boundaries
+func tokenizeDescription(description string) []string {
+	var tokens []string
+	var current strings.Builder
+	var lastIsDigit bool
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range strings.ToLower(description) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			isDigit := unicode.IsDigit(r)
+			if current.Len() > 0 && isDigit != lastIsDigit {
+				flush()
+			}
+			current.WriteRune(r)
+			lastIsDigit = isDigit
+		case r == '\'':
+			// Drop apostrophes so "Levi's" and "Levis" match
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return tokens
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Profitability report groupings
+const (
+	ProfitByItem     = "item"
+	ProfitByVendor   = "vendor"
+	ProfitByCategory = "category"
+)
+
+// ProfitabilityFilter selects the sales a profitability report covers and
+// how they are grouped
+type ProfitabilityFilter struct {
+	GroupBy  string     `json:"group_by"` // ProfitByItem, ProfitByVendor or ProfitByCategory
+	Store    *string    `json:"store,omitempty"`
+	DateFrom *time.Time `json:"date_from,omitempty"`
+	DateTo   *time.Time `json:"date_to,omitempty"`
+}
+
+// ProfitSummary is the profit made on the items of a group, net of returns.
+// Sales, proceeds and cost only count items whose cost is known, so items
+// without one are reported as uncosted instead of being treated as free.
+type ProfitSummary struct {
+	Group          string   `json:"group"`                 // Product key, vendor or category; "" for uncategorized items
+	Description    string   `json:"description,omitempty"` // A description of the item, when grouped by item
+	CostedItems    int64    `json:"costed_items"`          // Items sold with a known cost, less those returned
+	UncostedItems  int64    `json:"uncosted_items"`        // Items sold without a known cost, less those returned
+	Sales          float64  `json:"sales"`
+	Proceeds       float64  `json:"proceeds"` // What the seller received: remaining, or sale price less commission
+	Cost           float64  `json:"cost"`
+	Profit         float64  `json:"profit"`
+	Margin         *float64 `json:"margin,omitempty"`           // Profit as a percentage of sales; nil without costed sales
+	BreakEvenPrice *float64 `json:"break_even_price,omitempty"` // Sale price at which the average item's proceeds cover its cost
+}
+
+// ProfitabilityReport lists the profit per group, most profitable first
+type ProfitabilityReport struct {
+	GroupBy string          `json:"group_by"`
+	Groups  []ProfitSummary `json:"groups"`
+	Total   ProfitSummary   `json:"total"`
+}
+
+// CalculateProfit sets the profit, margin and break-even price from the
+// summary's sales, proceeds and cost
+func (p *ProfitSummary) CalculateProfit() {
+	p.Profit = math.Round((p.Proceeds-p.Cost)*100) / 100
+	p.Margin = nil
+	p.BreakEvenPrice = nil
+	if p.Sales <= 0 {
+		return
+	}
+
+	margin := p.Profit / p.Sales * 100
+	p.Margin = &margin
+
+	// The share of the sale price the seller keeps is the same at any price
+	// when commission is a flat rate
+	if kept := p.Proceeds / p.Sales; kept > 0 && p.CostedItems > 0 {
+		price := math.Round(p.Cost/float64(p.CostedItems)/kept*100) / 100
+		p.BreakEvenPrice = &price
+	}
+}
+
+// Add adds the items and amounts of other to the summary
+func (p *ProfitSummary) Add(other ProfitSummary) {
+	p.CostedItems += other.CostedItems
+	p.UncostedItems += other.UncostedItems
+	p.Sales = math.Round((p.Sales+other.Sales)*100) / 100
+	p.Proceeds = math.Round((p.Proceeds+other.Proceeds)*100) / 100
+	p.Cost = math.Round((p.Cost+other.Cost)*100) / 100
+}
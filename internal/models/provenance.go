@@ -0,0 +1,20 @@
+package models
+
+// SourceRow is the row of an import's source a record was parsed from, as
+// read before any parsing
+type SourceRow struct {
+	Table   int      `json:"table,omitempty"` // 1-based table of a multi-table import
+	Row     int      `json:"row"`             // 1-based row of the table, counting header rows
+	Headers []string `json:"headers"`         // Headers of the row's table
+	Cells   []string `json:"cells"`
+}
+
+// RecordProvenance traces a sales record back to where its numbers came from
+type RecordProvenance struct {
+	Record      SalesRecord       `json:"record"`
+	Import      *ImportRun        `json:"import,omitempty"`     // Import that last wrote the record; nil for records entered by hand
+	SourceRow   *SourceRow        `json:"source_row,omitempty"` // Row of the import's source the record was parsed from
+	HasSource   bool              `json:"has_source"`           // Whether the import's raw content is kept, for GetImportSource
+	Adjustments []SalesAdjustment `json:"adjustments"`
+	History     []AuditEntry      `json:"history"` // Changes made to the record since, oldest first
+}
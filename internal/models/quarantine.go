@@ -0,0 +1,33 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// QuarantinedRow is an imported row that parsed but failed a business-rule
+// check, set aside for review instead of being imported or rejected
+type QuarantinedRow struct {
+	ID          int64                    `json:"id" db:"id"`
+	ImportRunID *int64                   `json:"import_run_id,omitempty" db:"import_run_id"` // The import the row was read in, nil once its history is removed
+	Record      CreateSalesRecordRequest `json:"record" db:"record"`
+	Reasons     []string                 `json:"reasons" db:"reasons"` // Why the row was quarantined
+	CreatedAt   time.Time                `json:"created_at" db:"created_at"`
+}
+
+// CheckBusinessRules returns why a record that parsed still looks wrong: a
+// sale dated after today, or a commission or remaining amount above the sale
+// price. It returns nil when the record passes every check.
+func CheckBusinessRules(record CreateSalesRecordRequest, today time.Time) []string {
+	var reasons []string
+	if record.Date > today.Format("2006-01-02") {
+		reasons = append(reasons, fmt.Sprintf("date %s is in the future", record.Date))
+	}
+	if record.Commission != nil && *record.Commission > record.SalePrice {
+		reasons = append(reasons, fmt.Sprintf("commission %.2f is more than the sale price %.2f", *record.Commission, record.SalePrice))
+	}
+	if record.Remaining != nil && *record.Remaining > record.SalePrice {
+		reasons = append(reasons, fmt.Sprintf("remaining %.2f is more than the sale price %.2f", *record.Remaining, record.SalePrice))
+	}
+	return reasons
+}
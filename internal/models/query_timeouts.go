@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// MinQueryTimeout is the shortest query timeout that can be saved, other than
+// zero for no timeout
+const MinQueryTimeout = time.Second
+
+// QueryTimeouts are how long a query may run in the database, by the class of
+// operation it belongs to, before it is stopped with a timeout error. A zero
+// timeout lets queries of its class run as long as they need. Only the time a
+// query spends in the database counts, not the time taken to process its rows,
+// so long exports are not stopped while they write their files.
+type QueryTimeouts struct {
+	Read        Duration `json:"read"`        // Reports, lists and other queries that only read
+	Write       Duration `json:"write"`       // Imports, edits and deletes
+	Maintenance Duration `json:"maintenance"` // Compaction, integrity checks and checkpoints
+}
+
+// DefaultQueryTimeouts returns the timeouts used until others are saved
+func DefaultQueryTimeouts() QueryTimeouts {
+	return QueryTimeouts{
+		Read:  Duration(30 * time.Second),
+		Write: Duration(5 * time.Minute),
+	}
+}
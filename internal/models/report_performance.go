@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Limits of the report performance settings
+const (
+	MinPerformanceThreshold  = 10000
+	MinPerformanceSampleSize = 1000
+)
+
+// ReportPerformanceConfig decides when the heavy reports, the pivot table,
+// store and vendor performance and top products, switch to performance mode
+// to stay interactive on huge datasets. In performance mode the pivot and
+// performance reports are calculated from sales pre-aggregated by month, store
+// and vendor and by day, which gives the same figures, and top products are
+// estimated from a sample of the records.
+type ReportPerformanceConfig struct {
+	Enabled    bool  `json:"enabled"`
+	Threshold  int64 `json:"threshold"`   // Records above which performance mode is used
+	SampleSize int64 `json:"sample_size"` // Records the estimated reports read, about
+}
+
+// DefaultReportPerformanceConfig returns the settings used until others are
+// saved: performance mode past a million records, estimating from about
+// 100,000
+func DefaultReportPerformanceConfig() ReportPerformanceConfig {
+	return ReportPerformanceConfig{
+		Enabled:    true,
+		Threshold:  1000000,
+		SampleSize: 100000,
+	}
+}
+
+// ReportPerformanceStatus tells whether the heavy reports are in performance
+// mode and how fresh the pre-aggregated sales are
+type ReportPerformanceStatus struct {
+	Config            ReportPerformanceConfig `json:"config"`
+	RecordCount       int64                   `json:"record_count"`
+	Active            bool                    `json:"active"`
+	AggregatesBuiltAt *time.Time              `json:"aggregates_built_at,omitempty"` // nil until built, and once records changed since
+}
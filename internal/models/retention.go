@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// RetentionPolicy controls how long deleted, old and historical data is kept.
+// A value of 0 disables the corresponding rule.
+type RetentionPolicy struct {
+	Enabled                     bool `json:"enabled"`                         // Apply automatically from the maintenance scheduler
+	PurgeDeletedAfterDays       int  `json:"purge_deleted_after_days"`        // Permanently remove deleted records after this many days
+	ArchiveAfterYears           int  `json:"archive_after_years"`             // Move sales older than this out of reports into the archive
+	PurgeImportHistoryAfterDays int  `json:"purge_import_history_after_days"` // Remove import summaries older than this
+}
+
+// DefaultRetentionPolicy returns the policy used until one is saved. It is
+// disabled, so nothing is removed until the user opts in.
+func DefaultRetentionPolicy() RetentionPolicy {
+	return RetentionPolicy{
+		PurgeDeletedAfterDays: 90,
+		ArchiveAfterYears:     7,
+	}
+}
+
+// RetentionResult reports what a retention run changed, or would change for a preview
+type RetentionResult struct {
+	DryRun               bool      `json:"dry_run"`
+	RanAt                time.Time `json:"ran_at"`
+	ArchivedRecords      int64     `json:"archived_records"`
+	ArchivedAdjustments  int64     `json:"archived_adjustments"`
+	PurgedDeletedRecords int64     `json:"purged_deleted_records"`
+	PurgedImportRuns     int64     `json:"purged_import_runs"`
+}
+
+// Changed reports whether the run affected any rows
+func (r RetentionResult) Changed() bool {
+	return r.ArchivedRecords > 0 || r.ArchivedAdjustments > 0 || r.PurgedDeletedRecords > 0 || r.PurgedImportRuns > 0
+}
@@ -12,11 +12,19 @@ type SalesRecord struct {
 	ID          int64     `json:"id" db:"id"`
 	Store       string    `json:"store" db:"store"`
 	Vendor      string    `json:"vendor" db:"vendor"`
-	Date        time.Time `json:"date" db:"date"`
+	Date        Date      `json:"date" db:"date"`
 	Description string    `json:"description" db:"description"`
+	ProductKey  string    `json:"product_key" db:"product_key"` // Normalized description for grouping
 	SalePrice   float64   `json:"sale_price" db:"sale_price"`
-	Commission  float64   `json:"commission" db:"commission"`
-	Remaining   float64   `json:"remaining" db:"remaining"`
+	Commission  *float64  `json:"commission" db:"commission"` // nil when the source did not report it
+	Remaining   *float64  `json:"remaining" db:"remaining"`   // nil when the source did not report it
+	IsReturn    bool      `json:"is_return" db:"is_return"`   // Amounts are positive; reports subtract returns
+	Currency    *string   `json:"currency,omitempty" db:"currency"` // ISO 4217 code; nil means the base currency
+	ExternalID  *string   `json:"external_id,omitempty" db:"external_id"` // Transaction/line id from the source report
+	Cost        *float64  `json:"cost,omitempty" db:"cost"`         // What the seller paid for the item; nil when unknown
+	Category    *string   `json:"category,omitempty" db:"category"` // Grouping for profitability reports, such as "Furniture"
+	Tags        Tags      `json:"tags,omitempty" db:"tags"`         // Free-form labels, sorted; imports never change them
+	Metadata    Metadata  `json:"metadata,omitempty" db:"metadata"` // Custom fields, such as report columns no field was mapped to
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -66,13 +74,34 @@ func (nt NullTime) Value() (driver.Value, error) {
 // CreateSalesRecordRequest represents the data needed to create a new sales record
 // Used for API requests and data import operations
 type CreateSalesRecordRequest struct {
-	Store       string  `json:"store" validate:"required,min=1,max=100"`
-	Vendor      string  `json:"vendor" validate:"required,min=1,max=100"`
-	Date        string  `json:"date" validate:"required"` // Date as string for parsing
-	Description string  `json:"description" validate:"required,min=1"`
-	SalePrice   float64 `json:"sale_price" validate:"required,min=0"`
-	Commission  float64 `json:"commission" validate:"min=0"`
-	Remaining   float64 `json:"remaining" validate:"min=0"`
+	Store       string     `json:"store" validate:"required,min=1,max=100"`
+	Vendor      string     `json:"vendor" validate:"required,min=1,max=100"`
+	Date        string     `json:"date" validate:"required"` // Date as string for parsing
+	Description string     `json:"description" validate:"required,min=1"`
+	SalePrice   float64    `json:"sale_price" validate:"required,min=0"`
+	Commission  *float64   `json:"commission,omitempty" validate:"omitempty,min=0"` // nil means unknown, not zero
+	Remaining   *float64   `json:"remaining,omitempty" validate:"omitempty,min=0"`  // nil means unknown, not zero
+	IsReturn    bool       `json:"is_return,omitempty"`                             // A return or refund, with positive amounts
+	Currency    *string    `json:"currency,omitempty"`                              // ISO 4217 code; nil means the base currency
+	ExternalID  *string    `json:"external_id,omitempty"`                           // Re-importing the same store and id updates the existing record
+	Cost        *float64   `json:"cost,omitempty" validate:"omitempty,min=0"`       // What the seller paid; nil keeps a re-imported record's cost
+	Category    *string    `json:"category,omitempty"`                              // nil keeps a re-imported record's category
+	Metadata    Metadata   `json:"metadata,omitempty"`                              // Custom fields, keyed by name
+	SourceRow   *SourceRow `json:"source_row,omitempty"`                            // Row of the import's source the record was parsed from
+}
+
+// BatchRowError describes a record that could not be inserted during a partial batch
+type BatchRowError struct {
+	Index  int                      `json:"index"` // Position of the record in the submitted batch
+	Record CreateSalesRecordRequest `json:"record"`
+	Reason string                   `json:"reason"`
+}
+
+// UpsertResult summarizes an upsert of records keyed on their external id
+type UpsertResult struct {
+	Inserted  int `json:"inserted"`  // New records, including those without an external id
+	Updated   int `json:"updated"`   // Existing records whose fields changed
+	Unchanged int `json:"unchanged"` // Existing records that already matched
 }
 
 // UpdateSalesRecordRequest represents the data that can be updated for a sales record
@@ -84,20 +113,33 @@ type UpdateSalesRecordRequest struct {
 	SalePrice   *float64 `json:"sale_price,omitempty" validate:"omitempty,min=0"`
 	Commission  *float64 `json:"commission,omitempty" validate:"omitempty,min=0"`
 	Remaining   *float64 `json:"remaining,omitempty" validate:"omitempty,min=0"`
+	IsReturn    *bool    `json:"is_return,omitempty"`
+	Cost        *float64 `json:"cost,omitempty" validate:"omitempty,min=0"`
+	Category    *string  `json:"category,omitempty"` // "" clears the category
+	Tags        Tags     `json:"tags,omitempty"`     // Replaces all tags; [] clears them
+	Metadata    Metadata `json:"metadata,omitempty"` // Replaces all custom fields; {} clears them
 }
 
 // SalesRecordFilter represents filtering options for querying sales records
 type SalesRecordFilter struct {
-	Store     *string    `json:"store,omitempty"`
-	Vendor    *string    `json:"vendor,omitempty"`
-	DateFrom  *time.Time `json:"date_from,omitempty"`
-	DateTo    *time.Time `json:"date_to,omitempty"`
-	MinPrice  *float64   `json:"min_price,omitempty"`
-	MaxPrice  *float64   `json:"max_price,omitempty"`
-	Limit     *int       `json:"limit,omitempty"`
-	Offset    *int       `json:"offset,omitempty"`
-	SortBy    *string    `json:"sort_by,omitempty"`    // date, store, vendor, sale_price
-	SortOrder *string    `json:"sort_order,omitempty"` // asc, desc
+	Store     *string           `json:"store,omitempty"`
+	Vendor    *string           `json:"vendor,omitempty"`
+	Search    *string           `json:"search,omitempty"`     // Text the description contains, ignoring case
+	StoreIn   []string          `json:"store_in,omitempty"`   // Match any of these stores
+	VendorIn  []string          `json:"vendor_in,omitempty"`  // Match any of these vendors
+	NotStore  []string          `json:"not_store,omitempty"`  // Exclude these stores
+	NotVendor []string          `json:"not_vendor,omitempty"` // Exclude these vendors
+	DateFrom  *time.Time        `json:"date_from,omitempty"`
+	DateTo    *time.Time        `json:"date_to,omitempty"`
+	MinPrice  *float64          `json:"min_price,omitempty"`
+	MaxPrice  *float64          `json:"max_price,omitempty"`
+	Category  *string           `json:"category,omitempty"`
+	Tag       *string           `json:"tag,omitempty"`      // Records carrying this tag
+	Metadata  map[string]string `json:"metadata,omitempty"` // Custom fields that must have these values
+	Limit     *int              `json:"limit,omitempty"`
+	Offset    *int              `json:"offset,omitempty"`
+	SortBy    *string           `json:"sort_by,omitempty"`    // date, store, vendor, sale_price
+	SortOrder *string           `json:"sort_order,omitempty"` // asc, desc
 }
 
 // SalesRecordList represents a paginated list of sales records
@@ -112,49 +154,89 @@ type SalesRecordList struct {
 // SalesSummary represents aggregated sales data
 type SalesSummary struct {
 	Period        string  `json:"period"`         // Year, Month, or Date
-	ItemsSold     int64   `json:"items_sold"`     // Count of records
-	TotalSales    float64 `json:"total_sales"`    // Sum of sale_price
-	TotalCommission float64 `json:"total_commission"` // Sum of commission
-	TotalRemaining  float64 `json:"total_remaining"`  // Sum of remaining
+	ItemsSold     int64   `json:"items_sold"`     // Count of sales, excluding returns
+	ReturnedItems int64   `json:"returned_items"` // Count of returns
+	GrossSales    float64 `json:"gross_sales"`    // Sum of sale_price over sales
+	TotalReturns  float64 `json:"total_returns"`  // Sum of sale_price over returns
+	TotalSales    float64 `json:"total_sales"`    // Net sales: gross sales minus returns
+	TotalCommission float64 `json:"total_commission"` // Net commission (unknown values ignored)
+	TotalRemaining  float64 `json:"total_remaining"`  // Net remaining (unknown values ignored)
+	CommissionRate  float64 `json:"commission_rate"`  // Commission / sales over rows with known commission
 	UniqueStores    int64   `json:"unique_stores"`    // Count of distinct stores
 	UniqueVendors   int64   `json:"unique_vendors"`   // Count of distinct vendors
 }
 
+// CustomSummaryRequest selects the records of a custom summary, how they are
+// grouped and which groups are reported, such as the vendors with over $1,000
+// of sales in March
+type CustomSummaryRequest struct {
+	GroupBy       string            `json:"group_by"`                  // year, month, week, day, store or vendor
+	Year          *string           `json:"year,omitempty"`            // Records of this year, such as "2024"
+	Filter        SalesRecordFilter `json:"filter"`                    // Records to summarize; limit, offset and sorting are ignored
+	MinTotalSales *float64          `json:"min_total_sales,omitempty"` // Only groups with at least these net sales
+	MinItemsSold  *int64            `json:"min_items_sold,omitempty"`  // Only groups with at least this many sales
+	DateBasis     string            `json:"date_basis,omitempty"`      // DateBasisImport groups, and filters by year and dates, on when records were imported
+}
+
 // YearlySummary represents yearly aggregated data
 type YearlySummary struct {
 	Year            string  `json:"year"`
-	ItemsSold       int64   `json:"items_sold"`
-	TotalSales      float64 `json:"total_sales"`
-	TotalCommission float64 `json:"total_commission"`
-	TotalRemaining  float64 `json:"total_remaining"`
+	ItemsSold       int64   `json:"items_sold"`     // Sales only; returns are counted in ReturnedItems
+	ReturnedItems   int64   `json:"returned_items"`
+	GrossSales      float64 `json:"gross_sales"`
+	TotalReturns    float64 `json:"total_returns"`
+	TotalSales      float64 `json:"total_sales"`      // Net sales: gross sales minus returns
+	TotalCommission float64 `json:"total_commission"` // Net of returns
+	TotalRemaining  float64 `json:"total_remaining"`  // Net of returns
+	CommissionRate  float64 `json:"commission_rate"`        // Commission / sales over rows with known commission
+	CommissionKnown int64   `json:"commission_known_items"` // Rows whose commission was reported
 	UniqueStores    int64   `json:"unique_stores"`
 	UniqueVendors   int64   `json:"unique_vendors"`
 }
 
+// Summary dimensions a monthly summary can be split by
+const (
+	DimensionStore  = "store"
+	DimensionVendor = "vendor"
+)
+
 // MonthlySummary represents monthly aggregated data
 type MonthlySummary struct {
 	Year            string  `json:"year"`
 	Month           string  `json:"month"`
 	YearMonth       string  `json:"year_month"`
+	Dimension       string  `json:"dimension,omitempty"` // The store or vendor, when split by one, or the business in a consolidated report
 	ItemsSold       int64   `json:"items_sold"`
+	ReturnedItems   int64   `json:"returned_items"`
+	GrossSales      float64 `json:"gross_sales"`
+	TotalReturns    float64 `json:"total_returns"`
 	TotalSales      float64 `json:"total_sales"`
 	TotalCommission float64 `json:"total_commission"`
 	TotalRemaining  float64 `json:"total_remaining"`
+	CommissionRate  float64 `json:"commission_rate"`
+	CommissionKnown int64   `json:"commission_known_items"`
 	UniqueStores    int64   `json:"unique_stores"`
 	UniqueVendors   int64   `json:"unique_vendors"`
+	Closed          bool    `json:"closed,omitempty"`  // The month is closed and its records read-only
+	Amended         bool    `json:"amended,omitempty"` // The month was reopened and its records changed since it was closed
 }
 
 // DailySummary represents daily aggregated data
 type DailySummary struct {
-	Date            time.Time `json:"date"`
+	Date            Date      `json:"date"`
 	Year            string    `json:"year"`
 	Month           string    `json:"month"`
 	Day             string    `json:"day"`
 	YearMonth       string    `json:"year_month"`
 	ItemsSold       int64     `json:"items_sold"`
+	ReturnedItems   int64     `json:"returned_items"`
+	GrossSales      float64   `json:"gross_sales"`
+	TotalReturns    float64   `json:"total_returns"`
 	TotalSales      float64   `json:"total_sales"`
 	TotalCommission float64   `json:"total_commission"`
 	TotalRemaining  float64   `json:"total_remaining"`
+	CommissionRate  float64   `json:"commission_rate"`
+	CommissionKnown int64     `json:"commission_known_items"`
 	UniqueStores    int64     `json:"unique_stores"`
 	UniqueVendors   int64     `json:"unique_vendors"`
 }
@@ -162,36 +244,60 @@ type DailySummary struct {
 // StorePerformance represents store-based analytics
 type StorePerformance struct {
 	Store           string    `json:"store"`
-	TotalItems      int64     `json:"total_items"`
-	TotalSales      float64   `json:"total_sales"`
+	TotalItems      int64     `json:"total_items"` // Sales only
+	ReturnedItems   int64     `json:"returned_items"`
+	GrossSales      float64   `json:"gross_sales"`
+	TotalReturns    float64   `json:"total_returns"`
+	TotalSales      float64   `json:"total_sales"` // Net of returns
 	TotalCommission float64   `json:"total_commission"`
 	TotalRemaining  float64   `json:"total_remaining"`
-	AvgSalePrice    float64   `json:"avg_sale_price"`
-	FirstSaleDate   time.Time `json:"first_sale_date"`
-	LastSaleDate    time.Time `json:"last_sale_date"`
+	AvgSalePrice    float64   `json:"avg_sale_price"` // Over sales only
+	CommissionRate  float64   `json:"commission_rate"`
+	CommissionKnown int64     `json:"commission_known_items"`
+	FirstSaleDate   Date      `json:"first_sale_date"`
+	LastSaleDate    Date      `json:"last_sale_date"`
 	UniqueVendors   int64     `json:"unique_vendors"`
 }
 
 // VendorPerformance represents vendor-based analytics
 type VendorPerformance struct {
 	Vendor          string    `json:"vendor"`
-	TotalItems      int64     `json:"total_items"`
-	TotalSales      float64   `json:"total_sales"`
+	TotalItems      int64     `json:"total_items"` // Sales only
+	ReturnedItems   int64     `json:"returned_items"`
+	GrossSales      float64   `json:"gross_sales"`
+	TotalReturns    float64   `json:"total_returns"`
+	TotalSales      float64   `json:"total_sales"` // Net of returns
 	TotalCommission float64   `json:"total_commission"`
 	TotalRemaining  float64   `json:"total_remaining"`
-	AvgSalePrice    float64   `json:"avg_sale_price"`
-	FirstSaleDate   time.Time `json:"first_sale_date"`
-	LastSaleDate    time.Time `json:"last_sale_date"`
+	AvgSalePrice    float64   `json:"avg_sale_price"` // Over sales only
+	CommissionRate  float64   `json:"commission_rate"`
+	CommissionKnown int64     `json:"commission_known_items"`
+	FirstSaleDate   Date      `json:"first_sale_date"`
+	LastSaleDate    Date      `json:"last_sale_date"`
 	UniqueStores    int64     `json:"unique_stores"`
 }
 
+// ProductSummary represents sales aggregated by canonical product key
+type ProductSummary struct {
+	ProductKey    string  `json:"product_key"`
+	Description   string  `json:"description"` // Representative description for display
+	Variants      int64   `json:"variants"`    // Distinct descriptions sharing the key
+	ItemsSold     int64   `json:"items_sold"`  // Sales only
+	ReturnedItems int64   `json:"returned_items"`
+	TotalSales    float64 `json:"total_sales"`    // Net of returns
+	AvgSalePrice  float64 `json:"avg_sale_price"` // Over sales only
+	Estimated     bool    `json:"estimated,omitempty"` // Scaled up from a sample of the records in report performance mode
+}
+
 // DatabaseStats represents overall database statistics
 type DatabaseStats struct {
-	TotalRecords    int64     `json:"total_records"`
-	EarliestDate    time.Time `json:"earliest_date"`
-	LatestDate      time.Time `json:"latest_date"`
-	TotalSales      float64   `json:"total_sales"`
-	AvgSalePrice    float64   `json:"avg_sale_price"`
+	TotalRecords    int64     `json:"total_records"` // Sales and returns
+	EarliestDate    Date      `json:"earliest_date"`
+	LatestDate      Date      `json:"latest_date"`
+	GrossSales      float64   `json:"gross_sales"`
+	TotalReturns    float64   `json:"total_returns"`
+	TotalSales      float64   `json:"total_sales"`    // Net of returns
+	AvgSalePrice    float64   `json:"avg_sale_price"` // Over sales only
 	UniqueStores    int64     `json:"unique_stores"`
 	UniqueVendors   int64     `json:"unique_vendors"`
 	LastUpdated     time.Time `json:"last_updated"`
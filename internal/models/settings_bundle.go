@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// SettingsBundleVersion is the version of the settings file written by this
+// build. Files of a newer version are refused.
+const SettingsBundleVersion = 1
+
+// SettingsBundle holds the settings and rules a user sets up by hand, saved
+// to a file to set up a second computer or restore them after a reinstall.
+// Sales data and the app lock are not included.
+type SettingsBundle struct {
+	Version         int                           `json:"version"`
+	ExportedAt      time.Time                     `json:"exported_at"`
+	ExportFormat    *ExportFormat                 `json:"export_format,omitempty"`    // nil if never saved
+	RetentionPolicy *RetentionPolicy              `json:"retention_policy,omitempty"` // nil if never saved
+	WeekStart       *string                       `json:"week_start,omitempty"`       // nil if never saved
+	IgnoreRules     []IgnoreRule                  `json:"ignore_rules,omitempty"`     // Replace the saved rules when present
+	Aliases         []SaveNameAliasRequest        `json:"aliases,omitempty"`
+	CommissionRules []CreateCommissionRuleRequest `json:"commission_rules,omitempty"`
+	PayoutSchedules []SavePayoutScheduleRequest   `json:"payout_schedules,omitempty"`
+}
+
+// SettingsImportResult counts what importing a settings bundle saved
+type SettingsImportResult struct {
+	ExportFormat    bool `json:"export_format"`
+	RetentionPolicy bool `json:"retention_policy"`
+	WeekStart       bool `json:"week_start"`
+	IgnoreRules     int  `json:"ignore_rules"`
+	Aliases         int  `json:"aliases"`
+	CommissionRules int  `json:"commission_rules"`
+	PayoutSchedules int  `json:"payout_schedules"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// ReportSnapshot is the state of the records a report covered when it was
+// taken, kept to compare against the numbers as they are later
+type ReportSnapshot struct {
+	ID        int64             `json:"id" db:"id"`
+	Name      string            `json:"name" db:"name"`
+	Filter    SalesRecordFilter `json:"filter" db:"filter"` // Records the report covered; limit, offset and sorting are ignored
+	Totals    ReportTotals      `json:"totals" db:"totals"` // Totals of the records when the snapshot was taken
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+}
+
+// CreateReportSnapshotRequest represents a snapshot to take
+type CreateReportSnapshotRequest struct {
+	Name   string            `json:"name" validate:"required,max=200"`
+	Filter SalesRecordFilter `json:"filter"`
+}
+
+// ReportTotals are the totals of a set of records, net of returns
+type ReportTotals struct {
+	Records         int64   `json:"records"`
+	ItemsSold       int64   `json:"items_sold"` // Sales only; returns are counted in ReturnedItems
+	ReturnedItems   int64   `json:"returned_items"`
+	TotalSales      float64 `json:"total_sales"`
+	TotalCommission float64 `json:"total_commission"` // Unknown commissions are left out
+	TotalRemaining  float64 `json:"total_remaining"`  // Unknown remaining amounts are left out
+}
+
+// Add adds a record to the totals
+func (t *ReportTotals) Add(record SalesRecord) {
+	sign := 1.0
+	if record.IsReturn {
+		sign = -1
+		t.ReturnedItems++
+	} else {
+		t.ItemsSold++
+	}
+	t.Records++
+	t.TotalSales += sign * record.SalePrice
+	if record.Commission != nil {
+		t.TotalCommission += sign * *record.Commission
+	}
+	if record.Remaining != nil {
+		t.TotalRemaining += sign * *record.Remaining
+	}
+}
+
+// SnapshotComparison lists how the records covered by a snapshot's report
+// differ now from when the snapshot was taken
+type SnapshotComparison struct {
+	Snapshot ReportSnapshot   `json:"snapshot"`
+	Current  ReportTotals     `json:"current"` // Totals of the records the report covers now
+	Added    []SalesRecord    `json:"added"`   // Records the report covers now but did not then
+	Removed  []SalesRecord    `json:"removed"` // Records the report covered then, as they were, but does not now
+	Changed  []SnapshotChange `json:"changed"`
+}
+
+// SnapshotChange is a record that changed since a snapshot was taken
+type SnapshotChange struct {
+	Before  SalesRecord `json:"before"`
+	After   SalesRecord `json:"after"`
+	Changes string      `json:"changes"` // The fields that changed, such as "sale price 20.00 → 18.00"
+}
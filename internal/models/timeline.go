@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Types of timeline entries
+const (
+	TimelineSale       = "sale"
+	TimelineReturn     = "return"
+	TimelineAdjustment = "adjustment"
+	TimelinePayout     = "payout" // Expected from the store's payout schedule
+)
+
+// TimelineFilter selects the store or vendor a timeline is for and the dates
+// it covers
+type TimelineFilter struct {
+	Kind     string     `json:"kind"` // AliasKindStore or AliasKindVendor
+	Name     string     `json:"name"`
+	DateFrom *time.Time `json:"date_from,omitempty"`
+	DateTo   *time.Time `json:"date_to,omitempty"`
+}
+
+// TimelineEntry is one event on a store's or vendor's timeline
+type TimelineEntry struct {
+	Date          Date     `json:"date"`
+	Type          string   `json:"type"` // One of the Timeline* entry types
+	Store         string   `json:"store"`
+	Vendor        string   `json:"vendor,omitempty"`          // Empty for payouts on a store's timeline
+	Description   string   `json:"description"`               // The sale's description, the adjustment's reason or the month paid out
+	Amount        float64  `json:"amount"`                    // Sale price, negative for returns; sale price change; or payout
+	Currency      *string  `json:"currency,omitempty"`        // Nil means the base currency
+	SalesRecordID *int64   `json:"sales_record_id,omitempty"` // The sale, or the sale adjusted
+	AdjustmentID  *int64   `json:"adjustment_id,omitempty"`
+	Month         string   `json:"month,omitempty"`     // YYYY-MM of the sales a payout covers
+	Remaining     *float64 `json:"remaining,omitempty"` // For sales and returns, when reported
+}
+
+// Timeline is a store's or vendor's sales, adjustments and expected payouts
+// merged into one list, oldest first. Entries on the same day keep the order
+// sales, adjustments, payouts.
+type Timeline struct {
+	Kind    string          `json:"kind"`
+	Name    string          `json:"name"`
+	Entries []TimelineEntry `json:"entries"`
+}
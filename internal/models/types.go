@@ -0,0 +1,229 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DateLayout is the canonical date-only format used in JSON and the database
+const DateLayout = "2006-01-02"
+
+// Date is a calendar date without a time-of-day component.
+// It serializes as "2006-01-02" in JSON so the frontend doesn't have to slice
+// RFC3339 timestamps, and an unset date serializes as null.
+type Date struct {
+	time.Time
+}
+
+// NewDate creates a Date from a time, discarding the time-of-day
+func NewDate(t time.Time) Date {
+	return Date{Time: time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)}
+}
+
+// ParseDate parses a "2006-01-02" string into a Date
+func ParseDate(value string) (Date, error) {
+	t, err := time.Parse(DateLayout, value)
+	if err != nil {
+		return Date{}, err
+	}
+	return Date{Time: t}, nil
+}
+
+// String returns the date in "2006-01-02" format, or an empty string if unset
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return d.Format(DateLayout)
+}
+
+// MarshalJSON implements json.Marshaler
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(d.Format(DateLayout))
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting date-only and RFC3339 strings
+func (d *Date) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		d.Time = time.Time{}
+		return nil
+	}
+
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("date must be a string: %w", err)
+	}
+	if value == "" {
+		d.Time = time.Time{}
+		return nil
+	}
+
+	if t, err := time.Parse(DateLayout, value); err == nil {
+		d.Time = t
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return fmt.Errorf("cannot parse date %q", value)
+	}
+	*d = NewDate(t)
+	return nil
+}
+
+// Scan implements the Scanner interface for database/sql
+func (d *Date) Scan(value interface{}) error {
+	var nt NullTime
+	if err := nt.Scan(value); err != nil {
+		return err
+	}
+	d.Time = nt.Time
+	return nil
+}
+
+// Value implements the driver Valuer interface
+func (d Date) Value() (driver.Value, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	return d.Time, nil
+}
+
+// Duration is a time.Duration that serializes as a human-readable string
+// ("1.52ms") rather than a raw count of nanoseconds
+type Duration time.Duration
+
+// String returns the duration formatted by time.Duration
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// MarshalJSON implements json.Marshaler
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting a duration string or nanoseconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "\"") {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("cannot parse duration %q: %w", value, err)
+		}
+		*d = Duration(parsed)
+		return nil
+	}
+
+	var nanos int64
+	if err := json.Unmarshal(data, &nanos); err != nil {
+		return fmt.Errorf("duration must be a string or integer: %w", err)
+	}
+	*d = Duration(nanos)
+	return nil
+}
+
+// Metadata holds a record's custom fields, such as an "Item #" column of an
+// import that no field was mapped to. It is stored as a JSON object, and an
+// empty set is stored as NULL.
+type Metadata map[string]string
+
+// Scan implements the Scanner interface for database/sql
+func (m *Metadata) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("cannot scan %T into Metadata", value)
+	}
+
+	fields := Metadata{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("cannot parse metadata: %w", err)
+	}
+	*m = fields
+	return nil
+}
+
+// Value implements the driver Valuer interface
+func (m Metadata) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(map[string]string(m))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Equal reports whether two sets of custom fields hold the same values,
+// treating nil and empty as equal
+func (m Metadata) Equal(other Metadata) bool {
+	if len(m) != len(other) {
+		return false
+	}
+	for key, value := range m {
+		if otherValue, ok := other[key]; !ok || otherValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Tags are free-form labels on a record, such as "estate sale". They are
+// stored as a JSON array, and an empty set is stored as NULL.
+type Tags []string
+
+// Scan implements the Scanner interface for database/sql
+func (t *Tags) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("cannot scan %T into Tags", value)
+	}
+
+	var tags []string
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return fmt.Errorf("cannot parse tags: %w", err)
+	}
+	if len(tags) == 0 {
+		tags = nil
+	}
+	*t = tags
+	return nil
+}
+
+// Value implements the driver Valuer interface
+func (t Tags) Value() (driver.Value, error) {
+	if len(t) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal([]string(t))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
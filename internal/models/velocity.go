@@ -0,0 +1,56 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// DefaultDormantWeeks is how many weeks without a sale mark a vendor as
+// dormant when the filter does not say
+const DefaultDormantWeeks = 8
+
+// VelocityFilter selects the sales a velocity report covers
+type VelocityFilter struct {
+	Store        *string `json:"store,omitempty"`
+	Weeks        int     `json:"weeks,omitempty"`         // Calendar weeks the average covers, ending with the week of the report date; 0 covers each vendor's whole history
+	DormantWeeks int     `json:"dormant_weeks,omitempty"` // Weeks without a sale after which a vendor is dormant; defaults to DefaultDormantWeeks
+}
+
+// VendorVelocity is how quickly a vendor's items sell. Returns are not
+// counted as items sold.
+type VendorVelocity struct {
+	Vendor             string  `json:"vendor"`
+	ItemsSold          int64   `json:"items_sold"`            // In the weeks averaged over
+	Weeks              float64 `json:"weeks"`                 // Averaged over: from the vendor's first sale, or the start of the filter's weeks if later
+	ItemsPerWeek       float64 `json:"items_per_week"`        // Rounded to hundredths
+	FirstSaleDate      Date    `json:"first_sale_date"`       // Ever
+	LastSaleDate       Date    `json:"last_sale_date"`        // Ever
+	WeeksSinceLastSale int     `json:"weeks_since_last_sale"` // Whole weeks
+	Dormant            bool    `json:"dormant"`               // No sale for at least the dormant weeks
+}
+
+// VelocityReport lists the vendors' sales velocity, dormant vendors first
+// and then the slowest sellers, so the inventory to return comes first
+type VelocityReport struct {
+	AsOf           Date             `json:"as_of"`
+	Weeks          int              `json:"weeks"`
+	DormantWeeks   int              `json:"dormant_weeks"`
+	Vendors        []VendorVelocity `json:"vendors"`
+	DormantVendors int              `json:"dormant_vendors"`
+}
+
+// Calculate sets the weeks averaged over, the items sold per week and the
+// weeks since the last sale as of asOf, counting from windowStart or the
+// first sale, whichever is later. The average covers at least one week.
+func (v *VendorVelocity) Calculate(asOf, windowStart time.Time, dormantWeeks int) {
+	start := v.FirstSaleDate.Time
+	if windowStart.After(start) {
+		start = windowStart
+	}
+	days := asOf.Sub(start).Hours()/24 + 1
+	v.Weeks = math.Max(math.Round(days/7*10)/10, 1)
+	v.ItemsPerWeek = math.Round(float64(v.ItemsSold)/v.Weeks*100) / 100
+
+	v.WeeksSinceLastSale = int(asOf.Sub(v.LastSaleDate.Time).Hours()/24) / 7
+	v.Dormant = v.WeeksSinceLastSale >= dormantWeeks
+}
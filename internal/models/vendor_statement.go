@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// VendorStatementFilter selects the vendor and period of a vendor statement
+type VendorStatementFilter struct {
+	Vendor   string     `json:"vendor"`
+	DateFrom *time.Time `json:"date_from,omitempty"`
+	DateTo   *time.Time `json:"date_to,omitempty"`
+}
+
+// VendorStatementLine is a sale as shown to its vendor. It leaves out the
+// seller's own fields, such as cost, tags and custom fields.
+type VendorStatementLine struct {
+	Date        Date     `json:"date"`
+	Store       string   `json:"store"`
+	Description string   `json:"description"`
+	IsReturn    bool     `json:"is_return"`
+	SalePrice   float64  `json:"sale_price"`
+	Commission  *float64 `json:"commission,omitempty"`
+	Remaining   *float64 `json:"remaining,omitempty"`
+	Currency    *string  `json:"currency,omitempty"`
+}
+
+// VendorSettlement is what a store's sales in a month came to for the
+// vendor, net of returns
+type VendorSettlement struct {
+	Store           string  `json:"store"`
+	Month           string  `json:"month"` // YYYY-MM
+	ItemsSold       int64   `json:"items_sold"`
+	ReturnedItems   int64   `json:"returned_items"`
+	TotalSales      float64 `json:"total_sales"`
+	TotalCommission float64 `json:"total_commission"`
+	TotalRemaining  float64 `json:"total_remaining"` // Owed to the vendor
+}
+
+// VendorStatement is a vendor's sales and monthly settlements, holding
+// nothing about other vendors so it can be shared with the vendor
+type VendorStatement struct {
+	Vendor      string                `json:"vendor"`
+	From        Date                  `json:"from"` // First sale included, or the filter's start
+	To          Date                  `json:"to"`   // Last sale included, or the filter's end
+	GeneratedAt time.Time             `json:"generated_at"`
+	Lines       []VendorStatementLine `json:"lines"`
+	Settlements []VendorSettlement    `json:"settlements"` // By month, newest first, then store
+	Total       VendorSettlement      `json:"total"`       // Sum of the settlements; Store and Month are empty
+}
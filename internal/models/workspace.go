@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// WorkspaceFormatVersion is the version of the workspace archive layout
+// written by this build
+const WorkspaceFormatVersion = 1
+
+// WorkspaceManifest describes a workspace archive: the whole database,
+// settings included, for moving to another computer
+type WorkspaceManifest struct {
+	FormatVersion int       `json:"format_version"` // WorkspaceFormatVersion of the build that wrote it
+	CreatedAt     time.Time `json:"created_at"`
+	SchemaVersion int       `json:"schema_version"` // Migration version of the archived database
+	Records       int64     `json:"records"`        // Sales records archived, for confirming an import
+}
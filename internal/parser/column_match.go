@@ -0,0 +1,90 @@
+package parser
+
+import "strings"
+
+// Ways a field was matched to a column, reported in ColumnMatch.Method
+const (
+	MatchExact      = "exact"      // The header is one of the field's names
+	MatchPartial    = "partial"    // The header contains one of the field's names, or the reverse
+	MatchLayout     = "layout"     // The header is named by the configured layout
+	MatchPositional = "positional" // The field was assigned to the column by position
+	MatchOverride   = "override"   // The field was assigned to the column by a ColumnOverride
+)
+
+// ColumnMatch explains why a field was mapped to a column, so that wrong
+// mappings can be spotted, as in "Sale Price ← 'Amount' (90%)"
+type ColumnMatch struct {
+	Column     int    `json:"column"`            // Index of the column
+	Header     string `json:"header"`            // Header of the column
+	Synonym    string `json:"synonym,omitempty"` // Name of the field the header matched; empty for positional mapping
+	Method     string `json:"method"`            // MatchExact, MatchPartial, MatchLayout, MatchPositional or MatchOverride
+	Confidence int    `json:"confidence"`        // Percentage; partial matches score lower the less of the header they cover
+}
+
+// explainMapping describes how each field of mapping was matched to its
+// header, using the matching rules of createColumnMapping
+func (p *HTMLTableParser) explainMapping(headers []string, mapping map[string]int) map[string]ColumnMatch {
+	matches := make(map[string]ColumnMatch, len(mapping))
+	overridden, _, _ := p.overriddenColumns(headers)
+	for field, idx := range mapping {
+		match := ColumnMatch{Column: idx}
+		if idx < len(headers) {
+			match.Header = strings.TrimSpace(headers[idx])
+		}
+
+		forced, isForced := overridden[field]
+		switch {
+		case isForced && forced == idx:
+			match.Method = MatchOverride
+			match.Confidence = 100
+		case p.UsePositionalMapping && len(p.PositionalColumns) > 0:
+			match.Method = MatchPositional
+			match.Confidence = 100
+		case p.Layout != nil && len(p.Layout.Headers) > 0:
+			match.Method = MatchLayout
+			match.Synonym = match.Header
+			match.Confidence = 100
+		default:
+			match.Synonym, match.Method, match.Confidence = synonymMatch(field, match.Header)
+		}
+		matches[field] = match
+	}
+	return matches
+}
+
+// synonymMatch finds the field's name that header matches and rates the
+// match. Names equal to the header are preferred: the field's main name
+// scores 100 and another name 90. Otherwise the first name that one of them
+// contains scores between 50 and 90 by how much of the longer text the
+// shorter one covers.
+func synonymMatch(field, header string) (string, string, int) {
+	variations, exactOnly := ExactColumnMapping[field]
+	if !exactOnly {
+		variations = ColumnMapping[field]
+	}
+
+	normalized := strings.ToLower(strings.TrimSpace(header))
+	for i, variation := range variations {
+		if normalized == strings.ToLower(variation) {
+			if i == 0 {
+				return variation, MatchExact, 100
+			}
+			return variation, MatchExact, 90
+		}
+	}
+
+	if exactOnly {
+		return "", MatchPartial, 0
+	}
+	for _, variation := range variations {
+		variation = strings.ToLower(variation)
+		if strings.Contains(normalized, variation) || strings.Contains(variation, normalized) {
+			shorter, longer := len(variation), len(normalized)
+			if shorter > longer {
+				shorter, longer = longer, shorter
+			}
+			return variation, MatchPartial, 50 + 40*shorter/longer
+		}
+	}
+	return "", MatchPartial, 0
+}
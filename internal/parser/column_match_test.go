@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestParseHTML_ColumnMatches(t *testing.T) {
+	table := `<table>
+		<tr><th>Store</th><th>Supplier</th><th>Sale Date</th><th>Item Name</th><th>Amount</th><th>Comm</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-03-15</td><td>Lamp</td><td>40.00</td><td>4.00</td></tr>
+	</table>`
+
+	result, err := NewHTMLTableParser().ParseHTML(table)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+
+	expected := map[string]ColumnMatch{
+		"store":       {Column: 0, Header: "Store", Synonym: "store", Method: MatchExact, Confidence: 100},
+		"vendor":      {Column: 1, Header: "Supplier", Synonym: "supplier", Method: MatchExact, Confidence: 90},
+		"date":        {Column: 2, Header: "Sale Date", Synonym: "sale date", Method: MatchExact, Confidence: 90},
+		"description": {Column: 3, Header: "Item Name", Synonym: "item", Method: MatchPartial, Confidence: 67},
+		"sale_price":  {Column: 4, Header: "Amount", Synonym: "amount", Method: MatchExact, Confidence: 90},
+		"commission":  {Column: 5, Header: "Comm", Synonym: "comm", Method: MatchExact, Confidence: 90},
+	}
+	for field, want := range expected {
+		if got := result.ColumnMatches[field]; got != want {
+			t.Errorf("Expected %s matched as %+v, got %+v", field, want, got)
+		}
+	}
+	if len(result.ColumnMatches) != len(result.ColumnMapping) {
+		t.Errorf("Expected a match for each of %v, got %+v", result.ColumnMapping, result.ColumnMatches)
+	}
+
+	// The streaming parser explains its mapping the same way
+	streamed, _, err := collectStream(t, NewHTMLTableParser(), table)
+	if err != nil {
+		t.Fatalf("ParseHTMLStream failed: %v", err)
+	}
+	if streamed.ColumnMatches["sale_price"] != expected["sale_price"] {
+		t.Errorf("Expected streamed sale_price match %+v, got %+v", expected["sale_price"], streamed.ColumnMatches["sale_price"])
+	}
+}
+
+func TestParseHTML_ColumnMatchesPositional(t *testing.T) {
+	parser := NewHTMLTableParser()
+	parser.SetConsignableMapping()
+
+	result, err := parser.ParseHTML(`<tr><td>Store A</td><td>Vendor 1</td><td>2024-03-15</td><td>Lamp</td><td>40.00</td><td>4.00</td><td>36.00</td></tr>`)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+
+	match := result.ColumnMatches["sale_price"]
+	if match.Method != MatchPositional || match.Column != 4 || match.Confidence != 100 || match.Synonym != "" {
+		t.Errorf("Expected a positional match of column 4, got %+v", match)
+	}
+}
@@ -0,0 +1,437 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Containers a saved report page may be wrapped in
+const (
+	ContainerMHTML      = "mhtml"      // "Save page as" single-file web page (.mht, .mhtml)
+	ContainerWebArchive = "webarchive" // Safari web archive (.webarchive)
+	ContainerDataURL    = "data-url"   // data: URL holding the page
+)
+
+// ContainerSniffSize is how much of the start of a file DetectContainer needs
+const ContainerSniffSize = 1024
+
+// DetectContainer reports the container format that data, the start of a
+// file, is wrapped in, or "" for a plain page or export
+func DetectContainer(prefix []byte) string {
+	prefix = bytes.TrimPrefix(prefix, []byte("\xef\xbb\xbf"))
+	switch {
+	case bytes.HasPrefix(prefix, []byte("bplist00")):
+		return ContainerWebArchive
+	case hasFoldPrefix(bytes.TrimSpace(prefix), "data:"):
+		return ContainerDataURL
+	case isMIMEHeader(prefix) && bytes.Contains(bytes.ToLower(prefix), []byte("multipart/related")):
+		return ContainerMHTML
+	}
+	return ""
+}
+
+// ExtractHTML returns the page held in a container detected by
+// DetectContainer, converted to UTF-8. Data that is not in a container is
+// returned unchanged.
+func ExtractHTML(data []byte) ([]byte, error) {
+	switch DetectContainer(data) {
+	case ContainerMHTML:
+		return extractMHTML(data)
+	case ContainerWebArchive:
+		return extractWebArchive(data)
+	case ContainerDataURL:
+		return extractDataURL(data)
+	}
+	return data, nil
+}
+
+// hasFoldPrefix reports whether data starts with prefix, ignoring case
+func hasFoldPrefix(data []byte, prefix string) bool {
+	return len(data) >= len(prefix) && strings.EqualFold(string(data[:len(prefix)]), prefix)
+}
+
+// isMIMEHeader reports whether data starts with a MIME header line, such as
+// "From: <Saved by Blink>" or "MIME-Version: 1.0"
+func isMIMEHeader(data []byte) bool {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	name, _, ok := bytes.Cut(line, []byte(":"))
+	if !ok || len(name) == 0 {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// extractMHTML returns the first HTML part of an MHTML file, which browsers
+// write for the saved page itself before its frames and resources
+func extractMHTML(data []byte) ([]byte, error) {
+	message, err := mail.ReadMessage(bufio.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MHTML file: %w", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return nil, fmt.Errorf("invalid MHTML file: missing multipart boundary")
+	}
+
+	parts := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		// Raw parts leave the transfer encoding to be decoded here, as
+		// NextPart only decodes quoted-printable
+		part, err := parts.NextRawPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("MHTML file has no HTML page")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid MHTML file: %w", err)
+		}
+
+		mediaType, params, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if err != nil || mediaType != "text/html" {
+			continue
+		}
+		body, err := decodeTransfer(part, part.Header)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MHTML page: %w", err)
+		}
+		return decodeCharset(body, params["charset"])
+	}
+}
+
+// decodeTransfer reads a MIME part, undoing its Content-Transfer-Encoding
+func decodeTransfer(r io.Reader, header textproto.MIMEHeader) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, newlineStripper{r})
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	}
+	return io.ReadAll(r)
+}
+
+// newlineStripper drops the line breaks of base64 encoded MIME parts
+type newlineStripper struct {
+	r io.Reader
+}
+
+// Read reads from the underlying reader, leaving out line breaks
+func (s newlineStripper) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	kept := 0
+	for _, c := range p[:n] {
+		if c != '\r' && c != '\n' {
+			p[kept] = c
+			kept++
+		}
+	}
+	return kept, err
+}
+
+// extractDataURL decodes a data: URL holding a page or export, such as
+// "data:text/html;base64,PHRhYmxlPg=="
+func extractDataURL(data []byte) ([]byte, error) {
+	text := strings.TrimSpace(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	header, payload, ok := strings.Cut(text[len("data:"):], ",")
+	if !ok {
+		return nil, fmt.Errorf("invalid data URL: missing ','")
+	}
+
+	isBase64 := false
+	if before, found := strings.CutSuffix(header, ";base64"); found {
+		header, isBase64 = before, true
+	}
+	mediaType, params := "text/plain", map[string]string{}
+	if header != "" {
+		var err error
+		if mediaType, params, err = mime.ParseMediaType(header); err != nil {
+			return nil, fmt.Errorf("invalid data URL media type: %w", err)
+		}
+	}
+	if !strings.HasPrefix(mediaType, "text/") {
+		return nil, fmt.Errorf("data URL holds %s, not a page or export", mediaType)
+	}
+
+	var body []byte
+	var err error
+	if isBase64 {
+		payload, err = url.PathUnescape(payload)
+		if err == nil {
+			payload = strings.Join(strings.Fields(payload), "")
+			body, err = base64.StdEncoding.DecodeString(payload)
+			if err != nil {
+				body, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(payload, "="))
+			}
+		}
+	} else {
+		var unescaped string
+		unescaped, err = url.PathUnescape(payload)
+		body = []byte(unescaped)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid data URL: %w", err)
+	}
+	return decodeCharset(body, params["charset"])
+}
+
+// extractWebArchive returns the main resource of a Safari web archive, a
+// binary property list holding the page and its subresources
+func extractWebArchive(data []byte) ([]byte, error) {
+	root, err := readBinaryPlist(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid web archive: %w", err)
+	}
+	archive, _ := root.(map[string]interface{})
+	resource, _ := archive["WebMainResource"].(map[string]interface{})
+	body, ok := resource["WebResourceData"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("web archive has no main page")
+	}
+	charset, _ := resource["WebResourceTextEncodingName"].(string)
+	return decodeCharset(body, charset)
+}
+
+// maxPlistDepth bounds the nesting read from a property list, so a crafted
+// file referring to its own objects cannot recurse forever
+const maxPlistDepth = 32
+
+// binaryPlist reads the objects of a binary property list ("bplist00")
+type binaryPlist struct {
+	data    []byte
+	offsets []uint64
+	refSize int
+	decoded map[uint64]interface{} // Objects already read, as lists share them
+}
+
+// readBinaryPlist decodes the top object of a binary property list. Only
+// dictionaries, arrays, strings, data and integers are read; other objects
+// decode as nil.
+func readBinaryPlist(data []byte) (interface{}, error) {
+	if len(data) < 8+32 {
+		return nil, fmt.Errorf("property list too short")
+	}
+	trailer := data[len(data)-32:]
+	offsetSize, refSize := int(trailer[6]), int(trailer[7])
+	count := binary.BigEndian.Uint64(trailer[8:16])
+	top := binary.BigEndian.Uint64(trailer[16:24])
+	tableOffset := binary.BigEndian.Uint64(trailer[24:32])
+	if offsetSize < 1 || offsetSize > 8 || refSize < 1 || refSize > 8 || top >= count ||
+		tableOffset >= uint64(len(data)) || count > (uint64(len(data))-tableOffset)/uint64(offsetSize) {
+		return nil, fmt.Errorf("invalid property list trailer")
+	}
+
+	plist := &binaryPlist{data: data, refSize: refSize, offsets: make([]uint64, count), decoded: make(map[uint64]interface{})}
+	for i := range plist.offsets {
+		start := tableOffset + uint64(i*offsetSize)
+		plist.offsets[i] = readUint(data[start : start+uint64(offsetSize)])
+	}
+	return plist.object(top, 0)
+}
+
+// readUint reads a big-endian unsigned integer of up to 8 bytes
+func readUint(b []byte) uint64 {
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
+
+// slice returns n bytes of the list at offset, checking they are in range
+func (p *binaryPlist) slice(offset, n uint64) ([]byte, error) {
+	if offset > uint64(len(p.data)) || n > uint64(len(p.data))-offset {
+		return nil, fmt.Errorf("object out of range")
+	}
+	return p.data[offset : offset+n], nil
+}
+
+// length reads the element count of the object whose marker is at offset,
+// returning it and the offset of the object's contents
+func (p *binaryPlist) length(offset uint64) (uint64, uint64, error) {
+	info := uint64(p.data[offset] & 0x0f)
+	if info != 0x0f {
+		return info, offset + 1, nil
+	}
+	marker, err := p.slice(offset+1, 1)
+	if err != nil || marker[0]>>4 != 0x1 {
+		return 0, 0, fmt.Errorf("invalid object length")
+	}
+	size := uint64(1) << (marker[0] & 0x0f)
+	b, err := p.slice(offset+2, size)
+	if err != nil {
+		return 0, 0, err
+	}
+	return readUint(b), offset + 2 + size, nil
+}
+
+// refs reads n object references starting at offset
+func (p *binaryPlist) refs(offset, n uint64) ([]uint64, error) {
+	if n > uint64(len(p.data))/uint64(p.refSize) {
+		return nil, fmt.Errorf("object out of range")
+	}
+	b, err := p.slice(offset, n*uint64(p.refSize))
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]uint64, n)
+	for i := range refs {
+		refs[i] = readUint(b[i*p.refSize : (i+1)*p.refSize])
+	}
+	return refs, nil
+}
+
+// object decodes the object with index ref
+func (p *binaryPlist) object(ref uint64, depth int) (interface{}, error) {
+	if ref >= uint64(len(p.offsets)) || depth > maxPlistDepth {
+		return nil, fmt.Errorf("invalid object reference")
+	}
+	if object, ok := p.decoded[ref]; ok {
+		return object, nil
+	}
+	object, err := p.decode(ref, depth)
+	if err != nil {
+		return nil, err
+	}
+	p.decoded[ref] = object
+	return object, nil
+}
+
+// decode reads the object with index ref from the list
+func (p *binaryPlist) decode(ref uint64, depth int) (interface{}, error) {
+	offset := p.offsets[ref]
+	if offset >= uint64(len(p.data)) {
+		return nil, fmt.Errorf("object out of range")
+	}
+
+	switch p.data[offset] >> 4 {
+	case 0x1: // Integer of 2^n bytes
+		b, err := p.slice(offset+1, uint64(1)<<(p.data[offset]&0x0f))
+		if err != nil {
+			return nil, err
+		}
+		return int64(readUint(b)), nil
+	case 0x4, 0x5, 0x6: // Data, ASCII string, UTF-16 string
+		n, start, err := p.length(offset)
+		if err != nil {
+			return nil, err
+		}
+		kind := p.data[offset] >> 4
+		if kind == 0x6 {
+			n *= 2
+		}
+		b, err := p.slice(start, n)
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+		case 0x4:
+			return b, nil
+		case 0x5:
+			return string(b), nil
+		}
+		units := make([]uint16, len(b)/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(b[2*i:])
+		}
+		return string(utf16.Decode(units)), nil
+	case 0xA: // Array
+		n, start, err := p.length(offset)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := p.refs(start, n)
+		if err != nil {
+			return nil, err
+		}
+		array := make([]interface{}, len(refs))
+		for i, ref := range refs {
+			if array[i], err = p.object(ref, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+	case 0xD: // Dictionary: keys, then values
+		n, start, err := p.length(offset)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := p.refs(start, 2*n)
+		if err != nil {
+			return nil, err
+		}
+		dict := make(map[string]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			key, err := p.object(refs[i], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				continue
+			}
+			if dict[name], err = p.object(refs[n+i], depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	}
+	return nil, nil
+}
+
+// windows1252 maps the bytes 0x80 to 0x9F of Windows-1252 to the characters
+// they stand for; the other bytes match ISO-8859-1
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// decodeCharset converts a page in one of the character sets browsers save
+// pages in to UTF-8
+func decodeCharset(data []byte, charset string) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "", "utf-8", "utf8", "us-ascii":
+		return bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), nil
+	case "iso-8859-1", "latin1", "windows-1252", "cp1252":
+		decoded := make([]byte, 0, len(data))
+		for _, c := range data {
+			r := rune(c)
+			if c >= 0x80 && c < 0xA0 {
+				r = windows1252[c-0x80]
+			}
+			decoded = utf8.AppendRune(decoded, r)
+		}
+		return decoded, nil
+	case "utf-16", "utf-16le", "utf-16be":
+		order := binary.ByteOrder(binary.LittleEndian)
+		switch {
+		case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+			order, data = binary.BigEndian, data[2:]
+		case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+			data = data[2:]
+		case strings.EqualFold(charset, "utf-16be"):
+			order = binary.BigEndian
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = order.Uint16(data[2*i:])
+		}
+		return []byte(string(utf16.Decode(units))), nil
+	}
+	return nil, fmt.Errorf("unsupported character set %q", charset)
+}
@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+const containerPage = `<table><tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Café Lamp</td><td>$40.00</td></tr></table>`
+
+// testWebArchive builds a binary property list holding a web archive whose
+// main resource is page, written the way Safari saves it
+func testWebArchive(page []byte, charset string) []byte {
+	// Objects: 0 archive, 1 "WebMainResource", 2 resource, 3 "WebResourceData",
+	// 4 data, 5 "WebResourceTextEncodingName", 6 charset
+	length := func(marker byte, n int) []byte {
+		if n < 15 {
+			return []byte{marker | byte(n)}
+		}
+		b := []byte{marker | 0x0f, 0x13}
+		return binary.BigEndian.AppendUint64(b, uint64(n))
+	}
+	ascii := func(s string) []byte { return append(length(0x50, len(s)), s...) }
+	objects := [][]byte{
+		append(length(0xd0, 1), 1, 2),
+		ascii("WebMainResource"),
+		append(length(0xd0, 2), 3, 5, 4, 6),
+		ascii("WebResourceData"),
+		append(length(0x40, len(page)), page...),
+		ascii("WebResourceTextEncodingName"),
+		ascii(charset),
+	}
+
+	data := []byte("bplist00")
+	var offsets []uint64
+	for _, object := range objects {
+		offsets = append(offsets, uint64(len(data)))
+		data = append(data, object...)
+	}
+	tableOffset := uint64(len(data))
+	for _, offset := range offsets {
+		data = binary.BigEndian.AppendUint64(data, offset)
+	}
+	trailer := make([]byte, 6, 32)
+	trailer = append(trailer, 8, 1)
+	trailer = binary.BigEndian.AppendUint64(trailer, uint64(len(objects)))
+	trailer = binary.BigEndian.AppendUint64(trailer, 0)
+	trailer = binary.BigEndian.AppendUint64(trailer, tableOffset)
+	return append(data, trailer...)
+}
+
+func TestExtractHTML(t *testing.T) {
+	mhtml := "From: <Saved by Blink>\r\n" +
+		"Snapshot-Content-Location: https://consignable.example/reports/42\r\n" +
+		"Subject: Sales Report\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/related;\r\n\ttype=\"text/html\";\r\n\tboundary=\"----MultipartBoundary--abc----\"\r\n" +
+		"\r\n" +
+		"------MultipartBoundary--abc----\r\n" +
+		"Content-Type: text/html\r\n" +
+		"Content-ID: <frame-1@mhtml.blink>\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		strings.ReplaceAll(strings.ReplaceAll(containerPage, "é", "=C3=A9"), "\n", "=0A=\r\n") + "\r\n" +
+		"------MultipartBoundary--abc----\r\n" +
+		"Content-Type: text/css\r\n" +
+		"\r\n" +
+		"td { color: red; }\r\n" +
+		"------MultipartBoundary--abc------\r\n"
+
+	latin1 := bytes.ReplaceAll([]byte(containerPage), []byte("é"), []byte{0xe9})
+	encoded := base64.StdEncoding.EncodeToString(latin1)
+	base64Parts := "From: <Saved by Microsoft Internet Explorer>\r\n" +
+		"Content-Type: multipart/related; boundary=\"=_NextPart\"\r\n" +
+		"\r\n" +
+		"--=_NextPart\r\n" +
+		"Content-Type: text/html; charset=\"windows-1252\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		encoded[:60] + "\r\n" + encoded[60:] + "\r\n" +
+		"--=_NextPart--\r\n"
+
+	tests := []struct {
+		name      string
+		data      []byte
+		container string
+	}{
+		{"mhtml quoted-printable", []byte(mhtml), ContainerMHTML},
+		{"mhtml base64 windows-1252", []byte(base64Parts), ContainerMHTML},
+		{"webarchive", testWebArchive([]byte(containerPage), "UTF-8"), ContainerWebArchive},
+		{"webarchive latin-1", testWebArchive(latin1, "ISO-8859-1"), ContainerWebArchive},
+		{"data URL base64", []byte("data:text/html;charset=utf-8;base64," + base64.StdEncoding.EncodeToString([]byte(containerPage)) + "\n"), ContainerDataURL},
+		{"data URL percent-encoded", []byte("data:text/html," + strings.NewReplacer("<", "%3C", ">", "%3E", "\n", "%0A", "é", "%C3%A9", "$", "%24").Replace(containerPage)), ContainerDataURL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if container := DetectContainer(tt.data); container != tt.container {
+				t.Fatalf("Expected %q to be detected, got %q", tt.container, container)
+			}
+			page, err := ExtractHTML(tt.data)
+			if err != nil {
+				t.Fatalf("ExtractHTML failed: %v", err)
+			}
+			if string(page) != containerPage {
+				t.Errorf("Expected the saved page, got %q", page)
+			}
+
+			result, err := NewHTMLTableParser().ParseHTML(string(page))
+			if err != nil || len(result.Records) != 1 || result.Records[0].Description != "Café Lamp" {
+				t.Errorf("Expected the page to parse, got %+v, %v", result, err)
+			}
+		})
+	}
+
+	// Plain pages and exports are left alone
+	for _, data := range []string{containerPage, "Store,Vendor\nStore A,Vendor 1\n", "Date: 2024-01-15\n<table></table>"} {
+		if container := DetectContainer([]byte(data)); container != "" {
+			t.Errorf("Expected no container for %q, got %q", data, container)
+		}
+	}
+}
+
+func TestExtractHTML_Invalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"no html part":     "MIME-Version: 1.0\r\nContent-Type: multipart/related; boundary=b\r\n\r\n--b\r\nContent-Type: image/png\r\n\r\nxx\r\n--b--\r\n",
+		"image data URL":   "data:image/png;base64,iVBORw0KGgo=",
+		"truncated plist":  "bplist00\xd1\x01\x02",
+		"unknown charset":  "data:text/html;charset=koi8-r,%3Ctable%3E",
+		"data URL missing": "data:text/html",
+	} {
+		if _, err := ExtractHTML([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// An archive whose dictionary refers to itself is rejected rather than
+	// read forever
+	archive := testWebArchive([]byte(containerPage), "UTF-8")
+	archive[bytes.Index(archive, []byte{0xd1, 1, 2})+2] = 0
+	if _, err := ExtractHTML(archive); err == nil {
+		t.Error("Expected a self-referencing archive to be rejected")
+	}
+}
@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+
+	"sales-track/internal/models"
+)
+
+// contentDigest accumulates a hash of the records a parse produced, for
+// recognizing a report that was already imported. Records are hashed without
+// their SourceRow and the record hashes are combined in sorted order, so the
+// digest does not change with the markup around the table, the row numbers or
+// the order the rows were exported in.
+type contentDigest struct {
+	records [][sha256.Size]byte
+}
+
+// add includes record in the digest
+func (d *contentDigest) add(record models.CreateSalesRecordRequest) {
+	record.SourceRow = nil
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		// Parsed records hold only strings and finite numbers
+		return
+	}
+	d.records = append(d.records, sha256.Sum256(encoded))
+}
+
+// sum returns the hex-encoded digest, or "" when no records were added
+func (d *contentDigest) sum() string {
+	if len(d.records) == 0 {
+		return ""
+	}
+	sort.Slice(d.records, func(i, j int) bool {
+		return bytes.Compare(d.records[i][:], d.records[j][:]) < 0
+	})
+	h := sha256.New()
+	for _, record := range d.records {
+		h.Write(record[:])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordsHash returns the content hash of records, as kept in
+// ParseResult.ContentHash
+func recordsHash(records []models.CreateSalesRecordRequest) string {
+	var digest contentDigest
+	for _, record := range records {
+		digest.add(record)
+	}
+	return digest.sum()
+}
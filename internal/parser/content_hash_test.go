@@ -0,0 +1,59 @@
+package parser
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"sales-track/internal/models"
+)
+
+// TestContentHash tests recognizing the same report content parsed again
+func TestContentHash(t *testing.T) {
+	table := `<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-03-15</td><td>Lamp</td><td>40.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-03-16</td><td>Chair</td><td>75.00</td></tr>
+	</table>`
+	reordered := `<h2>Downtown</h2><table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-03-16</td><td>Chair</td><td>$75</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-03-15</td><td>Lamp</td><td>$40.00</td></tr>
+	</table>`
+	changed := strings.Replace(table, "75.00", "76.00", 1)
+
+	hash := func(data string) string {
+		result, err := NewHTMLTableParser().ParseHTML(data)
+		if err != nil {
+			t.Fatalf("ParseHTML failed: %v", err)
+		}
+		return result.ContentHash
+	}
+
+	original := hash(table)
+	if len(original) != 64 {
+		t.Fatalf("Expected a hex SHA-256 content hash, got %q", original)
+	}
+	if got := hash(reordered); got != original {
+		t.Errorf("Expected the same hash for the same records in another order and markup, got %s and %s", got, original)
+	}
+	if got := hash(changed); got == original {
+		t.Error("Expected a different hash for a changed sale price")
+	}
+
+	// The streaming parser hashes the records it sends alike
+	records := make(chan models.CreateSalesRecordRequest, 10)
+	streamed, err := NewHTMLTableParser().ParseHTMLStream(context.Background(), strings.NewReader(table), records)
+	if err != nil {
+		t.Fatalf("ParseHTMLStream failed: %v", err)
+	}
+	if streamed.ContentHash != original {
+		t.Errorf("Expected the streamed hash %s, got %s", original, streamed.ContentHash)
+	}
+
+	// A report without any valid record has no content to match
+	empty := strings.Replace(strings.Replace(table, "40.00", "n/a", 1), "75.00", "n/a", 1)
+	if got := hash(empty); got != "" {
+		t.Errorf("Expected no hash without records, got %s", got)
+	}
+}
@@ -0,0 +1,218 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// TableContext is the text around a table that describes it, such as
+// "Sales for March 2024". It names the import and supplies the year for
+// dates written without one.
+type TableContext struct {
+	Caption string `json:"caption,omitempty"` // The table's <caption>
+	Heading string `json:"heading,omitempty"` // Nearest <h1>-<h4> before the table
+	Section string `json:"section,omitempty"` // Title of an enclosing fieldset, details, or labelled section
+	Period  string `json:"period,omitempty"`  // Month named in the context, as YYYY-MM
+	Year    int    `json:"year,omitempty"`    // Year named in the context; 0 if none
+}
+
+var (
+	// monthYearPattern matches "March 2024", "Mar. 2024" and "March, 2024"
+	monthYearPattern = regexp.MustCompile(`(?i)\b(jan(?:uary)?|feb(?:ruary)?|mar(?:ch)?|apr(?:il)?|may|june?|july?|aug(?:ust)?|sep(?:t(?:ember)?)?|oct(?:ober)?|nov(?:ember)?|dec(?:ember)?)\.?,?\s+(\d{4})\b`)
+
+	// numericPeriodPattern matches "2024-03" and "2024/03"
+	numericPeriodPattern = regexp.MustCompile(`\b(\d{4})[-/](\d{1,2})\b`)
+
+	// numericMonthFirstPattern matches "03/2024" and "3-2024"
+	numericMonthFirstPattern = regexp.MustCompile(`\b(\d{1,2})[-/](\d{4})\b`)
+
+	// yearPattern matches a year on its own, such as "Annual Report 2023"
+	yearPattern = regexp.MustCompile(`\b(19|20)\d{2}\b`)
+)
+
+// monthPrefixes lists the three-letter month prefixes in calendar order
+const monthPrefixes = "janfebmaraprmayjunjulaugsepoctnovdec"
+
+// yearlessDateFormats are read when a date has no year and the table's
+// context supplies one
+var yearlessDateFormats = []string{
+	"Jan 2",
+	"January 2",
+	"2 Jan",
+	"2 January",
+	"Jan-2",
+	"2-Jan",
+}
+
+// Title returns the most specific description of the table: its caption,
+// else the heading before it, else its section title
+func (c TableContext) Title() string {
+	switch {
+	case c.Caption != "":
+		return c.Caption
+	case c.Heading != "":
+		return c.Heading
+	default:
+		return c.Section
+	}
+}
+
+// IsEmpty reports whether no context was found
+func (c TableContext) IsEmpty() bool {
+	return c.Title() == ""
+}
+
+// extractTableContext collects the caption, preceding heading and section
+// title of a table, and the period and year they name
+func (p *HTMLTableParser) extractTableContext(table *html.Node) TableContext {
+	var context TableContext
+
+	for child := table.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.Data == "caption" {
+			context.Caption = normalizeSpace(p.extractTextContent(child))
+			break
+		}
+	}
+	context.Heading = p.precedingHeading(table)
+	context.Section = p.sectionTitle(table)
+	context.resolveDates()
+	return context
+}
+
+// resolveDates sets the period and year from the most specific text that
+// names one
+func (c *TableContext) resolveDates() {
+	for _, text := range []string{c.Caption, c.Heading, c.Section} {
+		if c.Period == "" {
+			c.Period = contextPeriod(text)
+		}
+		if c.Year == 0 {
+			c.Year = contextYear(text)
+		}
+	}
+	if c.Period != "" {
+		c.Year, _ = strconv.Atoi(c.Period[:4])
+	}
+}
+
+// precedingHeading returns the text of the nearest <h1>-<h4> before the
+// table. Headings are not looked for past an earlier table, whose heading
+// they would be.
+func (p *HTMLTableParser) precedingHeading(table *html.Node) string {
+	for node := table; node != nil && node.Type != html.DocumentNode; node = node.Parent {
+		for sibling := node.PrevSibling; sibling != nil; sibling = sibling.PrevSibling {
+			if sibling.Type != html.ElementNode {
+				continue
+			}
+			if isHeading(sibling.Data) {
+				return normalizeSpace(p.extractTextContent(sibling))
+			}
+			if sibling.Data == "table" || len(p.findTables(sibling)) > 0 {
+				return ""
+			}
+		}
+	}
+	return ""
+}
+
+// sectionTitle returns the title of the nearest enclosing element that has
+// one: a fieldset's <legend>, a details element's <summary>, or an
+// aria-label or title attribute
+func (p *HTMLTableParser) sectionTitle(table *html.Node) string {
+	for node := table.Parent; node != nil && node.Type == html.ElementNode; node = node.Parent {
+		for _, attr := range node.Attr {
+			if (attr.Key == "aria-label" || attr.Key == "title") && strings.TrimSpace(attr.Val) != "" {
+				return normalizeSpace(attr.Val)
+			}
+		}
+
+		titleTag := map[string]string{"fieldset": "legend", "details": "summary"}[node.Data]
+		if titleTag == "" {
+			continue
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.ElementNode && child.Data == titleTag {
+				if title := normalizeSpace(p.extractTextContent(child)); title != "" {
+					return title
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// isHeading reports whether tag is h1 to h4
+func isHeading(tag string) bool {
+	return len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '4'
+}
+
+// normalizeSpace collapses runs of whitespace into single spaces
+func normalizeSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// contextPeriod derives a month, as YYYY-MM, from a caption or heading such
+// as "Sales for March 2024" or "2024-03". It returns "" if there is none.
+func contextPeriod(context string) string {
+	if match := monthYearPattern.FindStringSubmatch(context); match != nil {
+		month := strings.Index(monthPrefixes, strings.ToLower(match[1][:3]))/3 + 1
+		return fmt.Sprintf("%s-%02d", match[2], month)
+	}
+
+	year, month := "", ""
+	if match := numericPeriodPattern.FindStringSubmatch(context); match != nil {
+		year, month = match[1], match[2]
+	} else if match := numericMonthFirstPattern.FindStringSubmatch(context); match != nil {
+		year, month = match[2], match[1]
+	}
+	if m, err := strconv.Atoi(month); err == nil && m >= 1 && m <= 12 {
+		return fmt.Sprintf("%s-%02d", year, m)
+	}
+	return ""
+}
+
+// contextYear finds a year between 1900 and 2099 in text, or returns 0
+func contextYear(text string) int {
+	year, _ := strconv.Atoi(yearPattern.FindString(text))
+	return year
+}
+
+// parseYearlessDate reads a date such as "Mar 15" in the given year
+func parseYearlessDate(dateStr string, year int) (string, bool) {
+	for _, format := range yearlessDateFormats {
+		parsed, err := time.Parse(format, dateStr)
+		if err != nil {
+			continue
+		}
+		date := time.Date(year, parsed.Month(), parsed.Day(), 0, 0, 0, 0, time.UTC)
+		if date.Day() != parsed.Day() {
+			return "", false // February 29 in a year that is not a leap year
+		}
+		return date.Format("2006-01-02"), true
+	}
+	return "", false
+}
+
+// setContextYear makes dates without a year in the rows parsed next take the
+// context's year
+func (p *HTMLTableParser) setContextYear(context TableContext) {
+	p.contextYear = context.Year
+	p.yearsInferred = 0
+}
+
+// yearInferenceWarning reports how many dates took an inferred year, and
+// where it came from, if any did
+func (p *HTMLTableParser) yearInferenceWarning(context TableContext) []ParseWarning {
+	if p.yearsInferred == 0 {
+		return nil
+	}
+	return []ParseWarning{{
+		Column:  "date",
+		Message: fmt.Sprintf("%d dates without a year were read as %d, from %s", p.yearsInferred, p.yearlessYear(), p.yearlessSource(context)),
+	}}
+}
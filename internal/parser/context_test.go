@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"sales-track/internal/models"
+)
+
+func TestParseHTML_TableContext(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected *TableContext
+	}{
+		{
+			name:     "caption",
+			html:     `<h1>Vendor Portal</h1><table><caption>Statement for March 2024</caption>` + contextRows + `</table>`,
+			expected: &TableContext{Caption: "Statement for March 2024", Heading: "Vendor Portal", Period: "2024-03", Year: 2024},
+		},
+		{
+			name: "heading in an enclosing element",
+			html: `<h2>Annual   Sales
+				2023</h2><div><p>Generated nightly</p><table>` + contextRows + `</table></div>`,
+			expected: &TableContext{Heading: "Annual Sales 2023", Year: 2023},
+		},
+		{
+			name:     "fieldset legend",
+			html:     `<fieldset><legend>Payouts - 2022</legend><table>` + contextRows + `</table></fieldset>`,
+			expected: &TableContext{Section: "Payouts - 2022", Year: 2022},
+		},
+		{
+			name:     "heading belongs to an earlier table",
+			html:     `<h2>Summary</h2><table><tr><td>Total</td></tr></table><table>` + contextRows + `</table>`,
+			expected: nil,
+		},
+		{
+			name:     "h5 is not a heading",
+			html:     `<h5>Printed 2024-01-02</h5><table>` + contextRows + `</table>`,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewHTMLTableParser().ParseHTML(tt.html)
+			if err != nil {
+				t.Fatalf("ParseHTML failed: %v", err)
+			}
+
+			got := result.Statistics.Context
+			if (got == nil) != (tt.expected == nil) || (got != nil && *got != *tt.expected) {
+				t.Errorf("Expected context %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+// contextRows is a header row and a data row
+const contextRows = `
+	<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+	<tr><td>Store A</td><td>Vendor 1</td><td>2024-03-15</td><td>Lamp</td><td>40.00</td></tr>`
+
+func TestParseHTML_YearInference(t *testing.T) {
+	table := `<h2>Sales Report 2023</h2>
+	<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>Mar 15</td><td>Lamp</td><td>40.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>4 April</td><td>Chair</td><td>75.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>Feb 29</td><td>Rug</td><td>120.00</td></tr>
+	</table>`
+
+	result, err := NewHTMLTableParser().ParseHTML(table)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+
+	if result.SuccessCount != 2 || result.Records[0].Date != "2023-03-15" || result.Records[1].Date != "2023-04-04" {
+		t.Errorf("Expected dates in 2023, got %+v", result.Records)
+	}
+	// 2023 is not a leap year
+	if result.ErrorCount != 1 || result.Errors[0].Value != "Feb 29" {
+		t.Errorf("Expected Feb 29 to fail, got %+v", result.Errors)
+	}
+
+	var warned bool
+	for _, warning := range result.Warnings {
+		if strings.Contains(warning.Message, "2 dates without a year were read as 2023") {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Errorf("Expected a year inference warning, got %+v", result.Warnings)
+	}
+	if result.Title() != "Sales Report 2023" {
+		t.Errorf("Expected title %q, got %q", "Sales Report 2023", result.Title())
+	}
+
+	// Without a year nearby the date is rejected
+	result, err = NewHTMLTableParser().ParseHTML(table[strings.Index(table, "<table>"):])
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if result.SuccessCount != 0 {
+		t.Errorf("Expected yearless dates to fail without context, got %+v", result.Records)
+	}
+
+	// The streaming parser reads the heading and caption too
+	records := make(chan models.CreateSalesRecordRequest, 10)
+	streamed, err := NewHTMLTableParser().ParseHTMLStream(context.Background(), strings.NewReader(table), records)
+	if err != nil {
+		t.Fatalf("ParseHTMLStream failed: %v", err)
+	}
+	if streamed.SuccessCount != 2 || streamed.Title() != "Sales Report 2023" {
+		t.Errorf("Expected 2 streamed records titled from the heading, got %d titled %q", streamed.SuccessCount, streamed.Title())
+	}
+	if record := <-records; record.Date != "2023-03-15" {
+		t.Errorf("Expected the streamed date in 2023, got %s", record.Date)
+	}
+}
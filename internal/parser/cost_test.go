@@ -0,0 +1,60 @@
+package parser
+
+import "testing"
+
+func TestParseHTML_CostAndCategory(t *testing.T) {
+	// "Item Cost" and "Purchase Price" contain names of the description and
+	// sale price fields, and must not be taken for them
+	table := `<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Item Cost</th><th>Item</th><th>Price</th><th>Category</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-03-15</td><td>$12.50</td><td>Lamp</td><td>40.00</td><td>Lighting</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-03-16</td><td>n/a</td><td>Chair</td><td>75.00</td><td></td></tr>
+	</table>`
+
+	for i := 0; i < 10; i++ { // Fields are matched in map order
+		result, err := NewHTMLTableParser().ParseHTML(table)
+		if err != nil {
+			t.Fatalf("ParseHTML failed: %v", err)
+		}
+		if result.ColumnMapping["cost"] != 3 || result.ColumnMapping["description"] != 4 ||
+			result.ColumnMapping["sale_price"] != 5 || result.ColumnMapping["category"] != 6 {
+			t.Fatalf("Unexpected column mapping: %v", result.ColumnMapping)
+		}
+		if match := result.ColumnMatches["cost"]; match.Method != MatchExact || match.Synonym != "item cost" {
+			t.Errorf("Expected an exact match for cost, got %+v", match)
+		}
+
+		lamp, chair := result.Records[0], result.Records[1]
+		if lamp.Description != "Lamp" || lamp.SalePrice != 40.00 || lamp.Cost == nil || *lamp.Cost != 12.50 ||
+			lamp.Category == nil || *lamp.Category != "Lighting" {
+			t.Errorf("Unexpected lamp: %+v", lamp)
+		}
+		// An unreadable cost is unknown, not an error
+		if chair.Cost != nil || chair.Category != nil || result.ErrorCount != 0 {
+			t.Errorf("Expected the chair's cost and category to be unknown, got %+v", chair)
+		}
+		var warned bool
+		for _, w := range result.Warnings {
+			warned = warned || (w.Row == 3 && w.Column == "cost")
+		}
+		if !warned {
+			t.Errorf("Expected a warning for the chair's cost, got %+v", result.Warnings)
+		}
+	}
+}
+
+func TestParseHTML_PurchasePriceIsCost(t *testing.T) {
+	table := `<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Purchase Price</th><th>Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-03-15</td><td>Lamp</td><td>10.00</td><td>40.00</td></tr>
+	</table>`
+
+	result, err := NewHTMLTableParser().ParseHTML(table)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	record := result.Records[0]
+	if record.SalePrice != 40.00 || record.Cost == nil || *record.Cost != 10.00 {
+		t.Errorf("Expected a 40.00 sale of a 10.00 item, got %+v", record)
+	}
+}
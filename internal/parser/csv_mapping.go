@@ -0,0 +1,150 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"sales-track/internal/models"
+)
+
+// CSVColumn is a column of a delimited file as the mapping wizard shows it
+type CSVColumn struct {
+	Index          int      `json:"index"` // 1-based position, as in ColumnOverride
+	Header         string   `json:"header"`
+	Samples        []string `json:"samples"`                   // Values of the first rows, empty ones included
+	Type           string   `json:"type"`                      // One of ColumnTypes, guessed from the samples
+	SuggestedField string   `json:"suggested_field,omitempty"` // Field header matching would give the column, if any
+	Confidence     int      `json:"confidence,omitempty"`      // Percentage, as in ColumnMatch
+}
+
+// CSVInspection describes a delimited file for the mapping wizard: its
+// columns, and the fields they can be assigned
+type CSVInspection struct {
+	Delimiter      string      `json:"delimiter"`
+	Columns        []CSVColumn `json:"columns"`
+	Fields         []string    `json:"fields"`          // Fields a column can hold, sorted
+	RequiredFields []string    `json:"required_fields"` // Fields every import needs, from a column or the import's defaults
+}
+
+// InspectCSV reads the header and first rows of a delimited file, detecting
+// its delimiter, and describes each column with sample values, a type guess
+// and the field header matching suggests
+func InspectCSV(data string) (*CSVInspection, error) {
+	data = strings.TrimSpace(data)
+	if data == "" {
+		return nil, fmt.Errorf("no data provided")
+	}
+	if strings.Contains(strings.ToLower(data), "<tr") {
+		return nil, fmt.Errorf("data is an HTML table, not a delimited file")
+	}
+
+	rows, delimiter := sampleRows(data)
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("a delimited file needs a header row and at least one data row")
+	}
+
+	headers := rows[0]
+	suggested := matchHeaders(headers)
+	fields := make(map[int]string, len(suggested))
+	for field, idx := range suggested {
+		fields[idx] = field
+	}
+
+	p := NewHTMLTableParser()
+	inspection := &CSVInspection{Delimiter: string(delimiter), Fields: importFields(), RequiredFields: requiredColumns}
+	for i, header := range headers {
+		column := CSVColumn{Index: i + 1, Header: strings.TrimSpace(header), Samples: []string{}}
+		for _, row := range rows[1:] {
+			if i < len(row) {
+				column.Samples = append(column.Samples, strings.TrimSpace(row[i]))
+			}
+		}
+
+		column.Type = p.detectDataType(column.Samples)
+		if column.Type == "unknown" {
+			column.Type = ColumnTypeText
+		}
+		if field, ok := fields[i]; ok {
+			column.SuggestedField = field
+			_, _, column.Confidence = synonymMatch(field, column.Header)
+		}
+		inspection.Columns = append(inspection.Columns, column)
+	}
+	return inspection, nil
+}
+
+// importFields returns the fields a column can be mapped to, sorted
+func importFields() []string {
+	fields := make([]string, 0, len(ColumnMapping)+len(ExactColumnMapping))
+	for field := range ColumnMapping {
+		fields = append(fields, field)
+	}
+	for field := range ExactColumnMapping {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// ValidateCSVMapping checks that a mapping has a single-character delimiter
+// and assigns known fields to named columns, each field to one column only.
+// Required fields are checked when a file is parsed, as the import's
+// defaults can supply them.
+func ValidateCSVMapping(mapping models.CSVMapping) error {
+	delimiter, size := utf8.DecodeRuneInString(mapping.Delimiter)
+	if size == 0 || size != len(mapping.Delimiter) || delimiter == '"' || delimiter == '\r' || delimiter == '\n' || delimiter == utf8.RuneError {
+		return fmt.Errorf("mapping delimiter must be a single character other than a quote or line break")
+	}
+	if len(mapping.Columns) == 0 {
+		return fmt.Errorf("mapping must assign a field to at least one column")
+	}
+
+	headers := make(map[string]bool, len(mapping.Columns))
+	columns := make(map[string]string, len(mapping.Columns))
+	for header, field := range mapping.Columns {
+		normalized := strings.ToLower(strings.TrimSpace(header))
+		switch {
+		case normalized == "":
+			return fmt.Errorf("mapping assigns %s to a column without a header", field)
+		case headers[normalized]:
+			return fmt.Errorf("mapping names column %q more than once", header)
+		case !isField(field):
+			return fmt.Errorf("mapping assigns unknown field %q to column %q", field, header)
+		case columns[field] != "":
+			return fmt.Errorf("mapping assigns %s to both %q and %q", field, columns[field], header)
+		}
+		headers[normalized] = true
+		columns[field] = header
+	}
+	return nil
+}
+
+// SetCSVMapping configures the parser to read a delimited file with the
+// fields a mapping assigns to its columns, in place of a built-in layout
+func (p *HTMLTableParser) SetCSVMapping(mapping models.CSVMapping) error {
+	if err := ValidateCSVMapping(mapping); err != nil {
+		return err
+	}
+
+	name := mapping.Name
+	if name == "" {
+		name = "custom"
+	}
+	delimiter, _ := utf8.DecodeRuneInString(mapping.Delimiter)
+	layout := Layout{
+		Name:      name,
+		Label:     name,
+		Delimiter: delimiter,
+		Headers:   make(map[string]string, len(mapping.Columns)),
+	}
+	for header, field := range mapping.Columns {
+		layout.Headers[strings.ToLower(strings.TrimSpace(header))] = field
+	}
+
+	p.Layout = &layout
+	p.UsePositionalMapping = false
+	p.PositionalColumns = nil
+	return nil
+}
@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"sales-track/internal/models"
+)
+
+const wizardCSV = `Shop;Maker;When;What;Paid;Cut;Item Cost
+"Harbor Gifts";Maple Lane Pottery;2024-03-15;Stoneware Bowl;$64.00;$19.20;20.00
+Harbor Gifts;Oak & Pine;2024-03-16;Cutting Board;$35.00;$10.50;`
+
+// TestInspectCSV tests the columns, samples and suggestions the mapping
+// wizard is shown
+func TestInspectCSV(t *testing.T) {
+	inspection, err := InspectCSV(wizardCSV)
+	if err != nil {
+		t.Fatalf("InspectCSV failed: %v", err)
+	}
+	if inspection.Delimiter != ";" || len(inspection.Columns) != 7 {
+		t.Fatalf("Expected 7 columns split on ';', got %q and %+v", inspection.Delimiter, inspection.Columns)
+	}
+
+	shop := inspection.Columns[0]
+	if shop.Index != 1 || shop.Header != "Shop" || shop.SuggestedField != "store" || shop.Confidence == 0 {
+		t.Errorf("Expected Shop suggested as the store, got %+v", shop)
+	}
+	if when := inspection.Columns[2]; when.Type != ColumnTypeDate || when.SuggestedField != "" {
+		t.Errorf("Expected When typed as a date with no suggestion, got %+v", when)
+	}
+	if paid := inspection.Columns[4]; paid.Type != ColumnTypeCurrency || len(paid.Samples) != 2 || paid.Samples[0] != "$64.00" {
+		t.Errorf("Expected Paid typed as currency with both samples, got %+v", paid)
+	}
+	if cost := inspection.Columns[6]; cost.SuggestedField != "cost" {
+		t.Errorf("Expected Item Cost suggested as the cost, got %+v", cost)
+	}
+	if !strings.Contains(strings.Join(inspection.Fields, ","), "sale_price") || len(inspection.RequiredFields) != len(requiredColumns) {
+		t.Errorf("Expected the assignable and required fields, got %v and %v", inspection.Fields, inspection.RequiredFields)
+	}
+
+	if _, err := InspectCSV("<tr><td>a</td></tr>"); err == nil {
+		t.Error("Expected an HTML table to be refused")
+	}
+	if _, err := InspectCSV("Shop,Maker"); err == nil {
+		t.Error("Expected a file without data rows to be refused")
+	}
+}
+
+// TestCSVMapping tests parsing a file with the fields assigned in the wizard
+func TestCSVMapping(t *testing.T) {
+	mapping := models.CSVMapping{Delimiter: ";", Columns: map[string]string{
+		"shop": "store", "Maker": "vendor", "When": "date", "What": "description", "Paid": "sale_price", "Cut": "commission",
+	}}
+
+	p := NewHTMLTableParser()
+	if err := p.SetCSVMapping(mapping); err != nil {
+		t.Fatalf("SetCSVMapping failed: %v", err)
+	}
+	result, err := p.ParseHTML(wizardCSV)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if len(result.Records) != 2 {
+		t.Fatalf("Expected 2 records, got %d: %+v", len(result.Records), result.Errors)
+	}
+	record := result.Records[0]
+	if record.Store != "Harbor Gifts" || record.Vendor != "Maple Lane Pottery" || record.SalePrice != 64.00 || record.Commission == nil || *record.Commission != 19.20 {
+		t.Errorf("Expected the mapped fields read, got %+v", record)
+	}
+	if record.Cost != nil {
+		t.Errorf("Expected the unassigned Item Cost column left out, got %v", *record.Cost)
+	}
+
+	// Required fields can come from the import's defaults instead
+	delete(mapping.Columns, "shop")
+	p = NewHTMLTableParser()
+	p.SetCSVMapping(mapping)
+	if _, err := p.ParseHTML(wizardCSV); err == nil || !strings.Contains(err.Error(), "store") {
+		t.Errorf("Expected the missing store reported, got %v", err)
+	}
+	p.Defaults = FieldDefaults{Store: "Harbor Gifts"}
+	if result, err := p.ParseHTML(wizardCSV); err != nil || len(result.Records) != 2 {
+		t.Errorf("Expected the default store used, got %v", err)
+	}
+
+	for _, invalid := range []models.CSVMapping{
+		{Delimiter: "", Columns: map[string]string{"Paid": "sale_price"}},
+		{Delimiter: ";;", Columns: map[string]string{"Paid": "sale_price"}},
+		{Delimiter: ";"},
+		{Delimiter: ";", Columns: map[string]string{"Paid": "price"}},
+		{Delimiter: ";", Columns: map[string]string{"Paid": "sale_price", "Cut": "sale_price"}},
+		{Delimiter: ";", Columns: map[string]string{"Paid": "sale_price", " paid ": "commission"}},
+	} {
+		if err := ValidateCSVMapping(invalid); err == nil {
+			t.Errorf("Expected %+v to be refused", invalid)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"regexp"
+	"time"
+)
+
+// ordinalPattern matches a day written as an ordinal, such as "15th"
+var ordinalPattern = regexp.MustCompile(`(?i)\b(\d{1,2})(?:st|nd|rd|th)\b`)
+
+// twoDigitYearFormats are read from older exports that shorten the year, such
+// as "3/15/24" and "15-Mar-24". The century is chosen by expandYear.
+var twoDigitYearFormats = []string{
+	"1/2/06",
+	"2-Jan-06",
+	"2 Jan 06",
+	"Jan-2-06", // eBay
+	"Jan 2, 06",
+}
+
+// twoDigitYearLead is how many years after the reference year a two-digit
+// year may fall before it is read as the previous century
+const twoDigitYearLead = 10
+
+// stripOrdinals turns "March 15th, 2024" into "March 15, 2024"
+func stripOrdinals(dateStr string) string {
+	return ordinalPattern.ReplaceAllString(dateStr, "$1")
+}
+
+// parseTwoDigitYearDate reads a date with a two-digit year, placing it in
+// the century closest to the year named near the table, or the current year
+// when none is
+func (p *HTMLTableParser) parseTwoDigitYearDate(dateStr string) (string, bool) {
+	reference := p.contextYear
+	if reference == 0 {
+		reference = time.Now().Year()
+	}
+
+	for _, format := range twoDigitYearFormats {
+		parsed, err := time.Parse(format, dateStr)
+		if err != nil {
+			continue
+		}
+		year := expandYear(parsed.Year()%100, reference)
+		date := time.Date(year, parsed.Month(), parsed.Day(), 0, 0, 0, 0, time.UTC)
+		if date.Day() != parsed.Day() {
+			return "", false // February 29 moved to a year that is not a leap year
+		}
+		return date.Format("2006-01-02"), true
+	}
+	return "", false
+}
+
+// expandYear places a two-digit year in the 100 years ending
+// twoDigitYearLead years after reference, so that with a reference of 2026
+// "24" is 2024, "36" is 2036 and "98" is 1998
+func expandYear(yy, reference int) int {
+	year := reference - reference%100 + yy
+	switch {
+	case year > reference+twoDigitYearLead:
+		year -= 100
+	case year <= reference+twoDigitYearLead-100:
+		year += 100
+	}
+	return year
+}
@@ -0,0 +1,49 @@
+package parser
+
+import "testing"
+
+func TestExpandYear(t *testing.T) {
+	testCases := []struct {
+		yy, reference, expected int
+	}{
+		{24, 2026, 2024},
+		{36, 2026, 2036},
+		{37, 2026, 1937},
+		{98, 2026, 1998},
+		{0, 2026, 2000},
+		{5, 1995, 2005},
+		{6, 1995, 1906},
+	}
+
+	for _, tc := range testCases {
+		if year := expandYear(tc.yy, tc.reference); year != tc.expected {
+			t.Errorf("expandYear(%d, %d) = %d, expected %d", tc.yy, tc.reference, year, tc.expected)
+		}
+	}
+}
+
+func TestParseHTML_TwoDigitYearsUseContext(t *testing.T) {
+	// A report titled 1999 reads "01" as 2001 and "85" as 1985, and yearless
+	// dates with ordinals take the year too
+	table := `<h2>Sales 1999</h2>
+	<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>1/2/01</td><td>Lamp</td><td>40.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>15-Mar-85</td><td>Chair</td><td>75.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>Mar 3rd</td><td>Rug</td><td>20.00</td></tr>
+	</table>`
+
+	result, err := NewHTMLTableParser().ParseHTML(table)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	expected := []string{"2001-01-02", "1985-03-15", "1999-03-03"}
+	if len(result.Records) != len(expected) {
+		t.Fatalf("Expected %d records, got %d: %+v", len(expected), len(result.Records), result.Errors)
+	}
+	for i, date := range expected {
+		if result.Records[i].Date != date {
+			t.Errorf("Expected record %d dated %s, got %s", i, date, result.Records[i].Date)
+		}
+	}
+}
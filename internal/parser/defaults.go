@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// FieldDefaults are values for fields a report leaves out, set alongside the
+// column mapping of an import. They let a seller with a single store import
+// reports that have no store column.
+type FieldDefaults struct {
+	Store          string   `json:"store,omitempty"`
+	Vendor         string   `json:"vendor,omitempty"`
+	CommissionRate *float64 `json:"commission_rate,omitempty"` // Percent of the sale price, for rows without a commission
+}
+
+// Validate checks that the defaults can be applied
+func (d FieldDefaults) Validate() error {
+	if d.CommissionRate != nil && (*d.CommissionRate < 0 || *d.CommissionRate > 100 || math.IsNaN(*d.CommissionRate)) {
+		return fmt.Errorf("default commission rate must be between 0 and 100")
+	}
+	return nil
+}
+
+// value returns the default of a text column, if one is set
+func (d FieldDefaults) value(column string) (string, bool) {
+	switch column {
+	case "store":
+		return d.Store, d.Store != ""
+	case "vendor":
+		return d.Vendor, d.Vendor != ""
+	}
+	return "", false
+}
+
+// covers reports whether the defaults supply column, so its absence need not
+// be reported
+func (d FieldDefaults) covers(column string) bool {
+	if column == "commission" {
+		return d.CommissionRate != nil
+	}
+	_, ok := d.value(column)
+	return ok
+}
+
+// defaultCommission returns the commission on a sale price at the default
+// rate, rounded to cents
+func (d FieldDefaults) defaultCommission(salePrice float64) float64 {
+	return math.Round(salePrice**d.CommissionRate) / 100
+}
+
+// useDefault counts a field filled from the defaults in the table being
+// parsed
+func (p *HTMLTableParser) useDefault(column string) {
+	if p.defaultsUsed == nil {
+		p.defaultsUsed = make(map[string]int)
+	}
+	p.defaultsUsed[column]++
+}
+
+// defaultsWarnings reports how many records of the table just parsed took
+// each default, and starts counting afresh for the next table
+func (p *HTMLTableParser) defaultsWarnings() []ParseWarning {
+	var warnings []ParseWarning
+	for _, column := range []string{"store", "vendor"} {
+		if count := p.defaultsUsed[column]; count > 0 {
+			value, _ := p.Defaults.value(column)
+			warnings = append(warnings, ParseWarning{
+				Column:  column,
+				Message: fmt.Sprintf("%d records without a %s were recorded for the default %q", count, column, value),
+			})
+		}
+	}
+	if count := p.defaultsUsed["commission"]; count > 0 {
+		warnings = append(warnings, ParseWarning{
+			Column:  "commission",
+			Message: fmt.Sprintf("%d records without a commission were charged the default rate of %s%%", count, strconv.FormatFloat(*p.Defaults.CommissionRate, 'f', -1, 64)),
+		})
+	}
+	p.defaultsUsed = nil
+	return warnings
+}
@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseHTML_Defaults tests that defaults fill fields a report leaves out
+// or blank and are reported in warnings
+func TestParseHTML_Defaults(t *testing.T) {
+	htmlData := `<table>
+		<tr><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th><th>Commission</th></tr>
+		<tr><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>$40.00</td><td>$10.00</td></tr>
+		<tr><td></td><td>2024-01-16</td><td>Chair</td><td>$25.50</td><td></td></tr>
+	</table>`
+	rate := 15.0
+	defaults := FieldDefaults{Store: "Main Street", Vendor: "House", CommissionRate: &rate}
+
+	// Without defaults the report has no store column to import
+	if _, err := NewHTMLTableParser().ParseHTML(htmlData); err == nil {
+		t.Fatal("Expected a report without a store column to fail")
+	}
+
+	parser := NewHTMLTableParser()
+	parser.Defaults = defaults
+	result, err := parser.ParseHTML(htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if len(result.Records) != 2 {
+		t.Fatalf("Expected 2 records, got %d: %+v", len(result.Records), result.Errors)
+	}
+
+	first, second := result.Records[0], result.Records[1]
+	if first.Store != "Main Street" || second.Store != "Main Street" {
+		t.Errorf("Expected the default store, got %q and %q", first.Store, second.Store)
+	}
+	if first.Vendor != "Vendor 1" || second.Vendor != "House" {
+		t.Errorf("Expected the default vendor only for the blank cell, got %q and %q", first.Vendor, second.Vendor)
+	}
+	if first.Commission == nil || *first.Commission != 10.00 {
+		t.Errorf("Expected the reported commission to be kept, got %v", first.Commission)
+	}
+	if second.Commission == nil || *second.Commission != 3.83 {
+		t.Errorf("Expected a commission of 3.83 at the default rate, got %v", second.Commission)
+	}
+
+	messages := make(map[string]string)
+	for _, warning := range result.Warnings {
+		messages[warning.Column] = warning.Message
+	}
+	for column, want := range map[string]string{
+		"store":      `2 records without a store were recorded for the default "Main Street"`,
+		"vendor":     `1 records without a vendor were recorded for the default "House"`,
+		"commission": "1 records without a commission were charged the default rate of 15%",
+	} {
+		if messages[column] != want {
+			t.Errorf("Expected %s warning %q, got %q", column, want, messages[column])
+		}
+	}
+
+	parser = NewHTMLTableParser()
+	parser.Defaults = defaults
+	streamResult, streamed, err := collectStream(t, parser, htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTMLStream failed: %v", err)
+	}
+	if len(streamed) != 2 || streamed[1].Store != "Main Street" || streamed[1].Vendor != "House" {
+		t.Errorf("Expected streamed records to take the defaults, got %+v", streamed)
+	}
+	if len(streamResult.Warnings) != len(result.Warnings) {
+		t.Errorf("Expected the stream to warn like ParseHTML, got %+v", streamResult.Warnings)
+	}
+}
+
+// TestParseHTML_DefaultsOverrideLayout tests that an import's defaults take
+// precedence over those of a built-in layout
+func TestParseHTML_DefaultsOverrideLayout(t *testing.T) {
+	data := "Location,Date,Item,Net Sales,Event Type\nMarket,2024-03-01,Mug,12.00,Payment\n"
+
+	parser := NewHTMLTableParser()
+	if err := parser.SetLayout("square"); err != nil {
+		t.Fatalf("SetLayout failed: %v", err)
+	}
+	parser.Defaults = FieldDefaults{Vendor: "Pottery Co"}
+	result, err := parser.ParseHTML(data)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if len(result.Records) != 1 || result.Records[0].Vendor != "Pottery Co" {
+		t.Errorf("Expected the import's default vendor, got %+v", result.Records)
+	}
+}
+
+func TestFieldDefaults_Validate(t *testing.T) {
+	for _, rate := range []float64{-1, 100.5} {
+		if err := (FieldDefaults{CommissionRate: &rate}).Validate(); err == nil || !strings.Contains(err.Error(), "commission rate") {
+			t.Errorf("Expected rate %v to be rejected, got %v", rate, err)
+		}
+	}
+	rate := 0.0
+	if err := (FieldDefaults{CommissionRate: &rate}).Validate(); err != nil {
+		t.Errorf("Expected a zero rate to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,251 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// AutoLayout is the layout name that asks the importer to detect the layout
+const AutoLayout = "auto"
+
+// MinAutoDetectConfidence is the confidence a match needs to be applied
+// automatically; weaker matches are only suggestions
+const MinAutoDetectConfidence = 0.6
+
+// detectSampleRows is the number of rows inspected when fingerprinting data
+const detectSampleRows = 6
+
+// LayoutMatch is a built-in layout suggested for pasted data
+type LayoutMatch struct {
+	Layout     string  `json:"layout"`
+	Confidence float64 `json:"confidence"` // 0 to 1
+	Reason     string  `json:"reason"`
+}
+
+// DetectLayout fingerprints the headers, column count and date style of data
+// and returns the built-in layouts that can import it, most likely first.
+// Only the first few rows are inspected, so data may be a prefix of a larger
+// export. An empty result means no layout fits and generic header matching
+// should be used.
+func DetectLayout(data string) []LayoutMatch {
+	rows, delimiter := sampleRows(strings.TrimSpace(data))
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var matches []LayoutMatch
+	for _, layout := range Layouts() {
+		var match *LayoutMatch
+		if len(layout.Positional) > 0 {
+			match = matchPositionalLayout(layout, data, rows)
+		} else {
+			match = matchHeaderLayout(layout, rows, delimiter)
+		}
+		if match != nil {
+			matches = append(matches, *match)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Confidence > matches[j].Confidence
+	})
+	return matches
+}
+
+// sampleRows returns the first rows of data and, for delimited text, the
+// delimiter they were split on. HTML rows report a delimiter of 0.
+func sampleRows(data string) ([][]string, rune) {
+	lower := strings.ToLower(data)
+	if strings.Contains(lower, "<tr") {
+		return sampleHTMLRows(data), 0
+	}
+
+	delimiter := ','
+	firstLine := data
+	if i := strings.IndexByte(data, '\n'); i >= 0 {
+		firstLine = data[:i]
+	}
+	if strings.Contains(firstLine, "\t") {
+		delimiter = '\t'
+	} else if !strings.Contains(firstLine, ",") && strings.Contains(firstLine, "|") {
+		delimiter = '|'
+	} else if !strings.Contains(firstLine, ",") && strings.Contains(firstLine, ";") {
+		delimiter = ';'
+	}
+
+	reader := newDelimitedReader(strings.NewReader(data), delimiter)
+	var rows [][]string
+	for len(rows) < detectSampleRows {
+		cells, err := readDelimitedRow(reader, len(rows) == 0)
+		if err != nil {
+			// io.EOF, or a row cut off at the end of a sample
+			break
+		}
+		rows = append(rows, cells)
+	}
+	return rows, delimiter
+}
+
+// sampleHTMLRows collects the cell text of the first rows in an HTML fragment
+func sampleHTMLRows(data string) [][]string {
+	tokenizer := html.NewTokenizer(strings.NewReader(data))
+
+	var (
+		rows   [][]string
+		row    []string
+		inCell bool
+		cell   strings.Builder
+	)
+	for len(rows) < detectSampleRows {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if tokenizer.Err() == io.EOF && len(row) > 0 {
+				rows = append(rows, row)
+			}
+			return rows
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "tr":
+				if len(row) > 0 {
+					rows = append(rows, row)
+				}
+				row = nil
+			case "td", "th":
+				inCell = true
+				cell.Reset()
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "td", "th":
+				if inCell {
+					row = append(row, strings.TrimSpace(cell.String()))
+					inCell = false
+				}
+			case "tr":
+				if len(row) > 0 {
+					rows = append(rows, row)
+				}
+				row = nil
+			}
+		case html.TextToken:
+			if inCell {
+				cell.Write(tokenizer.Text())
+			}
+		}
+	}
+	return rows
+}
+
+// matchHeaderLayout scores a header layout by the share of its mapped and
+// signature headers present in the first row. Layouts whose required columns
+// are missing cannot import the data and are not suggested.
+func matchHeaderLayout(layout Layout, rows [][]string, delimiter rune) *LayoutMatch {
+	// Text split on another delimiter would be read as a single column
+	if delimiter != 0 && delimiter != layout.Delimiter {
+		return nil
+	}
+
+	p := NewHTMLTableParser()
+	p.Layout = &layout
+	mapping, err := p.createLayoutMapping(rows[0])
+	if err != nil {
+		return nil
+	}
+
+	headers := make(map[string]bool, len(rows[0]))
+	for _, header := range rows[0] {
+		headers[strings.ToLower(strings.TrimSpace(header))] = true
+	}
+	signature := 0
+	for _, header := range layout.Signature {
+		if headers[header] {
+			signature++
+		}
+	}
+
+	confidence := 0.6 * float64(len(mapping)) / float64(len(layout.Headers))
+	if len(layout.Signature) > 0 {
+		confidence += 0.4 * float64(signature) / float64(len(layout.Signature))
+	}
+	reason := fmt.Sprintf("matched %d of %d columns and %d of %d signature headers",
+		len(mapping), len(layout.Headers), signature, len(layout.Signature))
+
+	// A date the layout cannot read suggests a look-alike export
+	if len(rows) > 1 {
+		if idx, ok := mapping["date"]; ok && idx < len(rows[1]) {
+			if _, err := p.parseDate(strings.TrimSpace(rows[1][idx])); err != nil {
+				confidence /= 2
+				reason += fmt.Sprintf("; date %q not recognized", rows[1][idx])
+			}
+		}
+	}
+
+	return &LayoutMatch{Layout: layout.Name, Confidence: roundConfidence(confidence), Reason: reason}
+}
+
+// matchPositionalLayout scores a headerless layout by whether the first row of
+// bare table rows already holds data in the expected columns
+func matchPositionalLayout(layout Layout, data string, rows [][]string) *LayoutMatch {
+	p := NewHTMLTableParser()
+	// Positional layouts rely on the synthetic header added to bare rows
+	if !p.looksLikeTableRows(data) {
+		return nil
+	}
+
+	columns := make(map[string]int, len(layout.Positional))
+	for i, col := range layout.Positional {
+		columns[col] = i
+	}
+	cellAt := func(row []string, col string) (string, bool) {
+		idx, ok := columns[col]
+		if !ok || idx >= len(row) {
+			return "", false
+		}
+		return strings.TrimSpace(row[idx]), true
+	}
+
+	first := rows[0]
+	if len(first) < len(requiredColumns) {
+		return nil
+	}
+	date, _ := cellAt(first, "date")
+	if _, err := p.parseDate(date); err != nil {
+		return nil
+	}
+	price, _ := cellAt(first, "sale_price")
+	if _, err := p.parseCurrency(price); err != nil || price == "" {
+		return nil
+	}
+
+	confidence := 0.5
+	reason := "first row holds a date and a price in the expected columns"
+	if len(first) == len(layout.Positional) {
+		confidence += 0.3
+		reason += fmt.Sprintf("; %d columns", len(first))
+	}
+	amounts := 0
+	for _, col := range optionalAmountColumns {
+		if value, ok := cellAt(first, col); ok && value != "" {
+			if _, err := p.parseCurrency(value); err == nil {
+				amounts++
+			}
+		}
+	}
+	if amounts == len(optionalAmountColumns) {
+		confidence += 0.15
+		reason += "; commission and remaining amounts present"
+	}
+
+	return &LayoutMatch{Layout: layout.Name, Confidence: roundConfidence(confidence), Reason: reason}
+}
+
+// roundConfidence rounds a confidence to two decimal places
+func roundConfidence(confidence float64) float64 {
+	return float64(int(confidence*100+0.5)) / 100
+}
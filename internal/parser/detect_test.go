@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestDetectLayout_Fixtures tests that every sample export is detected as its own layout
+func TestDetectLayout_Fixtures(t *testing.T) {
+	fixtures := map[string]string{
+		"consignable": "consignable.html",
+		"square":      "square.csv",
+		"shopify":     "shopify.csv",
+		"etsy":        "etsy.csv",
+		"ebay":        "ebay.csv",
+	}
+
+	for layout, fixture := range fixtures {
+		t.Run(layout, func(t *testing.T) {
+			matches := DetectLayout(readFixture(t, fixture))
+			if len(matches) == 0 {
+				t.Fatal("Expected a layout match")
+			}
+			if matches[0].Layout != layout {
+				t.Errorf("Expected %s, got %+v", layout, matches)
+			}
+			if matches[0].Confidence < MinAutoDetectConfidence {
+				t.Errorf("Expected a confident match, got %+v", matches[0])
+			}
+		})
+	}
+}
+
+// TestDetectLayout_Prefix tests detection on a sample cut off mid-row
+func TestDetectLayout_Prefix(t *testing.T) {
+	data := readFixture(t, "shopify.csv")
+	matches := DetectLayout(data[:len(data)/2])
+	if len(matches) == 0 || matches[0].Layout != "shopify" {
+		t.Errorf("Expected shopify from a prefix, got %+v", matches)
+	}
+}
+
+// TestDetectLayout_NoMatch tests that generic data suggests no layout
+func TestDetectLayout_NoMatch(t *testing.T) {
+	inputs := map[string]string{
+		"html table": basicTableHTML,
+		"tab-delimited": "Store\tVendor\tDate\tDescription\tSale Price\n" +
+			"Store A\tVendor 1\t2024-01-15\tLamp\t10.00\n",
+		"empty": "   ",
+	}
+
+	for name, data := range inputs {
+		if matches := DetectLayout(data); len(matches) != 0 {
+			t.Errorf("%s: expected no matches, got %+v", name, matches)
+		}
+	}
+}
+
+// TestDetectLayout_Confidence tests that partial matches rank lower
+func TestDetectLayout_Confidence(t *testing.T) {
+	// Only the mapped eBay headers, without its signature headers
+	minimal := "Item Title,Sold For,Sale Date,Transaction ID\nLamp,$10.00,Jun-14-24,1\n"
+	matches := DetectLayout(minimal)
+	if len(matches) != 1 || matches[0].Layout != "ebay" {
+		t.Fatalf("Expected a single ebay match, got %+v", matches)
+	}
+	if matches[0].Confidence != 0.6 {
+		t.Errorf("Expected confidence 0.6 without signature headers, got %+v", matches[0])
+	}
+
+	// A date the layout cannot read halves the confidence
+	badDate := strings.Replace(minimal, "Jun-14-24", "14th of June", 1)
+	matches = DetectLayout(badDate)
+	if len(matches) != 1 || matches[0].Confidence != 0.3 || !strings.Contains(matches[0].Reason, "not recognized") {
+		t.Errorf("Expected a halved confidence for an unreadable date, got %+v", matches)
+	}
+
+	// Headerless rows with fewer columns than Consignable are a weaker match
+	rows := `<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>$10.00</td></tr>`
+	matches = DetectLayout(rows)
+	if len(matches) != 1 || matches[0].Layout != "consignable" || matches[0].Confidence >= MinAutoDetectConfidence {
+		t.Errorf("Expected a weak consignable match, got %+v", matches)
+	}
+}
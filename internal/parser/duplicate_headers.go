@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// duplicateSampleRows is how many data rows are read to tell apart columns
+// whose headers match the same field
+const duplicateSampleRows = 5
+
+// fieldDataTypes are the types detectDataType finds in the columns of fields
+// that hold a particular kind of value
+var fieldDataTypes = map[string][]string{
+	"date":       {"date"},
+	"sale_price": {"currency", "number"},
+	"commission": {"currency", "number"},
+	"remaining":  {"currency", "number"},
+}
+
+// resolveDuplicateHeaders settles fields whose header matches more than one
+// column, such as a table with both a sale date and a payout date. Header
+// matching takes whichever column it finds first, so the columns are
+// compared again: a column whose sampled values have the field's type is
+// preferred, then one whose header is exactly one of the field's names, and
+// otherwise the column already chosen is kept. mapping is updated, and each
+// field with more than one match gets a warning naming the columns and the
+// one used. rows are the table's data rows, of which the first few are
+// sampled.
+func (p *HTMLTableParser) resolveDuplicateHeaders(headers []string, mapping map[string]int, rows [][]string) []ParseWarning {
+	if p.UsePositionalMapping || (p.Layout != nil && len(p.Layout.Headers) > 0) {
+		return nil
+	}
+	rows = rows[:min(len(rows), duplicateSampleRows)]
+
+	// Columns given a field or a type by the overrides are settled
+	forcedFields, forcedTypes, _ := p.overriddenColumns(headers)
+
+	used := make(map[int]bool, len(mapping))
+	for _, idx := range mapping {
+		used[idx] = true
+	}
+
+	var warnings []ParseWarning
+	for _, field := range slices.Sorted(maps.Keys(ColumnMapping)) {
+		chosen, mapped := mapping[field]
+		if _, forced := forcedFields[field]; !mapped || forced {
+			continue
+		}
+		candidates := []int{chosen}
+		for i, header := range headers {
+			if _, typed := forcedTypes[i]; !used[i] && !typed && headerMatches(field, header) {
+				candidates = append(candidates, i)
+			}
+		}
+		if len(candidates) == 1 {
+			continue
+		}
+		slices.Sort(candidates)
+
+		best, reason := p.preferColumn(field, headers, chosen, candidates, rows)
+		mapping[field] = best
+		delete(used, chosen)
+		used[best] = true
+
+		names := make([]string, len(candidates))
+		for i, idx := range candidates {
+			names[i] = fmt.Sprintf("%q (column %d)", strings.TrimSpace(headers[idx]), idx+1)
+		}
+		warnings = append(warnings, ParseWarning{
+			Column:  field,
+			Message: fmt.Sprintf("%d columns match %s: %s; using column %d, %s", len(candidates), field, strings.Join(names, ", "), best+1, reason),
+		})
+	}
+	return warnings
+}
+
+// preferColumn picks the column for field among candidates, in column
+// order, and says why. Without a column of the field's type or a header
+// naming the field exactly, the column header matching chose is kept.
+func (p *HTMLTableParser) preferColumn(field string, headers []string, chosen int, candidates []int, rows [][]string) (int, string) {
+	if types, typed := fieldDataTypes[field]; typed && len(rows) > 0 {
+		var fitting []int
+		for _, idx := range candidates {
+			values := make([]string, 0, len(rows))
+			for _, row := range rows {
+				if idx < len(row) {
+					values = append(values, row[idx])
+				}
+			}
+			if slices.Contains(types, p.detectDataType(values)) {
+				fitting = append(fitting, idx)
+			}
+		}
+		if len(fitting) == 1 {
+			return fitting[0], fmt.Sprintf("the only one holding %s values", types[0])
+		}
+		if len(fitting) > 1 {
+			candidates = fitting
+		}
+	}
+
+	exact := func(idx int) bool {
+		return slices.Contains(ColumnMapping[field], strings.ToLower(strings.TrimSpace(headers[idx])))
+	}
+	if slices.Contains(candidates, chosen) && exact(chosen) {
+		return chosen, "whose header names the field exactly"
+	}
+	for _, idx := range candidates {
+		if exact(idx) {
+			return idx, "whose header names the field exactly"
+		}
+	}
+	if slices.Contains(candidates, chosen) {
+		return chosen, "the best header match"
+	}
+	return candidates[0], "the leftmost"
+}
+
+// headerMatches reports whether header matches one of field's names, by the
+// substring rule of createColumnMapping. Blank headers match nothing.
+func headerMatches(field, header string) bool {
+	header = strings.ToLower(strings.TrimSpace(header))
+	if header == "" {
+		return false
+	}
+	for _, variation := range ColumnMapping[field] {
+		if strings.Contains(header, variation) || strings.Contains(variation, header) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+// duplicateWarnings returns the warnings about columns matching the same field
+func duplicateWarnings(result *ParseResult) []ParseWarning {
+	var warnings []ParseWarning
+	for _, warning := range result.Warnings {
+		if strings.Contains(warning.Message, " columns match ") {
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
+}
+
+// TestParseHTML_DuplicateHeaders tests how columns whose headers match the same field are told apart
+func TestParseHTML_DuplicateHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		column   int
+		date     string
+		expected string
+	}{
+		{
+			name: "payout date before sale date",
+			html: `<table>
+				<tr><th>Store</th><th>Vendor</th><th>Payout Date</th><th>Sale Date</th><th>Description</th><th>Sale Price</th></tr>
+				<tr><td>Store A</td><td>Vendor 1</td><td>2024-02-01</td><td>2024-01-15</td><td>Lamp</td><td>10.00</td></tr>
+			</table>`,
+			column:   3,
+			date:     "2024-01-15",
+			expected: `2 columns match date: "Payout Date" (column 3), "Sale Date" (column 4); using column 4, whose header names the field exactly`,
+		},
+		{
+			name: "two date columns, one unpaid",
+			html: `<table>
+				<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+				<tr><td>Store A</td><td>Vendor 1</td><td>Pending</td><td>2024-01-15</td><td>Lamp</td><td>10.00</td></tr>
+				<tr><td>Store A</td><td>Vendor 1</td><td>Pending</td><td>2024-01-16</td><td>Chair</td><td>20.00</td></tr>
+			</table>`,
+			column:   3,
+			date:     "2024-01-15",
+			expected: `2 columns match date: "Date" (column 3), "Date" (column 4); using column 4, the only one holding date values`,
+		},
+		{
+			name: "identical date columns",
+			html: `<table>
+				<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+				<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>2024-02-01</td><td>Lamp</td><td>10.00</td></tr>
+			</table>`,
+			column:   2,
+			date:     "2024-01-15",
+			expected: `2 columns match date: "Date" (column 3), "Date" (column 4); using column 3, whose header names the field exactly`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, stream := range []bool{false, true} {
+				var result *ParseResult
+				var err error
+				if stream {
+					result, _, err = collectStream(t, NewHTMLTableParser(), tt.html)
+				} else {
+					result, err = NewHTMLTableParser().ParseHTML(tt.html)
+				}
+				if err != nil {
+					t.Fatalf("Parse failed (stream %v): %v", stream, err)
+				}
+				if got := result.ColumnMapping["date"]; got != tt.column {
+					t.Errorf("Expected date from column %d (stream %v), got %d", tt.column, stream, got)
+				}
+				if !stream && (len(result.Records) == 0 || result.Records[0].Date != tt.date) {
+					t.Errorf("Expected the first record dated %s, got %+v", tt.date, result.Records)
+				}
+				warnings := duplicateWarnings(result)
+				if len(warnings) != 1 || warnings[0].Column != "date" || warnings[0].Message != tt.expected {
+					t.Errorf("Unexpected warnings (stream %v): %+v", stream, warnings)
+				}
+				if match := result.ColumnMatches["date"]; match.Column != tt.column {
+					t.Errorf("Expected the column match to follow the chosen column, got %+v", match)
+				}
+			}
+		})
+	}
+}
+
+// TestParseHTML_NoDuplicateHeaders tests that tables with one match per field get no warning
+func TestParseHTML_NoDuplicateHeaders(t *testing.T) {
+	result, err := NewHTMLTableParser().ParseHTML(basicTableHTML)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if warnings := duplicateWarnings(result); len(warnings) != 0 {
+		t.Errorf("Expected no duplicate header warnings, got %+v", warnings)
+	}
+}
@@ -137,14 +137,14 @@ func printConsignableResults(title string, result *parser.ParseResult) {
 				desc = desc[:22] + "..."
 			}
 			
-			fmt.Printf("%-15s %-20s %-12s %-25s %10.2f %10.2f %10.2f\n",
+			fmt.Printf("%-15s %-20s %-12s %-25s %10.2f %10s %10s\n",
 				truncate(record.Store, 15),
 				truncate(record.Vendor, 20),
 				record.Date,
 				desc,
 				record.SalePrice,
-				record.Commission,
-				record.Remaining)
+				formatAmount(record.Commission),
+				formatAmount(record.Remaining))
 		}
 	}
 	
@@ -177,3 +177,11 @@ func truncate(s string, maxLen int) string {
 	}
 	return s[:maxLen-3] + "..."
 }
+
+// formatAmount renders an optional amount, showing "-" when it is unknown
+func formatAmount(amount *float64) string {
+	if amount == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", *amount)
+}
@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// fragmentContexts are the elements a partial copy is parsed inside of when
+// parsing it as a document finds no table: rows first, then whole sections
+var fragmentContexts = []atom.Atom{atom.Tbody, atom.Table}
+
+// parseTableFragment reads data that parsing as a document found no table
+// in, such as a paste that starts or ends partway through the table and
+// lost its <table> tag, as the inside of a table. It returns a table holding
+// the rows found, or nil if there are none.
+func parseTableFragment(data string) *html.Node {
+	for _, context := range fragmentContexts {
+		nodes, err := html.ParseFragment(strings.NewReader(data), &html.Node{
+			Type:     html.ElementNode,
+			Data:     context.String(),
+			DataAtom: context,
+		})
+		if err != nil {
+			continue
+		}
+
+		table := &html.Node{Type: html.ElementNode, Data: atom.Table.String(), DataAtom: atom.Table}
+		parent := table
+		if context == atom.Tbody {
+			parent = &html.Node{Type: html.ElementNode, Data: atom.Tbody.String(), DataAtom: atom.Tbody}
+			table.AppendChild(parent)
+		}
+		for _, node := range nodes {
+			parent.AppendChild(node)
+		}
+		if hasTableRows(table) {
+			return table
+		}
+	}
+	return nil
+}
+
+// hasTableRows reports whether n holds a row with at least one cell
+func hasTableRows(n *html.Node) bool {
+	if n.Type == html.ElementNode && (n.DataAtom == atom.Td || n.DataAtom == atom.Th) {
+		return true
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if hasTableRows(child) {
+			return true
+		}
+	}
+	return false
+}
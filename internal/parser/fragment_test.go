@@ -0,0 +1,33 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseHTML_PartialTable tests that pastes whose table tag was cut off are read as rows
+func TestParseHTML_PartialTable(t *testing.T) {
+	htmlData := `<table<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>$10.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 2</td><td>2024-01-16</td><td>Chair</td><td>$20.00</td></tr>`
+
+	result, err := NewHTMLTableParser().ParseHTML(htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if len(result.Records) != 2 || result.Records[1].Description != "Chair" {
+		t.Errorf("Expected the 2 rows to be read, got %+v", result.Records)
+	}
+	found := false
+	for _, warning := range result.Warnings {
+		found = found || strings.Contains(warning.Message, "No complete table")
+	}
+	if !found {
+		t.Errorf("Expected a warning that the table was cut off, got %+v", result.Warnings)
+	}
+
+	// Text without any cells still has no table
+	if _, err := NewHTMLTableParser().ParseHTML("<p>Nothing to see</p>"); err == nil || !strings.Contains(err.Error(), "no HTML tables") {
+		t.Errorf("Expected no tables to be found, got %v", err)
+	}
+}
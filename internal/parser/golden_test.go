@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// goldenDir holds anonymized report pages from the supported portals, each
+// with the ParseResult expected of it in a .golden.json file
+var goldenDir = filepath.Join("testdata", "golden")
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite the .golden.json files in "+goldenDir)
+
+// goldenOptions are the parser settings of a fixture, read from its optional
+// .options.json file. The names match the import options of the app.
+type goldenOptions struct {
+	Layout              string         `json:"layout,omitempty"`
+	MultiTable          bool           `json:"multi_table,omitempty"`
+	KeepUnmappedColumns bool           `json:"keep_unmapped_columns,omitempty"`
+	YearInference       *YearInference `json:"year_inference,omitempty"`
+}
+
+// TestGoldenFixtures parses every page in goldenDir and fails when the result
+// differs from its golden file, so a parser change that alters the outcome of
+// a real report is noticed. Run with -update-golden to accept the new results
+// after checking the diff.
+func TestGoldenFixtures(t *testing.T) {
+	pages, err := filepath.Glob(filepath.Join(goldenDir, "*.html"))
+	if err != nil || len(pages) == 0 {
+		t.Fatalf("Expected fixtures in %s, got %v", goldenDir, err)
+	}
+
+	for _, page := range pages {
+		name := strings.TrimSuffix(filepath.Base(page), ".html")
+		t.Run(name, func(t *testing.T) {
+			got := parseGoldenFixture(t, page)
+			goldenFile := filepath.Join(goldenDir, name+".golden.json")
+
+			if *updateGolden {
+				if err := os.WriteFile(goldenFile, got, 0644); err != nil {
+					t.Fatalf("Failed to write %s: %v", goldenFile, err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(goldenFile)
+			if err != nil {
+				t.Fatalf("Failed to read %s (run with -update-golden to create it): %v", goldenFile, err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Parse result differs from %s at %s\nRun go test -run TestGoldenFixtures -update-golden and review the diff if the change is intended",
+					goldenFile, firstDifference(string(want), string(got)))
+			}
+		})
+	}
+}
+
+// parseGoldenFixture parses a fixture with the options beside it and returns
+// the result as indented JSON, without the timings that vary between runs
+func parseGoldenFixture(t *testing.T, page string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(page)
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	var options goldenOptions
+	optionsFile := strings.TrimSuffix(page, ".html") + ".options.json"
+	if raw, err := os.ReadFile(optionsFile); err == nil {
+		if err := json.Unmarshal(raw, &options); err != nil {
+			t.Fatalf("Failed to read %s: %v", optionsFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		t.Fatalf("Failed to read %s: %v", optionsFile, err)
+	}
+
+	parser := NewHTMLTableParser()
+	if options.Layout != "" {
+		if err := parser.SetLayout(options.Layout); err != nil {
+			t.Fatalf("SetLayout failed: %v", err)
+		}
+	}
+	parser.MultiTable = options.MultiTable
+	parser.KeepUnmappedColumns = options.KeepUnmappedColumns
+	if options.YearInference != nil {
+		parser.YearInference = *options.YearInference
+	}
+
+	result, err := parser.ParseHTML(string(data))
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	result.Statistics.ProcessingTime = 0
+	result.Statistics.ValidationTime = 0
+	result.Statistics.WaitTime = 0
+
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false) // Keep the snippets of table cells readable
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		t.Fatalf("Failed to encode result: %v", err)
+	}
+	return encoded.Bytes()
+}
+
+// firstDifference describes the first line where got differs from want
+func firstDifference(want, got string) string {
+	wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var wantLine, gotLine string
+		if i < len(wantLines) {
+			wantLine = wantLines[i]
+		}
+		if i < len(gotLines) {
+			gotLine = gotLines[i]
+		}
+		if wantLine != gotLine {
+			return fmt.Sprintf("line %d:\n  want: %s\n  got:  %s", i+1, strings.TrimSpace(wantLine), strings.TrimSpace(gotLine))
+		}
+	}
+	return "the end of the file"
+}
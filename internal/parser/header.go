@@ -0,0 +1,238 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// headerCell is a cell of a header row and the columns and rows it spans
+type headerCell struct {
+	text    string
+	colspan int
+	rowspan int
+	th      bool // Marked up as a header cell
+}
+
+// mapHeader maps the header at the top of a table. Reports sometimes split
+// the header over two rows, such as "Sale" above "Price". The rows are
+// merged first when the second holds no data and merging maps more of the
+// table's columns, or as many when the second row is marked up with <th>
+// cells. second is nil for a table with a single row; merged reports whether
+// the second row was taken as part of the header.
+func (p *HTMLTableParser) mapHeader(top, second []headerCell) (headers []string, mapping map[string]int, merged bool, err error) {
+	headers = cellTexts(top)
+	mapping, err = p.createColumnMapping(headers)
+	if second == nil || p.UsePositionalMapping || !p.looksLikeHeaderRow(cellTexts(second)) {
+		return headers, mapping, false, err
+	}
+
+	mergedHeaders := mergeHeaderRows(expandHeaderRows(top, second))
+	mergedMapping, mergedErr := p.createColumnMapping(mergedHeaders)
+	if mergedErr != nil {
+		return headers, mapping, false, err
+	}
+	if err == nil {
+		gained := mappedColumns(mergedMapping) - mappedColumns(mapping)
+		if gained < 0 || (gained == 0 && !allHeaderCells(second)) {
+			return headers, mapping, false, err
+		}
+	}
+	return mergedHeaders, mergedMapping, true, nil
+}
+
+// mappedColumns counts the table columns a mapping uses. Short headers such
+// as "Sale" match several fields, so the number of fields mapped overstates
+// how well the headers were understood.
+func mappedColumns(mapping map[string]int) int {
+	columns := make(map[int]bool)
+	for _, idx := range mapping {
+		columns[idx] = true
+	}
+	return len(columns)
+}
+
+// allHeaderCells reports whether every cell of a row is a <th>
+func allHeaderCells(cells []headerCell) bool {
+	for _, cell := range cells {
+		if !cell.th {
+			return false
+		}
+	}
+	return len(cells) > 0
+}
+
+// looksLikeHeaderRow reports whether a row has text but no dates, amounts or
+// numbers, which every data row has
+func (p *HTMLTableParser) looksLikeHeaderRow(row []string) bool {
+	hasText := false
+	for _, cell := range row {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		if p.looksLikeDate(cell) || p.looksLikeCurrency(cell) {
+			return false
+		}
+		if _, err := strconv.ParseFloat(strings.ReplaceAll(cell, ",", ""), 64); err == nil {
+			return false
+		}
+		hasText = true
+	}
+	return hasText
+}
+
+// expandHeaderRows lays two header rows out on a grid, repeating each cell
+// across the columns and rows it spans, so that a column's text in both rows
+// has the same index
+func expandHeaderRows(top, second []headerCell) ([]string, []string) {
+	var topRow []string
+	carried := map[int]string{} // Columns of the second row filled by cells above
+	for _, cell := range top {
+		for i := 0; i < cell.colspan; i++ {
+			if cell.rowspan > 1 {
+				carried[len(topRow)] = cell.text
+			}
+			topRow = append(topRow, cell.text)
+		}
+	}
+
+	var secondRow []string
+	for len(second) > 0 || len(carried) > 0 {
+		if text, ok := carried[len(secondRow)]; ok {
+			delete(carried, len(secondRow))
+			secondRow = append(secondRow, text)
+			continue
+		}
+		if len(second) == 0 {
+			secondRow = append(secondRow, "") // A gap before a later carried cell
+			continue
+		}
+		cell := second[0]
+		second = second[1:]
+		for i := 0; i < cell.colspan; i++ {
+			secondRow = append(secondRow, cell.text)
+		}
+	}
+	return topRow, secondRow
+}
+
+// mergeHeaderRows joins two header rows column by column. A cell spanning
+// both rows, or with nothing below it, keeps its own text.
+func mergeHeaderRows(top, second []string) []string {
+	width := len(top)
+	if len(second) > width {
+		width = len(second)
+	}
+
+	merged := make([]string, width)
+	for i := range merged {
+		var upper, lower string
+		if i < len(top) {
+			upper = strings.TrimSpace(top[i])
+		}
+		if i < len(second) {
+			lower = strings.TrimSpace(second[i])
+		}
+
+		switch {
+		case lower == "" || strings.EqualFold(upper, lower):
+			merged[i] = upper
+		case upper == "":
+			merged[i] = lower
+		default:
+			merged[i] = upper + " " + lower
+		}
+	}
+	return merged
+}
+
+// cellTexts returns the text of each cell
+func cellTexts(cells []headerCell) []string {
+	texts := make([]string, len(cells))
+	for i, cell := range cells {
+		texts[i] = cell.text
+	}
+	return texts
+}
+
+// plainCells makes cells that each span one column and row, for sources
+// such as delimited exports that have no spans
+func plainCells(texts []string) []headerCell {
+	cells := make([]headerCell, len(texts))
+	for i, text := range texts {
+		cells[i] = headerCell{text: text, colspan: 1, rowspan: 1}
+	}
+	return cells
+}
+
+// newHeaderCell reads a <td> or <th> cell's spans from its colspan and
+// rowspan attributes
+func newHeaderCell(tag, text string, attrs []html.Attribute) headerCell {
+	cell := headerCell{text: text, colspan: 1, rowspan: 1, th: tag == "th"}
+	for _, attr := range attrs {
+		span, err := strconv.Atoi(strings.TrimSpace(attr.Val))
+		if err != nil || span < 1 {
+			continue
+		}
+		switch attr.Key {
+		case "colspan":
+			cell.colspan = span
+		case "rowspan":
+			cell.rowspan = span
+		}
+	}
+	return cell
+}
+
+// headerRows returns the cells of the first two rows of a table, the rows
+// that may hold its header
+func (p *HTMLTableParser) headerRows(table *html.Node) [][]headerCell {
+	var rows [][]headerCell
+
+	var traverse func(*html.Node)
+	traverse = func(node *html.Node) {
+		if len(rows) == 2 {
+			return
+		}
+		if node.Type == html.ElementNode && node.Data == "tr" {
+			var cells []headerCell
+			for cell := node.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") {
+					cells = append(cells, newHeaderCell(cell.Data, strings.TrimSpace(p.extractTextContent(cell)), cell.Attr))
+				}
+			}
+			if len(cells) > 0 {
+				rows = append(rows, cells)
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			traverse(child)
+		}
+	}
+
+	traverse(table)
+	return rows
+}
+
+// mapTableHeader maps the header of a table read with extractTableData. It
+// returns the headers and how many rows they took.
+func (p *HTMLTableParser) mapTableHeader(table *html.Node, tableData [][]string) ([]string, map[string]int, int, error) {
+	rows := p.headerRows(table)
+	if len(rows) == 0 || len(tableData) < 3 || len(cellTexts(rows[0])) != len(tableData[0]) {
+		// Cells were found differently from extractTableData; keep the first row
+		mapping, err := p.createColumnMapping(tableData[0])
+		return tableData[0], mapping, 1, err
+	}
+
+	var second []headerCell
+	if len(rows) > 1 {
+		second = rows[1]
+	}
+	headers, mapping, merged, err := p.mapHeader(rows[0], second)
+	if merged {
+		return headers, mapping, 2, err
+	}
+	return headers, mapping, 1, err
+}
@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHTML_TwoRowHeader(t *testing.T) {
+	tests := []struct {
+		name            string
+		html            string
+		expectedHeaders []string
+		expectedMapping map[string]int
+	}{
+		{
+			name: "spanning cells",
+			html: `<table>
+				<tr><th rowspan="2">Store</th><th rowspan="2">Vendor</th><th rowspan="2">Date</th><th rowspan="2">Item</th><th colspan="3">Sale</th></tr>
+				<tr><th>Price</th><th>Commission</th><th>Remaining</th></tr>
+				<tr><td>Store A</td><td>Vendor 1</td><td>2024-03-15</td><td>Lamp</td><td>$40.00</td><td>$4.00</td><td>$36.00</td></tr>
+				<tr><td>Store B</td><td>Vendor 2</td><td>2024-03-16</td><td>Chair</td><td>$75.00</td><td>$7.50</td><td>$67.50</td></tr>
+			</table>`,
+			expectedHeaders: []string{"Store", "Vendor", "Date", "Item", "Sale Price", "Sale Commission", "Sale Remaining"},
+			expectedMapping: map[string]int{"store": 0, "vendor": 1, "date": 2, "description": 3, "sale_price": 4, "commission": 5, "remaining": 6},
+		},
+		{
+			name: "one cell per column",
+			html: `<table>
+				<tr><th>Store</th><th>Vendor</th><th>Sale</th><th>Item</th><th>Sale</th></tr>
+				<tr><th></th><th></th><th>Date</th><th>Description</th><th>Price</th></tr>
+				<tr><td>Store A</td><td>Vendor 1</td><td>2024-03-15</td><td>Lamp</td><td>40.00</td></tr>
+				<tr><td>Store B</td><td>Vendor 2</td><td>2024-03-16</td><td>Chair</td><td>75.00</td></tr>
+			</table>`,
+			expectedHeaders: []string{"Store", "Vendor", "Sale Date", "Item Description", "Sale Price"},
+			expectedMapping: map[string]int{"store": 0, "vendor": 1, "date": 2, "description": 3, "sale_price": 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewHTMLTableParser().ParseHTML(tt.html)
+			if err != nil {
+				t.Fatalf("ParseHTML failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(result.Statistics.HeadersDetected, tt.expectedHeaders) {
+				t.Errorf("Expected headers %v, got %v", tt.expectedHeaders, result.Statistics.HeadersDetected)
+			}
+			for col, idx := range tt.expectedMapping {
+				if result.ColumnMapping[col] != idx {
+					t.Errorf("Expected %s mapped to column %d, got %v", col, idx, result.ColumnMapping)
+				}
+			}
+			if result.TotalRows != 2 || result.SuccessCount != 2 {
+				t.Errorf("Expected 2 data rows parsed, got %d of %d: %+v", result.SuccessCount, result.TotalRows, result.Errors)
+			}
+			if result.Records[1].SalePrice != 75.00 {
+				t.Errorf("Expected the second sale price to be 75.00, got %v", result.Records[1].SalePrice)
+			}
+
+			// The streaming parser merges the same rows
+			streamed, records, err := collectStream(t, NewHTMLTableParser(), tt.html)
+			if err != nil {
+				t.Fatalf("ParseHTMLStream failed: %v", err)
+			}
+			if !reflect.DeepEqual(streamed.ColumnMapping, result.ColumnMapping) || len(records) != 2 {
+				t.Errorf("Expected streamed mapping %v and 2 records, got %v and %d", result.ColumnMapping, streamed.ColumnMapping, len(records))
+			}
+		})
+	}
+}
+
+func TestParseHTML_TwoRowHeaderRowNumbers(t *testing.T) {
+	table := `<table>
+		<tr><th rowspan="2">Store</th><th rowspan="2">Vendor</th><th rowspan="2">Date</th><th rowspan="2">Item</th><th>Sale</th></tr>
+		<tr><th>Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-03-15</td><td>Lamp</td><td>40.00</td></tr>
+		<tr><td>Store B</td><td>Vendor 2</td><td>not a date</td><td>Chair</td><td>75.00</td></tr>
+	</table>`
+
+	result, err := NewHTMLTableParser().ParseHTML(table)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	// Rows are numbered from the top of the table, counting both header rows
+	if result.ErrorCount != 1 || result.Errors[0].Row != 4 {
+		t.Errorf("Expected an error on row 4, got %+v", result.Errors)
+	}
+
+	streamed, _, err := collectStream(t, NewHTMLTableParser(), table)
+	if err != nil {
+		t.Fatalf("ParseHTMLStream failed: %v", err)
+	}
+	if streamed.ErrorCount != 1 || streamed.Errors[0].Row != 4 {
+		t.Errorf("Expected a streamed error on row 4, got %+v", streamed.Errors)
+	}
+}
+
+func TestParseHTML_SingleHeaderRowKept(t *testing.T) {
+	// A text-only second row is only merged when it improves the mapping
+	table := `<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th><th>Commission</th><th>Remaining</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>unknown</td><td>Lamp</td><td>n/a</td><td></td><td></td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-03-15</td><td>Lamp</td><td>40.00</td><td>4.00</td><td>36.00</td></tr>
+	</table>`
+
+	result, err := NewHTMLTableParser().ParseHTML(table)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if result.TotalRows != 2 || result.Statistics.HeadersDetected[0] != "Store" {
+		t.Errorf("Expected a single header row and 2 data rows, got headers %v and %d rows", result.Statistics.HeadersDetected, result.TotalRows)
+	}
+}
@@ -2,7 +2,9 @@ package parser
 
 import (
 	"fmt"
+	"math"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -18,7 +20,9 @@ var (
 		regexp.MustCompile(`^\d{4}-\d{1,2}-\d{1,2}`),
 		regexp.MustCompile(`^\d{1,2}/\d{1,2}/\d{4}`),
 		regexp.MustCompile(`^\d{1,2}-\d{1,2}-\d{4}`),
-		regexp.MustCompile(`^[A-Za-z]{3,9}\s+\d{1,2},?\s+\d{4}`),
+		regexp.MustCompile(`^[A-Za-z]{3,9}\s+\d{1,2}(?:st|nd|rd|th)?,?\s+\d{4}`),
+		regexp.MustCompile(`^\d{1,2}/\d{1,2}/\d{2}$`),
+		regexp.MustCompile(`^\d{1,2}-[A-Za-z]{3}-\d{2}(?:\d{2})?$`),
 	}
 	
 	currencyPatterns = []*regexp.Regexp{
@@ -26,6 +30,11 @@ var (
 		regexp.MustCompile(`^\d+\.\d{2}$`),
 		regexp.MustCompile(`^\(\d+\.?\d*\)$`), // Negative in parentheses
 	}
+
+	// returnMarker matches upper-case return markers in descriptions, such as
+	// "RETURN - Blue Lamp". Mixed-case words like "Return of the Jedi" are
+	// ordinary product names.
+	returnMarker = regexp.MustCompile(`\b(RETURN|RETURNED|REFUND|REFUNDED)\b`)
 )
 
 // HTMLTableParser handles parsing HTML table data into sales records
@@ -36,6 +45,55 @@ type HTMLTableParser struct {
 	// Positional mapping for headerless tables
 	UsePositionalMapping bool     // Enable positional column mapping
 	PositionalColumns    []string // Column names in order for positional mapping
+
+	// Built-in platform preset; nil for generic header matching
+	Layout *Layout
+
+	// Parse every table on the page instead of only the largest, for reports
+	// that render one table per period
+	MultiTable bool
+
+	// Keep the values of columns no field was mapped to in each record's
+	// Metadata, keyed by header, instead of dropping them
+	KeepUnmappedColumns bool
+
+	// Set aside rows that parse but fail a business-rule check, such as a
+	// future date or a commission above the sale price, in
+	// ParseResult.Quarantined instead of returning them as records
+	Quarantine bool
+
+	// Where dates written without a year take it from; by default the year
+	// named near the table
+	YearInference YearInference
+
+	// Column values derived from the heading of the table being parsed
+	sectionDefaults map[string]string
+
+	// Year named near the table being parsed, for dates written without one,
+	// and the number of dates that took it
+	contextYear   int
+	yearsInferred int
+
+	// Time spent in parseRow during the parse under way
+	rowTime time.Duration
+
+	// Header of each unmapped column kept in record metadata, by column index
+	metadataColumns map[int]string
+
+	// Fields and types forced on columns, set with SetColumnOverrides
+	columnOverrides []ColumnOverride
+
+	// Values for fields the report leaves out or blank, and the number of
+	// records of the table being parsed that took each
+	Defaults     FieldDefaults
+	defaultsUsed map[string]int
+
+	// Rules skipping rows such as fee lines, set with SetIgnoreRules, and the
+	// number of rows of the table being parsed each skipped or could not read
+	// as a fee
+	ignorePatterns []ignorePattern
+	ignored        map[int]int
+	unreadFees     map[int]int
 }
 
 // NewHTMLTableParser creates a new HTML table parser
@@ -66,28 +124,52 @@ func (p *HTMLTableParser) SetConsignableMapping() {
 	})
 }
 
+// SchemaVersion is the version of the JSON encoding of ParseResult and of the
+// import results built from it. It is raised when a field is renamed, removed
+// or changes meaning; new fields do not change it.
+const SchemaVersion = 1
+
 // ParseResult contains the results of parsing HTML table data
 type ParseResult struct {
-	Records       []models.CreateSalesRecordRequest `json:"records"`
-	TotalRows     int                               `json:"total_rows"`
-	SuccessCount  int                               `json:"success_count"`
-	ErrorCount    int                               `json:"error_count"`
-	Errors        []ParseError                      `json:"errors,omitempty"`
-	Warnings      []ParseWarning                    `json:"warnings,omitempty"`
-	ColumnMapping map[string]int                    `json:"column_mapping"`
-	Statistics    ParseStatistics                   `json:"statistics"`
+	SchemaVersion   int                               `json:"schema_version"` // SchemaVersion of the encoding
+	Records         []models.CreateSalesRecordRequest `json:"records"`
+	TotalRows       int                               `json:"total_rows"`
+	SuccessCount    int                               `json:"success_count"`
+	ErrorCount      int                               `json:"error_count"`
+	Errors          []ParseError                      `json:"errors,omitempty"`
+	Warnings        []ParseWarning                    `json:"warnings,omitempty"`
+	ColumnMapping   map[string]int                    `json:"column_mapping"`
+	Statistics      ParseStatistics                   `json:"statistics"`
+	Tables          []TableSection                    `json:"tables,omitempty"`           // Per-table results in multi-table mode
+	ColumnMatches   map[string]ColumnMatch            `json:"column_matches,omitempty"`   // How each field in ColumnMapping was matched to its header
+	UnmappedColumns []UnmappedColumn                  `json:"unmapped_columns,omitempty"` // Columns with data that were not imported
+	IgnoredRows     int                               `json:"ignored_rows,omitempty"`     // Rows skipped by ignore rules, not counted in TotalRows
+	Fees            []models.CreateFeeRequest         `json:"fees,omitempty"`             // Ignored rows recorded as fees by rules with a fee category
+	Quarantined     []models.QuarantinedRow           `json:"quarantined,omitempty"`      // Rows set aside by the Quarantine option, not counted in TotalRows
+	ContentHash     string                            `json:"content_hash,omitempty"`     // SHA-256 of the parsed records, the same whenever the same report is parsed
+}
+
+// Title returns the caption or heading of the parsed table, or "" if it had none
+func (r *ParseResult) Title() string {
+	if r.Statistics.Context == nil {
+		return ""
+	}
+	return r.Statistics.Context.Title()
 }
 
 // ParseError represents an error that occurred during parsing
 type ParseError struct {
+	Table   int    `json:"table,omitempty"` // 1-based table number in multi-table mode
 	Row     int    `json:"row"`
 	Column  string `json:"column,omitempty"`
 	Message string `json:"message"`
 	Value   string `json:"value,omitempty"`
+	Snippet string `json:"snippet,omitempty"` // The failed cell and its neighbours as table cells, escaped and cut short
 }
 
 // ParseWarning represents a warning that occurred during parsing
 type ParseWarning struct {
+	Table   int    `json:"table,omitempty"` // 1-based table number in multi-table mode
 	Row     int    `json:"row"`
 	Column  string `json:"column,omitempty"`
 	Message string `json:"message"`
@@ -98,9 +180,21 @@ type ParseWarning struct {
 type ParseStatistics struct {
 	TablesFound       int                    `json:"tables_found"`
 	HeadersDetected   []string               `json:"headers_detected"`
+	Context           *TableContext          `json:"context,omitempty"` // Caption, heading and section title of the table; nil if none
 	DataTypesDetected map[string]string      `json:"data_types_detected"`
 	ValueRanges       map[string]ValueRange  `json:"value_ranges,omitempty"`
-	ProcessingTime    time.Duration          `json:"processing_time"`
+	ProcessingTime    models.Duration        `json:"processing_time"`
+	ValidationTime    models.Duration        `json:"validation_time"`     // Part of ProcessingTime spent reading and checking the values of rows
+	WaitTime          models.Duration        `json:"wait_time,omitempty"` // Part of ProcessingTime a streaming parse spent waiting for its records to be taken
+}
+
+// Timings splits the processing time into reading the table and checking its
+// rows. Time spent waiting for records to be taken is in neither.
+func (s ParseStatistics) Timings() models.ImportTimings {
+	return models.ImportTimings{
+		Parse:    s.ProcessingTime - s.ValidationTime - s.WaitTime,
+		Validate: s.ValidationTime,
+	}
 }
 
 // ValueRange represents the range of values found in a column
@@ -113,12 +207,21 @@ type ValueRange struct {
 // Required columns for sales record validation
 var requiredColumns = []string{"store", "vendor", "date", "description", "sale_price"}
 
+// Optional amount columns whose absence means "unknown", not zero
+var optionalAmountColumns = []string{"commission", "remaining"}
+
 // validateRequiredColumns consolidates validation logic for required columns
 func (p *HTMLTableParser) validateRequiredColumns(mapping map[string]int, context string) error {
 	missingColumns := []string{}
 	
 	for _, col := range requiredColumns {
 		if _, exists := mapping[col]; !exists {
+			if _, ok := p.columnDefault(col); ok {
+				continue
+			}
+			if p.Defaults.covers(col) {
+				continue
+			}
 			missingColumns = append(missingColumns, col)
 		}
 	}
@@ -151,13 +254,48 @@ var ColumnMapping = map[string][]string{
 	"remaining": {
 		"remaining", "balance", "remaining balance", "outstanding", "due", "remaining amount", "balance due",
 	},
+	// Optional source transaction id; bare "#" columns are usually row numbers, so they are not matched
+	"external_id": {
+		"transaction id", "txn id", "line id", "line item id", "external id", "receipt id", "reference id",
+	},
+	// Optional ISO 4217 currency code for multi-currency reports
+	"currency": {
+		"currency", "currency code", "ccy",
+	},
+}
+
+// ExactColumnMapping names optional fields whose headers must match one of
+// their names exactly. Their headers contain words such as "item", "price"
+// and "product" that other fields match by substring, or are words such as
+// "type" that appear in unrelated headers, so they claim their columns
+// before the other fields are matched.
+var ExactColumnMapping = map[string][]string{
+	// What the seller paid for the item
+	"cost": {
+		"item cost", "unit cost", "my cost", "cost basis", "cost price", "purchase price", "purchase cost", "acquisition cost",
+	},
+	"category": {
+		"category", "product category", "item category", "department", "dept",
+	},
+	// Optional sale/return indicator; a bare "type" would also match headers like "Payment Type"
+	"record_type": {
+		"type", "record type", "transaction type", "sale type",
+	},
+}
+
+// optionalColumns are recognized when present but never required, even in strict mode
+var optionalColumns = map[string]bool{
+	"external_id": true,
+	"currency":    true,
 }
 
 // ParseHTML parses HTML table data and extracts sales records
 func (p *HTMLTableParser) ParseHTML(htmlData string) (*ParseResult, error) {
 	startTime := time.Now()
+	p.rowTime = 0
 	
 	result := &ParseResult{
+		SchemaVersion: SchemaVersion,
 		Records:       []models.CreateSalesRecordRequest{},
 		ColumnMapping: make(map[string]int),
 		Statistics: ParseStatistics{
@@ -177,14 +315,34 @@ func (p *HTMLTableParser) ParseHTML(htmlData string) (*ParseResult, error) {
 
 	// Find all tables
 	tables := p.findTables(doc)
+	if len(tables) == 0 {
+		// A partial copy may hold rows whose table was cut off
+		if table := parseTableFragment(strings.TrimSpace(htmlData)); table != nil {
+			tables = []*html.Node{table}
+			result.Warnings = append(result.Warnings, ParseWarning{
+				Message: "No complete table was found, so the data was read as rows of a table cut off by the copy; check the first and last rows",
+			})
+		}
+	}
 	result.Statistics.TablesFound = len(tables)
 	
 	if len(tables) == 0 {
 		return nil, fmt.Errorf("no HTML tables found in the provided data")
 	}
 
+	if p.MultiTable {
+		return p.parseTables(tables, result, startTime)
+	}
+
 	// Process the first table (or the largest table if multiple)
 	table := p.selectBestTable(tables)
+
+	// The caption or heading names the import and supplies missing years
+	context := p.extractTableContext(table)
+	if !context.IsEmpty() {
+		result.Statistics.Context = &context
+	}
+	p.setContextYear(context)
 	
 	// Extract table data
 	tableData, err := p.extractTableData(table)
@@ -196,21 +354,39 @@ func (p *HTMLTableParser) ParseHTML(htmlData string) (*ParseResult, error) {
 		return nil, fmt.Errorf("no data rows found in table")
 	}
 
-	result.TotalRows = len(tableData) - 1 // Subtract header row
-
-	// Detect headers and create column mapping
-	headers := tableData[0]
+	// Detect headers, which may be split over two rows, and create column mapping
+	headers, columnMapping, headerRows, err := p.mapTableHeader(table, tableData)
 	result.Statistics.HeadersDetected = headers
-	
-	columnMapping, err := p.createColumnMapping(headers)
 	if err != nil {
 		return nil, fmt.Errorf("failed to map columns: %w", err)
 	}
+	result.Warnings = append(result.Warnings, p.resolveDuplicateHeaders(headers, columnMapping, tableData[headerRows:])...)
 	result.ColumnMapping = columnMapping
+	result.ColumnMatches = p.explainMapping(headers, columnMapping)
+	result.TotalRows = len(tableData) - headerRows
+	tableData = append([][]string{headers}, tableData[headerRows:]...)
+
+	// Optional amount columns that are absent leave every record's value unknown
+	for _, col := range optionalAmountColumns {
+		if _, exists := columnMapping[col]; !exists && !p.Defaults.covers(col) {
+			result.Warnings = append(result.Warnings, ParseWarning{
+				Row:     0,
+				Column:  col,
+				Message: fmt.Sprintf("No %s column found; values will be recorded as unknown", col),
+			})
+		}
+	}
 
 	// Parse data rows
+	unmapped := p.trackUnmapped(headers, columnMapping)
 	for i, row := range tableData[1:] {
-		rowNum := i + 2 // +2 because we skip header and want 1-based indexing
+		unmapped.observe(row)
+		rowNum := i + headerRows + 1 // Skip the header rows and use 1-based indexing
+		if p.ignoreRow(row, columnMapping, rowNum, result) {
+			result.TotalRows--
+			result.IgnoredRows++
+			continue
+		}
 		
 		record, parseErrors, warnings := p.parseRow(row, columnMapping, rowNum)
 		
@@ -218,18 +394,33 @@ func (p *HTMLTableParser) ParseHTML(htmlData string) (*ParseResult, error) {
 			result.Errors = append(result.Errors, parseErrors...)
 			result.ErrorCount++
 		} else {
-			result.Records = append(result.Records, record)
-			result.SuccessCount++
+			record.SourceRow = sourceRow(0, rowNum, headers, row)
+			if p.quarantineRecord(record, result) {
+				result.TotalRows--
+			} else {
+				result.Records = append(result.Records, record)
+				result.SuccessCount++
+			}
 		}
 		
 		if len(warnings) > 0 {
 			result.Warnings = append(result.Warnings, warnings...)
 		}
 	}
+	result.Warnings = append(result.Warnings, p.yearInferenceWarning(context)...)
+	result.Warnings = append(result.Warnings, p.defaultsWarnings()...)
+	result.Warnings = append(result.Warnings, p.ignoreWarnings()...)
+	p.contextYear = 0
+
+	unmappedColumns, unmappedWarnings := unmapped.results(0)
+	result.UnmappedColumns = unmappedColumns
+	result.Warnings = append(result.Warnings, unmappedWarnings...)
 
 	// Calculate statistics
 	p.calculateStatistics(result, tableData)
-	result.Statistics.ProcessingTime = time.Since(startTime)
+	result.Statistics.ProcessingTime = models.Duration(time.Since(startTime))
+	result.Statistics.ValidationTime = models.Duration(p.rowTime)
+	result.ContentHash = recordsHash(result.Records)
 
 	return result, nil
 }
@@ -238,6 +429,11 @@ func (p *HTMLTableParser) ParseHTML(htmlData string) (*ParseResult, error) {
 func (p *HTMLTableParser) cleanHTML(htmlData string) string {
 	// Remove common problematic characters and normalize whitespace
 	cleaned := strings.TrimSpace(htmlData)
+
+	// Delimited platform exports are read as CSV, which handles quoted fields
+	if p.isDelimitedLayout(cleaned) {
+		return p.convertCSVToHTML(cleaned)
+	}
 	
 	// Check if this looks like table rows without a table wrapper
 	if p.looksLikeTableRows(cleaned) {
@@ -305,6 +501,11 @@ func (p *HTMLTableParser) getDisplayColumnName(internalName string) string {
 		"sale_price":  "Sale Price",
 		"commission":  "Commission",
 		"remaining":   "Remaining",
+		"external_id": "Transaction ID",
+		"currency":    "Currency",
+		"record_type": "Type",
+		"cost":        "Item Cost",
+		"category":    "Category",
 	}
 	
 	if display, exists := displayNames[internalName]; exists {
@@ -358,6 +559,36 @@ func (p *HTMLTableParser) convertDelimitedToHTML(data string) string {
 	return htmlBuilder.String()
 }
 
+// convertCSVToHTML converts a delimited export to an HTML table using the
+// delimiter of the configured layout
+func (p *HTMLTableParser) convertCSVToHTML(data string) string {
+	reader := newDelimitedReader(strings.NewReader(data), p.Layout.Delimiter)
+
+	var htmlBuilder strings.Builder
+	htmlBuilder.WriteString("<html><body><table>")
+
+	for first := true; ; first = false {
+		cells, err := readDelimitedRow(reader, first)
+		if err != nil {
+			// io.EOF, or a malformed row; everything read so far is kept
+			break
+		}
+
+		tag := "td"
+		if first {
+			tag = "th"
+		}
+		htmlBuilder.WriteString("<tr>")
+		for _, cell := range cells {
+			htmlBuilder.WriteString(fmt.Sprintf("<%s>%s</%s>", tag, html.EscapeString(strings.TrimSpace(cell)), tag))
+		}
+		htmlBuilder.WriteString("</tr>")
+	}
+
+	htmlBuilder.WriteString("</table></body></html>")
+	return htmlBuilder.String()
+}
+
 // findTables finds all table elements in the HTML document
 func (p *HTMLTableParser) findTables(n *html.Node) []*html.Node {
 	var tables []*html.Node
@@ -487,6 +718,9 @@ func (p *HTMLTableParser) createColumnMapping(headers []string) (map[string]int,
 				mapping[col] = i
 			}
 		}
+		if err := p.applyColumnOverrides(headers, mapping); err != nil {
+			return nil, err
+		}
 		
 		// Use consolidated validation
 		if err := p.validateRequiredColumns(mapping, "positional mapping"); err != nil {
@@ -496,21 +730,70 @@ func (p *HTMLTableParser) createColumnMapping(headers []string) (map[string]int,
 		
 		return mapping, nil
 	}
+
+	// Platform layouts match their own header names exactly
+	if p.Layout != nil && len(p.Layout.Headers) > 0 {
+		return p.createLayoutMapping(headers)
+	}
 	
 	// Original header-based mapping logic
+	mapping = matchHeaders(headers)
+	
+	// Strict mode requires every column except the optional ones
+	if p.StrictMode {
+		for expectedCol := range ColumnMapping {
+			if _, found := mapping[expectedCol]; !found && !optionalColumns[expectedCol] {
+				return nil, fmt.Errorf("required column '%s' not found in headers: %v", expectedCol, headers)
+			}
+		}
+	}
+	if err := p.applyColumnOverrides(headers, mapping); err != nil {
+		return nil, err
+	}
+	
+	// Use consolidated validation
+	if err := p.validateRequiredColumns(mapping, "header-based mapping"); err != nil {
+		return nil, fmt.Errorf("%w. Available headers: %v", err, headers)
+	}
+	
+	return mapping, nil
+}
+
+// matchHeaders maps fields to the columns whose headers match one of their
+// names, ignoring case. Fields of ExactColumnMapping claim their columns
+// first; the others match headers that contain one of their names or are
+// contained in one.
+func matchHeaders(headers []string) map[string]int {
+	mapping := make(map[string]int)
+
 	// Normalize headers for comparison
 	normalizedHeaders := make([]string, len(headers))
 	for i, header := range headers {
 		normalizedHeaders[i] = strings.ToLower(strings.TrimSpace(header))
 	}
-	
+
+	// Fields matched only by exact header claim their columns first
+	claimed := make(map[int]bool)
+	for expectedCol, variations := range ExactColumnMapping {
+		for i, header := range normalizedHeaders {
+			if !claimed[i] && slices.Contains(variations, header) {
+				mapping[expectedCol] = i
+				claimed[i] = true
+				break
+			}
+		}
+	}
+
 	// Try to match each expected column
 	for expectedCol, variations := range ColumnMapping {
 		found := false
 		for _, variation := range variations {
 			for i, header := range normalizedHeaders {
-				if strings.Contains(header, strings.ToLower(variation)) || 
-				   strings.Contains(strings.ToLower(variation), header) {
+				if claimed[i] {
+					continue
+				}
+				if strings.Contains(header, strings.ToLower(variation)) ||
+					strings.Contains(strings.ToLower(variation), header) {
 					mapping[expectedCol] = i
 					found = true
 					break
@@ -520,30 +803,37 @@ func (p *HTMLTableParser) createColumnMapping(headers []string) (map[string]int,
 				break
 			}
 		}
-		
-		if !found && p.StrictMode {
-			return nil, fmt.Errorf("required column '%s' not found in headers: %v", expectedCol, headers)
-		}
-	}
-	
-	// Use consolidated validation
-	if err := p.validateRequiredColumns(mapping, "header-based mapping"); err != nil {
-		return nil, fmt.Errorf("%w. Available headers: %v", err, headers)
 	}
-	
-	return mapping, nil
+
+	return mapping
 }
 
 // parseRow parses a single data row into a sales record
 func (p *HTMLTableParser) parseRow(row []string, columnMapping map[string]int, rowNum int) (models.CreateSalesRecordRequest, []ParseError, []ParseWarning) {
+	defer func(start time.Time) { p.rowTime += time.Since(start) }(time.Now())
 	var record models.CreateSalesRecordRequest
 	var errors []ParseError
 	var warnings []ParseWarning
 	
 	// Helper function to get cell value safely
 	getCell := func(column string) string {
-		if idx, exists := columnMapping[column]; exists && idx < len(row) {
-			return strings.TrimSpace(row[idx])
+		idx, exists := columnMapping[column]
+		inRow := exists && idx < len(row)
+		if inRow {
+			if value := strings.TrimSpace(row[idx]); value != "" {
+				return value
+			}
+		}
+		// The import's defaults fill fields left out or left blank
+		if value, ok := p.Defaults.value(column); ok {
+			p.useDefault(column)
+			return value
+		}
+		if inRow {
+			return ""
+		}
+		if value, ok := p.columnDefault(column); ok {
+			return value
 		}
 		return ""
 	}
@@ -622,7 +912,8 @@ func (p *HTMLTableParser) parseRow(row []string, columnMapping map[string]int, r
 		}
 	}
 	
-	// Parse Commission (optional)
+	// Parse Commission (optional). An absent or unparseable value is recorded
+	// as unknown (nil) rather than zero so commission-rate reports stay honest.
 	commissionStr := getCell("commission")
 	if commissionStr != "" {
 		commission, err := p.parseCurrency(commissionStr)
@@ -630,13 +921,16 @@ func (p *HTMLTableParser) parseRow(row []string, columnMapping map[string]int, r
 			warnings = append(warnings, ParseWarning{
 				Row:     rowNum,
 				Column:  "commission",
-				Message: fmt.Sprintf("Invalid commission format, using 0.00: %v", err),
+				Message: fmt.Sprintf("Invalid commission format, recording as unknown: %v", err),
 				Value:   commissionStr,
 			})
-			record.Commission = 0.00
 		} else {
-			record.Commission = commission
+			record.Commission = &commission
 		}
+	} else if p.Defaults.CommissionRate != nil && len(errors) == 0 {
+		commission := p.Defaults.defaultCommission(record.SalePrice)
+		record.Commission = &commission
+		p.useDefault("commission")
 	}
 	
 	// Parse Remaining (optional)
@@ -647,18 +941,97 @@ func (p *HTMLTableParser) parseRow(row []string, columnMapping map[string]int, r
 			warnings = append(warnings, ParseWarning{
 				Row:     rowNum,
 				Column:  "remaining",
-				Message: fmt.Sprintf("Invalid remaining format, using 0.00: %v", err),
+				Message: fmt.Sprintf("Invalid remaining format, recording as unknown: %v", err),
 				Value:   remainingStr,
 			})
-			record.Remaining = 0.00
 		} else {
-			record.Remaining = remaining
+			record.Remaining = &remaining
+		}
+	}
+
+	// Source transaction id (optional); re-imports with the same id update the record
+	if externalID := getCell("external_id"); externalID != "" {
+		record.ExternalID = &externalID
+	}
+
+	// Returns are recognized by a negative sale price, a return/refund type, or
+	// a RETURN marker in the description, and are stored with positive amounts
+	if record.SalePrice < 0 || isReturnType(getCell("record_type")) || returnMarker.MatchString(record.Description) {
+		record.IsReturn = true
+		record.SalePrice = math.Abs(record.SalePrice)
+		if record.Commission != nil {
+			commission := math.Abs(*record.Commission)
+			record.Commission = &commission
+		}
+		if record.Remaining != nil {
+			remaining := math.Abs(*record.Remaining)
+			record.Remaining = &remaining
+		}
+	}
+
+	// Currency code (optional); records without one are in the base currency
+	if currencyStr := getCell("currency"); currencyStr != "" {
+		code := models.NormalizeCurrency(currencyStr)
+		if models.IsCurrencyCode(code) {
+			record.Currency = &code
+		} else {
+			warnings = append(warnings, ParseWarning{
+				Row:     rowNum,
+				Column:  "currency",
+				Message: "Invalid currency code, recording in the base currency",
+				Value:   currencyStr,
+			})
+		}
+	}
+
+	// Item cost (optional); amounts are positive for returns too
+	if costStr := getCell("cost"); costStr != "" {
+		cost, err := p.parseCurrency(costStr)
+		if err == nil && cost < 0 {
+			err = fmt.Errorf("cost cannot be negative")
+		}
+		if err != nil {
+			warnings = append(warnings, ParseWarning{
+				Row:     rowNum,
+				Column:  "cost",
+				Message: fmt.Sprintf("Invalid cost format, recording as unknown: %v", err),
+				Value:   costStr,
+			})
+		} else {
+			record.Cost = &cost
+		}
+	}
+
+	// Category (optional)
+	if category := getCell("category"); category != "" {
+		record.Category = &category
+	}
+
+	// Custom fields from unmapped columns, when they are kept
+	record.Metadata = p.rowMetadata(row)
+
+	// Show where each failed value sits in its row
+	for i := range errors {
+		if idx, exists := columnMapping[errors[i].Column]; exists {
+			errors[i].Snippet = errorSnippet(row, idx)
 		}
 	}
 	
 	return record, errors, warnings
 }
 
+// sourceRow returns the row of the source a record was parsed from, so the
+// record can be traced back to it. table is 0 unless the page has several.
+func sourceRow(table, rowNum int, headers, row []string) *models.SourceRow {
+	return &models.SourceRow{Table: table, Row: rowNum, Headers: headers, Cells: row}
+}
+
+// isReturnType reports whether a type column value marks a return or refund
+func isReturnType(value string) bool {
+	value = strings.ToLower(value)
+	return strings.Contains(value, "return") || strings.Contains(value, "refund")
+}
+
 // parseDate parses various date formats
 func (p *HTMLTableParser) parseDate(dateStr string) (string, error) {
 	// Common date formats to try
@@ -674,15 +1047,32 @@ func (p *HTMLTableParser) parseDate(dateStr string) (string, error) {
 		"January 2, 2006",
 		"2 Jan 2006",
 		"2 January 2006",
+		"2-Jan-2006",
 		"2006-01-02 15:04:05",
 		"01/02/2006 15:04:05",
+		"2006-01-02 15:04:05 -0700", // Shopify
 	}
+	dateStr = stripOrdinals(dateStr)
 	
 	for _, format := range formats {
 		if parsed, err := time.Parse(format, dateStr); err == nil {
 			return parsed.Format("2006-01-02"), nil
 		}
 	}
+
+	// Dates such as "3/15/24" (Etsy) and "Mar-15-24" (eBay)
+	if date, ok := p.parseTwoDigitYearDate(dateStr); ok {
+		return date, nil
+	}
+
+	// Dates such as "Mar 15" take the year named near the table, or the one
+	// chosen with YearInference
+	if year := p.yearlessYear(); year != 0 {
+		if date, ok := parseYearlessDate(dateStr, year); ok {
+			p.yearsInferred++
+			return date, nil
+		}
+	}
 	
 	return "", fmt.Errorf("unable to parse date: %s", dateStr)
 }
@@ -722,6 +1112,7 @@ func (p *HTMLTableParser) calculateStatistics(result *ParseResult, tableData [][
 	}
 	
 	headers := tableData[0]
+	_, types, _ := p.overriddenColumns(headers)
 	
 	// Analyze data types for each column
 	for i, header := range headers {
@@ -738,6 +1129,9 @@ func (p *HTMLTableParser) calculateStatistics(result *ParseResult, tableData [][
 		}
 		
 		dataType := p.detectDataType(sampleValues)
+		if forced, ok := types[i]; ok {
+			dataType = forced
+		}
 		result.Statistics.DataTypesDetected[header] = dataType
 	}
 }
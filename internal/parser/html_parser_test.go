@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 	"time"
@@ -82,11 +83,11 @@ func TestParseHTML_BasicTable(t *testing.T) {
 	if record1.SalePrice != 899.99 {
 		t.Errorf("Expected sale price 899.99, got %f", record1.SalePrice)
 	}
-	if record1.Commission != 89.99 {
-		t.Errorf("Expected commission 89.99, got %f", record1.Commission)
+	if record1.Commission == nil || *record1.Commission != 89.99 {
+		t.Errorf("Expected commission 89.99, got %v", record1.Commission)
 	}
-	if record1.Remaining != 810.00 {
-		t.Errorf("Expected remaining 810.00, got %f", record1.Remaining)
+	if record1.Remaining == nil || *record1.Remaining != 810.00 {
+		t.Errorf("Expected remaining 810.00, got %v", record1.Remaining)
 	}
 	
 	// Check second record with different date format
@@ -258,6 +259,80 @@ func TestParseHTML_MissingColumns(t *testing.T) {
 	}
 }
 
+// TestParseHTML_MissingCommissionColumn tests that absent amounts are unknown, not zero
+func TestParseHTML_MissingCommissionColumn(t *testing.T) {
+	htmlData := `
+	<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>$40.00</td></tr>
+	</table>`
+
+	parser := NewHTMLTableParser()
+	result, err := parser.ParseHTML(htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+
+	if len(result.Records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(result.Records))
+	}
+	if result.Records[0].Commission != nil {
+		t.Errorf("Expected nil commission, got %v", *result.Records[0].Commission)
+	}
+	if result.Records[0].Remaining != nil {
+		t.Errorf("Expected nil remaining, got %v", *result.Records[0].Remaining)
+	}
+
+	found := false
+	for _, warning := range result.Warnings {
+		if warning.Column == "commission" && warning.Row == 0 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected a warning about the missing commission column, got %v", result.Warnings)
+	}
+}
+
+// TestParseHTML_ExternalIDColumn tests capturing source transaction ids
+func TestParseHTML_ExternalIDColumn(t *testing.T) {
+	parser := NewHTMLTableParser()
+
+	htmlData := `
+	<table>
+		<tr><th>Transaction ID</th><th>Store</th><th>Vendor</th><th>Transaction Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>TX-1001</td><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Product 1</td><td>10.00</td></tr>
+		<tr><td></td><td>Store A</td><td>Vendor 1</td><td>2024-01-16</td><td>Product 2</td><td>20.00</td></tr>
+	</table>`
+
+	result, err := parser.ParseHTML(htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+
+	if idx, ok := result.ColumnMapping["external_id"]; !ok || idx != 0 {
+		t.Errorf("Expected external_id mapped to column 0, got %v (%v)", idx, ok)
+	}
+	if result.ColumnMapping["date"] != 3 {
+		t.Errorf("Expected date mapped to column 3, got %d", result.ColumnMapping["date"])
+	}
+	if len(result.Records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(result.Records))
+	}
+	if id := result.Records[0].ExternalID; id == nil || *id != "TX-1001" {
+		t.Errorf("Expected external id TX-1001, got %v", id)
+	}
+	if result.Records[1].ExternalID != nil {
+		t.Errorf("Expected nil external id for empty cell, got %q", *result.Records[1].ExternalID)
+	}
+
+	// Strict mode does not require the optional id column
+	parser.StrictMode = true
+	if _, err := parser.ParseHTML(basicTableHTML); err != nil {
+		t.Errorf("Expected strict mode to accept a table without ids: %v", err)
+	}
+}
+
 // TestParseHTML_NoTables tests handling when no tables are found
 func TestParseHTML_NoTables(t *testing.T) {
 	parser := NewHTMLTableParser()
@@ -375,6 +450,15 @@ func TestParseDate(t *testing.T) {
 		{"Jan 15, 2024", "2024-01-15", false},
 		{"January 15, 2024", "2024-01-15", false},
 		{"15 Jan 2024", "2024-01-15", false},
+		{"3/15/24", "2024-03-15", false},
+		{"15-Mar-24", "2024-03-15", false},
+		{"15-Mar-2024", "2024-03-15", false},
+		{"Mar-15-24", "2024-03-15", false},
+		{"12/31/98", "1998-12-31", false},
+		{"March 15th, 2024", "2024-03-15", false},
+		{"1st Mar 2024", "2024-03-01", false},
+		{"2/29/23", "", true},
+		{"Mar-15", "", true}, // No year near the table
 		{"invalid-date", "", true},
 		{"", "", true},
 	}
@@ -432,6 +516,9 @@ func TestLooksLikeDate(t *testing.T) {
 		{"01/15/2024", true},
 		{"Jan 15, 2024", true},
 		{"January 15, 2024", true},
+		{"March 15th, 2024", true},
+		{"3/15/24", true},
+		{"15-Mar-24", true},
 		{"not a date", false},
 		{"123.45", false},
 		{"", false},
@@ -651,11 +738,11 @@ func TestParseHTML_HeaderlessRows(t *testing.T) {
 	if record1.SalePrice != 899.99 {
 		t.Errorf("Expected sale price 899.99, got %f", record1.SalePrice)
 	}
-	if record1.Commission != 89.99 {
-		t.Errorf("Expected commission 89.99, got %f", record1.Commission)
+	if record1.Commission == nil || *record1.Commission != 89.99 {
+		t.Errorf("Expected commission 89.99, got %v", record1.Commission)
 	}
-	if record1.Remaining != 810.00 {
-		t.Errorf("Expected remaining 810.00, got %f", record1.Remaining)
+	if record1.Remaining == nil || *record1.Remaining != 810.00 {
+		t.Errorf("Expected remaining 810.00, got %v", record1.Remaining)
 	}
 	
 	// Check second record with different date format
@@ -983,7 +1070,133 @@ func TestParseHTML_ProcessingTime(t *testing.T) {
 		t.Error("Expected processing time to be recorded and greater than 0")
 	}
 	
-	if result.Statistics.ProcessingTime > time.Second {
+	if time.Duration(result.Statistics.ProcessingTime) > time.Second {
 		t.Error("Processing time seems unusually high for a simple table")
 	}
+
+	// Processing time should serialize as a readable duration, not nanoseconds
+	data, err := json.Marshal(result.Statistics)
+	if err != nil {
+		t.Fatalf("Failed to marshal statistics: %v", err)
+	}
+	if !strings.Contains(string(data), `"processing_time":"`) {
+		t.Errorf("Expected processing_time as a duration string, got %s", data)
+	}
+}
+
+func TestParseHTML_CurrencyColumn(t *testing.T) {
+	parser := NewHTMLTableParser()
+	parser.StrictMode = true
+
+	htmlData := `
+	<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th><th>Commission</th><th>Remaining</th><th>Currency</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Product 1</td><td>10.00</td><td>1.00</td><td>9.00</td><td>gbp</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-16</td><td>Product 2</td><td>20.00</td><td>2.00</td><td>18.00</td><td></td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-17</td><td>Product 3</td><td>30.00</td><td>3.00</td><td>27.00</td><td>pounds</td></tr>
+	</table>`
+
+	result, err := parser.ParseHTML(htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+
+	if result.ColumnMapping["currency"] != 7 {
+		t.Errorf("Expected currency mapped to column 7, got %d", result.ColumnMapping["currency"])
+	}
+	if len(result.Records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(result.Records))
+	}
+	if c := result.Records[0].Currency; c == nil || *c != "GBP" {
+		t.Errorf("Expected currency GBP, got %v", c)
+	}
+	if result.Records[1].Currency != nil || result.Records[2].Currency != nil {
+		t.Errorf("Expected no currency for blank and invalid codes")
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Column != "currency" {
+		t.Errorf("Expected one currency warning, got %+v", result.Warnings)
+	}
+
+	// Strict mode does not require the currency column
+	withoutCurrency := `
+	<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th><th>Commission</th><th>Remaining</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Product 1</td><td>10.00</td><td>1.00</td><td>9.00</td></tr>
+	</table>`
+	if _, err := parser.ParseHTML(withoutCurrency); err != nil {
+		t.Errorf("Expected strict mode to accept a table without a currency column: %v", err)
+	}
+}
+
+func TestParseHTML_Returns(t *testing.T) {
+	parser := NewHTMLTableParser()
+
+	htmlData := `
+	<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th><th>Commission</th><th>Type</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>25.00</td><td>2.50</td><td>Sale</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-16</td><td>Lamp</td><td>-25.00</td><td>-2.50</td><td></td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-16</td><td>Chair</td><td>(10.00)</td><td></td><td></td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-17</td><td>Table</td><td>30.00</td><td>3.00</td><td>Refund</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-17</td><td>RETURN - Rug</td><td>40.00</td><td></td><td></td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-18</td><td>Return of the Jedi DVD</td><td>5.00</td><td></td><td></td></tr>
+	</table>`
+
+	result, err := parser.ParseHTML(htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if len(result.Records) != 6 {
+		t.Fatalf("Expected 6 records, got %d (errors: %v)", len(result.Records), result.Errors)
+	}
+
+	expected := []struct {
+		isReturn  bool
+		salePrice float64
+	}{
+		{false, 25.00},
+		{true, 25.00},
+		{true, 10.00},
+		{true, 30.00},
+		{true, 40.00},
+		{false, 5.00},
+	}
+	for i, want := range expected {
+		record := result.Records[i]
+		if record.IsReturn != want.isReturn || record.SalePrice != want.salePrice {
+			t.Errorf("Record %d: expected return=%v price=%.2f, got return=%v price=%.2f",
+				i, want.isReturn, want.salePrice, record.IsReturn, record.SalePrice)
+		}
+	}
+
+	if c := result.Records[1].Commission; c == nil || *c != 2.50 {
+		t.Errorf("Expected return commission stored as 2.50, got %v", c)
+	}
+}
+
+func TestParseHTML_PaymentTypeIsNotRecordType(t *testing.T) {
+	parser := NewHTMLTableParser()
+
+	htmlData := `
+	<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th><th>Commission</th><th>Payment Type</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>25.00</td><td>2.50</td><td>Refund Card</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-16</td><td>Chair</td><td>10.00</td><td>1.00</td><td>Cash</td></tr>
+	</table>`
+
+	result, err := parser.ParseHTML(htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if idx, ok := result.ColumnMapping["record_type"]; ok {
+		t.Errorf("Expected Payment Type not mapped as record_type, got column %d", idx)
+	}
+	if len(result.Records) != 2 {
+		t.Fatalf("Expected 2 records, got %d (errors: %v)", len(result.Records), result.Errors)
+	}
+	for i, record := range result.Records {
+		if record.IsReturn {
+			t.Errorf("Record %d: expected a sale, got a return", i)
+		}
+	}
 }
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"sales-track/internal/models"
+)
+
+// ignorePattern is an ignore rule compiled for matching
+type ignorePattern struct {
+	rule    models.IgnoreRule
+	pattern *regexp.Regexp
+}
+
+// SetIgnoreRules makes the parser skip rows matching any of rules, such as
+// fee lines in a sales report. Patterns are matched ignoring case, and rows
+// matching a rule with a fee category are collected in ParseResult.Fees.
+func (p *HTMLTableParser) SetIgnoreRules(rules []models.IgnoreRule) error {
+	p.ignorePatterns = nil
+	for _, rule := range rules {
+		if rule.Field != models.IgnoreFieldDescription && rule.Field != models.IgnoreFieldStore {
+			return fmt.Errorf("ignore rule field must be %q or %q", models.IgnoreFieldDescription, models.IgnoreFieldStore)
+		}
+		if rule.FeeCategory != "" && !models.IsFeeCategory(rule.FeeCategory) {
+			return fmt.Errorf("unknown fee category %q", rule.FeeCategory)
+		}
+		pattern, err := regexp.Compile("(?i)" + rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid ignore pattern %q: %w", rule.Pattern, err)
+		}
+		p.ignorePatterns = append(p.ignorePatterns, ignorePattern{rule: rule, pattern: pattern})
+	}
+	return nil
+}
+
+// ignoreRow reports whether row matches an ignore rule, counting it against
+// the first rule it matches. A row matched by a fee rule is added to
+// result's fees.
+func (p *HTMLTableParser) ignoreRow(row []string, columnMapping map[string]int, rowNum int, result *ParseResult) bool {
+	for i, ignore := range p.ignorePatterns {
+		col, ok := columnMapping[ignore.rule.Field]
+		if !ok || col >= len(row) {
+			continue
+		}
+		if ignore.pattern.MatchString(strings.TrimSpace(row[col])) {
+			if p.ignored == nil {
+				p.ignored = make(map[int]int)
+			}
+			p.ignored[i]++
+			if ignore.rule.FeeCategory != "" {
+				if fee, ok := p.parseFee(row, columnMapping, rowNum, ignore.rule.FeeCategory); ok {
+					result.Fees = append(result.Fees, fee)
+				} else {
+					if p.unreadFees == nil {
+						p.unreadFees = make(map[int]int)
+					}
+					p.unreadFees[i]++
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// parseFee reads a row matched by a fee rule as a fee, reporting false when
+// its store, date, description or amount cannot be read. The amount is taken
+// from the sale price column, whatever its sign.
+func (p *HTMLTableParser) parseFee(row []string, columnMapping map[string]int, rowNum int, category string) (models.CreateFeeRequest, bool) {
+	record, parseErrors, _ := p.parseRow(row, columnMapping, rowNum)
+	for _, parseError := range parseErrors {
+		// Fees are charged by the store and often name no vendor
+		if parseError.Column != "vendor" {
+			return models.CreateFeeRequest{}, false
+		}
+	}
+
+	fee := models.CreateFeeRequest{
+		Store:       record.Store,
+		Date:        record.Date,
+		Category:    category,
+		Description: record.Description,
+		Amount:      math.Abs(record.SalePrice),
+	}
+	if record.Vendor != "" {
+		fee.Vendor = &record.Vendor
+	}
+	return fee, true
+}
+
+// ignoreWarnings reports how many rows of the table just parsed each ignore
+// rule skipped or recorded as fees, and starts counting afresh for the next
+// table
+func (p *HTMLTableParser) ignoreWarnings() []ParseWarning {
+	var warnings []ParseWarning
+	for i, ignore := range p.ignorePatterns {
+		count := p.ignored[i]
+		if count == 0 {
+			continue
+		}
+		rule := ignore.rule
+		if rule.FeeCategory == "" {
+			warnings = append(warnings, ParseWarning{
+				Column:  rule.Field,
+				Message: fmt.Sprintf("%d rows with a %s matching %q were ignored", count, rule.Field, rule.Pattern),
+			})
+			continue
+		}
+		if recorded := count - p.unreadFees[i]; recorded > 0 {
+			warnings = append(warnings, ParseWarning{
+				Column:  rule.Field,
+				Message: fmt.Sprintf("%d rows with a %s matching %q were recorded as %s fees", recorded, rule.Field, rule.Pattern, rule.FeeCategory),
+			})
+		}
+		if unread := p.unreadFees[i]; unread > 0 {
+			warnings = append(warnings, ParseWarning{
+				Column:  rule.Field,
+				Message: fmt.Sprintf("%d rows with a %s matching %q could not be read as fees and were ignored", unread, rule.Field, rule.Pattern),
+			})
+		}
+	}
+	p.ignored = nil
+	p.unreadFees = nil
+	return warnings
+}
@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"testing"
+
+	"sales-track/internal/models"
+)
+
+// TestParseHTML_IgnoreRules tests that rows matching an ignore rule are
+// skipped and counted in a warning rather than reported as errors
+func TestParseHTML_IgnoreRules(t *testing.T) {
+	htmlData := `<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>$40.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-31</td><td>Listing Fee</td><td>($5.00)</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td></td><td>LISTING FEE - January</td><td></td></tr>
+		<tr><td>Online</td><td>Vendor 1</td><td>2024-01-16</td><td>Postage</td><td>$4.50</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-17</td><td>Shipping Crate</td><td>$25.00</td></tr>
+	</table>`
+	rules := []models.IgnoreRule{
+		{Field: models.IgnoreFieldDescription, Pattern: "listing fee"},
+		{Field: models.IgnoreFieldStore, Pattern: "^online$"},
+	}
+
+	parser := NewHTMLTableParser()
+	if err := parser.SetIgnoreRules(rules); err != nil {
+		t.Fatalf("SetIgnoreRules failed: %v", err)
+	}
+	result, err := parser.ParseHTML(htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if len(result.Records) != 2 || result.ErrorCount != 0 {
+		t.Fatalf("Expected the lamp and crate without errors, got %+v %+v", result.Records, result.Errors)
+	}
+	if result.TotalRows != 2 || result.IgnoredRows != 3 {
+		t.Errorf("Expected 2 rows and 3 ignored, got %d and %d", result.TotalRows, result.IgnoredRows)
+	}
+
+	messages := make(map[string]string)
+	for _, warning := range result.Warnings {
+		messages[warning.Column] = warning.Message
+	}
+	if want := `2 rows with a description matching "listing fee" were ignored`; messages["description"] != want {
+		t.Errorf("Expected warning %q, got %q", want, messages["description"])
+	}
+	if want := `1 rows with a store matching "^online$" were ignored`; messages["store"] != want {
+		t.Errorf("Expected warning %q, got %q", want, messages["store"])
+	}
+
+	parser = NewHTMLTableParser()
+	if err := parser.SetIgnoreRules(rules); err != nil {
+		t.Fatalf("SetIgnoreRules failed: %v", err)
+	}
+	streamResult, streamed, err := collectStream(t, parser, htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTMLStream failed: %v", err)
+	}
+	if len(streamed) != 2 || streamResult.TotalRows != 2 || streamResult.IgnoredRows != 3 || len(streamResult.Warnings) != len(result.Warnings) {
+		t.Errorf("Expected the stream to ignore rows like ParseHTML, got %d records, %+v", len(streamed), streamResult)
+	}
+
+	if err := NewHTMLTableParser().SetIgnoreRules([]models.IgnoreRule{{Field: "vendor", Pattern: "x"}}); err == nil {
+		t.Error("Expected an error for a rule on an unsupported field")
+	}
+	if err := NewHTMLTableParser().SetIgnoreRules([]models.IgnoreRule{{Field: models.IgnoreFieldDescription, Pattern: "("}}); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+}
+
+// TestParseHTML_FeeRules tests that rows matching a rule with a fee category
+// are collected as fees, whatever the sign of their amount
+func TestParseHTML_FeeRules(t *testing.T) {
+	htmlData := `<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>$40.00</td></tr>
+		<tr><td>Store A</td><td></td><td>2024-01-31</td><td>Listing Fee</td><td>($5.00)</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td></td><td>LISTING FEE - January</td><td></td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-31</td><td>Booth Rent</td><td>$50.00</td></tr>
+	</table>`
+	rules := []models.IgnoreRule{
+		{Field: models.IgnoreFieldDescription, Pattern: "listing fee", FeeCategory: models.FeeCategoryListing},
+		{Field: models.IgnoreFieldDescription, Pattern: "rent", FeeCategory: models.FeeCategoryRent},
+	}
+
+	parser := NewHTMLTableParser()
+	if err := parser.SetIgnoreRules(rules); err != nil {
+		t.Fatalf("SetIgnoreRules failed: %v", err)
+	}
+	result, err := parser.ParseHTML(htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if len(result.Records) != 1 || result.TotalRows != 1 || result.IgnoredRows != 3 {
+		t.Fatalf("Expected only the lamp to be read as a sale, got %+v", result)
+	}
+	if len(result.Fees) != 2 {
+		t.Fatalf("Expected 2 fees, got %+v", result.Fees)
+	}
+	listing, rent := result.Fees[0], result.Fees[1]
+	if listing.Category != models.FeeCategoryListing || listing.Amount != 5 || listing.Date != "2024-01-31" || listing.Vendor != nil {
+		t.Errorf("Unexpected listing fee %+v", listing)
+	}
+	if rent.Category != models.FeeCategoryRent || rent.Amount != 50 || rent.Vendor == nil || *rent.Vendor != "Vendor 1" {
+		t.Errorf("Unexpected rent %+v", rent)
+	}
+
+	var messages []string
+	for _, warning := range result.Warnings {
+		messages = append(messages, warning.Message)
+	}
+	for _, want := range []string{
+		`1 rows with a description matching "listing fee" were recorded as listing fees`,
+		`1 rows with a description matching "listing fee" could not be read as fees and were ignored`,
+		`1 rows with a description matching "rent" were recorded as rent fees`,
+	} {
+		found := false
+		for _, message := range messages {
+			found = found || message == want
+		}
+		if !found {
+			t.Errorf("Expected warning %q, got %q", want, messages)
+		}
+	}
+
+	parser = NewHTMLTableParser()
+	if err := parser.SetIgnoreRules(rules); err != nil {
+		t.Fatalf("SetIgnoreRules failed: %v", err)
+	}
+	streamResult, _, err := collectStream(t, parser, htmlData)
+	if err != nil || len(streamResult.Fees) != 2 {
+		t.Errorf("Expected the stream to collect fees like ParseHTML, got %+v, %v", streamResult, err)
+	}
+
+	if err := NewHTMLTableParser().SetIgnoreRules([]models.IgnoreRule{{Field: models.IgnoreFieldDescription, Pattern: "x", FeeCategory: "tax"}}); err == nil {
+		t.Error("Expected an error for an unknown fee category")
+	}
+}
@@ -0,0 +1,208 @@
+package parser
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Layout is a built-in column preset for the export format of a sales platform.
+// Positional layouts read headerless tables by column order; header layouts
+// match the platform's own column names exactly instead of guessing.
+type Layout struct {
+	Name        string            `json:"name"`
+	Label       string            `json:"label"` // Platform name shown to users, such as "eBay"
+	Description string            `json:"description"`
+	Delimiter   rune              `json:"-"`                    // Field separator of delimited exports; 0 for HTML tables
+	Positional  []string          `json:"positional,omitempty"` // Column order for headerless exports
+	Headers     map[string]string `json:"headers,omitempty"`    // Lower-case export header to column
+	Defaults    map[string]string `json:"defaults,omitempty"`   // Values for columns the export does not have
+	Signature   []string          `json:"-"`                    // Distinctive unmapped headers used by DetectLayout
+}
+
+// layouts are the built-in presets, keyed by lower-case name
+var layouts = map[string]Layout{
+	"consignable": {
+		Name:        "consignable",
+		Label:       "Consignable",
+		Description: "Consignable vendor sales report (HTML table without headers)",
+		Positional:  []string{"store", "vendor", "date", "description", "sale_price", "commission", "remaining"},
+	},
+	"square": {
+		Name:        "square",
+		Label:       "Square",
+		Description: "Square item sales CSV export",
+		Delimiter:   ',',
+		Headers: map[string]string{
+			"location":   "store",
+			"date":       "date",
+			"item":       "description",
+			"net sales":  "sale_price",
+			"event type": "record_type",
+		},
+		// Square does not track a vendor per item
+		Defaults:  map[string]string{"vendor": "Square"},
+		Signature: []string{"time zone", "gross sales", "price point name", "device name", "dining option"},
+	},
+	"shopify": {
+		Name:        "shopify",
+		Label:       "Shopify",
+		Description: "Shopify order export CSV, one row per line item",
+		Delimiter:   ',',
+		Headers: map[string]string{
+			"created at":     "date",
+			"lineitem name":  "description",
+			"lineitem price": "sale_price",
+			"vendor":         "vendor",
+			"currency":       "currency",
+		},
+		Defaults:  map[string]string{"store": "Shopify"},
+		Signature: []string{"financial status", "fulfillment status", "lineitem quantity", "lineitem sku", "lineitem compare at price"},
+	},
+	"etsy": {
+		Name:        "etsy",
+		Label:       "Etsy",
+		Description: "Etsy sold order items CSV",
+		Delimiter:   ',',
+		Headers: map[string]string{
+			"sale date":      "date",
+			"item name":      "description",
+			"item total":     "sale_price",
+			"currency":       "currency",
+			"transaction id": "external_id",
+		},
+		Defaults:  map[string]string{"store": "Etsy", "vendor": "Etsy"},
+		Signature: []string{"listing id", "order shipping", "coupon details", "date shipped"},
+	},
+	"ebay": {
+		Name:        "ebay",
+		Label:       "eBay",
+		Description: "eBay orders report CSV",
+		Delimiter:   ',',
+		Headers: map[string]string{
+			"sale date":      "date",
+			"item title":     "description",
+			"sold for":       "sale_price",
+			"transaction id": "external_id",
+		},
+		Defaults:  map[string]string{"store": "eBay", "vendor": "eBay"},
+		Signature: []string{"sales record number", "buyer username", "item number", "shipping and handling"},
+	},
+}
+
+// Layouts returns the built-in layouts sorted by name
+func Layouts() []Layout {
+	result := make([]Layout, 0, len(layouts))
+	for _, layout := range layouts {
+		result = append(result, layout)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// LookupLayout finds a built-in layout by name, ignoring case
+func LookupLayout(name string) (Layout, bool) {
+	layout, ok := layouts[strings.ToLower(strings.TrimSpace(name))]
+	return layout, ok
+}
+
+// LayoutLabel returns the label of a built-in layout, or name itself when it
+// is not one
+func LayoutLabel(name string) string {
+	if layout, ok := LookupLayout(name); ok {
+		return layout.Label
+	}
+	return name
+}
+
+// SetLayout configures the parser for a built-in layout
+func (p *HTMLTableParser) SetLayout(name string) error {
+	layout, ok := LookupLayout(name)
+	if !ok {
+		return fmt.Errorf("unknown layout %q", name)
+	}
+
+	p.Layout = &layout
+	p.UsePositionalMapping = false
+	p.PositionalColumns = nil
+	if len(layout.Positional) > 0 {
+		p.SetPositionalMapping(layout.Positional)
+	}
+	return nil
+}
+
+// columnDefault returns the value for a column the export does not have: the
+// date derived from a table's heading in multi-table mode, or the layout's
+// default
+func (p *HTMLTableParser) columnDefault(column string) (string, bool) {
+	if value, ok := p.sectionDefaults[column]; ok {
+		return value, true
+	}
+	if p.Layout == nil {
+		return "", false
+	}
+	value, ok := p.Layout.Defaults[column]
+	return value, ok
+}
+
+// isDelimitedLayout reports whether data should be read as a delimited export
+// for the configured layout rather than as HTML
+func (p *HTMLTableParser) isDelimitedLayout(data string) bool {
+	if p.Layout == nil || p.Layout.Delimiter == 0 {
+		return false
+	}
+	return !strings.Contains(strings.ToLower(data), "<table") && !p.looksLikeTableRows(data)
+}
+
+// createLayoutMapping maps the headers a layout knows by exact name. Columns
+// the export does not have must be covered by the layout's defaults.
+func (p *HTMLTableParser) createLayoutMapping(headers []string) (map[string]int, error) {
+	mapping := make(map[string]int)
+	for i, header := range headers {
+		column, ok := p.Layout.Headers[strings.ToLower(strings.TrimSpace(header))]
+		if !ok {
+			continue
+		}
+		if _, mapped := mapping[column]; !mapped {
+			mapping[column] = i
+		}
+	}
+	if err := p.applyColumnOverrides(headers, mapping); err != nil {
+		return nil, err
+	}
+
+	context := fmt.Sprintf("%s layout", p.Layout.Name)
+	if err := p.validateRequiredColumns(mapping, context); err != nil {
+		return nil, fmt.Errorf("%w. Available headers: %v", err, headers)
+	}
+
+	return mapping, nil
+}
+
+// newDelimitedReader returns a lenient CSV reader for platform exports, which
+// often have ragged rows and stray quotes
+func newDelimitedReader(r io.Reader, delimiter rune) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	return reader
+}
+
+// readDelimitedRow reads the next row, dropping the UTF-8 byte order mark that
+// spreadsheet tools write before the first header
+func readDelimitedRow(reader *csv.Reader, first bool) ([]string, error) {
+	cells, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	if first && len(cells) > 0 {
+		cells[0] = strings.TrimPrefix(cells[0], "\ufeff")
+	}
+	return cells, nil
+}
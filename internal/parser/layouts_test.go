@@ -0,0 +1,179 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readFixture loads a sample export from testdata/layouts
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", "layouts", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture %s: %v", name, err)
+	}
+	return string(data)
+}
+
+// TestLayouts_Fixtures tests every built-in layout against a sample export
+func TestLayouts_Fixtures(t *testing.T) {
+	tests := []struct {
+		layout      string
+		fixture     string
+		records     int
+		store       string
+		vendor      string
+		date        string
+		description string
+		salePrice   float64
+		currency    string
+		externalID  string
+	}{
+		{"consignable", "consignable.html", 2, "Downtown Branch", "Maple Lane Pottery", "2024-03-15", "Stoneware Serving Bowl", 64.00, "", ""},
+		{"square", "square.csv", 3, "Main Street Market", "Square", "2024-03-02", "Blue Glazed Vase", 45.00, "", ""},
+		{"shopify", "shopify.csv", 3, "Shopify", "Oak & Pine Woodworks", "2024-04-10", "Walnut Cutting Board", 35.00, "USD", ""},
+		{"etsy", "etsy.csv", 2, "Etsy", "Etsy", "2024-05-03", "Hand-Thrown Mug", 44.00, "USD", "3011223344"},
+		{"ebay", "ebay.csv", 2, "eBay", "eBay", "2024-06-14", "Vintage Brass Candlestick Pair", 38.00, "", "2211334455001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			parser := NewHTMLTableParser()
+			if err := parser.SetLayout(tt.layout); err != nil {
+				t.Fatalf("SetLayout failed: %v", err)
+			}
+
+			result, err := parser.ParseHTML(readFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatalf("ParseHTML failed: %v", err)
+			}
+
+			if result.ErrorCount != 0 {
+				t.Errorf("Expected 0 errors, got %d: %v", result.ErrorCount, result.Errors)
+			}
+			if len(result.Records) != tt.records {
+				t.Fatalf("Expected %d records, got %d", tt.records, len(result.Records))
+			}
+
+			record := result.Records[0]
+			if record.Store != tt.store || record.Vendor != tt.vendor || record.Date != tt.date ||
+				record.Description != tt.description || record.SalePrice != tt.salePrice {
+				t.Errorf("Unexpected first record: %+v", record)
+			}
+
+			currency := ""
+			if record.Currency != nil {
+				currency = *record.Currency
+			}
+			if currency != tt.currency {
+				t.Errorf("Expected currency %q, got %q", tt.currency, currency)
+			}
+
+			externalID := ""
+			if record.ExternalID != nil {
+				externalID = *record.ExternalID
+			}
+			if externalID != tt.externalID {
+				t.Errorf("Expected external id %q, got %q", tt.externalID, externalID)
+			}
+		})
+	}
+}
+
+// TestLayouts_QuotedFieldsAndReturns tests CSV quoting and platform refund rows
+func TestLayouts_QuotedFieldsAndReturns(t *testing.T) {
+	parser := NewHTMLTableParser()
+	if err := parser.SetLayout("Square"); err != nil {
+		t.Fatalf("SetLayout failed: %v", err)
+	}
+
+	result, err := parser.ParseHTML(readFixture(t, "square.csv"))
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if len(result.Records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(result.Records))
+	}
+
+	if quilt := result.Records[1]; quilt.Description != "Quilt, Queen Size" || quilt.SalePrice != 110.00 {
+		t.Errorf("Expected quoted description and net price, got %+v", quilt)
+	}
+	if refund := result.Records[2]; !refund.IsReturn || refund.SalePrice != 45.00 {
+		t.Errorf("Expected a 45.00 return, got %+v", refund)
+	}
+
+	if err := parser.SetLayout("etsy"); err != nil {
+		t.Fatalf("SetLayout failed: %v", err)
+	}
+	result, err = parser.ParseHTML(readFixture(t, "etsy.csv"))
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if ring := result.Records[1]; ring.Description != `Ring, Sterling "Crescent Moon"` || *ring.Currency != "GBP" {
+		t.Errorf("Expected escaped quotes and GBP, got %+v", ring)
+	}
+}
+
+// TestLayouts_Stream tests that delimited layouts stream the same records
+func TestLayouts_Stream(t *testing.T) {
+	for _, layout := range []string{"square", "shopify", "etsy", "ebay"} {
+		t.Run(layout, func(t *testing.T) {
+			parser := NewHTMLTableParser()
+			if err := parser.SetLayout(layout); err != nil {
+				t.Fatalf("SetLayout failed: %v", err)
+			}
+
+			data := readFixture(t, layout+".csv")
+			expected, err := parser.ParseHTML(data)
+			if err != nil {
+				t.Fatalf("ParseHTML failed: %v", err)
+			}
+
+			result, records, err := collectStream(t, parser, data)
+			if err != nil {
+				t.Fatalf("ParseHTMLStream failed: %v", err)
+			}
+			if result.SuccessCount != expected.SuccessCount || len(records) != len(expected.Records) {
+				t.Fatalf("Expected %d records, streamed %d", len(expected.Records), len(records))
+			}
+			for i := range records {
+				if records[i].Description != expected.Records[i].Description || records[i].SalePrice != expected.Records[i].SalePrice {
+					t.Errorf("Record %d differs: %+v vs %+v", i, records[i], expected.Records[i])
+				}
+			}
+		})
+	}
+}
+
+// TestLayouts_Lookup tests layout listing and unknown names
+func TestLayouts_Lookup(t *testing.T) {
+	names := []string{}
+	for _, layout := range Layouts() {
+		names = append(names, layout.Name)
+	}
+	expected := []string{"consignable", "ebay", "etsy", "shopify", "square"}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected layouts %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("Expected layouts %v, got %v", expected, names)
+			break
+		}
+	}
+
+	parser := NewHTMLTableParser()
+	if err := parser.SetLayout("quickbooks"); err == nil {
+		t.Error("Expected error for unknown layout")
+	}
+
+	// Exports missing a required column name the layout in the error
+	if err := parser.SetLayout("ebay"); err != nil {
+		t.Fatalf("SetLayout failed: %v", err)
+	}
+	if _, err := parser.ParseHTML("Item Title,Sold For\nLamp,$10.00\n"); err == nil {
+		t.Error("Expected missing column error")
+	}
+}
@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"sales-track/internal/models"
+)
+
+// TableSection describes one table of a multi-table report
+type TableSection struct {
+	Table        int    `json:"table"`             // 1-based position among the tables on the page
+	Context      string `json:"context,omitempty"` // Title of the table's context: its caption, heading or section title
+	Period       string `json:"period,omitempty"`  // Month derived from the context, as YYYY-MM
+	FirstRecord  int    `json:"first_record"`      // Index in ParseResult.Records of the table's first record
+	TotalRows    int    `json:"total_rows"`
+	SuccessCount int    `json:"success_count"`
+	ErrorCount   int    `json:"error_count"`
+}
+
+// parseTables parses every table whose headers map to the required columns.
+// A table without a date column takes its records' dates from the month in
+// its caption or heading. Tables that cannot be mapped are skipped with a
+// warning.
+func (p *HTMLTableParser) parseTables(tables []*html.Node, result *ParseResult, startTime time.Time) (*ParseResult, error) {
+	var skipped []string
+
+	for i, table := range tables {
+		tableNum := i + 1
+		if len(p.findTables(table)) > 1 {
+			continue // Layout tables holding the report tables
+		}
+
+		tableData, err := p.extractTableData(table)
+		if err != nil || len(tableData) < 2 {
+			continue
+		}
+
+		context := p.extractTableContext(table)
+		section := TableSection{
+			Table:       tableNum,
+			Context:     context.Title(),
+			Period:      context.Period,
+			FirstRecord: len(result.Records),
+		}
+
+		// Rows without a date column fall on the first day of the table's month
+		p.sectionDefaults = nil
+		if section.Period != "" {
+			p.sectionDefaults = map[string]string{"date": section.Period + "-01"}
+		}
+		p.setContextYear(context)
+
+		headers, columnMapping, headerRows, err := p.mapTableHeader(table, tableData)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("table %d: %v", tableNum, err))
+			continue
+		}
+		for _, warning := range p.resolveDuplicateHeaders(headers, columnMapping, tableData[headerRows:]) {
+			warning.Table = tableNum
+			result.Warnings = append(result.Warnings, warning)
+		}
+		section.TotalRows = len(tableData) - headerRows
+		tableData = append([][]string{headers}, tableData[headerRows:]...)
+		if _, hasDate := columnMapping["date"]; !hasDate && section.Period != "" {
+			result.Warnings = append(result.Warnings, ParseWarning{
+				Table:   tableNum,
+				Column:  "date",
+				Message: fmt.Sprintf("No date column; dating records %s-01 from %q", section.Period, section.Context),
+			})
+		}
+
+		// The first mapped table provides the headers and mapping reported
+		// for the whole page
+		if len(result.Tables) == 0 {
+			if !context.IsEmpty() {
+				result.Statistics.Context = &context
+			}
+			result.Statistics.HeadersDetected = tableData[0]
+			result.ColumnMapping = columnMapping
+			result.ColumnMatches = p.explainMapping(headers, columnMapping)
+			for _, col := range optionalAmountColumns {
+				if _, exists := columnMapping[col]; !exists && !p.Defaults.covers(col) {
+					result.Warnings = append(result.Warnings, ParseWarning{
+						Row:     0,
+						Column:  col,
+						Message: fmt.Sprintf("No %s column found; values will be recorded as unknown", col),
+					})
+				}
+			}
+		}
+
+		unmapped := p.trackUnmapped(headers, columnMapping)
+		for j, row := range tableData[1:] {
+			unmapped.observe(row)
+			rowNum := j + headerRows + 1
+			if p.ignoreRow(row, columnMapping, rowNum, result) {
+				section.TotalRows--
+				result.IgnoredRows++
+				continue
+			}
+			record, parseErrors, warnings := p.parseRow(row, columnMapping, rowNum)
+			for k := range parseErrors {
+				parseErrors[k].Table = tableNum
+			}
+			for k := range warnings {
+				warnings[k].Table = tableNum
+			}
+
+			if len(parseErrors) > 0 {
+				result.Errors = append(result.Errors, parseErrors...)
+				section.ErrorCount++
+			} else {
+				record.SourceRow = sourceRow(tableNum, rowNum, headers, row)
+				if p.quarantineRecord(record, result) {
+					section.TotalRows--
+				} else {
+					result.Records = append(result.Records, record)
+					section.SuccessCount++
+				}
+			}
+			result.Warnings = append(result.Warnings, warnings...)
+		}
+		tableWarnings := append(p.yearInferenceWarning(context), p.defaultsWarnings()...)
+		for _, warning := range append(tableWarnings, p.ignoreWarnings()...) {
+			warning.Table = tableNum
+			result.Warnings = append(result.Warnings, warning)
+		}
+		unmappedColumns, unmappedWarnings := unmapped.results(tableNum)
+		result.UnmappedColumns = append(result.UnmappedColumns, unmappedColumns...)
+		result.Warnings = append(result.Warnings, unmappedWarnings...)
+
+		result.TotalRows += section.TotalRows
+		result.SuccessCount += section.SuccessCount
+		result.ErrorCount += section.ErrorCount
+		result.Tables = append(result.Tables, section)
+		p.calculateStatistics(result, tableData)
+	}
+	p.sectionDefaults = nil
+	p.contextYear = 0
+
+	if len(result.Tables) == 0 {
+		if len(skipped) > 0 {
+			return nil, fmt.Errorf("failed to map columns in any table: %s", strings.Join(skipped, "; "))
+		}
+		return nil, fmt.Errorf("no data rows found in any table")
+	}
+	for _, reason := range skipped {
+		result.Warnings = append(result.Warnings, ParseWarning{
+			Message: fmt.Sprintf("Skipped %s", reason),
+		})
+	}
+
+	result.Statistics.ProcessingTime = models.Duration(time.Since(startTime))
+	result.Statistics.ValidationTime = models.Duration(p.rowTime)
+	result.ContentHash = recordsHash(result.Records)
+	return result, nil
+}
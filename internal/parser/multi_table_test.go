@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseHTML_MultiTable tests a statement with one table per month
+func TestParseHTML_MultiTable(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "multi_table", "monthly_statement.html"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	// Only the largest table is read by default, and it has no date column
+	if _, err := NewHTMLTableParser().ParseHTML(string(data)); err == nil {
+		t.Error("Expected single-table parsing to fail without a date column")
+	}
+
+	parser := NewHTMLTableParser()
+	parser.MultiTable = true
+	result, err := parser.ParseHTML(string(data))
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+
+	if result.TotalRows != 5 || result.SuccessCount != 4 || result.ErrorCount != 1 {
+		t.Errorf("Expected 5 rows with 4 records and 1 error, got %d rows, %d records, %d errors",
+			result.TotalRows, result.SuccessCount, result.ErrorCount)
+	}
+
+	// The layout table is skipped; the others are numbered by page position
+	expected := []TableSection{
+		{Table: 2, Context: "March 2024", Period: "2024-03", FirstRecord: 0, TotalRows: 2, SuccessCount: 2},
+		{Table: 3, Context: "Sales for April, 2024", Period: "2024-04", FirstRecord: 2, TotalRows: 1, SuccessCount: 1},
+		{Table: 4, Context: "2024-05", Period: "2024-05", FirstRecord: 3, TotalRows: 2, SuccessCount: 1, ErrorCount: 1},
+	}
+	if len(result.Tables) != len(expected) {
+		t.Fatalf("Expected %d tables, got %+v", len(expected), result.Tables)
+	}
+	for i, want := range expected {
+		if result.Tables[i] != want {
+			t.Errorf("Table %d: expected %+v, got %+v", i, want, result.Tables[i])
+		}
+	}
+
+	dates := []string{"2024-03-01", "2024-03-01", "2024-04-01", "2024-05-18"}
+	for i, want := range dates {
+		if result.Records[i].Date != want {
+			t.Errorf("Record %d: expected date %s, got %s", i, want, result.Records[i].Date)
+		}
+	}
+
+	// A table with a date column still requires a date in every row
+	if len(result.Errors) != 1 || result.Errors[0].Table != 4 || result.Errors[0].Row != 3 || result.Errors[0].Column != "date" {
+		t.Errorf("Expected a date error in row 3 of table 4, got %+v", result.Errors)
+	}
+
+	var skipped bool
+	for _, warning := range result.Warnings {
+		if strings.HasPrefix(warning.Message, "Skipped table 1") {
+			skipped = true
+		}
+	}
+	if !skipped {
+		t.Errorf("Expected a warning for the skipped layout table, got %+v", result.Warnings)
+	}
+}
+
+func TestContextPeriod(t *testing.T) {
+	tests := []struct {
+		context string
+		want    string
+	}{
+		{"March 2024", "2024-03"},
+		{"Sales for Sept. 2023", "2023-09"},
+		{"Statement: dec, 2022", "2022-12"},
+		{"2024/11 payouts", "2024-11"},
+		{"Period 7/2024", "2024-07"},
+		{"Marketplace 2024", ""},
+		{"2024-13", ""},
+		{"Weekly totals", ""},
+	}
+
+	for _, tt := range tests {
+		if got := contextPeriod(tt.context); got != tt.want {
+			t.Errorf("contextPeriod(%q) = %q, want %q", tt.context, got, tt.want)
+		}
+	}
+}
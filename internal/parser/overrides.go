@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Column types a ColumnOverride can set, as reported in DataTypesDetected
+const (
+	ColumnTypeText     = "text"
+	ColumnTypeNumber   = "number"
+	ColumnTypeCurrency = "currency"
+	ColumnTypeDate     = "date"
+)
+
+// ColumnTypes are the types a ColumnOverride accepts
+var ColumnTypes = []string{ColumnTypeText, ColumnTypeNumber, ColumnTypeCurrency, ColumnTypeDate}
+
+// ColumnOverride forces how one column is read, for reports whose headers or
+// values mislead the automatic mapping, as in "column 5 is commission" or
+// "treat column 6 as text". The column is named by its header, or by its
+// position when Header is empty.
+type ColumnOverride struct {
+	Header string `json:"header,omitempty"` // Header of the column, ignoring case and surrounding spaces
+	Index  int    `json:"index,omitempty"`  // 1-based position of the column, used when Header is empty
+	Field  string `json:"field,omitempty"`  // Field the column holds, such as "commission"
+	Type   string `json:"type,omitempty"`   // One of ColumnTypes; "text" also keeps the column from being mapped to a field
+}
+
+// Validate checks that the override names a column and a known field or
+// type, and does not map a column treated as text
+func (o ColumnOverride) Validate() error {
+	switch {
+	case o.Header == "" && o.Index <= 0:
+		return fmt.Errorf("column override must name a column header or a position from 1")
+	case o.Field == "" && o.Type == "":
+		return fmt.Errorf("column override for %s must set a field or a type", o.column())
+	case o.Field != "" && !isField(o.Field):
+		return fmt.Errorf("column override for %s names unknown field %q", o.column(), o.Field)
+	case o.Type != "" && !slices.Contains(ColumnTypes, o.Type):
+		return fmt.Errorf("column override for %s has unknown type %q; must be one of %s", o.column(), o.Type, strings.Join(ColumnTypes, ", "))
+	case o.Field != "" && o.Type == ColumnTypeText:
+		return fmt.Errorf("column override for %s cannot map a column treated as text", o.column())
+	}
+	return nil
+}
+
+// column describes the column an override names, for messages
+func (o ColumnOverride) column() string {
+	if o.Header != "" {
+		return fmt.Sprintf("%q", o.Header)
+	}
+	return fmt.Sprintf("column %d", o.Index)
+}
+
+// isField reports whether field is a field header matching can map
+func isField(field string) bool {
+	_, matched := ColumnMapping[field]
+	_, exact := ExactColumnMapping[field]
+	return matched || exact
+}
+
+// SetColumnOverrides makes the parser read columns as overrides say, ahead
+// of header matching and type detection. It rejects invalid overrides and a
+// field given to two columns.
+func (p *HTMLTableParser) SetColumnOverrides(overrides []ColumnOverride) error {
+	fields := make(map[string]bool)
+	for _, override := range overrides {
+		if err := override.Validate(); err != nil {
+			return err
+		}
+		if override.Field != "" {
+			if fields[override.Field] {
+				return fmt.Errorf("column overrides give %s to more than one column", override.Field)
+			}
+			fields[override.Field] = true
+		}
+	}
+	p.columnOverrides = overrides
+	return nil
+}
+
+// overriddenColumns finds the columns of headers the overrides name and
+// returns the fields and types they set, by column index
+func (p *HTMLTableParser) overriddenColumns(headers []string) (fields map[string]int, types map[int]string, err error) {
+	fields = make(map[string]int)
+	types = make(map[int]string)
+	for _, override := range p.columnOverrides {
+		idx := override.Index - 1
+		if override.Header != "" {
+			idx = slices.IndexFunc(headers, func(header string) bool {
+				return strings.EqualFold(strings.TrimSpace(header), strings.TrimSpace(override.Header))
+			})
+		}
+		if idx < 0 || idx >= len(headers) {
+			return nil, nil, fmt.Errorf("column override names %s, which the table does not have", override.column())
+		}
+		if override.Field != "" {
+			fields[override.Field] = idx
+		}
+		if override.Type != "" {
+			types[idx] = override.Type
+		}
+	}
+	return fields, types, nil
+}
+
+// applyColumnOverrides changes mapping as the overrides say: a field moves
+// to the column given it, and columns treated as text, or given to another
+// field, no longer hold the fields they were matched to
+func (p *HTMLTableParser) applyColumnOverrides(headers []string, mapping map[string]int) error {
+	if len(p.columnOverrides) == 0 {
+		return nil
+	}
+	fields, types, err := p.overriddenColumns(headers)
+	if err != nil {
+		return err
+	}
+
+	taken := make(map[int]bool, len(fields))
+	for _, idx := range fields {
+		taken[idx] = true
+	}
+	for field, idx := range mapping {
+		if _, overridden := fields[field]; overridden {
+			continue
+		}
+		if taken[idx] || types[idx] == ColumnTypeText {
+			delete(mapping, field)
+		}
+	}
+	for field, idx := range fields {
+		mapping[field] = idx
+	}
+	return nil
+}
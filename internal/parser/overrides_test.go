@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+// overrideTableHTML has a commission column whose header no field matches
+// and a "Sale #" column that header matching takes for the sale price
+const overrideTableHTML = `<table>
+	<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale #</th><th>Consignor Cut</th><th>Price</th></tr>
+	<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>1001</td><td>4.00</td><td>40.00</td></tr>
+</table>`
+
+// TestColumnOverrides tests forcing fields and types on columns by header and position
+func TestColumnOverrides(t *testing.T) {
+	parser := NewHTMLTableParser()
+	err := parser.SetColumnOverrides([]ColumnOverride{
+		{Index: 6, Field: "commission"},
+		{Header: " sale # ", Type: ColumnTypeText},
+		{Header: "Price", Field: "sale_price", Type: ColumnTypeCurrency},
+	})
+	if err != nil {
+		t.Fatalf("SetColumnOverrides failed: %v", err)
+	}
+
+	result, err := parser.ParseHTML(overrideTableHTML)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if len(result.Records) != 1 {
+		t.Fatalf("Expected 1 record, got %+v", result.Errors)
+	}
+	record := result.Records[0]
+	if record.SalePrice != 40.00 || record.Commission == nil || *record.Commission != 4.00 {
+		t.Errorf("Expected a sale price of 40.00 and commission of 4.00, got %+v", record)
+	}
+	if result.ColumnMapping["commission"] != 5 || result.ColumnMapping["sale_price"] != 6 {
+		t.Errorf("Unexpected mapping: %v", result.ColumnMapping)
+	}
+	if match := result.ColumnMatches["commission"]; match.Method != MatchOverride || match.Header != "Consignor Cut" {
+		t.Errorf("Expected the commission match to be an override, got %+v", match)
+	}
+	if got := result.Statistics.DataTypesDetected["Sale #"]; got != ColumnTypeText {
+		t.Errorf("Expected Sale # to be reported as text, got %q", got)
+	}
+
+	// A column the table lacks fails the mapping
+	if err := parser.SetColumnOverrides([]ColumnOverride{{Header: "Payout", Field: "commission"}}); err != nil {
+		t.Fatalf("SetColumnOverrides failed: %v", err)
+	}
+	if _, err := parser.ParseHTML(overrideTableHTML); err == nil || !strings.Contains(err.Error(), `"Payout"`) {
+		t.Errorf("Expected an error for a missing column, got %v", err)
+	}
+
+	// Treating a required column as text leaves the field unmapped
+	if err := parser.SetColumnOverrides([]ColumnOverride{{Header: "Store", Type: ColumnTypeText}}); err != nil {
+		t.Fatalf("SetColumnOverrides failed: %v", err)
+	}
+	if _, err := parser.ParseHTML(overrideTableHTML); err == nil {
+		t.Error("Expected an error when the store column is treated as text")
+	}
+}
+
+// TestSetColumnOverrides_Invalid tests that invalid overrides are rejected
+func TestSetColumnOverrides_Invalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides []ColumnOverride
+	}{
+		{"no column", []ColumnOverride{{Field: "commission"}}},
+		{"nothing set", []ColumnOverride{{Index: 2}}},
+		{"unknown field", []ColumnOverride{{Index: 2, Field: "payout"}}},
+		{"unknown type", []ColumnOverride{{Index: 2, Type: "boolean"}}},
+		{"mapped text", []ColumnOverride{{Index: 2, Field: "vendor", Type: ColumnTypeText}}},
+		{"field twice", []ColumnOverride{{Index: 2, Field: "vendor"}, {Index: 3, Field: "vendor"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := NewHTMLTableParser().SetColumnOverrides(tt.overrides); err == nil {
+				t.Errorf("Expected %+v to be rejected", tt.overrides)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package parser
+
+import "strings"
+
+// parseMemoryFactor is about how many bytes of memory ParseHTML holds for
+// each byte of its input: the DOM, the cleaned copy of the input and the
+// parsed records. A 10 MB report of 100,000 rows held about 350 MB.
+const parseMemoryFactor = 35
+
+// EstimateParseMemory returns about how much memory ParseHTML needs to parse
+// size bytes of input. ParseHTMLStream needs about the same at any size.
+func EstimateParseMemory(size int) int64 {
+	return int64(size) * parseMemoryFactor
+}
+
+// CanStream reports whether ParseHTMLStream can read data the way p is
+// configured: as a delimited layout, or as an HTML table whose first row is
+// the header. Multi-table reports, headerless rows read by position and pasted
+// rows without a table need ParseHTML. The stream reads the first table on the
+// page where ParseHTML reads the largest.
+func (p *HTMLTableParser) CanStream(data string) bool {
+	if p.MultiTable || p.UsePositionalMapping {
+		return false
+	}
+	if p.Layout != nil && p.Layout.Delimiter != 0 {
+		return true
+	}
+
+	// Saved pages may start with long styles and scripts, so the whole input
+	// is searched, without copying it to change its case
+	const tag = "<table"
+	for {
+		i := strings.IndexByte(data, '<')
+		if i < 0 {
+			return false
+		}
+		data = data[i:]
+		if len(data) >= len(tag) && strings.EqualFold(data[:len(tag)], tag) {
+			return true
+		}
+		data = data[1:]
+	}
+}
@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"testing"
+
+	"sales-track/internal/models"
+)
+
+// TestCanStream tests telling which inputs the streaming parser reads
+func TestCanStream(t *testing.T) {
+	table := `<html><body><TABLE><tr><th>Store</th></tr></TABLE></body></html>`
+	rows := "Store\tVendor\tDate\nStore A\tVendor 1\t2024-03-15"
+
+	if !NewHTMLTableParser().CanStream(table) {
+		t.Error("Expected an HTML table to stream")
+	}
+	if NewHTMLTableParser().CanStream(rows) {
+		t.Error("Expected tab-delimited rows without a layout to need ParseHTML")
+	}
+
+	multi := NewHTMLTableParser()
+	multi.MultiTable = true
+	if multi.CanStream(table) {
+		t.Error("Expected a multi-table report to need ParseHTML")
+	}
+
+	positional := NewHTMLTableParser()
+	positional.UsePositionalMapping = true
+	if positional.CanStream(table) {
+		t.Error("Expected headerless rows read by position to need ParseHTML")
+	}
+
+	mapped := NewHTMLTableParser()
+	if err := mapped.SetCSVMapping(models.CSVMapping{
+		Delimiter: ",",
+		Columns:   map[string]string{"Store": "store", "Vendor": "vendor", "Date": "date", "Item": "description", "Price": "sale_price"},
+	}); err != nil {
+		t.Fatalf("SetCSVMapping failed: %v", err)
+	}
+	if !mapped.CanStream("Store,Vendor,Date,Item,Price") {
+		t.Error("Expected CSV read through a mapping to stream")
+	}
+
+	if got := EstimateParseMemory(10 << 20); got < 100<<20 {
+		t.Errorf("Expected a 10 MB report to need far more memory than its size, got %d bytes", got)
+	}
+}
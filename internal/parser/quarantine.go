@@ -0,0 +1,23 @@
+package parser
+
+import (
+	"time"
+
+	"sales-track/internal/models"
+)
+
+// quarantineRecord reports whether record fails a business-rule check and
+// should be set aside for review, adding it to the result's quarantined rows
+// when it does. It never quarantines unless the parser's Quarantine option is
+// set.
+func (p *HTMLTableParser) quarantineRecord(record models.CreateSalesRecordRequest, result *ParseResult) bool {
+	if !p.Quarantine {
+		return false
+	}
+	reasons := models.CheckBusinessRules(record, time.Now())
+	if len(reasons) == 0 {
+		return false
+	}
+	result.Quarantined = append(result.Quarantined, models.QuarantinedRow{Record: record, Reasons: reasons})
+	return true
+}
@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestParseHTML_Quarantine tests that rows failing a business-rule check are
+// set aside with their reasons when the Quarantine option is set
+func TestParseHTML_Quarantine(t *testing.T) {
+	htmlData := `<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th><th>Commission</th><th>Remaining</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>$40.00</td><td>$10.00</td><td>$30.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2999-01-15</td><td>Vase</td><td>$20.00</td><td>$5.00</td><td>$15.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-16</td><td>Rug</td><td>$10.00</td><td>$100.00</td><td>$90.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-17</td><td>Crate</td><td>$25.00</td><td>$5.00</td><td>$20.00</td></tr>
+	</table>`
+
+	result, err := NewHTMLTableParser().ParseHTML(htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if len(result.Records) != 4 || len(result.Quarantined) != 0 {
+		t.Fatalf("Expected every row imported without the option, got %d records and %d quarantined", len(result.Records), len(result.Quarantined))
+	}
+
+	parser := NewHTMLTableParser()
+	parser.Quarantine = true
+	result, err = parser.ParseHTML(htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if len(result.Records) != 2 || result.TotalRows != 2 || result.SuccessCount != 2 || result.ErrorCount != 0 {
+		t.Fatalf("Expected the lamp and crate as the only rows, got %d records, %+v", len(result.Records), result)
+	}
+	if len(result.Quarantined) != 2 {
+		t.Fatalf("Expected 2 quarantined rows, got %+v", result.Quarantined)
+	}
+	vase, rug := result.Quarantined[0], result.Quarantined[1]
+	if vase.Record.Description != "Vase" || len(vase.Reasons) != 1 || !strings.Contains(vase.Reasons[0], "future") {
+		t.Errorf("Expected the vase quarantined for its future date, got %+v", vase)
+	}
+	if rug.Record.Description != "Rug" || len(rug.Reasons) != 2 || rug.Record.SourceRow == nil || rug.Record.SourceRow.Row != 4 {
+		t.Errorf("Expected the rug quarantined for its commission and remaining with its source row, got %+v", rug)
+	}
+
+	streamResult, streamed, err := collectStream(t, parser, htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTMLStream failed: %v", err)
+	}
+	if len(streamed) != 2 || streamResult.TotalRows != 2 || len(streamResult.Quarantined) != 2 {
+		t.Errorf("Expected the stream to quarantine rows like ParseHTML, got %d records, %+v", len(streamed), streamResult)
+	}
+}
@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Bounds of the snippet of a failed row kept with a parse error
+const (
+	snippetNeighbors = 1  // Cells shown on each side of the failed cell
+	maxSnippetCell   = 48 // Bytes of escaped text kept of each cell
+
+	maxSnippetLength = (2*snippetNeighbors+1)*(len("<td></td>")+maxSnippetCell+len("…")) + 2*len("…")
+)
+
+// errorSnippet returns the cell at col of row with its neighbours, written as
+// the table cells they were read from, such as
+// "<td>Vendor 1</td><td>not a date</td><td>Lamp</td>", to show where a value
+// that failed to parse sits in its row. Text is escaped, characters that do
+// not print are written as \u escapes so spaces and marks pasted with the
+// data can be seen, and long cells are cut short. A col past the end of a
+// short row shows its last cells.
+func errorSnippet(row []string, col int) string {
+	if len(row) == 0 || col < 0 {
+		return ""
+	}
+	col = min(col, len(row)-1)
+	start := max(col-snippetNeighbors, 0)
+	end := min(col+snippetNeighbors+1, len(row))
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for _, cell := range row[start:end] {
+		b.WriteString("<td>")
+		b.WriteString(snippetText(cell))
+		b.WriteString("</td>")
+	}
+	if end < len(row) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// snippetText escapes a cell's text for a snippet, cutting it short at
+// maxSnippetCell bytes
+func snippetText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		var piece string
+		switch {
+		case r == utf8.RuneError:
+			piece = `�`
+		case r != ' ' && !unicode.IsPrint(r):
+			piece = fmt.Sprintf(`\u%04x`, r)
+		default:
+			piece = html.EscapeString(string(r))
+		}
+		if b.Len()+len(piece) > maxSnippetCell {
+			b.WriteString("…")
+			break
+		}
+		b.WriteString(piece)
+	}
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TestErrorSnippet tests the snippets of failed cells
+func TestErrorSnippet(t *testing.T) {
+	row := []string{"Store A", "Vendor 1", "not a date", "Lamp", "$10.00"}
+
+	tests := []struct {
+		name     string
+		row      []string
+		col      int
+		expected string
+	}{
+		{"middle", row, 2, "…<td>Vendor 1</td><td>not a date</td><td>Lamp</td>…"},
+		{"first", row, 0, "<td>Store A</td><td>Vendor 1</td>…"},
+		{"past a short row", row, 9, "…<td>Lamp</td><td>$10.00</td>"},
+		{"escaped", []string{"<b>Tom & Jerry</b>"}, 0, "<td>&lt;b&gt;Tom &amp; Jerry&lt;/b&gt;</td>"},
+		{"invisible", []string{"12\u00a0.00\u200b"}, 0, `<td>12\u00a0.00\u200b</td>`},
+		{"long", []string{strings.Repeat("x", 60)}, 0, "<td>" + strings.Repeat("x", maxSnippetCell) + "…</td>"},
+		{"empty row", nil, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorSnippet(tt.row, tt.col); got != tt.expected {
+				t.Errorf("errorSnippet() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestParseHTML_ErrorSnippet tests that failed rows show the cells around the failed value
+func TestParseHTML_ErrorSnippet(t *testing.T) {
+	htmlData := `<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-15</td><td>Lamp</td><td>$10.<span>00</span>&nbsp;USD</td></tr>
+	</table>`
+
+	result, err := NewHTMLTableParser().ParseHTML(htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if len(result.Errors) != 1 {
+		t.Fatalf("Expected 1 parse error, got %+v", result.Errors)
+	}
+	if snippet := result.Errors[0].Snippet; snippet != `…<td>Lamp</td><td>$10.00\u00a0USD</td>` {
+		t.Errorf("Unexpected snippet %q", snippet)
+	}
+}
+
+// FuzzErrorSnippet checks that snippets of any row stay short, valid UTF-8
+// and free of markup and characters that do not print
+func FuzzErrorSnippet(f *testing.F) {
+	f.Add("Store A", "not a date", "<td>\x00\xff</td>", 1)
+	f.Add("", "\u200b\u00a0", strings.Repeat("&", 100), 5)
+	f.Add("\U0001F600", "\r\n\t", "x", -1)
+
+	f.Fuzz(func(t *testing.T, a, b, c string, col int) {
+		snippet := errorSnippet([]string{a, b, c}, col)
+		if len(snippet) > maxSnippetLength {
+			t.Errorf("Snippet of %d bytes is longer than %d: %q", len(snippet), maxSnippetLength, snippet)
+		}
+		if !utf8.ValidString(snippet) {
+			t.Errorf("Snippet is not valid UTF-8: %q", snippet)
+		}
+		text := strings.NewReplacer("<td>", "", "</td>", "").Replace(snippet)
+		if strings.ContainsAny(text, "<>") {
+			t.Errorf("Snippet text is not escaped: %q", snippet)
+		}
+		for _, r := range text {
+			if r != ' ' && !unicode.IsPrint(r) {
+				t.Errorf("Snippet holds %U: %q", r, snippet)
+			}
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sales-track/internal/models"
+)
+
+// TestParseHTML_SourceRow tests that records keep the row they were parsed from
+func TestParseHTML_SourceRow(t *testing.T) {
+	htmlData := `<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>not a date</td><td>Lamp</td><td>$10.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-01-16</td><td>Chair</td><td>$1,020.00</td></tr>
+	</table>`
+
+	result, err := NewHTMLTableParser().ParseHTML(htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+	if len(result.Records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(result.Records))
+	}
+
+	// Rows count the header, and rows that failed to parse keep their number
+	expected := &models.SourceRow{
+		Row:     3,
+		Headers: []string{"Store", "Vendor", "Date", "Description", "Sale Price"},
+		Cells:   []string{"Store A", "Vendor 1", "2024-01-16", "Chair", "$1,020.00"},
+	}
+	if !reflect.DeepEqual(result.Records[0].SourceRow, expected) {
+		t.Errorf("Expected source row %+v, got %+v", expected, result.Records[0].SourceRow)
+	}
+
+	_, streamed, err := collectStream(t, NewHTMLTableParser(), htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTMLStream failed: %v", err)
+	}
+	if len(streamed) != 1 || !reflect.DeepEqual(streamed[0].SourceRow, expected) {
+		t.Errorf("Expected the streamed record to keep the same source row, got %+v", streamed)
+	}
+}
+
+// TestParseHTML_MultiTableSourceRow tests that records of multi-table reports
+// keep the table they were parsed from
+func TestParseHTML_MultiTableSourceRow(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "multi_table", "monthly_statement.html"))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+
+	parser := NewHTMLTableParser()
+	parser.MultiTable = true
+	result, err := parser.ParseHTML(string(data))
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+
+	for i, section := range result.Tables {
+		end := len(result.Records)
+		if i+1 < len(result.Tables) {
+			end = result.Tables[i+1].FirstRecord
+		}
+		for _, record := range result.Records[section.FirstRecord:end] {
+			if record.SourceRow == nil || record.SourceRow.Table != section.Table || record.SourceRow.Row < 2 {
+				t.Errorf("Expected a source row in table %d, got %+v", section.Table, record.SourceRow)
+			}
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"sales-track/internal/models"
+)
+
+// ParseHTMLStream parses the first HTML table in r without building a DOM,
+// sending each valid record to out as soon as its row is complete. out is
+// closed when parsing finishes, fails, or ctx is cancelled.
+//
+// The returned ParseResult carries counts, errors, warnings, and the column
+// mapping, but its Records slice is left empty so memory use does not grow
+// with the size of the input. Unlike ParseHTML, the input must contain a
+// <table> element whose first row is the header row, unless a layout for a
+// delimited export is configured.
+func (p *HTMLTableParser) ParseHTMLStream(ctx context.Context, r io.Reader, out chan<- models.CreateSalesRecordRequest) (*ParseResult, error) {
+	defer close(out)
+
+	startTime := time.Now()
+	p.rowTime = 0
+
+	result := &ParseResult{
+		SchemaVersion: SchemaVersion,
+		Records:       []models.CreateSalesRecordRequest{},
+		ColumnMapping: make(map[string]int),
+		Statistics: ParseStatistics{
+			DataTypesDetected: make(map[string]string),
+			ValueRanges:       make(map[string]ValueRange),
+		},
+	}
+
+	if p.MultiTable {
+		return nil, fmt.Errorf("multi-table reports cannot be streamed; use ParseHTML")
+	}
+
+	rows := &streamRows{p: p, ctx: ctx, out: out, result: result}
+
+	// Delimited platform exports are read row by row with a CSV reader
+	if p.Layout != nil && p.Layout.Delimiter != 0 {
+		return p.parseDelimitedStream(r, rows, startTime)
+	}
+
+	tokenizer := html.NewTokenizer(r)
+
+	var (
+		tableDepth int
+		inCell     bool
+		cell       strings.Builder
+		cellTag    string
+		cellAttrs  []html.Attribute
+		row        []headerCell
+
+		// Caption and last heading before the table
+		context   TableContext
+		inContext bool
+		text      strings.Builder
+	)
+
+	// handleRow processes the row collected so far. The table's context is
+	// complete by the header row, so dates in the data rows can use its year.
+	handleRow := func() error {
+		if len(row) == 0 {
+			return nil
+		}
+		if !rows.headerSeen {
+			context.resolveDates()
+			if !context.IsEmpty() {
+				result.Statistics.Context = &context
+			}
+			p.setContextYear(context)
+		}
+		cells := row
+		row = nil
+		return rows.handle(cells)
+	}
+
+	// finish completes the result once the table has been read
+	finish := func() (*ParseResult, error) {
+		result.Warnings = append(result.Warnings, p.yearInferenceWarning(context)...)
+		p.contextYear = 0
+		return rows.finish(startTime)
+	}
+
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return nil, fmt.Errorf("failed to parse HTML: %w", err)
+			}
+			if result.Statistics.TablesFound == 0 {
+				return nil, fmt.Errorf("no HTML tables found in the provided data")
+			}
+			// Unterminated table: flush whatever row was in progress
+			if err := handleRow(); err != nil {
+				return nil, err
+			}
+			return finish()
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "table":
+				tableDepth++
+				if tableDepth == 1 {
+					result.Statistics.TablesFound++
+				}
+			case "tr":
+				if tableDepth == 1 {
+					if err := handleRow(); err != nil {
+						return nil, err
+					}
+				}
+			case "td", "th":
+				if tableDepth == 1 {
+					inCell = true
+					cell.Reset()
+					cellTag = string(name)
+					cellAttrs = cellAttrs[:0]
+					for hasAttr := true; hasAttr; {
+						var key, val []byte
+						key, val, hasAttr = tokenizer.TagAttr()
+						if len(key) > 0 {
+							cellAttrs = append(cellAttrs, html.Attribute{Key: string(key), Val: string(val)})
+						}
+					}
+				}
+			case "caption":
+				if tableDepth == 1 {
+					inContext = true
+					text.Reset()
+				}
+			case "h1", "h2", "h3", "h4":
+				if tableDepth == 0 {
+					inContext = true
+					text.Reset()
+				}
+			}
+
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "td", "th":
+				if tableDepth == 1 && inCell {
+					row = append(row, newHeaderCell(cellTag, strings.TrimSpace(cell.String()), cellAttrs))
+					inCell = false
+				}
+			case "caption":
+				if tableDepth == 1 && inContext {
+					context.Caption = normalizeSpace(text.String())
+					inContext = false
+				}
+			case "h1", "h2", "h3", "h4":
+				if tableDepth == 0 && inContext {
+					context.Heading = normalizeSpace(text.String())
+					inContext = false
+				}
+			case "tr":
+				if tableDepth == 1 {
+					if err := handleRow(); err != nil {
+						return nil, err
+					}
+				}
+			case "table":
+				tableDepth--
+				if tableDepth == 0 {
+					// Only the first table is imported
+					if err := handleRow(); err != nil {
+						return nil, err
+					}
+					return finish()
+				}
+			}
+
+		case html.TextToken:
+			if inCell {
+				cell.Write(tokenizer.Text())
+			} else if inContext {
+				text.Write(tokenizer.Text())
+			}
+		}
+	}
+}
+
+// streamRows maps the header row and parses data rows as they arrive
+type streamRows struct {
+	p          *HTMLTableParser
+	ctx        context.Context
+	out        chan<- models.CreateSalesRecordRequest
+	result     *ParseResult
+	headerSeen bool
+	top        []headerCell // First row, held until the second shows whether the header continues
+	rowNum     int
+	unmapped   *unmappedTracker
+	waited     time.Duration // Blocked sending records to out
+	digest     contentDigest // Records sent to out, for the content hash
+}
+
+// handle processes a completed row; the first row, or the first two when
+// the header is split over them, provides the headers
+func (s *streamRows) handle(cells []headerCell) error {
+	result := s.result
+
+	if !s.headerSeen {
+		s.headerSeen = true
+		s.top = cells
+		s.rowNum = 1
+		return nil
+	}
+	if s.top != nil {
+		merged, err := s.mapHeader(cells)
+		if err != nil || merged {
+			return err
+		}
+	}
+
+	s.rowNum++
+	row := cellTexts(cells)
+	s.unmapped.observe(row)
+	if s.p.ignoreRow(row, result.ColumnMapping, s.rowNum, result) {
+		result.IgnoredRows++
+		return nil
+	}
+	result.TotalRows++
+
+	record, parseErrors, warnings := s.p.parseRow(row, result.ColumnMapping, s.rowNum)
+	if len(warnings) > 0 {
+		result.Warnings = append(result.Warnings, warnings...)
+	}
+	if len(parseErrors) > 0 {
+		result.Errors = append(result.Errors, parseErrors...)
+		result.ErrorCount++
+		return nil
+	}
+	record.SourceRow = sourceRow(0, s.rowNum, result.Statistics.HeadersDetected, row)
+	if s.p.quarantineRecord(record, result) {
+		result.TotalRows--
+		return nil
+	}
+
+	sending := time.Now()
+	defer func() { s.waited += time.Since(sending) }()
+	select {
+	case s.out <- record:
+		result.SuccessCount++
+		s.digest.add(record)
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// parseDelimitedStream reads a delimited export for the configured layout
+func (p *HTMLTableParser) parseDelimitedStream(r io.Reader, rows *streamRows, startTime time.Time) (*ParseResult, error) {
+	reader := newDelimitedReader(r, p.Layout.Delimiter)
+
+	for first := true; ; first = false {
+		cells, err := readDelimitedRow(reader, first)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse delimited data: %w", err)
+		}
+		if err := rows.handle(plainCells(cells)); err != nil {
+			return nil, err
+		}
+	}
+
+	return rows.finish(startTime)
+}
+
+// mapHeader maps the held first row, merging second into it when the header
+// continues there. second is nil when the table ended after the first row.
+func (s *streamRows) mapHeader(second []headerCell) (bool, error) {
+	result := s.result
+
+	headers, columnMapping, merged, err := s.p.mapHeader(s.top, second)
+	s.top = nil
+	result.Statistics.HeadersDetected = headers
+	if err != nil {
+		return false, fmt.Errorf("failed to map columns: %w", err)
+	}
+	// Only the row after the header has been read to tell columns apart
+	var sample [][]string
+	if second != nil && !merged {
+		sample = [][]string{cellTexts(second)}
+	}
+	result.Warnings = append(result.Warnings, s.p.resolveDuplicateHeaders(headers, columnMapping, sample)...)
+	result.ColumnMapping = columnMapping
+	result.ColumnMatches = s.p.explainMapping(headers, columnMapping)
+	s.unmapped = s.p.trackUnmapped(headers, columnMapping)
+
+	for _, col := range optionalAmountColumns {
+		if _, exists := columnMapping[col]; !exists && !s.p.Defaults.covers(col) {
+			result.Warnings = append(result.Warnings, ParseWarning{
+				Row:     0,
+				Column:  col,
+				Message: fmt.Sprintf("No %s column found; values will be recorded as unknown", col),
+			})
+		}
+	}
+	if merged {
+		s.rowNum = 2
+	}
+	return merged, nil
+}
+
+// finish validates and completes a streaming parse result
+func (s *streamRows) finish(startTime time.Time) (*ParseResult, error) {
+	if !s.headerSeen {
+		return nil, fmt.Errorf("no data rows found in table")
+	}
+	if s.top != nil {
+		if _, err := s.mapHeader(nil); err != nil {
+			return nil, err
+		}
+	}
+	unmappedColumns, unmappedWarnings := s.unmapped.results(0)
+	s.result.UnmappedColumns = unmappedColumns
+	s.result.Warnings = append(s.result.Warnings, unmappedWarnings...)
+	s.result.Warnings = append(s.result.Warnings, s.p.defaultsWarnings()...)
+	s.result.Warnings = append(s.result.Warnings, s.p.ignoreWarnings()...)
+	s.result.Statistics.ProcessingTime = models.Duration(time.Since(startTime))
+	s.result.Statistics.ValidationTime = models.Duration(s.p.rowTime)
+	s.result.Statistics.WaitTime = models.Duration(s.waited)
+	s.result.ContentHash = s.digest.sum()
+	return s.result, nil
+}
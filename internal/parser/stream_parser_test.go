@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sales-track/internal/models"
+)
+
+// collectStream runs ParseHTMLStream and gathers the streamed records
+func collectStream(t *testing.T, parser *HTMLTableParser, htmlData string) (*ParseResult, []models.CreateSalesRecordRequest, error) {
+	t.Helper()
+
+	out := make(chan models.CreateSalesRecordRequest)
+	done := make(chan struct{})
+	var records []models.CreateSalesRecordRequest
+	go func() {
+		for record := range out {
+			records = append(records, record)
+		}
+		close(done)
+	}()
+
+	result, err := parser.ParseHTMLStream(context.Background(), strings.NewReader(htmlData), out)
+	<-done
+	return result, records, err
+}
+
+// TestParseHTMLStream_MatchesParseHTML tests that streaming yields the same records as ParseHTML
+func TestParseHTMLStream_MatchesParseHTML(t *testing.T) {
+	parser := NewHTMLTableParser()
+
+	expected, err := parser.ParseHTML(basicTableHTML)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+
+	result, records, err := collectStream(t, parser, basicTableHTML)
+	if err != nil {
+		t.Fatalf("ParseHTMLStream failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(records, expected.Records) {
+		t.Errorf("Streamed records differ from ParseHTML:\n got: %+v\nwant: %+v", records, expected.Records)
+	}
+	if result.TotalRows != expected.TotalRows || result.SuccessCount != expected.SuccessCount {
+		t.Errorf("Expected %d/%d rows, got %d/%d", expected.SuccessCount, expected.TotalRows, result.SuccessCount, result.TotalRows)
+	}
+	if len(result.Records) != 0 {
+		t.Errorf("Expected streamed result to hold no records, got %d", len(result.Records))
+	}
+}
+
+// TestParseHTMLStream_RowErrors tests that invalid rows are reported and skipped
+func TestParseHTMLStream_RowErrors(t *testing.T) {
+	htmlData := `
+	<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td></td><td>Test Vendor</td><td>invalid-date</td><td></td><td>not-a-number</td></tr>
+		<tr><td>Valid Store</td><td>Valid Vendor</td><td>2024-01-15</td><td>Valid <b>Product</b></td><td>100.00</td></tr>
+	</table>
+	<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Second Table</td><td>Ignored</td><td>2024-01-16</td><td>Ignored</td><td>1.00</td></tr>
+	</table>`
+
+	result, records, err := collectStream(t, NewHTMLTableParser(), htmlData)
+	if err != nil {
+		t.Fatalf("ParseHTMLStream failed: %v", err)
+	}
+
+	if result.TotalRows != 2 || result.ErrorCount != 1 || result.SuccessCount != 1 {
+		t.Errorf("Expected 2 rows with 1 error, got total=%d errors=%d success=%d",
+			result.TotalRows, result.ErrorCount, result.SuccessCount)
+	}
+	if len(records) != 1 || records[0].Description != "Valid Product" {
+		t.Errorf("Expected only the valid record from the first table, got %+v", records)
+	}
+}
+
+// TestParseHTMLStream_NoTable tests that input without a table is rejected
+func TestParseHTMLStream_NoTable(t *testing.T) {
+	_, records, err := collectStream(t, NewHTMLTableParser(), "<p>No tables here</p>")
+	if err == nil {
+		t.Error("Expected error for input without a table")
+	}
+	if len(records) != 0 {
+		t.Errorf("Expected no records, got %d", len(records))
+	}
+}
+
+// TestParseHTMLStream_Cancel tests that a cancelled context stops the parser
+func TestParseHTMLStream_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Unbuffered and never read, so the first record can only be abandoned
+	out := make(chan models.CreateSalesRecordRequest)
+	_, err := NewHTMLTableParser().ParseHTMLStream(ctx, strings.NewReader(basicTableHTML), out)
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if _, open := <-out; open {
+		t.Error("Expected output channel to be closed")
+	}
+}
@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"sales-track/internal/models"
+)
+
+// UnmappedColumn is a column holding data that no field was mapped to. Its
+// values are not imported unless KeepUnmappedColumns is set.
+type UnmappedColumn struct {
+	Table  int    `json:"table,omitempty"`  // 1-based table number in multi-table mode
+	Column int    `json:"column"`           // Index of the column
+	Header string `json:"header"`           // Header of the column; may be empty
+	Values int    `json:"values"`           // Number of data rows with a value in the column
+	Sample string `json:"sample,omitempty"` // First value found
+}
+
+// Name returns the header, or the column's position when it has none
+func (c UnmappedColumn) Name() string {
+	if c.Header != "" {
+		return c.Header
+	}
+	return fmt.Sprintf("column %d", c.Column+1)
+}
+
+// unmappedTracker counts the values in the columns of a table that no field
+// was mapped to
+type unmappedTracker struct {
+	headers []string
+	mapped  map[int]bool
+	columns map[int]*UnmappedColumn
+	kept    bool // Values are kept in record metadata rather than dropped
+}
+
+// trackUnmapped tracks the columns of headers missing from mapping. With
+// KeepUnmappedColumns set, it also makes parseRow keep their values in each
+// record's metadata.
+func (p *HTMLTableParser) trackUnmapped(headers []string, mapping map[string]int) *unmappedTracker {
+	mapped := make(map[int]bool, len(mapping))
+	for _, idx := range mapping {
+		mapped[idx] = true
+	}
+
+	p.metadataColumns = nil
+	if p.KeepUnmappedColumns {
+		p.metadataColumns = make(map[int]string)
+		names := make(map[string]bool)
+		for i, header := range headers {
+			if mapped[i] {
+				continue
+			}
+			// Custom field names cannot contain double quotes
+			name := UnmappedColumn{Column: i, Header: strings.TrimSpace(header)}.Name()
+			name = strings.ReplaceAll(name, `"`, "'")
+			if names[name] {
+				name = fmt.Sprintf("%s (column %d)", name, i+1)
+			}
+			names[name] = true
+			p.metadataColumns[i] = name
+		}
+	}
+
+	return &unmappedTracker{
+		headers: headers,
+		mapped:  mapped,
+		columns: make(map[int]*UnmappedColumn),
+		kept:    p.KeepUnmappedColumns,
+	}
+}
+
+// rowMetadata returns the values of a row's unmapped columns, keyed by
+// header, or nil if they are not kept or all empty
+func (p *HTMLTableParser) rowMetadata(row []string) models.Metadata {
+	var metadata models.Metadata
+	for i, name := range p.metadataColumns {
+		if i >= len(row) {
+			continue
+		}
+		if value := strings.TrimSpace(row[i]); value != "" {
+			if metadata == nil {
+				metadata = models.Metadata{}
+			}
+			metadata[name] = value
+		}
+	}
+	return metadata
+}
+
+// observe records the values of a data row in unmapped columns. Rows may
+// have more cells than there are headers.
+func (t *unmappedTracker) observe(row []string) {
+	for i, cell := range row {
+		value := strings.TrimSpace(cell)
+		if t.mapped[i] || value == "" {
+			continue
+		}
+
+		column, ok := t.columns[i]
+		if !ok {
+			column = &UnmappedColumn{Column: i, Sample: value}
+			if i < len(t.headers) {
+				column.Header = strings.TrimSpace(t.headers[i])
+			}
+			t.columns[i] = column
+		}
+		column.Values++
+	}
+}
+
+// results returns the unmapped columns that held data, in column order, and
+// a warning listing them. There is no warning if there are none, or if their
+// values were kept in record metadata.
+func (t *unmappedTracker) results(table int) ([]UnmappedColumn, []ParseWarning) {
+	if len(t.columns) == 0 {
+		return nil, nil
+	}
+
+	var columns []UnmappedColumn
+	var names []string
+	for i := 0; len(columns) < len(t.columns); i++ {
+		column, ok := t.columns[i]
+		if !ok {
+			continue
+		}
+		column.Table = table
+		columns = append(columns, *column)
+		names = append(names, fmt.Sprintf("%q", column.Name()))
+	}
+	if t.kept {
+		return columns, nil
+	}
+
+	warning := ParseWarning{
+		Table:   table,
+		Message: fmt.Sprintf("%d columns with data were not imported: %s", len(columns), strings.Join(names, ", ")),
+	}
+	if len(columns) == 1 {
+		warning.Message = fmt.Sprintf("Column %s has data but was not imported", names[0])
+	}
+	return columns, []ParseWarning{warning}
+}
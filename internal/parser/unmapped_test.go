@@ -0,0 +1,96 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"sales-track/internal/models"
+)
+
+func TestParseHTML_UnmappedColumns(t *testing.T) {
+	table := `<table>
+		<tr><th>Item #</th><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th><th>Payout Date</th><th>Notes</th></tr>
+		<tr><td>A-1</td><td>Store A</td><td>Vendor 1</td><td>2024-03-15</td><td>Lamp</td><td>40.00</td><td>2024-04-01</td><td></td></tr>
+		<tr><td>A-2</td><td>Store A</td><td>Vendor 1</td><td>2024-03-16</td><td>Chair</td><td>75.00</td><td></td><td></td></tr>
+	</table>`
+
+	result, err := NewHTMLTableParser().ParseHTML(table)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+
+	expected := []UnmappedColumn{
+		{Column: 0, Header: "Item #", Values: 2, Sample: "A-1"},
+		{Column: 6, Header: "Payout Date", Values: 1, Sample: "2024-04-01"},
+	}
+	if len(result.UnmappedColumns) != len(expected) {
+		t.Fatalf("Expected unmapped columns %+v, got %+v", expected, result.UnmappedColumns)
+	}
+	for i, want := range expected {
+		if result.UnmappedColumns[i] != want {
+			t.Errorf("Expected unmapped column %+v, got %+v", want, result.UnmappedColumns[i])
+		}
+	}
+
+	var warning string
+	for _, w := range result.Warnings {
+		if strings.Contains(w.Message, "not imported") {
+			warning = w.Message
+		}
+	}
+	if warning != `2 columns with data were not imported: "Item #", "Payout Date"` {
+		t.Errorf("Expected a warning listing the unmapped columns, got %q", warning)
+	}
+
+	// The streaming parser reports the same columns
+	streamed, _, err := collectStream(t, NewHTMLTableParser(), table)
+	if err != nil {
+		t.Fatalf("ParseHTMLStream failed: %v", err)
+	}
+	if len(streamed.UnmappedColumns) != 2 || streamed.UnmappedColumns[0] != expected[0] {
+		t.Errorf("Expected streamed unmapped columns %+v, got %+v", expected, streamed.UnmappedColumns)
+	}
+}
+
+func TestParseHTML_KeepUnmappedColumns(t *testing.T) {
+	table := `<table>
+		<tr><th>Item #</th><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th><th>Payout Date</th><th>Notes</th></tr>
+		<tr><td>A-1</td><td>Store A</td><td>Vendor 1</td><td>2024-03-15</td><td>Lamp</td><td>40.00</td><td>2024-04-01</td><td></td></tr>
+		<tr><td>A-2</td><td>Store A</td><td>Vendor 1</td><td>2024-03-16</td><td>Chair</td><td>75.00</td><td></td><td></td></tr>
+	</table>`
+
+	parser := NewHTMLTableParser()
+	parser.KeepUnmappedColumns = true
+	result, err := parser.ParseHTML(table)
+	if err != nil {
+		t.Fatalf("ParseHTML failed: %v", err)
+	}
+
+	expected := []models.Metadata{
+		{"Item #": "A-1", "Payout Date": "2024-04-01"},
+		{"Item #": "A-2"},
+	}
+	for i, want := range expected {
+		if !result.Records[i].Metadata.Equal(want) {
+			t.Errorf("Expected record %d metadata %v, got %v", i, want, result.Records[i].Metadata)
+		}
+	}
+	if len(result.UnmappedColumns) != 2 {
+		t.Errorf("Expected kept columns to still be reported, got %+v", result.UnmappedColumns)
+	}
+	for _, w := range result.Warnings {
+		if strings.Contains(w.Message, "not imported") {
+			t.Errorf("Expected no warning for kept columns, got %q", w.Message)
+		}
+	}
+
+	streamParser := NewHTMLTableParser()
+	streamParser.KeepUnmappedColumns = true
+	_, records, err := collectStream(t, streamParser, table)
+	if err != nil {
+		t.Fatalf("ParseHTMLStream failed: %v", err)
+	}
+	if len(records) != 2 || !records[0].Metadata.Equal(expected[0]) {
+		t.Errorf("Expected streamed records to keep metadata %v, got %+v", expected[0], records)
+	}
+}
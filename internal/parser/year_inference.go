@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"fmt"
+	"time"
+)
+
+// Where dates written without a year, such as "Mar 15", take their year from
+const (
+	YearFromContext = "context" // The year named in the table's caption or heading; the default
+	YearFromImport  = "import"  // The year the import runs in
+	YearFixed       = "fixed"   // YearInference.Year
+)
+
+// YearInference chooses the year of dates written without one. Without a
+// year to give them, such dates fail their rows.
+type YearInference struct {
+	Source string `json:"source,omitempty"` // YearFromContext, YearFromImport or YearFixed; empty means YearFromContext
+	Year   int    `json:"year,omitempty"`   // The year for YearFixed
+}
+
+// Validate checks that the source is known and a fixed year is given
+func (y YearInference) Validate() error {
+	switch y.Source {
+	case "", YearFromContext, YearFromImport:
+		return nil
+	case YearFixed:
+		if y.Year < 1900 || y.Year > 2099 {
+			return fmt.Errorf("year for dates without one must be between 1900 and 2099")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown year inference source %q; must be %s, %s or %s", y.Source, YearFromContext, YearFromImport, YearFixed)
+}
+
+// yearlessYear returns the year dates without one take in the table being
+// parsed, or 0 if they cannot be read
+func (p *HTMLTableParser) yearlessYear() int {
+	switch p.YearInference.Source {
+	case YearFromImport:
+		return time.Now().Year()
+	case YearFixed:
+		return p.YearInference.Year
+	}
+	return p.contextYear
+}
+
+// yearlessSource describes where yearlessYear comes from, for warnings
+func (p *HTMLTableParser) yearlessSource(context TableContext) string {
+	switch p.YearInference.Source {
+	case YearFromImport:
+		return "the year of the import"
+	case YearFixed:
+		return "the year set for the import"
+	}
+	return fmt.Sprintf("%q", context.Title())
+}
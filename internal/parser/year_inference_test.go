@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestYearInference tests choosing the year of dates written without one
+func TestYearInference(t *testing.T) {
+	table := `<h2>Sales Report 2023</h2>
+	<table>
+		<tr><th>Store</th><th>Vendor</th><th>Date</th><th>Description</th><th>Sale Price</th></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>Mar 15</td><td>Lamp</td><td>40.00</td></tr>
+		<tr><td>Store A</td><td>Vendor 1</td><td>2024-04-04</td><td>Chair</td><td>75.00</td></tr>
+	</table>`
+	withoutHeading := table[strings.Index(table, "<table>"):]
+
+	tests := []struct {
+		name      string
+		inference YearInference
+		data      string
+		date      string
+		source    string
+	}{
+		{"context by default", YearInference{}, table, "2023-03-15", `"Sales Report 2023"`},
+		{"fixed over the context", YearInference{Source: YearFixed, Year: 2021}, table, "2021-03-15", "the year set for the import"},
+		{"fixed without a context", YearInference{Source: YearFixed, Year: 2022}, withoutHeading, "2022-03-15", "the year set for the import"},
+		{"year of the import", YearInference{Source: YearFromImport}, withoutHeading, time.Now().Format("2006") + "-03-15", "the year of the import"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewHTMLTableParser()
+			p.YearInference = tt.inference
+			result, err := p.ParseHTML(tt.data)
+			if err != nil {
+				t.Fatalf("ParseHTML failed: %v", err)
+			}
+			if result.SuccessCount != 2 || result.Records[0].Date != tt.date || result.Records[1].Date != "2024-04-04" {
+				t.Fatalf("Expected the yearless date read as %s, got %+v %+v", tt.date, result.Records, result.Errors)
+			}
+
+			var warned bool
+			for _, warning := range result.Warnings {
+				if strings.Contains(warning.Message, "1 dates without a year were read as "+tt.date[:4]+", from "+tt.source) {
+					warned = true
+				}
+			}
+			if !warned {
+				t.Errorf("Expected a warning naming %s, got %+v", tt.source, result.Warnings)
+			}
+		})
+	}
+
+	for _, invalid := range []YearInference{{Source: "caption"}, {Source: YearFixed}, {Source: YearFixed, Year: 24}} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected %+v to be refused", invalid)
+		}
+	}
+}
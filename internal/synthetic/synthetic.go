@@ -0,0 +1,80 @@
+// Package synthetic generates reproducible sales data for benchmarks and
+// profiling. The same seed always produces the same records.
+package synthetic
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"sales-track/internal/models"
+)
+
+var (
+	stores     = []string{"Downtown", "Uptown", "Riverside", "Mall", "Airport", "Harbor", "Old Town", "Westside"}
+	products   = []string{"Lamp", "Chair", "Table", "Mirror", "Vase", "Rug", "Clock", "Painting", "Dresser", "Bookcase"}
+	adjectives = []string{"Vintage", "Antique", "Modern", "Oak", "Brass", "Walnut", "Ceramic", "Painted"}
+)
+
+// vendorCount is the number of distinct vendors generated
+const vendorCount = 250
+
+// Generator produces synthetic sales records
+type Generator struct {
+	rng   *rand.Rand
+	start time.Time
+	days  int
+}
+
+// NewGenerator creates a generator spreading records over the given number of
+// years ending at 2024-12-31
+func NewGenerator(seed int64, years int) *Generator {
+	end := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	start := end.AddDate(-years, 0, 1)
+	return &Generator{
+		rng:   rand.New(rand.NewSource(seed)),
+		start: start,
+		days:  int(end.Sub(start).Hours()/24) + 1,
+	}
+}
+
+// Next returns the next synthetic record
+func (g *Generator) Next() models.CreateSalesRecordRequest {
+	price := math.Round((5+g.rng.ExpFloat64()*60)*100) / 100
+	record := models.CreateSalesRecordRequest{
+		Store:  stores[g.rng.Intn(len(stores))],
+		Vendor: fmt.Sprintf("Vendor %03d", g.rng.Intn(vendorCount)),
+		Date:   g.start.AddDate(0, 0, g.rng.Intn(g.days)).Format("2006-01-02"),
+		Description: fmt.Sprintf("%s %s #%d",
+			adjectives[g.rng.Intn(len(adjectives))], products[g.rng.Intn(len(products))], g.rng.Intn(1000)),
+		SalePrice: price,
+	}
+
+	// Roughly one record in ten comes from a report without commission data
+	if g.rng.Intn(10) != 0 {
+		commission := math.Round(price*0.2*100) / 100
+		remaining := math.Round((price-commission)*100) / 100
+		record.Commission = &commission
+		record.Remaining = &remaining
+	}
+
+	return record
+}
+
+// Records returns n synthetic records
+func (g *Generator) Records(n int) []models.CreateSalesRecordRequest {
+	records := make([]models.CreateSalesRecordRequest, n)
+	for i := range records {
+		records[i] = g.Next()
+	}
+	return records
+}
+
+// Stream sends n synthetic records to out and closes it
+func (g *Generator) Stream(n int, out chan<- models.CreateSalesRecordRequest) {
+	defer close(out)
+	for i := 0; i < n; i++ {
+		out <- g.Next()
+	}
+}
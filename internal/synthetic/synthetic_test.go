@@ -0,0 +1,46 @@
+package synthetic
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestGeneratorReproducible tests that a seed always yields the same records
+func TestGeneratorReproducible(t *testing.T) {
+	first := NewGenerator(42, 2).Records(100)
+	second := NewGenerator(42, 2).Records(100)
+
+	if !reflect.DeepEqual(first, second) {
+		t.Error("Expected identical records for the same seed")
+	}
+
+	other := NewGenerator(43, 2).Records(100)
+	if reflect.DeepEqual(first, other) {
+		t.Error("Expected different records for a different seed")
+	}
+}
+
+// TestGeneratorValues tests that generated records are valid for import
+func TestGeneratorValues(t *testing.T) {
+	unknownCommission := 0
+	for _, record := range NewGenerator(1, 5).Records(1000) {
+		if record.Store == "" || record.Vendor == "" || record.Description == "" {
+			t.Fatalf("Expected required fields to be set, got %+v", record)
+		}
+		if record.SalePrice <= 0 {
+			t.Fatalf("Expected positive sale price, got %.2f", record.SalePrice)
+		}
+		if record.Date < "2020-01-01" || record.Date > "2024-12-31" {
+			t.Fatalf("Expected date within five years ending 2024-12-31, got %s", record.Date)
+		}
+		if record.Commission == nil {
+			unknownCommission++
+		} else if *record.Commission+*record.Remaining-record.SalePrice > 0.011 {
+			t.Fatalf("Expected commission + remaining to equal sale price, got %+v", record)
+		}
+	}
+
+	if unknownCommission == 0 {
+		t.Error("Expected some records without commission data")
+	}
+}
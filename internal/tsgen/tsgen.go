@@ -0,0 +1,223 @@
+// Package tsgen writes TypeScript interfaces for the Go types the frontend
+// receives, following the rules encoding/json uses to encode them. Generating
+// the interfaces keeps the frontend's view of results such as ImportResult
+// from drifting as fields are added.
+package tsgen
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Generate returns an interface for each named struct type reachable from
+// types, sorted by name. Types with their own JSON or text encoding, such as
+// time.Time, are written as strings. A name used by structs in two packages
+// is prefixed with the package name for all but the first one found.
+func Generate(types ...reflect.Type) string {
+	g := &generator{
+		names: make(map[reflect.Type]string),
+		taken: make(map[string]reflect.Type),
+	}
+	for _, t := range types {
+		g.tsType(t)
+	}
+
+	interfaces := make(map[string]string)
+	for len(g.queue) > 0 {
+		t := g.queue[0]
+		g.queue = g.queue[1:]
+		interfaces[g.names[t]] = g.writeInterface(t)
+	}
+
+	names := make([]string, 0, len(interfaces))
+	for name := range interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(interfaces[name])
+	}
+	return b.String()
+}
+
+// generator names the struct types found and queues them to be written
+type generator struct {
+	names map[reflect.Type]string
+	taken map[string]reflect.Type
+	queue []reflect.Type
+}
+
+// field is a property of an interface
+type field struct {
+	name     string
+	tsType   string
+	optional bool
+}
+
+// tsType returns the TypeScript type JSON values of t take
+func (g *generator) tsType(t reflect.Type) string {
+	if t.Implements(jsonMarshaler) || reflect.PointerTo(t).Implements(jsonMarshaler) ||
+		t.Implements(textMarshaler) || reflect.PointerTo(t).Implements(textMarshaler) {
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.tsType(t.Elem())
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string" // Encoded as base64
+		}
+		elem := g.tsType(t.Elem())
+		if strings.Contains(elem, " ") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
+	case reflect.Map:
+		return fmt.Sprintf("Record<string, %s>", g.tsType(t.Elem()))
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.inlineStruct(t)
+		}
+		return g.structName(t)
+	default:
+		return "unknown"
+	}
+}
+
+// structName returns the interface name of a named struct, queueing the
+// struct to be written the first time it is seen
+func (g *generator) structName(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, ok := g.taken[name]; ok {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	g.names[t] = name
+	g.taken[name] = t
+	g.queue = append(g.queue, t)
+	return name
+}
+
+// inlineStruct writes an anonymous struct as an object type
+func (g *generator) inlineStruct(t reflect.Type) string {
+	var props []string
+	for _, f := range g.fields(t) {
+		props = append(props, f.String())
+	}
+	return "{ " + strings.Join(props, "; ") + " }"
+}
+
+// writeInterface writes the interface for a named struct
+func (g *generator) writeInterface(t reflect.Type) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "export interface %s {\n", g.names[t])
+	for _, f := range g.fields(t) {
+		fmt.Fprintf(&b, "  %s;\n", f)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// fields lists the JSON properties of a struct. Embedded structs without a
+// JSON name have their fields promoted, as encoding/json does.
+func (g *generator) fields(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := sf.Type
+		if sf.Anonymous && name == "" {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fields = append(fields, g.fields(ft)...)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		f := field{name: name, tsType: g.tsType(ft), optional: hasOption(opts, "omitempty")}
+		if hasOption(opts, "string") {
+			f.tsType = "string"
+		}
+		if ft.Kind() == reflect.Pointer && !f.optional {
+			f.tsType += " | null"
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// String writes the field as an interface property
+func (f field) String() string {
+	name := f.name
+	if !isIdentifier(name) {
+		name = fmt.Sprintf("%q", name)
+	}
+	if f.optional {
+		return fmt.Sprintf("%s?: %s", name, f.tsType)
+	}
+	return fmt.Sprintf("%s: %s", name, f.tsType)
+}
+
+// hasOption reports whether a json tag's options include option
+func hasOption(opts, option string) bool {
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// isIdentifier reports whether name can be written as a property without quotes
+func isIdentifier(name string) bool {
+	for i, r := range name {
+		switch {
+		case r == '_' || r == '$' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
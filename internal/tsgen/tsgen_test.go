@@ -0,0 +1,82 @@
+package tsgen
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type base struct {
+	ID      int64     `json:"id"`
+	Created time.Time `json:"created_at"`
+}
+
+type item struct {
+	Name string `json:"name"`
+}
+
+type order struct {
+	base
+	Items    []item            `json:"items"`
+	Note     *string           `json:"note"`
+	Coupon   *string           `json:"coupon,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Total    float64           `json:"total,string"`
+	Raw      []byte            `json:"raw"`
+	Extra    interface{}       `json:"extra"`
+	Skipped  string            `json:"-"`
+	Untagged bool
+	internal int
+}
+
+func TestGenerate(t *testing.T) {
+	got := Generate(reflect.TypeOf(&order{}))
+
+	expected := `export interface item {
+  name: string;
+}
+
+export interface order {
+  id: number;
+  created_at: string;
+  items: item[];
+  note: string | null;
+  coupon?: string;
+  tags?: Record<string, string>;
+  total: string;
+  raw: string;
+  extra: unknown;
+  Untagged: boolean;
+}
+`
+	if got != expected {
+		t.Errorf("Unexpected output:\n%s\nExpected:\n%s", got, expected)
+	}
+}
+
+func TestGenerate_NameCollision(t *testing.T) {
+	type item struct {
+		SKU string `json:"sku"`
+	}
+	type wrapper struct {
+		First  base `json:"first"`
+		Second item `json:"second"`
+		Third  struct {
+			Item item `json:"item"`
+		} `json:"third"`
+	}
+
+	got := Generate(reflect.TypeOf(wrapper{}), reflect.TypeOf(order{}))
+	for _, want := range []string{
+		"second: item;",
+		"third: { item: item };",
+		"export interface item {\n  sku: string;\n}",
+		"items: Tsgenitem[];",
+		"export interface Tsgenitem {\n  name: string;\n}",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, got)
+		}
+	}
+}
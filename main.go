@@ -25,6 +25,7 @@ func main() {
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.startup,
+		ErrorFormatter:   formatBindingError,
 		Bind: []interface{}{
 			app,
 		},